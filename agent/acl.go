@@ -136,6 +136,24 @@ func (a *Agent) vetServiceUpdate(token string, serviceID string) error {
 	return nil
 }
 
+// vetNodeUpdate makes sure the given token is allowed to change the modes of
+// this agent's node, such as maintenance and drain modes.
+func (a *Agent) vetNodeUpdate(token string) error {
+	// Resolve the token and bail if ACLs aren't enabled.
+	rule, err := a.resolveToken(token)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return nil
+	}
+
+	if !rule.NodeWrite(a.config.NodeName, nil) {
+		return acl.ErrPermissionDenied
+	}
+	return nil
+}
+
 // vetCheckRegister makes sure the check registration action is allowed by the
 // given token.
 func (a *Agent) vetCheckRegister(token string, check *structs.HealthCheck) error {
@@ -287,6 +287,16 @@ func fixCreateTimeAndHash(raw interface{}) error {
 		}
 	}
 
+	if val, ok := rawMap["ExpirationTime"]; ok {
+		if sval, ok := val.(string); ok {
+			t, err := time.Parse(time.RFC3339, sval)
+			if err != nil {
+				return err
+			}
+			rawMap["ExpirationTime"] = t
+		}
+	}
+
 	if val, ok := rawMap["Hash"]; ok {
 		if sval, ok := val.(string); ok {
 			rawMap["Hash"] = []byte(sval)
@@ -504,6 +514,48 @@ func (s *HTTPServer) ACLTokenDelete(resp http.ResponseWriter, req *http.Request,
 	return true, nil
 }
 
+func (s *HTTPServer) ACLLogin(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if s.checkACLDisabled(resp, req) {
+		return nil, nil
+	}
+
+	args := structs.ACLLoginRequest{
+		Datacenter: s.agent.config.Datacenter,
+		Auth:       &structs.ACLLoginParams{},
+	}
+	s.parseDC(req, &args.Datacenter)
+
+	if err := decodeBody(req, args.Auth, nil); err != nil {
+		return nil, BadRequestError{Reason: fmt.Sprintf("Failed to decode request body: %v", err)}
+	}
+
+	var out structs.ACLToken
+	if err := s.agent.RPC("ACL.Login", &args, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+func (s *HTTPServer) ACLLogout(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if s.checkACLDisabled(resp, req) {
+		return nil, nil
+	}
+
+	args := structs.ACLLogoutRequest{
+		Datacenter: s.agent.config.Datacenter,
+	}
+	s.parseDC(req, &args.Datacenter)
+	s.parseToken(req, &args.Token)
+
+	var ignored bool
+	if err := s.agent.RPC("ACL.Logout", &args, &ignored); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
 func (s *HTTPServer) ACLTokenClone(resp http.ResponseWriter, req *http.Request, tokenID string) (interface{}, error) {
 	if s.checkACLDisabled(resp, req) {
 		return nil, nil
@@ -143,6 +143,18 @@ func (a *TestACLAgent) Shutdown() error {
 func (a *TestACLAgent) Stats() map[string]map[string]string {
 	return nil
 }
+func (a *TestACLAgent) DebugState() *consul.DebugState {
+	return nil
+}
+func (a *TestACLAgent) FlushCaches() (*consul.FlushedCaches, error) {
+	return nil, fmt.Errorf("Unimplemented")
+}
+func (a *TestACLAgent) FaultRules() ([]structs.FaultRule, error) {
+	return nil, fmt.Errorf("Unimplemented")
+}
+func (a *TestACLAgent) SetFaultRules(rules []structs.FaultRule) error {
+	return fmt.Errorf("Unimplemented")
+}
 func (a *TestACLAgent) ReloadConfig(config *consul.Config) error {
 	return fmt.Errorf("Unimplemented")
 }
@@ -321,6 +333,19 @@ func TestACL_vetServiceUpdate(t *testing.T) {
 	require.True(t, acl.IsErrPermissionDenied(err))
 }
 
+func TestACL_vetNodeUpdate(t *testing.T) {
+	t.Parallel()
+	a := NewTestACLAgent(t.Name(), TestACLConfig(), catalogPolicy)
+
+	// Update with write privs.
+	require.NoError(t, a.vetNodeUpdate("node-rw"))
+
+	// Update without write privs.
+	err := a.vetNodeUpdate("node-ro")
+	require.Error(t, err)
+	require.True(t, acl.IsErrPermissionDenied(err))
+}
+
 func TestACL_vetCheckRegister(t *testing.T) {
 	t.Parallel()
 	a := NewTestACLAgent(t.Name(), TestACLConfig(), catalogPolicy)
@@ -44,6 +44,7 @@ import (
 	"github.com/hashicorp/consul/watch"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/logutils"
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
@@ -67,6 +68,14 @@ const (
 		"but no reason was provided. This is a default message."
 	defaultServiceMaintReason = "Maintenance mode is enabled for this " +
 		"service, but no reason was provided. This is a default message."
+
+	// Default reason for node drain mode
+	defaultNodeDrainReason = "Drain mode is enabled for this node, " +
+		"but no reason was provided. This is a default message."
+
+	// leaveMaintReason is the node maintenance reason used while the agent
+	// waits to leave the cluster.
+	leaveMaintReason = "This node is leaving the cluster."
 )
 
 type configSource int
@@ -95,6 +104,10 @@ type delegate interface {
 	SnapshotRPC(args *structs.SnapshotRequest, in io.Reader, out io.Writer, replyFn structs.SnapshotReplyFn) error
 	Shutdown() error
 	Stats() map[string]map[string]string
+	DebugState() *consul.DebugState
+	FlushCaches() (*consul.FlushedCaches, error)
+	FaultRules() ([]structs.FaultRule, error)
+	SetFaultRules(rules []structs.FaultRule) error
 	ReloadConfig(config *consul.Config) error
 	enterpriseDelegate
 }
@@ -123,6 +136,10 @@ type Agent struct {
 	// Used for streaming logs to
 	LogWriter *logger.LogWriter
 
+	// LogFilter is the filter setting the log level, which can be changed
+	// at runtime. It's nil if the agent wasn't started by the agent command.
+	LogFilter *logutils.LevelFilter
+
 	// In-memory sink used for collecting metrics
 	MemSink *metrics.InmemSink
 
@@ -177,6 +194,11 @@ type Agent struct {
 	// checkLock protects updates to the check* maps
 	checkLock sync.Mutex
 
+	// checkScheduler runs the interval checks from a shared pool of
+	// workers, if check_workers is set. Otherwise each check runs on a
+	// goroutine of its own.
+	checkScheduler *checks.Scheduler
+
 	// dockerClient is the client for performing docker health checks.
 	dockerClient *checks.DockerClient
 
@@ -221,14 +243,43 @@ type Agent struct {
 	wgServers sync.WaitGroup
 
 	// watchPlans tracks all the currently-running watch plans for the
-	// agent.
-	watchPlans []*watch.Plan
+	// agent, protected by watchPlansLock.
+	watchPlans     []*watch.Plan
+	watchPlansLock sync.Mutex
+
+	// templates are the runners for the currently configured templates,
+	// protected by templatesLock.
+	templates     []*templateRunner
+	templatesLock sync.Mutex
+
+	// servicesDir watches the configured services directory, protected by
+	// servicesDirLock.
+	servicesDir     *servicesDirWatcher
+	servicesDirLock sync.Mutex
+
+	// hooks runs the configured hooks when local services and checks
+	// change.
+	hooks *hookRunner
+
+	// benchStop ends the benchmark the agent is running, if any, protected
+	// by benchLock.
+	benchStop context.CancelFunc
+	benchLock sync.Mutex
 
 	// tokens holds ACL tokens initially from the configuration, but can
 	// be updated at runtime, so should always be used instead of going to
 	// the configuration directly.
 	tokens *token.Store
 
+	// persistedTokensLock serializes saving the tokens set through the API
+	// to the data dir.
+	persistedTokensLock sync.Mutex
+
+	// runtimeSettingsLock serializes changes to the settings that can be
+	// changed at runtime, so each change is logged against the right
+	// previous value.
+	runtimeSettingsLock sync.Mutex
+
 	// proxyManager is the proxy process manager for managed Connect proxies.
 	proxyManager *proxyprocess.Manager
 
@@ -249,6 +300,10 @@ type Agent struct {
 	// grpcServer is the server instance used currently to serve xDS API for
 	// Envoy.
 	grpcServer *grpc.Server
+
+	// wanGateway passes connections from servers in other datacenters on to
+	// the servers in this one, when the agent is a WAN federation gateway.
+	wanGateway *consul.WANGateway
 }
 
 func New(c *config.RuntimeConfig) (*Agent, error) {
@@ -283,11 +338,25 @@ func New(c *config.RuntimeConfig) (*Agent, error) {
 		return nil, err
 	}
 
-	// Set up the initial state of the token store based on the config.
-	a.tokens.UpdateUserToken(a.config.ACLToken)
-	a.tokens.UpdateAgentToken(a.config.ACLAgentToken)
-	a.tokens.UpdateAgentMasterToken(a.config.ACLAgentMasterToken)
-	a.tokens.UpdateACLReplicationToken(a.config.ACLReplicationToken)
+	// Set up the initial state of the token store based on the config. The
+	// tokens saved through the API take precedence, if persistence is on.
+	persisted, err := a.loadPersistedTokens()
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]string{
+		"acl_token":              a.config.ACLToken,
+		"acl_agent_token":        a.config.ACLAgentToken,
+		"acl_agent_master_token": a.config.ACLAgentMasterToken,
+		"acl_replication_token":  a.config.ACLReplicationToken,
+	}
+	for target, token := range persisted {
+		tokens[target] = token
+	}
+	for target, token := range tokens {
+		a.setToken(target, token)
+	}
+	a.tokens.SetRotationGrace(a.config.ACLTokenRotationGrace)
 
 	return a, nil
 }
@@ -296,6 +365,7 @@ func LocalConfig(cfg *config.RuntimeConfig) local.Config {
 	lc := local.Config{
 		AdvertiseAddr:       cfg.AdvertiseAddrLAN.String(),
 		CheckUpdateInterval: cfg.CheckUpdateInterval,
+		CheckSyncLimit:      cfg.CheckOutputSyncLimit,
 		Datacenter:          cfg.Datacenter,
 		DiscardCheckOutput:  cfg.DiscardCheckOutput,
 		NodeID:              cfg.NodeID,
@@ -372,6 +442,11 @@ func (a *Agent) Start() error {
 	// create the cache
 	a.cache = cache.New(nil)
 
+	// create the scheduler for the interval checks
+	if c.CheckWorkers > 0 {
+		a.checkScheduler = checks.NewScheduler(c.CheckWorkers)
+	}
+
 	// create the config for the rpc server/client
 	consulCfg, err := a.consulConfig()
 	if err != nil {
@@ -412,7 +487,19 @@ func (a *Agent) Start() error {
 	// populated from above.
 	a.registerCache()
 
-	// Load checks/services/metadata.
+	// Start running hooks before the services and checks are loaded so
+	// hooks also see the initial registrations.
+	a.hooks = newHookRunner(c.NodeName, a.State, a.LogOutput, a.logger)
+	a.hooks.lock.Lock()
+	a.hooks.setHooks(c.Hooks)
+	a.hooks.lock.Unlock()
+	go a.hooks.Run(a.shutdownCh)
+
+	// Load checks/services/metadata, after making sure the persisted ones
+	// can be loaded.
+	if err := a.reconcilePersistedState(c); err != nil {
+		return err
+	}
 	if err := a.loadServices(c); err != nil {
 		return err
 	}
@@ -422,10 +509,16 @@ func (a *Agent) Start() error {
 	if err := a.loadChecks(c); err != nil {
 		return err
 	}
+	a.reloadServicesDir(c)
 	if err := a.loadMetadata(c); err != nil {
 		return err
 	}
 
+	// Seed a dev agent with its fixtures, after its own services.
+	if err := a.loadDevFixtures(c.DevFixtures); err != nil {
+		return err
+	}
+
 	// create the proxy process manager and start it. This is purposely
 	// done here after the local state above is loaded in so we can have
 	// a more accurate initial state view.
@@ -499,11 +592,19 @@ func (a *Agent) Start() error {
 		return err
 	}
 
+	// Start the WAN federation gateway.
+	if err := a.listenAndServeWANGateway(); err != nil {
+		return err
+	}
+
 	// register watches
 	if err := a.reloadWatches(a.config); err != nil {
 		return err
 	}
 
+	// start rendering templates
+	a.reloadTemplates(a.config)
+
 	// start retry join
 	go a.retryJoinLAN()
 	go a.retryJoinWAN()
@@ -546,6 +647,23 @@ func (a *Agent) listenAndServeGRPC() error {
 	return nil
 }
 
+func (a *Agent) listenAndServeWANGateway() error {
+	if a.config.WANGatewayBindAddr == nil {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", a.config.WANGatewayBindAddr.String())
+	if err != nil {
+		return err
+	}
+
+	domain := strings.TrimSuffix(a.config.DNSDomain, ".")
+	a.wanGateway = consul.NewWANGateway(ln, a.config.Datacenter, domain, a.delegate.LANMembers, a.logger)
+	a.logger.Printf("[INFO] agent: Started WAN federation gateway on %s", ln.Addr().String())
+	go a.wanGateway.Serve()
+	return nil
+}
+
 func (a *Agent) listenAndServeDNS() error {
 	notif := make(chan net.Addr, len(a.config.DNSAddrs))
 	errCh := make(chan error, len(a.config.DNSAddrs))
@@ -655,7 +773,9 @@ func (a *Agent) listenHTTP() ([]*HTTPServer, error) {
 				ln:        l,
 				agent:     a,
 				blacklist: NewBlacklist(a.config.HTTPBlockEndpoints),
-				proto:     proto,
+				authorizer: newHTTPAuthorizer(a.config.HTTPAuthorizerURL,
+					a.config.HTTPAuthorizerCategories, a.config.HTTPAuthorizerTimeout),
+				proto: proto,
 			}
 			srv.Server.Handler = srv.handler(a.config.EnableDebug)
 
@@ -757,6 +877,9 @@ func (a *Agent) serveHTTP(srv *HTTPServer) error {
 // reloadWatches stops any existing watch plans and attempts to load the given
 // set of watches.
 func (a *Agent) reloadWatches(cfg *config.RuntimeConfig) error {
+	a.watchPlansLock.Lock()
+	defer a.watchPlansLock.Unlock()
+
 	// Stop the current watches.
 	for _, wp := range a.watchPlans {
 		wp.Stop()
@@ -876,6 +999,7 @@ func (a *Agent) consulConfig() (*consul.Config, error) {
 
 	// Apply dev mode
 	base.DevMode = a.config.DevMode
+	base.FaultInjection = a.config.FaultInjection
 
 	// Override with our config
 	// todo(fs): these are now always set in the runtime config so we can simplify this
@@ -950,6 +1074,7 @@ func (a *Agent) consulConfig() (*consul.Config, error) {
 	if a.config.BootstrapExpect != 0 {
 		base.BootstrapExpect = a.config.BootstrapExpect
 	}
+	base.BootstrapDataDir = a.config.BootstrapDataDir
 	if a.config.RPCProtocol > 0 {
 		base.ProtocolVersion = uint8(a.config.RPCProtocol)
 	}
@@ -986,12 +1111,22 @@ func (a *Agent) consulConfig() (*consul.Config, error) {
 	if a.config.ACLEnableKeyListPolicy {
 		base.ACLEnableKeyListPolicy = a.config.ACLEnableKeyListPolicy
 	}
+	base.ACLTokenUnusedPeriod = a.config.ACLTokenUnusedPeriod
+	if a.config.ACLUnusedAction != "" {
+		base.ACLTokenUnusedAction = a.config.ACLUnusedAction
+	}
 	if a.config.SessionTTLMin != 0 {
 		base.SessionTTLMin = a.config.SessionTTLMin
 	}
+	base.SessionLockDelayMin = a.config.SessionLockDelayMin
+	base.SessionLockDelayMax = a.config.SessionLockDelayMax
 	if a.config.NonVotingServer {
 		base.NonVoter = a.config.NonVotingServer
 	}
+	base.ExternalChecksEnabled = a.config.EnableExternalChecks
+	base.ExternalCheckAllowedCIDRs = a.config.ExternalCheckAllowedCIDRs
+	base.ExternalNodeProbeInterval = a.config.ExternalNodeProbeInterval
+	base.ExternalNodeProbeTimeout = a.config.ExternalNodeProbeTimeout
 
 	// These are fully specified in the agent defaults, so we can simply
 	// copy them over.
@@ -1016,13 +1151,87 @@ func (a *Agent) consulConfig() (*consul.Config, error) {
 		base.RPCMaxBurst = a.config.RPCMaxBurst
 	}
 
+	// Rate limiting for RPC calls handled by servers.
+	if a.config.RPCServerReadRate > 0 {
+		base.RPCServerReadRate = a.config.RPCServerReadRate
+	}
+	if a.config.RPCServerWriteRate > 0 {
+		base.RPCServerWriteRate = a.config.RPCServerWriteRate
+	}
+	if a.config.RPCServerTokenRate > 0 {
+		base.RPCServerTokenRate = a.config.RPCServerTokenRate
+	}
+	if a.config.RPCServerIPRate > 0 {
+		base.RPCServerIPRate = a.config.RPCServerIPRate
+	}
+	if a.config.RPCServerMaxBurst > 0 {
+		base.RPCServerMaxBurst = a.config.RPCServerMaxBurst
+	}
+
+	// Throttling of the catalog updates made by the leader for Serf events.
+	if a.config.ReconcileRate > 0 {
+		base.ReconcileRate = a.config.ReconcileRate
+	}
+	if a.config.ReconcileMaxBurst > 0 {
+		base.ReconcileMaxBurst = a.config.ReconcileMaxBurst
+	}
+	base.ReconcilePanicThreshold = a.config.ReconcilePanicThreshold
+
+	// Limits on concurrent blocking queries.
+	base.DatacenterForwardingAllow = a.config.DatacenterForwardingAllow
+	base.DatacenterForwardingDeny = a.config.DatacenterForwardingDeny
+
+	base.KVReplicationDatacenter = a.config.KVReplicationDatacenter
+	base.KVReplicationPrefix = a.config.KVReplicationPrefix
+	base.ChangeFeedMaxEntries = a.config.ChangeFeedMaxEntries
+	base.WANFederationLocalGateways = a.config.WANFederationLocalGateways
+	base.WANFederationPrimaryGateways = a.config.WANFederationPrimaryGateways
+
+	base.MaxBlockingQueries = a.config.MaxBlockingQueries
+	base.MaxBlockingQueriesPerTable = a.config.MaxBlockingQueriesPerTable
+	base.MaxBlockingQueriesPerToken = a.config.MaxBlockingQueriesPerToken
+	base.RaftApplyQueueDepth = a.config.RaftApplyQueueDepth
+	base.RaftApplyQueueWait = a.config.RaftApplyQueueWait
+	base.MaxNodes = a.config.MaxNodes
+	base.MaxServicesPerNode = a.config.MaxServicesPerNode
+	base.MaxChecksPerNode = a.config.MaxChecksPerNode
+	base.MaxIDLength = a.config.MaxIDLength
+	base.StrictRegistrationValidation = a.config.RegistrationValidation == "strict"
+	base.QuerySourceValidation = a.config.QuerySourceValidation
+	base.MaxQueryResults = a.config.MaxQueryResults
+	base.QueryCacheSize = a.config.QueryCacheSize
+	base.CheckLivenessInterval = a.config.CheckLivenessInterval
+	base.StateMemoryBudget = int64(a.config.StateMemoryBudgetMB) * 1024 * 1024
+
 	// RPC-related performance configs.
 	if a.config.RPCHoldTimeout > 0 {
 		base.RPCHoldTimeout = a.config.RPCHoldTimeout
 	}
+	if a.config.RPCLeaderRetryWait > 0 {
+		base.RPCLeaderRetryWait = a.config.RPCLeaderRetryWait
+	}
+	if a.config.RPCKeepAliveInterval > 0 {
+		base.RPCKeepAliveInterval = a.config.RPCKeepAliveInterval
+	}
+	if a.config.RPCConnectionWriteTimeout > 0 {
+		base.RPCConnectionWriteTimeout = a.config.RPCConnectionWriteTimeout
+	}
+	base.RPCStreamTimeout = a.config.RPCStreamTimeout
+	base.RPCMaxStreams = a.config.RPCMaxStreams
 	if a.config.LeaveDrainTime > 0 {
 		base.LeaveDrainTime = a.config.LeaveDrainTime
 	}
+	base.CrossDCBreakerThreshold = a.config.CrossDCBreakerThreshold
+	base.CrossDCBreakerCooldown = a.config.CrossDCBreakerCooldown
+	base.LeaderFlapThreshold = a.config.LeaderFlapThreshold
+	base.LeaderFlapWindow = a.config.LeaderFlapWindow
+	if a.config.LocalityHostRTT > 0 {
+		base.LocalityHostRTT = a.config.LocalityHostRTT
+		base.LocalityZoneRTT = a.config.LocalityZoneRTT
+		base.LocalityRegionRTT = a.config.LocalityRegionRTT
+	}
+	base.EnableResponseSigning = a.config.EnableResponseSigning
+	base.ClockSkewThreshold = a.config.ClockSkewThreshold
 
 	// set the src address for outgoing rpc connections
 	// Use port 0 so that outgoing connections use a random port.
@@ -1403,8 +1612,24 @@ func (a *Agent) SnapshotRPC(args *structs.SnapshotRequest, in io.Reader, out io.
 	return a.delegate.SnapshotRPC(args, in, out, replyFn)
 }
 
-// Leave is used to prepare the agent for a graceful shutdown
+// Leave is used to prepare the agent for a graceful shutdown. If a leave
+// maintenance time is configured, the node is first put into maintenance mode
+// and the agent waits for that long before leaving, so consumers stop using
+// its services before they are deregistered.
 func (a *Agent) Leave() error {
+	if wait := a.config.LeaveMaintenanceTime; wait > 0 {
+		// The check isn't persisted, so an agent that is stopped while it
+		// waits doesn't come back up in maintenance mode.
+		a.enableNodeMaintenance(leaveMaintReason, "", false)
+		if err := a.State.SyncChanges(); err != nil {
+			a.logger.Printf("[WARN] agent: Failed to sync node maintenance before leaving: %v", err)
+		}
+		a.logger.Printf("[INFO] agent: Waiting %s before leaving", wait)
+		select {
+		case <-time.After(wait):
+		case <-a.shutdownCh:
+		}
+	}
 	return a.delegate.Leave()
 }
 
@@ -1444,12 +1669,26 @@ func (a *Agent) ShutdownAgent() error {
 	for _, chk := range a.checkAliases {
 		chk.Stop()
 	}
+	if a.checkScheduler != nil {
+		a.checkScheduler.Stop()
+	}
+
+	// Stop rendering templates
+	a.stopTemplates()
+
+	// Stop watching the services directory
+	a.stopServicesDir()
 
 	// Stop gRPC
 	if a.grpcServer != nil {
 		a.grpcServer.Stop()
 	}
 
+	// Stop the WAN federation gateway
+	if a.wanGateway != nil {
+		a.wanGateway.Shutdown()
+	}
+
 	// Stop the proxy config manager
 	if a.proxyConfig != nil {
 		a.proxyConfig.Close()
@@ -1699,6 +1938,7 @@ OUTER:
 					Node:         a.config.NodeName,
 					Segment:      segment,
 					Coord:        coord,
+					SentAt:       time.Now(),
 					WriteRequest: structs.WriteRequest{Token: a.tokens.AgentToken()},
 				}
 				var reply struct{}
@@ -2113,6 +2353,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *structs.CheckType,
 				Timeout:         chkType.Timeout,
 				Logger:          a.logger,
 				TLSClientConfig: tlsClientConfig,
+				Scheduler:       a.checkScheduler,
 			}
 			http.Start()
 			a.checkHTTPs[check.CheckID] = http
@@ -2129,12 +2370,13 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *structs.CheckType,
 			}
 
 			tcp := &checks.CheckTCP{
-				Notify:   a.State,
-				CheckID:  check.CheckID,
-				TCP:      chkType.TCP,
-				Interval: chkType.Interval,
-				Timeout:  chkType.Timeout,
-				Logger:   a.logger,
+				Notify:    a.State,
+				CheckID:   check.CheckID,
+				TCP:       chkType.TCP,
+				Interval:  chkType.Interval,
+				Timeout:   chkType.Timeout,
+				Logger:    a.logger,
+				Scheduler: a.checkScheduler,
 			}
 			tcp.Start()
 			a.checkTCPs[check.CheckID] = tcp
@@ -2167,6 +2409,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *structs.CheckType,
 				Timeout:         chkType.Timeout,
 				Logger:          a.logger,
 				TLSClientConfig: tlsClientConfig,
+				Scheduler:       a.checkScheduler,
 			}
 			grpc.Start()
 			a.checkGRPCs[check.CheckID] = grpc
@@ -2201,6 +2444,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *structs.CheckType,
 				Interval:          chkType.Interval,
 				Logger:            a.logger,
 				Client:            a.dockerClient,
+				Scheduler:         a.checkScheduler,
 			}
 			if prev := a.checkDockers[check.CheckID]; prev != nil {
 				prev.Stop()
@@ -2226,6 +2470,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *structs.CheckType,
 				Interval:   chkType.Interval,
 				Timeout:    chkType.Timeout,
 				Logger:     a.logger,
+				Scheduler:  a.checkScheduler,
 			}
 			monitor.Start()
 			a.checkMonitors[check.CheckID] = monitor
@@ -2389,8 +2634,8 @@ func (a *Agent) addProxyLocked(proxy *structs.ConnectManagedProxy, persist, From
 		chkTypes = []*structs.CheckType{
 			&structs.CheckType{
 				Name: "Connect Proxy Listening",
-				TCP: fmt.Sprintf("%s:%d", chkAddr,
-					proxyCfg["bind_port"]),
+				TCP: net.JoinHostPort(chkAddr,
+					fmt.Sprintf("%v", proxyCfg["bind_port"])),
 				Interval: 10 * time.Second,
 			},
 		}
@@ -3303,6 +3548,12 @@ func (a *Agent) DisableServiceMaintenance(serviceID string) error {
 
 // EnableNodeMaintenance places a node into maintenance mode.
 func (a *Agent) EnableNodeMaintenance(reason, token string) {
+	a.enableNodeMaintenance(reason, token, true)
+}
+
+// enableNodeMaintenance is EnableNodeMaintenance with control over whether the
+// maintenance check is persisted, so it's restored when the agent restarts.
+func (a *Agent) enableNodeMaintenance(reason, token string, persist bool) {
 	// Ensure node maintenance is not already enabled
 	if _, ok := a.State.Checks()[structs.NodeMaint]; ok {
 		return
@@ -3321,7 +3572,7 @@ func (a *Agent) EnableNodeMaintenance(reason, token string) {
 		Notes:   reason,
 		Status:  api.HealthCritical,
 	}
-	a.AddCheck(check, nil, true, token, ConfigSourceLocal)
+	a.AddCheck(check, nil, persist, token, ConfigSourceLocal)
 	a.logger.Printf("[INFO] agent: Node entered maintenance mode")
 }
 
@@ -3334,12 +3585,59 @@ func (a *Agent) DisableNodeMaintenance() {
 	a.logger.Printf("[INFO] agent: Node left maintenance mode")
 }
 
+// EnableNodeDrain places a node into drain mode. Its services stay in the
+// catalog and keep passing, but are ranked last in sorted and DNS answers
+// and get a DNS weight of zero, so connections move elsewhere before the
+// node is removed.
+func (a *Agent) EnableNodeDrain(reason, token string) {
+	// Ensure node drain is not already enabled
+	if _, ok := a.State.Checks()[structs.NodeDrain]; ok {
+		return
+	}
+
+	// Use a default notes value
+	if reason == "" {
+		reason = defaultNodeDrainReason
+	}
+
+	// Create and register the node drain check
+	check := &structs.HealthCheck{
+		Node:    a.config.NodeName,
+		CheckID: structs.NodeDrain,
+		Name:    "Node Drain Mode",
+		Notes:   reason,
+		Status:  api.HealthPassing,
+	}
+	a.AddCheck(check, nil, true, token, ConfigSourceLocal)
+	a.logger.Printf("[INFO] agent: Node entered drain mode")
+}
+
+// DisableNodeDrain removes a node from drain mode
+func (a *Agent) DisableNodeDrain() {
+	if _, ok := a.State.Checks()[structs.NodeDrain]; !ok {
+		return
+	}
+	a.RemoveCheck(structs.NodeDrain, true)
+	a.logger.Printf("[INFO] agent: Node left drain mode")
+}
+
 func (a *Agent) loadLimits(conf *config.RuntimeConfig) {
 	a.config.RPCRateLimit = conf.RPCRateLimit
 	a.config.RPCMaxBurst = conf.RPCMaxBurst
+	a.config.RPCServerReadRate = conf.RPCServerReadRate
+	a.config.RPCServerWriteRate = conf.RPCServerWriteRate
+	a.config.RPCServerTokenRate = conf.RPCServerTokenRate
+	a.config.RPCServerIPRate = conf.RPCServerIPRate
+	a.config.RPCServerMaxBurst = conf.RPCServerMaxBurst
 }
 
 func (a *Agent) ReloadConfig(newCfg *config.RuntimeConfig) error {
+	// Hold off running hooks until the reload is finished, so services and
+	// checks that are unloaded and loaded again don't look like changes.
+	a.hooks.lock.Lock()
+	defer a.hooks.lock.Unlock()
+	a.hooks.setHooks(newCfg.Hooks)
+
 	// Bulk update the services and checks
 	a.PauseSync()
 	defer a.ResumeSync()
@@ -3348,6 +3646,10 @@ func (a *Agent) ReloadConfig(newCfg *config.RuntimeConfig) error {
 	snap := a.snapshotCheckState()
 	defer a.restoreCheckState(snap)
 
+	// Stop watching the services directory, so it doesn't change anything
+	// while the definitions are reloaded.
+	a.stopServicesDir()
+
 	// First unload all checks, services, and metadata. This lets us begin the reload
 	// with a clean slate.
 	if err := a.unloadProxies(); err != nil {
@@ -3371,6 +3673,7 @@ func (a *Agent) ReloadConfig(newCfg *config.RuntimeConfig) error {
 	if err := a.loadChecks(newCfg); err != nil {
 		return fmt.Errorf("Failed reloading checks: %s", err)
 	}
+	a.reloadServicesDir(newCfg)
 	if err := a.loadMetadata(newCfg); err != nil {
 		return fmt.Errorf("Failed reloading metadata: %s", err)
 	}
@@ -3379,8 +3682,13 @@ func (a *Agent) ReloadConfig(newCfg *config.RuntimeConfig) error {
 		return fmt.Errorf("Failed reloading watches: %v", err)
 	}
 
+	a.reloadTemplates(newCfg)
+
 	a.loadLimits(newCfg)
 
+	// Fault injection rules replace any set through the API.
+	a.config.FaultInjection = newCfg.FaultInjection
+
 	// create the config for the rpc server/client
 	consulCfg, err := a.consulConfig()
 	if err != nil {
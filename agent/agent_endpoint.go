@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -17,7 +18,6 @@ import (
 
 	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent/cache-types"
-	"github.com/hashicorp/consul/agent/checks"
 	"github.com/hashicorp/consul/agent/config"
 	"github.com/hashicorp/consul/agent/debug"
 	"github.com/hashicorp/consul/agent/local"
@@ -41,6 +41,40 @@ type Self struct {
 	Member      serf.Member
 	Stats       map[string]map[string]string
 	Meta        map[string]string
+	Features    SelfFeatures
+	Protocol    SelfProtocol
+	Build       SelfBuild
+}
+
+// SelfFeatures lists which of the optional features are enabled on the
+// agent.
+type SelfFeatures struct {
+	ACLs        bool
+	TLS         bool
+	Connect     bool
+	Segments    bool
+	UI          bool
+	Coordinates bool
+	RemoteExec  bool
+}
+
+// SelfProtocol holds the protocol versions the agent speaks. Raft is only
+// set on servers.
+type SelfProtocol struct {
+	Consul    int
+	ConsulMin int
+	ConsulMax int
+	Raft      int
+}
+
+// SelfBuild describes the binary the agent is running.
+type SelfBuild struct {
+	Version           string
+	VersionPrerelease string
+	Revision          string
+	GoVersion         string
+	OS                string
+	Arch              string
 }
 
 func (s *HTTPServer) AgentSelf(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
@@ -78,13 +112,45 @@ func (s *HTTPServer) AgentSelf(resp http.ResponseWriter, req *http.Request) (int
 		Server:     s.agent.config.ServerMode,
 		Version:    s.agent.config.Version,
 	}
+	// The protocol versions are the ones the agent advertises to the
+	// other members.
+	member := s.agent.LocalMember()
+	tag := func(name string) int {
+		v, _ := strconv.Atoi(member.Tags[name])
+		return v
+	}
+
+	cfg := s.agent.config
 	return Self{
 		Config:      config,
-		DebugConfig: s.agent.config.Sanitized(),
-		Coord:       cs[s.agent.config.SegmentName],
-		Member:      s.agent.LocalMember(),
+		DebugConfig: cfg.Sanitized(),
+		Coord:       cs[cfg.SegmentName],
+		Member:      member,
 		Stats:       s.agent.Stats(),
 		Meta:        s.agent.State.Metadata(),
+		Features: SelfFeatures{
+			ACLs:        cfg.ACLsEnabled,
+			TLS:         cfg.CertFile != "" || cfg.VerifyOutgoing,
+			Connect:     cfg.ConnectEnabled,
+			Segments:    cfg.SegmentName != "" || len(cfg.Segments) > 0,
+			UI:          s.IsUIEnabled(),
+			Coordinates: !cfg.DisableCoordinates,
+			RemoteExec:  !cfg.DisableRemoteExec,
+		},
+		Protocol: SelfProtocol{
+			Consul:    tag("vsn"),
+			ConsulMin: tag("vsn_min"),
+			ConsulMax: tag("vsn_max"),
+			Raft:      tag("raft_vsn"),
+		},
+		Build: SelfBuild{
+			Version:           cfg.Version,
+			VersionPrerelease: cfg.VersionPrerelease,
+			Revision:          cfg.Revision,
+			GoVersion:         runtime.Version(),
+			OS:                runtime.GOOS,
+			Arch:              runtime.GOARCH,
+		},
 	}, nil
 }
 
@@ -154,6 +220,158 @@ func (s *HTTPServer) AgentReload(resp http.ResponseWriter, req *http.Request) (i
 	}
 }
 
+// AgentStateDumpResponse is returned by the state dump endpoint.
+type AgentStateDumpResponse struct {
+	// Path is where the dump was written, in the agent's data dir.
+	Path string
+
+	// Dump is the dump itself, so it can be retrieved remotely.
+	Dump *StateDump
+}
+
+// AgentStateDump writes the agent's in-memory state to its data dir for
+// debugging, the same as sending the agent SIGUSR1.
+func (s *HTTPServer) AgentStateDump(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && !rule.AgentWrite(s.agent.config.NodeName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	path, dump, err := s.agent.WriteStateDump()
+	if err != nil {
+		return nil, err
+	}
+	return &AgentStateDumpResponse{Path: path, Dump: dump}, nil
+}
+
+// AgentFlushCaches drops the agent's resolved ACLs and pooled connections to
+// the servers, and on the leader applies the pending coordinate updates, so
+// emergency fixes take effect without waiting for them to expire.
+func (s *HTTPServer) AgentFlushCaches(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && !rule.AgentWrite(s.agent.config.NodeName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	return s.agent.delegate.FlushCaches()
+}
+
+// AgentFaultRules is the body of the fault injection endpoint.
+type AgentFaultRules struct {
+	Rules []structs.FaultRule
+}
+
+// AgentFaults reads or replaces the rules a dev mode server uses to inject
+// latency and errors into RPCs and Raft applies.
+func (s *HTTPServer) AgentFaults(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method == "GET" {
+		if rule != nil && !rule.AgentRead(s.agent.config.NodeName) {
+			return nil, acl.ErrPermissionDenied
+		}
+		rules, err := s.agent.delegate.FaultRules()
+		if err != nil {
+			return nil, err
+		}
+		return &AgentFaultRules{Rules: rules}, nil
+	}
+
+	if rule != nil && !rule.AgentWrite(s.agent.config.NodeName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	var args AgentFaultRules
+	durations := NewDurationFixer("latency")
+	if err := decodeBody(req, &args, durations.FixupDurations); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Request decode failed: %v", err)
+		return nil, nil
+	}
+	if err := s.agent.delegate.SetFaultRules(args.Rules); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err.Error())
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// AgentBench generates load against the cluster from the agent, and returns
+// the throughput and latencies it measured once the run ends. The requests
+// are sent with the caller's token, so it must also be able to write the
+// keys and nodes of the run.
+func (s *HTTPServer) AgentBench(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && !rule.AgentWrite(s.agent.config.NodeName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	var args api.AgentBenchOptions
+	durations := NewDurationFixer("duration")
+	if err := decodeBody(req, &args, durations.FixupDurations); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Request decode failed: %v", err)
+		return nil, nil
+	}
+
+	report, err := s.agent.Bench(req.Context(), args, token)
+	if err == errBenchRunning {
+		resp.WriteHeader(http.StatusConflict)
+		fmt.Fprint(resp, err.Error())
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// AgentBenchStop ends the benchmark the agent is running early, so the
+// pending AgentBench request returns what was measured until then.
+func (s *HTTPServer) AgentBenchStop(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && !rule.AgentWrite(s.agent.config.NodeName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	if !s.agent.StopBench() {
+		resp.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(resp, "No benchmark is running on this agent")
+		return nil, nil
+	}
+	return nil, nil
+}
+
 func buildAgentService(s *structs.NodeService, proxies map[string]*local.ManagedProxy) api.AgentService {
 	weights := api.AgentWeights{Passing: 1, Warning: 1}
 	if s.Weights != nil {
@@ -688,10 +906,10 @@ func (s *HTTPServer) AgentCheckUpdate(resp http.ResponseWriter, req *http.Reques
 		return nil, nil
 	}
 
-	total := len(update.Output)
-	if total > checks.BufSize {
+	maxSize := s.agent.config.CheckOutputMaxSize
+	if total := len(update.Output); total > maxSize {
 		update.Output = fmt.Sprintf("%s ... (captured %d of %d bytes)",
-			update.Output[:checks.BufSize], checks.BufSize, total)
+			update.Output[:maxSize], maxSize, total)
 	}
 
 	checkID := types.CheckID(strings.TrimPrefix(req.URL.Path, "/v1/agent/check/update/"))
@@ -759,10 +977,22 @@ func (s *HTTPServer) AgentHealthServiceByID(resp http.ResponseWriter, req *http.
 	if serviceID == "" {
 		return nil, &BadRequestError{Reason: "Missing serviceID"}
 	}
+
+	// Fetch the ACL token, if any.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+
 	services := s.agent.State.Services()
 	proxies := s.agent.State.Proxies()
 	for _, service := range services {
 		if service.ID == serviceID {
+			if rule != nil && !rule.ServiceRead(service.Service) {
+				return nil, acl.ErrPermissionDenied
+			}
 			code, status, healthChecks := agentHealthService(serviceID, s)
 			if returnTextPlain(req) {
 				return status, CodeWithPayloadError{StatusCode: code, Reason: status, ContentType: "text/plain"}
@@ -794,6 +1024,18 @@ func (s *HTTPServer) AgentHealthServiceByName(resp http.ResponseWriter, req *htt
 	if serviceName == "" {
 		return nil, &BadRequestError{Reason: "Missing service Name"}
 	}
+
+	// Fetch the ACL token, if any, and enforce the service policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && !rule.ServiceRead(serviceName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
 	code := http.StatusNotFound
 	status := fmt.Sprintf("ServiceName %s Not Found", serviceName)
 	services := s.agent.State.Services()
@@ -1059,6 +1301,26 @@ func (s *HTTPServer) AgentDeregisterService(resp http.ResponseWriter, req *http.
 	return nil, nil
 }
 
+// parseEnable parses the required ?enable query param of the endpoints that
+// toggle maintenance and drain modes. Returns true on error.
+func parseEnable(resp http.ResponseWriter, req *http.Request, enable *bool) bool {
+	raw, ok := req.URL.Query()["enable"]
+	if !ok {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Missing value for enable")
+		return true
+	}
+
+	val, err := strconv.ParseBool(raw[0])
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Invalid value for enable: %q", raw[0])
+		return true
+	}
+	*enable = val
+	return false
+}
+
 func (s *HTTPServer) AgentServiceMaintenance(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	// Ensure we have a service ID
 	serviceID := strings.TrimPrefix(req.URL.Path, "/v1/agent/service/maintenance/")
@@ -1069,18 +1331,8 @@ func (s *HTTPServer) AgentServiceMaintenance(resp http.ResponseWriter, req *http
 	}
 
 	// Ensure we have some action
-	params := req.URL.Query()
-	if _, ok := params["enable"]; !ok {
-		resp.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(resp, "Missing value for enable")
-		return nil, nil
-	}
-
-	raw := params.Get("enable")
-	enable, err := strconv.ParseBool(raw)
-	if err != nil {
-		resp.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(resp, "Invalid value for enable: %q", raw)
+	var enable bool
+	if parseEnable(resp, req, &enable) {
 		return nil, nil
 	}
 
@@ -1092,14 +1344,14 @@ func (s *HTTPServer) AgentServiceMaintenance(resp http.ResponseWriter, req *http
 	}
 
 	if enable {
-		reason := params.Get("reason")
-		if err = s.agent.EnableServiceMaintenance(serviceID, reason, token); err != nil {
+		reason := req.URL.Query().Get("reason")
+		if err := s.agent.EnableServiceMaintenance(serviceID, reason, token); err != nil {
 			resp.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(resp, err.Error())
 			return nil, nil
 		}
 	} else {
-		if err = s.agent.DisableServiceMaintenance(serviceID); err != nil {
+		if err := s.agent.DisableServiceMaintenance(serviceID); err != nil {
 			resp.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(resp, err.Error())
 			return nil, nil
@@ -1111,36 +1363,45 @@ func (s *HTTPServer) AgentServiceMaintenance(resp http.ResponseWriter, req *http
 
 func (s *HTTPServer) AgentNodeMaintenance(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	// Ensure we have some action
-	params := req.URL.Query()
-	if _, ok := params["enable"]; !ok {
-		resp.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(resp, "Missing value for enable")
+	var enable bool
+	if parseEnable(resp, req, &enable) {
 		return nil, nil
 	}
 
-	raw := params.Get("enable")
-	enable, err := strconv.ParseBool(raw)
-	if err != nil {
-		resp.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(resp, "Invalid value for enable: %q", raw)
+	// Get the provided token, if any, and vet against any ACL policies.
+	var token string
+	s.parseToken(req, &token)
+	if err := s.agent.vetNodeUpdate(token); err != nil {
+		return nil, err
+	}
+
+	if enable {
+		s.agent.EnableNodeMaintenance(req.URL.Query().Get("reason"), token)
+	} else {
+		s.agent.DisableNodeMaintenance()
+	}
+	s.syncChanges()
+	return nil, nil
+}
+
+func (s *HTTPServer) AgentNodeDrain(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Ensure we have some action
+	var enable bool
+	if parseEnable(resp, req, &enable) {
 		return nil, nil
 	}
 
 	// Get the provided token, if any, and vet against any ACL policies.
 	var token string
 	s.parseToken(req, &token)
-	rule, err := s.agent.resolveToken(token)
-	if err != nil {
+	if err := s.agent.vetNodeUpdate(token); err != nil {
 		return nil, err
 	}
-	if rule != nil && !rule.NodeWrite(s.agent.config.NodeName, nil) {
-		return nil, acl.ErrPermissionDenied
-	}
 
 	if enable {
-		s.agent.EnableNodeMaintenance(params.Get("reason"), token)
+		s.agent.EnableNodeDrain(req.URL.Query().Get("reason"), token)
 	} else {
-		s.agent.DisableNodeMaintenance()
+		s.agent.DisableNodeDrain()
 	}
 	s.syncChanges()
 	return nil, nil
@@ -1262,30 +1523,24 @@ func (s *HTTPServer) AgentToken(resp http.ResponseWriter, req *http.Request) (in
 		return nil, nil
 	}
 
-	// Figure out the target token.
+	// Figure out the target token, which can also be named after its
+	// config option.
 	target := strings.TrimPrefix(req.URL.Path, "/v1/agent/token/")
-	switch target {
-	case "acl_token":
-		s.agent.tokens.UpdateUserToken(args.Token)
-
-	case "acl_agent_token":
-		s.agent.tokens.UpdateAgentToken(args.Token)
-
-	case "acl_agent_master_token":
-		s.agent.tokens.UpdateAgentMasterToken(args.Token)
-
-	case "acl_replication_token":
-		s.agent.tokens.UpdateACLReplicationToken(args.Token)
-
-	case "connect_replication_token":
-		s.agent.tokens.UpdateConnectReplicationToken(args.Token)
-
-	default:
+	if name, ok := tokenAliases[target]; ok {
+		target = name
+	}
+	if !s.agent.setToken(target, args.Token) {
 		resp.WriteHeader(http.StatusNotFound)
 		fmt.Fprintf(resp, "Token %q is unknown", target)
 		return nil, nil
 	}
 
+	if s.agent.config.ACLTokenPersistence {
+		if err := s.agent.persistToken(target, args.Token); err != nil {
+			return nil, err
+		}
+	}
+
 	s.agent.logger.Printf("[INFO] agent: Updated agent's ACL token %q", target)
 	return nil, nil
 }
@@ -1612,3 +1867,40 @@ func (s *HTTPServer) AgentHost(resp http.ResponseWriter, req *http.Request) (int
 
 	return debug.CollectHostInfo(), nil
 }
+
+// AgentDNSForwarding
+//
+// GET /v1/agent/dns-forwarding?format=<bind|dnsmasq|systemd-resolved>
+//
+// Returns a snippet of configuration for the given resolver that forwards
+// queries for the Consul domain to the agent's DNS server. Requires an
+// agent:read ACL token.
+func (s *HTTPServer) AgentDNSForwarding(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && !rule.AgentRead(s.agent.config.NodeName) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Missing format")
+		return nil, nil
+	}
+	out, err := dnsForwardingConfig(format, s.agent.config.DNSDomain, s.agent.config.DNSAddrs)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, err.Error())
+		return nil, nil
+	}
+
+	resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(resp, out)
+	return nil, nil
+}
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -11,7 +12,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -20,6 +23,7 @@ import (
 	"github.com/hashicorp/consul/agent/checks"
 	"github.com/hashicorp/consul/agent/config"
 	"github.com/hashicorp/consul/agent/connect"
+	"github.com/hashicorp/consul/agent/consul"
 	"github.com/hashicorp/consul/agent/debug"
 	"github.com/hashicorp/consul/agent/local"
 	"github.com/hashicorp/consul/agent/structs"
@@ -29,6 +33,7 @@ import (
 	"github.com/hashicorp/consul/testrpc"
 	"github.com/hashicorp/consul/testutil/retry"
 	"github.com/hashicorp/consul/types"
+	"github.com/hashicorp/serf/coordinate"
 	"github.com/hashicorp/serf/serf"
 	"github.com/mitchellh/copystructure"
 	"github.com/stretchr/testify/assert"
@@ -822,6 +827,58 @@ func TestAgent_HealthServiceByID(t *testing.T) {
 	})
 }
 
+func TestAgent_HealthService_ACLDeny(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), TestACLConfig())
+	defer a.Shutdown()
+
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+	service := &structs.NodeService{
+		ID:      "mysql1",
+		Service: "mysql",
+	}
+	if err := a.AddService(service, nil, false, "", ConfigSourceLocal); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, url := range []string{
+		"/v1/agent/health/service/id/mysql1",
+		"/v1/agent/health/service/name/mysql",
+	} {
+		t.Run(url+" no token", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", url, nil)
+			resp := httptest.NewRecorder()
+			var err error
+			if strings.Contains(url, "/id/") {
+				_, err = a.srv.AgentHealthServiceByID(resp, req)
+			} else {
+				_, err = a.srv.AgentHealthServiceByName(resp, req)
+			}
+			if !acl.IsErrPermissionDenied(err) {
+				t.Fatalf("err: %v", err)
+			}
+		})
+
+		t.Run(url+" root token", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", url+"?token=root", nil)
+			resp := httptest.NewRecorder()
+			var err error
+			if strings.Contains(url, "/id/") {
+				_, err = a.srv.AgentHealthServiceByID(resp, req)
+			} else {
+				_, err = a.srv.AgentHealthServiceByName(resp, req)
+			}
+			codeWithPayload, ok := err.(CodeWithPayloadError)
+			if !ok {
+				t.Fatalf("err: %v", err)
+			}
+			if codeWithPayload.StatusCode != http.StatusOK {
+				t.Fatalf("bad: %#v", codeWithPayload)
+			}
+		})
+	}
+}
+
 func TestAgent_HealthServiceByName(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -1140,6 +1197,16 @@ func TestAgent_Self(t *testing.T) {
 	if !reflect.DeepEqual(a.config.NodeMeta, val.Meta) {
 		t.Fatalf("meta fields are not equal: %v != %v", a.config.NodeMeta, val.Meta)
 	}
+
+	require.Equal(t, SelfFeatures{Connect: true, Coordinates: true}, val.Features)
+	require.Equal(t, SelfProtocol{
+		Consul:    int(consul.ProtocolVersion2Compatible),
+		ConsulMin: int(consul.ProtocolVersionMin),
+		ConsulMax: consul.ProtocolVersionMax,
+		Raft:      3,
+	}, val.Protocol)
+	require.Equal(t, a.config.Version, val.Build.Version)
+	require.Equal(t, runtime.GOOS, val.Build.OS)
 }
 
 func TestAgent_Self_ACLDeny(t *testing.T) {
@@ -1300,6 +1367,264 @@ func TestAgent_Reload_ACLDeny(t *testing.T) {
 	// repeating again here.
 }
 
+func TestAgent_StateDump(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	srv := &structs.NodeService{
+		ID:      "redis",
+		Service: "redis",
+		Port:    8000,
+	}
+	chkTypes := []*structs.CheckType{&structs.CheckType{TTL: time.Minute}}
+	if err := a.AddService(srv, chkTypes, false, "", ConfigSourceLocal); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req, _ := http.NewRequest("PUT", "/v1/agent/state-dump", nil)
+	obj, err := a.srv.AgentStateDump(nil, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out := obj.(*AgentStateDumpResponse)
+	if filepath.Dir(out.Path) != filepath.Join(a.Config.DataDir, "debug") {
+		t.Fatalf("bad: %s", out.Path)
+	}
+
+	// The file should have the same contents as the response.
+	buf, err := ioutil.ReadFile(out.Path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var dump StateDump
+	if err := json.Unmarshal(buf, &dump); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	require.Equal(t, a.Config.NodeName, dump.Node)
+	require.True(t, dump.Server)
+	require.Len(t, dump.Servers, 1)
+	require.Len(t, dump.Services, 1)
+	require.Equal(t, "redis", dump.Services[0].Service.ID)
+	require.Len(t, dump.Checks, 1)
+	require.Equal(t, "service:redis", string(dump.Checks[0].Check.CheckID))
+	require.NotEmpty(t, dump.Coordinates)
+	require.Equal(t, out.Dump.Services[0].Service.ID, dump.Services[0].Service.ID)
+}
+
+func TestAgent_StateDump_ACLDeny(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), TestACLConfig())
+	defer a.Shutdown()
+
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+	t.Run("no token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/state-dump", nil)
+		if _, err := a.srv.AgentStateDump(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+
+	t.Run("read-only token", func(t *testing.T) {
+		ro := makeReadOnlyAgentACL(t, a.srv)
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/v1/agent/state-dump?token=%s", ro), nil)
+		if _, err := a.srv.AgentStateDump(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+
+	t.Run("root token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/state-dump?token=root", nil)
+		if _, err := a.srv.AgentStateDump(nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	})
+}
+
+func TestAgent_FlushCaches(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `
+		consul = {
+			coordinate = {
+				update_period = "1h"
+			}
+		}
+	`)
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// Coordinate updates are held by the leader until the next batch,
+	// which is an hour away.
+	update := structs.CoordinateUpdateRequest{
+		Datacenter: "dc1",
+		Node:       a.Config.NodeName,
+		Coord:      coordinate.NewCoordinate(coordinate.DefaultConfig()),
+	}
+	var reply struct{}
+	require.NoError(t, a.RPC("Coordinate.Update", &update, &reply))
+
+	req, _ := http.NewRequest("PUT", "/v1/agent/flush-caches", nil)
+	obj, err := a.srv.AgentFlushCaches(nil, req)
+	require.NoError(t, err)
+	flushed := obj.(*consul.FlushedCaches)
+	require.Equal(t, 1, flushed.CoordinateUpdates)
+
+	// The update is applied straight away.
+	args := structs.NodeSpecificRequest{Datacenter: "dc1", Node: a.Config.NodeName}
+	var out structs.IndexedCoordinates
+	require.NoError(t, a.RPC("Coordinate.Node", &args, &out))
+	require.Len(t, out.Coordinates, 1)
+}
+
+func TestAgent_FlushCaches_ACLDeny(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), TestACLConfig())
+	defer a.Shutdown()
+
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+	t.Run("no token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/flush-caches", nil)
+		if _, err := a.srv.AgentFlushCaches(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+
+	t.Run("read-only token", func(t *testing.T) {
+		ro := makeReadOnlyAgentACL(t, a.srv)
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/v1/agent/flush-caches?token=%s", ro), nil)
+		if _, err := a.srv.AgentFlushCaches(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+
+	t.Run("root token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/flush-caches?token=root", nil)
+		if _, err := a.srv.AgentFlushCaches(nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	})
+}
+
+func TestAgent_Bench(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	t.Run("run", func(t *testing.T) {
+		body := bytes.NewBufferString(`{"Workloads": ["kv", "blocking"], "Duration": "300ms", "Concurrency": 2, "Keys": 3}`)
+		req, _ := http.NewRequest("PUT", "/v1/agent/bench", body)
+		obj, err := a.srv.AgentBench(httptest.NewRecorder(), req)
+		require.NoError(t, err)
+		report := obj.(*api.AgentBenchReport)
+		require.Len(t, report.Workloads, 2)
+		for i, name := range []string{"kv", "blocking"} {
+			w := report.Workloads[i]
+			require.Equal(t, name, w.Name)
+			require.NotZero(t, w.Requests)
+			require.Zero(t, w.Errors, w.FirstError)
+			require.True(t, w.P50 <= w.Max)
+		}
+
+		// The keys written are cleaned up.
+		args := structs.KeyListRequest{Datacenter: "dc1", Prefix: "consul-bench/"}
+		var out structs.IndexedKeyList
+		require.NoError(t, a.RPC("KVS.ListKeys", &args, &out))
+		require.Empty(t, out.Keys)
+	})
+
+	t.Run("bad options", func(t *testing.T) {
+		body := bytes.NewBufferString(`{"Workloads": ["nope"]}`)
+		req, _ := http.NewRequest("PUT", "/v1/agent/bench", body)
+		_, err := a.srv.AgentBench(httptest.NewRecorder(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `unknown workload "nope"`)
+
+		body = bytes.NewBufferString(`{"Duration": "1h"}`)
+		req, _ = http.NewRequest("PUT", "/v1/agent/bench", body)
+		_, err = a.srv.AgentBench(httptest.NewRecorder(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "duration must be")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		// Nothing is running yet.
+		req, _ := http.NewRequest("PUT", "/v1/agent/bench/stop", nil)
+		resp := httptest.NewRecorder()
+		_, err := a.srv.AgentBenchStop(resp, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, resp.Code)
+
+		type result struct {
+			report *api.AgentBenchReport
+			err    error
+		}
+		doneCh := make(chan result, 1)
+		go func() {
+			report, err := a.Bench(context.Background(), api.AgentBenchOptions{Duration: time.Minute}, "")
+			doneCh <- result{report, err}
+		}()
+
+		retry.Run(t, func(r *retry.R) {
+			a.benchLock.Lock()
+			defer a.benchLock.Unlock()
+			if a.benchStop == nil {
+				r.Fatal("benchmark isn't running")
+			}
+		})
+
+		// A second run is refused while the first one is running.
+		body := bytes.NewBufferString(`{"Duration": "1s"}`)
+		req, _ = http.NewRequest("PUT", "/v1/agent/bench", body)
+		resp = httptest.NewRecorder()
+		_, err = a.srv.AgentBench(resp, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusConflict, resp.Code)
+
+		req, _ = http.NewRequest("PUT", "/v1/agent/bench/stop", nil)
+		resp = httptest.NewRecorder()
+		_, err = a.srv.AgentBenchStop(resp, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		select {
+		case res := <-doneCh:
+			require.NoError(t, res.err)
+			require.True(t, res.report.Elapsed < time.Minute)
+			require.Len(t, res.report.Workloads, 1)
+		case <-time.After(10 * time.Second):
+			t.Fatal("benchmark didn't stop")
+		}
+	})
+}
+
+func TestAgent_Bench_ACLDeny(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), TestACLConfig())
+	defer a.Shutdown()
+
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+	t.Run("no token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/bench", nil)
+		if _, err := a.srv.AgentBench(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+		req, _ = http.NewRequest("PUT", "/v1/agent/bench/stop", nil)
+		if _, err := a.srv.AgentBenchStop(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+
+	t.Run("read-only token", func(t *testing.T) {
+		ro := makeReadOnlyAgentACL(t, a.srv)
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/v1/agent/bench?token=%s", ro), nil)
+		if _, err := a.srv.AgentBench(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+}
+
 func TestAgent_Members(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -2206,6 +2531,38 @@ func TestAgent_UpdateCheck(t *testing.T) {
 	})
 }
 
+func TestAgent_UpdateCheck_OutputMaxSize(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `check_output_max_size = 10`)
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	chk := &structs.HealthCheck{Name: "test", CheckID: "test"}
+	chkType := &structs.CheckType{TTL: 15 * time.Second}
+	if err := a.AddCheck(chk, chkType, false, "", ConfigSourceLocal); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	args := checkUpdate{
+		Status: api.HealthWarning,
+		Output: "0123456789abcdef",
+	}
+	req, _ := http.NewRequest("PUT", "/v1/agent/check/update/test", jsonReader(args))
+	resp := httptest.NewRecorder()
+	if _, err := a.srv.AgentCheckUpdate(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+
+	state := a.State.Checks()["test"]
+	want := "0123456789 ... (captured 10 of 16 bytes)"
+	if state.Status != api.HealthWarning || state.Output != want {
+		t.Fatalf("bad: %v", state)
+	}
+}
+
 func TestAgent_UpdateCheck_ACLDeny(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), TestACLConfig())
@@ -3902,6 +4259,76 @@ func TestAgent_NodeMaintenance_ACLDeny(t *testing.T) {
 	})
 }
 
+func TestAgent_NodeDrain(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// A missing or bad value for enable is rejected
+	for _, url := range []string{"/v1/agent/drain", "/v1/agent/drain?enable=nope"} {
+		req, _ := http.NewRequest("PUT", url, nil)
+		resp := httptest.NewRecorder()
+		if _, err := a.srv.AgentNodeDrain(resp, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if resp.Code != 400 {
+			t.Fatalf("expected 400, got %d", resp.Code)
+		}
+	}
+
+	// Put the node into drain mode
+	req, _ := http.NewRequest("PUT", "/v1/agent/drain?enable=true&reason=scaling+down", nil)
+	resp := httptest.NewRecorder()
+	if _, err := a.srv.AgentNodeDrain(resp, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+
+	// Ensure the drain check was registered, and is passing so the node
+	// stays discoverable
+	check, ok := a.State.Checks()[structs.NodeDrain]
+	if !ok {
+		t.Fatalf("should have registered drain check")
+	}
+	if check.Status != api.HealthPassing || check.Notes != "scaling down" {
+		t.Fatalf("bad: %#v", check)
+	}
+
+	// Leave drain mode
+	req, _ = http.NewRequest("PUT", "/v1/agent/drain?enable=false", nil)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.AgentNodeDrain(resp, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := a.State.Checks()[structs.NodeDrain]; ok {
+		t.Fatalf("should have removed drain check")
+	}
+}
+
+func TestAgent_NodeDrain_ACLDeny(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), TestACLConfig())
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	t.Run("no token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/drain?enable=true", nil)
+		if _, err := a.srv.AgentNodeDrain(nil, req); !acl.IsErrPermissionDenied(err) {
+			t.Fatalf("err: %v", err)
+		}
+	})
+
+	t.Run("root token", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/v1/agent/drain?enable=true&token=root", nil)
+		if _, err := a.srv.AgentNodeDrain(nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	})
+}
+
 func TestAgent_RegisterCheck_Service(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -4121,6 +4548,22 @@ func TestAgent_Token(t *testing.T) {
 			code:   http.StatusOK,
 			want:   tokens{repl: "R"},
 		},
+		{
+			name:   "set default by config name",
+			method: "PUT",
+			url:    "default?token=root",
+			body:   body("U"),
+			code:   http.StatusOK,
+			want:   tokens{user: "U", agent: "U"},
+		},
+		{
+			name:   "set agent by config name",
+			method: "PUT",
+			url:    "agent?token=root",
+			body:   body("A"),
+			code:   http.StatusOK,
+			want:   tokens{agent: "A"},
+		},
 		{
 			name:   "clear user",
 			method: "PUT",
@@ -4195,6 +4638,43 @@ func TestAgent_Token(t *testing.T) {
 	})
 }
 
+func TestAgent_Token_Persistence(t *testing.T) {
+	t.Parallel()
+
+	a := NewTestAgent(t.Name(), TestACLConfig()+`
+		acl {
+			enable_token_persistence = true
+		}
+	`)
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	req, _ := http.NewRequest("PUT", "/v1/agent/token/default?token=root", jsonReader(&api.AgentToken{Token: "U"}))
+	if _, err := a.srv.AgentToken(httptest.NewRecorder(), req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req, _ = http.NewRequest("PUT", "/v1/agent/token/acl_agent_token?token=root", jsonReader(&api.AgentToken{Token: "A"}))
+	if _, err := a.srv.AgentToken(httptest.NewRecorder(), req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// An agent started with the same data dir uses the saved tokens
+	// instead of the configured ones.
+	a2, err := New(a.config)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got, want := a2.tokens.UserToken(), "U"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if got, want := a2.tokens.AgentToken(), "A"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if !a2.tokens.IsAgentMasterToken("towel") {
+		t.Fatalf("should keep the configured agent master token")
+	}
+}
+
 func TestAgentConnectCARoots_empty(t *testing.T) {
 	t.Parallel()
 
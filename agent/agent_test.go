@@ -2573,6 +2573,61 @@ func TestAgent_AddCheck_restoresSnapshot(t *testing.T) {
 	}
 }
 
+func TestAgent_Leave_MaintenanceTime(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `
+		leave_maintenance_time = "1s"
+		performance {
+			leave_drain_time = "1ms"
+		}
+	`)
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- a.Leave()
+	}()
+
+	// The maintenance check should reach the catalog before the agent leaves.
+	retry.Run(t, func(r *retry.R) {
+		args := &structs.NodeSpecificRequest{
+			Datacenter: "dc1",
+			Node:       a.Config.NodeName,
+		}
+		var out structs.IndexedHealthChecks
+		if err := a.RPC("Health.NodeChecks", args, &out); err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		for _, check := range out.HealthChecks {
+			if check.CheckID == structs.NodeMaint && check.Status == api.HealthCritical {
+				return
+			}
+		}
+		r.Fatalf("node maintenance check not found in %v", out.HealthChecks)
+	})
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for leave")
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("left after %s, should have waited at least 1s", elapsed)
+	}
+
+	// The maintenance check must not be persisted, or a restarted agent
+	// would come back up in maintenance mode.
+	file := filepath.Join(a.Config.DataDir, checksDir, checkIDHash(structs.NodeMaint))
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("maintenance check should not be persisted, err: %v", err)
+	}
+}
+
 func TestAgent_NodeMaintenanceMode(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -2949,6 +3004,19 @@ func TestAgent_reloadWatchesHTTPS(t *testing.T) {
 	}
 }
 
+func TestAgent_ReloadConfig_FaultInjection(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	newConf := *a.config
+	newConf.FaultInjection = []structs.FaultRule{{Method: "KVS.Apply", ErrorRate: 1}}
+	require.NoError(t, a.ReloadConfig(&newConf))
+
+	// The servers get the reloaded rules.
+	require.Equal(t, newConf.FaultInjection, a.consulConfig().FaultInjection)
+}
+
 func TestAgent_AddProxy(t *testing.T) {
 	t.Parallel()
 
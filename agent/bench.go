@@ -0,0 +1,547 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// benchWorkloadKV writes keys, benchWorkloadRegister registers nodes
+	// with a service, and benchWorkloadBlocking measures how long a
+	// blocking query takes to return after the key it watches is written.
+	benchWorkloadKV       = "kv"
+	benchWorkloadRegister = "register"
+	benchWorkloadBlocking = "blocking"
+
+	// benchBlockingWait is the wait time of the blocking queries. They
+	// should return as soon as the key they watch is written, so a query
+	// that runs this long is counted as failed.
+	benchBlockingWait = 30 * time.Second
+
+	// The defaults of the benchmark options.
+	benchDefaultDuration    = 30 * time.Second
+	benchDefaultConcurrency = 8
+	benchDefaultKeys        = 100
+	benchDefaultValueSize   = 128
+	benchDefaultPrefix      = "consul-bench"
+
+	// The limits of the benchmark options, so a single request can't tie
+	// up the agent and the servers indefinitely.
+	benchMaxDuration    = 10 * time.Minute
+	benchMaxConcurrency = 64
+	benchMaxKeys        = 10000
+)
+
+// BenchWorkloads are the workloads a benchmark can run, in the order they're
+// reported.
+var BenchWorkloads = []string{benchWorkloadKV, benchWorkloadRegister, benchWorkloadBlocking}
+
+// errBenchRunning is returned when a benchmark is started while another one
+// is running on the agent.
+var errBenchRunning = fmt.Errorf("a benchmark is already running on this agent")
+
+// benchRun is a benchmark the agent is running. The requests are sent as
+// RPCs with the token of the HTTP request that started the run, so they're
+// subject to the ACLs of the keys and nodes written.
+type benchRun struct {
+	agent *Agent
+	opts  api.AgentBenchOptions
+	token string
+}
+
+// benchResult has what was measured for a workload.
+type benchResult struct {
+	// seq numbers the requests of the workload, so the workers spread
+	// them over the keys and nodes.
+	seq uint64
+
+	sync.Mutex
+	latencies []time.Duration
+	errors    int
+	firstErr  error
+}
+
+// record records the outcome of one request.
+func (r *benchResult) record(latency time.Duration, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if err != nil {
+		r.errors++
+		if r.firstErr == nil {
+			r.firstErr = err
+		}
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+}
+
+// setBenchDefaults fills in the defaults of the options left unset, and
+// checks them against the limits.
+func setBenchDefaults(opts *api.AgentBenchOptions) error {
+	if len(opts.Workloads) == 0 {
+		opts.Workloads = []string{benchWorkloadKV}
+	}
+	if opts.Duration == 0 {
+		opts.Duration = benchDefaultDuration
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = benchDefaultConcurrency
+	}
+	if opts.Keys == 0 {
+		opts.Keys = benchDefaultKeys
+	}
+	if opts.ValueSize == 0 {
+		opts.ValueSize = benchDefaultValueSize
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = benchDefaultPrefix
+	}
+
+	for _, w := range opts.Workloads {
+		valid := false
+		for _, known := range BenchWorkloads {
+			if w == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown workload %q, must be one of: %s", w, strings.Join(BenchWorkloads, ", "))
+		}
+	}
+	if opts.Duration < 0 || opts.Duration > benchMaxDuration {
+		return fmt.Errorf("duration must be greater than zero and at most %s", benchMaxDuration)
+	}
+	if opts.Rate < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	if opts.Concurrency < 0 || opts.Concurrency > benchMaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", benchMaxConcurrency)
+	}
+	if opts.Keys < 0 || opts.Keys > benchMaxKeys {
+		return fmt.Errorf("keys must be between 1 and %d", benchMaxKeys)
+	}
+	if opts.ValueSize < 0 || opts.ValueSize > maxKVSize {
+		return fmt.Errorf("value size must be between 1 and %d bytes", maxKVSize)
+	}
+	return nil
+}
+
+// Bench generates load against the cluster with the given options, and
+// reports what was measured once the duration has passed, ctx is done, or
+// the run is stopped with StopBench. Only one benchmark runs at a time.
+func (a *Agent) Bench(ctx context.Context, opts api.AgentBenchOptions, token string) (*api.AgentBenchReport, error) {
+	if err := setBenchDefaults(&opts); err != nil {
+		return nil, BadRequestError{Reason: err.Error()}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	a.benchLock.Lock()
+	if a.benchStop != nil {
+		a.benchLock.Unlock()
+		return nil, errBenchRunning
+	}
+	a.benchStop = cancel
+	a.benchLock.Unlock()
+	defer func() {
+		a.benchLock.Lock()
+		a.benchStop = nil
+		a.benchLock.Unlock()
+	}()
+
+	b := &benchRun{agent: a, opts: opts, token: token}
+	if err := b.checkTargets(); err != nil {
+		return nil, err
+	}
+
+	// The duration only starts once the targets are checked.
+	ctx, cancelRun := context.WithTimeout(ctx, opts.Duration)
+	defer cancelRun()
+	a.logger.Printf("[INFO] agent: Running benchmark %s for %s with %d workers each",
+		strings.Join(opts.Workloads, ", "), opts.Duration, opts.Concurrency)
+	start := time.Now()
+	results := make(map[string]*benchResult)
+	var wg sync.WaitGroup
+	for _, w := range opts.Workloads {
+		if _, ok := results[w]; ok {
+			continue
+		}
+		r := &benchResult{}
+		results[w] = r
+
+		limiter := rate.NewLimiter(rate.Inf, 1)
+		if opts.Rate > 0 {
+			limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+		}
+		for i := 0; i < opts.Concurrency; i++ {
+			wg.Add(1)
+			go func(w string, worker int) {
+				defer wg.Done()
+				b.runWorker(ctx, w, worker, limiter, r)
+			}(w, i)
+		}
+	}
+	wg.Wait()
+	report := b.report(results, time.Since(start))
+
+	if !opts.SkipCleanup {
+		if err := b.cleanUp(results); err != nil {
+			return nil, fmt.Errorf("error cleaning up: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// StopBench ends the benchmark the agent is running early. It returns false
+// if there's none.
+func (a *Agent) StopBench() bool {
+	a.benchLock.Lock()
+	defer a.benchLock.Unlock()
+	if a.benchStop == nil {
+		return false
+	}
+	a.benchStop()
+	return true
+}
+
+// runWorker sends the requests of a workload one after the other until the
+// context is done.
+func (b *benchRun) runWorker(ctx context.Context, workload string, worker int,
+	limiter *rate.Limiter, r *benchResult) {
+
+	// The blocking workload watches a key of its own, and needs the index
+	// of the key to block on.
+	blockingKey := b.blockingKey(worker)
+	var blockingIndex uint64
+
+	for {
+		if err := limiter.Wait(ctx); err != nil {
+			return
+		}
+		n := atomic.AddUint64(&r.seq, 1) - 1
+		start := time.Now()
+
+		var err error
+		switch workload {
+		case benchWorkloadKV:
+			err = b.writeKey(b.kvKey(n%uint64(b.opts.Keys)), b.value(n))
+
+		case benchWorkloadRegister:
+			err = b.registerNode(b.nodeName(n%uint64(b.opts.Keys)), n)
+
+		case benchWorkloadBlocking:
+			blockingIndex, err = b.writeAndWatch(blockingKey, blockingIndex, n, &start)
+		}
+
+		// Requests cut short at the end of the run don't count.
+		if ctx.Err() != nil {
+			return
+		}
+		r.record(time.Since(start), err)
+	}
+}
+
+// kvKey, blockingKey and nodeName return the names of the keys and nodes the
+// workloads write.
+func (b *benchRun) kvKey(i uint64) string {
+	return fmt.Sprintf("%s/kv/%d", b.opts.Prefix, i)
+}
+
+func (b *benchRun) blockingKey(worker int) string {
+	return fmt.Sprintf("%s/blocking/%d", b.opts.Prefix, worker)
+}
+
+func (b *benchRun) nodeName(i uint64) string {
+	return fmt.Sprintf("%s-%d", b.opts.Prefix, i)
+}
+
+// checkTargets returns an error if any of the keys or nodes the workloads
+// write already exist. They'd be overwritten by the run, and deleted when
+// cleaning up after it.
+func (b *benchRun) checkTargets() error {
+	var keys, nodes []string
+	for _, w := range b.opts.Workloads {
+		switch w {
+		case benchWorkloadKV:
+			for i := 0; i < b.opts.Keys; i++ {
+				keys = append(keys, b.kvKey(uint64(i)))
+			}
+		case benchWorkloadBlocking:
+			for i := 0; i < b.opts.Concurrency; i++ {
+				keys = append(keys, b.blockingKey(i))
+			}
+		case benchWorkloadRegister:
+			for i := 0; i < b.opts.Keys; i++ {
+				nodes = append(nodes, b.nodeName(uint64(i)))
+			}
+		}
+	}
+
+	if len(keys) > 0 {
+		args := structs.KeyListRequest{
+			Datacenter:   b.agent.config.Datacenter,
+			Prefix:       b.opts.Prefix + "/",
+			QueryOptions: structs.QueryOptions{Token: b.token},
+		}
+		var out structs.IndexedKeyList
+		if err := b.agent.RPC("KVS.ListKeys", &args, &out); err != nil {
+			return err
+		}
+		if k := firstExisting(keys, out.Keys); k != "" {
+			return BadRequestError{Reason: fmt.Sprintf("key %q already exists, use another prefix", k)}
+		}
+	}
+	if len(nodes) > 0 {
+		args := structs.DCSpecificRequest{
+			Datacenter:   b.agent.config.Datacenter,
+			QueryOptions: structs.QueryOptions{Token: b.token},
+		}
+		var out structs.IndexedNodes
+		if err := b.agent.RPC("Catalog.ListNodes", &args, &out); err != nil {
+			return err
+		}
+		existing := make([]string, 0, len(out.Nodes))
+		for _, node := range out.Nodes {
+			existing = append(existing, node.Node)
+		}
+		if n := firstExisting(nodes, existing); n != "" {
+			return BadRequestError{Reason: fmt.Sprintf("node %q already exists, use another prefix", n)}
+		}
+	}
+	return nil
+}
+
+// firstExisting returns the first of names that's in existing, or an empty
+// string if there's none.
+func firstExisting(names, existing []string) string {
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+	for _, name := range names {
+		if found[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// value returns the value written for the request with the given sequence
+// number. It starts with the number, so every write changes the key.
+func (b *benchRun) value(n uint64) []byte {
+	v := []byte(strconv.FormatUint(n, 10))
+	if len(v) < b.opts.ValueSize {
+		v = append(v, bytes.Repeat([]byte("x"), b.opts.ValueSize-len(v))...)
+	}
+	return v
+}
+
+func (b *benchRun) writeKey(key string, value []byte) error {
+	return b.applyKey(api.KVSet, key, value)
+}
+
+func (b *benchRun) applyKey(op api.KVOp, key string, value []byte) error {
+	args := structs.KVSRequest{
+		Datacenter:   b.agent.config.Datacenter,
+		Op:           op,
+		DirEnt:       structs.DirEntry{Key: key, Value: value},
+		WriteRequest: structs.WriteRequest{Token: b.token},
+	}
+	var out bool
+	return b.agent.RPC("KVS.Apply", &args, &out)
+}
+
+func (b *benchRun) registerNode(node string, n uint64) error {
+	// The servers skip registrations that don't change anything, so the
+	// sequence number goes in the service's metadata to make each one a
+	// write.
+	args := structs.RegisterRequest{
+		Datacenter: b.agent.config.Datacenter,
+		Node:       node,
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			ID:      b.opts.Prefix,
+			Service: b.opts.Prefix,
+			Port:    8080,
+			Meta:    map[string]string{"bench-seq": strconv.FormatUint(n, 10)},
+		},
+		WriteRequest: structs.WriteRequest{Token: b.token},
+	}
+	var out struct{}
+	return b.agent.RPC("Catalog.Register", &args, &out)
+}
+
+// getKey returns the value of the key, blocking until its index is past the
+// given one if that's not zero, and the index to block on next.
+func (b *benchRun) getKey(key string, index uint64, wait time.Duration) ([]byte, uint64, error) {
+	args := structs.KeyRequest{
+		Datacenter: b.agent.config.Datacenter,
+		Key:        key,
+		QueryOptions: structs.QueryOptions{
+			Token:         b.token,
+			MinQueryIndex: index,
+			MaxQueryTime:  wait,
+		},
+	}
+	var out structs.IndexedDirEntries
+	if err := b.agent.RPC("KVS.Get", &args, &out); err != nil {
+		return nil, 0, err
+	}
+	if len(out.Entries) == 0 {
+		return nil, out.Index, nil
+	}
+	return out.Entries[0].Value, out.Index, nil
+}
+
+// writeAndWatch writes the key while a blocking query watches it, and returns
+// once the query sees the write. The latency is measured from the start of
+// the write, which start is set to, so it covers the write and the
+// notification of the watcher. It returns the index to block on next.
+func (b *benchRun) writeAndWatch(key string, index uint64, n uint64, start *time.Time) (uint64, error) {
+	if index == 0 {
+		_, idx, err := b.getKey(key, 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		index = idx
+	}
+
+	value := b.value(n)
+	type watchResult struct {
+		index uint64
+		err   error
+	}
+	// The channel is buffered so the watcher can finish on its own if the
+	// write fails and nobody waits for it.
+	watchCh := make(chan watchResult, 1)
+	go func() {
+		deadline := time.Now().Add(benchBlockingWait)
+		for {
+			got, idx, err := b.getKey(key, index, time.Until(deadline))
+			if err != nil {
+				watchCh <- watchResult{err: err}
+				return
+			}
+			if bytes.Equal(got, value) {
+				watchCh <- watchResult{index: idx}
+				return
+			}
+			if time.Now().After(deadline) {
+				watchCh <- watchResult{err: fmt.Errorf("blocking query didn't return within %s of the write", benchBlockingWait)}
+				return
+			}
+			index = idx
+		}
+	}()
+
+	*start = time.Now()
+	if err := b.writeKey(key, value); err != nil {
+		// Look the index up again next time, in case the write went
+		// through.
+		return 0, err
+	}
+	res := <-watchCh
+	if res.err != nil {
+		return 0, res.err
+	}
+	return res.index, nil
+}
+
+// report sums up the throughput and latency percentiles of each workload.
+func (b *benchRun) report(results map[string]*benchResult, elapsed time.Duration) *api.AgentBenchReport {
+	report := &api.AgentBenchReport{Elapsed: elapsed}
+	for _, w := range BenchWorkloads {
+		r, ok := results[w]
+		if !ok {
+			continue
+		}
+		r.Lock()
+		latencies := append([]time.Duration(nil), r.latencies...)
+		workload := api.AgentBenchWorkload{
+			Name:     w,
+			Requests: len(latencies),
+			Errors:   r.errors,
+			Rate:     float64(len(latencies)) / elapsed.Seconds(),
+		}
+		if r.firstErr != nil {
+			workload.FirstError = r.firstErr.Error()
+		}
+		r.Unlock()
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		workload.P50 = percentile(latencies, 0.5)
+		workload.P90 = percentile(latencies, 0.9)
+		workload.P99 = percentile(latencies, 0.99)
+		workload.Max = percentile(latencies, 1)
+		report.Workloads = append(report.Workloads, workload)
+	}
+	return report
+}
+
+// percentile returns the latency that the given fraction of the sorted
+// latencies are at or below, or zero if there are none.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return latencies[i]
+}
+
+// cleanUp deletes the keys and deregisters the nodes written by the run.
+// Only the exact keys and nodes the workloads write are removed, which
+// checkTargets made sure didn't exist before.
+func (b *benchRun) cleanUp(results map[string]*benchResult) error {
+	if r, ok := results[benchWorkloadKV]; ok {
+		for i := uint64(0); i < b.written(r); i++ {
+			if err := b.applyKey(api.KVDelete, b.kvKey(i), nil); err != nil {
+				return err
+			}
+		}
+	}
+	if _, ok := results[benchWorkloadBlocking]; ok {
+		for i := 0; i < b.opts.Concurrency; i++ {
+			if err := b.applyKey(api.KVDelete, b.blockingKey(i), nil); err != nil {
+				return err
+			}
+		}
+	}
+	if r, ok := results[benchWorkloadRegister]; ok {
+		for i := uint64(0); i < b.written(r); i++ {
+			args := structs.DeregisterRequest{
+				Datacenter:   b.agent.config.Datacenter,
+				Node:         b.nodeName(i),
+				WriteRequest: structs.WriteRequest{Token: b.token},
+			}
+			var out struct{}
+			if err := b.agent.RPC("Catalog.Deregister", &args, &out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// written returns how many of the keys or nodes a workload may have written.
+func (b *benchRun) written(r *benchResult) uint64 {
+	n := atomic.LoadUint64(&r.seq)
+	if n > uint64(b.opts.Keys) {
+		n = uint64(b.opts.Keys)
+	}
+	return n
+}
@@ -278,6 +278,24 @@ RETRY_GET:
 		cacheHit = false
 	}
 
+	// Background refresh types keep the entry as fresh as a blocking query
+	// allows, so fetching through the entry would only wait on the running
+	// refresh. If the caller demands something fresher than the entry can
+	// promise, go straight to the servers instead and leave the entry alone.
+	// On failure the cached value is returned alongside the error so the
+	// caller can decide whether it's acceptable.
+	if cacheHit && tEntry.Opts.Refresh && first && minIndex == 0 {
+		age := refreshAge(&entry)
+		if info.MustRevalidate || (info.MaxAge > 0 && info.MaxAge < age) {
+			metrics.IncrCounter([]string{"consul", "cache", t, "revalidate"}, 1)
+			value, meta, err := c.fetchDirect(t, r, 0)
+			if err != nil {
+				return entry.Value, ResultMeta{Index: entry.Index, Hit: true, Age: age}, err
+			}
+			return value, meta, nil
+		}
+	}
+
 	if cacheHit {
 		meta := ResultMeta{Index: entry.Index}
 		if first {
@@ -288,10 +306,7 @@ RETRY_GET:
 		// If refresh is enabled, calculate age based on whether the background
 		// routine is still connected.
 		if tEntry.Opts.Refresh {
-			meta.Age = time.Duration(0)
-			if !entry.RefreshLostContact.IsZero() {
-				meta.Age = time.Since(entry.RefreshLostContact)
-			}
+			meta.Age = refreshAge(&entry)
 		} else {
 			// For non-background refresh types, the age is just how long since we
 			// fetched it last.
@@ -603,7 +618,17 @@ func (c *Cache) fetchDirect(t string, r Request, minIndex uint64) (interface{},
 	}
 
 	// Return the result and ignore the rest
-	return result.Value, ResultMeta{}, nil
+	return result.Value, ResultMeta{Index: result.Index}, nil
+}
+
+// refreshAge returns the age of an entry belonging to a background refresh
+// type. It is zero while the refresh is connected since the value is as fresh
+// as the servers can make it, and otherwise the time since contact was lost.
+func refreshAge(entry *cacheEntry) time.Duration {
+	if entry.RefreshLostContact.IsZero() {
+		return 0
+	}
+	return time.Since(entry.RefreshLostContact)
 }
 
 func backOffWait(failures uint) time.Duration {
@@ -1033,6 +1033,94 @@ func TestCacheGet_refreshAge(t *testing.T) {
 	require.False(timeout, "failed to observe update after %s", time.Since(t0))
 }
 
+func TestCacheGet_refreshRevalidate(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	typ := TestType(t)
+	defer typ.AssertExpectations(t)
+	c := TestCache(t)
+	c.RegisterType("t", typ, &RegisterOptions{
+		Refresh:        true,
+		RefreshTimer:   0,
+		RefreshTimeout: 5 * time.Minute,
+	})
+
+	// Background refreshes block until the test ends so that only direct
+	// fetches can observe new data.
+	unblockCh := make(chan struct{})
+	defer close(unblockCh)
+
+	var index, shouldFail uint64
+	typ.On("Fetch", mock.Anything, mock.Anything).
+		Return(func(o FetchOptions, r Request) FetchResult {
+			if o.MinIndex > 0 {
+				<-unblockCh
+			}
+			idx := atomic.LoadUint64(&index)
+			if atomic.LoadUint64(&shouldFail) == 1 {
+				return FetchResult{Value: nil, Index: idx}
+			}
+			return FetchResult{Value: int(idx * 2), Index: idx}
+		}, func(o FetchOptions, r Request) error {
+			if atomic.LoadUint64(&shouldFail) == 1 {
+				return errors.New("test error")
+			}
+			return nil
+		})
+
+	// Set initial index/value
+	atomic.StoreUint64(&index, 4)
+
+	// Fetch
+	resultCh := TestCacheGetCh(t, c, "t", TestRequest(t, RequestInfo{Key: "hello"}))
+	TestCacheGetChResult(t, resultCh, 8)
+
+	// Change the data behind the cache's back.
+	atomic.StoreUint64(&index, 5)
+
+	{
+		// A normal get is a hit on the old value.
+		result, meta, err := c.Get("t", TestRequest(t, RequestInfo{Key: "hello"}))
+		require.NoError(err)
+		require.Equal(8, result)
+		require.True(meta.Hit)
+	}
+
+	{
+		// MaxAge is satisfied while the background refresh is connected.
+		result, meta, err := c.Get("t", TestRequest(t, RequestInfo{
+			Key: "hello", MaxAge: time.Second}))
+		require.NoError(err)
+		require.Equal(8, result)
+		require.True(meta.Hit)
+	}
+
+	{
+		// Revalidating goes to the servers directly.
+		result, meta, err := c.Get("t", TestRequest(t, RequestInfo{
+			Key: "hello", MustRevalidate: true}))
+		require.NoError(err)
+		require.Equal(10, result)
+		require.False(meta.Hit)
+		require.Equal(uint64(5), meta.Index)
+	}
+
+	// Now fail the direct fetch.
+	atomic.StoreUint64(&shouldFail, 1)
+
+	{
+		// The cached value comes back with the error.
+		result, meta, err := c.Get("t", TestRequest(t, RequestInfo{
+			Key: "hello", MustRevalidate: true}))
+		require.Error(err)
+		require.Equal(8, result)
+		require.True(meta.Hit)
+		require.Equal(uint64(4), meta.Index)
+	}
+}
+
 func TestCacheGet_nonRefreshAge(t *testing.T) {
 	t.Parallel()
 
@@ -51,15 +51,16 @@ type RequestInfo struct {
 
 	// MaxAge if set limits how stale a cache entry can be. If it is non-zero and
 	// there is an entry in cache that is older than specified, it is treated as a
-	// cache miss and re-fetched. It is ignored for cachetypes with Refresh =
-	// true.
+	// cache miss and re-fetched. For cachetypes with Refresh = true the age is
+	// how long the background refresh has been out of contact with the servers,
+	// and a stale entry is bypassed with a direct fetch rather than re-fetched.
 	MaxAge time.Duration
 
 	// MustRevalidate forces a new lookup of the cache even if there is an
 	// existing one that has not expired. It is implied by HTTP requests with
 	// `Cache-Control: max-age=0` but we can't distinguish that case from the
 	// unset case for MaxAge. Later we may support revalidating the index without
-	// a full re-fetch but for now the only option is to refetch. It is ignored
-	// for cachetypes with Refresh = true.
+	// a full re-fetch but for now the only option is to refetch. For cachetypes
+	// with Refresh = true the entry is bypassed with a direct fetch.
 	MustRevalidate bool
 }
@@ -3,6 +3,7 @@ package agent
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	metrics "github.com/armon/go-metrics"
@@ -12,6 +13,21 @@ import (
 
 var durations = NewDurationFixer("interval", "timeout", "deregistercriticalserviceafter")
 
+// parseCAS looks for a "cas" query parameter and, if there is one, makes the
+// request a check-and-set operation against the given index.
+func parseCAS(req *http.Request, cas *bool, index *uint64) error {
+	if _, ok := req.URL.Query()["cas"]; !ok {
+		return nil
+	}
+	casVal, err := strconv.ParseUint(req.URL.Query().Get("cas"), 10, 64)
+	if err != nil {
+		return err
+	}
+	*cas = true
+	*index = casVal
+	return nil
+}
+
 func (s *HTTPServer) CatalogRegister(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	metrics.IncrCounterWithLabels([]string{"client", "api", "catalog_register"}, 1,
 		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
@@ -27,11 +43,22 @@ func (s *HTTPServer) CatalogRegister(resp http.ResponseWriter, req *http.Request
 	if args.Datacenter == "" {
 		args.Datacenter = s.agent.config.Datacenter
 	}
-	s.parseToken(req, &args.Token)
+	if s.parseWriteRequest(resp, req, &args.WriteRequest) {
+		return nil, nil
+	}
+	if err := parseCAS(req, &args.CAS, &args.ModifyIndex); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Invalid cas index: %v", err)
+		return nil, nil
+	}
 
-	// Forward to the servers
+	// Forward to the servers. A failed check-and-set returns false, like
+	// it does for KV.
 	var out struct{}
 	if err := s.agent.RPC("Catalog.Register", &args, &out); err != nil {
+		if args.CAS && structs.IsErrCASFailed(err) {
+			return false, nil
+		}
 		metrics.IncrCounterWithLabels([]string{"client", "rpc", "error", "catalog_register"}, 1,
 			[]metrics.Label{{Name: "node", Value: s.nodeName()}})
 		return nil, err
@@ -56,11 +83,22 @@ func (s *HTTPServer) CatalogDeregister(resp http.ResponseWriter, req *http.Reque
 	if args.Datacenter == "" {
 		args.Datacenter = s.agent.config.Datacenter
 	}
-	s.parseToken(req, &args.Token)
+	if s.parseWriteRequest(resp, req, &args.WriteRequest) {
+		return nil, nil
+	}
+	if err := parseCAS(req, &args.CAS, &args.ModifyIndex); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Invalid cas index: %v", err)
+		return nil, nil
+	}
 
-	// Forward to the servers
+	// Forward to the servers. A failed check-and-set returns false, like
+	// it does for KV.
 	var out struct{}
 	if err := s.agent.RPC("Catalog.Deregister", &args, &out); err != nil {
+		if args.CAS && structs.IsErrCASFailed(err) {
+			return false, nil
+		}
 		metrics.IncrCounterWithLabels([]string{"client", "rpc", "error", "catalog_deregister"}, 1,
 			[]metrics.Label{{Name: "node", Value: s.nodeName()}})
 		return nil, err
@@ -70,6 +108,37 @@ func (s *HTTPServer) CatalogDeregister(resp http.ResponseWriter, req *http.Reque
 	return true, nil
 }
 
+func (s *HTTPServer) CatalogUpdateCheck(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	metrics.IncrCounterWithLabels([]string{"client", "api", "catalog_update_check"}, 1,
+		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+
+	var args structs.CheckUpdateRequest
+	if err := decodeBody(req, &args, nil); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Request decode failed: %v", err)
+		return nil, nil
+	}
+
+	// Setup the default DC if not provided
+	if args.Datacenter == "" {
+		args.Datacenter = s.agent.config.Datacenter
+	}
+	if s.parseWriteRequest(resp, req, &args.WriteRequest) {
+		return nil, nil
+	}
+
+	// Forward to the servers
+	var out struct{}
+	if err := s.agent.RPC("Catalog.UpdateCheck", &args, &out); err != nil {
+		metrics.IncrCounterWithLabels([]string{"client", "rpc", "error", "catalog_update_check"}, 1,
+			[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+		return nil, err
+	}
+	metrics.IncrCounterWithLabels([]string{"client", "api", "success", "catalog_update_check"}, 1,
+		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+	return true, nil
+}
+
 func (s *HTTPServer) CatalogDatacenters(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	metrics.IncrCounterWithLabels([]string{"client", "api", "catalog_datacenters"}, 1,
 		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
@@ -113,6 +182,7 @@ RETRY_ONCE:
 	out.ConsistencyLevel = args.QueryOptions.ConsistencyLevel()
 
 	s.agent.TranslateAddresses(args.Datacenter, out.Nodes)
+	setAddressFamilies(out.Nodes)
 
 	// Use empty list instead of nil
 	if out.Nodes == nil {
@@ -123,6 +193,47 @@ RETRY_ONCE:
 	return out.Nodes, nil
 }
 
+// CatalogNodesWithoutService returns the nodes that don't have a passing
+// instance of a service.
+func (s *HTTPServer) CatalogNodesWithoutService(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	metrics.IncrCounterWithLabels([]string{"client", "api", "catalog_nodes_without_service"}, 1,
+		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+
+	// Setup the request
+	args := structs.ServiceSpecificRequest{}
+	s.parseSource(req, &args.Source)
+	args.NodeMetaFilters = s.parseMetaFilter(req)
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	// Pull out the service name
+	args.ServiceName = strings.TrimPrefix(req.URL.Path, "/v1/catalog/nodes-without-service/")
+	if args.ServiceName == "" {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Missing service name")
+		return nil, nil
+	}
+
+	var out structs.IndexedNodes
+	defer setMeta(resp, &out.QueryMeta)
+	if err := s.agent.RPC("Catalog.NodesWithoutService", &args, &out); err != nil {
+		metrics.IncrCounterWithLabels([]string{"client", "rpc", "error", "catalog_nodes_without_service"}, 1,
+			[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+		return nil, err
+	}
+	s.agent.TranslateAddresses(args.Datacenter, out.Nodes)
+	setAddressFamilies(out.Nodes)
+
+	// Use empty list instead of nil
+	if out.Nodes == nil {
+		out.Nodes = make(structs.Nodes, 0)
+	}
+	metrics.IncrCounterWithLabels([]string{"client", "api", "success", "catalog_nodes_without_service"}, 1,
+		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+	return out.Nodes, nil
+}
+
 func (s *HTTPServer) CatalogServices(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	metrics.IncrCounterWithLabels([]string{"client", "api", "catalog_services"}, 1,
 		[]metrics.Label{{Name: "node", Value: s.nodeName()}})
@@ -181,6 +292,7 @@ func (s *HTTPServer) catalogServiceNodes(resp http.ResponseWriter, req *http.Req
 	args := structs.ServiceSpecificRequest{Connect: connect}
 	s.parseSource(req, &args.Source)
 	args.NodeMetaFilters = s.parseMetaFilter(req)
+	args.MultiDC = s.parseMultiDC(req)
 	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
 		return nil, nil
 	}
@@ -200,6 +312,14 @@ func (s *HTTPServer) catalogServiceNodes(resp http.ResponseWriter, req *http.Req
 		return nil, nil
 	}
 
+	// Signed responses are signed by the servers of a single datacenter.
+	args.Sign = parseSigned(req)
+	if args.Sign && len(args.MultiDC) > 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Signed responses can't span several datacenters")
+		return nil, nil
+	}
+
 	// Make the RPC request
 	var out structs.IndexedServiceNodes
 	defer setMeta(resp, &out.QueryMeta)
@@ -209,7 +329,11 @@ func (s *HTTPServer) catalogServiceNodes(resp http.ResponseWriter, req *http.Req
 		if err != nil {
 			metrics.IncrCounterWithLabels([]string{"client", "rpc", "error", "catalog_service_nodes"}, 1,
 				[]metrics.Label{{Name: "node", Value: s.nodeName()}})
-			return nil, err
+			// Don't return error if StaleIfError is set and we are within it and had
+			// a cached value.
+			if raw == nil || !m.Hit || args.QueryOptions.StaleIfError <= m.Age {
+				return nil, err
+			}
 		}
 		defer setCacheMeta(resp, &m)
 		reply, ok := raw.(*structs.IndexedServiceNodes)
@@ -233,7 +357,25 @@ func (s *HTTPServer) catalogServiceNodes(resp http.ResponseWriter, req *http.Req
 	}
 
 	out.ConsistencyLevel = args.QueryOptions.ConsistencyLevel()
-	s.agent.TranslateAddresses(args.Datacenter, out.ServiceNodes)
+
+	// The payload the servers signed is returned as-is, so its addresses
+	// aren't translated.
+	if args.Sign {
+		metrics.IncrCounterWithLabels([]string{"client", "api", "success", "catalog_service_nodes"}, 1,
+			[]metrics.Label{{Name: "node", Value: s.nodeName()}})
+		return nil, writeSignedResponse(resp, &out.QueryMeta, out.Signature)
+	}
+
+	// Nodes from several datacenters are translated for the one they came
+	// from.
+	if len(args.MultiDC) > 0 {
+		for i := range out.ServiceNodes {
+			s.agent.TranslateAddresses(out.ServiceNodes[i].Datacenter, out.ServiceNodes[i:i+1])
+		}
+	} else {
+		s.agent.TranslateAddresses(args.Datacenter, out.ServiceNodes)
+	}
+	setAddressFamilies(out.ServiceNodes)
 
 	// Use empty list instead of nil
 	if out.ServiceNodes == nil {
@@ -287,6 +429,9 @@ RETRY_ONCE:
 	if out.NodeServices != nil && out.NodeServices.Node != nil {
 		s.agent.TranslateAddresses(args.Datacenter, out.NodeServices.Node)
 	}
+	if out.NodeServices != nil {
+		setAddressFamilies(out.NodeServices)
+	}
 
 	// TODO: The NodeServices object in IndexedNodeServices is a pointer to
 	// something that's created for each request by the state store way down
@@ -596,6 +596,55 @@ func TestCatalogServiceNodes(t *testing.T) {
 	}
 }
 
+func TestCatalogServiceNodes_AddressFamily(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	require := require.New(t)
+
+	args := &structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "api",
+			Address: "2001:db8::1",
+		},
+	}
+	var out struct{}
+	require.NoError(a.RPC("Catalog.Register", args, &out))
+
+	req, _ := http.NewRequest("GET", "/v1/catalog/service/api", nil)
+	resp := httptest.NewRecorder()
+	obj, err := a.srv.CatalogServiceNodes(resp, req)
+	require.NoError(err)
+	nodes := obj.(structs.ServiceNodes)
+	require.Len(nodes, 1)
+	require.Equal(structs.AddressFamilyIPv4, nodes[0].AddressFamily)
+	require.Equal(structs.AddressFamilyIPv6, nodes[0].ServiceAddressFamily)
+
+	// The health endpoint reports them for the node and the service.
+	req, _ = http.NewRequest("GET", "/v1/health/service/api", nil)
+	resp = httptest.NewRecorder()
+	obj, err = a.srv.HealthServiceNodes(resp, req)
+	require.NoError(err)
+	entries := obj.(structs.CheckServiceNodes)
+	require.Len(entries, 1)
+	require.Equal(structs.AddressFamilyIPv4, entries[0].Node.AddressFamily)
+	require.Equal(structs.AddressFamilyIPv6, entries[0].Service.AddressFamily)
+
+	// The entries in the state store, which the agent's server hands out
+	// directly, are left alone.
+	var stored structs.IndexedServiceNodes
+	require.NoError(a.RPC("Catalog.ServiceNodes", &structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "api",
+	}, &stored))
+	require.Len(stored.ServiceNodes, 1)
+	require.Empty(stored.ServiceNodes[0].AddressFamily)
+	require.Empty(stored.ServiceNodes[0].ServiceAddressFamily)
+}
+
 func TestCatalogServiceNodes_NodeMetaFilter(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
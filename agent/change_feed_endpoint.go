@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/consul"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// changeFeedResponse is the body returned by the change feed endpoint.
+type changeFeedResponse struct {
+	Entries   structs.ChangeFeedEntries
+	NextIndex uint64
+	More      bool
+}
+
+// GET /v1/changes
+func (s *HTTPServer) ChangeFeedList(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.ChangeFeedRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+	if err := parseLimit(req, &args.Limit); err != nil {
+		return nil, BadRequestError{Reason: fmt.Sprintf("Invalid limit: %v", err)}
+	}
+
+	var reply structs.IndexedChangeFeed
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("ChangeFeed.List", &args, &reply); err != nil {
+		// We have to check the string since the RPC sheds the error type
+		switch {
+		case err.Error() == consul.ErrChangeFeedDisabled.Error():
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(resp, err.Error())
+			return nil, nil
+		case strings.Contains(err.Error(), consul.ErrChangeFeedExpired.Error()):
+			resp.WriteHeader(http.StatusGone)
+			fmt.Fprint(resp, err.Error())
+			return nil, nil
+		case strings.Contains(err.Error(), "Bad limit"):
+			return nil, BadRequestError{Reason: err.Error()}
+		}
+		return nil, err
+	}
+
+	return changeFeedResponse{
+		Entries:   reply.Entries,
+		NextIndex: reply.NextIndex,
+		More:      reply.More,
+	}, nil
+}
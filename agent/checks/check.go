@@ -64,9 +64,14 @@ type CheckMonitor struct {
 	Timeout    time.Duration
 	Logger     *log.Logger
 
-	stop     bool
-	stopCh   chan struct{}
-	stopLock sync.Mutex
+	// Scheduler runs the check, if set. Otherwise it runs on a goroutine of
+	// its own.
+	Scheduler *Scheduler
+
+	scheduled *ScheduledCheck
+	stop      bool
+	stopCh    chan struct{}
+	stopLock  sync.Mutex
 }
 
 // Start is used to start a check monitor.
@@ -76,7 +81,11 @@ func (c *CheckMonitor) Start() {
 	defer c.stopLock.Unlock()
 	c.stop = false
 	c.stopCh = make(chan struct{})
-	go c.run()
+	if c.Scheduler != nil {
+		c.scheduled = c.Scheduler.Schedule(c.Interval, c.Timeout, c.check)
+	} else {
+		go c.run()
+	}
 }
 
 // Stop is used to stop a check monitor.
@@ -86,6 +95,9 @@ func (c *CheckMonitor) Stop() {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		if c.scheduled != nil {
+			c.scheduled.Stop()
+		}
 	}
 }
 
@@ -304,7 +316,19 @@ type CheckHTTP struct {
 	Logger          *log.Logger
 	TLSClientConfig *tls.Config
 
+	// Control, if set, is called with the resolved address of every
+	// connection the check makes, including the ones of redirects, and
+	// the connection is refused if it returns an error. Proxies from the
+	// environment aren't used when it's set, so it sees the target's
+	// address.
+	Control func(network, address string, c syscall.RawConn) error
+
+	// Scheduler runs the check, if set. Otherwise it runs on a goroutine of
+	// its own.
+	Scheduler *Scheduler
+
 	httpClient *http.Client
+	scheduled  *ScheduledCheck
 	stop       bool
 	stopCh     chan struct{}
 	stopLock   sync.Mutex
@@ -325,6 +349,16 @@ func (c *CheckHTTP) Start() {
 		// Take on the supplied TLS client config.
 		trans.TLSClientConfig = c.TLSClientConfig
 
+		if c.Control != nil {
+			trans.Proxy = nil
+			trans.DialContext = (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+				DualStack: true,
+				Control:   c.Control,
+			}).DialContext
+		}
+
 		// Create the HTTP client.
 		c.httpClient = &http.Client{
 			Timeout:   10 * time.Second,
@@ -343,7 +377,11 @@ func (c *CheckHTTP) Start() {
 
 	c.stop = false
 	c.stopCh = make(chan struct{})
-	go c.run()
+	if c.Scheduler != nil {
+		c.scheduled = c.Scheduler.Schedule(c.Interval, c.httpClient.Timeout, c.check)
+	} else {
+		go c.run()
+	}
 }
 
 // Stop is used to stop an HTTP check.
@@ -353,6 +391,9 @@ func (c *CheckHTTP) Stop() {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		if c.scheduled != nil {
+			c.scheduled.Stop()
+		}
 	}
 }
 
@@ -452,10 +493,19 @@ type CheckTCP struct {
 	Timeout  time.Duration
 	Logger   *log.Logger
 
-	dialer   *net.Dialer
-	stop     bool
-	stopCh   chan struct{}
-	stopLock sync.Mutex
+	// Control, if set, is called with the resolved address of the
+	// connection, which is refused if it returns an error.
+	Control func(network, address string, c syscall.RawConn) error
+
+	// Scheduler runs the check, if set. Otherwise it runs on a goroutine of
+	// its own.
+	Scheduler *Scheduler
+
+	dialer    *net.Dialer
+	scheduled *ScheduledCheck
+	stop      bool
+	stopCh    chan struct{}
+	stopLock  sync.Mutex
 }
 
 // Start is used to start a TCP check.
@@ -466,7 +516,7 @@ func (c *CheckTCP) Start() {
 
 	if c.dialer == nil {
 		// Create the socket dialer
-		c.dialer = &net.Dialer{DualStack: true}
+		c.dialer = &net.Dialer{DualStack: true, Control: c.Control}
 
 		// For long (>10s) interval checks the socket timeout is 10s, otherwise
 		// the timeout is the interval. This means that a check *should* return
@@ -480,7 +530,11 @@ func (c *CheckTCP) Start() {
 
 	c.stop = false
 	c.stopCh = make(chan struct{})
-	go c.run()
+	if c.Scheduler != nil {
+		c.scheduled = c.Scheduler.Schedule(c.Interval, c.dialer.Timeout, c.check)
+	} else {
+		go c.run()
+	}
 }
 
 // Stop is used to stop a TCP check.
@@ -490,6 +544,9 @@ func (c *CheckTCP) Stop() {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		if c.scheduled != nil {
+			c.scheduled.Stop()
+		}
 	}
 }
 
@@ -537,7 +594,12 @@ type CheckDocker struct {
 	Logger            *log.Logger
 	Client            *DockerClient
 
-	stop chan struct{}
+	// Scheduler runs the check, if set. Otherwise it runs on a goroutine of
+	// its own.
+	Scheduler *Scheduler
+
+	scheduled *ScheduledCheck
+	stop      chan struct{}
 }
 
 func (c *CheckDocker) Start() {
@@ -556,7 +618,11 @@ func (c *CheckDocker) Start() {
 		}
 	}
 	c.stop = make(chan struct{})
-	go c.run()
+	if c.Scheduler != nil {
+		c.scheduled = c.Scheduler.Schedule(c.Interval, 0, c.check)
+	} else {
+		go c.run()
+	}
 }
 
 func (c *CheckDocker) Stop() {
@@ -564,6 +630,10 @@ func (c *CheckDocker) Stop() {
 		panic("Stop called before start")
 	}
 	close(c.stop)
+	if c.scheduled != nil {
+		c.scheduled.Stop()
+		c.Client.Close()
+	}
 }
 
 func (c *CheckDocker) run() {
@@ -654,10 +724,15 @@ type CheckGRPC struct {
 	TLSClientConfig *tls.Config
 	Logger          *log.Logger
 
-	probe    *GrpcHealthProbe
-	stop     bool
-	stopCh   chan struct{}
-	stopLock sync.Mutex
+	// Scheduler runs the check, if set. Otherwise it runs on a goroutine of
+	// its own.
+	Scheduler *Scheduler
+
+	probe     *GrpcHealthProbe
+	scheduled *ScheduledCheck
+	stop      bool
+	stopCh    chan struct{}
+	stopLock  sync.Mutex
 }
 
 func (c *CheckGRPC) Start() {
@@ -670,7 +745,11 @@ func (c *CheckGRPC) Start() {
 	c.probe = NewGrpcHealthProbe(c.GRPC, timeout, c.TLSClientConfig)
 	c.stop = false
 	c.stopCh = make(chan struct{})
-	go c.run()
+	if c.Scheduler != nil {
+		c.scheduled = c.Scheduler.Schedule(c.Interval, timeout, c.check)
+	} else {
+		go c.run()
+	}
 }
 
 func (c *CheckGRPC) run() {
@@ -705,5 +784,8 @@ func (c *CheckGRPC) Stop() {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		if c.scheduled != nil {
+			c.scheduled.Stop()
+		}
 	}
 }
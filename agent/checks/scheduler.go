@@ -0,0 +1,288 @@
+package checks
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/lib"
+)
+
+// Scheduler runs interval checks from a shared pool of workers, instead of a
+// goroutine and a timer for each check. The checks wait in a heap ordered by
+// when they're next due, and a single goroutine hands them to the workers as
+// they come due. Each check works the same as it does on its own: it first
+// runs after a random stagger of up to its interval, and then its interval
+// after each run finishes, so a check never runs twice at the same time.
+// When all the workers are busy, checks that are due wait for one to be
+// free. A check only holds on to its worker until its timeout, so checks
+// that hang can't take all the workers; it's then left to finish on its own,
+// and scheduled again once it does.
+type Scheduler struct {
+	// checks are the checks waiting to run, in a heap ordered by when
+	// they're due.
+	checks scheduleHeap
+
+	// scheduled and busy count the checks in the scheduler and the workers
+	// running one, for the metrics.
+	scheduled int
+	busy      int
+
+	// wakeCh is used to tell the dispatcher that the earliest deadline may
+	// have changed.
+	wakeCh chan struct{}
+
+	// workCh hands the checks that are due to the workers.
+	workCh chan *ScheduledCheck
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	lock     sync.Mutex
+}
+
+// ScheduledCheck is a check that is run by a Scheduler.
+type ScheduledCheck struct {
+	scheduler *Scheduler
+	interval  time.Duration
+	fn        func()
+
+	// hold is how long a run of the check may keep its worker.
+	hold time.Duration
+
+	// next is when the check is next due.
+	next time.Time
+
+	// index is the position of the check in the heap, or -1 if it's not
+	// in it, because it's running or stopped.
+	index int
+
+	// stopped is set when the check is stopped, so it's not run or
+	// scheduled again.
+	stopped bool
+}
+
+// NewScheduler returns a scheduler that runs up to the given number of
+// checks at the same time. It runs until Stop is called.
+func NewScheduler(workers int) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &Scheduler{
+		wakeCh: make(chan struct{}, 1),
+		workCh: make(chan *ScheduledCheck),
+		stopCh: make(chan struct{}),
+	}
+	go s.dispatch()
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Stop stops the scheduler. Checks that are running finish, but no more are
+// started.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+// Schedule adds a check that calls fn every interval, until the returned
+// check is stopped. A run of the check keeps its worker for up to the given
+// timeout, or the interval if the check has none.
+func (s *Scheduler) Schedule(interval, timeout time.Duration, fn func()) *ScheduledCheck {
+	hold := timeout
+	if hold <= 0 {
+		hold = interval
+	}
+	c := &ScheduledCheck{
+		scheduler: s,
+		interval:  interval,
+		fn:        fn,
+		hold:      hold,
+		next:      time.Now().Add(lib.RandomStagger(interval)),
+		index:     -1,
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	heap.Push(&s.checks, c)
+	s.scheduled++
+	metrics.SetGauge([]string{"agent", "checks", "scheduler", "checks"}, float32(s.scheduled))
+	s.wake()
+	return c
+}
+
+// Stop removes the check from the scheduler. If the check is running, the
+// run finishes, but it's not run again.
+func (c *ScheduledCheck) Stop() {
+	s := c.scheduler
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	if c.index >= 0 {
+		heap.Remove(&s.checks, c.index)
+	}
+	s.scheduled--
+	metrics.SetGauge([]string{"agent", "checks", "scheduler", "checks"}, float32(s.scheduled))
+}
+
+// wake tells the dispatcher to look at the heap again, without blocking. The
+// lock must be held.
+func (s *Scheduler) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands the checks to the workers as they come due.
+func (s *Scheduler) dispatch() {
+	for {
+		var due *ScheduledCheck
+		var wait time.Duration
+		s.lock.Lock()
+		if len(s.checks) > 0 {
+			if wait = time.Until(s.checks[0].next); wait <= 0 {
+				due = heap.Pop(&s.checks).(*ScheduledCheck)
+			}
+		}
+		idle := len(s.checks) == 0
+		s.lock.Unlock()
+
+		// This blocks while all the workers are busy.
+		if due != nil {
+			select {
+			case s.workCh <- due:
+			case <-s.stopCh:
+				return
+			}
+			continue
+		}
+
+		var timer *time.Timer
+		var timerCh <-chan time.Time
+		if !idle {
+			timer = time.NewTimer(wait)
+			timerCh = timer.C
+		}
+		select {
+		case <-timerCh:
+		case <-s.wakeCh:
+		case <-s.stopCh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		select {
+		case <-s.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// work runs the checks it's handed, and schedules them to run again.
+func (s *Scheduler) work() {
+	for {
+		select {
+		case c := <-s.workCh:
+			s.run(c)
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// run runs a check that is due, unless it was stopped while it waited for
+// a worker, and then schedules its next run.
+func (s *Scheduler) run(c *ScheduledCheck) {
+	s.lock.Lock()
+	stopped := c.stopped
+	if !stopped {
+		s.busy++
+		metrics.SetGauge([]string{"agent", "checks", "scheduler", "busy"}, float32(s.busy))
+	}
+	s.lock.Unlock()
+	if stopped {
+		return
+	}
+
+	metrics.MeasureSince([]string{"agent", "checks", "scheduler", "lateness"}, c.next)
+	doneCh := make(chan struct{})
+	go func() {
+		c.fn()
+		close(doneCh)
+	}()
+
+	timer := time.NewTimer(c.hold)
+	defer timer.Stop()
+	select {
+	case <-doneCh:
+		s.release()
+		s.reschedule(c)
+	case <-timer.C:
+		// Give the worker back, and only schedule the check again once
+		// it's done, so it still never runs twice at the same time.
+		metrics.IncrCounter([]string{"agent", "checks", "scheduler", "overrun"}, 1)
+		s.release()
+		go func() {
+			<-doneCh
+			s.reschedule(c)
+		}()
+	}
+}
+
+// release marks a worker as no longer busy.
+func (s *Scheduler) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.busy--
+	metrics.SetGauge([]string{"agent", "checks", "scheduler", "busy"}, float32(s.busy))
+}
+
+// reschedule schedules the next run of a check that's done running, unless
+// it was stopped.
+func (s *Scheduler) reschedule(c *ScheduledCheck) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !c.stopped {
+		c.next = time.Now().Add(c.interval)
+		heap.Push(&s.checks, c)
+		s.wake()
+	}
+}
+
+// scheduleHeap is a min-heap of checks by when they're next due.
+type scheduleHeap []*ScheduledCheck
+
+func (h scheduleHeap) Len() int           { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+
+func (h scheduleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduleHeap) Push(x interface{}) {
+	c := x.(*ScheduledCheck)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *scheduleHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	old[n-1] = nil
+	c.index = -1
+	*h = old[:n-1]
+	return c
+}
@@ -0,0 +1,139 @@
+package checks
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/mock"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/consul/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+	s := NewScheduler(2)
+	defer s.Stop()
+
+	var fast, slow, running, maxRunning int32
+	run := func(count *int32, d time.Duration) func() {
+		return func() {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(d)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(count, 1)
+		}
+	}
+	a := s.Schedule(10*time.Millisecond, time.Second, run(&fast, 0))
+	b := s.Schedule(10*time.Millisecond, time.Second, run(&slow, 50*time.Millisecond))
+	c := s.Schedule(10*time.Millisecond, time.Second, run(new(int32), 50*time.Millisecond))
+
+	retry.Run(t, func(r *retry.R) {
+		f, sl := atomic.LoadInt32(&fast), atomic.LoadInt32(&slow)
+		if f < 5 || sl < 2 {
+			r.Fatalf("checks haven't run enough: %d, %d", f, sl)
+		}
+	})
+	require.True(t, atomic.LoadInt32(&maxRunning) <= 2)
+
+	// Stopped checks don't run again.
+	a.Stop()
+	b.Stop()
+	c.Stop()
+	a.Stop()
+	time.Sleep(60 * time.Millisecond)
+	stopped := atomic.LoadInt32(&fast)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, stopped, atomic.LoadInt32(&fast))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	require.Empty(t, s.checks)
+	require.Equal(t, 0, s.scheduled)
+}
+
+func TestScheduler_Overrun(t *testing.T) {
+	t.Parallel()
+	s := NewScheduler(1)
+	defer s.Stop()
+
+	// A hung check only keeps the one worker until its timeout, and isn't
+	// run again until it's done.
+	var hung, fast int32
+	unblockCh := make(chan struct{})
+	a := s.Schedule(time.Millisecond, 10*time.Millisecond, func() {
+		atomic.AddInt32(&hung, 1)
+		<-unblockCh
+	})
+	defer a.Stop()
+	b := s.Schedule(time.Millisecond, time.Second, func() {
+		atomic.AddInt32(&fast, 1)
+	})
+	defer b.Stop()
+
+	retry.Run(t, func(r *retry.R) {
+		if n := atomic.LoadInt32(&fast); n < 5 {
+			r.Fatalf("fast check only ran %d times", n)
+		}
+	})
+	require.Equal(t, int32(1), atomic.LoadInt32(&hung))
+
+	close(unblockCh)
+	retry.Run(t, func(r *retry.R) {
+		if n := atomic.LoadInt32(&hung); n < 2 {
+			r.Fatalf("hung check only ran %d times", n)
+		}
+	})
+}
+
+func TestScheduler_Checks(t *testing.T) {
+	t.Parallel()
+	s := NewScheduler(4)
+	defer s.Stop()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	notif := mock.NewNotify()
+	tcp := &CheckTCP{
+		Notify:    notif,
+		CheckID:   types.CheckID("tcp"),
+		TCP:       ln.Addr().String(),
+		Interval:  10 * time.Millisecond,
+		Logger:    log.New(ioutil.Discard, uniqueID(), log.LstdFlags),
+		Scheduler: s,
+	}
+	tcp.Start()
+	defer tcp.Stop()
+
+	monitor := &CheckMonitor{
+		Notify:    notif,
+		CheckID:   types.CheckID("monitor"),
+		Script:    "exit 1",
+		Interval:  10 * time.Millisecond,
+		Logger:    log.New(ioutil.Discard, uniqueID(), log.LstdFlags),
+		Scheduler: s,
+	}
+	monitor.Start()
+	defer monitor.Stop()
+
+	retry.Run(t, func(r *retry.R) {
+		if got, want := notif.State("tcp"), api.HealthPassing; got != want {
+			r.Fatalf("got state %q want %q", got, want)
+		}
+		if got, want := notif.State("monitor"), api.HealthWarning; got != want {
+			r.Fatalf("got state %q want %q", got, want)
+		}
+	})
+}
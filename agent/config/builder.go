@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -339,6 +341,21 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		checks = append(checks, b.checkVal(&check))
 	}
 
+	var faultRules []structs.FaultRule
+	for i, rule := range c.FaultInjection {
+		faultRules = append(faultRules, b.faultRuleVal(i, &rule))
+	}
+
+	var hooks []RuntimeHook
+	for i, hook := range c.Hooks {
+		hooks = append(hooks, b.hookVal(i, &hook))
+	}
+
+	var templates []RuntimeTemplate
+	for _, tmpl := range c.Templates {
+		templates = append(templates, b.templateVal(&tmpl))
+	}
+
 	var services []*structs.ServiceDefinition
 	for _, service := range c.Services {
 		services = append(services, b.serviceVal(&service))
@@ -357,6 +374,7 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 	httpsPort := b.portVal("ports.https", c.Ports.HTTPS)
 	serverPort := b.portVal("ports.server", c.Ports.Server)
 	grpcPort := b.portVal("ports.grpc", c.Ports.GRPC)
+	wanGatewayPort := b.portVal("ports.wan_gateway", c.Ports.WANGateway)
 	serfPortLAN := b.portVal("ports.serf_lan", c.Ports.SerfLAN)
 	serfPortWAN := b.portVal("ports.serf_wan", c.Ports.SerfWAN)
 	proxyMinPort := b.portVal("ports.proxy_min_port", c.Ports.ProxyMinPort)
@@ -437,7 +455,7 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 	}
 
 	// derive other bind addresses from the bindAddr
-	rpcBindAddr := b.makeTCPAddr(bindAddr, nil, serverPort)
+	rpcBindAddr := b.makeTCPAddr(b.expandFirstIP("rpc_bind_addr", c.RPCBindAddr), bindAddr, serverPort)
 	serfBindAddrLAN := b.makeTCPAddr(b.expandFirstIP("serf_lan", c.SerfBindAddrLAN), bindAddr, serfPortLAN)
 
 	// Only initialize serf WAN bind address when its enabled
@@ -446,15 +464,21 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		serfBindAddrWAN = b.makeTCPAddr(b.expandFirstIP("serf_wan", c.SerfBindAddrWAN), bindAddr, serfPortWAN)
 	}
 
+	// Only initialize the WAN gateway bind address when its enabled
+	var wanGatewayBindAddr *net.TCPAddr
+	if wanGatewayPort >= 0 {
+		wanGatewayBindAddr = b.makeTCPAddr(bindAddr, nil, wanGatewayPort)
+	}
+
 	// derive other advertise addresses from the advertise address
 	advertiseAddrLAN := b.makeIPAddr(b.expandFirstIP("advertise_addr", c.AdvertiseAddrLAN), advertiseAddr)
 	advertiseAddrWAN := b.makeIPAddr(b.expandFirstIP("advertise_addr_wan", c.AdvertiseAddrWAN), advertiseAddrLAN)
-	rpcAdvertiseAddr := &net.TCPAddr{IP: advertiseAddrLAN.IP, Port: serverPort}
-	serfAdvertiseAddrLAN := &net.TCPAddr{IP: advertiseAddrLAN.IP, Port: serfPortLAN}
+	rpcAdvertiseAddr := b.makeAdvertiseAddr("advertise_addrs.rpc", c.AdvertiseAddrs.RPC, advertiseAddrLAN, serverPort)
+	serfAdvertiseAddrLAN := b.makeAdvertiseAddr("advertise_addrs.serf_lan", c.AdvertiseAddrs.SerfLAN, advertiseAddrLAN, serfPortLAN)
 	// Only initialize serf WAN advertise address when its enabled
 	var serfAdvertiseAddrWAN *net.TCPAddr
 	if serfPortWAN >= 0 {
-		serfAdvertiseAddrWAN = &net.TCPAddr{IP: advertiseAddrWAN.IP, Port: serfPortWAN}
+		serfAdvertiseAddrWAN = b.makeAdvertiseAddr("advertise_addrs.serf_wan", c.AdvertiseAddrs.SerfWAN, advertiseAddrWAN, serfPortWAN)
 	}
 
 	// determine client addresses
@@ -464,6 +488,13 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 	httpsAddrs := b.makeAddrs(b.expandAddrs("addresses.https", c.Addresses.HTTPS), clientAddrs, httpsPort)
 	grpcAddrs := b.makeAddrs(b.expandAddrs("addresses.grpc", c.Addresses.GRPC), clientAddrs, grpcPort)
 
+	// the external authorizer checks all the categories unless told otherwise
+	httpAuthorizerURL := b.stringVal(c.HTTPConfig.AuthorizerURL)
+	httpAuthorizerCategories := c.HTTPConfig.AuthorizerCategories
+	if httpAuthorizerURL != "" && len(httpAuthorizerCategories) == 0 {
+		httpAuthorizerCategories = []string{"acl", "keyring", "raft"}
+	}
+
 	for _, a := range dnsAddrs {
 		if x, ok := a.(*net.TCPAddr); ok {
 			dnsAddrs = append(dnsAddrs, &net.UDPAddr{IP: x.IP, Port: x.Port})
@@ -490,12 +521,31 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		}
 	}
 
-	// Create the default set of tagged addresses.
-	if c.TaggedAddresses == nil {
-		c.TaggedAddresses = make(map[string]string)
+	// Expand the templates in the configured tagged addresses, and add the
+	// default set of tagged addresses.
+	taggedAddresses := make(map[string]string)
+	for key, addr := range c.TaggedAddresses {
+		name := fmt.Sprintf("tagged_addresses.%s", key)
+		switch expanded := b.expandOptionalAddrs(name, &addr); len(expanded) {
+		case 0:
+			// Empty values are kept, and templates that fail to parse were
+			// already reported, but a template that resolves to nothing,
+			// like one for a missing interface, must not publish an empty
+			// address.
+			if expanded != nil {
+				b.err = multierror.Append(b.err, fmt.Errorf("%s: %q must resolve to a single address, not 0", name, addr))
+				continue
+			}
+			taggedAddresses[key] = addr
+		case 1:
+			taggedAddresses[key] = expanded[0]
+		default:
+			b.err = multierror.Append(b.err, fmt.Errorf("%s: %q must resolve to a single address, not %d", name, addr, len(expanded)))
+		}
 	}
-	c.TaggedAddresses["lan"] = advertiseAddrLAN.IP.String()
-	c.TaggedAddresses["wan"] = advertiseAddrWAN.IP.String()
+	taggedAddresses["lan"] = advertiseAddrLAN.IP.String()
+	taggedAddresses["wan"] = advertiseAddrWAN.IP.String()
+	c.TaggedAddresses = taggedAddresses
 
 	// segments
 	var segments []structs.NetworkSegment
@@ -680,12 +730,16 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		ACLDefaultPolicy:       b.stringValWithDefault(c.ACL.DefaultPolicy, b.stringVal(c.ACLDefaultPolicy)),
 		ACLDownPolicy:          b.stringValWithDefault(c.ACL.DownPolicy, b.stringVal(c.ACLDownPolicy)),
 		ACLEnableKeyListPolicy: b.boolValWithDefault(c.ACL.EnableKeyListPolicy, b.boolVal(c.ACLEnableKeyListPolicy)),
+		ACLTokenPersistence:    b.boolVal(c.ACL.TokenPersistence),
 		ACLMasterToken:         b.stringValWithDefault(c.ACL.Tokens.Master, b.stringVal(c.ACLMasterToken)),
 		ACLReplicationToken:    b.stringValWithDefault(c.ACL.Tokens.Replication, b.stringVal(c.ACLReplicationToken)),
 		ACLTokenTTL:            b.durationValWithDefault("acl.token_ttl", c.ACL.TokenTTL, b.durationVal("acl_ttl", c.ACLTTL)),
 		ACLPolicyTTL:           b.durationVal("acl.policy_ttl", c.ACL.PolicyTTL),
 		ACLToken:               b.stringValWithDefault(c.ACL.Tokens.Default, b.stringVal(c.ACLToken)),
 		ACLTokenReplication:    b.boolValWithDefault(c.ACL.TokenReplication, b.boolValWithDefault(c.EnableACLReplication, enableTokenReplication)),
+		ACLTokenRotationGrace:  b.durationVal("acl.token_rotation_grace", c.ACL.TokenRotationGrace),
+		ACLTokenUnusedPeriod:   b.durationVal("acl.token_unused_period", c.ACL.TokenUnusedPeriod),
+		ACLUnusedAction:        b.stringVal(c.ACL.TokenUnusedAction),
 
 		// Autopilot
 		AutopilotCleanupDeadServers:      b.boolVal(c.Autopilot.CleanupDeadServers),
@@ -705,6 +759,7 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		DNSEnableTruncate:     b.boolVal(c.DNS.EnableTruncate),
 		DNSMaxStale:           b.durationVal("dns_config.max_stale", c.DNS.MaxStale),
 		DNSNodeTTL:            b.durationVal("dns_config.node_ttl", c.DNS.NodeTTL),
+		DNSNodeTaggedAddress:  b.stringVal(c.DNS.NodeTaggedAddress),
 		DNSOnlyPassing:        b.boolVal(c.DNS.OnlyPassing),
 		DNSPort:               dnsPort,
 		DNSRecursorTimeout:    b.durationVal("recursor_timeout", c.DNS.RecursorTimeout),
@@ -713,15 +768,20 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		DNSSOA:                soa,
 		DNSUDPAnswerLimit:     b.intVal(c.DNS.UDPAnswerLimit),
 		DNSNodeMetaTXT:        b.boolValWithDefault(c.DNS.NodeMetaTXT, true),
+		DNSUseCache:           b.boolVal(c.DNS.UseCache),
+		DNSCacheMaxAge:        b.durationVal("dns_config.cache_max_age", c.DNS.CacheMaxAge),
 
 		// HTTP
-		HTTPPort:            httpPort,
-		HTTPSPort:           httpsPort,
-		HTTPAddrs:           httpAddrs,
-		HTTPSAddrs:          httpsAddrs,
-		HTTPBlockEndpoints:  c.HTTPConfig.BlockEndpoints,
-		HTTPResponseHeaders: c.HTTPConfig.ResponseHeaders,
-		AllowWriteHTTPFrom:  b.cidrsVal("allow_write_http_from", c.HTTPConfig.AllowWriteHTTPFrom),
+		HTTPPort:                 httpPort,
+		HTTPSPort:                httpsPort,
+		HTTPAddrs:                httpAddrs,
+		HTTPSAddrs:               httpsAddrs,
+		HTTPBlockEndpoints:       c.HTTPConfig.BlockEndpoints,
+		HTTPResponseHeaders:      c.HTTPConfig.ResponseHeaders,
+		HTTPAuthorizerURL:        httpAuthorizerURL,
+		HTTPAuthorizerCategories: httpAuthorizerCategories,
+		HTTPAuthorizerTimeout:    b.durationVal("http_config.authorizer_timeout", c.HTTPConfig.AuthorizerTimeout),
+		AllowWriteHTTPFrom:       b.cidrsVal("allow_write_http_from", c.HTTPConfig.AllowWriteHTTPFrom),
 
 		// Telemetry
 		Telemetry: lib.TelemetryConfig{
@@ -755,13 +815,20 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		AdvertiseAddrWAN:                        advertiseAddrWAN,
 		BindAddr:                                bindAddr,
 		Bootstrap:                               b.boolVal(c.Bootstrap),
+		BootstrapDataDir:                        b.stringVal(c.BootstrapDataDir),
 		BootstrapExpect:                         b.intVal(c.BootstrapExpect),
 		CAFile:                                  b.stringVal(c.CAFile),
 		CAPath:                                  b.stringVal(c.CAPath),
 		CertFile:                                b.stringVal(c.CertFile),
+		ChangeFeedMaxEntries:                    b.intVal(c.ChangeFeedMaxEntries),
+		CheckOutputMaxSize:                      b.intVal(c.CheckOutputMaxSize),
+		CheckOutputSyncLimit:                    b.intVal(c.CheckOutputSyncLimit),
 		CheckUpdateInterval:                     b.durationVal("check_update_interval", c.CheckUpdateInterval),
+		CheckLivenessInterval:                   b.durationVal("check_liveness_interval", c.CheckLivenessInterval),
+		CheckWorkers:                            b.intVal(c.Limits.CheckWorkers),
 		Checks:                                  checks,
 		ClientAddrs:                             clientAddrs,
+		ClockSkewThreshold:                      b.durationVal("clock_skew_threshold", c.ClockSkewThreshold),
 		ConnectEnabled:                          connectEnabled,
 		ConnectCAProvider:                       connectCAProvider,
 		ConnectCAConfig:                         connectCAConfig,
@@ -776,8 +843,13 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		ConnectProxyDefaultScriptCommand:        proxyDefaultScriptCommand,
 		ConnectProxyDefaultConfig:               proxyDefaultConfig,
 		ConnectReplicationToken:                 b.stringVal(c.ACL.Tokens.Replication),
+		CrossDCBreakerCooldown:                  b.durationVal("performance.cross_dc_breaker_cooldown", c.Performance.CrossDCBreakerCooldown),
+		CrossDCBreakerThreshold:                 b.intVal(c.Performance.CrossDCBreakerThreshold),
 		DataDir:                                 b.stringVal(c.DataDir),
 		Datacenter:                              datacenter,
+		DatacenterForwardingAllow:               b.datacentersVal(c.DatacenterForwarding.Allow),
+		DatacenterForwardingDeny:                b.datacentersVal(c.DatacenterForwarding.Deny),
+		DevFixtures:                             b.stringVal(c.DevFixtures),
 		DevMode:                                 b.boolVal(b.Flags.DevMode),
 		DisableAnonymousSignature:               b.boolVal(c.DisableAnonymousSignature),
 		DisableCoordinates:                      b.boolVal(c.DisableCoordinates),
@@ -790,40 +862,82 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		DiscoveryMaxStale:                       b.durationVal("discovery_max_stale", c.DiscoveryMaxStale),
 		EnableAgentTLSForChecks:                 b.boolVal(c.EnableAgentTLSForChecks),
 		EnableDebug:                             b.boolVal(c.EnableDebug),
+		EnableExternalChecks:                    b.boolVal(c.EnableExternalChecks),
 		EnableRemoteScriptChecks:                enableRemoteScriptChecks,
 		EnableLocalScriptChecks:                 enableLocalScriptChecks,
+		EnableResponseSigning:                   b.boolVal(c.EnableResponseSigning),
 		EnableSyslog:                            b.boolVal(c.EnableSyslog),
 		EnableUI:                                b.boolVal(c.UI),
 		EncryptKey:                              b.stringVal(c.EncryptKey),
 		EncryptVerifyIncoming:                   b.boolVal(c.EncryptVerifyIncoming),
 		EncryptVerifyOutgoing:                   b.boolVal(c.EncryptVerifyOutgoing),
+		ExternalCheckAllowedCIDRs:               b.cidrsVal("external_check_allowed_cidrs", c.ExternalCheckAllowedCIDRs),
+		ExternalNodeProbeInterval:               b.durationVal("external_node_probe_interval", c.ExternalNodeProbeInterval),
+		ExternalNodeProbeTimeout:                b.durationVal("external_node_probe_timeout", c.ExternalNodeProbeTimeout),
+		FaultInjection:                          faultRules,
 		GRPCPort:                                grpcPort,
 		GRPCAddrs:                               grpcAddrs,
+		Hooks:                                   hooks,
 		KeyFile:                                 b.stringVal(c.KeyFile),
+		KVReplicationDatacenter:                 strings.ToLower(b.stringVal(c.KVReplication.Datacenter)),
+		KVReplicationPrefix:                     b.stringVal(c.KVReplication.Prefix),
+		LeaderFlapThreshold:                     b.intVal(c.Performance.LeaderFlapThreshold),
+		LeaderFlapWindow:                        b.durationVal("performance.leader_flap_window", c.Performance.LeaderFlapWindow),
 		LeaveDrainTime:                          b.durationVal("performance.leave_drain_time", c.Performance.LeaveDrainTime),
+		LocalityHostRTT:                         b.durationVal("performance.locality_host_rtt", c.Performance.LocalityHostRTT),
+		LocalityRegionRTT:                       b.durationVal("performance.locality_region_rtt", c.Performance.LocalityRegionRTT),
+		LocalityZoneRTT:                         b.durationVal("performance.locality_zone_rtt", c.Performance.LocalityZoneRTT),
+		LeaveMaintenanceTime:                    b.durationVal("leave_maintenance_time", c.LeaveMaintenanceTime),
 		LeaveOnTerm:                             leaveOnTerm,
 		LogLevel:                                b.stringVal(c.LogLevel),
 		LogFile:                                 b.stringVal(c.LogFile),
 		LogRotateBytes:                          b.intVal(c.LogRotateBytes),
 		LogRotateDuration:                       b.durationVal("log_rotate_duration", c.LogRotateDuration),
+		MaxBlockingQueries:                      b.intVal(c.Limits.MaxBlockingQueries),
+		MaxBlockingQueriesPerTable:              c.Limits.MaxBlockingQueriesPerTable,
+		MaxBlockingQueriesPerToken:              b.intVal(c.Limits.MaxBlockingQueriesPerToken),
+		MaxChecksPerNode:                        b.intVal(c.Limits.MaxChecksPerNode),
+		MaxIDLength:                             b.intVal(c.Limits.MaxIDLength),
+		MaxNodes:                                b.intVal(c.Limits.MaxNodes),
+		MaxQueryResults:                         b.intVal(c.Limits.MaxQueryResults),
+		MaxServicesPerNode:                      b.intVal(c.Limits.MaxServicesPerNode),
 		NodeID:                                  types.NodeID(b.stringVal(c.NodeID)),
 		NodeMeta:                                c.NodeMeta,
 		NodeName:                                b.nodeName(c.NodeName),
 		NonVotingServer:                         b.boolVal(c.NonVotingServer),
 		PidFile:                                 b.stringVal(c.PidFile),
 		PrimaryDatacenter:                       primaryDatacenter,
+		QueryCacheSize:                          b.intVal(c.Limits.QueryCacheSize),
+		QuerySourceValidation:                   b.stringVal(c.QuerySourceValidation),
 		RPCAdvertiseAddr:                        rpcAdvertiseAddr,
 		RPCBindAddr:                             rpcBindAddr,
+		RPCConnectionWriteTimeout:               b.durationVal("performance.rpc_connection_write_timeout", c.Performance.RPCConnectionWriteTimeout),
 		RPCHoldTimeout:                          b.durationVal("performance.rpc_hold_timeout", c.Performance.RPCHoldTimeout),
+		RPCKeepAliveInterval:                    b.durationVal("performance.rpc_keep_alive_interval", c.Performance.RPCKeepAliveInterval),
+		RPCLeaderRetryWait:                      b.durationVal("performance.rpc_leader_retry_wait", c.Performance.RPCLeaderRetryWait),
+		RPCMaxStreams:                           b.intVal(c.Performance.RPCMaxStreams),
+		RPCStreamTimeout:                        b.durationVal("performance.rpc_stream_timeout", c.Performance.RPCStreamTimeout),
 		RPCMaxBurst:                             b.intVal(c.Limits.RPCMaxBurst),
 		RPCProtocol:                             b.intVal(c.RPCProtocol),
 		RPCRateLimit:                            rate.Limit(b.float64Val(c.Limits.RPCRate)),
+		RPCServerIPRate:                         rate.Limit(b.float64Val(c.Limits.RPCServerIPRate)),
+		RPCServerMaxBurst:                       b.intVal(c.Limits.RPCServerMaxBurst),
+		RPCServerReadRate:                       rate.Limit(b.float64Val(c.Limits.RPCServerReadRate)),
+		RPCServerTokenRate:                      rate.Limit(b.float64Val(c.Limits.RPCServerTokenRate)),
+		RPCServerWriteRate:                      rate.Limit(b.float64Val(c.Limits.RPCServerWriteRate)),
+		RaftApplyQueueDepth:                     b.intVal(c.Limits.RaftApplyQueueDepth),
+		RaftApplyQueueWait:                      b.durationVal("limits.raft_apply_queue_wait", c.Limits.RaftApplyQueueWait),
 		RaftProtocol:                            b.intVal(c.RaftProtocol),
+		ReconcileMaxBurst:                       b.intVal(c.Limits.ReconcileMaxBurst),
+		ReconcilePanicThreshold:                 b.float64Val(c.Limits.ReconcilePanicThreshold),
+		ReconcileRate:                           rate.Limit(b.float64Val(c.Limits.ReconcileRate)),
 		RaftSnapshotThreshold:                   b.intVal(c.RaftSnapshotThreshold),
 		RaftSnapshotInterval:                    b.durationVal("raft_snapshot_interval", c.RaftSnapshotInterval),
 		ReconnectTimeoutLAN:                     b.durationVal("reconnect_timeout", c.ReconnectTimeoutLAN),
 		ReconnectTimeoutWAN:                     b.durationVal("reconnect_timeout_wan", c.ReconnectTimeoutWAN),
+		RegistrationValidation:                  b.stringVal(c.RegistrationValidation),
 		RejoinAfterLeave:                        b.boolVal(c.RejoinAfterLeave),
+		RepairPersistedState:                    b.boolVal(c.RepairPersistedState),
 		RetryJoinIntervalLAN:                    b.durationVal("retry_interval", c.RetryJoinIntervalLAN),
 		RetryJoinIntervalWAN:                    b.durationVal("retry_interval_wan", c.RetryJoinIntervalWAN),
 		RetryJoinLAN:                            b.expandAllOptionalAddrs("retry_join", c.RetryJoinLAN),
@@ -842,15 +956,20 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		ServerName:                              b.stringVal(c.ServerName),
 		ServerPort:                              serverPort,
 		Services:                                services,
+		ServicesDir:                             b.stringVal(c.ServicesDir),
+		SessionLockDelayMax:                     b.durationVal("session_lock_delay_max", c.SessionLockDelayMax),
+		SessionLockDelayMin:                     b.durationVal("session_lock_delay_min", c.SessionLockDelayMin),
 		SessionTTLMin:                           b.durationVal("session_ttl_min", c.SessionTTLMin),
 		SkipLeaveOnInt:                          skipLeaveOnInt,
 		StartJoinAddrsLAN:                       b.expandAllOptionalAddrs("start_join", c.StartJoinAddrsLAN),
 		StartJoinAddrsWAN:                       b.expandAllOptionalAddrs("start_join_wan", c.StartJoinAddrsWAN),
+		StateMemoryBudgetMB:                     b.intVal(c.Limits.StateMemoryBudgetMB),
 		SyslogFacility:                          b.stringVal(c.SyslogFacility),
 		TLSCipherSuites:                         b.tlsCipherSuites("tls_cipher_suites", c.TLSCipherSuites),
 		TLSMinVersion:                           b.stringVal(c.TLSMinVersion),
 		TLSPreferServerCipherSuites:             b.boolVal(c.TLSPreferServerCipherSuites),
 		TaggedAddresses:                         c.TaggedAddresses,
+		Templates:                               templates,
 		TranslateWANAddrs:                       b.boolVal(c.TranslateWANAddrs),
 		UIDir:                                   b.stringVal(c.UIDir),
 		UnixSocketGroup:                         b.stringVal(c.UnixSocket.Group),
@@ -861,6 +980,10 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		VerifyIncomingRPC:                       b.boolVal(c.VerifyIncomingRPC),
 		VerifyOutgoing:                          verifyOutgoing,
 		VerifyServerHostname:                    verifyServerName,
+		WANFederationLocalGateways:              c.WANFederation.LocalGateways,
+		WANFederationPrimaryGateways:            c.WANFederation.PrimaryGateways,
+		WANGatewayBindAddr:                      wanGatewayBindAddr,
+		WANGatewayPort:                          wanGatewayPort,
 		Watches:                                 c.Watches,
 	}
 
@@ -870,6 +993,12 @@ func (b *Builder) Build() (rt RuntimeConfig, err error) {
 		b.warn(`BootstrapExpect is set to 1; this is the same as Bootstrap mode.`)
 	}
 
+	// Servers join the WAN pool through the gateways of the primary
+	// datacenter, unless told to join somewhere else.
+	if rt.ServerMode && len(rt.RetryJoinWAN) == 0 {
+		rt.RetryJoinWAN = rt.WANFederationPrimaryGateways
+	}
+
 	return rt, nil
 }
 
@@ -909,6 +1038,15 @@ func (b *Builder) Validate(rt RuntimeConfig) error {
 	if ipaddr.IsAny(rt.AdvertiseAddrWAN.IP) {
 		return fmt.Errorf("Advertise WAN address cannot be 0.0.0.0, :: or [::]")
 	}
+	if ipaddr.IsAny(rt.RPCAdvertiseAddr.IP) {
+		return fmt.Errorf("RPC advertise address cannot be 0.0.0.0, :: or [::]")
+	}
+	if ipaddr.IsAny(rt.SerfAdvertiseAddrLAN.IP) {
+		return fmt.Errorf("Serf advertise LAN address cannot be 0.0.0.0, :: or [::]")
+	}
+	if rt.SerfAdvertiseAddrWAN != nil && ipaddr.IsAny(rt.SerfAdvertiseAddrWAN.IP) {
+		return fmt.Errorf("Serf advertise WAN address cannot be 0.0.0.0, :: or [::]")
+	}
 	if err := b.validateSegments(rt); err != nil {
 		return err
 	}
@@ -925,6 +1063,9 @@ func (b *Builder) Validate(rt RuntimeConfig) error {
 	if rt.Bootstrap && !rt.ServerMode {
 		return fmt.Errorf("'bootstrap = true' requires 'server = true'")
 	}
+	if rt.BootstrapDataDir != "" && !rt.ServerMode {
+		return fmt.Errorf("'bootstrap_data_dir' requires 'server = true'")
+	}
 	if rt.BootstrapExpect < 0 {
 		return fmt.Errorf("bootstrap_expect cannot be %d. Must be greater than or equal to zero", rt.BootstrapExpect)
 	}
@@ -946,18 +1087,258 @@ func (b *Builder) Validate(rt RuntimeConfig) error {
 	if rt.ACLDatacenter != "" && !reDatacenter.MatchString(rt.ACLDatacenter) {
 		return fmt.Errorf("acl_datacenter cannot be %q. Please use only [a-z0-9-_].", rt.ACLDatacenter)
 	}
+	for _, dc := range append(rt.DatacenterForwardingAllow, rt.DatacenterForwardingDeny...) {
+		if !reDatacenter.MatchString(dc) {
+			return fmt.Errorf("datacenter_forwarding cannot contain %q. Please use only [a-z0-9-_].", dc)
+		}
+	}
+	for _, dc := range rt.DatacenterForwardingDeny {
+		if dc == rt.Datacenter {
+			return fmt.Errorf("datacenter_forwarding.deny cannot contain the local datacenter")
+		}
+	}
+	if len(rt.DatacenterForwardingAllow)+len(rt.DatacenterForwardingDeny) > 0 && !rt.VerifyIncoming && !rt.VerifyIncomingRPC {
+		return fmt.Errorf("datacenter_forwarding requires verify_incoming or verify_incoming_rpc, so servers from other datacenters can be identified by their certificates")
+	}
+	if rt.KVReplicationDatacenter != "" {
+		if !reDatacenter.MatchString(rt.KVReplicationDatacenter) {
+			return fmt.Errorf("kv_replication.datacenter cannot be %q. Please use only [a-z0-9-_].", rt.KVReplicationDatacenter)
+		}
+		if rt.KVReplicationDatacenter == rt.Datacenter {
+			return fmt.Errorf("kv_replication.datacenter cannot be the local datacenter")
+		}
+		if rt.KVReplicationPrefix == "" {
+			return fmt.Errorf("kv_replication.prefix must be set when kv_replication.datacenter is")
+		}
+	}
+	for _, gateway := range append(rt.WANFederationLocalGateways, rt.WANFederationPrimaryGateways...) {
+		if _, port, err := net.SplitHostPort(gateway); err != nil || port == "" {
+			return fmt.Errorf("wan_federation gateway %q must be a host:port address", gateway)
+		}
+	}
+	if len(rt.WANFederationLocalGateways) > 0 {
+		if n := len(strings.Join(rt.WANFederationLocalGateways, ",")); n > consul.MaxWANGatewaysTagSize {
+			return fmt.Errorf("wan_federation.local_gateways are %d bytes long, they can be at most %d bytes", n, consul.MaxWANGatewaysTagSize)
+		}
+		if !rt.ServerMode {
+			return fmt.Errorf("wan_federation.local_gateways requires 'server = true'")
+		}
+		if (rt.CAFile == "" && rt.CAPath == "") || rt.CertFile == "" || rt.KeyFile == "" {
+			return fmt.Errorf("wan_federation.local_gateways requires ca_file or ca_path, cert_file and key_file")
+		}
+		if (!rt.VerifyIncoming && !rt.VerifyIncomingRPC) || !rt.VerifyOutgoing {
+			return fmt.Errorf("wan_federation.local_gateways requires verify_incoming_rpc and verify_outgoing")
+		}
+	}
+	if len(rt.WANFederationPrimaryGateways) > 0 && len(rt.WANFederationLocalGateways) == 0 {
+		return fmt.Errorf("wan_federation.primary_gateways requires wan_federation.local_gateways")
+	}
 	if rt.EnableUI && rt.UIDir != "" {
 		return fmt.Errorf(
 			"Both the ui and ui-dir flags were specified, please provide only one.\n" +
 				"If trying to use your own web UI resources, use the ui-dir flag.\n" +
 				"If using Consul version 0.7.0 or later, the web UI is included in the binary so use ui to enable it")
 	}
+	if rt.CheckOutputMaxSize < 1 {
+		return fmt.Errorf("check_output_max_size cannot be %d. Must be greater than 0", rt.CheckOutputMaxSize)
+	}
+	if rt.CheckWorkers < 0 {
+		return fmt.Errorf("limits.check_workers cannot be %d. Must be greater than or equal to zero", rt.CheckWorkers)
+	}
+	if rt.CheckOutputSyncLimit < 0 {
+		return fmt.Errorf("check_output_sync_limit cannot be %d. Must be greater than or equal to zero", rt.CheckOutputSyncLimit)
+	}
+	if rt.ChangeFeedMaxEntries < 0 {
+		return fmt.Errorf("change_feed_max_entries cannot be %d. Must be greater than or equal to zero", rt.ChangeFeedMaxEntries)
+	}
+	if rt.ACLTokenUnusedPeriod < 0 {
+		return fmt.Errorf("acl.token_unused_period cannot be %s. Must be greater than or equal to zero", rt.ACLTokenUnusedPeriod)
+	}
+	// Usage is only tracked to within an hour, flushed periodically and
+	// checked hourly, so shorter periods would judge tokens that are in
+	// use as unused.
+	if rt.ACLTokenUnusedPeriod > 0 && rt.ACLTokenUnusedPeriod < 24*time.Hour {
+		return fmt.Errorf("acl.token_unused_period cannot be %s. Must be zero or at least 24h", rt.ACLTokenUnusedPeriod)
+	}
+	switch rt.ACLUnusedAction {
+	case "", "flag", "delete":
+	default:
+		return fmt.Errorf("acl.token_unused_action %q is invalid. Must be one of flag or delete", rt.ACLUnusedAction)
+	}
+	if rt.ClockSkewThreshold < 0 {
+		return fmt.Errorf("clock_skew_threshold cannot be %s. Must be greater than or equal to zero", rt.ClockSkewThreshold)
+	}
+	if rt.DevFixtures != "" && !rt.DevMode {
+		return fmt.Errorf("dev_fixtures is only allowed in dev mode")
+	}
+	if len(rt.FaultInjection) > 0 && !rt.DevMode {
+		return fmt.Errorf("fault_injection is only allowed in dev mode")
+	}
+	for i, rule := range rt.FaultInjection {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("fault_injection[%d] is invalid: %v", i, err)
+		}
+	}
+	for i, hook := range rt.Hooks {
+		if len(hook.Events) == 0 {
+			return fmt.Errorf("hooks[%d] must have at least one event", i)
+		}
+		for _, event := range hook.Events {
+			switch event {
+			case "service_register", "service_deregister", "check_update":
+			default:
+				return fmt.Errorf("hooks[%d] has invalid event %q. Must be one of service_register, service_deregister or check_update", i, event)
+			}
+		}
+		if (len(hook.Args) > 0) == (hook.HTTP != "") {
+			return fmt.Errorf("hooks[%d] must have exactly one of args or http", i)
+		}
+		if hook.Timeout < 0 {
+			return fmt.Errorf("hooks[%d].timeout cannot be %s. Must be greater than or equal to zero", i, hook.Timeout)
+		}
+	}
+	destinations := make(map[string]bool)
+	for i, tmpl := range rt.Templates {
+		if tmpl.Source == "" {
+			return fmt.Errorf("templates[%d] must have a source", i)
+		}
+		if tmpl.Destination == "" {
+			return fmt.Errorf("templates[%d] must have a destination", i)
+		}
+		if destinations[tmpl.Destination] {
+			return fmt.Errorf("templates[%d] has duplicate destination %q", i, tmpl.Destination)
+		}
+		destinations[tmpl.Destination] = true
+	}
+	if rt.HTTPAuthorizerURL != "" {
+		u, err := url.Parse(rt.HTTPAuthorizerURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("http_config.authorizer_url %q is invalid. Must be an http or https URL", rt.HTTPAuthorizerURL)
+		}
+	}
+	for _, category := range rt.HTTPAuthorizerCategories {
+		switch category {
+		case "acl", "keyring", "raft":
+		default:
+			return fmt.Errorf("http_config.authorizer_categories has invalid category %q. Must be one of acl, keyring or raft", category)
+		}
+	}
+	if rt.HTTPAuthorizerTimeout <= 0 {
+		return fmt.Errorf("http_config.authorizer_timeout cannot be %s. Must be greater than zero", rt.HTTPAuthorizerTimeout)
+	}
+	if rt.LeaveMaintenanceTime < 0 {
+		return fmt.Errorf("leave_maintenance_time cannot be %s. Must be greater than or equal to zero", rt.LeaveMaintenanceTime)
+	}
 	if rt.DNSUDPAnswerLimit < 0 {
 		return fmt.Errorf("dns_config.udp_answer_limit cannot be %d. Must be greater than or equal to zero", rt.DNSUDPAnswerLimit)
 	}
 	if rt.DNSARecordLimit < 0 {
 		return fmt.Errorf("dns_config.a_record_limit cannot be %d. Must be greater than or equal to zero", rt.DNSARecordLimit)
 	}
+	if rt.DNSNodeTTL < 0 {
+		return fmt.Errorf("dns_config.node_ttl cannot be %s. Must be greater than or equal to zero", rt.DNSNodeTTL)
+	}
+	if rt.DNSCacheMaxAge < 0 {
+		return fmt.Errorf("dns_config.cache_max_age cannot be %s. Must be greater than or equal to zero", rt.DNSCacheMaxAge)
+	}
+	for k, v := range rt.DNSServiceTTL {
+		if i := strings.Index(k, "*"); i >= 0 && i != len(k)-1 {
+			return fmt.Errorf("dns_config.service_ttl[%q] is invalid. The wildcard \"*\" is only allowed as the last character", k)
+		}
+		if v < 0 {
+			return fmt.Errorf("dns_config.service_ttl[%q] cannot be %s. Must be greater than or equal to zero", k, v)
+		}
+	}
+	if rt.MaxBlockingQueries < 0 {
+		return fmt.Errorf("limits.max_blocking_queries cannot be %d. Must be greater than or equal to zero", rt.MaxBlockingQueries)
+	}
+	for table, limit := range rt.MaxBlockingQueriesPerTable {
+		if limit < 0 {
+			return fmt.Errorf("limits.max_blocking_queries_per_table[%q] cannot be %d. Must be greater than or equal to zero", table, limit)
+		}
+	}
+	if rt.MaxBlockingQueriesPerToken < 0 {
+		return fmt.Errorf("limits.max_blocking_queries_per_token cannot be %d. Must be greater than or equal to zero", rt.MaxBlockingQueriesPerToken)
+	}
+	if rt.MaxChecksPerNode < 0 {
+		return fmt.Errorf("limits.max_checks_per_node cannot be %d. Must be greater than or equal to zero", rt.MaxChecksPerNode)
+	}
+	if rt.MaxIDLength < 0 {
+		return fmt.Errorf("limits.max_id_length cannot be %d. Must be greater than or equal to zero", rt.MaxIDLength)
+	}
+	switch rt.RegistrationValidation {
+	case "compat", "strict":
+	default:
+		return fmt.Errorf("registration_validation must be \"compat\" or \"strict\", not %q", rt.RegistrationValidation)
+	}
+	if rt.MaxNodes < 0 {
+		return fmt.Errorf("limits.max_nodes cannot be %d. Must be greater than or equal to zero", rt.MaxNodes)
+	}
+	if rt.MaxQueryResults < 0 {
+		return fmt.Errorf("limits.max_query_results cannot be %d. Must be greater than or equal to zero", rt.MaxQueryResults)
+	}
+	if rt.MaxServicesPerNode < 0 {
+		return fmt.Errorf("limits.max_services_per_node cannot be %d. Must be greater than or equal to zero", rt.MaxServicesPerNode)
+	}
+	if rt.QueryCacheSize < 0 {
+		return fmt.Errorf("limits.query_cache_size cannot be %d. Must be greater than or equal to zero", rt.QueryCacheSize)
+	}
+	switch rt.QuerySourceValidation {
+	case "trust", "override", "reject":
+	default:
+		return fmt.Errorf("query_source_validation must be \"trust\", \"override\" or \"reject\", not %q", rt.QuerySourceValidation)
+	}
+	if rt.StateMemoryBudgetMB < 0 {
+		return fmt.Errorf("limits.state_memory_budget_mb cannot be %d. Must be greater than or equal to zero", rt.StateMemoryBudgetMB)
+	}
+	if rt.CrossDCBreakerThreshold < 0 {
+		return fmt.Errorf("performance.cross_dc_breaker_threshold cannot be %d. Must be greater than or equal to zero", rt.CrossDCBreakerThreshold)
+	}
+	if rt.CrossDCBreakerThreshold > 0 && rt.CrossDCBreakerCooldown <= 0 {
+		return fmt.Errorf("performance.cross_dc_breaker_cooldown cannot be %s. Must be greater than zero", rt.CrossDCBreakerCooldown)
+	}
+	if rt.ExternalNodeProbeInterval <= 0 {
+		return fmt.Errorf("external_node_probe_interval cannot be %s. Must be greater than zero", rt.ExternalNodeProbeInterval)
+	}
+	if rt.ExternalNodeProbeTimeout <= 0 {
+		return fmt.Errorf("external_node_probe_timeout cannot be %s. Must be greater than zero", rt.ExternalNodeProbeTimeout)
+	}
+	if rt.SessionLockDelayMin < 0 {
+		return fmt.Errorf("session_lock_delay_min cannot be %s. Must be greater than or equal to zero", rt.SessionLockDelayMin)
+	}
+	if rt.SessionLockDelayMax < rt.SessionLockDelayMin || rt.SessionLockDelayMax > structs.MaxLockDelay {
+		return fmt.Errorf("session_lock_delay_max cannot be %s. Must be between session_lock_delay_min and %s", rt.SessionLockDelayMax, structs.MaxLockDelay)
+	}
+	if rt.LeaderFlapThreshold < 0 {
+		return fmt.Errorf("performance.leader_flap_threshold cannot be %d. Must be greater than or equal to zero", rt.LeaderFlapThreshold)
+	}
+	if rt.LeaderFlapThreshold > 0 && rt.LeaderFlapWindow <= 0 {
+		return fmt.Errorf("performance.leader_flap_window cannot be %s. Must be greater than zero", rt.LeaderFlapWindow)
+	}
+	if rt.LocalityHostRTT <= 0 {
+		return fmt.Errorf("performance.locality_host_rtt cannot be %s. Must be greater than zero", rt.LocalityHostRTT)
+	}
+	if rt.LocalityZoneRTT < rt.LocalityHostRTT {
+		return fmt.Errorf("performance.locality_zone_rtt cannot be %s. Must be greater than or equal to performance.locality_host_rtt", rt.LocalityZoneRTT)
+	}
+	if rt.LocalityRegionRTT < rt.LocalityZoneRTT {
+		return fmt.Errorf("performance.locality_region_rtt cannot be %s. Must be greater than or equal to performance.locality_zone_rtt", rt.LocalityRegionRTT)
+	}
+	if rt.RaftApplyQueueDepth < 0 {
+		return fmt.Errorf("limits.raft_apply_queue_depth cannot be %d. Must be greater than or equal to zero", rt.RaftApplyQueueDepth)
+	}
+	if rt.RaftApplyQueueWait < 0 {
+		return fmt.Errorf("limits.raft_apply_queue_wait cannot be %s. Must be greater than or equal to zero", rt.RaftApplyQueueWait)
+	}
+	if rt.RPCServerMaxBurst < 1 {
+		return fmt.Errorf("limits.rpc_server_max_burst cannot be %d. Must be greater than zero", rt.RPCServerMaxBurst)
+	}
+	if rt.ReconcileMaxBurst < 1 {
+		return fmt.Errorf("limits.reconcile_max_burst cannot be %d. Must be greater than zero", rt.ReconcileMaxBurst)
+	}
+	if rt.ReconcilePanicThreshold < 0 || rt.ReconcilePanicThreshold > 1 {
+		return fmt.Errorf("limits.reconcile_panic_threshold cannot be %v. Must be between 0 and 1", rt.ReconcilePanicThreshold)
+	}
 	if err := structs.ValidateMetadata(rt.NodeMeta, false); err != nil {
 		return fmt.Errorf("node_meta invalid: %v", err)
 	}
@@ -1019,6 +1400,27 @@ func (b *Builder) Validate(rt RuntimeConfig) error {
 			return err
 		}
 	}
+	// Validate the WAN gateway address only when its set
+	if rt.WANGatewayBindAddr != nil {
+		if err := addrUnique(inuse, "WAN Gateway", rt.WANGatewayBindAddr); err != nil {
+			return err
+		}
+	}
+
+	// The cluster listeners can be bound to different interfaces but
+	// they cannot share an address.
+	bound := map[string]string{}
+	if err := addrUnique(bound, "RPC", rt.RPCBindAddr); err != nil {
+		return err
+	}
+	if err := addrUnique(bound, "Serf LAN", rt.SerfBindAddrLAN); err != nil {
+		return err
+	}
+	if rt.SerfBindAddrWAN != nil {
+		if err := addrUnique(bound, "Serf WAN", rt.SerfBindAddrWAN); err != nil {
+			return err
+		}
+	}
 	if b.err != nil {
 		return b.err
 	}
@@ -1071,6 +1473,10 @@ func (b *Builder) Validate(rt RuntimeConfig) error {
 		b.warn("bootstrap_expect > 0: expecting %d servers", rt.BootstrapExpect)
 	}
 
+	if rt.DisableCoordinates && rt.ClockSkewThreshold > 0 {
+		b.warn("disable_coordinates is set, so the leader can't measure the clock skew of this agent")
+	}
+
 	return nil
 }
 
@@ -1150,6 +1556,44 @@ func (b *Builder) checkVal(v *CheckDefinition) *structs.CheckDefinition {
 	}
 }
 
+func (b *Builder) faultRuleVal(i int, v *FaultRule) structs.FaultRule {
+	return structs.FaultRule{
+		Method:    b.stringVal(v.Method),
+		Latency:   b.durationVal(fmt.Sprintf("fault_injection[%d].latency", i), v.Latency),
+		ErrorRate: b.float64Val(v.ErrorRate),
+		Error:     b.stringVal(v.Error),
+	}
+}
+
+func (b *Builder) hookVal(i int, v *Hook) RuntimeHook {
+	hook := RuntimeHook{
+		Events:        v.Events,
+		Args:          v.Args,
+		HTTP:          b.stringVal(v.HTTP),
+		Method:        b.stringVal(v.Method),
+		Header:        v.Header,
+		Timeout:       b.durationVal(fmt.Sprintf("hooks[%d].timeout", i), v.Timeout),
+		TLSSkipVerify: b.boolVal(v.TLSSkipVerify),
+	}
+	if hook.HTTP != "" {
+		if hook.Method == "" {
+			hook.Method = "POST"
+		}
+		if hook.Timeout == 0 {
+			hook.Timeout = 10 * time.Second
+		}
+	}
+	return hook
+}
+
+func (b *Builder) templateVal(v *Template) RuntimeTemplate {
+	return RuntimeTemplate{
+		Source:      b.stringVal(v.Source),
+		Destination: b.stringVal(v.Destination),
+		Args:        v.Args,
+	}
+}
+
 func (b *Builder) serviceVal(v *ServiceDefinition) *structs.ServiceDefinition {
 	if v == nil {
 		return nil
@@ -1356,6 +1800,18 @@ func (b *Builder) float64Val(v *float64) float64 {
 	return *v
 }
 
+func (b *Builder) datacentersVal(v []string) []string {
+	if v == nil {
+		return nil
+	}
+
+	dcs := make([]string, 0, len(v))
+	for _, dc := range v {
+		dcs = append(dcs, strings.ToLower(dc))
+	}
+	return dcs
+}
+
 func (b *Builder) cidrsVal(name string, v []string) (nets []*net.IPNet) {
 	if v == nil {
 		return
@@ -1554,6 +2010,40 @@ func (b *Builder) makeIPAddr(pri *net.IPAddr, sec *net.IPAddr) *net.IPAddr {
 	return sec
 }
 
+// makeAdvertiseAddr creates the address a cluster listener is advertised
+// on. s is a go-sockaddr template for an ip address with an optional port,
+// which overrides the given address and port. This allows a listener
+// behind a NAT to be advertised on an address and port that differ from
+// the ones it is bound to.
+func (b *Builder) makeAdvertiseAddr(name string, s *string, addr *net.IPAddr, port int) *net.TCPAddr {
+	def := &net.TCPAddr{IP: addr.IP, Port: port}
+	if s == nil || *s == "" {
+		return def
+	}
+
+	x, err := template.Parse(*s)
+	if err != nil {
+		b.err = multierror.Append(b.err, fmt.Errorf("%s: error parsing %q: %s", name, *s, err))
+		return def
+	}
+
+	host := x
+	if h, p, err := net.SplitHostPort(x); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 65535 {
+			b.err = multierror.Append(b.err, fmt.Errorf("%s: invalid port: %s", name, p))
+			return def
+		}
+		host, port = h, n
+	}
+	ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+	if ip == nil {
+		b.err = multierror.Append(b.err, fmt.Errorf("%s: invalid ip address: %s", name, host))
+		return def
+	}
+	return &net.TCPAddr{IP: ip, Port: port}
+}
+
 func (b *Builder) makeTCPAddr(pri *net.IPAddr, sec net.Addr, port int) *net.TCPAddr {
 	if pri == nil && reflect.ValueOf(sec).IsNil() || port <= 0 {
 		return nil
@@ -79,10 +79,13 @@ func Parse(data string, format string) (c Config, err error) {
 	// todo(fs): but this approach works for now.
 	m := patchSliceOfMaps(raw, []string{
 		"checks",
+		"fault_injection",
+		"hooks",
 		"segments",
 		"service.checks",
 		"services",
 		"services.checks",
+		"templates",
 		"watches",
 		"service.connect.proxy.config.upstreams", // Deprecated
 		"services.connect.proxy.config.upstreams", // Deprecated
@@ -173,23 +176,32 @@ type Config struct {
 	Addresses                        Addresses                `json:"addresses,omitempty" hcl:"addresses" mapstructure:"addresses"`
 	AdvertiseAddrLAN                 *string                  `json:"advertise_addr,omitempty" hcl:"advertise_addr" mapstructure:"advertise_addr"`
 	AdvertiseAddrWAN                 *string                  `json:"advertise_addr_wan,omitempty" hcl:"advertise_addr_wan" mapstructure:"advertise_addr_wan"`
+	AdvertiseAddrs                   AdvertiseAddrsConfig     `json:"advertise_addrs,omitempty" hcl:"advertise_addrs" mapstructure:"advertise_addrs"`
 	Autopilot                        Autopilot                `json:"autopilot,omitempty" hcl:"autopilot" mapstructure:"autopilot"`
 	BindAddr                         *string                  `json:"bind_addr,omitempty" hcl:"bind_addr" mapstructure:"bind_addr"`
 	Bootstrap                        *bool                    `json:"bootstrap,omitempty" hcl:"bootstrap" mapstructure:"bootstrap"`
+	BootstrapDataDir                 *string                  `json:"bootstrap_data_dir,omitempty" hcl:"bootstrap_data_dir" mapstructure:"bootstrap_data_dir"`
 	BootstrapExpect                  *int                     `json:"bootstrap_expect,omitempty" hcl:"bootstrap_expect" mapstructure:"bootstrap_expect"`
 	CAFile                           *string                  `json:"ca_file,omitempty" hcl:"ca_file" mapstructure:"ca_file"`
 	CAPath                           *string                  `json:"ca_path,omitempty" hcl:"ca_path" mapstructure:"ca_path"`
 	CertFile                         *string                  `json:"cert_file,omitempty" hcl:"cert_file" mapstructure:"cert_file"`
+	ChangeFeedMaxEntries             *int                     `json:"change_feed_max_entries,omitempty" hcl:"change_feed_max_entries" mapstructure:"change_feed_max_entries"`
 	Check                            *CheckDefinition         `json:"check,omitempty" hcl:"check" mapstructure:"check"` // needs to be a pointer to avoid partial merges
+	CheckLivenessInterval            *string                  `json:"check_liveness_interval,omitempty" hcl:"check_liveness_interval" mapstructure:"check_liveness_interval"`
+	CheckOutputMaxSize               *int                     `json:"check_output_max_size,omitempty" hcl:"check_output_max_size" mapstructure:"check_output_max_size"`
+	CheckOutputSyncLimit             *int                     `json:"check_output_sync_limit,omitempty" hcl:"check_output_sync_limit" mapstructure:"check_output_sync_limit"`
 	CheckUpdateInterval              *string                  `json:"check_update_interval,omitempty" hcl:"check_update_interval" mapstructure:"check_update_interval"`
 	Checks                           []CheckDefinition        `json:"checks,omitempty" hcl:"checks" mapstructure:"checks"`
 	ClientAddr                       *string                  `json:"client_addr,omitempty" hcl:"client_addr" mapstructure:"client_addr"`
+	ClockSkewThreshold               *string                  `json:"clock_skew_threshold,omitempty" hcl:"clock_skew_threshold" mapstructure:"clock_skew_threshold"`
 	Connect                          Connect                  `json:"connect,omitempty" hcl:"connect" mapstructure:"connect"`
 	DNS                              DNS                      `json:"dns_config,omitempty" hcl:"dns_config" mapstructure:"dns_config"`
 	DNSDomain                        *string                  `json:"domain,omitempty" hcl:"domain" mapstructure:"domain"`
 	DNSRecursors                     []string                 `json:"recursors,omitempty" hcl:"recursors" mapstructure:"recursors"`
 	DataDir                          *string                  `json:"data_dir,omitempty" hcl:"data_dir" mapstructure:"data_dir"`
 	Datacenter                       *string                  `json:"datacenter,omitempty" hcl:"datacenter" mapstructure:"datacenter"`
+	DatacenterForwarding             DatacenterForwarding     `json:"datacenter_forwarding,omitempty" hcl:"datacenter_forwarding" mapstructure:"datacenter_forwarding"`
+	DevFixtures                      *string                  `json:"dev_fixtures,omitempty" hcl:"dev_fixtures" mapstructure:"dev_fixtures"`
 	DisableAnonymousSignature        *bool                    `json:"disable_anonymous_signature,omitempty" hcl:"disable_anonymous_signature" mapstructure:"disable_anonymous_signature"`
 	DisableCoordinates               *bool                    `json:"disable_coordinates,omitempty" hcl:"disable_coordinates" mapstructure:"disable_coordinates"`
 	DisableHostNodeID                *bool                    `json:"disable_host_node_id,omitempty" hcl:"disable_host_node_id" mapstructure:"disable_host_node_id"`
@@ -202,16 +214,25 @@ type Config struct {
 	EnableACLReplication             *bool                    `json:"enable_acl_replication,omitempty" hcl:"enable_acl_replication" mapstructure:"enable_acl_replication"`
 	EnableAgentTLSForChecks          *bool                    `json:"enable_agent_tls_for_checks,omitempty" hcl:"enable_agent_tls_for_checks" mapstructure:"enable_agent_tls_for_checks"`
 	EnableDebug                      *bool                    `json:"enable_debug,omitempty" hcl:"enable_debug" mapstructure:"enable_debug"`
+	EnableExternalChecks             *bool                    `json:"enable_external_checks,omitempty" hcl:"enable_external_checks" mapstructure:"enable_external_checks"`
 	EnableScriptChecks               *bool                    `json:"enable_script_checks,omitempty" hcl:"enable_script_checks" mapstructure:"enable_script_checks"`
 	EnableLocalScriptChecks          *bool                    `json:"enable_local_script_checks,omitempty" hcl:"enable_local_script_checks" mapstructure:"enable_local_script_checks"`
+	EnableResponseSigning            *bool                    `json:"enable_response_signing,omitempty" hcl:"enable_response_signing" mapstructure:"enable_response_signing"`
 	EnableSyslog                     *bool                    `json:"enable_syslog,omitempty" hcl:"enable_syslog" mapstructure:"enable_syslog"`
 	EncryptKey                       *string                  `json:"encrypt,omitempty" hcl:"encrypt" mapstructure:"encrypt"`
 	EncryptVerifyIncoming            *bool                    `json:"encrypt_verify_incoming,omitempty" hcl:"encrypt_verify_incoming" mapstructure:"encrypt_verify_incoming"`
 	EncryptVerifyOutgoing            *bool                    `json:"encrypt_verify_outgoing,omitempty" hcl:"encrypt_verify_outgoing" mapstructure:"encrypt_verify_outgoing"`
+	ExternalCheckAllowedCIDRs        []string                 `json:"external_check_allowed_cidrs,omitempty" hcl:"external_check_allowed_cidrs" mapstructure:"external_check_allowed_cidrs"`
+	ExternalNodeProbeInterval        *string                  `json:"external_node_probe_interval,omitempty" hcl:"external_node_probe_interval" mapstructure:"external_node_probe_interval"`
+	ExternalNodeProbeTimeout         *string                  `json:"external_node_probe_timeout,omitempty" hcl:"external_node_probe_timeout" mapstructure:"external_node_probe_timeout"`
+	FaultInjection                   []FaultRule              `json:"fault_injection,omitempty" hcl:"fault_injection" mapstructure:"fault_injection"`
 	GossipLAN                        GossipLANConfig          `json:"gossip_lan,omitempty" hcl:"gossip_lan" mapstructure:"gossip_lan"`
 	GossipWAN                        GossipWANConfig          `json:"gossip_wan,omitempty" hcl:"gossip_wan" mapstructure:"gossip_wan"`
+	Hooks                            []Hook                   `json:"hooks,omitempty" hcl:"hooks" mapstructure:"hooks"`
 	HTTPConfig                       HTTPConfig               `json:"http_config,omitempty" hcl:"http_config" mapstructure:"http_config"`
 	KeyFile                          *string                  `json:"key_file,omitempty" hcl:"key_file" mapstructure:"key_file"`
+	KVReplication                    KVReplication            `json:"kv_replication,omitempty" hcl:"kv_replication" mapstructure:"kv_replication"`
+	LeaveMaintenanceTime             *string                  `json:"leave_maintenance_time,omitempty" hcl:"leave_maintenance_time" mapstructure:"leave_maintenance_time"`
 	LeaveOnTerm                      *bool                    `json:"leave_on_terminate,omitempty" hcl:"leave_on_terminate" mapstructure:"leave_on_terminate"`
 	Limits                           Limits                   `json:"limits,omitempty" hcl:"limits" mapstructure:"limits"`
 	LogLevel                         *string                  `json:"log_level,omitempty" hcl:"log_level" mapstructure:"log_level"`
@@ -226,13 +247,17 @@ type Config struct {
 	PidFile                          *string                  `json:"pid_file,omitempty" hcl:"pid_file" mapstructure:"pid_file"`
 	Ports                            Ports                    `json:"ports,omitempty" hcl:"ports" mapstructure:"ports"`
 	PrimaryDatacenter                *string                  `json:"primary_datacenter,omitempty" hcl:"primary_datacenter" mapstructure:"primary_datacenter"`
+	QuerySourceValidation            *string                  `json:"query_source_validation,omitempty" hcl:"query_source_validation" mapstructure:"query_source_validation"`
+	RPCBindAddr                      *string                  `json:"rpc_bind_addr,omitempty" hcl:"rpc_bind_addr" mapstructure:"rpc_bind_addr"`
 	RPCProtocol                      *int                     `json:"protocol,omitempty" hcl:"protocol" mapstructure:"protocol"`
 	RaftProtocol                     *int                     `json:"raft_protocol,omitempty" hcl:"raft_protocol" mapstructure:"raft_protocol"`
 	RaftSnapshotThreshold            *int                     `json:"raft_snapshot_threshold,omitempty" hcl:"raft_snapshot_threshold" mapstructure:"raft_snapshot_threshold"`
 	RaftSnapshotInterval             *string                  `json:"raft_snapshot_interval,omitempty" hcl:"raft_snapshot_interval" mapstructure:"raft_snapshot_interval"`
 	ReconnectTimeoutLAN              *string                  `json:"reconnect_timeout,omitempty" hcl:"reconnect_timeout" mapstructure:"reconnect_timeout"`
 	ReconnectTimeoutWAN              *string                  `json:"reconnect_timeout_wan,omitempty" hcl:"reconnect_timeout_wan" mapstructure:"reconnect_timeout_wan"`
+	RegistrationValidation           *string                  `json:"registration_validation,omitempty" hcl:"registration_validation" mapstructure:"registration_validation"`
 	RejoinAfterLeave                 *bool                    `json:"rejoin_after_leave,omitempty" hcl:"rejoin_after_leave" mapstructure:"rejoin_after_leave"`
+	RepairPersistedState             *bool                    `json:"repair_persisted_state,omitempty" hcl:"repair_persisted_state" mapstructure:"repair_persisted_state"`
 	RetryJoinIntervalLAN             *string                  `json:"retry_interval,omitempty" hcl:"retry_interval" mapstructure:"retry_interval"`
 	RetryJoinIntervalWAN             *string                  `json:"retry_interval_wan,omitempty" hcl:"retry_interval_wan" mapstructure:"retry_interval_wan"`
 	RetryJoinLAN                     []string                 `json:"retry_join,omitempty" hcl:"retry_join" mapstructure:"retry_join"`
@@ -247,6 +272,9 @@ type Config struct {
 	ServerName                       *string                  `json:"server_name,omitempty" hcl:"server_name" mapstructure:"server_name"`
 	Service                          *ServiceDefinition       `json:"service,omitempty" hcl:"service" mapstructure:"service"`
 	Services                         []ServiceDefinition      `json:"services,omitempty" hcl:"services" mapstructure:"services"`
+	ServicesDir                      *string                  `json:"services_dir,omitempty" hcl:"services_dir" mapstructure:"services_dir"`
+	SessionLockDelayMax              *string                  `json:"session_lock_delay_max,omitempty" hcl:"session_lock_delay_max" mapstructure:"session_lock_delay_max"`
+	SessionLockDelayMin              *string                  `json:"session_lock_delay_min,omitempty" hcl:"session_lock_delay_min" mapstructure:"session_lock_delay_min"`
 	SessionTTLMin                    *string                  `json:"session_ttl_min,omitempty" hcl:"session_ttl_min" mapstructure:"session_ttl_min"`
 	SkipLeaveOnInt                   *bool                    `json:"skip_leave_on_interrupt,omitempty" hcl:"skip_leave_on_interrupt" mapstructure:"skip_leave_on_interrupt"`
 	StartJoinAddrsLAN                []string                 `json:"start_join,omitempty" hcl:"start_join" mapstructure:"start_join"`
@@ -257,6 +285,7 @@ type Config struct {
 	TLSPreferServerCipherSuites      *bool                    `json:"tls_prefer_server_cipher_suites,omitempty" hcl:"tls_prefer_server_cipher_suites" mapstructure:"tls_prefer_server_cipher_suites"`
 	TaggedAddresses                  map[string]string        `json:"tagged_addresses,omitempty" hcl:"tagged_addresses" mapstructure:"tagged_addresses"`
 	Telemetry                        Telemetry                `json:"telemetry,omitempty" hcl:"telemetry" mapstructure:"telemetry"`
+	Templates                        []Template               `json:"templates,omitempty" hcl:"templates" mapstructure:"templates"`
 	TranslateWANAddrs                *bool                    `json:"translate_wan_addrs,omitempty" hcl:"translate_wan_addrs" mapstructure:"translate_wan_addrs"`
 	UI                               *bool                    `json:"ui,omitempty" hcl:"ui" mapstructure:"ui"`
 	UIDir                            *string                  `json:"ui_dir,omitempty" hcl:"ui_dir" mapstructure:"ui_dir"`
@@ -266,6 +295,7 @@ type Config struct {
 	VerifyIncomingRPC                *bool                    `json:"verify_incoming_rpc,omitempty" hcl:"verify_incoming_rpc" mapstructure:"verify_incoming_rpc"`
 	VerifyOutgoing                   *bool                    `json:"verify_outgoing,omitempty" hcl:"verify_outgoing" mapstructure:"verify_outgoing"`
 	VerifyServerHostname             *bool                    `json:"verify_server_hostname,omitempty" hcl:"verify_server_hostname" mapstructure:"verify_server_hostname"`
+	WANFederation                    WANFederation            `json:"wan_federation,omitempty" hcl:"wan_federation" mapstructure:"wan_federation"`
 	Watches                          []map[string]interface{} `json:"watches,omitempty" hcl:"watches" mapstructure:"watches"`
 
 	// This isn't used by Consul but we've documented a feature where users
@@ -399,6 +429,35 @@ type CheckDefinition struct {
 	DeregisterCriticalServiceAfter *string             `json:"deregister_critical_service_after,omitempty" hcl:"deregister_critical_service_after" mapstructure:"deregister_critical_service_after"`
 }
 
+// Hook is a script or HTTP endpoint which is called when the agent's local
+// services or checks change.
+type Hook struct {
+	Events        []string            `json:"events,omitempty" hcl:"events" mapstructure:"events"`
+	Args          []string            `json:"args,omitempty" hcl:"args" mapstructure:"args"`
+	HTTP          *string             `json:"http,omitempty" hcl:"http" mapstructure:"http"`
+	Header        map[string][]string `json:"header,omitempty" hcl:"header" mapstructure:"header"`
+	Method        *string             `json:"method,omitempty" hcl:"method" mapstructure:"method"`
+	Timeout       *string             `json:"timeout,omitempty" hcl:"timeout" mapstructure:"timeout"`
+	TLSSkipVerify *bool               `json:"tls_skip_verify,omitempty" hcl:"tls_skip_verify" mapstructure:"tls_skip_verify"`
+}
+
+// FaultRule injects latency and errors into the RPCs and Raft applies of a
+// dev mode server.
+type FaultRule struct {
+	Method    *string  `json:"method,omitempty" hcl:"method" mapstructure:"method"`
+	Latency   *string  `json:"latency,omitempty" hcl:"latency" mapstructure:"latency"`
+	ErrorRate *float64 `json:"error_rate,omitempty" hcl:"error_rate" mapstructure:"error_rate"`
+	Error     *string  `json:"error,omitempty" hcl:"error" mapstructure:"error"`
+}
+
+// Template is a file which the agent renders from a template using service
+// discovery results and KV data, and keeps up to date as they change.
+type Template struct {
+	Source      *string  `json:"source,omitempty" hcl:"source" mapstructure:"source"`
+	Destination *string  `json:"destination,omitempty" hcl:"destination" mapstructure:"destination"`
+	Args        []string `json:"args,omitempty" hcl:"args" mapstructure:"args"`
+}
+
 // ServiceConnect is the connect block within a service registration
 type ServiceConnect struct {
 	// Native is true when this service can natively understand Connect.
@@ -542,6 +601,11 @@ type SOA struct {
 	Minttl  *uint32 `json:"min_ttl,omitempty" hcl:"min_ttl" mapstructure:"min_ttl"`
 }
 
+type DatacenterForwarding struct {
+	Allow []string `json:"allow,omitempty" hcl:"allow" mapstructure:"allow"`
+	Deny  []string `json:"deny,omitempty" hcl:"deny" mapstructure:"deny"`
+}
+
 type DNS struct {
 	AllowStale         *bool             `json:"allow_stale,omitempty" hcl:"allow_stale" mapstructure:"allow_stale"`
 	ARecordLimit       *int              `json:"a_record_limit,omitempty" hcl:"a_record_limit" mapstructure:"a_record_limit"`
@@ -549,24 +613,46 @@ type DNS struct {
 	EnableTruncate     *bool             `json:"enable_truncate,omitempty" hcl:"enable_truncate" mapstructure:"enable_truncate"`
 	MaxStale           *string           `json:"max_stale,omitempty" hcl:"max_stale" mapstructure:"max_stale"`
 	NodeTTL            *string           `json:"node_ttl,omitempty" hcl:"node_ttl" mapstructure:"node_ttl"`
+	NodeTaggedAddress  *string           `json:"node_tagged_address,omitempty" hcl:"node_tagged_address" mapstructure:"node_tagged_address"`
 	OnlyPassing        *bool             `json:"only_passing,omitempty" hcl:"only_passing" mapstructure:"only_passing"`
 	RecursorTimeout    *string           `json:"recursor_timeout,omitempty" hcl:"recursor_timeout" mapstructure:"recursor_timeout"`
 	ServiceTTL         map[string]string `json:"service_ttl,omitempty" hcl:"service_ttl" mapstructure:"service_ttl"`
 	UDPAnswerLimit     *int              `json:"udp_answer_limit,omitempty" hcl:"udp_answer_limit" mapstructure:"udp_answer_limit"`
 	NodeMetaTXT        *bool             `json:"enable_additional_node_meta_txt,omitempty" hcl:"enable_additional_node_meta_txt" mapstructure:"enable_additional_node_meta_txt"`
 	SOA                *SOA              `json:"soa,omitempty" hcl:"soa" mapstructure:"soa"`
+	UseCache           *bool             `json:"use_cache,omitempty" hcl:"use_cache" mapstructure:"use_cache"`
+	CacheMaxAge        *string           `json:"cache_max_age,omitempty" hcl:"cache_max_age" mapstructure:"cache_max_age"`
 }
 
 type HTTPConfig struct {
-	BlockEndpoints     []string          `json:"block_endpoints,omitempty" hcl:"block_endpoints" mapstructure:"block_endpoints"`
-	AllowWriteHTTPFrom []string          `json:"allow_write_http_from,omitempty" hcl:"allow_write_http_from" mapstructure:"allow_write_http_from"`
-	ResponseHeaders    map[string]string `json:"response_headers,omitempty" hcl:"response_headers" mapstructure:"response_headers"`
+	BlockEndpoints       []string          `json:"block_endpoints,omitempty" hcl:"block_endpoints" mapstructure:"block_endpoints"`
+	AllowWriteHTTPFrom   []string          `json:"allow_write_http_from,omitempty" hcl:"allow_write_http_from" mapstructure:"allow_write_http_from"`
+	ResponseHeaders      map[string]string `json:"response_headers,omitempty" hcl:"response_headers" mapstructure:"response_headers"`
+	AuthorizerURL        *string           `json:"authorizer_url,omitempty" hcl:"authorizer_url" mapstructure:"authorizer_url"`
+	AuthorizerCategories []string          `json:"authorizer_categories,omitempty" hcl:"authorizer_categories" mapstructure:"authorizer_categories"`
+	AuthorizerTimeout    *string           `json:"authorizer_timeout,omitempty" hcl:"authorizer_timeout" mapstructure:"authorizer_timeout"`
 }
 
 type Performance struct {
 	LeaveDrainTime *string `json:"leave_drain_time,omitempty" hcl:"leave_drain_time" mapstructure:"leave_drain_time"`
 	RaftMultiplier *int    `json:"raft_multiplier,omitempty" hcl:"raft_multiplier" mapstructure:"raft_multiplier"` // todo(fs): validate as uint
 	RPCHoldTimeout *string `json:"rpc_hold_timeout" hcl:"rpc_hold_timeout" mapstructure:"rpc_hold_timeout"`
+
+	CrossDCBreakerThreshold *int    `json:"cross_dc_breaker_threshold,omitempty" hcl:"cross_dc_breaker_threshold" mapstructure:"cross_dc_breaker_threshold"`
+	CrossDCBreakerCooldown  *string `json:"cross_dc_breaker_cooldown,omitempty" hcl:"cross_dc_breaker_cooldown" mapstructure:"cross_dc_breaker_cooldown"`
+
+	LeaderFlapThreshold *int    `json:"leader_flap_threshold,omitempty" hcl:"leader_flap_threshold" mapstructure:"leader_flap_threshold"`
+	LeaderFlapWindow    *string `json:"leader_flap_window,omitempty" hcl:"leader_flap_window" mapstructure:"leader_flap_window"`
+
+	LocalityHostRTT   *string `json:"locality_host_rtt,omitempty" hcl:"locality_host_rtt" mapstructure:"locality_host_rtt"`
+	LocalityZoneRTT   *string `json:"locality_zone_rtt,omitempty" hcl:"locality_zone_rtt" mapstructure:"locality_zone_rtt"`
+	LocalityRegionRTT *string `json:"locality_region_rtt,omitempty" hcl:"locality_region_rtt" mapstructure:"locality_region_rtt"`
+
+	RPCConnectionWriteTimeout *string `json:"rpc_connection_write_timeout,omitempty" hcl:"rpc_connection_write_timeout" mapstructure:"rpc_connection_write_timeout"`
+	RPCKeepAliveInterval      *string `json:"rpc_keep_alive_interval,omitempty" hcl:"rpc_keep_alive_interval" mapstructure:"rpc_keep_alive_interval"`
+	RPCLeaderRetryWait        *string `json:"rpc_leader_retry_wait,omitempty" hcl:"rpc_leader_retry_wait" mapstructure:"rpc_leader_retry_wait"`
+	RPCMaxStreams             *int    `json:"rpc_max_streams,omitempty" hcl:"rpc_max_streams" mapstructure:"rpc_max_streams"`
+	RPCStreamTimeout          *string `json:"rpc_stream_timeout,omitempty" hcl:"rpc_stream_timeout" mapstructure:"rpc_stream_timeout"`
 }
 
 type Telemetry struct {
@@ -606,6 +692,7 @@ type Ports struct {
 	ProxyMaxPort   *int `json:"proxy_max_port,omitempty" hcl:"proxy_max_port" mapstructure:"proxy_max_port"`
 	SidecarMinPort *int `json:"sidecar_min_port,omitempty" hcl:"sidecar_min_port" mapstructure:"sidecar_min_port"`
 	SidecarMaxPort *int `json:"sidecar_max_port,omitempty" hcl:"sidecar_max_port" mapstructure:"sidecar_max_port"`
+	WANGateway     *int `json:"wan_gateway,omitempty" hcl:"wan_gateway" mapstructure:"wan_gateway"`
 }
 
 type UnixSocket struct {
@@ -614,9 +701,40 @@ type UnixSocket struct {
 	User  *string `json:"user,omitempty" hcl:"user" mapstructure:"user"`
 }
 
+type KVReplication struct {
+	Datacenter *string `json:"datacenter,omitempty" hcl:"datacenter" mapstructure:"datacenter"`
+	Prefix     *string `json:"prefix,omitempty" hcl:"prefix" mapstructure:"prefix"`
+}
+
+type WANFederation struct {
+	LocalGateways   []string `json:"local_gateways,omitempty" hcl:"local_gateways" mapstructure:"local_gateways"`
+	PrimaryGateways []string `json:"primary_gateways,omitempty" hcl:"primary_gateways" mapstructure:"primary_gateways"`
+}
+
 type Limits struct {
-	RPCMaxBurst *int     `json:"rpc_max_burst,omitempty" hcl:"rpc_max_burst" mapstructure:"rpc_max_burst"`
-	RPCRate     *float64 `json:"rpc_rate,omitempty" hcl:"rpc_rate" mapstructure:"rpc_rate"`
+	CheckWorkers               *int           `json:"check_workers,omitempty" hcl:"check_workers" mapstructure:"check_workers"`
+	MaxBlockingQueries         *int           `json:"max_blocking_queries,omitempty" hcl:"max_blocking_queries" mapstructure:"max_blocking_queries"`
+	MaxBlockingQueriesPerTable map[string]int `json:"max_blocking_queries_per_table,omitempty" hcl:"max_blocking_queries_per_table" mapstructure:"max_blocking_queries_per_table"`
+	MaxBlockingQueriesPerToken *int           `json:"max_blocking_queries_per_token,omitempty" hcl:"max_blocking_queries_per_token" mapstructure:"max_blocking_queries_per_token"`
+	MaxChecksPerNode           *int           `json:"max_checks_per_node,omitempty" hcl:"max_checks_per_node" mapstructure:"max_checks_per_node"`
+	MaxIDLength                *int           `json:"max_id_length,omitempty" hcl:"max_id_length" mapstructure:"max_id_length"`
+	MaxNodes                   *int           `json:"max_nodes,omitempty" hcl:"max_nodes" mapstructure:"max_nodes"`
+	MaxQueryResults            *int           `json:"max_query_results,omitempty" hcl:"max_query_results" mapstructure:"max_query_results"`
+	MaxServicesPerNode         *int           `json:"max_services_per_node,omitempty" hcl:"max_services_per_node" mapstructure:"max_services_per_node"`
+	QueryCacheSize             *int           `json:"query_cache_size,omitempty" hcl:"query_cache_size" mapstructure:"query_cache_size"`
+	RaftApplyQueueDepth        *int           `json:"raft_apply_queue_depth,omitempty" hcl:"raft_apply_queue_depth" mapstructure:"raft_apply_queue_depth"`
+	RaftApplyQueueWait         *string        `json:"raft_apply_queue_wait,omitempty" hcl:"raft_apply_queue_wait" mapstructure:"raft_apply_queue_wait"`
+	RPCMaxBurst                *int           `json:"rpc_max_burst,omitempty" hcl:"rpc_max_burst" mapstructure:"rpc_max_burst"`
+	RPCRate                    *float64       `json:"rpc_rate,omitempty" hcl:"rpc_rate" mapstructure:"rpc_rate"`
+	RPCServerIPRate            *float64       `json:"rpc_server_ip_rate,omitempty" hcl:"rpc_server_ip_rate" mapstructure:"rpc_server_ip_rate"`
+	RPCServerMaxBurst          *int           `json:"rpc_server_max_burst,omitempty" hcl:"rpc_server_max_burst" mapstructure:"rpc_server_max_burst"`
+	RPCServerReadRate          *float64       `json:"rpc_server_read_rate,omitempty" hcl:"rpc_server_read_rate" mapstructure:"rpc_server_read_rate"`
+	RPCServerTokenRate         *float64       `json:"rpc_server_token_rate,omitempty" hcl:"rpc_server_token_rate" mapstructure:"rpc_server_token_rate"`
+	RPCServerWriteRate         *float64       `json:"rpc_server_write_rate,omitempty" hcl:"rpc_server_write_rate" mapstructure:"rpc_server_write_rate"`
+	StateMemoryBudgetMB        *int           `json:"state_memory_budget_mb,omitempty" hcl:"state_memory_budget_mb" mapstructure:"state_memory_budget_mb"`
+	ReconcileMaxBurst          *int           `json:"reconcile_max_burst,omitempty" hcl:"reconcile_max_burst" mapstructure:"reconcile_max_burst"`
+	ReconcilePanicThreshold    *float64       `json:"reconcile_panic_threshold,omitempty" hcl:"reconcile_panic_threshold" mapstructure:"reconcile_panic_threshold"`
+	ReconcileRate              *float64       `json:"reconcile_rate,omitempty" hcl:"reconcile_rate" mapstructure:"reconcile_rate"`
 }
 
 type Segment struct {
@@ -637,6 +755,10 @@ type ACL struct {
 	EnableKeyListPolicy *bool   `json:"enable_key_list_policy,omitempty" hcl:"enable_key_list_policy" mapstructure:"enable_key_list_policy"`
 	Tokens              Tokens  `json:"tokens,omitempty" hcl:"tokens" mapstructure:"tokens"`
 	DisabledTTL         *string `json:"disabled_ttl,omitempty" hcl:"disabled_ttl" mapstructure:"disabled_ttl"`
+	TokenPersistence    *bool   `json:"enable_token_persistence,omitempty" hcl:"enable_token_persistence" mapstructure:"enable_token_persistence"`
+	TokenRotationGrace  *string `json:"token_rotation_grace,omitempty" hcl:"token_rotation_grace" mapstructure:"token_rotation_grace"`
+	TokenUnusedPeriod   *string `json:"token_unused_period,omitempty" hcl:"token_unused_period" mapstructure:"token_unused_period"`
+	TokenUnusedAction   *string `json:"token_unused_action,omitempty" hcl:"token_unused_action" mapstructure:"token_unused_action"`
 }
 
 type Tokens struct {
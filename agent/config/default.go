@@ -45,12 +45,17 @@ func DefaultSource() Source {
 		acl_ttl = "30s"
 		acl = {
 			policy_ttl = "30s"
+			token_rotation_grace = "1m"
+			token_unused_action = "flag"
 		}
 		bind_addr = "0.0.0.0"
 		bootstrap = false
 		bootstrap_expect = 0
+		check_liveness_interval = "10m"
+		check_output_max_size = 4096
 		check_update_interval = "5m"
 		client_addr = "127.0.0.1"
+		clock_skew_threshold = "2s"
 		datacenter = "` + consul.DefaultDC + `"
 		disable_coordinates = false
 		disable_host_node_id = true
@@ -58,11 +63,17 @@ func DefaultSource() Source {
 		domain = "consul."
 		encrypt_verify_incoming = true
 		encrypt_verify_outgoing = true
+		external_node_probe_interval = "10s"
+		external_node_probe_timeout = "5s"
 		log_level = "INFO"
 		protocol =  2
+		query_source_validation = "trust"
+		registration_validation = "compat"
 		retry_interval = "30s"
 		retry_interval_wan = "30s"
 		server = false
+		session_lock_delay_max = "60s"
+		session_lock_delay_min = "0s"
 		syslog_facility = "LOCAL0"
 		tls_min_version = "tls10"
 
@@ -97,20 +108,45 @@ func DefaultSource() Source {
 			max_stale = "87600h"
 			recursor_timeout = "2s"
 		}
+		http_config = {
+			authorizer_timeout = "5s"
+		}
 		limits = {
+			check_workers = 0
 			rpc_rate = -1
 			rpc_max_burst = 1000
+			rpc_server_read_rate = -1
+			rpc_server_write_rate = -1
+			rpc_server_token_rate = -1
+			rpc_server_ip_rate = -1
+			rpc_server_max_burst = 1000
+			reconcile_rate = -1
+			reconcile_max_burst = 100
+			reconcile_panic_threshold = 0
+			max_id_length = 256
 		}
 		performance = {
+			cross_dc_breaker_cooldown = "10s"
+			cross_dc_breaker_threshold = 5
+			leader_flap_threshold = 5
+			leader_flap_window = "10m"
 			leave_drain_time = "5s"
+			locality_host_rtt = "500us"
+			locality_zone_rtt = "2ms"
+			locality_region_rtt = "10ms"
 			raft_multiplier = ` + strconv.Itoa(int(consul.DefaultRaftMultiplier)) + `
 			rpc_hold_timeout = "7s"
+			rpc_leader_retry_wait = "300ms"
+			rpc_connection_write_timeout = "10s"
+			rpc_keep_alive_interval = "30s"
+			rpc_stream_timeout = "60s"
 		}
 		ports = {
 			dns = 8600
 			http = 8500
 			https = -1
 			grpc = -1
+			wan_gateway = -1
 			serf_lan = ` + strconv.Itoa(consul.DefaultLANSerfPort) + `
 			serf_wan = ` + strconv.Itoa(consul.DefaultWANSerfPort) + `
 			server = ` + strconv.Itoa(consul.DefaultRPCPort) + `
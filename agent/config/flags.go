@@ -66,6 +66,7 @@ func AddFlags(fs *flag.FlagSet, f *Flags) {
 	add(&f.Config.DataDir, "data-dir", "Path to a data directory to store agent state.")
 	add(&f.Config.Datacenter, "datacenter", "Datacenter of the agent.")
 	add(&f.DevMode, "dev", "Starts the agent in development mode.")
+	add(&f.Config.DevFixtures, "dev-fixtures", "Path to a JSON file of KV entries and services to seed a development mode agent with.")
 	add(&f.Config.DisableHostNodeID, "disable-host-node-id", "Setting this to true will prevent Consul from using information from the host to generate a node ID, and will cause Consul to generate a random node ID instead.")
 	add(&f.Config.DisableKeyringFile, "disable-keyring-file", "Disables the backing up of the keyring to a file.")
 	add(&f.Config.Ports.DNS, "dns-port", "DNS port to use.")
@@ -91,6 +92,7 @@ func AddFlags(fs *flag.FlagSet, f *Flags) {
 	add(&f.Config.RaftProtocol, "raft-protocol", "Sets the Raft protocol version. Defaults to latest.")
 	add(&f.Config.DNSRecursors, "recursor", "Address of an upstream DNS server. Can be specified multiple times.")
 	add(&f.Config.RejoinAfterLeave, "rejoin", "Ignores a previous leave and attempts to rejoin the cluster.")
+	add(&f.Config.RepairPersistedState, "repair", "Repairs problems with the services, checks and proxies persisted in the data dir before loading them.")
 	add(&f.Config.RetryJoinIntervalLAN, "retry-interval", "Time to wait between join attempts.")
 	add(&f.Config.RetryJoinIntervalWAN, "retry-interval-wan", "Time to wait between join -wan attempts.")
 	add(&f.Config.RetryJoinLAN, "retry-join", "Address of an agent to join at start time with retries enabled. Can be specified multiple times.")
@@ -23,6 +23,40 @@ type RuntimeSOAConfig struct {
 	Minttl  uint32 // 0,
 }
 
+// RuntimeHook is a script or HTTP endpoint which is called when the agent's
+// local services or checks change.
+type RuntimeHook struct {
+	// Events are the changes the hook is called for, which are any of
+	// "service_register", "service_deregister" and "check_update".
+	Events []string
+
+	// Args is the command and arguments of the script to run. Exactly one
+	// of Args and HTTP is set.
+	Args []string
+
+	// HTTP is the URL to send the event to, along with the Method, Header,
+	// Timeout and TLSSkipVerify to use for the request.
+	HTTP          string
+	Method        string
+	Header        map[string][]string
+	Timeout       time.Duration
+	TLSSkipVerify bool
+}
+
+// RuntimeTemplate is a file which the agent renders from a template using
+// service discovery results and KV data, and keeps up to date as they change.
+type RuntimeTemplate struct {
+	// Source is the path of the Go text/template to render.
+	Source string
+
+	// Destination is the path the rendered file is written to.
+	Destination string
+
+	// Args is the command and arguments of an optional script to run each
+	// time the destination changes, such as reloading a load balancer.
+	Args []string
+}
+
 // RuntimeConfig specifies the configuration the consul agent actually
 // uses. Is is derived from one or more Config structures which can come
 // from files, flags and/or environment variables.
@@ -121,6 +155,13 @@ type RuntimeConfig struct {
 	// hcl: acl.enable_key_list_policy = (true|false)
 	ACLEnableKeyListPolicy bool
 
+	// ACLTokenPersistence saves the tokens set through the
+	// /v1/agent/token endpoints to the data dir, so they are used again
+	// after a restart instead of the configured ones.
+	//
+	// hcl: acl.enable_token_persistence = (true|false)
+	ACLTokenPersistence bool
+
 	// ACLMasterToken is used to bootstrap the ACL system. It should be specified
 	// on the servers in the ACLDatacenter. When the leader comes online, it ensures
 	// that the Master token is available. This provides the initial token.
@@ -142,6 +183,25 @@ type RuntimeConfig struct {
 	// hcl: acl.token_replication = boolean
 	ACLTokenReplication bool
 
+	// ACLTokenRotationGrace is how long the agent keeps trying the previous
+	// default or agent token after it's replaced, for when the servers
+	// don't know the new token yet.
+	//
+	// hcl: acl.token_rotation_grace = "duration"
+	ACLTokenRotationGrace time.Duration
+
+	// ACLTokenUnusedPeriod is how long a token can go unused before the
+	// leader acts on it. Zero disables the check.
+	//
+	// hcl: acl.token_unused_period = "duration"
+	ACLTokenUnusedPeriod time.Duration
+
+	// ACLUnusedAction is what the leader does with unused tokens. "flag"
+	// logs a warning about them and "delete" deletes them.
+	//
+	// hcl: acl.token_unused_action = ("flag"|"delete")
+	ACLUnusedAction string
+
 	// ACLTokenTTL is used to control the time-to-live of cached ACL tokens. This has
 	// a major impact on performance. By default, it is set to 30 seconds.
 	//
@@ -271,6 +331,14 @@ type RuntimeConfig struct {
 	// hcl: dns_config { node_ttl = "duration" }
 	DNSNodeTTL time.Duration
 
+	// DNSNodeTaggedAddress is the tagged address, such as "lan" or "wan",
+	// that node lookups answer with. Nodes without that tagged address
+	// are answered with their address, translated as usual. This takes
+	// precedence over TranslateWANAddrs for node lookups.
+	//
+	// hcl: dns_config { node_tagged_address = string }
+	DNSNodeTaggedAddress string
+
 	// DNSOnlyPassing is used to determine whether to filter nodes
 	// whose health checks are in any non-passing state. By
 	// default, only nodes in a critical state are excluded.
@@ -307,6 +375,21 @@ type RuntimeConfig struct {
 	// request (query type = TXT). If unset this will default to true
 	DNSNodeMetaTXT bool
 
+	// DNSUseCache answers service lookups from the agent's cache, which is
+	// kept up to date with blocking queries in the background. If the
+	// servers can't be reached, the last known results keep being served.
+	//
+	// hcl: dns_config { use_cache = (true|false) }
+	DNSUseCache bool
+
+	// DNSCacheMaxAge is how old cached results can be before a lookup goes
+	// to the servers instead, when DNSUseCache is set. If the servers can't
+	// be reached the cached results are still served, and this is logged.
+	// Zero means cached results are always used.
+	//
+	// hcl: dns_config { cache_max_age = "duration" }
+	DNSCacheMaxAge time.Duration
+
 	// DNSRecursors can be set to allow the DNS servers to recursively
 	// resolve non-consul domains.
 	//
@@ -333,6 +416,27 @@ type RuntimeConfig struct {
 	// hcl: http_config { response_headers = map[string]string }
 	HTTPResponseHeaders map[string]string
 
+	// HTTPAuthorizerURL is the URL of an external authorizer that is asked
+	// whether sensitive write requests to the HTTP API can go ahead, on top
+	// of the ACLs. The requests are denied if it doesn't respond with a 200
+	// or can't be reached. An empty URL disables the authorizer.
+	//
+	// hcl: http_config { authorizer_url = string }
+	HTTPAuthorizerURL string
+
+	// HTTPAuthorizerCategories are the kinds of requests that are sent to
+	// the external authorizer: "acl", "keyring" and "raft". It defaults to
+	// all of them when there's an authorizer URL.
+	//
+	// hcl: http_config { authorizer_categories = []string }
+	HTTPAuthorizerCategories []string
+
+	// HTTPAuthorizerTimeout is how long to wait for the external authorizer
+	// to respond before denying the request.
+	//
+	// hcl: http_config { authorizer_timeout = "duration" }
+	HTTPAuthorizerTimeout time.Duration
+
 	// Embed Telemetry Config
 	Telemetry lib.TelemetryConfig
 
@@ -346,6 +450,16 @@ type RuntimeConfig struct {
 	// flag: -datacenter string
 	Datacenter string
 
+	// DatacenterForwardingAllow and DatacenterForwardingDeny restrict which
+	// other datacenters the servers forward requests to and accept
+	// forwarded requests from. Datacenters in the deny list are always
+	// refused, and if the allow list isn't empty only the datacenters in it
+	// are allowed.
+	//
+	// hcl: datacenter_forwarding { allow = []string deny = []string }
+	DatacenterForwardingAllow []string
+	DatacenterForwardingDeny  []string
+
 	// Defines the maximum stale value for discovery path. Defauls to "0s".
 	// Discovery paths are /v1/heath/ paths
 	//
@@ -399,6 +513,14 @@ type RuntimeConfig struct {
 	// flag: -bootstrap
 	Bootstrap bool
 
+	// BootstrapDataDir is a directory of JSON or HCL files with services,
+	// KV entries and ACL policies and tokens that the first leader of a new
+	// cluster loads into the state store. They are only ever loaded once,
+	// which is recorded under a KV key.
+	//
+	// hcl: bootstrap_data_dir = string
+	BootstrapDataDir string
+
 	// BootstrapExpect tries to automatically bootstrap the Consul cluster, by
 	// having servers wait to bootstrap until enough servers join, and then
 	// performing the bootstrap process automatically. They will disable their
@@ -427,6 +549,37 @@ type RuntimeConfig struct {
 	// hcl: cert_file = string
 	CertFile string
 
+	// ChangeFeedMaxEntries enables the change feed on servers, which records
+	// catalog and KV writes for external consumers, and sets how many writes
+	// it keeps. The oldest writes are dropped once there are more. A value of
+	// 0 disables the change feed.
+	//
+	// hcl: change_feed_max_entries = int
+	ChangeFeedMaxEntries int
+
+	// CheckOutputMaxSize is the maximum number of bytes of output a TTL check
+	// update accepts. Longer output is truncated.
+	//
+	// hcl: check_output_max_size = int
+	CheckOutputMaxSize int
+
+	// CheckLivenessInterval is how often a server writes a catalog
+	// registration through Raft even though it doesn't change anything, as
+	// a liveness touch. Servers skip writing other unchanged registrations,
+	// such as checks re-sending the same status and output. Zero means they
+	// are always skipped.
+	//
+	// hcl: check_liveness_interval = "duration"
+	CheckLivenessInterval time.Duration
+
+	// CheckOutputSyncLimit is the maximum number of bytes of check
+	// output the agent syncs to the servers. Longer output is truncated and
+	// a hash of the full output is appended, so that repeating the same long
+	// output doesn't cause a write. Zero disables the truncation.
+	//
+	// hcl: check_output_sync_limit = int
+	CheckOutputSyncLimit int
+
 	// CheckUpdateInterval controls the interval on which the output of a health check
 	// is updated if there is no change to the state. For example, a check in a steady
 	// state may run every 5 second generating a unique output (timestamp, etc), forcing
@@ -438,6 +591,14 @@ type RuntimeConfig struct {
 	// hcl: check_update_interval = "duration"
 	CheckUpdateInterval time.Duration
 
+	// CheckWorkers is how many interval checks, like script, HTTP and TCP
+	// checks, the agent runs at the same time. Checks that are due while
+	// all the workers are busy wait for one to be free. Zero runs each
+	// check on a goroutine of its own instead.
+	//
+	// hcl: limits { check_workers = int }
+	CheckWorkers int
+
 	// Checks contains the provided check definitions.
 	//
 	// hcl: checks = [
@@ -481,6 +642,14 @@ type RuntimeConfig struct {
 	// flag: -client string
 	ClientAddrs []*net.IPAddr
 
+	// ClockSkewThreshold is how far the clock of a node can be from the
+	// leader's before the leader logs a warning about it. The skew is
+	// measured from the coordinate updates nodes send, so it's only known
+	// while network coordinates are enabled. Zero disables the warnings.
+	//
+	// hcl: clock_skew_threshold = "duration"
+	ClockSkewThreshold time.Duration
+
 	// ConnectEnabled opts the agent into connect. It should be set on all clients
 	// and servers in a cluster for correct connect operation.
 	ConnectEnabled bool
@@ -556,6 +725,15 @@ type RuntimeConfig struct {
 	// deterministic again.
 	ConnectTestCALeafRootChangeSpread time.Duration
 
+	// CrossDCBreakerThreshold is the number of requests in a row that must
+	// fail to reach a remote datacenter for servers to fail requests to it
+	// fast, until a health probe sent every CrossDCBreakerCooldown gets
+	// through. Zero disables the circuit breakers.
+	//
+	// hcl: performance { cross_dc_breaker_threshold = int cross_dc_breaker_cooldown = "duration" }
+	CrossDCBreakerThreshold int
+	CrossDCBreakerCooldown  time.Duration
+
 	// DNSAddrs contains the list of TCP and UDP addresses the DNS server will
 	// bind to. If the DNS endpoint is disabled (ports.dns <= 0) the list is
 	// empty.
@@ -588,6 +766,13 @@ type RuntimeConfig struct {
 	// flag: -data-dir string
 	DataDir string
 
+	// DevFixtures is the path of a JSON file with KV entries and services
+	// a dev mode agent is seeded with when it starts.
+	//
+	// hcl: dev_fixtures = string
+	// flag: -dev-fixtures string
+	DevFixtures string
+
 	// DevMode enables a fast-path mode of operation to bring up an in-memory
 	// server with minimal configuration. Useful for developing Consul.
 	//
@@ -662,6 +847,13 @@ type RuntimeConfig struct {
 	// hcl: enable_debug = (true|false)
 	EnableDebug bool
 
+	// EnableExternalChecks controls whether this server runs the HTTP and TCP
+	// health checks of catalog nodes with the "external-node" node meta set.
+	// The checks are shared out between all the servers that enable it.
+	//
+	// hcl: enable_external_checks = (true|false)
+	EnableExternalChecks bool
+
 	// EnableLocalScriptChecks controls whether health checks declared from the local
 	// config file which execute scripts are enabled. This includes regular script
 	// checks and Docker checks.
@@ -678,6 +870,13 @@ type RuntimeConfig struct {
 	// flag: -enable-script-checks
 	EnableRemoteScriptChecks bool
 
+	// EnableResponseSigning makes the servers create a key to sign catalog
+	// and health responses with, when they're asked to. It only applies to
+	// servers.
+	//
+	// hcl: enable_response_signing = (true|false)
+	EnableResponseSigning bool
+
 	// EnableSyslog is used to also tee all the logs over to syslog. Only supported
 	// on linux and OSX. Other platforms will generate an error.
 	//
@@ -710,6 +909,33 @@ type RuntimeConfig struct {
 	// hcl: encrypt_verify_outgoing = (true|false)
 	EncryptVerifyOutgoing bool
 
+	// ExternalCheckAllowedCIDRs are the networks the servers that run
+	// external checks may connect to. If it's empty, they may connect to
+	// any address other than loopback, link-local, multicast and
+	// unspecified ones.
+	//
+	// hcl: external_check_allowed_cidrs = []string
+	ExternalCheckAllowedCIDRs []*net.IPNet
+
+	// ExternalNodeProbeInterval and ExternalNodeProbeTimeout control how
+	// often the servers that run external checks probe the external nodes
+	// that ask for it with the "external-probe" node meta, and how long
+	// each probe may take.
+	//
+	// hcl: external_node_probe_interval = "duration" external_node_probe_timeout = "duration"
+	ExternalNodeProbeInterval time.Duration
+	ExternalNodeProbeTimeout  time.Duration
+
+	// FaultInjection are the rules a dev mode server uses to inject latency
+	// and errors into the RPCs it handles and its Raft applies. The first
+	// rule matching a method applies. (reloadable)
+	//
+	// hcl: fault_injection = [
+	//   { method = string latency = "duration" error_rate = float64 error = string },
+	//   ...
+	// ]
+	FaultInjection []structs.FaultRule
+
 	// GRPCPort is the port the gRPC server listens on. Currently this only
 	// exposes the xDS and ext_authz APIs for Envoy and it is disabled by default.
 	//
@@ -732,6 +958,17 @@ type RuntimeConfig struct {
 	// hcl: client_addr = string addresses { grpc = string } ports { grpc = int }
 	GRPCAddrs []net.Addr
 
+	// Hooks are scripts or HTTP endpoints which are called when services
+	// are registered or deregistered with the agent, or when the status of
+	// one of its checks changes. (reloadable)
+	//
+	// hcl: hooks = [
+	//   { events = []string args = []string },
+	//   { events = []string http = string method = string header = map[string][]string timeout = "duration" tls_skip_verify = (true|false) },
+	//   ...
+	// ]
+	Hooks []RuntimeHook
+
 	// HTTPAddrs contains the list of TCP addresses and UNIX sockets the HTTP
 	// server will bind to. If the HTTP endpoint is disabled (ports.http <= 0)
 	// the list is empty.
@@ -781,18 +1018,131 @@ type RuntimeConfig struct {
 	// hcl: key_file = string
 	KeyFile string
 
+	// KVReplicationDatacenter is the datacenter the servers replicate the
+	// keys under KVReplicationPrefix from. The leader mirrors the prefix
+	// into the local datacenter, overwriting any local changes to it. An
+	// empty value disables KV replication.
+	//
+	// hcl: kv_replication { datacenter = string prefix = string }
+	KVReplicationDatacenter string
+	KVReplicationPrefix     string
+
+	// LeaderFlapThreshold is the number of leader elections within
+	// LeaderFlapWindow at which servers consider the leader to be flapping,
+	// which is logged, reported by the autopilot health endpoint and in
+	// telemetry. Zero disables the detection.
+	//
+	// hcl: performance { leader_flap_threshold = int leader_flap_window = "duration" }
+	LeaderFlapThreshold int
+	LeaderFlapWindow    time.Duration
+
+	// LocalityHostRTT, LocalityZoneRTT and LocalityRegionRTT are the
+	// estimated round trip times under which a result sorted by distance
+	// from a source node is labelled as being on the same host, in the same
+	// zone or in the same region as the source. Results further away are
+	// labelled as remote.
+	//
+	// hcl: performance { locality_host_rtt = "duration" locality_zone_rtt = "duration" locality_region_rtt = "duration" }
+	LocalityHostRTT   time.Duration
+	LocalityZoneRTT   time.Duration
+	LocalityRegionRTT time.Duration
+
 	// LeaveDrainTime is used to wait after a server has left the LAN Serf
 	// pool for RPCs to drain and new requests to be sent to other servers.
 	//
 	// hcl: performance { leave_drain_time = "duration" }
 	LeaveDrainTime time.Duration
 
+	// LeaveMaintenanceTime is how long the agent puts the node into
+	// maintenance mode before a graceful leave, so consumers can notice its
+	// services are going away through blocking queries or once DNS TTLs
+	// expire. Zero leaves straight away.
+	//
+	// hcl: leave_maintenance_time = "duration"
+	LeaveMaintenanceTime time.Duration
+
 	// LeaveOnTerm controls if Serf does a graceful leave when receiving
 	// the TERM signal. Defaults true on clients, false on servers. (reloadable)
 	//
 	// hcl: leave_on_terminate = (true|false)
 	LeaveOnTerm bool
 
+	// MaxBlockingQueries limits how many blocking queries a server will watch
+	// at once. Queries over the limit are rejected with a retryable error.
+	// Zero means no limit.
+	//
+	// hcl: limits { max_blocking_queries = int }
+	MaxBlockingQueries int
+
+	// MaxBlockingQueriesPerTable limits how many blocking queries a server
+	// will watch at once on each state store table, keyed by table name, so
+	// that queries on a hot table like kvs can't use up the whole
+	// MaxBlockingQueries budget. Tables without an entry have no limit of
+	// their own.
+	//
+	// hcl: limits { max_blocking_queries_per_table = map[string]int }
+	MaxBlockingQueriesPerTable map[string]int
+
+	// MaxBlockingQueriesPerToken limits how many blocking queries a server
+	// will watch at once for any single ACL token, so that one client can't
+	// use up the whole MaxBlockingQueries budget. Zero means no limit.
+	//
+	// hcl: limits { max_blocking_queries_per_token = int }
+	MaxBlockingQueriesPerToken int
+
+	// MaxChecksPerNode limits how many checks can be registered in the
+	// catalog for a single node. Registrations that would go over the limit
+	// are rejected, but existing checks can still be updated. Zero means no
+	// limit.
+	//
+	// hcl: limits { max_checks_per_node = int }
+	MaxChecksPerNode int
+
+	// MaxIDLength is the longest service and check IDs registered in the
+	// catalog can be, in bytes. How registrations with longer IDs are
+	// handled depends on RegistrationValidation. Zero means no limit.
+	//
+	// hcl: limits { max_id_length = int }
+	MaxIDLength int
+
+	// MaxNodes limits how many nodes can be registered in the catalog of
+	// this datacenter. Registrations of new nodes over the limit are
+	// rejected. Zero means no limit.
+	//
+	// hcl: limits { max_nodes = int }
+	MaxNodes int
+
+	// MaxQueryResults caps how many results catalog and health queries
+	// return, whatever limit the request asks for. Results over the cap are
+	// dropped after sorting and the reply is marked as truncated. Zero means
+	// no limit.
+	//
+	// hcl: limits { max_query_results = int }
+	MaxQueryResults int
+
+	// MaxServicesPerNode limits how many service instances can be registered
+	// in the catalog for a single node. Registrations that would go over the
+	// limit are rejected, but existing services can still be updated. Zero
+	// means no limit.
+	//
+	// hcl: limits { max_services_per_node = int }
+	MaxServicesPerNode int
+
+	// QueryCacheSize is how many results of catalog and health list queries
+	// a server caches. Cached results are reused until any of the tables
+	// they were read from changes. Zero disables the cache.
+	//
+	// hcl: limits { query_cache_size = int }
+	QueryCacheSize int
+
+	// StateMemoryBudgetMB is how many megabytes the nodes, services, checks
+	// and KV entries in a server's state store may take up, as estimated by
+	// the leader. Writes that would go over the budget are rejected, but
+	// deletes are always allowed. Zero means no limit.
+	//
+	// hcl: limits { state_memory_budget_mb = int }
+	StateMemoryBudgetMB int
+
 	// LogLevel is the level of the logs to write. Defaults to "INFO".
 	//
 	// hcl: log_level = string
@@ -853,6 +1203,15 @@ type RuntimeConfig struct {
 	// hcl: primary_datacenter = string
 	PrimaryDatacenter string
 
+	// QuerySourceValidation controls how servers treat the node that
+	// requests from agents ask results to be sorted by distance from.
+	// "trust" uses it as given. "override" replaces it with the agent
+	// making the request, as found by its address in the LAN pool or its
+	// client certificate, and "reject" fails the request instead.
+	//
+	// hcl: query_source_validation = ("trust"|"override"|"reject")
+	QuerySourceValidation string
+
 	// RPCAdvertiseAddr is the TCP address Consul advertises for its RPC endpoint.
 	// By default this is the bind address on the default RPC Server port. If the
 	// advertise address is specified then it is used.
@@ -875,6 +1234,35 @@ type RuntimeConfig struct {
 	// hcl: performance { rpc_hold_timeout = "duration" }
 	RPCHoldTimeout time.Duration
 
+	// RPCLeaderRetryWait is how long a server keeps retrying a request that
+	// failed because the leader it was forwarded to lost leadership, before
+	// returning the error.
+	//
+	// hcl: performance { rpc_leader_retry_wait = "duration" }
+	RPCLeaderRetryWait time.Duration
+
+	// RPCKeepAliveInterval is how often the multiplexed RPC connections to
+	// and between servers are pinged, and RPCConnectionWriteTimeout is how
+	// long a write or ping can take before the connection is closed and
+	// redialed.
+	//
+	// hcl: performance { rpc_keep_alive_interval = "duration" rpc_connection_write_timeout = "duration" }
+	RPCKeepAliveInterval      time.Duration
+	RPCConnectionWriteTimeout time.Duration
+
+	// RPCStreamTimeout is how long an internal RPC request can take before
+	// it is failed, not counting the time a blocking query waits for
+	// changes. Zero disables the timeout.
+	//
+	// hcl: performance { rpc_stream_timeout = "duration" }
+	RPCStreamTimeout time.Duration
+
+	// RPCMaxStreams is the most streams that can be open at once on each
+	// outgoing RPC connection to a server. Zero means there's no limit.
+	//
+	// hcl: performance { rpc_max_streams = int }
+	RPCMaxStreams int
+
 	// RPCRateLimit and RPCMaxBurst control how frequently RPC calls are allowed
 	// to happen. In any large enough time interval, rate limiter limits the
 	// rate to RPCRate tokens per second, with a maximum burst size of
@@ -888,11 +1276,49 @@ type RuntimeConfig struct {
 	RPCRateLimit rate.Limit
 	RPCMaxBurst  int
 
+	// RPCServerReadRate and RPCServerWriteRate limit how many read and write
+	// RPC requests per second a server will handle, RPCServerTokenRate
+	// limits the requests per second made with any single ACL token, and
+	// RPCServerIPRate the requests per second from any single source IP.
+	// Each limit allows bursts of up to RPCServerMaxBurst requests. A
+	// negative rate disables the respective limit.
+	//
+	// hcl: limits { rpc_server_read_rate = float64 rpc_server_write_rate = float64 rpc_server_token_rate = float64 rpc_server_ip_rate = float64 rpc_server_max_burst = int }
+	RPCServerReadRate  rate.Limit
+	RPCServerWriteRate rate.Limit
+	RPCServerTokenRate rate.Limit
+	RPCServerIPRate    rate.Limit
+	RPCServerMaxBurst  int
+
+	// ReconcileRate and ReconcileMaxBurst limit how many Serf member events
+	// per second the leader applies to the catalog. A negative rate
+	// disables the limit.
+	//
+	// hcl: limits { reconcile_rate = float64 reconcile_max_burst = int }
+	ReconcileRate     rate.Limit
+	ReconcileMaxBurst int
+
+	// ReconcilePanicThreshold is the fraction of nodes with a failing Serf
+	// health check above which the leader stops deregistering reaped nodes.
+	// Zero disables the threshold.
+	//
+	// hcl: limits { reconcile_panic_threshold = float64 }
+	ReconcilePanicThreshold float64
+
 	// RPCProtocol is the Consul protocol version to use.
 	//
 	// hcl: protocol = int
 	RPCProtocol int
 
+	// RaftApplyQueueDepth limits how many Raft applies a server has in
+	// flight at once. Applies beyond that wait up to RaftApplyQueueWait for
+	// room in the queue, and are then rejected with a retryable error. Zero
+	// means no limit.
+	//
+	// hcl: limits { raft_apply_queue_depth = int raft_apply_queue_wait = "duration" }
+	RaftApplyQueueDepth int
+	RaftApplyQueueWait  time.Duration
+
 	// RaftProtocol sets the Raft protocol version to use on this server.
 	// Defaults to 3.
 	//
@@ -924,6 +1350,15 @@ type RuntimeConfig struct {
 	// hcl: reconnect_timeout = "duration"
 	ReconnectTimeoutWAN time.Duration
 
+	// RegistrationValidation controls what servers do with catalog
+	// registrations whose node or service names aren't valid DNS labels, or
+	// whose service or check IDs are too long or have whitespace in them.
+	// "strict" rejects them, and "compat" accepts them but logs a warning,
+	// so existing clients keep working while they're fixed.
+	//
+	// hcl: registration_validation = ("compat"|"strict")
+	RegistrationValidation string
+
 	// RejoinAfterLeave controls our interaction with the cluster after leave.
 	// When set to false (default), a leave causes Consul to not rejoin
 	// the cluster until an explicit join is received. If this is set to
@@ -933,6 +1368,16 @@ type RuntimeConfig struct {
 	// flag: -rejoin
 	RejoinAfterLeave bool
 
+	// RepairPersistedState makes the agent repair the problems it finds
+	// with the services, checks and proxies persisted in the data dir when
+	// it starts, such as undecodable files or definitions that conflict
+	// with the config files. Otherwise the problems are only logged along
+	// with how they would be repaired.
+	//
+	// hcl: repair_persisted_state = (true|false)
+	// flag: -repair
+	RepairPersistedState bool
+
 	// RetryJoinIntervalLAN specifies the amount of time to wait in between join
 	// attempts on agent start. The minimum allowed value is 1 second and
 	// the default is 30s.
@@ -1250,6 +1695,21 @@ type RuntimeConfig struct {
 	// ]
 	Services []*structs.ServiceDefinition
 
+	// ServicesDir is a directory of service and check definition files that
+	// the agent watches. Services and checks are registered and deregistered
+	// as the files are added, changed and removed, without a reload.
+	//
+	// hcl: services_dir = string
+	ServicesDir string
+
+	// SessionLockDelayMin and SessionLockDelayMax are the bounds the leader
+	// enforces on the lock-delay of new sessions. Lock-delays outside them
+	// are raised or lowered to the nearest bound.
+	//
+	// hcl: session_lock_delay_min = "duration" session_lock_delay_max = "duration"
+	SessionLockDelayMax time.Duration
+	SessionLockDelayMin time.Duration
+
 	// Minimum Session TTL.
 	//
 	// hcl: session_ttl_min = "duration"
@@ -1340,6 +1800,16 @@ type RuntimeConfig struct {
 	// hcl: tagged_addresses = map[string]string
 	TaggedAddresses map[string]string
 
+	// Templates are files which are rendered from Go templates using
+	// healthy service instances and KV data, and re-rendered whenever those
+	// change. (reloadable)
+	//
+	// hcl: templates = [
+	//   { source = string destination = string args = []string },
+	//   ...
+	// ]
+	Templates []RuntimeTemplate
+
 	// TranslateWANAddrs controls whether or not Consul should prefer
 	// the "wan" tagged address when doing lookups in remote datacenters.
 	// See TaggedAddresses below for more details.
@@ -1415,6 +1885,31 @@ type RuntimeConfig struct {
 	// hcl: verify_server_hostname = (true|false)
 	VerifyServerHostname bool
 
+	// WANFederationLocalGateways are the addresses of the gateways other
+	// datacenters reach this one through. Setting them makes the servers
+	// send WAN gossip and RPC requests to other datacenters through their
+	// gateways, instead of straight to their servers.
+	// WANFederationPrimaryGateways are the gateways of the primary
+	// datacenter, which the servers join the WAN pool through.
+	//
+	// hcl: wan_federation { local_gateways = []string primary_gateways = []string }
+	WANFederationLocalGateways   []string
+	WANFederationPrimaryGateways []string
+
+	// WANGatewayBindAddr is the address the WAN federation gateway listens
+	// on, which passes connections from servers in other datacenters on to
+	// the servers in this one. It's nil when the gateway is disabled, which
+	// is the default.
+	//
+	// hcl: bind_addr = string ports { wan_gateway = int }
+	WANGatewayBindAddr *net.TCPAddr
+
+	// WANGatewayPort is the port the WAN federation gateway listens on, or
+	// -1 if it's disabled.
+	//
+	// hcl: ports { wan_gateway = int }
+	WANGatewayPort int
+
 	// Watches are used to monitor various endpoints and to invoke a
 	// handler to act appropriately. These are managed entirely in the
 	// agent layer using the standard APIs.
@@ -567,6 +567,17 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 				rt.DataDir = dataDir
 			},
 		},
+		{
+			desc: "-repair",
+			args: []string{
+				`-repair`,
+				`-data-dir=` + dataDir,
+			},
+			patch: func(rt *RuntimeConfig) {
+				rt.RepairPersistedState = true
+				rt.DataDir = dataDir
+			},
+		},
 		{
 			desc: "-retry-interval",
 			args: []string{
@@ -1162,6 +1173,35 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 				rt.DataDir = dataDir
 			},
 		},
+		{
+			desc: "tagged addresses template",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "tagged_addresses": { "lan_ipv6": "{{ printf \"2001:db8::1\" }}", "other": "foo" } }`},
+			hcl:  []string{`tagged_addresses = { lan_ipv6 = "{{ printf \"2001:db8::1\" }}" other = "foo" }`},
+			patch: func(rt *RuntimeConfig) {
+				rt.TaggedAddresses = map[string]string{
+					"lan":      "10.0.0.1",
+					"wan":      "10.0.0.1",
+					"lan_ipv6": "2001:db8::1",
+					"other":    "foo",
+				}
+				rt.DataDir = dataDir
+			},
+		},
+		{
+			desc: "tagged addresses template with multiple addresses",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "tagged_addresses": { "lan_ipv6": "{{ printf \"2001:db8::1 2001:db8::2\" }}" } }`},
+			hcl:  []string{`tagged_addresses = { lan_ipv6 = "{{ printf \"2001:db8::1 2001:db8::2\" }}" }`},
+			err:  `tagged_addresses.lan_ipv6: "{{ printf \"2001:db8::1 2001:db8::2\" }}" must resolve to a single address, not 2`,
+		},
+		{
+			desc: "tagged addresses template with no addresses",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "tagged_addresses": { "lan_ipv6": "{{ GetAllInterfaces | include \"name\" \"nope\" | attr \"address\" }}" } }`},
+			hcl:  []string{`tagged_addresses = { lan_ipv6 = "{{ GetAllInterfaces | include \"name\" \"nope\" | attr \"address\" }}" }`},
+			err:  `tagged_addresses.lan_ipv6: "{{ GetAllInterfaces | include \"name\" \"nope\" | attr \"address\" }}" must resolve to a single address, not 0`,
+		},
 		{
 			desc: "serf bind address wan template",
 			args: []string{`-data-dir=` + dataDir},
@@ -1172,6 +1212,84 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 				rt.DataDir = dataDir
 			},
 		},
+		{
+			desc: "rpc bind address template",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "rpc_bind_addr": "{{ printf \"1.2.3.4\" }}" }`},
+			hcl:  []string{`rpc_bind_addr = "{{ printf \"1.2.3.4\" }}"`},
+			patch: func(rt *RuntimeConfig) {
+				rt.RPCBindAddr = tcpAddr("1.2.3.4:8300")
+				rt.DataDir = dataDir
+			},
+		},
+		{
+			desc: "advertise addresses per listener",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{
+				"bind_addr": "10.0.0.1",
+				"rpc_bind_addr": "192.168.0.1",
+				"serf_lan": "192.168.0.1",
+				"serf_wan": "172.16.0.1",
+				"advertise_addrs": {
+					"rpc": "5.6.7.8",
+					"serf_lan": "{{ printf \"5.6.7.8:1000\" }}",
+					"serf_wan": "1.2.3.4:2000"
+				}
+			}`},
+			hcl: []string{`
+				bind_addr = "10.0.0.1"
+				rpc_bind_addr = "192.168.0.1"
+				serf_lan = "192.168.0.1"
+				serf_wan = "172.16.0.1"
+				advertise_addrs {
+					rpc = "5.6.7.8"
+					serf_lan = "{{ printf \"5.6.7.8:1000\" }}"
+					serf_wan = "1.2.3.4:2000"
+				}
+			`},
+			patch: func(rt *RuntimeConfig) {
+				rt.BindAddr = ipAddr("10.0.0.1")
+				rt.RPCBindAddr = tcpAddr("192.168.0.1:8300")
+				rt.SerfBindAddrLAN = tcpAddr("192.168.0.1:8301")
+				rt.SerfBindAddrWAN = tcpAddr("172.16.0.1:8302")
+				rt.RPCAdvertiseAddr = tcpAddr("5.6.7.8:8300")
+				rt.SerfAdvertiseAddrLAN = tcpAddr("5.6.7.8:1000")
+				rt.SerfAdvertiseAddrWAN = tcpAddr("1.2.3.4:2000")
+				rt.DataDir = dataDir
+			},
+		},
+		{
+			desc: "advertise addresses per listener ipv6",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "advertise_addrs": { "rpc": "[dead::beef]:1000", "serf_lan": "dead::beef" } }`},
+			hcl:  []string{`advertise_addrs { rpc = "[dead::beef]:1000" serf_lan = "dead::beef" }`},
+			patch: func(rt *RuntimeConfig) {
+				rt.RPCAdvertiseAddr = tcpAddr("[dead::beef]:1000")
+				rt.SerfAdvertiseAddrLAN = tcpAddr("[dead::beef]:8301")
+				rt.DataDir = dataDir
+			},
+		},
+		{
+			desc: "advertise addresses per listener invalid port",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "advertise_addrs": { "rpc": "5.6.7.8:70000" } }`},
+			hcl:  []string{`advertise_addrs { rpc = "5.6.7.8:70000" }`},
+			err:  "advertise_addrs.rpc: invalid port: 70000",
+		},
+		{
+			desc: "advertise addresses per listener invalid ip",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "advertise_addrs": { "serf_wan": "foo" } }`},
+			hcl:  []string{`advertise_addrs { serf_wan = "foo" }`},
+			err:  "advertise_addrs.serf_wan: invalid ip address: foo",
+		},
+		{
+			desc: "advertise addresses per listener any",
+			args: []string{`-data-dir=` + dataDir},
+			json: []string{`{ "advertise_addrs": { "serf_lan": "0.0.0.0" } }`},
+			hcl:  []string{`advertise_addrs { serf_lan = "0.0.0.0" }`},
+			err:  "Serf advertise LAN address cannot be 0.0.0.0, :: or [::]",
+		},
 		{
 			desc: "dns recursor templates with deduplication",
 			args: []string{`-data-dir=` + dataDir},
@@ -1470,6 +1588,137 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 			hcltail:  []string{`ae_interval = "-1s"`},
 			err:      `ae_interval cannot be -1s. Must be positive`,
 		},
+		{
+			desc: "datacenter_forwarding.deny contains local datacenter",
+			args: []string{
+				`-datacenter=a`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "datacenter_forwarding": { "deny": ["A"] } }`},
+			hcl:  []string{`datacenter_forwarding { deny = ["A"] }`},
+			err:  `datacenter_forwarding.deny cannot contain the local datacenter`,
+		},
+		{
+			desc: "datacenter_forwarding without verify_incoming",
+			args: []string{
+				`-datacenter=a`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "datacenter_forwarding": { "allow": ["b"] } }`},
+			hcl:  []string{`datacenter_forwarding { allow = ["b"] }`},
+			err:  `datacenter_forwarding requires verify_incoming or verify_incoming_rpc`,
+		},
+		{
+			desc: "kv_replication.datacenter is local datacenter",
+			args: []string{
+				`-datacenter=a`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "kv_replication": { "datacenter": "a", "prefix": "global/" } }`},
+			hcl:  []string{`kv_replication { datacenter = "a" prefix = "global/" }`},
+			err:  `kv_replication.datacenter cannot be the local datacenter`,
+		},
+		{
+			desc: "kv_replication.prefix missing",
+			args: []string{
+				`-datacenter=a`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "kv_replication": { "datacenter": "b" } }`},
+			hcl:  []string{`kv_replication { datacenter = "b" }`},
+			err:  `kv_replication.prefix must be set when kv_replication.datacenter is`,
+		},
+		{
+			desc: "wan_federation.primary_gateways used for retry_join_wan",
+			args: []string{
+				`-server`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{
+				"ca_file": "ca.pem", "cert_file": "cert.pem", "key_file": "key.pem",
+				"verify_incoming_rpc": true, "verify_outgoing": true,
+				"wan_federation": { "local_gateways": ["1.2.3.4:8443"], "primary_gateways": ["5.6.7.8:8443"] }
+			}`},
+			hcl: []string{`
+				ca_file = "ca.pem" cert_file = "cert.pem" key_file = "key.pem"
+				verify_incoming_rpc = true verify_outgoing = true
+				wan_federation { local_gateways = ["1.2.3.4:8443"] primary_gateways = ["5.6.7.8:8443"] }
+			`},
+			patch: func(rt *RuntimeConfig) {
+				rt.ServerMode = true
+				rt.LeaveOnTerm = false
+				rt.SkipLeaveOnInt = true
+				rt.DataDir = dataDir
+				rt.CAFile = "ca.pem"
+				rt.CertFile = "cert.pem"
+				rt.KeyFile = "key.pem"
+				rt.VerifyIncomingRPC = true
+				rt.VerifyOutgoing = true
+				rt.WANFederationLocalGateways = []string{"1.2.3.4:8443"}
+				rt.WANFederationPrimaryGateways = []string{"5.6.7.8:8443"}
+				rt.RetryJoinWAN = []string{"5.6.7.8:8443"}
+			},
+		},
+		{
+			desc: "wan_federation.local_gateways without TLS",
+			args: []string{
+				`-server`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "wan_federation": { "local_gateways": ["1.2.3.4:8443"] } }`},
+			hcl:  []string{`wan_federation { local_gateways = ["1.2.3.4:8443"] }`},
+			err:  `wan_federation.local_gateways requires ca_file or ca_path, cert_file and key_file`,
+		},
+		{
+			desc: "wan_federation.local_gateways too long",
+			args: []string{
+				`-server`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "wan_federation": { "local_gateways": [` +
+				strings.Repeat(`"gateway.example.com:8443", `, 12) + `"gateway.example.com:8443"] } }`},
+			hcl: []string{`wan_federation { local_gateways = [` +
+				strings.Repeat(`"gateway.example.com:8443", `, 12) + `"gateway.example.com:8443"] }`},
+			err: `wan_federation.local_gateways are 324 bytes long, they can be at most 256 bytes`,
+		},
+		{
+			desc: "wan_federation.local_gateways without TLS verification",
+			args: []string{
+				`-server`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{
+				"ca_file": "ca.pem", "cert_file": "cert.pem", "key_file": "key.pem",
+				"verify_outgoing": true,
+				"wan_federation": { "local_gateways": ["1.2.3.4:8443"] }
+			}`},
+			hcl: []string{`
+				ca_file = "ca.pem" cert_file = "cert.pem" key_file = "key.pem"
+				verify_outgoing = true
+				wan_federation { local_gateways = ["1.2.3.4:8443"] }
+			`},
+			err: `wan_federation.local_gateways requires verify_incoming_rpc and verify_outgoing`,
+		},
+		{
+			desc: "wan_federation.primary_gateways without local_gateways",
+			args: []string{
+				`-server`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "wan_federation": { "primary_gateways": ["5.6.7.8:8443"] } }`},
+			hcl:  []string{`wan_federation { primary_gateways = ["5.6.7.8:8443"] }`},
+			err:  `wan_federation.primary_gateways requires wan_federation.local_gateways`,
+		},
+		{
+			desc: "wan_federation gateway without port",
+			args: []string{
+				`-server`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "wan_federation": { "local_gateways": ["1.2.3.4"] } }`},
+			hcl:  []string{`wan_federation { local_gateways = ["1.2.3.4"] }`},
+			err:  `wan_federation gateway "1.2.3.4" must be a host:port address`,
+		},
 		{
 			desc: "acl_datacenter invalid",
 			args: []string{
@@ -1532,6 +1781,16 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 			hcl:  []string{`bootstrap_expect = 3`},
 			err:  "'bootstrap_expect > 0' requires 'server = true'",
 		},
+		{
+			desc: "bootstrap_data_dir without server",
+			args: []string{
+				`-datacenter=a`,
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "bootstrap_data_dir": "/etc/consul.d/bootstrap" }`},
+			hcl:  []string{`bootstrap_data_dir = "/etc/consul.d/bootstrap"`},
+			err:  "'bootstrap_data_dir' requires 'server = true'",
+		},
 		{
 			desc: "bootstrap-expect invalid",
 			args: []string{
@@ -1701,6 +1960,236 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 			hcl:  []string{`recursors = ["::"]`},
 			err:  "DNS recursor address cannot be 0.0.0.0, :: or [::]",
 		},
+		{
+			desc: "change_feed_max_entries invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "change_feed_max_entries": -1 }`},
+			hcl:  []string{`change_feed_max_entries = -1`},
+			err:  "change_feed_max_entries cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "acl.token_unused_period invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "acl": { "token_unused_period": "-1s" } }`},
+			hcl:  []string{`acl = { token_unused_period = "-1s" }`},
+			err:  "acl.token_unused_period cannot be -1s. Must be greater than or equal to zero",
+		},
+		{
+			desc: "acl.token_unused_period too short",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "acl": { "token_unused_period": "2h" } }`},
+			hcl:  []string{`acl = { token_unused_period = "2h" }`},
+			err:  "acl.token_unused_period cannot be 2h0m0s. Must be zero or at least 24h",
+		},
+		{
+			desc: "acl.token_unused_action invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "acl": { "token_unused_action": "disable" } }`},
+			hcl:  []string{`acl = { token_unused_action = "disable" }`},
+			err:  `acl.token_unused_action "disable" is invalid. Must be one of flag or delete`,
+		},
+		{
+			desc: "clock_skew_threshold invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "clock_skew_threshold": "-1s" }`},
+			hcl:  []string{`clock_skew_threshold = "-1s"`},
+			err:  "clock_skew_threshold cannot be -1s. Must be greater than or equal to zero",
+		},
+		{
+			desc: "clock_skew_threshold with disable_coordinates",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "disable_coordinates": true }`},
+			hcl:  []string{`disable_coordinates = true`},
+			patch: func(rt *RuntimeConfig) {
+				rt.DataDir = dataDir
+				rt.DisableCoordinates = true
+			},
+			warns: []string{"disable_coordinates is set, so the leader can't measure the clock skew of this agent"},
+		},
+		{
+			desc: "limits.check_workers invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "check_workers": -1 } }`},
+			hcl:  []string{`limits = { check_workers = -1 }`},
+			err:  "limits.check_workers cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "check_output_max_size invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "check_output_max_size": 0 }`},
+			hcl:  []string{`check_output_max_size = 0`},
+			err:  "check_output_max_size cannot be 0. Must be greater than 0",
+		},
+		{
+			desc: "check_output_sync_limit invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "check_output_sync_limit": -1 }`},
+			hcl:  []string{`check_output_sync_limit = -1`},
+			err:  "check_output_sync_limit cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "http_config.authorizer_url defaults categories",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "http_config": { "authorizer_url": "http://127.0.0.1:9000/authorize" } }`},
+			hcl:  []string{`http_config { authorizer_url = "http://127.0.0.1:9000/authorize" }`},
+			patch: func(rt *RuntimeConfig) {
+				rt.DataDir = dataDir
+				rt.HTTPAuthorizerURL = "http://127.0.0.1:9000/authorize"
+				rt.HTTPAuthorizerCategories = []string{"acl", "keyring", "raft"}
+			},
+		},
+		{
+			desc: "http_config.authorizer_url invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "http_config": { "authorizer_url": "127.0.0.1:9000" } }`},
+			hcl:  []string{`http_config { authorizer_url = "127.0.0.1:9000" }`},
+			err:  `http_config.authorizer_url "127.0.0.1:9000" is invalid. Must be an http or https URL`,
+		},
+		{
+			desc: "http_config.authorizer_categories invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "http_config": { "authorizer_url": "http://127.0.0.1:9000", "authorizer_categories": ["kv"] } }`},
+			hcl:  []string{`http_config { authorizer_url = "http://127.0.0.1:9000" authorizer_categories = ["kv"] }`},
+			err:  `http_config.authorizer_categories has invalid category "kv". Must be one of acl, keyring or raft`,
+		},
+		{
+			desc: "hooks without events",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "hooks": [{ "args": ["/bin/true"] }] }`},
+			hcl:  []string{`hooks = [{ args = ["/bin/true"] }]`},
+			err:  "hooks[0] must have at least one event",
+		},
+		{
+			desc: "hooks with invalid event",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "hooks": [{ "events": ["node_leave"], "args": ["/bin/true"] }] }`},
+			hcl:  []string{`hooks = [{ events = ["node_leave"] args = ["/bin/true"] }]`},
+			err:  `hooks[0] has invalid event "node_leave". Must be one of service_register, service_deregister or check_update`,
+		},
+		{
+			desc: "hooks with args and http",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "hooks": [{ "events": ["check_update"], "args": ["/bin/true"], "http": "http://localhost/" }] }`},
+			hcl:  []string{`hooks = [{ events = ["check_update"] args = ["/bin/true"] http = "http://localhost/" }]`},
+			err:  "hooks[0] must have exactly one of args or http",
+		},
+		{
+			desc: "hooks with http defaults",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "hooks": [{ "events": ["check_update"], "http": "http://localhost/" }] }`},
+			hcl:  []string{`hooks = [{ events = ["check_update"] http = "http://localhost/" }]`},
+			patch: func(rt *RuntimeConfig) {
+				rt.DataDir = dataDir
+				rt.Hooks = []RuntimeHook{
+					{
+						Events:  []string{"check_update"},
+						HTTP:    "http://localhost/",
+						Method:  "POST",
+						Timeout: 10 * time.Second,
+					},
+				}
+			},
+		},
+		{
+			desc: "dev_fixtures without dev mode",
+			args: []string{
+				`-data-dir=` + dataDir,
+				`-dev-fixtures=fixtures.json`,
+			},
+			err: "dev_fixtures is only allowed in dev mode",
+		},
+		{
+			desc: "fault_injection without dev mode",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "fault_injection": [{ "method": "*", "latency": "1s" }] }`},
+			hcl:  []string{`fault_injection = [{ method = "*" latency = "1s" }]`},
+			err:  "fault_injection is only allowed in dev mode",
+		},
+		{
+			desc: "fault_injection invalid error rate",
+			args: []string{
+				`-dev`,
+			},
+			json: []string{`{ "fault_injection": [{ "method": "*", "error_rate": 1.5 }] }`},
+			hcl:  []string{`fault_injection = [{ method = "*" error_rate = 1.5 }]`},
+			err:  "fault_injection[0] is invalid: Error rate cannot be 1.5. Must be between 0 and 1",
+		},
+		{
+			desc: "templates without source",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "templates": [{ "destination": "/tmp/haproxy.cfg" }] }`},
+			hcl:  []string{`templates = [{ destination = "/tmp/haproxy.cfg" }]`},
+			err:  "templates[0] must have a source",
+		},
+		{
+			desc: "templates without destination",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "templates": [{ "source": "/tmp/haproxy.ctmpl" }] }`},
+			hcl:  []string{`templates = [{ source = "/tmp/haproxy.ctmpl" }]`},
+			err:  "templates[0] must have a destination",
+		},
+		{
+			desc: "templates with duplicate destination",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "templates": [
+				{ "source": "/tmp/a.ctmpl", "destination": "/tmp/haproxy.cfg" },
+				{ "source": "/tmp/b.ctmpl", "destination": "/tmp/haproxy.cfg" }
+			] }`},
+			hcl: []string{`templates = [
+				{ source = "/tmp/a.ctmpl" destination = "/tmp/haproxy.cfg" },
+				{ source = "/tmp/b.ctmpl" destination = "/tmp/haproxy.cfg" }
+			]`},
+			err: `templates[1] has duplicate destination "/tmp/haproxy.cfg"`,
+		},
+		{
+			desc: "leave_maintenance_time invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "leave_maintenance_time": "-1s" }`},
+			hcl:  []string{`leave_maintenance_time = "-1s"`},
+			err:  "leave_maintenance_time cannot be -1s. Must be greater than or equal to zero",
+		},
 		{
 			desc: "dns_config.udp_answer_limit invalid",
 			args: []string{
@@ -1719,6 +2208,285 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 			hcl:  []string{`dns_config = { a_record_limit = -1 }`},
 			err:  "dns_config.a_record_limit cannot be -1. Must be greater than or equal to zero",
 		},
+		{
+			desc: "dns_config.node_ttl invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "dns_config": { "node_ttl": "-5s" } }`},
+			hcl:  []string{`dns_config = { node_ttl = "-5s" }`},
+			err:  "dns_config.node_ttl cannot be -5s. Must be greater than or equal to zero",
+		},
+		{
+			desc: "dns_config.cache_max_age invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "dns_config": { "cache_max_age": "-5s" } }`},
+			hcl:  []string{`dns_config = { cache_max_age = "-5s" }`},
+			err:  "dns_config.cache_max_age cannot be -5s. Must be greater than or equal to zero",
+		},
+		{
+			desc: "dns_config.service_ttl wildcard not at end",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "dns_config": { "service_ttl": { "we*b": "5s" } } }`},
+			hcl:  []string{`dns_config = { service_ttl = { "we*b" = "5s" } }`},
+			err:  `dns_config.service_ttl["we*b"] is invalid. The wildcard "*" is only allowed as the last character`,
+		},
+		{
+			desc: "dns_config.service_ttl negative",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "dns_config": { "service_ttl": { "web*": "-5s" } } }`},
+			hcl:  []string{`dns_config = { service_ttl = { "web*" = "-5s" } }`},
+			err:  `dns_config.service_ttl["web*"] cannot be -5s. Must be greater than or equal to zero`,
+		},
+		{
+			desc: "limits.max_blocking_queries invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_blocking_queries": -1 } }`},
+			hcl:  []string{`limits = { max_blocking_queries = -1 }`},
+			err:  "limits.max_blocking_queries cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.max_blocking_queries_per_table invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_blocking_queries_per_table": { "kvs": -1 } } }`},
+			hcl:  []string{`limits = { max_blocking_queries_per_table = { kvs = -1 } }`},
+			err:  `limits.max_blocking_queries_per_table["kvs"] cannot be -1. Must be greater than or equal to zero`,
+		},
+		{
+			desc: "limits.max_blocking_queries_per_token invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_blocking_queries_per_token": -1 } }`},
+			hcl:  []string{`limits = { max_blocking_queries_per_token = -1 }`},
+			err:  "limits.max_blocking_queries_per_token cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "performance.cross_dc_breaker_threshold invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "performance": { "cross_dc_breaker_threshold": -1 } }`},
+			hcl:  []string{`performance = { cross_dc_breaker_threshold = -1 }`},
+			err:  "performance.cross_dc_breaker_threshold cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "performance.cross_dc_breaker_cooldown invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "performance": { "cross_dc_breaker_cooldown": "0s" } }`},
+			hcl:  []string{`performance = { cross_dc_breaker_cooldown = "0s" }`},
+			err:  "performance.cross_dc_breaker_cooldown cannot be 0s. Must be greater than zero",
+		},
+		{
+			desc: "external_node_probe_interval invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "external_node_probe_interval": "0s" }`},
+			hcl:  []string{`external_node_probe_interval = "0s"`},
+			err:  "external_node_probe_interval cannot be 0s. Must be greater than zero",
+		},
+		{
+			desc: "session_lock_delay_min invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "session_lock_delay_min": "-1s" }`},
+			hcl:  []string{`session_lock_delay_min = "-1s"`},
+			err:  "session_lock_delay_min cannot be -1s. Must be greater than or equal to zero",
+		},
+		{
+			desc: "session_lock_delay_max below min",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "session_lock_delay_min": "20s", "session_lock_delay_max": "10s" }`},
+			hcl:  []string{`session_lock_delay_min = "20s" session_lock_delay_max = "10s"`},
+			err:  "session_lock_delay_max cannot be 10s. Must be between session_lock_delay_min and 1m0s",
+		},
+		{
+			desc: "session_lock_delay_max too large",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "session_lock_delay_max": "2m" }`},
+			hcl:  []string{`session_lock_delay_max = "2m"`},
+			err:  "session_lock_delay_max cannot be 2m0s. Must be between session_lock_delay_min and 1m0s",
+		},
+		{
+			desc: "performance.leader_flap_threshold invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "performance": { "leader_flap_threshold": -1 } }`},
+			hcl:  []string{`performance = { leader_flap_threshold = -1 }`},
+			err:  "performance.leader_flap_threshold cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "performance.leader_flap_window invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "performance": { "leader_flap_window": "0s" } }`},
+			hcl:  []string{`performance = { leader_flap_window = "0s" }`},
+			err:  "performance.leader_flap_window cannot be 0s. Must be greater than zero",
+		},
+		{
+			desc: "performance.locality_host_rtt invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "performance": { "locality_host_rtt": "0s" } }`},
+			hcl:  []string{`performance = { locality_host_rtt = "0s" }`},
+			err:  "performance.locality_host_rtt cannot be 0s. Must be greater than zero",
+		},
+		{
+			desc: "performance.locality_region_rtt below zone",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "performance": { "locality_region_rtt": "1ms" } }`},
+			hcl:  []string{`performance = { locality_region_rtt = "1ms" }`},
+			err:  "performance.locality_region_rtt cannot be 1ms. Must be greater than or equal to performance.locality_zone_rtt",
+		},
+		{
+			desc: "limits.max_query_results invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_query_results": -1 } }`},
+			hcl:  []string{`limits = { max_query_results = -1 }`},
+			err:  "limits.max_query_results cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.max_nodes invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_nodes": -1 } }`},
+			hcl:  []string{`limits = { max_nodes = -1 }`},
+			err:  "limits.max_nodes cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.max_services_per_node invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_services_per_node": -2 } }`},
+			hcl:  []string{`limits = { max_services_per_node = -2 }`},
+			err:  "limits.max_services_per_node cannot be -2. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.max_id_length invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_id_length": -1 } }`},
+			hcl:  []string{`limits = { max_id_length = -1 }`},
+			err:  "limits.max_id_length cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "registration_validation invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "registration_validation": "lenient" }`},
+			hcl:  []string{`registration_validation = "lenient"`},
+			err:  `registration_validation must be "compat" or "strict", not "lenient"`,
+		},
+		{
+			desc: "query_source_validation invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "query_source_validation": "verify" }`},
+			hcl:  []string{`query_source_validation = "verify"`},
+			err:  `query_source_validation must be "trust", "override" or "reject", not "verify"`,
+		},
+		{
+			desc: "limits.query_cache_size invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "query_cache_size": -1 } }`},
+			hcl:  []string{`limits = { query_cache_size = -1 }`},
+			err:  "limits.query_cache_size cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.state_memory_budget_mb invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "state_memory_budget_mb": -1 } }`},
+			hcl:  []string{`limits = { state_memory_budget_mb = -1 }`},
+			err:  "limits.state_memory_budget_mb cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.raft_apply_queue_depth invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "raft_apply_queue_depth": -1 } }`},
+			hcl:  []string{`limits = { raft_apply_queue_depth = -1 }`},
+			err:  "limits.raft_apply_queue_depth cannot be -1. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.raft_apply_queue_wait invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "raft_apply_queue_wait": "-1s" } }`},
+			hcl:  []string{`limits = { raft_apply_queue_wait = "-1s" }`},
+			err:  "limits.raft_apply_queue_wait cannot be -1s. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.max_checks_per_node invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "max_checks_per_node": -3 } }`},
+			hcl:  []string{`limits = { max_checks_per_node = -3 }`},
+			err:  "limits.max_checks_per_node cannot be -3. Must be greater than or equal to zero",
+		},
+		{
+			desc: "limits.rpc_server_max_burst invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "rpc_server_max_burst": 0 } }`},
+			hcl:  []string{`limits = { rpc_server_max_burst = 0 }`},
+			err:  "limits.rpc_server_max_burst cannot be 0. Must be greater than zero",
+		},
+		{
+			desc: "limits.reconcile_max_burst invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "reconcile_max_burst": 0 } }`},
+			hcl:  []string{`limits = { reconcile_max_burst = 0 }`},
+			err:  "limits.reconcile_max_burst cannot be 0. Must be greater than zero",
+		},
+		{
+			desc: "limits.reconcile_panic_threshold invalid",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{ "limits": { "reconcile_panic_threshold": 1.5 } }`},
+			hcl:  []string{`limits = { reconcile_panic_threshold = 1.5 }`},
+			err:  "limits.reconcile_panic_threshold cannot be 1.5. Must be between 0 and 1",
+		},
 		{
 			desc: "performance.raft_multiplier < 0",
 			args: []string{
@@ -1886,6 +2654,21 @@ func TestConfigFlagsAndEdgecases(t *testing.T) {
 				`},
 			err: "Serf Advertise WAN address 10.0.0.1:1000 already configured for RPC Advertise",
 		},
+		{
+			desc: "unique bind addresses RPC vs Serf LAN",
+			args: []string{
+				`-data-dir=` + dataDir,
+			},
+			json: []string{`{
+					"ports": { "server": 1000, "serf_lan": 1000 },
+					"advertise_addrs": { "serf_lan": "10.0.0.1:2000" }
+				}`},
+			hcl: []string{`
+					ports = { server = 1000 serf_lan = 1000 }
+					advertise_addrs = { serf_lan = "10.0.0.1:2000" }
+				`},
+			err: "Serf LAN address 0.0.0.0:1000 already configured for RPC",
+		},
 		{
 			desc: "sidecar_service can't have ID",
 			args: []string{
@@ -2863,14 +3646,13 @@ func testConfig(t *testing.T, tests []configTest, dataDir string) {
 // To aid populating the fields the following bash functions can be used
 // to generate random strings and ints:
 //
-//   random-int() { echo $RANDOM }
-//   random-string() { base64 /dev/urandom | tr -d '/+' | fold -w ${1:-32} | head -n 1 }
+//	random-int() { echo $RANDOM }
+//	random-string() { base64 /dev/urandom | tr -d '/+' | fold -w ${1:-32} | head -n 1 }
 //
 // To generate a random string of length 8 run the following command in
 // a terminal:
 //
-//   random-string 8
-//
+//	random-string 8
 func TestFullConfig(t *testing.T) {
 	dataDir := testutil.TempDir(t, "consul")
 	defer os.RemoveAll(dataDir)
@@ -2902,6 +3684,10 @@ func TestFullConfig(t *testing.T) {
 				"policy_ttl": "1123s",
 				"token_ttl": "3321s",
 				"enable_token_replication" : true,
+				"enable_token_persistence": true,
+				"token_rotation_grace": "2357s",
+				"token_unused_period": "92873s",
+				"token_unused_action": "delete",
 				"tokens" : {
 					"master" : "8a19ac27",
 					"agent_master" : "64fd0e08",
@@ -2929,6 +3715,7 @@ func TestFullConfig(t *testing.T) {
 			},
 			"bind_addr": "16.99.34.17",
 			"bootstrap": true,
+			"bootstrap_data_dir": "pE6vw0Lj",
 			"bootstrap_expect": 53,
 			"ca_file": "erA7T0PM",
 			"ca_path": "mQEN1Mfp",
@@ -3004,8 +3791,13 @@ func TestFullConfig(t *testing.T) {
 					"deregister_critical_service_after": "2366s"
 				}
 			],
+			"change_feed_max_entries": 6027,
+			"check_output_max_size": 2914,
+			"check_output_sync_limit": 5093,
+			"check_liveness_interval": "6281s",
 			"check_update_interval": "16507s",
 			"client_addr": "93.83.18.19",
+			"clock_skew_threshold": "3185s",
 			"connect": {
 				"ca_provider": "consul",
 				"ca_config": {
@@ -3042,6 +3834,11 @@ func TestFullConfig(t *testing.T) {
 			},
 			"data_dir": "` + dataDir + `",
 			"datacenter": "rzo029wg",
+			"datacenter_forwarding": {
+				"allow": ["ok5zaxbt", "e0cqapqy"],
+				"deny": ["l63ykxjd"]
+			},
+			"dev_fixtures": "/wD4fN2aq/fixtures.json",
 			"disable_anonymous_signature": true,
 			"disable_coordinates": true,
 			"disable_host_node_id": true,
@@ -3055,39 +3852,100 @@ func TestFullConfig(t *testing.T) {
 			"dns_config": {
 				"allow_stale": true,
 				"a_record_limit": 29907,
+				"cache_max_age": "9210s",
 				"disable_compression": true,
 				"enable_truncate": true,
 				"max_stale": "29685s",
+				"node_tagged_address": "wan",
 				"node_ttl": "7084s",
 				"only_passing": true,
 				"recursor_timeout": "4427s",
 				"service_ttl": {
 					"*": "32030s"
 				},
-				"udp_answer_limit": 29909
+				"udp_answer_limit": 29909,
+				"use_cache": true
 			},
 			"enable_acl_replication": true,
 			"enable_agent_tls_for_checks": true,
 			"enable_debug": true,
 			"enable_script_checks": true,
+			"enable_external_checks": true,
 			"enable_local_script_checks": true,
+			"enable_response_signing": true,
 			"enable_syslog": true,
 			"encrypt": "A4wELWqH",
 			"encrypt_verify_incoming": true,
 			"encrypt_verify_outgoing": true,
+			"external_check_allowed_cidrs": [ "10.0.0.0/8", "192.168.0.0/16" ],
+			"external_node_probe_interval": "28s",
+			"external_node_probe_timeout": "3s",
+			"fault_injection": [
+				{
+					"method": "Catalog.*",
+					"latency": "5102ms",
+					"error_rate": 0.25,
+					"error": "fmTzf9s7"
+				}
+			],
+			"hooks": [
+				{
+					"events": ["service_register", "service_deregister"],
+					"args": ["/bin/UZH9sAu2", "LB6sQWjs"]
+				},
+				{
+					"events": ["check_update"],
+					"http": "http://nsa8ZK2e.local/",
+					"method": "PUT",
+					"header": {
+						"9XMPWwbd": ["Dj0ETa8J"]
+					},
+					"timeout": "4133s",
+					"tls_skip_verify": true
+				}
+			],
 			"http_config": {
 				"block_endpoints": [ "RBvAFcGD", "fWOWFznh" ],
 				"allow_write_http_from": [ "127.0.0.1/8", "22.33.44.55/32", "0.0.0.0/0" ],
+				"authorizer_url": "https://zT4c1wXq.local/authorize",
+				"authorizer_categories": [ "acl", "raft" ],
+				"authorizer_timeout": "3061s",
 				"response_headers": {
 					"M6TKa9NP": "xjuxjOzQ",
 					"JRCrHZed": "rl0mTx81"
 				}
 			},
 			"key_file": "IEkkwgIA",
+			"kv_replication": {
+				"datacenter": "ho2mbrfx",
+				"prefix": "Uq6cBqN9/"
+			},
+			"leave_maintenance_time": "2263s",
 			"leave_on_terminate": true,
 			"limits": {
+				"check_workers": 3377,
+				"max_blocking_queries": 30522,
+				"max_blocking_queries_per_table": { "kvs": 7364, "services": 2219 },
+				"max_blocking_queries_per_token": 4311,
+				"max_checks_per_node": 2217,
+				"max_id_length": 6619,
+				"max_nodes": 53069,
+				"max_query_results": 7315,
+				"max_services_per_node": 1484,
+				"query_cache_size": 6021,
+				"raft_apply_queue_depth": 8130,
+				"raft_apply_queue_wait": "2971s",
 				"rpc_rate": 12029.43,
-				"rpc_max_burst": 44848
+				"rpc_max_burst": 44848,
+				"rpc_server_read_rate": 3851.27,
+				"rpc_server_write_rate": 1094.62,
+				"rpc_server_token_rate": 219.84,
+				"rpc_server_ip_rate": 73.19,
+				"rpc_server_max_burst": 27119,
+				"state_memory_budget_mb": 3317,
+				"reconcile_rate": 361.29,
+				"reconcile_max_burst": 7406,
+				"reconcile_panic_threshold": 0.37
 			},
 			"log_level": "k1zo9Spt",
 			"node_id": "AsUIlw99",
@@ -3098,9 +3956,21 @@ func TestFullConfig(t *testing.T) {
 			"node_name": "otlLxGaI",
 			"non_voting_server": true,
 			"performance": {
+				"cross_dc_breaker_cooldown": "3917s",
+				"cross_dc_breaker_threshold": 8231,
+				"leader_flap_threshold": 7381,
+				"leader_flap_window": "4517s",
 				"leave_drain_time": "8265s",
+				"locality_host_rtt": "2143s",
+				"locality_zone_rtt": "3871s",
+				"locality_region_rtt": "6905s",
 				"raft_multiplier": 5,
-				"rpc_hold_timeout": "15707s"
+				"rpc_hold_timeout": "15707s",
+				"rpc_connection_write_timeout": "9193s",
+				"rpc_keep_alive_interval": "6372s",
+				"rpc_leader_retry_wait": "4729s",
+				"rpc_max_streams": 3819,
+				"rpc_stream_timeout": "27154s"
 			},
 			"pid_file": "43xN80Km",
 			"ports": {
@@ -3112,17 +3982,21 @@ func TestFullConfig(t *testing.T) {
 				"proxy_min_port": 2000,
 				"proxy_max_port": 3000,
 				"sidecar_min_port": 8888,
-				"sidecar_max_port": 9999
+				"sidecar_max_port": 9999,
+				"wan_gateway": 6532
 			},
 			"protocol": 30793,
 			"primary_datacenter": "ejtmd43d",
+			"query_source_validation": "override",
 			"raft_protocol": 19016,
 			"raft_snapshot_threshold": 16384,
 			"raft_snapshot_interval": "30s",
 			"reconnect_timeout": "23739s",
 			"reconnect_timeout_wan": "26694s",
 			"recursors": [ "63.38.39.58", "92.49.18.18" ],
+			"registration_validation": "strict",
 			"rejoin_after_leave": true,
+			"repair_persisted_state": true,
 			"retry_interval": "8067s",
 			"retry_interval_wan": "28866s",
 			"retry_join": [ "pbsSFY7U", "l0qLtWij" ],
@@ -3366,6 +4240,9 @@ func TestFullConfig(t *testing.T) {
 					}
 				}
 			],
+			"services_dir": "/tmp/services.d/Ji9dfo",
+			"session_lock_delay_max": "43s",
+			"session_lock_delay_min": "17s",
 			"session_ttl_min": "26627s",
 			"skip_leave_on_interrupt": true,
 			"start_join": [ "LR3hGDoG", "MwVpZ4Up" ],
@@ -3399,6 +4276,13 @@ func TestFullConfig(t *testing.T) {
 				"statsd_address": "drce87cy",
 				"statsite_address": "HpFwKB8R"
 			},
+			"templates": [
+				{
+					"source": "/etc/Yv7cTq3b.ctmpl",
+					"destination": "/etc/Yv7cTq3b.cfg",
+					"args": ["/bin/Ka2Nf0ps", "reload"]
+				}
+			],
 			"tls_cipher_suites": "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
 			"tls_min_version": "pAOWafkR",
 			"tls_prefer_server_cipher_suites": true,
@@ -3415,6 +4299,10 @@ func TestFullConfig(t *testing.T) {
 			"verify_incoming_rpc": true,
 			"verify_outgoing": true,
 			"verify_server_hostname": true,
+			"wan_federation": {
+				"local_gateways": [ "76.54.21.12:8443", "76.54.21.13:8443" ],
+				"primary_gateways": [ "gw.ejtmd43d.example.com:8443" ]
+			},
 			"watches": [
 				{
 					"type": "key",
@@ -3449,6 +4337,10 @@ func TestFullConfig(t *testing.T) {
 				policy_ttl = "1123s"
 				token_ttl = "3321s"
 				enable_token_replication = true
+				enable_token_persistence = true
+				token_rotation_grace = "2357s"
+				token_unused_period = "92873s"
+				token_unused_action = "delete"
 				tokens = {
 					master = "8a19ac27",
 					agent_master = "64fd0e08",
@@ -3476,6 +4368,7 @@ func TestFullConfig(t *testing.T) {
 			}
 			bind_addr = "16.99.34.17"
 			bootstrap = true
+			bootstrap_data_dir = "pE6vw0Lj"
 			bootstrap_expect = 53
 			ca_file = "erA7T0PM"
 			ca_path = "mQEN1Mfp"
@@ -3551,8 +4444,13 @@ func TestFullConfig(t *testing.T) {
 					deregister_critical_service_after = "2366s"
 				}
 			]
+			change_feed_max_entries = 6027
+			check_output_max_size = 2914
+			check_output_sync_limit = 5093
+			check_liveness_interval = "6281s"
 			check_update_interval = "16507s"
 			client_addr = "93.83.18.19"
+			clock_skew_threshold = "3185s"
 			connect {
 				ca_provider = "consul"
 				ca_config {
@@ -3591,6 +4489,11 @@ func TestFullConfig(t *testing.T) {
 			}
 			data_dir = "` + dataDir + `"
 			datacenter = "rzo029wg"
+			datacenter_forwarding {
+				allow = ["ok5zaxbt", "e0cqapqy"]
+				deny = ["l63ykxjd"]
+			}
+			dev_fixtures = "/wD4fN2aq/fixtures.json"
 			disable_anonymous_signature = true
 			disable_coordinates = true
 			disable_host_node_id = true
@@ -3604,9 +4507,11 @@ func TestFullConfig(t *testing.T) {
 			dns_config {
 				allow_stale = true
 				a_record_limit = 29907
+				cache_max_age = "9210s"
 				disable_compression = true
 				enable_truncate = true
 				max_stale = "29685s"
+				node_tagged_address = "wan"
 				node_ttl = "7084s"
 				only_passing = true
 				recursor_timeout = "4427s"
@@ -3614,29 +4519,91 @@ func TestFullConfig(t *testing.T) {
 					"*" = "32030s"
 				}
 				udp_answer_limit = 29909
+				use_cache = true
 			}
 			enable_acl_replication = true
 			enable_agent_tls_for_checks = true
 			enable_debug = true
 			enable_script_checks = true
+			enable_external_checks = true
 			enable_local_script_checks = true
+			enable_response_signing = true
 			enable_syslog = true
 			encrypt = "A4wELWqH"
 			encrypt_verify_incoming = true
 			encrypt_verify_outgoing = true
+			external_check_allowed_cidrs = [ "10.0.0.0/8", "192.168.0.0/16" ]
+			external_node_probe_interval = "28s"
+			external_node_probe_timeout = "3s"
+			fault_injection = [
+				{
+					method = "Catalog.*"
+					latency = "5102ms"
+					error_rate = 0.25
+					error = "fmTzf9s7"
+				}
+			]
+			hooks = [
+				{
+					events = ["service_register", "service_deregister"]
+					args = ["/bin/UZH9sAu2", "LB6sQWjs"]
+				},
+				{
+					events = ["check_update"]
+					http = "http://nsa8ZK2e.local/"
+					method = "PUT"
+					header = {
+						"9XMPWwbd" = ["Dj0ETa8J"]
+					}
+					timeout = "4133s"
+					tls_skip_verify = true
+				}
+			]
 			http_config {
 				block_endpoints = [ "RBvAFcGD", "fWOWFznh" ]
 				allow_write_http_from = [ "127.0.0.1/8", "22.33.44.55/32", "0.0.0.0/0" ]
+				authorizer_url = "https://zT4c1wXq.local/authorize"
+				authorizer_categories = [ "acl", "raft" ]
+				authorizer_timeout = "3061s"
 				response_headers = {
 					"M6TKa9NP" = "xjuxjOzQ"
 					"JRCrHZed" = "rl0mTx81"
 				}
 			}
 			key_file = "IEkkwgIA"
+			kv_replication {
+				datacenter = "ho2mbrfx"
+				prefix = "Uq6cBqN9/"
+			}
+			leave_maintenance_time = "2263s"
 			leave_on_terminate = true
 			limits {
+				check_workers = 3377
+				max_blocking_queries = 30522
+				max_blocking_queries_per_table {
+					kvs = 7364
+					services = 2219
+				}
+				max_blocking_queries_per_token = 4311
+				max_checks_per_node = 2217
+				max_id_length = 6619
+				max_nodes = 53069
+				max_query_results = 7315
+				max_services_per_node = 1484
+				query_cache_size = 6021
+				raft_apply_queue_depth = 8130
+				raft_apply_queue_wait = "2971s"
 				rpc_rate = 12029.43
 				rpc_max_burst = 44848
+				rpc_server_read_rate = 3851.27
+				rpc_server_write_rate = 1094.62
+				rpc_server_token_rate = 219.84
+				rpc_server_ip_rate = 73.19
+				rpc_server_max_burst = 27119
+				state_memory_budget_mb = 3317
+				reconcile_rate = 361.29
+				reconcile_max_burst = 7406
+				reconcile_panic_threshold = 0.37
 			}
 			log_level = "k1zo9Spt"
 			node_id = "AsUIlw99"
@@ -3647,9 +4614,21 @@ func TestFullConfig(t *testing.T) {
 			node_name = "otlLxGaI"
 			non_voting_server = true
 			performance {
+				cross_dc_breaker_cooldown = "3917s"
+				cross_dc_breaker_threshold = 8231
+				leader_flap_threshold = 7381
+				leader_flap_window = "4517s"
 				leave_drain_time = "8265s"
+				locality_host_rtt = "2143s"
+				locality_zone_rtt = "3871s"
+				locality_region_rtt = "6905s"
 				raft_multiplier = 5
 				rpc_hold_timeout = "15707s"
+				rpc_connection_write_timeout = "9193s"
+				rpc_keep_alive_interval = "6372s"
+				rpc_leader_retry_wait = "4729s"
+				rpc_max_streams = 3819
+				rpc_stream_timeout = "27154s"
 			}
 			pid_file = "43xN80Km"
 			ports {
@@ -3662,16 +4641,20 @@ func TestFullConfig(t *testing.T) {
 				proxy_max_port = 3000
 				sidecar_min_port = 8888
 				sidecar_max_port = 9999
+				wan_gateway = 6532
 			}
 			protocol = 30793
 			primary_datacenter = "ejtmd43d"
+			query_source_validation = "override"
 			raft_protocol = 19016
 			raft_snapshot_threshold = 16384
 			raft_snapshot_interval = "30s"
 			reconnect_timeout = "23739s"
 			reconnect_timeout_wan = "26694s"
 			recursors = [ "63.38.39.58", "92.49.18.18" ]
+			registration_validation = "strict"
 			rejoin_after_leave = true
+			repair_persisted_state = true
 			retry_interval = "8067s"
 			retry_interval_wan = "28866s"
 			retry_join = [ "pbsSFY7U", "l0qLtWij" ]
@@ -3915,6 +4898,9 @@ func TestFullConfig(t *testing.T) {
 					}
 				}
 			]
+			services_dir = "/tmp/services.d/Ji9dfo"
+			session_lock_delay_max = "43s"
+			session_lock_delay_min = "17s"
 			session_ttl_min = "26627s"
 			skip_leave_on_interrupt = true
 			start_join = [ "LR3hGDoG", "MwVpZ4Up" ]
@@ -3948,6 +4934,13 @@ func TestFullConfig(t *testing.T) {
 				statsd_address = "drce87cy"
 				statsite_address = "HpFwKB8R"
 			}
+			templates = [
+				{
+					source = "/etc/Yv7cTq3b.ctmpl"
+					destination = "/etc/Yv7cTq3b.cfg"
+					args = ["/bin/Ka2Nf0ps", "reload"]
+				}
+			]
 			tls_cipher_suites = "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"
 			tls_min_version = "pAOWafkR"
 			tls_prefer_server_cipher_suites = true
@@ -3964,6 +4957,10 @@ func TestFullConfig(t *testing.T) {
 			verify_incoming_rpc = true
 			verify_outgoing = true
 			verify_server_hostname = true
+			wan_federation {
+				local_gateways = [ "76.54.21.12:8443", "76.54.21.13:8443" ]
+				primary_gateways = [ "gw.ejtmd43d.example.com:8443" ]
+			}
 			watches = [{
 				type = "key"
 				datacenter = "GyE6jpeW"
@@ -4116,6 +5113,10 @@ func TestFullConfig(t *testing.T) {
 		ACLPolicyTTL:                     1123 * time.Second,
 		ACLToken:                         "418fdff1",
 		ACLTokenReplication:              true,
+		ACLTokenPersistence:              true,
+		ACLTokenRotationGrace:            2357 * time.Second,
+		ACLTokenUnusedPeriod:             92873 * time.Second,
+		ACLUnusedAction:                  "delete",
 		AdvertiseAddrLAN:                 ipAddr("17.99.29.16"),
 		AdvertiseAddrWAN:                 ipAddr("78.63.37.19"),
 		AutopilotCleanupDeadServers:      true,
@@ -4127,6 +5128,7 @@ func TestFullConfig(t *testing.T) {
 		AutopilotUpgradeVersionTag:       "W9pDwFAL",
 		BindAddr:                         ipAddr("16.99.34.17"),
 		Bootstrap:                        true,
+		BootstrapDataDir:                 "pE6vw0Lj",
 		BootstrapExpect:                  53,
 		CAFile:                           "erA7T0PM",
 		CAPath:                           "mQEN1Mfp",
@@ -4202,8 +5204,14 @@ func TestFullConfig(t *testing.T) {
 				DeregisterCriticalServiceAfter: 13209 * time.Second,
 			},
 		},
+		ChangeFeedMaxEntries:    6027,
+		CheckOutputMaxSize:      2914,
+		CheckOutputSyncLimit:    5093,
 		CheckUpdateInterval:     16507 * time.Second,
+		CheckLivenessInterval:   6281 * time.Second,
+		CheckWorkers:            3377,
 		ClientAddrs:             []*net.IPAddr{ipAddr("93.83.18.19")},
+		ClockSkewThreshold:      3185 * time.Second,
 		ConnectEnabled:          true,
 		ConnectProxyBindMinPort: 2000,
 		ConnectProxyBindMaxPort: 3000,
@@ -4232,6 +5240,7 @@ func TestFullConfig(t *testing.T) {
 		DNSDomain:                        "7W1xXSqd",
 		DNSEnableTruncate:                true,
 		DNSMaxStale:                      29685 * time.Second,
+		DNSNodeTaggedAddress:             "wan",
 		DNSNodeTTL:                       7084 * time.Second,
 		DNSOnlyPassing:                   true,
 		DNSPort:                          7001,
@@ -4241,8 +5250,15 @@ func TestFullConfig(t *testing.T) {
 		DNSServiceTTL:                    map[string]time.Duration{"*": 32030 * time.Second},
 		DNSUDPAnswerLimit:                29909,
 		DNSNodeMetaTXT:                   true,
+		DNSUseCache:                      true,
+		DNSCacheMaxAge:                   9210 * time.Second,
+		CrossDCBreakerCooldown:           3917 * time.Second,
+		CrossDCBreakerThreshold:          8231,
 		DataDir:                          dataDir,
 		Datacenter:                       "rzo029wg",
+		DatacenterForwardingAllow:        []string{"ok5zaxbt", "e0cqapqy"},
+		DatacenterForwardingDeny:         []string{"l63ykxjd"},
+		DevFixtures:                      "/wD4fN2aq/fixtures.json",
 		DevMode:                          true,
 		DisableAnonymousSignature:        true,
 		DisableCoordinates:               true,
@@ -4255,51 +5271,116 @@ func TestFullConfig(t *testing.T) {
 		DiscoveryMaxStale:                5 * time.Second,
 		EnableAgentTLSForChecks:          true,
 		EnableDebug:                      true,
+		EnableExternalChecks:             true,
 		EnableRemoteScriptChecks:         true,
 		EnableLocalScriptChecks:          true,
+		EnableResponseSigning:            true,
 		EnableSyslog:                     true,
 		EnableUI:                         true,
 		EncryptKey:                       "A4wELWqH",
 		EncryptVerifyIncoming:            true,
 		EncryptVerifyOutgoing:            true,
-		GRPCPort:                         4881,
-		GRPCAddrs:                        []net.Addr{tcpAddr("32.31.61.91:4881")},
-		HTTPAddrs:                        []net.Addr{tcpAddr("83.39.91.39:7999")},
-		HTTPBlockEndpoints:               []string{"RBvAFcGD", "fWOWFznh"},
-		AllowWriteHTTPFrom:               []*net.IPNet{cidr("127.0.0.0/8"), cidr("22.33.44.55/32"), cidr("0.0.0.0/0")},
-		HTTPPort:                         7999,
-		HTTPResponseHeaders:              map[string]string{"M6TKa9NP": "xjuxjOzQ", "JRCrHZed": "rl0mTx81"},
-		HTTPSAddrs:                       []net.Addr{tcpAddr("95.17.17.19:15127")},
-		HTTPSPort:                        15127,
-		KeyFile:                          "IEkkwgIA",
-		LeaveDrainTime:                   8265 * time.Second,
-		LeaveOnTerm:                      true,
-		LogLevel:                         "k1zo9Spt",
-		NodeID:                           types.NodeID("AsUIlw99"),
-		NodeMeta:                         map[string]string{"5mgGQMBk": "mJLtVMSG", "A7ynFMJB": "0Nx6RGab"},
-		NodeName:                         "otlLxGaI",
-		NonVotingServer:                  true,
-		PidFile:                          "43xN80Km",
-		PrimaryDatacenter:                "ejtmd43d",
-		RPCAdvertiseAddr:                 tcpAddr("17.99.29.16:3757"),
-		RPCBindAddr:                      tcpAddr("16.99.34.17:3757"),
-		RPCHoldTimeout:                   15707 * time.Second,
-		RPCProtocol:                      30793,
-		RPCRateLimit:                     12029.43,
-		RPCMaxBurst:                      44848,
-		RaftProtocol:                     19016,
-		RaftSnapshotThreshold:            16384,
-		RaftSnapshotInterval:             30 * time.Second,
-		ReconnectTimeoutLAN:              23739 * time.Second,
-		ReconnectTimeoutWAN:              26694 * time.Second,
-		RejoinAfterLeave:                 true,
-		RetryJoinIntervalLAN:             8067 * time.Second,
-		RetryJoinIntervalWAN:             28866 * time.Second,
-		RetryJoinLAN:                     []string{"pbsSFY7U", "l0qLtWij"},
-		RetryJoinMaxAttemptsLAN:          913,
-		RetryJoinMaxAttemptsWAN:          23160,
-		RetryJoinWAN:                     []string{"PFsR02Ye", "rJdQIhER"},
-		SegmentName:                      "BC2NhTDi",
+		ExternalCheckAllowedCIDRs:        []*net.IPNet{cidr("10.0.0.0/8"), cidr("192.168.0.0/16")},
+		ExternalNodeProbeInterval:        28 * time.Second,
+		ExternalNodeProbeTimeout:         3 * time.Second,
+		FaultInjection: []structs.FaultRule{
+			{
+				Method:    "Catalog.*",
+				Latency:   5102 * time.Millisecond,
+				ErrorRate: 0.25,
+				Error:     "fmTzf9s7",
+			},
+		},
+		GRPCPort:  4881,
+		GRPCAddrs: []net.Addr{tcpAddr("32.31.61.91:4881")},
+		Hooks: []RuntimeHook{
+			{
+				Events: []string{"service_register", "service_deregister"},
+				Args:   []string{"/bin/UZH9sAu2", "LB6sQWjs"},
+			},
+			{
+				Events:        []string{"check_update"},
+				HTTP:          "http://nsa8ZK2e.local/",
+				Method:        "PUT",
+				Header:        map[string][]string{"9XMPWwbd": []string{"Dj0ETa8J"}},
+				Timeout:       4133 * time.Second,
+				TLSSkipVerify: true,
+			},
+		},
+		HTTPAddrs:                  []net.Addr{tcpAddr("83.39.91.39:7999")},
+		HTTPAuthorizerURL:          "https://zT4c1wXq.local/authorize",
+		HTTPAuthorizerCategories:   []string{"acl", "raft"},
+		HTTPAuthorizerTimeout:      3061 * time.Second,
+		HTTPBlockEndpoints:         []string{"RBvAFcGD", "fWOWFznh"},
+		AllowWriteHTTPFrom:         []*net.IPNet{cidr("127.0.0.0/8"), cidr("22.33.44.55/32"), cidr("0.0.0.0/0")},
+		HTTPPort:                   7999,
+		HTTPResponseHeaders:        map[string]string{"M6TKa9NP": "xjuxjOzQ", "JRCrHZed": "rl0mTx81"},
+		HTTPSAddrs:                 []net.Addr{tcpAddr("95.17.17.19:15127")},
+		HTTPSPort:                  15127,
+		KeyFile:                    "IEkkwgIA",
+		KVReplicationDatacenter:    "ho2mbrfx",
+		KVReplicationPrefix:        "Uq6cBqN9/",
+		LeaderFlapThreshold:        7381,
+		LeaderFlapWindow:           4517 * time.Second,
+		LeaveDrainTime:             8265 * time.Second,
+		LeaveMaintenanceTime:       2263 * time.Second,
+		LeaveOnTerm:                true,
+		LocalityHostRTT:            2143 * time.Second,
+		LocalityRegionRTT:          6905 * time.Second,
+		LocalityZoneRTT:            3871 * time.Second,
+		LogLevel:                   "k1zo9Spt",
+		MaxBlockingQueries:         30522,
+		MaxBlockingQueriesPerTable: map[string]int{"kvs": 7364, "services": 2219},
+		MaxBlockingQueriesPerToken: 4311,
+		MaxChecksPerNode:           2217,
+		MaxIDLength:                6619,
+		MaxNodes:                   53069,
+		MaxQueryResults:            7315,
+		MaxServicesPerNode:         1484,
+		NodeID:                     types.NodeID("AsUIlw99"),
+		NodeMeta:                   map[string]string{"5mgGQMBk": "mJLtVMSG", "A7ynFMJB": "0Nx6RGab"},
+		NodeName:                   "otlLxGaI",
+		NonVotingServer:            true,
+		PidFile:                    "43xN80Km",
+		PrimaryDatacenter:          "ejtmd43d",
+		QueryCacheSize:             6021,
+		QuerySourceValidation:      "override",
+		RPCAdvertiseAddr:           tcpAddr("17.99.29.16:3757"),
+		RPCBindAddr:                tcpAddr("16.99.34.17:3757"),
+		RPCConnectionWriteTimeout:  9193 * time.Second,
+		RPCHoldTimeout:             15707 * time.Second,
+		RPCKeepAliveInterval:       6372 * time.Second,
+		RPCLeaderRetryWait:         4729 * time.Second,
+		RPCMaxStreams:              3819,
+		RPCStreamTimeout:           27154 * time.Second,
+		RPCProtocol:                30793,
+		RPCRateLimit:               12029.43,
+		RPCMaxBurst:                44848,
+		RPCServerReadRate:          3851.27,
+		RPCServerWriteRate:         1094.62,
+		RPCServerTokenRate:         219.84,
+		RPCServerIPRate:            73.19,
+		RPCServerMaxBurst:          27119,
+		RaftApplyQueueDepth:        8130,
+		RaftApplyQueueWait:         2971 * time.Second,
+		RaftProtocol:               19016,
+		ReconcileMaxBurst:          7406,
+		ReconcilePanicThreshold:    0.37,
+		ReconcileRate:              361.29,
+		RaftSnapshotThreshold:      16384,
+		RaftSnapshotInterval:       30 * time.Second,
+		ReconnectTimeoutLAN:        23739 * time.Second,
+		ReconnectTimeoutWAN:        26694 * time.Second,
+		RegistrationValidation:     "strict",
+		RejoinAfterLeave:           true,
+		RepairPersistedState:       true,
+		RetryJoinIntervalLAN:       8067 * time.Second,
+		RetryJoinIntervalWAN:       28866 * time.Second,
+		RetryJoinLAN:               []string{"pbsSFY7U", "l0qLtWij"},
+		RetryJoinMaxAttemptsLAN:    913,
+		RetryJoinMaxAttemptsWAN:    23160,
+		RetryJoinWAN:               []string{"PFsR02Ye", "rJdQIhER"},
+		SegmentName:                "BC2NhTDi",
 		Segments: []structs.NetworkSegment{
 			{
 				Name:        "PExYMe2E",
@@ -4557,10 +5638,14 @@ func TestFullConfig(t *testing.T) {
 		SerfAdvertiseAddrWAN: tcpAddr("78.63.37.19:8302"),
 		SerfBindAddrLAN:      tcpAddr("99.43.63.15:8301"),
 		SerfBindAddrWAN:      tcpAddr("67.88.33.19:8302"),
+		ServicesDir:          "/tmp/services.d/Ji9dfo",
+		SessionLockDelayMax:  43 * time.Second,
+		SessionLockDelayMin:  17 * time.Second,
 		SessionTTLMin:        26627 * time.Second,
 		SkipLeaveOnInt:       true,
 		StartJoinAddrsLAN:    []string{"LR3hGDoG", "MwVpZ4Up"},
 		StartJoinAddrsWAN:    []string{"EbFSc3nA", "kwXTh623"},
+		StateMemoryBudgetMB:  3317,
 		SyslogFacility:       "hHv79Uia",
 		Telemetry: lib.TelemetryConfig{
 			CirconusAPIApp:                     "p4QOTe9j",
@@ -4596,16 +5681,27 @@ func TestFullConfig(t *testing.T) {
 			"lan":      "17.99.29.16",
 			"wan":      "78.63.37.19",
 		},
-		TranslateWANAddrs:    true,
-		UIDir:                "11IFzAUn",
-		UnixSocketUser:       "E0nB1DwA",
-		UnixSocketGroup:      "8pFodrV8",
-		UnixSocketMode:       "E8sAwOv4",
-		VerifyIncoming:       true,
-		VerifyIncomingHTTPS:  true,
-		VerifyIncomingRPC:    true,
-		VerifyOutgoing:       true,
-		VerifyServerHostname: true,
+		Templates: []RuntimeTemplate{
+			{
+				Source:      "/etc/Yv7cTq3b.ctmpl",
+				Destination: "/etc/Yv7cTq3b.cfg",
+				Args:        []string{"/bin/Ka2Nf0ps", "reload"},
+			},
+		},
+		TranslateWANAddrs:            true,
+		UIDir:                        "11IFzAUn",
+		UnixSocketUser:               "E0nB1DwA",
+		UnixSocketGroup:              "8pFodrV8",
+		UnixSocketMode:               "E8sAwOv4",
+		VerifyIncoming:               true,
+		VerifyIncomingHTTPS:          true,
+		VerifyIncomingRPC:            true,
+		VerifyOutgoing:               true,
+		VerifyServerHostname:         true,
+		WANFederationLocalGateways:   []string{"76.54.21.12:8443", "76.54.21.13:8443"},
+		WANFederationPrimaryGateways: []string{"gw.ejtmd43d.example.com:8443"},
+		WANGatewayBindAddr:           tcpAddr("16.99.34.17:6532"),
+		WANGatewayPort:               6532,
 		Watches: []map[string]interface{}{
 			map[string]interface{}{
 				"type":       "key",
@@ -4625,6 +5721,7 @@ func TestFullConfig(t *testing.T) {
 	warns := []string{
 		`The 'acl_datacenter' field is deprecated. Use the 'primary_datacenter' field instead.`,
 		`bootstrap_expect > 0: expecting 53 servers`,
+		`disable_coordinates is set, so the leader can't measure the clock skew of this agent`,
 	}
 
 	// ensure that all fields are set to unique non-zero values
@@ -4678,8 +5775,10 @@ func TestFullConfig(t *testing.T) {
 			// all fields with non-zero values and to have a valid configuration
 			// we are patching a handful of safe fields to make validation pass.
 			rt.Bootstrap = false
+			rt.DevFixtures = ""
 			rt.DevMode = false
 			rt.EnableUI = false
+			rt.FaultInjection = nil
 			rt.SegmentName = ""
 			rt.Segments = nil
 
@@ -4928,9 +6027,13 @@ func TestSanitize(t *testing.T) {
 		"ACLMasterToken": "hidden",
 		"ACLPolicyTTL": "0s",
 		"ACLReplicationToken": "hidden",
+		"ACLTokenPersistence": false,
 		"ACLTokenReplication": false,
+		"ACLTokenRotationGrace": "0s",
 		"ACLTokenTTL": "0s",
+		"ACLTokenUnusedPeriod": "0s",
 		"ACLToken": "hidden",
+		"ACLUnusedAction": "",
 		"ACLsEnabled": false,
 		"AEInterval": "0s",
 		"AdvertiseAddrLAN": "",
@@ -4944,13 +6047,19 @@ func TestSanitize(t *testing.T) {
 		"AutopilotUpgradeVersionTag": "",
 		"BindAddr": "127.0.0.1",
 		"Bootstrap": false,
+		"BootstrapDataDir": "",
 		"BootstrapExpect": 0,
 		"CAFile": "",
 		"CAPath": "",
 		"CertFile": "",
+		"ChangeFeedMaxEntries": 0,
 		"CheckDeregisterIntervalMin": "0s",
+		"CheckLivenessInterval": "0s",
+		"CheckOutputMaxSize": 0,
+		"CheckOutputSyncLimit": 0,
 		"CheckReapInterval": "0s",
 		"CheckUpdateInterval": "0s",
+		"CheckWorkers": 0,
 		"Checks": [{
 			"AliasNode": "",
 			"AliasService": "",
@@ -4976,6 +6085,7 @@ func TestSanitize(t *testing.T) {
 			"Token": "hidden"
 		}],
 		"ClientAddrs": [],
+		"ClockSkewThreshold": "0s",
 		"ConnectCAConfig": {},
 		"ConnectCAProvider": "",
 		"ConnectEnabled": false,
@@ -5011,18 +6121,22 @@ func TestSanitize(t *testing.T) {
 		"GossipWANRetransmitMult": 0,
 		"GossipWANSuspicionMult": 0,
 		"ConsulServerHealthInterval": "0s",
+		"CrossDCBreakerCooldown": "0s",
+		"CrossDCBreakerThreshold": 0,
 		"DNSARecordLimit": 0,
 		"DNSAddrs": [
 			"tcp://1.2.3.4:5678",
 			"udp://1.2.3.4:5678"
 		],
 		"DNSAllowStale": false,
+		"DNSCacheMaxAge": "0s",
 		"DNSDisableCompression": false,
 		"DNSDomain": "",
 		"DNSEnableTruncate": false,
 		"DNSMaxStale": "0s",
 		"DNSNodeMetaTXT": false,
 		"DNSNodeTTL": "0s",
+		"DNSNodeTaggedAddress": "",
 		"DNSOnlyPassing": false,
 		"DNSPort": 0,
 		"DNSRecursorTimeout": "0s",
@@ -5035,8 +6149,12 @@ func TestSanitize(t *testing.T) {
 			"Minttl": 0
 		},
 		"DNSUDPAnswerLimit": 0,
+		"DNSUseCache": false,
 		"DataDir": "",
 		"Datacenter": "",
+		"DatacenterForwardingAllow": [],
+		"DatacenterForwardingDeny": [],
+		"DevFixtures": "",
 		"DevMode": false,
 		"DisableAnonymousSignature": false,
 		"DisableCoordinates": false,
@@ -5049,49 +6167,94 @@ func TestSanitize(t *testing.T) {
 		"DiscoveryMaxStale": "0s",
 		"EnableAgentTLSForChecks": false,
 		"EnableDebug": false,
+		"EnableExternalChecks": false,
 		"EnableLocalScriptChecks": false,
 		"EnableRemoteScriptChecks": false,
+		"EnableResponseSigning": false,
 		"EnableSyslog": false,
 		"EnableUI": false,
 		"EncryptKey": "hidden",
 		"EncryptVerifyIncoming": false,
 		"EncryptVerifyOutgoing": false,
+		"ExternalCheckAllowedCIDRs": [],
+		"ExternalNodeProbeInterval": "0s",
+		"ExternalNodeProbeTimeout": "0s",
+		"FaultInjection": [],
 		"GRPCAddrs": [],
 		"GRPCPort": 0,
+		"Hooks": [],
 		"HTTPAddrs": [
 			"tcp://1.2.3.4:5678",
 			"unix:///var/run/foo"
 		],
+		"HTTPAuthorizerCategories": [],
+		"HTTPAuthorizerTimeout": "0s",
+		"HTTPAuthorizerURL": "",
 		"HTTPBlockEndpoints": [],
 		"HTTPPort": 0,
 		"HTTPResponseHeaders": {},
 		"HTTPSAddrs": [],
 		"HTTPSPort": 0,
+		"KVReplicationDatacenter": "",
+		"KVReplicationPrefix": "",
 		"KeyFile": "hidden",
+		"LeaderFlapThreshold": 0,
+		"LeaderFlapWindow": "0s",
 		"LeaveDrainTime": "0s",
+		"LeaveMaintenanceTime": "0s",
 		"LeaveOnTerm": false,
+		"LocalityHostRTT": "0s",
+		"LocalityRegionRTT": "0s",
+		"LocalityZoneRTT": "0s",
 		"LogLevel": "",
 		"LogFile": "",
 		"LogRotateBytes": 0,
 		"LogRotateDuration": "0s",
+		"MaxBlockingQueries": 0,
+		"MaxBlockingQueriesPerTable": {},
+		"MaxBlockingQueriesPerToken": 0,
+		"MaxChecksPerNode": 0,
+		"MaxIDLength": 0,
+		"MaxNodes": 0,
+		"MaxQueryResults": 0,
+		"MaxServicesPerNode": 0,
 		"NodeID": "",
 		"NodeMeta": {},
 		"NodeName": "",
 		"NonVotingServer": false,
 		"PidFile": "",
 		"PrimaryDatacenter": "",
+		"QueryCacheSize": 0,
+		"QuerySourceValidation": "",
 		"RPCAdvertiseAddr": "",
 		"RPCBindAddr": "",
+		"RPCConnectionWriteTimeout": "0s",
 		"RPCHoldTimeout": "0s",
+		"RPCKeepAliveInterval": "0s",
+		"RPCLeaderRetryWait": "0s",
 		"RPCMaxBurst": 0,
+		"RPCMaxStreams": 0,
 		"RPCProtocol": 0,
 		"RPCRateLimit": 0,
+		"RPCServerMaxBurst": 0,
+		"RPCServerIPRate": 0,
+		"RPCServerReadRate": 0,
+		"RPCServerTokenRate": 0,
+		"RPCServerWriteRate": 0,
+		"RPCStreamTimeout": "0s",
+		"RaftApplyQueueDepth": 0,
+		"RaftApplyQueueWait": "0s",
 		"RaftProtocol": 0,
 		"RaftSnapshotInterval": "0s",
 		"RaftSnapshotThreshold": 0,
+		"ReconcileMaxBurst": 0,
+		"ReconcilePanicThreshold": 0,
+		"ReconcileRate": 0,
 		"ReconnectTimeoutLAN": "0s",
 		"ReconnectTimeoutWAN": "0s",
+		"RegistrationValidation": "",
 		"RejoinAfterLeave": false,
+		"RepairPersistedState": false,
 		"RetryJoinIntervalLAN": "0s",
 		"RetryJoinIntervalWAN": "0s",
 		"RetryJoinLAN": [
@@ -5157,10 +6320,14 @@ func TestSanitize(t *testing.T) {
 				"Warning": 3
 			}
 		}],
+		"ServicesDir": "",
+		"SessionLockDelayMax": "0s",
+		"SessionLockDelayMin": "0s",
 		"SessionTTLMin": "0s",
 		"SkipLeaveOnInt": false,
 		"StartJoinAddrsLAN": [],
 		"StartJoinAddrsWAN": [],
+		"StateMemoryBudgetMB": 0,
 		"SyncCoordinateIntervalMin": "0s",
 		"SyncCoordinateRateTarget": 0,
 		"SyslogFacility": "",
@@ -5193,6 +6360,7 @@ func TestSanitize(t *testing.T) {
 			"StatsdAddr": "",
 			"StatsiteAddr": ""
 		},
+		"Templates": [],
 		"TranslateWANAddrs": false,
 		"UIDir": "",
 		"UnixSocketGroup": "",
@@ -5205,6 +6373,10 @@ func TestSanitize(t *testing.T) {
 		"VerifyServerHostname": false,
 		"Version": "",
 		"VersionPrerelease": "",
+		"WANFederationLocalGateways": [],
+		"WANFederationPrimaryGateways": [],
+		"WANGatewayBindAddr": "",
+		"WANGatewayPort": 0,
 		"Watches": [],
 		"AllowWriteHTTPFrom": []
 	}`
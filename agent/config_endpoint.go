@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/consul"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// GET /v1/config
+func (s *HTTPServer) ConfigEntryList(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	return s.configEntryList("", resp, req)
+}
+
+// ConfigEntrySpecific handles listing the config entries of a kind at
+// /v1/config/:kind, and the GET, PUT and DELETE operations on a single entry
+// at /v1/config/:kind/:name.
+func (s *HTTPServer) ConfigEntrySpecific(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/v1/config/")
+	parts := strings.SplitN(path, "/", 2)
+	kind := parts[0]
+	if kind == "" {
+		return nil, BadRequestError{Reason: "Missing config entry kind"}
+	}
+
+	if len(parts) == 1 {
+		if req.Method != "GET" {
+			return nil, MethodNotAllowedError{req.Method, []string{"GET"}}
+		}
+		return s.configEntryList(kind, resp, req)
+	}
+
+	name := parts[1]
+	if name == "" {
+		return nil, BadRequestError{Reason: "Missing config entry name"}
+	}
+	switch req.Method {
+	case "GET":
+		return s.configEntryGet(kind, name, resp, req)
+	case "PUT":
+		return s.configEntryApply(structs.ConfigEntryOpUpsert, kind, name, resp, req)
+	case "DELETE":
+		return s.configEntryApply(structs.ConfigEntryOpDelete, kind, name, resp, req)
+	default:
+		return nil, MethodNotAllowedError{req.Method, []string{"GET", "PUT", "DELETE"}}
+	}
+}
+
+// GET /v1/config and /v1/config/:kind
+func (s *HTTPServer) configEntryList(kind string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ConfigEntryQuery{
+		Kind: kind,
+	}
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedConfigEntries
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("ConfigEntry.List", &args, &reply); err != nil {
+		return nil, err
+	}
+	for _, entry := range reply.Entries {
+		entry.NormalizeConfig()
+	}
+	return reply.Entries, nil
+}
+
+// GET /v1/config/:kind/:name
+func (s *HTTPServer) configEntryGet(kind, name string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ConfigEntryQuery{
+		Kind: kind,
+		Name: name,
+	}
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedConfigEntries
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("ConfigEntry.Get", &args, &reply); err != nil {
+		// We have to check the string since the RPC sheds the error type
+		if err.Error() == consul.ErrConfigEntryNotFound.Error() {
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(resp, err.Error())
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// This shouldn't happen since the RPC returns an error if the entry
+	// doesn't exist.
+	if len(reply.Entries) != 1 {
+		return nil, fmt.Errorf("internal error loading config entry")
+	}
+	reply.Entries[0].NormalizeConfig()
+	return reply.Entries[0], nil
+}
+
+// PUT and DELETE /v1/config/:kind/:name
+func (s *HTTPServer) configEntryApply(op structs.ConfigEntryOp, kind, name string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ConfigEntryRequest{
+		Op:    op,
+		Entry: &structs.ConfigEntry{},
+	}
+	s.parseDC(req, &args.Datacenter)
+	s.parseToken(req, &args.Token)
+
+	if op == structs.ConfigEntryOpUpsert {
+		if err := decodeBody(req, args.Entry, nil); err != nil {
+			return nil, BadRequestError{Reason: fmt.Sprintf("Request decode failed: %v", err)}
+		}
+	}
+	if (args.Entry.Kind != "" && args.Entry.Kind != kind) || (args.Entry.Name != "" && args.Entry.Name != name) {
+		return nil, BadRequestError{Reason: "Config entry kind and name in URL and payload do not match"}
+	}
+	args.Entry.Kind = kind
+	args.Entry.Name = name
+
+	var reply struct{}
+	if err := s.agent.RPC("ConfigEntry.Apply", &args, &reply); err != nil {
+		if strings.Contains(err.Error(), "Must provide") || strings.Contains(err.Error(), "Invalid config entry kind") {
+			return nil, BadRequestError{Reason: err.Error()}
+		}
+		return nil, err
+	}
+	return true, nil
+}
@@ -37,6 +37,10 @@ const (
 	// in size but should be sufficiently large to handle 1 replication round in a single batch
 	aclBatchDeleteSize = 4096
 
+	// aclTokenReapMaxWait is the longest the leader waits between looking
+	// for expired tokens, in case the clock jumps.
+	aclTokenReapMaxWait = time.Hour
+
 	// aclBatchUpsertSize is the target size in bytes we want to submit for a batch upsert request. We estimate the size at runtime
 	// due to the data being more variable in its size.
 	aclBatchUpsertSize = 256 * 1024
@@ -683,6 +687,12 @@ func (r *ACLResolver) resolveTokenToPolicies(token string) (structs.ACLPolicies,
 		return nil, acl.ErrNotFound
 	}
 
+	// Tokens created by logging in stop working when they expire, even
+	// before the leader reaps them.
+	if t, ok := identity.(*structs.ACLToken); ok && t.IsExpired(time.Now()) {
+		return nil, acl.ErrNotFound
+	}
+
 	// Resolve the ACLIdentity to ACLPolicies
 	return r.resolvePoliciesForIdentity(identity)
 }
@@ -1304,6 +1314,30 @@ func vetRegisterWithACL(rule acl.Authorizer, subj *structs.RegisterRequest,
 	return nil
 }
 
+// vetCheckUpdateWithACL applies the given ACL's policy to a check status
+// update. Like registering the check, it needs write access to the check's
+// service, or to the node for node-level checks.
+func vetCheckUpdateWithACL(rule acl.Authorizer, subj *structs.CheckUpdateRequest,
+	nc *structs.HealthCheck) error {
+
+	// Fast path if ACLs are not enabled.
+	if rule == nil {
+		return nil
+	}
+
+	if nc.ServiceID != "" {
+		if !rule.ServiceWrite(nc.ServiceName, nil) {
+			return acl.ErrPermissionDenied
+		}
+	} else {
+		if !rule.NodeWrite(subj.Node, nil) {
+			return acl.ErrPermissionDenied
+		}
+	}
+
+	return nil
+}
+
 // vetDeregisterWithACL applies the given ACL's policy to the catalog update and
 // determines if it is allowed. Since the catalog deregister request is so
 // dynamic, this is a pretty complex algorithm and was worth breaking out of the
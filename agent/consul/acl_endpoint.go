@@ -203,7 +203,7 @@ func (a *ACL) TokenRead(args *structs.ACLTokenGetRequest, reply *structs.ACLToke
 		}
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions, &reply.QueryMeta,
+	return a.srv.blockingQuery("acl-tokens", &args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			var index uint64
 			var token *structs.ACLToken
@@ -215,7 +215,12 @@ func (a *ACL) TokenRead(args *structs.ACLTokenGetRequest, reply *structs.ACLToke
 					a.srv.filterACLWithAuthorizer(rule, &token)
 				}
 			} else {
+				// Client agents resolve tokens by reading them by secret,
+				// so this counts as using the token.
 				index, token, err = state.ACLTokenGetBySecret(ws, args.TokenID)
+				if token != nil {
+					a.srv.recordACLTokenUsage(token)
+				}
 			}
 
 			if err != nil {
@@ -307,6 +312,10 @@ func (a *ACL) TokenSet(args *structs.ACLTokenSetRequest, reply *structs.ACLToken
 		return acl.ErrPermissionDenied
 	}
 
+	if args.ACLToken.AccessorID == "" && (args.ACLToken.AuthMethod != "" || args.ACLToken.ExpirationTime != nil) {
+		return fmt.Errorf("AuthMethod and ExpirationTime can only be set by logging in")
+	}
+
 	return a.tokenSetInternal(args, reply, false)
 }
 
@@ -381,6 +390,10 @@ func (a *ACL) tokenSetInternal(args *structs.ACLTokenSetRequest, reply *structs.
 		} else {
 			token.CreateTime = existing.CreateTime
 		}
+
+		// Tokens created by logging in keep their auth method and expiry
+		token.AuthMethod = existing.AuthMethod
+		token.ExpirationTime = existing.ExpirationTime
 	}
 
 	policyIDs := make(map[string]struct{})
@@ -543,22 +556,98 @@ func (a *ACL) TokenList(args *structs.ACLTokenListRequest, reply *structs.ACLTok
 		return acl.ErrPermissionDenied
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions, &reply.QueryMeta,
+	return a.srv.blockingQuery("acl-tokens", &args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, tokens, err := state.ACLTokenList(ws, args.IncludeLocal, args.IncludeGlobal, args.Policy)
 			if err != nil {
 				return err
 			}
 
+			accessors := make([]string, 0, len(tokens))
+			for _, token := range tokens {
+				accessors = append(accessors, token.AccessorID)
+			}
+			usage, _, err := state.ACLTokenUsageGet(accessors)
+			if err != nil {
+				return err
+			}
+
 			stubs := make([]*structs.ACLTokenListStub, 0, len(tokens))
 			for _, token := range tokens {
-				stubs = append(stubs, token.Stub())
+				stub := token.Stub()
+				if used, ok := usage[token.AccessorID]; ok {
+					stub.LastUsed = &used
+				}
+				stubs = append(stubs, stub)
 			}
 			reply.Index, reply.Tokens = index, stubs
 			return nil
 		})
 }
 
+// TokenUsageUpdate records when tokens were last used. Servers send it with
+// the usage of the tokens they resolved. Usage that's within
+// aclTokenUsageResolution of what's already recorded is dropped, so
+// frequently used tokens don't cause a Raft write every time.
+func (a *ACL) TokenUsageUpdate(args *structs.ACLTokenUsageRequest, reply *struct{}) error {
+	if err := a.aclPreCheck(); err != nil {
+		return err
+	}
+
+	if done, err := a.srv.forward("ACL.TokenUsageUpdate", args, args, reply); done {
+		return err
+	}
+
+	defer metrics.MeasureSince([]string{"acl", "token", "usage_update"}, time.Now())
+
+	rule, err := a.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	} else if rule != nil && !rule.NodeWrite(args.Node, nil) {
+		return acl.ErrPermissionDenied
+	}
+	a.srv.recordACLTokenUsageReport(args.SourceDatacenter)
+	if len(args.Usage) == 0 {
+		return nil
+	}
+
+	accessors := make([]string, 0, len(args.Usage))
+	for _, u := range args.Usage {
+		accessors = append(accessors, u.AccessorID)
+	}
+	recorded, _, err := a.srv.fsm.State().ACLTokenUsageGet(accessors)
+	if err != nil {
+		return err
+	}
+
+	// Usage can't be in the future, whatever the reporting server's clock
+	// says, or a token could be kept from ever looking unused.
+	now := time.Now().UTC()
+	var usage []*structs.ACLTokenUsage
+	for _, u := range args.Usage {
+		if u.LastUsed.After(now) {
+			u.LastUsed = now
+		}
+		if last, ok := recorded[u.AccessorID]; ok && u.LastUsed.Sub(last) < aclTokenUsageResolution {
+			continue
+		}
+		usage = append(usage, u)
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+	args.Usage = usage
+
+	resp, err := a.srv.raftApply(structs.ACLTokenUsageRequestType, args)
+	if err != nil {
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
+
 func (a *ACL) TokenBatchRead(args *structs.ACLTokenBatchGetRequest, reply *structs.ACLTokenBatchResponse) error {
 	if err := a.aclPreCheck(); err != nil {
 		return err
@@ -579,7 +668,7 @@ func (a *ACL) TokenBatchRead(args *structs.ACLTokenBatchGetRequest, reply *struc
 		return acl.ErrPermissionDenied
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions, &reply.QueryMeta,
+	return a.srv.blockingQuery("acl-tokens", &args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, tokens, err := state.ACLTokenBatchGet(ws, args.AccessorIDs)
 			if err != nil {
@@ -608,7 +697,7 @@ func (a *ACL) PolicyRead(args *structs.ACLPolicyGetRequest, reply *structs.ACLPo
 		return acl.ErrPermissionDenied
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions, &reply.QueryMeta,
+	return a.srv.blockingQuery("acl-policies", &args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, policy, err := state.ACLPolicyGetByID(ws, args.PolicyID)
 
@@ -636,7 +725,7 @@ func (a *ACL) PolicyBatchRead(args *structs.ACLPolicyBatchGetRequest, reply *str
 		return acl.ErrPermissionDenied
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions, &reply.QueryMeta,
+	return a.srv.blockingQuery("acl-policies", &args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, policies, err := state.ACLPolicyBatchGet(ws, args.PolicyIDs)
 			if err != nil {
@@ -841,7 +930,7 @@ func (a *ACL) PolicyList(args *structs.ACLPolicyListRequest, reply *structs.ACLP
 		return acl.ErrPermissionDenied
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions, &reply.QueryMeta,
+	return a.srv.blockingQuery("acl-policies", &args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, policies, err := state.ACLPolicyList(ws)
 			if err != nil {
@@ -202,7 +202,7 @@ func (a *ACL) Get(args *structs.ACLSpecificRequest,
 		return acl.ErrDisabled
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions,
+	return a.srv.blockingQuery("acl-tokens", &args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, token, err := state.ACLTokenGetBySecret(ws, args.ACL)
@@ -246,7 +246,7 @@ func (a *ACL) List(args *structs.DCSpecificRequest,
 		return acl.ErrPermissionDenied
 	}
 
-	return a.srv.blockingQuery(&args.QueryOptions,
+	return a.srv.blockingQuery("acl-tokens", &args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, tokens, err := state.ACLTokenList(ws, false, true, "")
@@ -0,0 +1,237 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// Login exchanges a credential issued by a third party for a local ACL token.
+// The credential is checked by the auth method named in the request, and the
+// token gets the policies chosen by the auth method's binding rules. Logging
+// in doesn't need a token.
+func (a *ACL) Login(args *structs.ACLLoginRequest, reply *structs.ACLToken) error {
+	if err := a.aclPreCheck(); err != nil {
+		return err
+	}
+	if !a.srv.LocalTokensEnabled() {
+		return fmt.Errorf("Local tokens are disabled")
+	}
+	if args.Auth == nil {
+		return fmt.Errorf("Missing login details")
+	}
+
+	if done, err := a.srv.forward("ACL.Login", args, args, reply); done {
+		return err
+	}
+
+	// Older servers don't know tokens can expire, so they'd keep resolving
+	// the token after its expiration time.
+	if err := a.srv.checkDatacenterFeature(metadata.FeatureACLLogin, "ACL login"); err != nil {
+		return err
+	}
+
+	defer metrics.MeasureSince([]string{"acl", "login"}, time.Now())
+
+	_, entry, err := a.srv.fsm.State().ConfigEntry(nil, structs.ACLAuthMethod, args.Auth.AuthMethod)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return acl.ErrNotFound
+	}
+	method, err := structs.ParseACLAuthMethodConfig(entry.Config)
+	if err != nil {
+		return err
+	}
+
+	vars, err := verifyLoginJWT(method, args.Auth.BearerToken, time.Now())
+	if err != nil {
+		a.srv.logger.Printf("[WARN] consul.acl: Failed to log in to auth method %q: %v", args.Auth.AuthMethod, err)
+		return acl.ErrPermissionDenied
+	}
+
+	// Only grant the policies that exist, so that rules can refer to
+	// policies that are only created for some workloads.
+	state := a.srv.fsm.State()
+	var policies []structs.ACLTokenPolicyLink
+	for _, name := range method.BindPolicies(vars) {
+		_, policy, err := state.ACLPolicyGetByName(nil, name)
+		if err != nil {
+			return err
+		}
+		if policy != nil {
+			policies = append(policies, structs.ACLTokenPolicyLink{ID: policy.ID})
+		}
+	}
+	if len(policies) == 0 {
+		return acl.ErrPermissionDenied
+	}
+
+	description := fmt.Sprintf("Token created by logging in to auth method %q", args.Auth.AuthMethod)
+	if len(args.Auth.Meta) > 0 {
+		meta, err := json.Marshal(args.Auth.Meta)
+		if err != nil {
+			return err
+		}
+		description += " with " + string(meta)
+	}
+
+	expiration := time.Now().Add(method.MaxTokenTTL)
+	req := structs.ACLTokenSetRequest{
+		Datacenter: args.Datacenter,
+		ACLToken: structs.ACLToken{
+			Description:    description,
+			Policies:       policies,
+			Local:          true,
+			AuthMethod:     args.Auth.AuthMethod,
+			ExpirationTime: &expiration,
+		},
+		WriteRequest: args.WriteRequest,
+	}
+	return a.tokenSetInternal(&req, reply, false)
+}
+
+// Logout deletes the token the request is made with. Only tokens created by
+// logging in can be deleted this way.
+func (a *ACL) Logout(args *structs.ACLLogoutRequest, reply *bool) error {
+	if err := a.aclPreCheck(); err != nil {
+		return err
+	}
+	if !a.srv.LocalTokensEnabled() {
+		return fmt.Errorf("Local tokens are disabled")
+	}
+	if args.Token == "" || args.Token == anonymousToken {
+		return acl.ErrNotFound
+	}
+
+	if done, err := a.srv.forward("ACL.Logout", args, args, reply); done {
+		return err
+	}
+
+	defer metrics.MeasureSince([]string{"acl", "logout"}, time.Now())
+
+	_, token, err := a.srv.fsm.State().ACLTokenGetBySecret(nil, args.Token)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return acl.ErrNotFound
+	}
+	if token.AuthMethod == "" {
+		return acl.PermissionDeniedError{Cause: "Only tokens created by logging in can be logged out"}
+	}
+
+	req := &structs.ACLTokenBatchDeleteRequest{
+		TokenIDs: []string{token.AccessorID},
+	}
+	resp, err := a.srv.raftApply(structs.ACLTokenDeleteRequestType, req)
+	if err != nil {
+		return fmt.Errorf("Failed to apply token delete request: %v", err)
+	}
+
+	// Purge the identity from the cache so the token stops working straight away
+	a.srv.acls.cache.RemoveIdentity(token.SecretID)
+
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+
+	if reply != nil {
+		*reply = true
+	}
+	return nil
+}
+
+// jwtAlgorithms are the JWT signing algorithms logins can use.
+var jwtAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+}
+
+// jwtLeeway is how far the clocks of the JWT issuer and the servers can be
+// apart when checking the times in its claims.
+const jwtLeeway = 30 * time.Second
+
+// verifyLoginJWT checks that the JWT is signed by one of the auth method's
+// keys and that its claims are valid at the given time. The JWT must have an
+// expiration time, so a leaked JWT can't be used to log in forever. It returns
+// the variables for the binding rules, taken from the mapped claims.
+func verifyLoginJWT(method *structs.ACLAuthMethodConfig, raw string, now time.Time) (map[string]string, error) {
+	token, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT: %v", err)
+	}
+	for _, header := range token.Headers {
+		if !jwtAlgorithms[header.Algorithm] {
+			return nil, fmt.Errorf("unsupported JWT signing algorithm %q", header.Algorithm)
+		}
+	}
+
+	keys, err := method.PublicKeys()
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range keys {
+		if err := token.Claims(key); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("JWT isn't signed by any of the validation keys")
+	}
+
+	var std jwt.Claims
+	var claims map[string]interface{}
+	if err := token.UnsafeClaimsWithoutVerification(&std, &claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %v", err)
+	}
+	if std.Expiry == nil {
+		return nil, fmt.Errorf("JWT has no expiration time")
+	}
+	expected := jwt.Expected{
+		Issuer: method.BoundIssuer,
+		Time:   now,
+	}
+	if err := std.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %v", err)
+	}
+	if len(method.BoundAudiences) > 0 && !jwtAudienceMatches(std.Audience, method.BoundAudiences) {
+		return nil, fmt.Errorf("JWT audience doesn't match")
+	}
+
+	vars := make(map[string]string)
+	for claim, name := range method.ClaimMappings {
+		switch v := claims[claim].(type) {
+		case string:
+			vars[name] = v
+		case float64, bool:
+			vars[name] = fmt.Sprint(v)
+		}
+	}
+	return vars, nil
+}
+
+// jwtAudienceMatches returns true if the "aud" claim includes one of the bound
+// audiences.
+func jwtAudienceMatches(aud jwt.Audience, bound []string) bool {
+	for _, b := range bound {
+		if aud.Contains(b) {
+			return true
+		}
+	}
+	return false
+}
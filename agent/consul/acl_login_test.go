@@ -0,0 +1,247 @@
+package consul
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// testLoginKey generates a key for signing JWTs, and returns it along with its
+// PEM encoded public key.
+func testLoginKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// testLoginJWT returns a JWT with the given claims, signed with ES256.
+func testLoginJWT(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return raw
+}
+
+// testLoginSetup creates a policy for the web service and an auth method that
+// grants it to JWTs for that service.
+func testLoginSetup(t *testing.T, s *Server, pubKey string, ttl string) {
+	policyReq := structs.ACLPolicySetRequest{
+		Datacenter: "dc1",
+		Policy: structs.ACLPolicy{
+			Name:  "service-web",
+			Rules: `service "web" { policy = "write" }`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var policy structs.ACLPolicy
+	require.NoError(t, s.RPC("ACL.PolicySet", &policyReq, &policy))
+
+	methodReq := structs.ConfigEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ConfigEntryOpUpsert,
+		Entry: &structs.ConfigEntry{
+			Kind: structs.ACLAuthMethod,
+			Name: "workloads",
+			Config: map[string]interface{}{
+				"JWTValidationPubKeys": []interface{}{pubKey},
+				"BoundIssuer":          "scheduler",
+				"BoundAudiences":       []interface{}{"consul"},
+				"ClaimMappings":        map[string]interface{}{"svc": "service"},
+				"MaxTokenTTL":          ttl,
+				"BindingRules": []interface{}{
+					map[string]interface{}{
+						"Policies": []interface{}{"service-${service}"},
+					},
+				},
+			},
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var out struct{}
+	require.NoError(t, s.RPC("ConfigEntry.Apply", &methodReq, &out))
+}
+
+func TestACLEndpoint_Login(t *testing.T) {
+	t.Parallel()
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	key, pubKey := testLoginKey(t)
+	testLoginSetup(t, s1, pubKey, "1h")
+
+	login := func(claims map[string]interface{}) (*structs.ACLToken, error) {
+		req := structs.ACLLoginRequest{
+			Datacenter: "dc1",
+			Auth: &structs.ACLLoginParams{
+				AuthMethod:  "workloads",
+				BearerToken: testLoginJWT(t, key, claims),
+				Meta:        map[string]string{"host": "node1"},
+			},
+		}
+		var token structs.ACLToken
+		err := s1.RPC("ACL.Login", &req, &token)
+		return &token, err
+	}
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "scheduler",
+			"aud": []string{"other", "consul"},
+			"svc": "web",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	token, err := login(valid())
+	require.NoError(t, err)
+
+	// Small differences between the issuer's clock and ours are allowed.
+	skewed := valid()
+	skewed["iat"] = time.Now().Add(10 * time.Second).Unix()
+	skewed["nbf"] = time.Now().Add(10 * time.Second).Unix()
+	_, err = login(skewed)
+	require.NoError(t, err)
+	require.True(t, token.Local)
+	require.Equal(t, "workloads", token.AuthMethod)
+	require.NotNil(t, token.ExpirationTime)
+	require.WithinDuration(t, time.Now().Add(time.Hour), *token.ExpirationTime, time.Minute)
+	require.Len(t, token.Policies, 1)
+	require.Contains(t, token.Description, `"host":"node1"`)
+
+	authz, err := s1.ResolveToken(token.SecretID)
+	require.NoError(t, err)
+	require.True(t, authz.ServiceWrite("web", nil))
+	require.False(t, authz.ServiceWrite("db", nil))
+
+	// Bad credentials are all denied.
+	for name, modify := range map[string]func(map[string]interface{}){
+		"expired":       func(c map[string]interface{}) { c["exp"] = time.Now().Add(-5 * time.Minute).Unix() },
+		"no expiry":     func(c map[string]interface{}) { delete(c, "exp") },
+		"bad expiry":    func(c map[string]interface{}) { c["exp"] = "tomorrow" },
+		"not yet":       func(c map[string]interface{}) { c["nbf"] = time.Now().Add(5 * time.Minute).Unix() },
+		"issued later":  func(c map[string]interface{}) { c["iat"] = time.Now().Add(5 * time.Minute).Unix() },
+		"issuer":        func(c map[string]interface{}) { c["iss"] = "someone" },
+		"audience":      func(c map[string]interface{}) { c["aud"] = "vault" },
+		"no policies":   func(c map[string]interface{}) { c["svc"] = "db" },
+		"missing claim": func(c map[string]interface{}) { delete(c, "svc") },
+	} {
+		claims := valid()
+		modify(claims)
+		_, err := login(claims)
+		require.True(t, acl.IsErrPermissionDenied(err), "%s: %v", name, err)
+	}
+
+	// A JWT signed by another key is denied.
+	otherKey, _ := testLoginKey(t)
+	req := structs.ACLLoginRequest{
+		Datacenter: "dc1",
+		Auth: &structs.ACLLoginParams{
+			AuthMethod:  "workloads",
+			BearerToken: testLoginJWT(t, otherKey, valid()),
+		},
+	}
+	var out structs.ACLToken
+	err = s1.RPC("ACL.Login", &req, &out)
+	require.True(t, acl.IsErrPermissionDenied(err), "%v", err)
+
+	// The token's expiry can't be changed by updating it.
+	setReq := structs.ACLTokenSetRequest{
+		Datacenter: "dc1",
+		ACLToken: structs.ACLToken{
+			AccessorID:  token.AccessorID,
+			Description: "changed",
+			Local:       true,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var updated structs.ACLToken
+	require.NoError(t, s1.RPC("ACL.TokenSet", &setReq, &updated))
+	require.Equal(t, "workloads", updated.AuthMethod)
+	require.NotNil(t, updated.ExpirationTime)
+
+	// Logging out deletes the token, but only works for tokens created by
+	// logging in.
+	var ok bool
+	logoutReq := structs.ACLLogoutRequest{
+		Datacenter:   "dc1",
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	require.Error(t, s1.RPC("ACL.Logout", &logoutReq, &ok))
+
+	logoutReq.Token = token.SecretID
+	require.NoError(t, s1.RPC("ACL.Logout", &logoutReq, &ok))
+	_, existing, err := s1.fsm.State().ACLTokenGetByAccessor(nil, token.AccessorID)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+}
+
+func TestACLEndpoint_Login_Expiry(t *testing.T) {
+	t.Parallel()
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	key, pubKey := testLoginKey(t)
+	testLoginSetup(t, s1, pubKey, "1s")
+
+	req := structs.ACLLoginRequest{
+		Datacenter: "dc1",
+		Auth: &structs.ACLLoginParams{
+			AuthMethod: "workloads",
+			BearerToken: testLoginJWT(t, key, map[string]interface{}{
+				"iss": "scheduler",
+				"aud": "consul",
+				"svc": "web",
+				"exp": time.Now().Add(time.Minute).Unix(),
+			}),
+		},
+	}
+	var token structs.ACLToken
+	require.NoError(t, s1.RPC("ACL.Login", &req, &token))
+
+	// The leader deletes the token once it expires.
+	retry.Run(t, func(r *retry.R) {
+		_, existing, err := s1.fsm.State().ACLTokenGetByAccessor(nil, token.AccessorID)
+		if err != nil {
+			r.Fatal(err)
+		}
+		if existing != nil {
+			r.Fatal("token still exists")
+		}
+	})
+
+	_, err := s1.ResolveToken(token.SecretID)
+	require.True(t, acl.IsErrNotFound(err), "%v", err)
+}
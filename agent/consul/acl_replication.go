@@ -71,7 +71,7 @@ func (s *Server) deleteLocalACLPolicies(deletions []string, ctx context.Context)
 			req.PolicyIDs = deletions[i : i+aclBatchDeleteSize]
 		}
 
-		resp, err := s.raftApply(structs.ACLPolicyDeleteRequestType, &req)
+		resp, err := s.raftApplyInternal(structs.ACLPolicyDeleteRequestType, &req)
 		if err != nil {
 			return false, fmt.Errorf("Failed to apply policy deletions: %v", err)
 		}
@@ -109,7 +109,7 @@ func (s *Server) updateLocalACLPolicies(policies structs.ACLPolicies, ctx contex
 			Policies: policies[batchStart:batchEnd],
 		}
 
-		resp, err := s.raftApply(structs.ACLPolicySetRequestType, &req)
+		resp, err := s.raftApplyInternal(structs.ACLPolicySetRequestType, &req)
 		if err != nil {
 			return false, fmt.Errorf("Failed to apply policy upserts: %v", err)
 		}
@@ -252,7 +252,7 @@ func (s *Server) deleteLocalACLTokens(deletions []string, ctx context.Context) (
 			req.TokenIDs = deletions[i : i+aclBatchDeleteSize]
 		}
 
-		resp, err := s.raftApply(structs.ACLTokenDeleteRequestType, &req)
+		resp, err := s.raftApplyInternal(structs.ACLTokenDeleteRequestType, &req)
 		if err != nil {
 			return false, fmt.Errorf("Failed to apply token deletions: %v", err)
 		}
@@ -291,7 +291,7 @@ func (s *Server) updateLocalACLTokens(tokens structs.ACLTokens, ctx context.Cont
 			CAS:    false,
 		}
 
-		resp, err := s.raftApply(structs.ACLTokenSetRequestType, &req)
+		resp, err := s.raftApplyInternal(structs.ACLTokenSetRequestType, &req)
 		if err != nil {
 			return false, fmt.Errorf("Failed to apply token upserts: %v", err)
 		}
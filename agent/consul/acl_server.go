@@ -146,6 +146,7 @@ func (s *Server) ResolveIdentityFromToken(token string) (bool, structs.ACLIdenti
 	if err != nil {
 		return true, nil, err
 	} else if aclToken != nil {
+		s.recordACLTokenUsage(aclToken)
 		return true, aclToken, nil
 	}
 
@@ -0,0 +1,329 @@
+package consul
+
+import (
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+const (
+	// aclTokenUsageResolution is how precisely the leader keeps track of
+	// when tokens were last used. Usage that's more recent than what's
+	// recorded by less than this isn't written, so tokens that are used all
+	// the time don't turn into a constant stream of Raft writes.
+	aclTokenUsageResolution = time.Hour
+
+	// aclTokenUnusedCheckInterval controls how often the leader looks for
+	// unused tokens.
+	aclTokenUnusedCheckInterval = time.Hour
+
+	// aclTokenUsageReportMaxAge is how recently every other datacenter has
+	// to have reported token usage for the ACL datacenter to delete unused
+	// global tokens. Servers report every ACLTokenUsageFlushInterval even
+	// when no global tokens were used, so this only trips when a
+	// datacenter can't reach us or doesn't support usage tracking.
+	aclTokenUsageReportMaxAge = time.Hour
+)
+
+// aclTokenUse is when this server last resolved a token.
+type aclTokenUse struct {
+	lastUsed time.Time
+	local    bool
+}
+
+// recordACLTokenUsage notes that a token was used. The usage is kept in
+// memory and sent to the leader by flushACLTokenUsage.
+func (s *Server) recordACLTokenUsage(token *structs.ACLToken) {
+	s.aclTokenUsageLock.Lock()
+	defer s.aclTokenUsageLock.Unlock()
+
+	if s.aclTokenUsage == nil {
+		s.aclTokenUsage = make(map[string]aclTokenUse)
+	}
+	s.aclTokenUsage[token.AccessorID] = aclTokenUse{
+		lastUsed: time.Now().UTC(),
+		local:    token.Local,
+	}
+}
+
+// runACLTokenUsageFlush periodically sends the token usage this server
+// recorded to the leader until the server shuts down.
+func (s *Server) runACLTokenUsageFlush() {
+	ticker := time.NewTicker(s.config.ACLTokenUsageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCh:
+			return
+		case <-ticker.C:
+			s.flushACLTokenUsage()
+		}
+	}
+}
+
+// flushACLTokenUsage sends the token usage recorded since the last flush to
+// the leader. The usage of global tokens also goes to the ACL datacenter,
+// which is where they're checked for being unused. Usage that can't be sent
+// is dropped, since the token will be recorded again the next time it's
+// used.
+func (s *Server) flushACLTokenUsage() {
+	s.aclTokenUsageLock.Lock()
+	used := s.aclTokenUsage
+	s.aclTokenUsage = nil
+	s.aclTokenUsageLock.Unlock()
+
+	var all, global []*structs.ACLTokenUsage
+	for accessor, use := range used {
+		usage := &structs.ACLTokenUsage{AccessorID: accessor, LastUsed: use.lastUsed}
+		all = append(all, usage)
+		if !use.local {
+			global = append(global, usage)
+		}
+	}
+
+	if len(all) > 0 {
+		s.sendACLTokenUsage(s.config.Datacenter, all)
+	}
+
+	// The ACL datacenter hears from us even when no global tokens were
+	// used, so it knows our usage isn't missing.
+	if !s.InACLDatacenter() {
+		s.sendACLTokenUsage(s.config.ACLDatacenter, global)
+	}
+}
+
+// sendACLTokenUsage sends token usage to the leader of the given datacenter.
+func (s *Server) sendACLTokenUsage(dc string, usage []*structs.ACLTokenUsage) {
+	req := structs.ACLTokenUsageRequest{
+		Datacenter:       dc,
+		Node:             s.config.NodeName,
+		SourceDatacenter: s.config.Datacenter,
+		Usage:            usage,
+		WriteRequest:     structs.WriteRequest{Token: s.tokens.AgentToken()},
+	}
+	var out struct{}
+	if err := s.RPC("ACL.TokenUsageUpdate", &req, &out); err != nil {
+		if structs.IsErrFeatureNotSupported(err) {
+			s.logger.Printf("[DEBUG] consul.acl: Not sending token usage to datacenter %q: %v", dc, err)
+			return
+		}
+		s.logger.Printf("[WARN] consul.acl: Failed to send token usage to datacenter %q: %v", dc, err)
+	}
+}
+
+// startACLTokenUnusedCheck starts a goroutine that periodically looks for
+// tokens that haven't been used for ACLTokenUnusedPeriod.
+func (s *Server) startACLTokenUnusedCheck() {
+	s.aclTokenUnusedLock.Lock()
+	defer s.aclTokenUnusedLock.Unlock()
+
+	if s.aclTokenUnusedEnabled || s.config.ACLTokenUnusedPeriod <= 0 {
+		return
+	}
+
+	s.aclTokenUnusedCh = make(chan struct{})
+
+	go func(stopCh chan struct{}) {
+		ticker := time.NewTicker(aclTokenUnusedCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.checkUnusedACLTokens(); err != nil {
+				s.logger.Printf("[ERR] consul.acl: error checking for unused tokens: %v", err)
+			}
+
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(s.aclTokenUnusedCh)
+
+	s.aclTokenUnusedEnabled = true
+}
+
+// stopACLTokenUnusedCheck stops looking for unused tokens when we lose
+// leadership.
+func (s *Server) stopACLTokenUnusedCheck() {
+	s.aclTokenUnusedLock.Lock()
+	defer s.aclTokenUnusedLock.Unlock()
+
+	if !s.aclTokenUnusedEnabled {
+		return
+	}
+
+	close(s.aclTokenUnusedCh)
+	s.aclTokenUnusedEnabled = false
+	s.aclTokenUnusedFlagged = nil
+	s.aclTokenUsageReports = nil
+}
+
+// recordACLTokenUsageReport notes that a server in the given datacenter
+// reported its token usage to us.
+func (s *Server) recordACLTokenUsageReport(dc string) {
+	s.aclTokenUnusedLock.Lock()
+	defer s.aclTokenUnusedLock.Unlock()
+
+	if !s.aclTokenUnusedEnabled || dc == "" || dc == s.config.Datacenter {
+		return
+	}
+	if s.aclTokenUsageReports == nil {
+		s.aclTokenUsageReports = make(map[string]time.Time)
+	}
+	s.aclTokenUsageReports[dc] = time.Now()
+}
+
+// silentACLTokenUsageDatacenters returns the other datacenters that haven't
+// reported token usage to us within aclTokenUsageReportMaxAge. Global tokens
+// used there may look unused here.
+func (s *Server) silentACLTokenUsageDatacenters(now time.Time) []string {
+	s.aclTokenUnusedLock.Lock()
+	defer s.aclTokenUnusedLock.Unlock()
+
+	var silent []string
+	for _, dc := range s.router.GetDatacenters() {
+		if dc == s.config.Datacenter {
+			continue
+		}
+		if last, ok := s.aclTokenUsageReports[dc]; !ok || now.Sub(last) > aclTokenUsageReportMaxAge {
+			silent = append(silent, dc)
+		}
+	}
+	return silent
+}
+
+// unusedACLTokens returns the tokens this datacenter is responsible for that
+// haven't been used for ACLTokenUnusedPeriod. Tokens are only judged once
+// usage has been tracked for the whole period, and never for the anonymous
+// token or management tokens, which would lock operators out.
+func (s *Server) unusedACLTokens(now time.Time) (structs.ACLTokens, error) {
+	state := s.fsm.State()
+
+	// Global tokens are only checked in the ACL datacenter, which is the
+	// only one that can delete them.
+	_, tokens, err := state.ACLTokenList(nil, true, s.InACLDatacenter(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	accessors := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		accessors = append(accessors, token.AccessorID)
+	}
+	usage, since, err := state.ACLTokenUsageGet(accessors)
+	if err != nil {
+		return nil, err
+	}
+	if since.IsZero() || now.Sub(since) < s.config.ACLTokenUnusedPeriod {
+		return nil, nil
+	}
+
+	var unused structs.ACLTokens
+	for _, token := range tokens {
+		if token.AccessorID == structs.ACLTokenAnonymousID || isManagementToken(token) {
+			continue
+		}
+		last := since
+		if token.CreateTime.After(last) {
+			last = token.CreateTime
+		}
+		if used, ok := usage[token.AccessorID]; ok && used.After(last) {
+			last = used
+		}
+		if now.Sub(last) >= s.config.ACLTokenUnusedPeriod {
+			unused = append(unused, token)
+		}
+	}
+	return unused, nil
+}
+
+// checkUnusedACLTokens looks for unused tokens and flags or deletes them,
+// depending on ACLTokenUnusedAction. Tokens are only flagged once while
+// they stay unused.
+func (s *Server) checkUnusedACLTokens() error {
+	defer metrics.MeasureSince([]string{"leader", "acl", "token_unused_check"}, time.Now())
+
+	unused, err := s.unusedACLTokens(time.Now())
+	if err != nil {
+		return err
+	}
+	metrics.SetGauge([]string{"acl", "token", "unused"}, float32(len(unused)))
+
+	if s.config.ACLTokenUnusedAction == "delete" {
+		// Global tokens are only deleted while every datacenter is
+		// reporting its usage, otherwise tokens that are only used
+		// elsewhere would be deleted.
+		if silent := s.silentACLTokenUsageDatacenters(time.Now()); len(silent) > 0 {
+			var local structs.ACLTokens
+			for _, token := range unused {
+				if token.Local {
+					local = append(local, token)
+				}
+			}
+			if len(local) < len(unused) {
+				s.logger.Printf("[WARN] consul.acl: Not deleting %d unused global tokens, datacenters %v haven't reported token usage recently",
+					len(unused)-len(local), silent)
+			}
+			unused = local
+		}
+		return s.deleteUnusedACLTokens(unused)
+	}
+
+	s.aclTokenUnusedLock.Lock()
+	defer s.aclTokenUnusedLock.Unlock()
+
+	flagged := make(map[string]struct{}, len(unused))
+	for _, token := range unused {
+		flagged[token.AccessorID] = struct{}{}
+		if _, ok := s.aclTokenUnusedFlagged[token.AccessorID]; ok {
+			continue
+		}
+		s.logger.Printf("[WARN] consul.acl: Token %s (%q) hasn't been used for over %s",
+			token.AccessorID, token.Description, s.config.ACLTokenUnusedPeriod)
+	}
+	s.aclTokenUnusedFlagged = flagged
+	return nil
+}
+
+// deleteUnusedACLTokens deletes the given tokens in batches.
+func (s *Server) deleteUnusedACLTokens(unused structs.ACLTokens) error {
+	for len(unused) > 0 {
+		batch := unused
+		if len(batch) > aclBatchDeleteSize {
+			batch = batch[:aclBatchDeleteSize]
+		}
+		unused = unused[len(batch):]
+
+		req := &structs.ACLTokenBatchDeleteRequest{}
+		for _, token := range batch {
+			req.TokenIDs = append(req.TokenIDs, token.AccessorID)
+		}
+		resp, err := s.raftApplyInternal(structs.ACLTokenDeleteRequestType, req)
+		if respErr, ok := resp.(error); ok {
+			err = respErr
+		}
+		if err != nil {
+			return err
+		}
+		metrics.IncrCounter([]string{"acl", "token", "unused_deleted"}, float32(len(batch)))
+		s.logger.Printf("[INFO] consul.acl: Deleted %d tokens that hadn't been used for over %s",
+			len(batch), s.config.ACLTokenUnusedPeriod)
+	}
+	return nil
+}
+
+// isManagementToken returns whether the token has management privileges.
+func isManagementToken(token *structs.ACLToken) bool {
+	if token.Type == structs.ACLTokenTypeManagement {
+		return true
+	}
+	for _, link := range token.Policies {
+		if link.ID == structs.ACLPolicyGlobalManagementID {
+			return true
+		}
+	}
+	return false
+}
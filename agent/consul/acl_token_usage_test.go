@@ -0,0 +1,196 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACLTokenUsage(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLTokenUsageFlushInterval = time.Hour
+		c.ACLTokenUnusedPeriod = time.Hour
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	used, err := upsertTestToken(codec, "root", "dc1")
+	require.NoError(err)
+	unused, err := upsertTestToken(codec, "root", "dc1")
+	require.NoError(err)
+
+	// Resolving a token records its usage, which shows up in the token
+	// list once it's flushed.
+	_, err = s1.ResolveToken(used.SecretID)
+	require.NoError(err)
+	s1.flushACLTokenUsage()
+
+	lastUsed := func() map[string]*time.Time {
+		req := structs.ACLTokenListRequest{
+			Datacenter:   "dc1",
+			QueryOptions: structs.QueryOptions{Token: "root"},
+		}
+		var resp structs.ACLTokenListResponse
+		require.NoError(msgpackrpc.CallWithCodec(codec, "ACL.TokenList", &req, &resp))
+		out := make(map[string]*time.Time)
+		for _, token := range resp.Tokens {
+			out[token.AccessorID] = token.LastUsed
+		}
+		return out
+	}
+	usage := lastUsed()
+	require.NotNil(usage[used.AccessorID])
+	require.Nil(usage[unused.AccessorID])
+	first := *usage[used.AccessorID]
+
+	// Usage within the resolution of what's recorded isn't written.
+	update := func(lastUsed time.Time) {
+		req := structs.ACLTokenUsageRequest{
+			Datacenter: "dc1",
+			Node:       s1.config.NodeName,
+			Usage: []*structs.ACLTokenUsage{
+				{AccessorID: used.AccessorID, LastUsed: lastUsed},
+			},
+		}
+		var out struct{}
+		require.NoError(msgpackrpc.CallWithCodec(codec, "ACL.TokenUsageUpdate", &req, &out))
+	}
+	update(first.Add(time.Minute))
+	require.True(first.Equal(*lastUsed()[used.AccessorID]))
+
+	// Usage in the future is taken as now, so it's within the resolution
+	// too.
+	later := first.Add(150 * time.Minute)
+	update(later)
+	require.True(first.Equal(*lastUsed()[used.AccessorID]))
+
+	// Pretend the token was used later on, to judge it from the future.
+	require.NoError(s1.fsm.State().ACLTokenUsageSet(1000, []*structs.ACLTokenUsage{
+		{AccessorID: used.AccessorID, LastUsed: later},
+	}))
+	require.True(later.Equal(*lastUsed()[used.AccessorID]))
+
+	// Three hours on, only the token that was never used counts as unused.
+	// The management token and the anonymous token never do.
+	tokens, err := s1.unusedACLTokens(first.Add(3 * time.Hour))
+	require.NoError(err)
+	require.Len(tokens, 1)
+	require.Equal(unused.AccessorID, tokens[0].AccessorID)
+
+	// Nothing's unused until usage has been tracked for the whole period.
+	tokens, err = s1.unusedACLTokens(first.Add(30 * time.Minute))
+	require.NoError(err)
+	require.Empty(tokens)
+
+	// Deleting the unused tokens leaves the one in use.
+	tokens, err = s1.unusedACLTokens(first.Add(3 * time.Hour))
+	require.NoError(err)
+	require.NoError(s1.deleteUnusedACLTokens(tokens))
+	usage = lastUsed()
+	require.Contains(usage, used.AccessorID)
+	require.NotContains(usage, unused.AccessorID)
+}
+
+func TestACLTokenUsage_Flag(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLTokenUnusedPeriod = 50 * time.Millisecond
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	token, err := upsertTestToken(codec, "root", "dc1")
+	require.NoError(err)
+
+	// Start tracking usage an hour ago, before the token was created.
+	req := structs.ACLTokenUsageRequest{
+		Datacenter: "dc1",
+		Node:       s1.config.NodeName,
+		Usage: []*structs.ACLTokenUsage{
+			{AccessorID: structs.ACLTokenAnonymousID, LastUsed: time.Now().Add(-time.Hour)},
+		},
+	}
+	var out struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ACL.TokenUsageUpdate", &req, &out))
+
+	// Once the token's gone unused for the period it's flagged, but not
+	// deleted.
+	retry.Run(t, func(r *retry.R) {
+		if err := s1.checkUnusedACLTokens(); err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		s1.aclTokenUnusedLock.Lock()
+		defer s1.aclTokenUnusedLock.Unlock()
+		if _, ok := s1.aclTokenUnusedFlagged[token.AccessorID]; !ok {
+			r.Fatalf("token not flagged: %v", s1.aclTokenUnusedFlagged)
+		}
+	})
+	_, rtoken, err := s1.fsm.State().ACLTokenGetByAccessor(nil, token.AccessorID)
+	require.NoError(err)
+	require.NotNil(rtoken)
+
+	// Losing leadership forgets the flagged tokens.
+	s1.stopACLTokenUnusedCheck()
+	require.Nil(s1.aclTokenUnusedFlagged)
+}
+
+func TestACLTokenUsage_SilentDatacenters(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLTokenUnusedPeriod = time.Hour
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc2"
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+	joinWAN(t, s2, s1)
+	retry.Run(t, func(r *retry.R) {
+		if got := len(s1.router.GetDatacenters()); got != 2 {
+			r.Fatalf("got %d datacenters", got)
+		}
+	})
+
+	// Until dc2 reports its usage, global tokens can't be deleted.
+	now := time.Now()
+	require.Equal([]string{"dc2"}, s1.silentACLTokenUsageDatacenters(now))
+	s1.recordACLTokenUsageReport("dc2")
+	require.Empty(s1.silentACLTokenUsageDatacenters(now))
+
+	// Reports go stale.
+	require.Equal([]string{"dc2"}, s1.silentACLTokenUsageDatacenters(now.Add(2*aclTokenUsageReportMaxAge)))
+}
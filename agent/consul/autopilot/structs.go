@@ -146,6 +146,14 @@ type OperatorHealthReply struct {
 
 	// Servers holds the health of each server.
 	Servers []ServerHealth
+
+	// RecentLeaderElections is the number of leader elections the leader
+	// has seen within the leader flap detection window.
+	RecentLeaderElections int
+
+	// LeaderFlapping is true if RecentLeaderElections has reached the
+	// leader flap threshold.
+	LeaderFlapping bool
 }
 
 func (o *OperatorHealthReply) ServerHealth(id string) *ServerHealth {
@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+// blockingQueryWarnInterval limits how often a server logs that it is
+// shedding blocking queries, since that tends to happen in bursts.
+const blockingQueryWarnInterval = 10 * time.Second
+
+// BlockingQueryLimiter bounds the number of blocking queries a server is
+// watching at once. A global limit protects the server as a whole, per-table
+// limits keep queries on a hot table from crowding out queries on the others,
+// and a per-token limit keeps a single misbehaving client from using up the
+// whole budget so other tokens still get a fair share. A zero limit disables
+// the respective check, and tables without a limit are only held to the
+// global one. It is safe for concurrent use.
+type BlockingQueryLimiter struct {
+	sync.Mutex
+
+	limit       int
+	tokenLimit  int
+	tableLimits map[string]int
+
+	total   int
+	byToken map[string]int
+	byTable map[string]int
+
+	// shed counts the queries rejected since the last warning was logged.
+	shed     int
+	lastWarn time.Time
+}
+
+// NewBlockingQueryLimiter returns a limiter enforcing the given limits.
+// tableLimits is keyed by state store table name.
+func NewBlockingQueryLimiter(limit, tokenLimit int, tableLimits map[string]int) *BlockingQueryLimiter {
+	return &BlockingQueryLimiter{
+		limit:       limit,
+		tokenLimit:  tokenLimit,
+		tableLimits: tableLimits,
+		byToken:     make(map[string]int),
+		byTable:     make(map[string]int),
+	}
+}
+
+// Acquire reserves a slot for a blocking query on the given table made with
+// the given token. It returns false if any of the limits has been reached, in
+// which case the query should be shed. Every successful Acquire must be
+// paired with a Release.
+func (l *BlockingQueryLimiter) Acquire(table, token string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if (l.limit > 0 && l.total >= l.limit) ||
+		(l.tableLimits[table] > 0 && l.byTable[table] >= l.tableLimits[table]) ||
+		(l.tokenLimit > 0 && l.byToken[token] >= l.tokenLimit) {
+		l.shed++
+		return false
+	}
+	l.total++
+	l.byToken[token]++
+	l.byTable[table]++
+	l.setGauge()
+	return true
+}
+
+// Release frees a slot reserved by Acquire.
+func (l *BlockingQueryLimiter) Release(table, token string) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.total--
+	decrementCount(l.byToken, token)
+	decrementCount(l.byTable, table)
+	l.setGauge()
+}
+
+// decrementCount takes one off the count for the key, removing it once it
+// gets to zero so the map doesn't grow without bound.
+func decrementCount(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+	} else {
+		counts[key]--
+	}
+}
+
+// setGauge publishes the number of blocking queries being watched. It's set
+// on every change, so it drops back as soon as queries return. The lock must
+// be held.
+func (l *BlockingQueryLimiter) setGauge() {
+	metrics.SetGauge([]string{"rpc", "queries_blocking"}, float32(l.total))
+}
+
+// Len returns the number of blocking queries currently being watched.
+func (l *BlockingQueryLimiter) Len() int {
+	l.Lock()
+	defer l.Unlock()
+	return l.total
+}
+
+// Shed returns the number of queries rejected since the last call that
+// returned a non-zero count, at most once per blockingQueryWarnInterval. This
+// lets callers log shedding without flooding the logs.
+func (l *BlockingQueryLimiter) Shed() int {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.shed == 0 || time.Since(l.lastWarn) < blockingQueryWarnInterval {
+		return 0
+	}
+	n := l.shed
+	l.shed = 0
+	l.lastWarn = time.Now()
+	return n
+}
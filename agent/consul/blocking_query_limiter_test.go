@@ -0,0 +1,69 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockingQueryLimiter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewBlockingQueryLimiter(3, 2, nil)
+	require.True(l.Acquire("kvs", "a"))
+	require.True(l.Acquire("kvs", "a"))
+	require.False(l.Acquire("kvs", "a"))
+	require.True(l.Acquire("kvs", "b"))
+	require.False(l.Acquire("kvs", "c"))
+	require.Equal(3, l.Len())
+
+	// The first shed count is reported, then throttled.
+	require.Equal(2, l.Shed())
+	require.False(l.Acquire("kvs", "c"))
+	require.Equal(0, l.Shed())
+
+	// Releasing makes room again.
+	l.Release("kvs", "a")
+	require.Equal(2, l.Len())
+	require.True(l.Acquire("kvs", "c"))
+	require.False(l.Acquire("kvs", "d"))
+	l.Release("kvs", "a")
+	l.Release("kvs", "b")
+	l.Release("kvs", "c")
+	require.Equal(0, l.Len())
+	require.Empty(l.byToken)
+	require.Empty(l.byTable)
+}
+
+func TestBlockingQueryLimiter_table(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewBlockingQueryLimiter(0, 0, map[string]int{"kvs": 2, "services": 0})
+	require.True(l.Acquire("kvs", "a"))
+	require.True(l.Acquire("kvs", "b"))
+	require.False(l.Acquire("kvs", "c"))
+
+	// Other tables aren't held to the kvs limit.
+	for i := 0; i < 10; i++ {
+		require.True(l.Acquire("services", "a"))
+		require.True(l.Acquire("nodes", "a"))
+	}
+
+	// Releasing makes room on the table again.
+	l.Release("kvs", "a")
+	require.True(l.Acquire("kvs", "c"))
+	require.False(l.Acquire("kvs", "d"))
+}
+
+func TestBlockingQueryLimiter_unlimited(t *testing.T) {
+	t.Parallel()
+
+	l := NewBlockingQueryLimiter(0, 0, nil)
+	for i := 0; i < 100; i++ {
+		require.True(t, l.Acquire("kvs", ""))
+	}
+	require.Equal(t, 100, l.Len())
+	require.Equal(t, 0, l.Shed())
+}
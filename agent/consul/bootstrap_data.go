@@ -0,0 +1,458 @@
+package consul
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/lib"
+	"github.com/hashicorp/hcl"
+	"github.com/mitchellh/mapstructure"
+)
+
+// bootstrapData is the content of a file in the bootstrap data directory.
+// Files can be HCL or JSON:
+//
+//	service "web" {
+//	  node    = "ext-web1"
+//	  address = "10.0.0.10"
+//	  port    = 80
+//	}
+//
+//	key "config/web/replicas" {
+//	  value = "3"
+//	}
+//
+//	policy "web" {
+//	  rules = "service \"web\" { policy = \"write\" }"
+//	}
+//
+//	token {
+//	  secret_id = "0d7e5ec6-0c4f-4dfb-9c53-c0f9b0f1e4d6"
+//	  policies  = ["web"]
+//	}
+type bootstrapData struct {
+	Services []*bootstrapService `hcl:"service"`
+	Keys     []*bootstrapKey     `hcl:"key"`
+	Policies []*bootstrapPolicy  `hcl:"policy"`
+
+	// Tokens have no key, and HCL splits the attributes of unkeyed blocks
+	// into separate elements when it decodes them into a slice, so they're
+	// decoded from a raw map instead; see decodeBootstrapTokens.
+	Tokens []*bootstrapToken `hcl:"-"`
+}
+
+// bootstrapService is a service registered in the catalog, along with the
+// node it's registered against.
+type bootstrapService struct {
+	Name    string            `hcl:",key"`
+	ID      string            `hcl:"id"`
+	Node    string            `hcl:"node"`
+	Address string            `hcl:"address"`
+	Port    int               `hcl:"port"`
+	Tags    []string          `hcl:"tags"`
+	Meta    map[string]string `hcl:"meta"`
+}
+
+// bootstrapKey is a KV entry.
+type bootstrapKey struct {
+	Key   string `hcl:",key"`
+	Value string `hcl:"value"`
+	Flags int64  `hcl:"flags"`
+}
+
+// bootstrapPolicy is an ACL policy.
+type bootstrapPolicy struct {
+	Name        string `hcl:",key"`
+	Description string `hcl:"description"`
+	Rules       string `hcl:"rules"`
+}
+
+// bootstrapToken is an ACL token, which links to policies by name.
+type bootstrapToken struct {
+	AccessorID  string   `mapstructure:"accessor_id"`
+	SecretID    string   `mapstructure:"secret_id"`
+	Description string   `mapstructure:"description"`
+	Policies    []string `mapstructure:"policies"`
+	Local       bool     `mapstructure:"local"`
+}
+
+// parseBootstrapData reads and merges the .hcl and .json files in the given
+// directory, in lexical order, and checks that they're complete.
+func parseBootstrapData(dir string) (*bootstrapData, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bootstrap data directory: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	data := &bootstrapData{}
+	for _, fi := range files {
+		ext := filepath.Ext(fi.Name())
+		if fi.IsDir() || (ext != ".hcl" && ext != ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, fi.Name())
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bootstrap data file %q: %v", path, err)
+		}
+
+		// HCL decodes JSON as well.
+		var file bootstrapData
+		if err := hcl.Decode(&file, string(buf)); err != nil {
+			return nil, fmt.Errorf("failed to parse bootstrap data file %q: %v", path, err)
+		}
+		if file.Tokens, err = decodeBootstrapTokens(string(buf)); err != nil {
+			return nil, fmt.Errorf("failed to parse bootstrap data file %q: %v", path, err)
+		}
+		if err := file.validate(); err != nil {
+			return nil, fmt.Errorf("invalid bootstrap data file %q: %v", path, err)
+		}
+
+		data.Services = append(data.Services, file.Services...)
+		data.Keys = append(data.Keys, file.Keys...)
+		data.Policies = append(data.Policies, file.Policies...)
+		data.Tokens = append(data.Tokens, file.Tokens...)
+	}
+	return data, nil
+}
+
+// decodeBootstrapTokens decodes the token blocks of a bootstrap data file.
+// Decoding the whole file into a map keeps the attributes of each block
+// together, the same way the agent configuration is decoded.
+func decodeBootstrapTokens(src string) ([]*bootstrapToken, error) {
+	var raw map[string]interface{}
+	if err := hcl.Decode(&raw, src); err != nil {
+		return nil, err
+	}
+
+	var tokens []*bootstrapToken
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused: true,
+		Result:      &tokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Decode(raw["token"]); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// validate checks that the resources of a file have the fields they need.
+func (d *bootstrapData) validate() error {
+	for _, svc := range d.Services {
+		if svc.Node == "" || svc.Address == "" {
+			return fmt.Errorf("service %q must have a node and an address", svc.Name)
+		}
+	}
+	for _, key := range d.Keys {
+		if strings.HasPrefix(key.Key, "_consul/") {
+			return fmt.Errorf("key %q is in the reserved _consul/ prefix", key.Key)
+		}
+	}
+	for _, policy := range d.Policies {
+		if !validPolicyName.MatchString(policy.Name) {
+			return fmt.Errorf("policy %q has an invalid name", policy.Name)
+		}
+		if _, err := acl.NewPolicyFromSource("", 0, policy.Rules, acl.SyntaxCurrent, nil); err != nil {
+			return fmt.Errorf("policy %q has invalid rules: %v", policy.Name, err)
+		}
+	}
+	for _, token := range d.Tokens {
+		if token.SecretID == "" {
+			return fmt.Errorf("tokens must have a secret_id")
+		}
+	}
+	return nil
+}
+
+// loadBootstrapData loads the bootstrap data directory into the state store,
+// unless it has been loaded before. The catalog and KV part and the ACL part
+// are each recorded as loaded through Raft once they are, so they're never
+// loaded again, even by a different leader. The ACL part is left pending while
+// the ACL system is in legacy mode, or while the policies of local tokens
+// haven't been replicated from the primary datacenter yet, and the leader
+// calls this again as it reconciles until it's loaded. A leader that fails part
+// way through leaves the part unrecorded, so the next one loads it again; all
+// the writes are upserts, so that's safe.
+func (s *Server) loadBootstrapData() error {
+	dir := s.config.BootstrapDataDir
+	if dir == "" {
+		return nil
+	}
+
+	state := s.fsm.State()
+	catalogLoaded := state.BootstrapDataLoaded(structs.BootstrapDataPartCatalog) > 0
+	aclsLoaded := state.BootstrapDataLoaded(structs.BootstrapDataPartACLs) > 0
+	if catalogLoaded && aclsLoaded {
+		return nil
+	}
+
+	// Nothing is loaded until it can be recorded, otherwise it would be
+	// loaded again on every election.
+	if err := s.checkDatacenterFeature(metadata.FeatureBootstrapData, "Loading bootstrap data"); err != nil {
+		return err
+	}
+
+	data, err := parseBootstrapData(dir)
+	if err != nil {
+		return err
+	}
+
+	if !catalogLoaded {
+		if err := s.loadBootstrapCatalog(data); err != nil {
+			return err
+		}
+		if err := s.markBootstrapDataLoaded(structs.BootstrapDataPartCatalog); err != nil {
+			return err
+		}
+		s.logger.Printf("[INFO] consul.bootstrap: Loaded %d services and %d keys from %q",
+			len(data.Services), len(data.Keys), dir)
+	}
+
+	if !aclsLoaded {
+		done, err := s.loadBootstrapACLs(data)
+		if err != nil {
+			return err
+		}
+		if !done {
+			return nil
+		}
+		if err := s.markBootstrapDataLoaded(structs.BootstrapDataPartACLs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadBootstrapCatalog registers the services and writes the keys of the
+// bootstrap data.
+func (s *Server) loadBootstrapCatalog(data *bootstrapData) error {
+	for _, svc := range data.Services {
+		id := svc.ID
+		if id == "" {
+			id = svc.Name
+		}
+		req := structs.RegisterRequest{
+			Datacenter: s.config.Datacenter,
+			Node:       svc.Node,
+			Address:    svc.Address,
+			Service: &structs.NodeService{
+				ID:      id,
+				Service: svc.Name,
+				Port:    svc.Port,
+				Tags:    svc.Tags,
+				Meta:    svc.Meta,
+			},
+		}
+		if err := s.applyBootstrapData(structs.RegisterRequestType, &req); err != nil {
+			return fmt.Errorf("failed to register service %q: %v", svc.Name, err)
+		}
+	}
+
+	for _, key := range data.Keys {
+		req := structs.KVSRequest{
+			Datacenter: s.config.Datacenter,
+			Op:         api.KVSet,
+			DirEnt: structs.DirEntry{
+				Key:   key.Key,
+				Flags: uint64(key.Flags),
+				Value: []byte(key.Value),
+			},
+		}
+		if err := s.applyBootstrapData(structs.KVSRequestType, &req); err != nil {
+			return fmt.Errorf("failed to write key %q: %v", key.Key, err)
+		}
+	}
+	return nil
+}
+
+// loadBootstrapACLs creates the ACL policies and tokens of the bootstrap data
+// that this datacenter is responsible for. Policies and global tokens are
+// created in the primary datacenter, and other datacenters only create local
+// tokens once the policies they link to have been replicated. It returns false
+// if the ACLs can't be loaded yet.
+func (s *Server) loadBootstrapACLs(data *bootstrapData) (bool, error) {
+	if len(data.Policies) == 0 && len(data.Tokens) == 0 {
+		return true, nil
+	}
+	if !s.ACLsEnabled() {
+		s.logger.Printf("[WARN] consul.bootstrap: Skipping %d ACL policies and %d tokens, since ACLs are disabled",
+			len(data.Policies), len(data.Tokens))
+		return true, nil
+	}
+	if s.UseLegacyACLs() {
+		s.logger.Printf("[DEBUG] consul.bootstrap: Waiting for the ACL system to leave legacy mode before loading ACL policies and tokens")
+		return false, nil
+	}
+
+	if s.InACLDatacenter() {
+		if err := s.loadBootstrapPolicies(data.Policies); err != nil {
+			return false, err
+		}
+		if err := s.loadBootstrapTokens(data.Tokens); err != nil {
+			return false, err
+		}
+		s.logger.Printf("[INFO] consul.bootstrap: Loaded %d ACL policies and %d ACL tokens", len(data.Policies), len(data.Tokens))
+		return true, nil
+	}
+
+	var local []*bootstrapToken
+	for _, t := range data.Tokens {
+		if t.Local {
+			local = append(local, t)
+		}
+	}
+	if len(local) == 0 {
+		return true, nil
+	}
+	if !s.LocalTokensEnabled() {
+		s.logger.Printf("[WARN] consul.bootstrap: Skipping %d local ACL tokens, since token replication is disabled", len(local))
+		return true, nil
+	}
+	for _, t := range local {
+		for _, name := range t.Policies {
+			_, policy, err := s.fsm.State().ACLPolicyGetByName(nil, name)
+			if err != nil {
+				return false, err
+			}
+			if policy == nil {
+				s.logger.Printf("[DEBUG] consul.bootstrap: Waiting for ACL policy %q to be replicated before loading local ACL tokens", name)
+				return false, nil
+			}
+		}
+	}
+	if err := s.loadBootstrapTokens(local); err != nil {
+		return false, err
+	}
+	s.logger.Printf("[INFO] consul.bootstrap: Loaded %d local ACL tokens", len(local))
+	return true, nil
+}
+
+// markBootstrapDataLoaded records that the given part of the bootstrap data
+// was loaded.
+func (s *Server) markBootstrapDataLoaded(part string) error {
+	req := structs.BootstrapDataRequest{
+		Datacenter: s.config.Datacenter,
+		Part:       part,
+	}
+	if err := s.applyBootstrapData(structs.BootstrapDataRequestType, &req); err != nil {
+		return fmt.Errorf("failed to record that bootstrap data was loaded: %v", err)
+	}
+	return nil
+}
+
+// loadBootstrapPolicies creates the given ACL policies. Policies that already
+// exist with the same name are left alone.
+func (s *Server) loadBootstrapPolicies(policies []*bootstrapPolicy) error {
+	state := s.fsm.State()
+	for _, p := range policies {
+		_, existing, err := state.ACLPolicyGetByName(nil, p.Name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			s.logger.Printf("[WARN] consul.bootstrap: Not loading ACL policy %q, which already exists", p.Name)
+			continue
+		}
+
+		id, err := lib.GenerateUUID(s.checkPolicyUUID)
+		if err != nil {
+			return err
+		}
+		policy := structs.ACLPolicy{
+			ID:          id,
+			Name:        p.Name,
+			Description: p.Description,
+			Rules:       p.Rules,
+			Syntax:      acl.SyntaxCurrent,
+		}
+		policy.SetHash(true)
+
+		req := structs.ACLPolicyBatchSetRequest{
+			Policies: structs.ACLPolicies{&policy},
+		}
+		if err := s.applyBootstrapData(structs.ACLPolicySetRequestType, &req); err != nil {
+			return fmt.Errorf("failed to create ACL policy %q: %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// loadBootstrapTokens creates the given ACL tokens, linking them to their
+// policies by name. Tokens whose secret is already in use are left alone.
+func (s *Server) loadBootstrapTokens(tokens []*bootstrapToken) error {
+	state := s.fsm.State()
+	for _, t := range tokens {
+		_, existing, err := state.ACLTokenGetBySecret(nil, t.SecretID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			s.logger.Printf("[WARN] consul.bootstrap: Not loading ACL token %q, which already exists", existing.AccessorID)
+			continue
+		}
+
+		accessor := t.AccessorID
+		if accessor == "" {
+			if accessor, err = lib.GenerateUUID(s.checkTokenUUID); err != nil {
+				return err
+			}
+		}
+
+		var links []structs.ACLTokenPolicyLink
+		for _, name := range t.Policies {
+			_, policy, err := state.ACLPolicyGetByName(nil, name)
+			if err != nil {
+				return err
+			}
+			if policy == nil {
+				return fmt.Errorf("no such ACL policy %q for token %q", name, accessor)
+			}
+			links = append(links, structs.ACLTokenPolicyLink{ID: policy.ID})
+		}
+
+		token := structs.ACLToken{
+			AccessorID:  accessor,
+			SecretID:    t.SecretID,
+			Description: t.Description,
+			Policies:    links,
+			Local:       t.Local,
+			CreateTime:  time.Now(),
+		}
+		token.SetHash(true)
+
+		req := structs.ACLTokenBatchSetRequest{
+			Tokens: structs.ACLTokens{&token},
+			CAS:    false,
+		}
+		if err := s.applyBootstrapData(structs.ACLTokenSetRequestType, &req); err != nil {
+			return fmt.Errorf("failed to create ACL token %q: %v", accessor, err)
+		}
+	}
+	return nil
+}
+
+// applyBootstrapData applies a write through Raft, and returns the error the
+// FSM returned, if any.
+func (s *Server) applyBootstrapData(t structs.MessageType, msg interface{}) error {
+	resp, err := s.raftApplyInternal(t, msg)
+	if err != nil {
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
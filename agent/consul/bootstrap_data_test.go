@@ -0,0 +1,194 @@
+package consul
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const testBootstrapDataHCL = `
+service "web" {
+  node    = "ext-web1"
+  address = "10.0.0.10"
+  port    = 80
+  tags    = ["primary"]
+}
+
+policy "web" {
+  rules = "service \"web\" { policy = \"write\" }"
+}
+
+token {
+  secret_id = "0d7e5ec6-0c4f-4dfb-9c53-c0f9b0f1e4d6"
+  policies  = ["web"]
+}
+`
+
+const testBootstrapDataJSON = `{
+  "key": {
+    "config/web/replicas": {
+      "value": "3",
+      "flags": 42
+    }
+  }
+}`
+
+func writeTestBootstrapData(t *testing.T, files map[string]string) string {
+	dir := testutil.TempDir(t, "bootstrap")
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestParseBootstrapData(t *testing.T) {
+	t.Parallel()
+
+	dir := writeTestBootstrapData(t, map[string]string{
+		"a.hcl":      testBootstrapDataHCL,
+		"b.json":     testBootstrapDataJSON,
+		"README.txt": "not loaded",
+	})
+	defer os.RemoveAll(dir)
+
+	data, err := parseBootstrapData(dir)
+	require.NoError(t, err)
+	require.Len(t, data.Services, 1)
+	require.Equal(t, "web", data.Services[0].Name)
+	require.Equal(t, "ext-web1", data.Services[0].Node)
+	require.Equal(t, 80, data.Services[0].Port)
+	require.Equal(t, []string{"primary"}, data.Services[0].Tags)
+	require.Len(t, data.Keys, 1)
+	require.Equal(t, "config/web/replicas", data.Keys[0].Key)
+	require.Equal(t, int64(42), data.Keys[0].Flags)
+	require.Len(t, data.Policies, 1)
+	require.Len(t, data.Tokens, 1)
+	require.Equal(t, []string{"web"}, data.Tokens[0].Policies)
+
+	invalid := map[string]string{
+		"no node":      `service "web" { address = "10.0.0.10" }`,
+		"reserved key": `key "_consul/foo" { value = "bar" }`,
+		"bad rules":    `policy "web" { rules = "service \"web\" { policy = \"nope\" }" }`,
+		"no secret":    `token { policies = ["web"] }`,
+		"bad syntax":   `service "web" {`,
+	}
+	for name, content := range invalid {
+		dir := writeTestBootstrapData(t, map[string]string{"bad.hcl": content})
+		_, err := parseBootstrapData(dir)
+		os.RemoveAll(dir)
+		require.Error(t, err, name)
+	}
+}
+
+func TestLeader_LoadBootstrapData(t *testing.T) {
+	t.Parallel()
+
+	bootstrapDir := writeTestBootstrapData(t, map[string]string{
+		"a.hcl":  testBootstrapDataHCL,
+		"b.json": testBootstrapDataJSON,
+	})
+	defer os.RemoveAll(bootstrapDir)
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.BootstrapDataDir = bootstrapDir
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	state := s1.fsm.State()
+	retry.Run(t, func(r *retry.R) {
+		if state.BootstrapDataLoaded(structs.BootstrapDataPartCatalog) == 0 ||
+			state.BootstrapDataLoaded(structs.BootstrapDataPartACLs) == 0 {
+			r.Fatal("bootstrap data not loaded")
+		}
+	})
+
+	// The markers aren't in the KV store, where they could be deleted.
+	_, keys, err := state.KVSList(nil, "_consul/")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	_, services, err := state.NodeServices(nil, "ext-web1")
+	require.NoError(t, err)
+	require.NotNil(t, services)
+	require.Equal(t, "10.0.0.10", services.Node.Address)
+	require.Contains(t, services.Services, "web")
+
+	_, entry, err := state.KVSGet(nil, "config/web/replicas")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	require.Equal(t, "3", string(entry.Value))
+	require.Equal(t, uint64(42), entry.Flags)
+
+	_, policy, err := state.ACLPolicyGetByName(nil, "web")
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+
+	_, token, err := state.ACLTokenGetBySecret(nil, "0d7e5ec6-0c4f-4dfb-9c53-c0f9b0f1e4d6")
+	require.NoError(t, err)
+	require.NotNil(t, token)
+	require.Len(t, token.Policies, 1)
+	require.Equal(t, policy.ID, token.Policies[0].ID)
+
+	// Once the marker is written the data isn't loaded again.
+	req := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVDelete,
+		DirEnt:     structs.DirEntry{Key: "config/web/replicas"},
+	}
+	require.NoError(t, s1.applyBootstrapData(structs.KVSRequestType, &req))
+	require.NoError(t, s1.loadBootstrapData())
+	_, entry, err = state.KVSGet(nil, "config/web/replicas")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+}
+
+func TestLeader_LoadBootstrapData_ACLsPending(t *testing.T) {
+	t.Parallel()
+
+	bootstrapDir := writeTestBootstrapData(t, map[string]string{
+		"a.hcl": testBootstrapDataHCL,
+	})
+	defer os.RemoveAll(bootstrapDir)
+
+	// Without the primary datacenter, the ACL system stays in legacy mode.
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc2"
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.BootstrapDataDir = bootstrapDir
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc2")
+
+	state := s1.fsm.State()
+	retry.Run(t, func(r *retry.R) {
+		if state.BootstrapDataLoaded(structs.BootstrapDataPartCatalog) == 0 {
+			r.Fatal("bootstrap data not loaded")
+		}
+	})
+	_, services, err := state.NodeServices(nil, "ext-web1")
+	require.NoError(t, err)
+	require.NotNil(t, services)
+
+	// The ACLs are left pending rather than recorded as loaded, so they're
+	// loaded once the ACL system leaves legacy mode.
+	require.True(t, s1.UseLegacyACLs())
+	require.NoError(t, s1.loadBootstrapData())
+	require.Equal(t, uint64(0), state.BootstrapDataLoaded(structs.BootstrapDataPartACLs))
+}
@@ -0,0 +1,183 @@
+package consul
+
+import (
+	"sort"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/serf/serf"
+)
+
+// startCatalogCheck starts a goroutine that periodically cross-checks the
+// catalog against Serf and removes orphaned services and checks.
+func (s *Server) startCatalogCheck() {
+	s.catalogCheckLock.Lock()
+	defer s.catalogCheckLock.Unlock()
+
+	if s.catalogCheckEnabled || s.config.CatalogCheckInterval <= 0 {
+		return
+	}
+
+	s.catalogCheckCh = make(chan struct{})
+
+	go func(stopCh chan struct{}) {
+		ticker := time.NewTicker(s.config.CatalogCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if err := s.checkCatalog(); err != nil {
+					s.logger.Printf("[ERR] consul: error checking catalog consistency: %v", err)
+				}
+			}
+		}
+	}(s.catalogCheckCh)
+
+	s.catalogCheckEnabled = true
+}
+
+// stopCatalogCheck stops the catalog consistency check and clears its last
+// report, since it's only meaningful on the leader.
+func (s *Server) stopCatalogCheck() {
+	s.catalogCheckLock.Lock()
+	defer s.catalogCheckLock.Unlock()
+
+	if !s.catalogCheckEnabled {
+		return
+	}
+
+	close(s.catalogCheckCh)
+	s.catalogCheckEnabled = false
+	s.catalogCheckReport = structs.CatalogConsistencyReport{}
+}
+
+// getCatalogCheckReport returns the findings of the last catalog consistency
+// check.
+func (s *Server) getCatalogCheckReport() structs.CatalogConsistencyReport {
+	s.catalogCheckLock.RLock()
+	defer s.catalogCheckLock.RUnlock()
+	return s.catalogCheckReport
+}
+
+// checkCatalog cross-checks the catalog against the Serf members and looks
+// for services and checks whose node or service doesn't exist. Orphaned
+// services and checks are deregistered. Nodes that don't match Serf are only
+// reported here, since the reconcile loop is responsible for fixing them.
+func (s *Server) checkCatalog() error {
+	defer metrics.MeasureSince([]string{"leader", "catalog_check"}, time.Now())
+
+	var report structs.CatalogConsistencyReport
+	state := s.fsm.State()
+
+	_, nodes, err := state.Nodes(nil)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]struct{})
+	for _, node := range nodes {
+		known[node.Node] = struct{}{}
+	}
+	members := make(map[string]struct{})
+	for _, member := range s.serfLAN.Members() {
+		members[member.Name] = struct{}{}
+		if member.Status != serf.StatusAlive || !s.shouldHandleMember(member) {
+			continue
+		}
+		if _, ok := known[member.Name]; !ok {
+			report.UnregisteredMembers = append(report.UnregisteredMembers, member.Name)
+		}
+	}
+	_, checks, err := state.ChecksInState(nil, api.HealthAny)
+	if err != nil {
+		return err
+	}
+	for _, check := range checks {
+		if check.CheckID != structs.SerfCheckID {
+			continue
+		}
+		if _, ok := members[check.Node]; !ok {
+			report.StaleNodes = append(report.StaleNodes, check.Node)
+		}
+	}
+	sort.Strings(report.UnregisteredMembers)
+	sort.Strings(report.StaleNodes)
+
+	orphanedServices, orphanedChecks, err := state.CatalogOrphans()
+	if err != nil {
+		return err
+	}
+	removed := make(map[structs.CatalogOrphan]struct{})
+	for _, service := range orphanedServices {
+		removed[structs.CatalogOrphan{Node: service.Node, ID: service.ServiceID}] = struct{}{}
+		report.OrphanedServices = append(report.OrphanedServices, structs.CatalogOrphan{
+			Node: service.Node,
+			ID:   service.ServiceID,
+		})
+		s.logger.Printf("[WARN] consul: removing orphaned service %q on missing node %q",
+			service.ServiceID, service.Node)
+		req := structs.DeregisterRequest{
+			Datacenter: s.config.Datacenter,
+			Node:       service.Node,
+			ServiceID:  service.ServiceID,
+		}
+		if err := s.deregisterOrphan(&req); err != nil {
+			return err
+		}
+		report.Repaired++
+	}
+	for _, check := range orphanedChecks {
+		report.OrphanedChecks = append(report.OrphanedChecks, structs.CatalogOrphan{
+			Node: check.Node,
+			ID:   string(check.CheckID),
+		})
+
+		// Checks of orphaned services were removed along with them.
+		if _, ok := removed[structs.CatalogOrphan{Node: check.Node, ID: check.ServiceID}]; ok {
+			continue
+		}
+		s.logger.Printf("[WARN] consul: removing orphaned check %q on node %q",
+			check.CheckID, check.Node)
+		req := structs.DeregisterRequest{
+			Datacenter: s.config.Datacenter,
+			Node:       check.Node,
+			CheckID:    check.CheckID,
+		}
+		if err := s.deregisterOrphan(&req); err != nil {
+			return err
+		}
+		report.Repaired++
+	}
+
+	metrics.SetGauge([]string{"leader", "catalog_check", "unregistered_members"}, float32(len(report.UnregisteredMembers)))
+	metrics.SetGauge([]string{"leader", "catalog_check", "stale_nodes"}, float32(len(report.StaleNodes)))
+	metrics.SetGauge([]string{"leader", "catalog_check", "orphaned_services"}, float32(len(report.OrphanedServices)))
+	metrics.SetGauge([]string{"leader", "catalog_check", "orphaned_checks"}, float32(len(report.OrphanedChecks)))
+	if report.Repaired > 0 {
+		metrics.IncrCounter([]string{"leader", "catalog_check", "repaired"}, float32(report.Repaired))
+	}
+
+	report.LastRun = time.Now().UTC()
+	s.catalogCheckLock.Lock()
+	if s.catalogCheckEnabled {
+		s.catalogCheckReport = report
+	}
+	s.catalogCheckLock.Unlock()
+	return nil
+}
+
+// deregisterOrphan applies the deregistration of an orphaned catalog entry.
+func (s *Server) deregisterOrphan(req *structs.DeregisterRequest) error {
+	resp, err := s.raftApplyInternal(structs.DeregisterRequestType, req)
+	if err != nil {
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+)
+
+func TestCatalogCheck(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		// Keep the reconcile loop from reaping the node we register below.
+		c.ReconcileInterval = time.Hour
+		c.CatalogCheckInterval = 100 * time.Millisecond
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register a node with a Serf health check that isn't a Serf member.
+	arg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "ghost",
+		Address:    "127.0.0.2",
+		Check: &structs.HealthCheck{
+			Node:    "ghost",
+			CheckID: structs.SerfCheckID,
+			Name:    structs.SerfCheckName,
+			Status:  api.HealthPassing,
+		},
+	}
+	var out struct{}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	retry.Run(t, func(r *retry.R) {
+		args := structs.DCSpecificRequest{
+			Datacenter: "dc1",
+		}
+		var reply structs.CatalogConsistencyReport
+		if err := msgpackrpc.CallWithCodec(codec, "Operator.CatalogConsistency", &args, &reply); err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if reply.LastRun.IsZero() {
+			r.Fatalf("check hasn't run")
+		}
+		if len(reply.StaleNodes) != 1 || reply.StaleNodes[0] != "ghost" {
+			r.Fatalf("bad: %#v", reply.StaleNodes)
+		}
+		if len(reply.UnregisteredMembers) != 0 || len(reply.OrphanedServices) != 0 ||
+			len(reply.OrphanedChecks) != 0 || reply.Repaired != 0 {
+			r.Fatalf("bad: %#v", reply)
+		}
+	})
+
+	// Losing leadership clears the report.
+	s1.stopCatalogCheck()
+	if report := s1.getCatalogCheckReport(); !report.LastRun.IsZero() {
+		t.Fatalf("bad: %#v", report)
+	}
+}
+
+func TestCatalogCheck_Disabled(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.CatalogCheckInterval = 0
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	s1.catalogCheckLock.RLock()
+	enabled := s1.catalogCheckEnabled
+	s1.catalogCheckLock.RUnlock()
+	if enabled {
+		t.Fatalf("catalog check should not be running")
+	}
+}
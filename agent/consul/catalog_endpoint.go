@@ -2,12 +2,16 @@ package consul
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/metadata"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/ipaddr"
 	"github.com/hashicorp/consul/types"
@@ -15,6 +19,16 @@ import (
 	"github.com/hashicorp/go-uuid"
 )
 
+const (
+	// maxDNSLabelLength is the longest a node or service name can be and
+	// still be served over DNS.
+	maxDNSLabelLength = 63
+)
+
+// invalidDNSNameRe matches the characters that can't be used in node and
+// service names served over DNS.
+var invalidDNSNameRe = regexp.MustCompile(`[^A-Za-z0-9\-]`)
+
 // Catalog endpoint is used to manipulate the service catalog
 type Catalog struct {
 	srv *Server
@@ -27,6 +41,13 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 	}
 	defer metrics.MeasureSince([]string{"catalog", "register"}, time.Now())
 
+	// Older servers would apply a CAS registration unconditionally.
+	if args.CAS {
+		if err := c.srv.checkDatacenterFeature(metadata.FeatureCatalogCAS, "CAS registration"); err != nil {
+			return err
+		}
+	}
+
 	// Verify the args.
 	if args.Node == "" {
 		return fmt.Errorf("Must provide node")
@@ -95,6 +116,7 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 		args.Checks = append(args.Checks, args.Check)
 		args.Check = nil
 	}
+	checkIDs := make(map[types.CheckID]bool, len(args.Checks))
 	for _, check := range args.Checks {
 		if check.CheckID == "" && check.Name != "" {
 			check.CheckID = types.CheckID(check.Name)
@@ -102,6 +124,13 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 		if check.Node == "" {
 			check.Node = args.Node
 		}
+		if check.Status != "" && !structs.ValidStatus(check.Status) {
+			return fmt.Errorf("Invalid status %q for check %q", check.Status, check.CheckID)
+		}
+		if checkIDs[check.CheckID] {
+			return fmt.Errorf("Check %q is registered more than once", check.CheckID)
+		}
+		checkIDs[check.CheckID] = true
 	}
 
 	// Check the complete register request against the given ACL policy.
@@ -116,6 +145,50 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 		}
 	}
 
+	// Skip the Raft write if nothing would change. Agents and external
+	// tools often re-send the same check status and output. Check-and-set
+	// registrations always go through Raft so the index gets checked, and
+	// unchanged registrations are still written once every liveness
+	// interval.
+	touchKey := registerTouchKey(args)
+	if !args.CAS {
+		current, err := c.srv.fsm.State().RegistrationIsCurrent(args)
+		if err != nil {
+			return err
+		}
+		if current {
+			if !c.srv.registerTouches.due(touchKey) {
+				metrics.IncrCounter([]string{"catalog", "register", "noop"}, 1)
+				return nil
+			}
+			metrics.IncrCounter([]string{"catalog", "register", "touch"}, 1)
+		}
+	}
+
+	// Make sure the names and IDs won't break DNS answers and API paths
+	// later on.
+	if err := c.vetRegisterNames(args); err != nil {
+		return err
+	}
+
+	// Make sure the registration won't grow the catalog past the limits.
+	if err := c.vetRegisterWithLimits(args); err != nil {
+		return err
+	}
+
+	// Only registrations that add to the catalog are held to the memory
+	// budget, so agents can keep their existing entries in sync, including
+	// health check updates, when the store is full.
+	adds, err := c.registerAddsEntries(args)
+	if err != nil {
+		return err
+	}
+	if adds {
+		if err := c.srv.admitStateWrite(args); err != nil {
+			return err
+		}
+	}
+
 	resp, err := c.srv.raftApply(structs.RegisterRequestType, args)
 	if err != nil {
 		return err
@@ -123,9 +196,170 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 	if respErr, ok := resp.(error); ok {
 		return respErr
 	}
+	if act, ok := resp.(bool); ok && !act {
+		return structs.ErrCASFailed
+	}
+	c.srv.registerTouches.written(touchKey)
+	return nil
+}
+
+// vetRegisterNames checks the names and IDs in the given register request. In
+// strict mode a request with any problems is rejected, and otherwise they're
+// only logged, so existing clients keep working.
+func (c *Catalog) vetRegisterNames(args *structs.RegisterRequest) error {
+	problems := registrationProblems(args, c.srv.config.MaxIDLength)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	metrics.IncrCounter([]string{"catalog", "register", "invalid"}, 1)
+	if c.srv.config.StrictRegistrationValidation {
+		return fmt.Errorf("%v: %s", structs.ErrInvalidRegistration, strings.Join(problems, "; "))
+	}
+	c.srv.logger.Printf("[WARN] consul: Registration for node %q has problems that "+
+		"registration_validation = \"strict\" would reject: %s", args.Node, strings.Join(problems, "; "))
+	return nil
+}
+
+// registrationProblems returns what's wrong with the names and IDs in the
+// given register request. Node and service names must be valid DNS labels,
+// since they're served over DNS, and service and check IDs must be at most
+// maxIDLength bytes (unless it's zero) without any whitespace or control
+// characters, since they're used in API paths.
+func registrationProblems(args *structs.RegisterRequest, maxIDLength int) []string {
+	var problems []string
+	name := func(kind, name string) {
+		switch {
+		case invalidDNSNameRe.MatchString(name):
+			problems = append(problems, fmt.Sprintf("%s name %q has characters other "+
+				"than alpha-numerics and dashes", kind, name))
+		case len(name) > maxDNSLabelLength:
+			problems = append(problems, fmt.Sprintf("%s name %q is longer than %d bytes",
+				kind, name, maxDNSLabelLength))
+		}
+	}
+	id := func(kind, id string) {
+		switch {
+		case strings.IndexFunc(id, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0:
+			problems = append(problems, fmt.Sprintf("%s ID %q has whitespace or control characters", kind, id))
+		case maxIDLength > 0 && len(id) > maxIDLength:
+			problems = append(problems, fmt.Sprintf("%s ID %q is longer than %d bytes", kind, id, maxIDLength))
+		}
+	}
+
+	if !args.SkipNodeUpdate {
+		name("Node", args.Node)
+	}
+	if args.Service != nil {
+		name("Service", args.Service.Service)
+		id("Service", args.Service.ID)
+	}
+	for _, check := range args.Checks {
+		id("Check", string(check.CheckID))
+	}
+	return problems
+}
+
+// vetRegisterWithLimits makes sure that the given register request won't take
+// the catalog over the configured node, service and check limits. Updates to
+// things that are already registered are always allowed, so a node at the
+// limit can still sync its existing services and checks.
+func (c *Catalog) vetRegisterWithLimits(args *structs.RegisterRequest) error {
+	config := c.srv.config
+	state := c.srv.fsm.State()
+
+	if config.MaxNodes > 0 {
+		_, node, err := state.GetNode(args.Node)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			count, err := state.NodeCount()
+			if err != nil {
+				return err
+			}
+			if count >= config.MaxNodes {
+				metrics.IncrCounterWithLabels([]string{"catalog", "register", "limited"}, 1,
+					[]metrics.Label{{Name: "limit", Value: "max_nodes"}})
+				return fmt.Errorf("%v: datacenter already has %d nodes, which is the most allowed by limits.max_nodes",
+					structs.ErrCatalogLimitExceeded, count)
+			}
+		}
+	}
+
+	if config.MaxServicesPerNode > 0 && args.Service != nil {
+		_, ns, err := state.NodeServices(nil, args.Node)
+		if err != nil {
+			return err
+		}
+		if ns != nil {
+			if _, ok := ns.Services[args.Service.ID]; !ok && len(ns.Services) >= config.MaxServicesPerNode {
+				metrics.IncrCounterWithLabels([]string{"catalog", "register", "limited"}, 1,
+					[]metrics.Label{{Name: "limit", Value: "max_services_per_node"}})
+				return fmt.Errorf("%v: node %q already has %d services, which is the most allowed by limits.max_services_per_node",
+					structs.ErrCatalogLimitExceeded, args.Node, len(ns.Services))
+			}
+		}
+	}
+
+	if config.MaxChecksPerNode > 0 && len(args.Checks) > 0 {
+		_, checks, err := state.NodeChecks(nil, args.Node)
+		if err != nil {
+			return err
+		}
+		ids := make(map[types.CheckID]struct{})
+		for _, check := range checks {
+			ids[check.CheckID] = struct{}{}
+		}
+		for _, check := range args.Checks {
+			ids[check.CheckID] = struct{}{}
+		}
+		if len(ids) > len(checks) && len(ids) > config.MaxChecksPerNode {
+			metrics.IncrCounterWithLabels([]string{"catalog", "register", "limited"}, 1,
+				[]metrics.Label{{Name: "limit", Value: "max_checks_per_node"}})
+			return fmt.Errorf("%v: node %q would have %d checks, but limits.max_checks_per_node allows at most %d",
+				structs.ErrCatalogLimitExceeded, args.Node, len(ids), config.MaxChecksPerNode)
+		}
+	}
+
 	return nil
 }
 
+// registerAddsEntries returns true if the given registration creates a node,
+// service or check that isn't in the catalog yet.
+func (c *Catalog) registerAddsEntries(args *structs.RegisterRequest) (bool, error) {
+	state := c.srv.fsm.State()
+
+	_, node, err := state.GetNode(args.Node)
+	if err != nil {
+		return false, err
+	}
+	if node == nil {
+		return true, nil
+	}
+
+	if args.Service != nil {
+		_, ns, err := state.NodeService(args.Node, args.Service.ID)
+		if err != nil {
+			return false, err
+		}
+		if ns == nil {
+			return true, nil
+		}
+	}
+
+	for _, check := range args.Checks {
+		_, hc, err := state.NodeCheck(args.Node, check.CheckID)
+		if err != nil {
+			return false, err
+		}
+		if hc == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Deregister is used to remove a service registration for a given node.
 func (c *Catalog) Deregister(args *structs.DeregisterRequest, reply *struct{}) error {
 	if done, err := c.srv.forward("Catalog.Deregister", args, args, reply); done {
@@ -133,6 +367,13 @@ func (c *Catalog) Deregister(args *structs.DeregisterRequest, reply *struct{}) e
 	}
 	defer metrics.MeasureSince([]string{"catalog", "deregister"}, time.Now())
 
+	// Older servers would apply a CAS deregistration unconditionally.
+	if args.CAS {
+		if err := c.srv.checkDatacenterFeature(metadata.FeatureCatalogCAS, "CAS deregistration"); err != nil {
+			return err
+		}
+	}
+
 	// Verify the args
 	if args.Node == "" {
 		return fmt.Errorf("Must provide node")
@@ -170,12 +411,122 @@ func (c *Catalog) Deregister(args *structs.DeregisterRequest, reply *struct{}) e
 
 	}
 
-	if _, err := c.srv.raftApply(structs.DeregisterRequestType, args); err != nil {
+	resp, err := c.srv.raftApply(structs.DeregisterRequestType, args)
+	if err != nil {
 		return err
 	}
+	if act, ok := resp.(bool); ok && !act {
+		return structs.ErrCASFailed
+	}
 	return nil
 }
 
+// UpdateCheck is used to update the status and output of a health check
+// that's already registered.
+func (c *Catalog) UpdateCheck(args *structs.CheckUpdateRequest, reply *struct{}) error {
+	if done, err := c.srv.forward("Catalog.UpdateCheck", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"catalog", "update_check"}, time.Now())
+
+	// Verify the args.
+	if args.Node == "" {
+		return fmt.Errorf("Must provide node")
+	}
+	if args.CheckID == "" {
+		return fmt.Errorf("Must provide check ID")
+	}
+	if !structs.ValidStatus(args.Status) {
+		return fmt.Errorf("Invalid status %q", args.Status)
+	}
+
+	// Fetch the ACL token, if any, and apply the policy.
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+
+	// The check is written back as a check-and-set against the node and
+	// check indexes it was read at, so concurrent updates to the same check
+	// can't overwrite each other. Older servers would apply the write
+	// unconditionally, so it's only made a CAS once they've all upgraded.
+	cas := len(serversMissingFeature(c.srv.serfLAN.Members(), c.srv.config.Datacenter, metadata.FeatureCatalogCAS)) == 0
+	for attempt := 0; ; attempt++ {
+		applied, err := c.updateCheck(args, rule, cas)
+		if err != nil || applied {
+			return err
+		}
+		if attempt == maxUpdateCheckAttempts-1 {
+			return structs.ErrCASFailed
+		}
+		metrics.IncrCounter([]string{"catalog", "update_check", "cas_retry"}, 1)
+	}
+}
+
+// maxUpdateCheckAttempts is how many times UpdateCheck retries when the check
+// changes between reading and writing it.
+const maxUpdateCheckAttempts = 5
+
+// updateCheck reads the check, applies the status and output from the
+// request and writes it back. It returns false if the write was a CAS that
+// failed because the node or check changed in the meantime.
+func (c *Catalog) updateCheck(args *structs.CheckUpdateRequest, rule acl.Authorizer, cas bool) (bool, error) {
+	state := c.srv.fsm.State()
+	_, node, err := state.GetNode(args.Node)
+	if err != nil {
+		return false, fmt.Errorf("Node lookup failed: %v", err)
+	}
+	_, check, err := state.NodeCheck(args.Node, args.CheckID)
+	if err != nil {
+		return false, fmt.Errorf("Check lookup failed: %v", err)
+	}
+	if node == nil || check == nil {
+		return false, fmt.Errorf("Unknown check '%s' for node '%s'", args.CheckID, args.Node)
+	}
+	if err := vetCheckUpdateWithACL(rule, args, check); err != nil {
+		return false, err
+	}
+
+	// Skip the Raft write if nothing would change, like Register does.
+	if check.Status == args.Status && check.Output == args.Output {
+		metrics.IncrCounter([]string{"catalog", "update_check", "noop"}, 1)
+		return true, nil
+	}
+
+	// Write the check back with the new status, leaving the node and the
+	// rest of the check alone.
+	update := check.Clone()
+	update.Status = args.Status
+	update.Output = args.Output
+	req := structs.RegisterRequest{
+		Datacenter:     args.Datacenter,
+		Node:           args.Node,
+		SkipNodeUpdate: true,
+		Checks:         structs.HealthChecks{update},
+		WriteRequest:   args.WriteRequest,
+	}
+	if cas {
+		// This is the index EnsureRegistrationCAS compares against for a
+		// registration without a service.
+		req.CAS = true
+		req.ModifyIndex = node.ModifyIndex
+		if check.ModifyIndex > req.ModifyIndex {
+			req.ModifyIndex = check.ModifyIndex
+		}
+	}
+	resp, err := c.srv.raftApply(structs.RegisterRequestType, &req)
+	if err != nil {
+		return false, err
+	}
+	if respErr, ok := resp.(error); ok {
+		return false, respErr
+	}
+	if act, ok := resp.(bool); ok && !act {
+		return false, nil
+	}
+	return true, nil
+}
+
 // ListDatacenters is used to query for the list of known datacenters
 func (c *Catalog) ListDatacenters(args *struct{}, reply *[]string) error {
 	dcs, err := c.srv.router.GetDatacentersByDistance()
@@ -198,6 +549,7 @@ func (c *Catalog) ListNodes(args *structs.DCSpecificRequest, reply *structs.Inde
 	}
 
 	return c.srv.blockingQuery(
+		"nodes",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -217,7 +569,51 @@ func (c *Catalog) ListNodes(args *structs.DCSpecificRequest, reply *structs.Inde
 			if err := c.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
-			return c.srv.sortNodesByDistanceFrom(args.Source, reply.Nodes)
+			if err := c.srv.sortNodesByDistanceFrom(ws, &reply.QueryMeta, args.Source, reply.Nodes); err != nil {
+				return err
+			}
+			reply.Nodes = reply.Nodes[:c.srv.truncateResults(&args.QueryOptions, &reply.QueryMeta, len(reply.Nodes))]
+			return nil
+		})
+}
+
+// NodesWithoutService is used to list the nodes that don't have a passing
+// instance of a service, such as the ones a rollout hasn't reached yet.
+func (c *Catalog) NodesWithoutService(args *structs.ServiceSpecificRequest, reply *structs.IndexedNodes) error {
+	if done, err := c.srv.forward("Catalog.NodesWithoutService", args, args, reply); done {
+		return err
+	}
+
+	// Verify the arguments
+	if args.ServiceName == "" {
+		return fmt.Errorf("Must provide service name")
+	}
+
+	// Without read access to the service, every node would look like it
+	// doesn't have it, so deny the request instead.
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.ServiceRead(args.ServiceName) {
+		return acl.ErrPermissionDenied
+	}
+
+	return c.srv.blockingQuery(
+		"services",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, nodes, err := state.NodesWithoutService(ws, args.ServiceName, args.NodeMetaFilters)
+			if err != nil {
+				return err
+			}
+
+			reply.Index, reply.Nodes = index, nodes
+			if err := c.srv.filterACL(args.Token, reply); err != nil {
+				return err
+			}
+			return c.srv.sortNodesByDistanceFrom(ws, &reply.QueryMeta, args.Source, reply.Nodes)
 		})
 }
 
@@ -228,6 +624,7 @@ func (c *Catalog) ListServices(args *structs.DCSpecificRequest, reply *structs.I
 	}
 
 	return c.srv.blockingQuery(
+		"services",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -237,7 +634,7 @@ func (c *Catalog) ListServices(args *structs.DCSpecificRequest, reply *structs.I
 			if len(args.NodeMetaFilters) > 0 {
 				index, services, err = state.ServicesByNodeMeta(ws, args.NodeMetaFilters)
 			} else {
-				index, services, err = state.Services(ws)
+				index, services, err = c.srv.queryCache.services(ws, state)
 			}
 			if err != nil {
 				return err
@@ -250,6 +647,9 @@ func (c *Catalog) ListServices(args *structs.DCSpecificRequest, reply *structs.I
 
 // ServiceNodes returns all the nodes registered as part of a service
 func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *structs.IndexedServiceNodes) error {
+	if len(args.MultiDC) > 0 {
+		return c.serviceNodesMultiDC(args, reply)
+	}
 	if done, err := c.srv.forward("Catalog.ServiceNodes", args, args, reply); done {
 		return err
 	}
@@ -283,10 +683,10 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 					tags = []string{args.ServiceTag}
 				}
 
-				return s.ServiceTagNodes(ws, args.ServiceName, tags)
+				return c.srv.queryCache.serviceNodes(ws, s, args.ServiceName, tags)
 			}
 
-			return s.ServiceNodes(ws, args.ServiceName)
+			return c.srv.queryCache.serviceNodes(ws, s, args.ServiceName, nil)
 		}
 	}
 
@@ -306,6 +706,7 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 	}
 
 	err := c.srv.blockingQuery(
+		"services",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -327,7 +728,11 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 			if err := c.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
-			return c.srv.sortNodesByDistanceFrom(args.Source, reply.ServiceNodes)
+			if err := c.srv.sortNodesByDistanceFrom(ws, &reply.QueryMeta, args.Source, reply.ServiceNodes); err != nil {
+				return err
+			}
+			reply.ServiceNodes = reply.ServiceNodes[:c.srv.truncateResults(&args.QueryOptions, &reply.QueryMeta, len(reply.ServiceNodes))]
+			return nil
 		})
 
 	// Provide some metrics
@@ -364,9 +769,53 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 		}
 	}
 
+	if err == nil && args.Sign {
+		reply.Signature, err = c.srv.signResponse("Catalog.ServiceNodes", args, reply.Index, signedServiceNodes(reply.ServiceNodes))
+	}
 	return err
 }
 
+// serviceNodesMultiDC queries each of the datacenters in the request and
+// merges the nodes, with the nodes of the datacenters closest to us first.
+// The reply keeps the index of the local query, so blocking queries wait for
+// changes to the local datacenter.
+func (c *Catalog) serviceNodesMultiDC(args *structs.ServiceSpecificRequest, reply *structs.IndexedServiceNodes) error {
+	dcs, err := c.srv.sortDatacentersByDistance(args.MultiDC)
+	if err != nil {
+		return err
+	}
+
+	localArgs := *args
+	localArgs.Datacenter = c.srv.config.Datacenter
+	localArgs.MultiDC = nil
+	var local structs.IndexedServiceNodes
+	if err := c.ServiceNodes(&localArgs, &local); err != nil {
+		return err
+	}
+
+	replies := c.srv.forwardMultiDC("Catalog.ServiceNodes", dcs, args, func() interface{} {
+		return &structs.IndexedServiceNodes{}
+	})
+	replies[c.srv.config.Datacenter] = &local
+
+	reply.QueryMeta = local.QueryMeta
+	reply.ServiceNodes = nil
+	for _, dc := range dcs {
+		remote, ok := replies[dc]
+		if !ok {
+			continue
+		}
+		for _, node := range remote.(*structs.IndexedServiceNodes).ServiceNodes {
+			// The local nodes may point into the state store, so they're
+			// copied before tagging them with their datacenter.
+			n := *node
+			n.Datacenter = dc
+			reply.ServiceNodes = append(reply.ServiceNodes, &n)
+		}
+	}
+	return nil
+}
+
 // NodeServices returns all the services registered as part of a node
 func (c *Catalog) NodeServices(args *structs.NodeSpecificRequest, reply *structs.IndexedNodeServices) error {
 	if done, err := c.srv.forward("Catalog.NodeServices", args, args, reply); done {
@@ -379,6 +828,7 @@ func (c *Catalog) NodeServices(args *structs.NodeSpecificRequest, reply *structs
 	}
 
 	return c.srv.blockingQuery(
+		"services",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -5,6 +5,7 @@ import (
 	"net/rpc"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -535,6 +536,291 @@ func TestCatalog_Deregister(t *testing.T) {
 	}
 }
 
+func TestCatalog_registrationProblems(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("a", 64)
+	cases := []struct {
+		name     string
+		args     structs.RegisterRequest
+		problems []string
+	}{
+		{
+			"valid",
+			structs.RegisterRequest{
+				Node:    "node-1",
+				Service: &structs.NodeService{ID: "web:1", Service: "web"},
+				Checks:  structs.HealthChecks{&structs.HealthCheck{CheckID: "service:web:1"}},
+			},
+			nil,
+		},
+		{
+			"bad names",
+			structs.RegisterRequest{
+				Node:    "node_1",
+				Service: &structs.NodeService{ID: "web", Service: long},
+			},
+			[]string{
+				`Node name "node_1" has characters other than alpha-numerics and dashes`,
+				`Service name "` + long + `" is longer than 63 bytes`,
+			},
+		},
+		{
+			"node name skipped",
+			structs.RegisterRequest{
+				Node:           "node.example",
+				SkipNodeUpdate: true,
+			},
+			nil,
+		},
+		{
+			"bad IDs",
+			structs.RegisterRequest{
+				Node:    "node",
+				Service: &structs.NodeService{ID: strings.Repeat("b", 17), Service: "web"},
+				Checks:  structs.HealthChecks{&structs.HealthCheck{CheckID: "check one"}},
+			},
+			[]string{
+				`Service ID "bbbbbbbbbbbbbbbbb" is longer than 16 bytes`,
+				`Check ID "check one" has whitespace or control characters`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.problems, registrationProblems(&tc.args, 16))
+		})
+	}
+}
+
+func TestCatalog_Register_StrictValidation(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.StrictRegistrationValidation = true
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "db_primary",
+			Port:    8000,
+		},
+	}
+	var out struct{}
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	require.True(t, structs.IsErrInvalidRegistration(err), err)
+
+	arg.Service.Service = "db-primary"
+	arg.Service.ID = ""
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+}
+
+func TestCatalog_UpdateCheck(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register a node without an agent, along with several checks.
+	reg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "external",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "db",
+			Port:    5432,
+		},
+		Checks: structs.HealthChecks{
+			&structs.HealthCheck{CheckID: "ping", Name: "ping"},
+			&structs.HealthCheck{CheckID: "db-port", Name: "db-port", ServiceID: "db"},
+		},
+	}
+	var out struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.Register", &reg, &out))
+
+	// Checks can't be registered twice in one request, or with a bad status.
+	bad := reg
+	bad.Checks = structs.HealthChecks{
+		&structs.HealthCheck{CheckID: "ping"},
+		&structs.HealthCheck{CheckID: "ping"},
+	}
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &bad, &out)
+	require.Error(err)
+	require.Contains(err.Error(), "registered more than once")
+
+	bad.Checks = structs.HealthChecks{
+		&structs.HealthCheck{CheckID: "ping", Status: "fluffy"},
+	}
+	err = msgpackrpc.CallWithCodec(codec, "Catalog.Register", &bad, &out)
+	require.Error(err)
+	require.Contains(err.Error(), "Invalid status")
+
+	// Update the status of the service check.
+	update := structs.CheckUpdateRequest{
+		Datacenter: "dc1",
+		Node:       "external",
+		CheckID:    "db-port",
+		Status:     api.HealthPassing,
+		Output:     "port is open",
+	}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.UpdateCheck", &update, &out))
+
+	state := s1.fsm.State()
+	_, checks, err := state.NodeChecks(nil, "external")
+	require.NoError(err)
+	require.Len(checks, 2)
+	for _, check := range checks {
+		switch check.CheckID {
+		case "ping":
+			require.Equal(api.HealthCritical, check.Status)
+		case "db-port":
+			require.Equal(api.HealthPassing, check.Status)
+			require.Equal("port is open", check.Output)
+			require.Equal("db", check.ServiceID)
+		}
+	}
+
+	// The same update again doesn't change anything.
+	idx, _, err := state.NodeChecks(nil, "external")
+	require.NoError(err)
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.UpdateCheck", &update, &out))
+	after, _, err := state.NodeChecks(nil, "external")
+	require.NoError(err)
+	require.Equal(idx, after)
+
+	// Unknown checks and bad statuses are rejected.
+	update.CheckID = "nope"
+	err = msgpackrpc.CallWithCodec(codec, "Catalog.UpdateCheck", &update, &out)
+	require.Error(err)
+	require.Contains(err.Error(), "Unknown check")
+
+	update.CheckID = "ping"
+	update.Status = "fluffy"
+	err = msgpackrpc.CallWithCodec(codec, "Catalog.UpdateCheck", &update, &out)
+	require.Error(err)
+	require.Contains(err.Error(), "Invalid status")
+
+	// Concurrent updates to the same check are all applied in turn, and
+	// the check ends up with the output of one of them.
+	var wg sync.WaitGroup
+	errCh := make(chan error, maxUpdateCheckAttempts-1)
+	for i := 0; i < maxUpdateCheckAttempts-1; i++ {
+		c := rpcClient(t, s1)
+		defer c.Close()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := structs.CheckUpdateRequest{
+				Datacenter: "dc1",
+				Node:       "external",
+				CheckID:    "ping",
+				Status:     api.HealthWarning,
+				Output:     fmt.Sprintf("update %d", i),
+			}
+			var out struct{}
+			errCh <- msgpackrpc.CallWithCodec(c, "Catalog.UpdateCheck", &req, &out)
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		require.NoError(err)
+	}
+	_, check, err := state.NodeCheck("external", "ping")
+	require.NoError(err)
+	require.Equal(api.HealthWarning, check.Status)
+	require.Contains(check.Output, "update ")
+}
+
+func TestCatalog_UpdateCheck_ACLDeny(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register a node check and a service check.
+	reg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "node",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "service",
+			Port:    8000,
+		},
+		Checks: structs.HealthChecks{
+			&structs.HealthCheck{CheckID: "node-check"},
+			&structs.HealthCheck{CheckID: "service-check", ServiceID: "service"},
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var out struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.Register", &reg, &out))
+
+	// Create a token that can only write the service.
+	arg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name: "User token",
+			Type: structs.ACLTokenTypeClient,
+			Rules: `
+service "service" {
+	policy = "write"
+}
+`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var id string
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &id))
+
+	update := func(checkID types.CheckID, token string) error {
+		return msgpackrpc.CallWithCodec(codec, "Catalog.UpdateCheck",
+			&structs.CheckUpdateRequest{
+				Datacenter:   "dc1",
+				Node:         "node",
+				CheckID:      checkID,
+				Status:       api.HealthPassing,
+				WriteRequest: structs.WriteRequest{Token: token},
+			}, &out)
+	}
+
+	// Without a token nothing can be updated.
+	err := update("service-check", "")
+	require.True(acl.IsErrPermissionDenied(err), err)
+
+	// The token can update the service check, but not the node check.
+	require.NoError(update("service-check", id))
+	err = update("node-check", id)
+	require.True(acl.IsErrPermissionDenied(err), err)
+
+	// The master token can update both.
+	require.NoError(update("node-check", "root"))
+}
+
 func TestCatalog_Deregister_ACLDeny(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
@@ -926,6 +1212,53 @@ func TestCatalog_ListNodes_NodeMetaFilter(t *testing.T) {
 	})
 }
 
+func TestCatalog_ListNodes_MaxResults(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	for i, name := range []string{"foo1", "foo2", "foo3"} {
+		node := &structs.Node{Node: name, Address: "127.0.0.1"}
+		if err := s1.fsm.State().EnsureNode(uint64(i+1), node); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Get all the nodes, including the server's.
+	args := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	var all structs.IndexedNodes
+	retry.Run(t, func(r *retry.R) {
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &all); err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if got, want := len(all.Nodes), 4; got != want {
+			r.Fatalf("got %d nodes want %d", got, want)
+		}
+	})
+	require.False(t, all.ResultsTruncated)
+
+	// A limit returns the first nodes in the usual order.
+	args.MaxResults = 2
+	var out structs.IndexedNodes
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &out))
+	require.Equal(t, all.Nodes[:2], out.Nodes)
+	require.True(t, out.ResultsTruncated)
+
+	// A limit covering all the nodes doesn't truncate anything.
+	args.MaxResults = 4
+	out = structs.IndexedNodes{}
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &out))
+	require.Len(t, out.Nodes, 4)
+	require.False(t, out.ResultsTruncated)
+}
+
 func TestCatalog_ListNodes_StaleRead(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServer(t)
@@ -1913,6 +2246,43 @@ func TestCatalog_ListServiceNodes_DistanceSort(t *testing.T) {
 	}
 }
 
+func TestCatalog_ListServiceNodes_MultiDC(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	dir2, s2 := testServerDC(t, "dc2")
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+	testrpc.WaitForLeader(t, s2.RPC, "dc2")
+	joinWAN(t, s2, s1)
+
+	s1.fsm.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"})
+	s1.fsm.State().EnsureService(2, "foo", &structs.NodeService{ID: "db", Service: "db", Port: 5000})
+	s2.fsm.State().EnsureNode(1, &structs.Node{Node: "bar", Address: "127.0.0.2"})
+	s2.fsm.State().EnsureService(2, "bar", &structs.NodeService{ID: "db", Service: "db", Port: 5000})
+
+	args := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+		MultiDC:     []string{"dc2", "dc1"},
+	}
+	var out structs.IndexedServiceNodes
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.ServiceNodes", &args, &out))
+	require.Len(out.ServiceNodes, 2)
+	require.Equal("foo", out.ServiceNodes[0].Node)
+	require.Equal("dc1", out.ServiceNodes[0].Datacenter)
+	require.Equal("bar", out.ServiceNodes[1].Node)
+	require.Equal("dc2", out.ServiceNodes[1].Datacenter)
+}
+
 func TestCatalog_ListServiceNodes_ConnectProxy(t *testing.T) {
 	t.Parallel()
 
@@ -2570,3 +2940,257 @@ func TestCatalog_NodeServices_FilterACL(t *testing.T) {
 		t.Fatalf("bad: %#v", reply.NodeServices)
 	}
 }
+
+func TestCatalog_Register_SkipsNoop(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	register := func(status string) {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				Service: "db",
+				Tags:    []string{"master"},
+				Port:    8000,
+			},
+			Check: &structs.HealthCheck{
+				Name:      "db-check",
+				ServiceID: "db",
+				Status:    status,
+				Output:    "output",
+			},
+		}
+		var out struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Registering the same thing again shouldn't write to Raft, which would
+	// bump the index of the checks.
+	state := s1.fsm.State()
+	register(api.HealthPassing)
+	index, _, err := state.NodeChecks(nil, "foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	register(api.HealthPassing)
+	if got, _, _ := state.NodeChecks(nil, "foo"); got != index {
+		t.Fatalf("bad: %d != %d", got, index)
+	}
+
+	// A status change should be written.
+	register(api.HealthCritical)
+	if got, _, _ := state.NodeChecks(nil, "foo"); got == index {
+		t.Fatalf("registration should have been written")
+	}
+	_, checks, err := state.NodeChecks(nil, "foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(checks) != 1 || checks[0].Status != api.HealthCritical {
+		t.Fatalf("bad: %#v", checks)
+	}
+}
+
+func TestCatalog_Register_LivenessTouch(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.CheckLivenessInterval = 500 * time.Millisecond
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	register := func() {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Check: &structs.HealthCheck{
+				Name:   "foo-check",
+				Status: api.HealthPassing,
+				Output: "output",
+			},
+		}
+		var out struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	checksIndex := func() uint64 {
+		return s1.fsm.State().MaxIndexWatch(nil, "checks")
+	}
+
+	// An unchanged registration is skipped within the interval.
+	register()
+	index := checksIndex()
+	register()
+	if got := checksIndex(); got != index {
+		t.Fatalf("bad: %d != %d", got, index)
+	}
+
+	// Once the interval has passed it's written as a liveness touch, and
+	// then skipped again.
+	time.Sleep(600 * time.Millisecond)
+	register()
+	touched := checksIndex()
+	if touched <= index {
+		t.Fatalf("registration should have been written: %d", touched)
+	}
+	register()
+	if got := checksIndex(); got != touched {
+		t.Fatalf("bad: %d != %d", got, touched)
+	}
+}
+
+func TestCatalog_Register_Limits(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.MaxNodes = 2
+		c.MaxServicesPerNode = 2
+		c.MaxChecksPerNode = 2
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Wait for the leader to register itself, which counts against the
+	// node limit.
+	retry.Run(t, func(r *retry.R) {
+		_, nodes, err := s1.fsm.State().Nodes(nil)
+		if err != nil {
+			r.Fatal(err)
+		}
+		if len(nodes) != 1 {
+			r.Fatalf("bad: %v", nodes)
+		}
+	})
+
+	register := func(node, service string, checks ...string) error {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+		}
+		if service != "" {
+			arg.Service = &structs.NodeService{
+				Service: service,
+				Port:    8000,
+			}
+		}
+		for _, check := range checks {
+			arg.Checks = append(arg.Checks, &structs.HealthCheck{
+				Name:   check,
+				Status: api.HealthPassing,
+			})
+		}
+		var out struct{}
+		return msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	}
+
+	// Nodes.
+	require.NoError(t, register("foo", ""))
+	err := register("bar", "")
+	require.True(t, structs.IsErrCatalogLimitExceeded(err), "err: %v", err)
+	require.Contains(t, err.Error(), "limits.max_nodes")
+	require.NoError(t, register("foo", ""))
+
+	// Services.
+	require.NoError(t, register("foo", "db"))
+	require.NoError(t, register("foo", "web"))
+	err = register("foo", "cache")
+	require.True(t, structs.IsErrCatalogLimitExceeded(err), "err: %v", err)
+	require.Contains(t, err.Error(), "limits.max_services_per_node")
+	require.NoError(t, register("foo", "db"))
+
+	// Checks.
+	require.NoError(t, register("foo", "", "one"))
+	require.NoError(t, register("foo", "", "two"))
+	err = register("foo", "", "three")
+	require.True(t, structs.IsErrCatalogLimitExceeded(err), "err: %v", err)
+	require.Contains(t, err.Error(), "limits.max_checks_per_node")
+	err = register("foo", "", "one", "two", "three")
+	require.True(t, structs.IsErrCatalogLimitExceeded(err), "err: %v", err)
+
+	// Existing checks can still be updated.
+	require.NoError(t, register("foo", "", "one", "two"))
+
+	_, checks, err := s1.fsm.State().NodeChecks(nil, "foo")
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+}
+
+func TestCatalog_Register_CAS(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "db",
+			Port:    8000,
+		},
+		CAS: true,
+	}
+	var out struct{}
+
+	// An index of 0 only registers the service if it's not there yet.
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	require.True(t, structs.IsErrCASFailed(err), "err: %v", err)
+
+	state := s1.fsm.State()
+	_, ns, err := state.NodeService("foo", "db")
+	require.NoError(t, err)
+	require.NotNil(t, ns)
+
+	// Only the current index updates it, even if nothing changed.
+	arg.ModifyIndex = ns.ModifyIndex - 1
+	err = msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	require.True(t, structs.IsErrCASFailed(err), "err: %v", err)
+	arg.ModifyIndex = ns.ModifyIndex
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+
+	// Deregistering with a stale index fails too.
+	dereg := structs.DeregisterRequest{
+		Datacenter:  "dc1",
+		Node:        "foo",
+		ServiceID:   "db",
+		CAS:         true,
+		ModifyIndex: ns.ModifyIndex - 1,
+	}
+	err = msgpackrpc.CallWithCodec(codec, "Catalog.Deregister", &dereg, &out)
+	require.True(t, structs.IsErrCASFailed(err), "err: %v", err)
+	_, ns, err = state.NodeService("foo", "db")
+	require.NoError(t, err)
+	require.NotNil(t, ns)
+
+	dereg.ModifyIndex = ns.ModifyIndex
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.Deregister", &dereg, &out))
+	_, ns, err = state.NodeService("foo", "db")
+	require.NoError(t, err)
+	require.Nil(t, ns)
+}
@@ -0,0 +1,156 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+var (
+	// ErrChangeFeedDisabled is returned if the change feed isn't enabled on
+	// the servers.
+	ErrChangeFeedDisabled = errors.New("Change feed is disabled")
+
+	// ErrChangeFeedExpired is returned if entries after the requested index
+	// were already trimmed from the change feed, so the consumer has to
+	// resynchronize from the current state.
+	ErrChangeFeedExpired = errors.New("Change feed index expired")
+)
+
+// ChangeFeed reads the log of catalog and KV changes kept by the servers.
+type ChangeFeed struct {
+	// srv is a pointer back to the server.
+	srv *Server
+}
+
+// List returns the change feed entries after the MinQueryIndex of the request,
+// blocking until there are some. Changes the token can't read are left out.
+func (c *ChangeFeed) List(args *structs.ChangeFeedRequest, reply *structs.IndexedChangeFeed) error {
+	if done, err := c.srv.forward("ChangeFeed.List", args, args, reply); done {
+		return err
+	}
+
+	if c.srv.fsm.State().ChangeFeedMaxEntries() <= 0 {
+		return ErrChangeFeedDisabled
+	}
+	if args.Limit < 0 {
+		return fmt.Errorf("Bad limit '%d', must be >= 0", args.Limit)
+	}
+
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+
+	since := args.MinQueryIndex
+	return c.srv.blockingQuery(
+		"change-feed",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, trimmed, entries, more, err := state.ChangeFeedList(ws, since, args.Limit)
+			if err != nil {
+				return err
+			}
+			if since > 0 && since < trimmed {
+				return fmt.Errorf("%v: index %d is older than the oldest retained change", ErrChangeFeedExpired, since)
+			}
+
+			reply.Index = index
+			reply.More = more
+			reply.NextIndex = since
+			if len(entries) > 0 {
+				reply.NextIndex = entries[len(entries)-1].Index
+			}
+			reply.Entries = filterChangeFeed(rule, entries)
+			return nil
+		})
+}
+
+// filterChangeFeed removes the changes the token can't read, dropping entries
+// that have none left.
+func filterChangeFeed(rule acl.Authorizer, entries structs.ChangeFeedEntries) structs.ChangeFeedEntries {
+	if rule == nil {
+		return entries
+	}
+
+	filtered := structs.ChangeFeedEntries{}
+	for _, entry := range entries {
+		var changes []*structs.Change
+		for _, change := range entry.Changes {
+			if canReadChange(rule, change) {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if len(changes) < len(entry.Changes) {
+			entry = &structs.ChangeFeedEntry{
+				Index:   entry.Index,
+				Seq:     entry.Seq,
+				Changes: changes,
+			}
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// canReadChange returns true if the token can read the data the change is
+// about.
+func canReadChange(rule acl.Authorizer, change *structs.Change) bool {
+	switch change.Type {
+	case structs.ChangeKV:
+		return rule.KeyRead(change.Key)
+	case structs.ChangeNode:
+		return rule.NodeRead(change.Node)
+	case structs.ChangeService:
+		// Deletes only have the service ID, so they fall back to the
+		// node's rules.
+		if change.Service != nil {
+			return rule.ServiceRead(change.Service.Service)
+		}
+		return rule.NodeRead(change.Node)
+	case structs.ChangeCheck:
+		if change.Check != nil && change.Check.ServiceName != "" {
+			return rule.ServiceRead(change.Check.ServiceName)
+		}
+		return rule.NodeRead(change.Node)
+	default:
+		return false
+	}
+}
+
+// syncChangeFeedConfig writes the leader's change feed limit to Raft if it
+// differs from the one the servers are using. The limit is kept in the state
+// store so every server records the same changes, no matter how they are
+// configured. It's called again as the leader reconciles, since it can't be
+// written until every server supports the change feed.
+func (s *Server) syncChangeFeedConfig() {
+	maxEntries := s.config.ChangeFeedMaxEntries
+	if maxEntries < 0 {
+		maxEntries = 0
+	}
+	if s.fsm.State().ChangeFeedMaxEntries() == maxEntries {
+		return
+	}
+
+	req := structs.ChangeFeedConfigRequest{
+		Datacenter: s.config.Datacenter,
+		MaxEntries: maxEntries,
+	}
+	resp, err := s.raftApplyInternal(structs.ChangeFeedConfigRequestType, &req)
+	if respErr, ok := resp.(error); ok {
+		err = respErr
+	}
+	if err != nil {
+		s.logger.Printf("[WARN] consul: Failed to set the change feed limit: %v", err)
+		return
+	}
+	s.logger.Printf("[INFO] consul: Set the change feed limit to %d entries", maxEntries)
+}
@@ -0,0 +1,186 @@
+package consul
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeed_List(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ChangeFeedMaxEntries = 3
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	// The leader registers itself in the catalog, so wait for that to be
+	// recorded before looking at the feed.
+	testrpc.WaitForTestAgent(t, s1.RPC, "dc1")
+
+	setKey := func(key string) {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         api.KVSet,
+			DirEnt:     structs.DirEntry{Key: key, Value: []byte("test")},
+		}
+		var out bool
+		require.NoError(t, s1.RPC("KVS.Apply", &arg, &out))
+	}
+	list := func(index uint64, limit int) (*structs.IndexedChangeFeed, error) {
+		arg := structs.ChangeFeedRequest{
+			Datacenter:   "dc1",
+			Limit:        limit,
+			QueryOptions: structs.QueryOptions{MinQueryIndex: index, MaxQueryTime: time.Second},
+		}
+		var out structs.IndexedChangeFeed
+		err := msgpackrpc.CallWithCodec(codec, "ChangeFeed.List", &arg, &out)
+		return &out, err
+	}
+
+	out, err := list(0, 0)
+	require.NoError(t, err)
+	start := out.NextIndex
+
+	setKey("foo")
+	out, err = list(start, 0)
+	require.NoError(t, err)
+	require.Len(t, out.Entries, 1)
+	require.Equal(t, "foo", out.Entries[0].Changes[0].Key)
+	require.Equal(t, out.Entries[0].Index, out.NextIndex)
+	require.False(t, out.More)
+
+	// Reading from the last position blocks until there's a new write.
+	next := out.NextIndex
+	begin := time.Now()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		setKey("bar")
+	}()
+	out, err = list(next, 0)
+	require.NoError(t, err)
+	require.True(t, time.Since(begin) >= 100*time.Millisecond)
+	require.Len(t, out.Entries, 1)
+	require.Equal(t, "bar", out.Entries[0].Changes[0].Key)
+
+	// Limits split the entries into pages.
+	setKey("baz")
+	out, err = list(next, 1)
+	require.NoError(t, err)
+	require.Len(t, out.Entries, 1)
+	require.True(t, out.More)
+	out, err = list(out.NextIndex, 1)
+	require.NoError(t, err)
+	require.Equal(t, "baz", out.Entries[0].Changes[0].Key)
+
+	// Once entries after a position are trimmed, reading from it fails.
+	for i := 0; i < 3; i++ {
+		setKey(fmt.Sprintf("key%d", i))
+	}
+	_, err = list(next, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrChangeFeedExpired.Error())
+
+	// Starting from scratch returns what's left.
+	out, err = list(0, 0)
+	require.NoError(t, err)
+	require.Len(t, out.Entries, 3)
+	require.Equal(t, "key0", out.Entries[0].Changes[0].Key)
+
+	_, err = list(0, -1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Bad limit")
+}
+
+func TestChangeFeed_List_Disabled(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.ChangeFeedRequest{Datacenter: "dc1"}
+	var out structs.IndexedChangeFeed
+	err := msgpackrpc.CallWithCodec(codec, "ChangeFeed.List", &arg, &out)
+	require.Error(t, err)
+	require.Equal(t, ErrChangeFeedDisabled.Error(), err.Error())
+}
+
+func TestChangeFeed_List_ACLFilter(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.ChangeFeedMaxEntries = 10
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+	retry.Run(t, func(r *retry.R) {
+		if got := s1.fsm.State().ChangeFeedMaxEntries(); got != 10 {
+			r.Fatalf("got %d entries, want 10", got)
+		}
+	})
+
+	for _, key := range []string{"public/a", "private/b"} {
+		arg := structs.KVSRequest{
+			Datacenter:   "dc1",
+			Op:           api.KVSet,
+			DirEnt:       structs.DirEntry{Key: key},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out bool
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out))
+	}
+
+	aclReq := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name:  "User token",
+			Type:  structs.ACLTokenTypeClient,
+			Rules: `key "public/" { policy = "read" }`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var token string
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "ACL.Apply", &aclReq, &token))
+
+	arg := structs.ChangeFeedRequest{
+		Datacenter:   "dc1",
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	var out structs.IndexedChangeFeed
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "ChangeFeed.List", &arg, &out))
+
+	var keys []string
+	for _, entry := range out.Entries {
+		for _, change := range entry.Changes {
+			if change.Type == structs.ChangeKV {
+				keys = append(keys, change.Key)
+			}
+		}
+	}
+	require.Equal(t, []string{"public/a"}, keys)
+
+	// The position still moves past the entries that were left out.
+	require.Equal(t, out.Index, out.NextIndex)
+}
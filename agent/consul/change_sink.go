@@ -0,0 +1,357 @@
+package consul
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	// changeSinkMaxRetryBackoff is the maximum number of seconds to wait
+	// before publishing a batch again after a failure.
+	changeSinkMaxRetryBackoff = 64
+
+	// changeSinkRetryInterval is how long to wait before looking at the
+	// change-sink config entries again after a failure.
+	changeSinkRetryInterval = 5 * time.Second
+)
+
+// changeSink publishes batches of catalog changes to an external system.
+// Publish must only return nil once the system has accepted the batch.
+type changeSink interface {
+	Publish(batch *structs.ChangeSinkBatch) error
+}
+
+// changeSinkTypes has the constructors for each type of change sink.
+var changeSinkTypes = map[string]func(*structs.ChangeSinkConfig) changeSink{
+	structs.ChangeSinkWebhook: newWebhookChangeSink,
+}
+
+// runningChangeSink tracks the goroutine publishing for a change sink.
+type runningChangeSink struct {
+	modifyIndex uint64
+	stopCh      chan struct{}
+}
+
+// startChangeSinks starts a goroutine that runs a publisher for every
+// change-sink config entry, restarting them as the entries change. This only
+// runs on the leader, and needs the change feed to be enabled.
+func (s *Server) startChangeSinks() {
+	s.changeSinksLock.Lock()
+	defer s.changeSinksLock.Unlock()
+
+	if s.changeSinksEnabled || s.config.ChangeFeedMaxEntries <= 0 {
+		return
+	}
+
+	s.changeSinksCh = make(chan struct{})
+
+	go func(stopCh chan struct{}) {
+		running := make(map[string]*runningChangeSink)
+		defer func() {
+			for _, sink := range running {
+				close(sink.stopCh)
+			}
+		}()
+
+		for {
+			ws := memdb.NewWatchSet()
+			ws.Add(stopCh)
+
+			var timeoutCh <-chan time.Time
+			if err := s.syncChangeSinks(ws, running); err != nil {
+				s.logger.Printf("[ERR] consul.change_sink: Failed to update change sinks: %v", err)
+				timeoutCh = time.After(changeSinkRetryInterval)
+			}
+			ws.Watch(timeoutCh)
+
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+		}
+	}(s.changeSinksCh)
+
+	s.changeSinksEnabled = true
+}
+
+// stopChangeSinks stops the change sinks when we lose leadership.
+func (s *Server) stopChangeSinks() {
+	s.changeSinksLock.Lock()
+	defer s.changeSinksLock.Unlock()
+
+	if !s.changeSinksEnabled {
+		return
+	}
+
+	close(s.changeSinksCh)
+	s.changeSinksEnabled = false
+}
+
+// syncChangeSinks starts a publisher for every change-sink config entry that
+// doesn't have one running for its current version, and stops the ones whose
+// entries were deleted. The positions of deleted sinks are removed, so a sink
+// created later with the same name starts from scratch.
+func (s *Server) syncChangeSinks(ws memdb.WatchSet, running map[string]*runningChangeSink) error {
+	_, entries, err := s.fsm.State().ConfigEntries(ws, structs.ChangeSink)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool)
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name)
+		current[name] = true
+
+		sink, ok := running[name]
+		if ok && sink.modifyIndex == entry.ModifyIndex {
+			continue
+		}
+		if ok {
+			close(sink.stopCh)
+			delete(running, name)
+		}
+
+		// Entries are validated when they're written, so this only
+		// fails if the format changed between versions.
+		config, err := structs.ParseChangeSinkConfig(entry.Config)
+		if err != nil {
+			s.logger.Printf("[ERR] consul.change_sink: Invalid config for change sink %q: %v", entry.Name, err)
+			continue
+		}
+
+		sink = &runningChangeSink{
+			modifyIndex: entry.ModifyIndex,
+			stopCh:      make(chan struct{}),
+		}
+		running[name] = sink
+		go s.runChangeSink(name, changeSinkTypes[config.Type](config), config, sink.stopCh)
+	}
+
+	for name, sink := range running {
+		if !current[name] {
+			close(sink.stopCh)
+			delete(running, name)
+		}
+	}
+
+	_, positions, err := s.fsm.State().KVSList(nil, structs.ChangeSinkPositionKVPrefix)
+	if err != nil {
+		return err
+	}
+	for _, position := range positions {
+		if !current[strings.TrimPrefix(position.Key, structs.ChangeSinkPositionKVPrefix)] {
+			if err := s.applyChangeSinkKVS(api.KVDelete, position.Key, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runChangeSink publishes the catalog changes in the change feed to the given
+// sink, starting from its saved position, until the stop channel is closed.
+// Batches that fail are retried with a backoff until they succeed.
+func (s *Server) runChangeSink(name string, sink changeSink, config *structs.ChangeSinkConfig, stopCh chan struct{}) {
+	var index uint64
+	if _, entry, err := s.fsm.State().KVSGet(nil, structs.ChangeSinkPositionKey(name)); err != nil {
+		s.logger.Printf("[ERR] consul.change_sink: Failed to read the position of change sink %q: %v", name, err)
+		return
+	} else if entry != nil {
+		if index, err = strconv.ParseUint(string(entry.Value), 10, 64); err != nil {
+			s.logger.Printf("[WARN] consul.change_sink: Invalid position for change sink %q, starting over: %v", name, err)
+		}
+	}
+
+	s.logger.Printf("[INFO] consul.change_sink: Started change sink %q from index %d", name, index)
+
+	var failedAttempts uint
+	for {
+		next, exit, err := s.publishChanges(name, sink, config, index, stopCh)
+		if exit {
+			return
+		}
+
+		if err != nil {
+			metrics.IncrCounterWithLabels([]string{"change_sink", "failure"}, 1,
+				[]metrics.Label{{Name: "sink", Value: name}})
+			s.logger.Printf("[WARN] consul.change_sink: Failed to publish to change sink %q (will retry if still leader): %v", name, err)
+			if (1 << failedAttempts) < changeSinkMaxRetryBackoff {
+				failedAttempts++
+			}
+
+			select {
+			case <-stopCh:
+				return
+			case <-time.After((1 << failedAttempts) * time.Second):
+			}
+		} else {
+			failedAttempts = 0
+		}
+		index = next
+	}
+}
+
+// publishChanges waits for change feed entries after the given index, and
+// publishes the catalog changes in them as a batch. The sink's position is
+// saved once the batch is accepted. It returns the index published up to, and
+// whether the sink was stopped while it was waiting.
+func (s *Server) publishChanges(name string, sink changeSink, config *structs.ChangeSinkConfig, index uint64, stopCh chan struct{}) (uint64, bool, error) {
+	for {
+		ws := memdb.NewWatchSet()
+		ws.Add(stopCh)
+
+		_, trimmed, entries, _, err := s.fsm.State().ChangeFeedList(ws, index, config.BatchSize)
+		if err != nil {
+			return index, false, err
+		}
+		if index > 0 && index < trimmed {
+			metrics.IncrCounterWithLabels([]string{"change_sink", "expired"}, 1,
+				[]metrics.Label{{Name: "sink", Value: name}})
+			s.logger.Printf("[WARN] consul.change_sink: Change sink %q fell behind the change feed, changes after index %d were missed", name, index)
+			index = trimmed
+			continue
+		}
+
+		if len(entries) == 0 {
+			ws.Watch(nil)
+			select {
+			case <-stopCh:
+				return index, true, nil
+			default:
+			}
+			continue
+		}
+
+		batch := &structs.ChangeSinkBatch{
+			Sink:       name,
+			Datacenter: s.config.Datacenter,
+			Entries:    catalogChanges(entries),
+		}
+		next := entries[len(entries)-1].Index
+
+		// Entries with only KV changes are skipped without saving the
+		// position, which also skips the writes of the position itself.
+		if len(batch.Entries) == 0 {
+			index = next
+			continue
+		}
+
+		start := time.Now()
+		if err := sink.Publish(batch); err != nil {
+			return index, false, err
+		}
+		metrics.MeasureSinceWithLabels([]string{"change_sink", "publish"}, start,
+			[]metrics.Label{{Name: "sink", Value: name}})
+
+		position := []byte(strconv.FormatUint(next, 10))
+		if err := s.applyChangeSinkKVS(api.KVSet, structs.ChangeSinkPositionKey(name), position); err != nil {
+			return index, false, err
+		}
+		return next, false, nil
+	}
+}
+
+// applyChangeSinkKVS applies a KV operation on the position of a change sink.
+func (s *Server) applyChangeSinkKVS(op api.KVOp, key string, value []byte) error {
+	req := structs.KVSRequest{
+		Datacenter: s.config.Datacenter,
+		Op:         op,
+		DirEnt: structs.DirEntry{
+			Key:   key,
+			Value: value,
+		},
+	}
+	resp, err := s.raftApplyInternal(structs.KVSRequestType, &req)
+	if err != nil {
+		s.logger.Printf("[ERR] consul.change_sink: Apply failed: %v", err)
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
+
+// catalogChanges returns the given change feed entries with the KV changes
+// left out. Entries with no catalog changes are dropped.
+func catalogChanges(entries structs.ChangeFeedEntries) structs.ChangeFeedEntries {
+	var out structs.ChangeFeedEntries
+	for _, entry := range entries {
+		var changes []*structs.Change
+		for _, change := range entry.Changes {
+			if change.Type != structs.ChangeKV {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) > 0 {
+			out = append(out, &structs.ChangeFeedEntry{
+				Index:   entry.Index,
+				Seq:     entry.Seq,
+				Changes: changes,
+			})
+		}
+	}
+	return out
+}
+
+// webhookChangeSink POSTs batches as JSON to a URL. Any 2xx response means
+// the batch was accepted.
+type webhookChangeSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookChangeSink(config *structs.ChangeSinkConfig) changeSink {
+	client := cleanhttp.DefaultClient()
+	client.Timeout = config.Timeout
+	return &webhookChangeSink{
+		url:     config.URL,
+		headers: config.Headers,
+		client:  client,
+	}
+}
+
+// Publish implements changeSink.
+func (w *webhookChangeSink) Publish(batch *structs.ChangeSinkBatch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Unexpected response code: %d (%s)", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,162 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeSink_Webhook(t *testing.T) {
+	t.Parallel()
+
+	// The first request fails, so the batch has to be sent again.
+	var lock sync.Mutex
+	var batches []*structs.ChangeSinkBatch
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var batch structs.ChangeSinkBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, &batch)
+	}))
+	defer ts.Close()
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ChangeFeedMaxEntries = 100
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testrpc.WaitForTestAgent(t, s1.RPC, "dc1")
+
+	sinkReq := structs.ConfigEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ConfigEntryOpUpsert,
+		Entry: &structs.ConfigEntry{
+			Kind: structs.ChangeSink,
+			Name: "cmdb",
+			Config: map[string]interface{}{
+				"URL":     ts.URL,
+				"Headers": map[string]interface{}{"X-Token": "secret"},
+			},
+		},
+	}
+	var out struct{}
+	require.NoError(t, s1.RPC("ConfigEntry.Apply", &sinkReq, &out))
+
+	kvReq := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVSet,
+		DirEnt:     structs.DirEntry{Key: "foo", Value: []byte("bar")},
+	}
+	var ok bool
+	require.NoError(t, s1.RPC("KVS.Apply", &kvReq, &ok))
+
+	regReq := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service:    &structs.NodeService{ID: "db", Service: "db"},
+	}
+	require.NoError(t, s1.RPC("Catalog.Register", &regReq, &out))
+
+	// Only catalog changes are published, and the position is saved once
+	// they've been accepted.
+	var published uint64
+	retry.Run(t, func(r *retry.R) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		for _, batch := range batches {
+			if batch.Sink != "cmdb" || batch.Datacenter != "dc1" {
+				r.Fatalf("bad batch: %#v", batch)
+			}
+			for _, entry := range batch.Entries {
+				for _, change := range entry.Changes {
+					if change.Type == structs.ChangeKV {
+						r.Fatalf("KV change was published: %#v", change)
+					}
+					if change.Type == structs.ChangeService && change.ServiceID == "db" {
+						published = entry.Index
+					}
+				}
+			}
+		}
+		if published == 0 {
+			r.Fatal("service change not published")
+		}
+
+		_, entry, err := s1.fsm.State().KVSGet(nil, structs.ChangeSinkPositionKey("cmdb"))
+		if err != nil {
+			r.Fatal(err)
+		}
+		if entry == nil {
+			r.Fatal("position not saved")
+		}
+		if position, _ := strconv.ParseUint(string(entry.Value), 10, 64); position < published {
+			r.Fatalf("bad position: %d", position)
+		}
+	})
+
+	// Deleting the sink removes its position.
+	sinkReq.Op = structs.ConfigEntryOpDelete
+	require.NoError(t, s1.RPC("ConfigEntry.Apply", &sinkReq, &out))
+	retry.Run(t, func(r *retry.R) {
+		_, entry, err := s1.fsm.State().KVSGet(nil, structs.ChangeSinkPositionKey("cmdb"))
+		if err != nil {
+			r.Fatal(err)
+		}
+		if entry != nil {
+			r.Fatal("position not removed")
+		}
+	})
+}
+
+func TestChangeSink_InvalidConfig(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	for _, config := range []map[string]interface{}{
+		{},
+		{"URL": "not a url"},
+		{"URL": "http://127.0.0.1", "Type": "kafka"},
+		{"URL": "http://127.0.0.1", "BatchSize": 0},
+		{"URL": "http://127.0.0.1", "Unknown": true},
+	} {
+		arg := structs.ConfigEntryRequest{
+			Datacenter: "dc1",
+			Op:         structs.ConfigEntryOpUpsert,
+			Entry:      &structs.ConfigEntry{Kind: structs.ChangeSink, Name: "cmdb", Config: config},
+		}
+		var out struct{}
+		require.Error(t, s1.RPC("ConfigEntry.Apply", &arg, &out), "%v", config)
+	}
+}
@@ -123,13 +123,24 @@ func NewClientLogger(config *Config, logger *log.Logger) (*Client, error) {
 		logger = log.New(config.LogOutput, "", log.LstdFlags)
 	}
 
+	maxStreams := clientMaxStreams
+	if config.RPCMaxStreams > 0 && config.RPCMaxStreams < maxStreams {
+		maxStreams = config.RPCMaxStreams
+	}
 	connPool := &pool.ConnPool{
-		SrcAddr:    config.RPCSrcAddr,
-		LogOutput:  config.LogOutput,
-		MaxTime:    clientRPCConnMaxIdle,
-		MaxStreams: clientMaxStreams,
-		TLSWrapper: tlsWrap,
-		ForceTLS:   config.VerifyOutgoing,
+		SrcAddr:                config.RPCSrcAddr,
+		LogOutput:              config.LogOutput,
+		MaxTime:                clientRPCConnMaxIdle,
+		MaxStreams:             maxStreams,
+		MaxOpenStreams:         config.RPCMaxStreams,
+		KeepAliveInterval:      config.RPCKeepAliveInterval,
+		ConnectionWriteTimeout: config.RPCConnectionWriteTimeout,
+		StreamTimeout:          config.RPCStreamTimeout,
+		MaxQueryTime:           maxQueryTime,
+		DefaultQueryTime:       defaultQueryTime,
+		TLSWrapper:             tlsWrap,
+		ForceTLS:               config.VerifyOutgoing,
+		FeatureCheck:           checkConnFeatures,
 	}
 
 	// Create client
@@ -279,8 +290,12 @@ func (c *Client) RPC(method string, args interface{}, reply interface{}) error {
 	// is done on the server side inside forward(). This is because the
 	// servers may already be applying the RPCHoldTimeout up there, so by
 	// starting the timer here we won't potentially double up the delay.
-	// TODO (slackpad) Plumb a deadline here with a context.
+	// The request's own deadline starts here as well.
 	firstCheck := time.Now()
+	info, hasInfo := args.(structs.RPCInfo)
+	if hasInfo {
+		info.StartDeadline()
+	}
 
 TRY:
 	server := c.routers.FindServer()
@@ -295,6 +310,14 @@ TRY:
 		return structs.ErrRPCRateExceeded
 	}
 
+	// Don't send requests the server won't understand.
+	if err := checkServerFeatures(server, method, args); err != nil {
+		return err
+	}
+	if err := setForwardTimeout(args); err != nil {
+		return err
+	}
+
 	// Make the request.
 	rpcErr := c.connPool.RPC(c.config.Datacenter, server.Addr, server.Version, method, server.UseTLS, args, reply)
 	if rpcErr == nil {
@@ -302,7 +325,7 @@ TRY:
 	}
 
 	// Move off to another server, and see if we can retry.
-	c.logger.Printf("[ERR] consul: %q RPC failed to server %s: %v", method, server.Addr, rpcErr)
+	c.logger.Printf("[ERR] consul: %q RPC failed to server %s: %v%s", method, server.Addr, rpcErr, traceField(args))
 	metrics.IncrCounterWithLabels([]string{"client", "rpc", "failed"}, 1, []metrics.Label{{Name: "server", Value: server.Name}})
 	c.routers.NotifyFailedServer(server)
 	if retry := canRetry(args, rpcErr); !retry {
@@ -310,7 +333,7 @@ TRY:
 	}
 
 	// We can wait a bit and retry!
-	if time.Since(firstCheck) < c.config.RPCHoldTimeout {
+	if time.Since(firstCheck) < c.config.RPCHoldTimeout && !(hasInfo && pastDeadline(info)) {
 		jitter := lib.RandomStagger(c.config.RPCHoldTimeout / jitterFraction)
 		select {
 		case <-time.After(jitter):
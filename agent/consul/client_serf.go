@@ -74,7 +74,8 @@ func (c *Client) lanEventHandler() {
 				c.nodeFail(e.(serf.MemberEvent))
 			case serf.EventUser:
 				c.localEvent(e.(serf.UserEvent))
-			case serf.EventMemberUpdate: // Ignore
+			case serf.EventMemberUpdate:
+				c.nodeUpdate(e.(serf.MemberEvent))
 			case serf.EventMemberReap: // Ignore
 			case serf.EventQuery: // Ignore
 			default:
@@ -108,6 +109,18 @@ func (c *Client) nodeJoin(me serf.MemberEvent) {
 	}
 }
 
+// nodeUpdate is used to handle update events on the serf cluster, so changes
+// to a server's tags, such as the features it supports, are picked up.
+func (c *Client) nodeUpdate(me serf.MemberEvent) {
+	for _, m := range me.Members {
+		ok, parts := metadata.IsConsulServer(m)
+		if !ok || parts.Datacenter != c.config.Datacenter {
+			continue
+		}
+		c.routers.AddServer(parts)
+	}
+}
+
 // nodeFail is used to handle fail events on the serf cluster
 func (c *Client) nodeFail(me serf.MemberEvent) {
 	for _, m := range me.Members {
@@ -183,6 +183,53 @@ func TestClient_RPC(t *testing.T) {
 	})
 }
 
+func TestClient_RPC_FeatureNotSupported(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	dir2, c1 := testClient(t)
+	defer os.RemoveAll(dir2)
+	defer c1.Shutdown()
+
+	joinLAN(t, c1, s1)
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	args := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var out structs.IndexedNamespaces
+	retry.Run(t, func(r *retry.R) {
+		if err := c1.RPC("Namespace.List", &args, &out); err != nil {
+			r.Fatal(err)
+		}
+	})
+
+	// Make the server look like an older version that doesn't advertise
+	// any features.
+	tags := make(map[string]string)
+	for k, v := range s1.serfLAN.LocalMember().Tags {
+		if k != "ft" {
+			tags[k] = v
+		}
+	}
+	if err := s1.serfLAN.SetTags(tags); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The client should refuse to send it requests it doesn't support, but
+	// still send the others.
+	retry.Run(t, func(r *retry.R) {
+		err := c1.RPC("Namespace.List", &args, &out)
+		if !structs.IsErrFeatureNotSupported(err) {
+			r.Fatalf("err: %v", err)
+		}
+	})
+	var ping struct{}
+	if err := c1.RPC("Status.Ping", struct{}{}, &ping); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 type leaderFailer struct {
 	totalCalls int
 	onceCalls  int
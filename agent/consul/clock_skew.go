@@ -0,0 +1,96 @@
+package consul
+
+import (
+	"sort"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// observeClockSkew records the clock skew of a node from the time it sent a
+// coordinate update, and warns when it goes over the threshold. Clocks that
+// are too far apart break session TTLs, which the servers time, and the
+// validity periods of Connect certificates, which other nodes check.
+func (s *Server) observeClockSkew(node string, sentAt time.Time) {
+	if sentAt.IsZero() {
+		return
+	}
+	now := time.Now()
+	skew := sentAt.Sub(now)
+	threshold := s.config.ClockSkewThreshold
+	exceeded := threshold > 0 && (skew > threshold || skew < -threshold)
+
+	// This is a sample rather than a gauge per node, which would make a
+	// metric series for every node in the datacenter.
+	metrics.AddSample([]string{"leader", "clock_skew"}, float32(absDuration(skew).Seconds()*1000))
+
+	s.clockSkewLock.Lock()
+	defer s.clockSkewLock.Unlock()
+
+	if s.clockSkew == nil {
+		s.clockSkew = make(map[string]*structs.NodeClockSkew)
+	}
+	prev, ok := s.clockSkew[node]
+	switch {
+	case exceeded && (!ok || !prev.Exceeded):
+		metrics.IncrCounter([]string{"leader", "clock_skew", "exceeded"}, 1)
+		s.logger.Printf("[WARN] consul: Clock of node %q is %s off the leader's, more than the %s threshold. "+
+			"This can expire sessions early and fail certificate validation, check the node's time synchronization",
+			node, skew, threshold)
+	case !exceeded && ok && prev.Exceeded:
+		s.logger.Printf("[INFO] consul: Clock of node %q is back within %s of the leader's", node, threshold)
+	}
+	s.clockSkew[node] = &structs.NodeClockSkew{
+		Node:     node,
+		Skew:     skew,
+		Measured: now.Round(time.Second).UTC(),
+		Exceeded: exceeded,
+	}
+}
+
+// forgetClockSkew drops the clock skew of a node that left.
+func (s *Server) forgetClockSkew(node string) {
+	s.clockSkewLock.Lock()
+	defer s.clockSkewLock.Unlock()
+	delete(s.clockSkew, node)
+}
+
+// resetClockSkew drops the clock skews, which are measured against the
+// leader's clock, when a new leader is elected.
+func (s *Server) resetClockSkew() {
+	s.clockSkewLock.Lock()
+	defer s.clockSkewLock.Unlock()
+	s.clockSkew = nil
+}
+
+// getClockSkewReport returns the clock skew of the nodes, largest first.
+func (s *Server) getClockSkewReport() structs.ClockSkewReport {
+	s.clockSkewLock.Lock()
+	defer s.clockSkewLock.Unlock()
+
+	report := structs.ClockSkewReport{
+		Leader:    s.config.NodeName,
+		Threshold: s.config.ClockSkewThreshold,
+		Nodes:     make([]structs.NodeClockSkew, 0, len(s.clockSkew)),
+	}
+	for _, skew := range s.clockSkew {
+		report.Nodes = append(report.Nodes, *skew)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		a, b := absDuration(report.Nodes[i].Skew), absDuration(report.Nodes[j].Skew)
+		if a != b {
+			return a > b
+		}
+		return report.Nodes[i].Node < report.Nodes[j].Node
+	})
+	return report
+}
+
+// absDuration returns the absolute value of a duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,72 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkew(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ClockSkewThreshold = time.Minute
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+	testrpc.WaitForTestAgent(t, s1.RPC, "dc1")
+
+	update := func(node string, sentAt time.Time) {
+		t.Helper()
+		args := structs.CoordinateUpdateRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Coord:      generateRandomCoordinate(),
+			SentAt:     sentAt,
+		}
+		var out struct{}
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Coordinate.Update", &args, &out))
+	}
+	report := func() structs.ClockSkewReport {
+		t.Helper()
+		args := structs.DCSpecificRequest{Datacenter: "dc1"}
+		var out structs.ClockSkewReport
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Operator.ClockSkew", &args, &out))
+		return out
+	}
+
+	// Updates from older agents don't have a send time, so they're left out.
+	update("node1", time.Now())
+	update("node2", time.Now().Add(-time.Hour))
+	update("node3", time.Time{})
+
+	out := report()
+	require.Equal(t, s1.config.NodeName, out.Leader)
+	require.Equal(t, time.Minute, out.Threshold)
+	require.Len(t, out.Nodes, 2)
+	require.Equal(t, "node2", out.Nodes[0].Node)
+	require.InDelta(t, -time.Hour, out.Nodes[0].Skew, float64(time.Minute))
+	require.True(t, out.Nodes[0].Exceeded)
+	require.Equal(t, "node1", out.Nodes[1].Node)
+	require.False(t, out.Nodes[1].Exceeded)
+
+	// Once the clock is fixed the node is back under the threshold.
+	update("node2", time.Now())
+	out = report()
+	require.Len(t, out.Nodes, 2)
+	for _, n := range out.Nodes {
+		require.False(t, n.Exceeded, "node %s", n.Node)
+	}
+
+	// Nodes that leave are forgotten.
+	s1.forgetClockSkew("node1")
+	out = report()
+	require.Len(t, out.Nodes, 1)
+	require.Equal(t, "node2", out.Nodes[0].Node)
+}
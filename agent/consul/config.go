@@ -75,6 +75,11 @@ type Config struct {
 	// of nodes.
 	BootstrapExpect int
 
+	// BootstrapDataDir is a directory of files with services, KV entries
+	// and ACL policies and tokens that the first leader of the cluster
+	// loads into the state store, once. Nothing is loaded if it's empty.
+	BootstrapDataDir string
+
 	// Datacenter is the datacenter this Consul server represents.
 	Datacenter string
 
@@ -88,6 +93,10 @@ type Config struct {
 	// DevMode is used to enable a development server mode.
 	DevMode bool
 
+	// FaultInjection are the rules for injecting latency and errors into
+	// RPCs and Raft applies. They're only used in dev mode.
+	FaultInjection []structs.FaultRule
+
 	// NodeID is a unique identifier for this node across space and time.
 	NodeID types.NodeID
 
@@ -147,6 +156,80 @@ type Config struct {
 	// leader election.
 	ReconcileInterval time.Duration
 
+	// CatalogCheckInterval controls how often the leader cross-checks the
+	// catalog against Serf and looks for orphaned services and checks. A
+	// zero value disables the check.
+	CatalogCheckInterval time.Duration
+
+	// StateMemoryInterval controls how often the leader estimates the
+	// memory used by the state store. The admission checks against
+	// StateMemoryBudget use the last estimate. A zero value, or not having
+	// a budget, disables the accounting.
+	StateMemoryInterval time.Duration
+
+	// DatacenterForwardingAllow and DatacenterForwardingDeny restrict which
+	// other datacenters this one forwards requests to and accepts forwarded
+	// requests from. Datacenters in the deny list are always refused, and if
+	// the allow list isn't empty only the datacenters in it are allowed.
+	DatacenterForwardingAllow []string
+	DatacenterForwardingDeny  []string
+
+	// WANFederationLocalGateways are the addresses of the gateways other
+	// datacenters reach this one through. Setting them makes servers send
+	// WAN gossip and RPC requests to other datacenters through their
+	// gateways, instead of straight to their servers.
+	// WANFederationPrimaryGateways are the gateways of the primary
+	// datacenter, used to join the WAN pool through.
+	WANFederationLocalGateways   []string
+	WANFederationPrimaryGateways []string
+
+	// KVReplicationDatacenter is the datacenter to replicate the keys under
+	// KVReplicationPrefix from. The leader mirrors the prefix from there
+	// into this datacenter, overwriting any local changes to it. An empty
+	// value disables KV replication.
+	KVReplicationDatacenter string
+	KVReplicationPrefix     string
+
+	// ChangeFeedMaxEntries enables the change feed of catalog and KV writes
+	// and sets how many writes it keeps. A value of 0 disables it. The
+	// leader writes its value to Raft, so it applies to every server.
+	ChangeFeedMaxEntries int
+
+	// ExternalChecksEnabled makes this server take part in running the HTTP
+	// and TCP health checks of external nodes, which are catalog nodes with
+	// no agent of their own.
+	ExternalChecksEnabled bool
+
+	// ExternalCheckSyncInterval controls how often the server reloads the
+	// external checks from the catalog and works out which of them it should
+	// be running.
+	ExternalCheckSyncInterval time.Duration
+
+	// ExternalCheckAllowedCIDRs are the networks external checks may connect
+	// to. If it's empty, they may connect to any address other than
+	// loopback, link-local, multicast and unspecified ones, so catalog
+	// writers can't point the servers at themselves or at cloud metadata
+	// services.
+	ExternalCheckAllowedCIDRs []*net.IPNet
+
+	// ExternalNodeProbeInterval and ExternalNodeProbeTimeout are the interval
+	// and timeout of the probes of external nodes that ask to be probed for
+	// liveness.
+	ExternalNodeProbeInterval time.Duration
+	ExternalNodeProbeTimeout  time.Duration
+
+	// ReconcileRate and ReconcileMaxBurst limit how fast the leader writes
+	// to the catalog while reconciling Serf members, so a large number of
+	// members changing at once doesn't flood Raft with writes.
+	ReconcileRate     rate.Limit
+	ReconcileMaxBurst int
+
+	// ReconcilePanicThreshold is the fraction of nodes with a failing Serf
+	// health check above which the leader stops deregistering reaped
+	// nodes. This protects the catalog during large outages, where the
+	// nodes are likely to come back. A zero value disables the threshold.
+	ReconcilePanicThreshold float64
+
 	// LogOutput is the location to write logs to. If this is not set,
 	// logs will go to stderr.
 	LogOutput io.Writer
@@ -287,6 +370,17 @@ type Config struct {
 	// by default in Consul 1.0 and later.
 	ACLEnableKeyListPolicy bool
 
+	// ACLTokenUsageFlushInterval controls how often the server sends the
+	// last-used times of the tokens it resolved to the leader.
+	ACLTokenUsageFlushInterval time.Duration
+
+	// ACLTokenUnusedPeriod is how long a token can go unused before the
+	// leader acts on it, as set by ACLTokenUnusedAction. "flag" warns about
+	// unused tokens and "delete" deletes them. A zero period disables the
+	// check.
+	ACLTokenUnusedPeriod time.Duration
+	ACLTokenUnusedAction string
+
 	// TombstoneTTL is used to control how long KV tombstones are retained.
 	// This provides a window of time where the X-Consul-Index is monotonic.
 	// Outside this window, the index may not be monotonic. This is a result
@@ -313,6 +407,11 @@ type Config struct {
 	// Minimum Session TTL
 	SessionTTLMin time.Duration
 
+	// SessionLockDelayMin and SessionLockDelayMax bound the lock-delay of
+	// new sessions.
+	SessionLockDelayMin time.Duration
+	SessionLockDelayMax time.Duration
+
 	// ServerUp callback can be used to trigger a notification that
 	// a Consul server is now up and known about.
 	ServerUp func()
@@ -343,6 +442,30 @@ type Config struct {
 	// place, and a small jitter is applied to avoid a thundering herd.
 	RPCHoldTimeout time.Duration
 
+	// RPCLeaderRetryWait is how long a request that failed because the
+	// leader it was forwarded to lost leadership is retried before the error
+	// is returned. Leadership usually settles quickly, so this is much
+	// shorter than RPCHoldTimeout.
+	RPCLeaderRetryWait time.Duration
+
+	// RPCKeepAliveInterval is how often the multiplexed RPC connections
+	// between agents and servers ping the other side, and
+	// RPCConnectionWriteTimeout is how long a write or ping can take before
+	// the connection is considered broken and closed, so stalled
+	// connections are replaced rather than hanging requests.
+	RPCKeepAliveInterval      time.Duration
+	RPCConnectionWriteTimeout time.Duration
+
+	// RPCStreamTimeout is how long an outgoing RPC can take before it is
+	// failed. Blocking queries get the time they may block for on top of
+	// this. Zero disables the timeout.
+	RPCStreamTimeout time.Duration
+
+	// RPCMaxStreams is the most streams that can be open at once on each
+	// outgoing RPC connection. RPCs wait for a stream when there are this
+	// many. Zero means there's no limit.
+	RPCMaxStreams int
+
 	// RPCRate and RPCMaxBurst control how frequently RPC calls are allowed
 	// to happen. In any large enough time interval, rate limiter limits the
 	// rate to RPCRate tokens per second, with a maximum burst size of
@@ -354,10 +477,111 @@ type Config struct {
 	RPCRate     rate.Limit
 	RPCMaxBurst int
 
+	// MaxBlockingQueries limits how many blocking queries a server will
+	// watch at once, MaxBlockingQueriesPerTable limits how many of those can
+	// watch each state store table, and MaxBlockingQueriesPerToken limits how
+	// many can be made with any single ACL token. Queries over the limits are
+	// rejected with a retryable error. Zero means no limit.
+	MaxBlockingQueries         int
+	MaxBlockingQueriesPerTable map[string]int
+	MaxBlockingQueriesPerToken int
+
+	// RaftApplyQueueDepth limits how many Raft applies a server has in
+	// flight at once, and RaftApplyQueueWait is how long an apply waits for
+	// room in the queue before it's rejected. A zero depth means no limit.
+	RaftApplyQueueDepth int
+	RaftApplyQueueWait  time.Duration
+
+	// MaxNodes, MaxServicesPerNode and MaxChecksPerNode limit how many
+	// objects can be registered in the catalog, to keep misbehaving clients
+	// from growing the state store without bound. Registrations over the
+	// limits are rejected, but existing objects can still be updated. Zero
+	// means no limit.
+	MaxNodes           int
+	MaxServicesPerNode int
+	MaxChecksPerNode   int
+
+	// MaxIDLength is the longest service and check IDs can be, in bytes.
+	// Zero means no limit.
+	MaxIDLength int
+
+	// StrictRegistrationValidation rejects registrations with node or
+	// service names that aren't valid DNS labels, or with bad service or
+	// check IDs. Otherwise they're accepted, and only logged.
+	StrictRegistrationValidation bool
+
+	// QuerySourceValidation is how the query source of requests from agents
+	// is checked against the agent making them: QuerySourceTrust,
+	// QuerySourceOverride or QuerySourceReject.
+	QuerySourceValidation string
+
+	// StateMemoryBudget is how many bytes the nodes, services, checks and
+	// KV entries in the state store may take up. Writes that would take
+	// the store over the budget are rejected, but deletes and catalog
+	// registrations that only update existing entries are always allowed.
+	// Zero means no limit, and the store's memory isn't accounted for.
+	StateMemoryBudget int64
+
+	// MaxQueryResults caps how many results catalog and health queries
+	// return. Zero means no limit.
+	MaxQueryResults int
+
+	// QueryCacheSize is how many results of catalog and health list queries
+	// are cached. Zero disables the cache.
+	QueryCacheSize int
+
+	// CheckLivenessInterval is how often a catalog registration that doesn't
+	// change anything is written through Raft anyway, as a liveness touch.
+	// Other unchanged registrations are skipped. Zero means they're always
+	// skipped.
+	CheckLivenessInterval time.Duration
+
+	// RPCServerReadRate and RPCServerWriteRate limit how many read and write
+	// RPC requests per second a server will handle, RPCServerTokenRate
+	// limits the requests per second made with any single ACL token, and
+	// RPCServerIPRate the requests per second from any single source IP
+	// other than a server. Each limit allows bursts of up to
+	// RPCServerMaxBurst requests. Requests over the limits are rejected with
+	// a retryable error. A rate of Inf disables the respective limit.
+	RPCServerReadRate  rate.Limit
+	RPCServerWriteRate rate.Limit
+	RPCServerTokenRate rate.Limit
+	RPCServerIPRate    rate.Limit
+	RPCServerMaxBurst  int
+
 	// LeaveDrainTime is used to wait after a server has left the LAN Serf
 	// pool for RPCs to drain and new requests to be sent to other servers.
 	LeaveDrainTime time.Duration
 
+	// CrossDCBreakerThreshold is the number of requests in a row that must
+	// fail to reach a remote datacenter for requests to it to fail fast,
+	// until a health probe sent every CrossDCBreakerCooldown gets through.
+	// Zero disables the circuit breakers.
+	CrossDCBreakerThreshold int
+	CrossDCBreakerCooldown  time.Duration
+
+	// LeaderFlapThreshold is the number of leader elections within
+	// LeaderFlapWindow at which the leader is considered to be flapping.
+	// Zero disables the detection.
+	LeaderFlapThreshold int
+	LeaderFlapWindow    time.Duration
+
+	// LocalityHostRTT, LocalityZoneRTT and LocalityRegionRTT are the
+	// estimated round trip times under which results sorted by distance
+	// are labelled as being on the same host, zone or region as the source.
+	LocalityHostRTT   time.Duration
+	LocalityZoneRTT   time.Duration
+	LocalityRegionRTT time.Duration
+
+	// ClockSkewThreshold is how far a node's clock can be from the leader's
+	// before the leader warns about it. Zero disables the warnings.
+	ClockSkewThreshold time.Duration
+
+	// EnableResponseSigning makes the leader create a response signing key,
+	// and lets catalog and health queries ask for their replies to be signed
+	// with it.
+	EnableResponseSigning bool
+
 	// AutopilotConfig is used to apply the initial autopilot config when
 	// bootstrapping.
 	AutopilotConfig *autopilot.Config
@@ -419,26 +643,41 @@ func DefaultConfig() *Config {
 	}
 
 	conf := &Config{
-		Build:                    version.Version,
-		Datacenter:               DefaultDC,
-		NodeName:                 hostname,
-		RPCAddr:                  DefaultRPCAddr,
-		RaftConfig:               raft.DefaultConfig(),
-		SerfLANConfig:            lib.SerfDefaultConfig(),
-		SerfWANConfig:            lib.SerfDefaultConfig(),
-		SerfFloodInterval:        60 * time.Second,
-		ReconcileInterval:        60 * time.Second,
-		ProtocolVersion:          ProtocolVersion2Compatible,
-		ACLPolicyTTL:             30 * time.Second,
-		ACLTokenTTL:              30 * time.Second,
-		ACLDefaultPolicy:         "allow",
-		ACLDownPolicy:            "extend-cache",
-		ACLReplicationRate:       1,
-		ACLReplicationBurst:      5,
-		ACLReplicationApplyLimit: 100, // ops / sec
-		TombstoneTTL:             15 * time.Minute,
-		TombstoneTTLGranularity:  30 * time.Second,
-		SessionTTLMin:            10 * time.Second,
+		Build:                     version.Version,
+		Datacenter:                DefaultDC,
+		NodeName:                  hostname,
+		RPCAddr:                   DefaultRPCAddr,
+		RaftConfig:                raft.DefaultConfig(),
+		SerfLANConfig:             lib.SerfDefaultConfig(),
+		SerfWANConfig:             lib.SerfDefaultConfig(),
+		SerfFloodInterval:         60 * time.Second,
+		ReconcileInterval:         60 * time.Second,
+		CatalogCheckInterval:      5 * time.Minute,
+		CheckLivenessInterval:     10 * time.Minute,
+		StateMemoryInterval:       10 * time.Second,
+		ExternalCheckSyncInterval: 10 * time.Second,
+		ExternalNodeProbeInterval: 10 * time.Second,
+		ExternalNodeProbeTimeout:  5 * time.Second,
+		QuerySourceValidation:     QuerySourceTrust,
+		ReconcileRate:             rate.Inf,
+		ReconcileMaxBurst:         100,
+		ProtocolVersion:           ProtocolVersion2Compatible,
+		ACLPolicyTTL:              30 * time.Second,
+		ACLTokenTTL:               30 * time.Second,
+		ACLDefaultPolicy:          "allow",
+		ACLDownPolicy:             "extend-cache",
+		ACLReplicationRate:        1,
+		ACLReplicationBurst:       5,
+		ACLReplicationApplyLimit:  100, // ops / sec
+		TombstoneTTL:              15 * time.Minute,
+		TombstoneTTLGranularity:   30 * time.Second,
+		SessionTTLMin:             10 * time.Second,
+		SessionLockDelayMax:       structs.MaxLockDelay,
+
+		// Token usage is only tracked to the hour, so flushing it more
+		// often than this would only add load.
+		ACLTokenUsageFlushInterval: time.Minute,
+		ACLTokenUnusedAction:       "flag",
 
 		// These are tuned to provide a total throughput of 128 updates
 		// per second. If you update these, you should update the client-
@@ -447,9 +686,28 @@ func DefaultConfig() *Config {
 		CoordinateUpdateBatchSize:  128,
 		CoordinateUpdateMaxBatches: 5,
 
+		RPCLeaderRetryWait:        300 * time.Millisecond,
+		RPCKeepAliveInterval:      30 * time.Second,
+		RPCConnectionWriteTimeout: 10 * time.Second,
+		RPCStreamTimeout:          60 * time.Second,
+		CrossDCBreakerThreshold:   5,
+		CrossDCBreakerCooldown:    10 * time.Second,
+		LeaderFlapThreshold:       5,
+		LeaderFlapWindow:          10 * time.Minute,
+		LocalityHostRTT:           500 * time.Microsecond,
+		LocalityZoneRTT:           2 * time.Millisecond,
+		LocalityRegionRTT:         10 * time.Millisecond,
+		MaxIDLength:               256,
+
 		RPCRate:     rate.Inf,
 		RPCMaxBurst: 1000,
 
+		RPCServerReadRate:  rate.Inf,
+		RPCServerWriteRate: rate.Inf,
+		RPCServerTokenRate: rate.Inf,
+		RPCServerIPRate:    rate.Inf,
+		RPCServerMaxBurst:  1000,
+
 		TLSMinVersion: "tls10",
 
 		// TODO (slackpad) - Until #3744 is done, we need to keep these
@@ -0,0 +1,141 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+var (
+	// ErrConfigEntryNotFound is returned if there's no config entry with the
+	// given kind and name.
+	ErrConfigEntryNotFound = errors.New("Config entry not found")
+)
+
+// ConfigEntry manages the generic config entries stored by the servers.
+type ConfigEntry struct {
+	// srv is a pointer back to the server.
+	srv *Server
+}
+
+// Apply creates, updates or deletes a config entry. The ACLs needed depend on
+// the kind of the entry.
+func (c *ConfigEntry) Apply(args *structs.ConfigEntryRequest, reply *struct{}) error {
+	if done, err := c.srv.forward("ConfigEntry.Apply", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"config_entry", "apply"}, time.Now())
+
+	if args.Entry == nil {
+		return fmt.Errorf("Missing config entry")
+	}
+	switch args.Op {
+	case structs.ConfigEntryOpUpsert:
+		args.Entry.NormalizeConfig()
+		if err := args.Entry.Validate(); err != nil {
+			return err
+		}
+	case structs.ConfigEntryOpDelete:
+		if args.Entry.Kind == "" || args.Entry.Name == "" {
+			return fmt.Errorf("Must provide a config entry kind and name")
+		}
+	default:
+		return fmt.Errorf("Invalid config entry operation %q", args.Op)
+	}
+
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !args.Entry.CanWrite(rule) {
+		return acl.ErrPermissionDenied
+	}
+
+	resp, err := c.srv.raftApply(structs.ConfigEntryRequestType, args)
+	if err != nil {
+		c.srv.logger.Printf("[ERR] consul.config_entry: Apply failed: %v", err)
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
+
+// Get returns a single config entry by kind and name.
+func (c *ConfigEntry) Get(args *structs.ConfigEntryQuery, reply *structs.IndexedConfigEntries) error {
+	if done, err := c.srv.forward("ConfigEntry.Get", args, args, reply); done {
+		return err
+	}
+
+	if args.Kind == "" || args.Name == "" {
+		return fmt.Errorf("Must provide a config entry kind and name")
+	}
+
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	lookup := &structs.ConfigEntry{Kind: args.Kind, Name: args.Name}
+	if rule != nil && !lookup.CanRead(rule) {
+		return acl.ErrPermissionDenied
+	}
+
+	return c.srv.blockingQuery(
+		"config-entries",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, entry, err := state.ConfigEntry(ws, args.Kind, args.Name)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				return ErrConfigEntryNotFound
+			}
+
+			reply.Index = index
+			reply.Entries = structs.ConfigEntries{entry}
+			return nil
+		})
+}
+
+// List returns the config entries of the given kind, or of all kinds if none
+// is given, leaving out the ones the token can't read.
+func (c *ConfigEntry) List(args *structs.ConfigEntryQuery, reply *structs.IndexedConfigEntries) error {
+	if done, err := c.srv.forward("ConfigEntry.List", args, args, reply); done {
+		return err
+	}
+
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+
+	return c.srv.blockingQuery(
+		"config-entries",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, entries, err := state.ConfigEntries(ws, args.Kind)
+			if err != nil {
+				return err
+			}
+
+			reply.Index = index
+			reply.Entries = structs.ConfigEntries{}
+			for _, entry := range entries {
+				if rule != nil && !entry.CanRead(rule) {
+					continue
+				}
+				reply.Entries = append(reply.Entries, entry)
+			}
+			return nil
+		})
+}
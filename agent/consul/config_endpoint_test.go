@@ -0,0 +1,177 @@
+package consul
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEntry_Apply(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a couple of entries.
+	arg := structs.ConfigEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ConfigEntryOpUpsert,
+		Entry: &structs.ConfigEntry{
+			Kind:   structs.ServiceDefaults,
+			Name:   "web",
+			Config: map[string]interface{}{"protocol": "http"},
+		},
+	}
+	var reply struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply))
+	arg.Entry = &structs.ConfigEntry{Kind: "router", Name: "web"}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply))
+
+	// Read one back.
+	get := structs.ConfigEntryQuery{Datacenter: "dc1", Kind: structs.ServiceDefaults, Name: "web"}
+	var out structs.IndexedConfigEntries
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Get", &get, &out))
+	require.Len(out.Entries, 1)
+	require.Equal(structs.ServiceDefaults, out.Entries[0].Kind)
+	require.Contains(out.Entries[0].Config, "protocol")
+
+	// List them all, and by kind.
+	list := structs.ConfigEntryQuery{Datacenter: "dc1"}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.List", &list, &out))
+	require.Len(out.Entries, 2)
+	list.Kind = "router"
+	var routers structs.IndexedConfigEntries
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.List", &list, &routers))
+	require.Len(routers.Entries, 1)
+	require.Equal("router", routers.Entries[0].Kind)
+
+	// Kinds are validated.
+	arg.Entry = &structs.ConfigEntry{Kind: "Bad Kind", Name: "web"}
+	err := msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply)
+	require.Error(err)
+	require.Contains(err.Error(), "Invalid config entry kind")
+
+	// Delete one.
+	arg.Op = structs.ConfigEntryOpDelete
+	arg.Entry = &structs.ConfigEntry{Kind: structs.ServiceDefaults, Name: "web"}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply))
+	err = msgpackrpc.CallWithCodec(codec, "ConfigEntry.Get", &get, &out)
+	require.Error(err)
+	require.Equal(ErrConfigEntryNotFound.Error(), err.Error())
+}
+
+func TestConfigEntry_ACLDeny(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a token that can write the web service.
+	aclArg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name: "User token",
+			Type: structs.ACLTokenTypeClient,
+			Rules: `
+service "web" {
+	policy = "write"
+}
+`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var token string
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ACL.Apply", &aclArg, &token))
+
+	// Service defaults follow the service rules.
+	arg := structs.ConfigEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ConfigEntryOpUpsert,
+		Entry:      &structs.ConfigEntry{Kind: structs.ServiceDefaults, Name: "web"},
+	}
+	var reply struct{}
+	err := msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+	arg.Token = token
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply))
+
+	arg.Entry = &structs.ConfigEntry{Kind: structs.ServiceDefaults, Name: "db"}
+	err = msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	// Other kinds need operator privileges.
+	arg.Entry = &structs.ConfigEntry{Kind: "router", Name: "web"}
+	err = msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+	arg.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply))
+
+	// Entries the token can't read are left out of the list.
+	list := structs.ConfigEntryQuery{Datacenter: "dc1"}
+	var out structs.IndexedConfigEntries
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.List", &list, &out))
+	require.Len(out.Entries, 0)
+	list.Token = token
+	var userOut structs.IndexedConfigEntries
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.List", &list, &userOut))
+	require.Len(userOut.Entries, 1)
+	require.Equal(structs.ServiceDefaults, userOut.Entries[0].Kind)
+	list.Token = "root"
+	var rootOut structs.IndexedConfigEntries
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.List", &list, &rootOut))
+	require.Len(rootOut.Entries, 2)
+
+	get := structs.ConfigEntryQuery{Datacenter: "dc1", Kind: "router", Name: "web", QueryOptions: structs.QueryOptions{Token: token}}
+	err = msgpackrpc.CallWithCodec(codec, "ConfigEntry.Get", &get, &out)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	// Change sinks need operator write privileges to read, since their
+	// headers usually hold credentials.
+	aclArg.ACL.Rules = `operator = "read"`
+	var readToken string
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ACL.Apply", &aclArg, &readToken))
+	arg.Entry = &structs.ConfigEntry{
+		Kind: structs.ChangeSink,
+		Name: "cmdb",
+		Config: map[string]interface{}{
+			"URL":     "https://cmdb.example.com/changes",
+			"Headers": map[string]interface{}{"X-Token": "secret"},
+		},
+	}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Apply", &arg, &reply))
+
+	get = structs.ConfigEntryQuery{Datacenter: "dc1", Kind: structs.ChangeSink, Name: "cmdb", QueryOptions: structs.QueryOptions{Token: readToken}}
+	err = msgpackrpc.CallWithCodec(codec, "ConfigEntry.Get", &get, &out)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+	list.Token = readToken
+	var readOut structs.IndexedConfigEntries
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.List", &list, &readOut))
+	require.Len(readOut.Entries, 1)
+	require.Equal("router", readOut.Entries[0].Kind)
+	get.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ConfigEntry.Get", &get, &out))
+	require.Len(out.Entries, 1)
+}
@@ -265,6 +265,7 @@ func (s *ConnectCA) Roots(
 	}
 
 	return s.srv.blockingQuery(
+		"connect-ca-roots",
 		&args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, roots, err := state.CARoots(ws)
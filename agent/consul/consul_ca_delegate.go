@@ -16,7 +16,7 @@ func (c *consulCADelegate) State() *state.Store {
 }
 
 func (c *consulCADelegate) ApplyCARequest(req *structs.CARequest) error {
-	resp, err := c.srv.raftApply(structs.ConnectCARequestType, req)
+	resp, err := c.srv.raftApplyInternal(structs.ConnectCARequestType, req)
 	if err != nil {
 		return err
 	}
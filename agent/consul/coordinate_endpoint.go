@@ -10,6 +10,8 @@ import (
 	"github.com/hashicorp/consul/agent/consul/state"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/go-memdb"
+	"github.com/hashicorp/serf/coordinate"
+	"github.com/hashicorp/serf/serf"
 )
 
 // Coordinate manages queries and updates for network coordinates.
@@ -33,17 +35,48 @@ func NewCoordinate(srv *Server) *Coordinate {
 		updates: make(map[string]*structs.CoordinateUpdateRequest),
 	}
 
+	srv.coordinate = c
 	go c.batchUpdate()
+	go c.dropLeftUpdates()
 	return c
 }
 
+// dropLeftUpdates is a long-running routine that discards the pending updates
+// of nodes that leave or are reaped, so their coordinates aren't written after
+// they're deregistered.
+func (c *Coordinate) dropLeftUpdates() {
+	sub := c.srv.memberEvents.Subscribe("coordinate", memberEventChSize, false,
+		serf.EventMemberLeave, serf.EventMemberReap)
+	defer c.srv.memberEvents.Unsubscribe(sub)
+
+	for {
+		select {
+		case e := <-sub.Events():
+			c.updatesLock.Lock()
+			for _, m := range e.Members {
+				for key, update := range c.updates {
+					if update.Node == m.Name {
+						delete(c.updates, key)
+					}
+				}
+			}
+			c.updatesLock.Unlock()
+			for _, m := range e.Members {
+				c.srv.forgetClockSkew(m.Name)
+			}
+		case <-c.srv.shutdownCh:
+			return
+		}
+	}
+}
+
 // batchUpdate is a long-running routine that flushes pending coordinates to the
 // Raft log in batches.
 func (c *Coordinate) batchUpdate() {
 	for {
 		select {
 		case <-time.After(c.srv.config.CoordinateUpdatePeriod):
-			if err := c.batchApplyUpdates(); err != nil {
+			if _, err := c.batchApplyUpdates(); err != nil {
 				c.srv.logger.Printf("[WARN] consul.coordinate: Batch update failed: %v", err)
 			}
 		case <-c.srv.shutdownCh:
@@ -53,8 +86,8 @@ func (c *Coordinate) batchUpdate() {
 }
 
 // batchApplyUpdates applies all pending updates to the Raft log in a series of
-// batches.
-func (c *Coordinate) batchApplyUpdates() error {
+// batches, and returns how many were applied.
+func (c *Coordinate) batchApplyUpdates() (int, error) {
 	// Grab the pending updates and release the lock so we can still handle
 	// incoming messages.
 	c.updatesLock.Lock()
@@ -99,15 +132,15 @@ func (c *Coordinate) batchApplyUpdates() error {
 		t := structs.CoordinateBatchUpdateType | structs.IgnoreUnknownTypeFlag
 
 		slice := updates[start:end]
-		resp, err := c.srv.raftApply(t, slice)
+		resp, err := c.srv.raftApplyInternal(t, slice)
 		if err != nil {
-			return err
+			return start, err
 		}
 		if respErr, ok := resp.(error); ok {
-			return respErr
+			return start, respErr
 		}
 	}
-	return nil
+	return size, nil
 }
 
 // Update inserts or updates the LAN coordinate of a node.
@@ -116,22 +149,9 @@ func (c *Coordinate) Update(args *structs.CoordinateUpdateRequest, reply *struct
 		return err
 	}
 
-	// Older clients can send coordinates with invalid numeric values like
-	// NaN and Inf. We guard against these coming in, though newer clients
-	// should never send these.
-	if !args.Coord.IsValid() {
-		return fmt.Errorf("invalid coordinate")
-	}
-
-	// Since this is a coordinate coming from some place else we harden this
-	// and look for dimensionality problems proactively.
-	coord, err := c.srv.serfLAN.GetCoordinate()
-	if err != nil {
+	if err := c.checkCoordinate(args.Coord); err != nil {
 		return err
 	}
-	if !coord.IsCompatibleWith(args.Coord) {
-		return fmt.Errorf("incompatible coordinate")
-	}
 
 	// Fetch the ACL token, if any, and enforce the node policy if enabled.
 	rule, err := c.srv.ResolveToken(args.Token)
@@ -144,6 +164,8 @@ func (c *Coordinate) Update(args *structs.CoordinateUpdateRequest, reply *struct
 		}
 	}
 
+	c.srv.observeClockSkew(args.Node, args.SentAt)
+
 	// Add the coordinate to the map of pending updates.
 	key := fmt.Sprintf("%s:%s", args.Node, args.Segment)
 	c.updatesLock.Lock()
@@ -152,6 +174,103 @@ func (c *Coordinate) Update(args *structs.CoordinateUpdateRequest, reply *struct
 	return nil
 }
 
+// BatchUpdate inserts or updates the LAN coordinates of several nodes at once.
+// It's meant for agents that measure the latency to nodes that don't run
+// Serf, like a gateway in front of them, so those nodes must be registered in
+// the catalog and mustn't be Serf members, which report their own
+// coordinates. The token needs node:write on every node, and the whole batch
+// is rejected if any update is invalid.
+func (c *Coordinate) BatchUpdate(args *structs.CoordinateBatchUpdateRequest, reply *struct{}) error {
+	if done, err := c.srv.forward("Coordinate.BatchUpdate", args, args, reply); done {
+		return err
+	}
+
+	if max := c.srv.config.CoordinateUpdateBatchSize; len(args.Coordinates) > max {
+		return fmt.Errorf("too many coordinates, at most %d can be updated at once", max)
+	}
+
+	rule, err := c.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]bool)
+	for _, m := range c.srv.serfLAN.Members() {
+		if m.Status == serf.StatusAlive {
+			members[m.Name] = true
+		}
+	}
+
+	state := c.srv.fsm.State()
+	seen := make(map[string]bool)
+	for _, update := range args.Coordinates {
+		if update == nil || update.Node == "" {
+			return fmt.Errorf("missing node name")
+		}
+		key := fmt.Sprintf("%s:%s", update.Node, update.Segment)
+		if seen[key] {
+			return fmt.Errorf("duplicate coordinate for node %q", update.Node)
+		}
+		seen[key] = true
+
+		if err := c.checkCoordinate(update.Coord); err != nil {
+			return fmt.Errorf("%v for node %q", err, update.Node)
+		}
+		if rule != nil && c.srv.config.ACLEnforceVersion8 {
+			if !rule.NodeWrite(update.Node, nil) {
+				return acl.ErrPermissionDenied
+			}
+		}
+		if members[update.Node] {
+			return fmt.Errorf("node %q is a Serf member and reports its own coordinate", update.Node)
+		}
+		_, node, err := state.GetNode(update.Node)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return fmt.Errorf("node %q isn't registered", update.Node)
+		}
+	}
+
+	// Add the coordinates to the map of pending updates, so they're written
+	// in the same batches as the ones from Serf members.
+	c.updatesLock.Lock()
+	for _, update := range args.Coordinates {
+		key := fmt.Sprintf("%s:%s", update.Node, update.Segment)
+		c.updates[key] = &structs.CoordinateUpdateRequest{
+			Datacenter: args.Datacenter,
+			Node:       update.Node,
+			Segment:    update.Segment,
+			Coord:      update.Coord,
+		}
+	}
+	c.updatesLock.Unlock()
+	return nil
+}
+
+// checkCoordinate returns an error if a coordinate sent in an update can't
+// be stored.
+func (c *Coordinate) checkCoordinate(coord *coordinate.Coordinate) error {
+	// Older clients can send coordinates with invalid numeric values like
+	// NaN and Inf. We guard against these coming in, though newer clients
+	// should never send these.
+	if coord == nil || !coord.IsValid() {
+		return fmt.Errorf("invalid coordinate")
+	}
+
+	// Since this is a coordinate coming from some place else we harden this
+	// and look for dimensionality problems proactively.
+	local, err := c.srv.serfLAN.GetCoordinate()
+	if err != nil {
+		return err
+	}
+	if !local.IsCompatibleWith(coord) {
+		return fmt.Errorf("incompatible coordinate")
+	}
+	return nil
+}
+
 // ListDatacenters returns the list of datacenters and their respective nodes
 // and the raw coordinates of those nodes (if no coordinates are available for
 // any of the nodes, the node list may be empty).
@@ -181,7 +300,7 @@ func (c *Coordinate) ListNodes(args *structs.DCSpecificRequest, reply *structs.I
 		return err
 	}
 
-	return c.srv.blockingQuery(&args.QueryOptions,
+	return c.srv.blockingQuery("coordinates", &args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, coords, err := state.Coordinates(ws)
@@ -215,7 +334,7 @@ func (c *Coordinate) Node(args *structs.NodeSpecificRequest, reply *structs.Inde
 		}
 	}
 
-	return c.srv.blockingQuery(&args.QueryOptions,
+	return c.srv.blockingQuery("coordinates", &args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, nodeCoords, err := state.Coordinate(args.Node, ws)
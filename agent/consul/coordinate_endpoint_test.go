@@ -252,6 +252,151 @@ node "node1" {
 	}
 }
 
+func TestCoordinate_BatchUpdate(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.CoordinateUpdatePeriod = 500 * time.Millisecond
+		c.CoordinateUpdateBatchSize = 3
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+	testrpc.WaitForTestAgent(t, s1.RPC, "dc1")
+
+	nodes := []string{"node1", "node2"}
+	if err := registerNodes(nodes, codec); err != nil {
+		t.Fatal(err)
+	}
+
+	arg := structs.CoordinateBatchUpdateRequest{
+		Datacenter: "dc1",
+		Coordinates: structs.Coordinates{
+			{Node: "node1", Coord: generateRandomCoordinate()},
+			{Node: "node2", Coord: generateRandomCoordinate()},
+		},
+	}
+	var out struct{}
+	if err := msgpackrpc.CallWithCodec(codec, "Coordinate.BatchUpdate", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	retry.Run(t, func(r *retry.R) {
+		for _, update := range arg.Coordinates {
+			_, c, err := s1.fsm.State().Coordinate(update.Node, nil)
+			if err != nil {
+				r.Fatalf("err: %v", err)
+			}
+			verify.Values(r, "", c, lib.CoordinateSet{"": update.Coord})
+		}
+	})
+
+	// Invalid batches are rejected as a whole.
+	cases := map[string]struct {
+		coords structs.Coordinates
+		err    string
+	}{
+		"unregistered node": {
+			structs.Coordinates{{Node: "nope", Coord: generateRandomCoordinate()}},
+			"isn't registered",
+		},
+		"serf member": {
+			structs.Coordinates{{Node: s1.config.NodeName, Coord: generateRandomCoordinate()}},
+			"is a Serf member",
+		},
+		"duplicate": {
+			structs.Coordinates{
+				{Node: "node1", Coord: generateRandomCoordinate()},
+				{Node: "node1", Coord: generateRandomCoordinate()},
+			},
+			"duplicate coordinate",
+		},
+		"invalid": {
+			structs.Coordinates{{Node: "node1", Coord: &coordinate.Coordinate{Vec: []float64{math.NaN()}}}},
+			"invalid coordinate",
+		},
+		"too many": {
+			structs.Coordinates{
+				{Node: "node1", Coord: generateRandomCoordinate()},
+				{Node: "node2", Coord: generateRandomCoordinate()},
+				{Node: "node3", Coord: generateRandomCoordinate()},
+				{Node: "node4", Coord: generateRandomCoordinate()},
+			},
+			"too many coordinates",
+		},
+	}
+	for name, tc := range cases {
+		arg.Coordinates = tc.coords
+		err := msgpackrpc.CallWithCodec(codec, "Coordinate.BatchUpdate", &arg, &out)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: should have failed with %q, got %v", name, tc.err, err)
+		}
+	}
+}
+
+func TestCoordinate_BatchUpdate_ACLDeny(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.ACLEnforceVersion8 = false
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	nodes := []string{"node1", "node2"}
+	if err := registerNodes(nodes, codec); err != nil {
+		t.Fatal(err)
+	}
+	s1.config.ACLEnforceVersion8 = true
+
+	// Create an ACL that can only write to the first node.
+	arg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name: "User token",
+			Type: structs.ACLTokenTypeClient,
+			Rules: `
+node "node1" {
+	policy = "write"
+}
+`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var id string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := structs.CoordinateBatchUpdateRequest{
+		Datacenter: "dc1",
+		Coordinates: structs.Coordinates{
+			{Node: "node1", Coord: generateRandomCoordinate()},
+		},
+		WriteRequest: structs.WriteRequest{Token: id},
+	}
+	var out struct{}
+	if err := msgpackrpc.CallWithCodec(codec, "Coordinate.BatchUpdate", &req, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A batch with a node the token can't write to is rejected.
+	req.Coordinates = append(req.Coordinates, &structs.Coordinate{Node: "node2", Coord: generateRandomCoordinate()})
+	err := msgpackrpc.CallWithCodec(codec, "Coordinate.BatchUpdate", &req, &out)
+	if !acl.IsErrPermissionDenied(err) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestCoordinate_ListDatacenters(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServer(t)
@@ -0,0 +1,187 @@
+package consul
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/lib"
+)
+
+const (
+	// crossDCMaxRetries is how many more times a request forwarded to
+	// another datacenter is tried after failing to reach a server there.
+	crossDCMaxRetries = 2
+
+	// crossDCRetryBackoff is how long to wait before the first retry of a
+	// request forwarded to another datacenter. It doubles for each retry.
+	crossDCRetryBackoff = 100 * time.Millisecond
+)
+
+// dcBreaker is the circuit breaker state of a single datacenter.
+type dcBreaker struct {
+	// failures is the number of forwards in a row that failed to reach a
+	// server in the datacenter.
+	failures int
+
+	// open is whether requests to the datacenter fail fast.
+	open bool
+}
+
+// dcBreakers keeps a circuit breaker for each remote datacenter. A breaker
+// opens once a threshold number of forwards in a row fail to reach a server
+// in its datacenter, after which forwards there fail fast until a health
+// probe gets through and closes it again.
+type dcBreakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	breakers map[string]*dcBreaker
+	lock     sync.Mutex
+}
+
+// newDCBreakers returns breakers for the given threshold, and the cooldown
+// between health probes of open breakers. A threshold of zero disables the
+// breakers.
+func newDCBreakers(threshold int, cooldown time.Duration) *dcBreakers {
+	return &dcBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*dcBreaker),
+	}
+}
+
+// enabled returns whether the breakers are turned on.
+func (b *dcBreakers) enabled() bool {
+	return b.threshold > 0 && b.cooldown > 0
+}
+
+// allow returns whether requests may be forwarded to the given datacenter.
+func (b *dcBreakers) allow(dc string) bool {
+	if !b.enabled() {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	breaker, ok := b.breakers[dc]
+	return !ok || !breaker.open
+}
+
+// success records that a server in the given datacenter was reached, and
+// returns whether that closed its breaker.
+func (b *dcBreakers) success(dc string) bool {
+	if !b.enabled() {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	breaker, ok := b.breakers[dc]
+	if !ok {
+		return false
+	}
+	delete(b.breakers, dc)
+	return breaker.open
+}
+
+// failure records that a server in the given datacenter couldn't be
+// reached, and returns whether that opened its breaker.
+func (b *dcBreakers) failure(dc string) bool {
+	if !b.enabled() {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	breaker, ok := b.breakers[dc]
+	if !ok {
+		breaker = &dcBreaker{}
+		b.breakers[dc] = breaker
+	}
+	breaker.failures++
+	if breaker.open || breaker.failures < b.threshold {
+		return false
+	}
+	breaker.open = true
+	return true
+}
+
+// isForwardTransportErr returns whether an error forwarding a request means
+// the server couldn't be reached or stopped responding, rather than that it
+// handled the request and returned an error.
+func isForwardTransportErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	errStr := err.Error()
+	return strings.HasPrefix(errStr, "rpc error getting client") ||
+		strings.Contains(errStr, "timed out after") ||
+		lib.IsErrEOF(err)
+}
+
+// canRetryForward returns whether a request that failed to be forwarded to
+// another datacenter with the given error is safe to send again. Requests
+// that never made it to a server always are, and others are if canRetry
+// says so.
+func canRetryForward(args interface{}, err error) bool {
+	if strings.HasPrefix(err.Error(), "rpc error getting client") {
+		return true
+	}
+	return canRetry(args, err)
+}
+
+// probeDC pings a server in the given datacenter every cooldown until one
+// answers and the datacenter's breaker is closed, or the server shuts down.
+func (s *Server) probeDC(dc string) {
+	ticker := time.NewTicker(s.dcBreakers.cooldown)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.shutdownCh:
+			return
+		}
+
+		labels := []metrics.Label{{Name: "datacenter", Value: dc}}
+		manager, server, ok := s.router.FindRoute(dc)
+		if !ok {
+			continue
+		}
+		if ok, err := s.connPool.Ping(dc, server.Addr, server.Version, server.UseTLS); !ok {
+			manager.NotifyFailedServer(server)
+			metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "probe_failure"}, 1, labels)
+			s.logger.Printf("[DEBUG] consul.rpc: Health probe of server %s in DC %q failed: %v", server.Addr, dc, err)
+			continue
+		}
+
+		if s.dcBreakers.success(dc) {
+			metrics.SetGaugeWithLabels([]string{"rpc", "cross-dc", "breaker_open"}, 0, labels)
+			s.logger.Printf("[INFO] consul.rpc: Server %s in DC %q is reachable again, resuming forwarding", server.Addr, dc)
+		}
+		return
+	}
+}
+
+// recordForward updates the breaker of the given datacenter and the forward
+// metrics with the outcome of a forwarded request.
+func (s *Server) recordForward(dc string, err error) {
+	labels := []metrics.Label{{Name: "datacenter", Value: dc}}
+	if !isForwardTransportErr(err) {
+		metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "success"}, 1, labels)
+		if s.dcBreakers.success(dc) {
+			metrics.SetGaugeWithLabels([]string{"rpc", "cross-dc", "breaker_open"}, 0, labels)
+			s.logger.Printf("[INFO] consul.rpc: DC %q is reachable again, resuming forwarding", dc)
+		}
+		return
+	}
+
+	metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "failure"}, 1, labels)
+	if s.dcBreakers.failure(dc) {
+		metrics.SetGaugeWithLabels([]string{"rpc", "cross-dc", "breaker_open"}, 1, labels)
+		s.logger.Printf("[WARN] consul.rpc: Failed to reach DC %q %d times in a row, failing requests to it fast until a server there responds",
+			dc, s.dcBreakers.threshold)
+		go s.probeDC(dc)
+	}
+}
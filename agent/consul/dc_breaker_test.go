@@ -0,0 +1,66 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDCBreakers(t *testing.T) {
+	t.Parallel()
+
+	b := newDCBreakers(3, time.Second)
+	require.True(t, b.allow("dc2"))
+
+	// Failures below the threshold don't open the breaker, and a success
+	// starts the count over.
+	require.False(t, b.failure("dc2"))
+	require.False(t, b.failure("dc2"))
+	require.False(t, b.success("dc2"))
+	require.False(t, b.failure("dc2"))
+	require.False(t, b.failure("dc2"))
+	require.True(t, b.allow("dc2"))
+
+	// The threshold opens it once.
+	require.True(t, b.failure("dc2"))
+	require.False(t, b.failure("dc2"))
+	require.False(t, b.allow("dc2"))
+
+	// Other datacenters are unaffected.
+	require.True(t, b.allow("dc3"))
+
+	// A success closes it again.
+	require.True(t, b.success("dc2"))
+	require.True(t, b.allow("dc2"))
+
+	// A zero threshold disables the breakers.
+	b = newDCBreakers(0, time.Second)
+	for i := 0; i < 10; i++ {
+		require.False(t, b.failure("dc2"))
+	}
+	require.True(t, b.allow("dc2"))
+}
+
+func TestIsForwardTransportErr(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, isForwardTransportErr(nil))
+	require.True(t, isForwardTransportErr(fmt.Errorf("rpc error getting client: dial tcp: i/o timeout")))
+	require.True(t, isForwardTransportErr(fmt.Errorf("rpc error making call: Catalog.ListNodes timed out after 1m0s")))
+	require.True(t, isForwardTransportErr(io.EOF))
+	require.False(t, isForwardTransportErr(fmt.Errorf("rpc error making call: %v", structs.ErrNoLeader)))
+	require.False(t, isForwardTransportErr(errors.New("Permission denied")))
+
+	// Requests that never reached a server can always be retried, others
+	// only if they're reads.
+	write := &structs.RegisterRequest{}
+	read := &structs.DCSpecificRequest{}
+	require.True(t, canRetryForward(write, fmt.Errorf("rpc error getting client: dial tcp: i/o timeout")))
+	require.False(t, canRetryForward(write, io.EOF))
+	require.True(t, canRetryForward(read, io.EOF))
+}
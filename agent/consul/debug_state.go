@@ -0,0 +1,43 @@
+package consul
+
+import (
+	"sort"
+
+	"github.com/hashicorp/consul/agent/pool"
+)
+
+// DebugState is a point in time view of what a client or server knows about
+// the servers it talks to, used to debug an agent disagreeing with the
+// servers.
+type DebugState struct {
+	// Servers are the servers known to this agent. For clients they are
+	// in the order they will be tried for RPCs.
+	Servers []string
+
+	// ConnPool describes the open RPC connections to servers.
+	ConnPool pool.PoolStats
+}
+
+// DebugState returns the client's view of the servers.
+func (c *Client) DebugState() *DebugState {
+	state := &DebugState{
+		ConnPool: c.connPool.Stats(),
+	}
+	for _, s := range c.routers.Servers() {
+		state.Servers = append(state.Servers, s.String())
+	}
+	return state
+}
+
+// DebugState returns the server's view of the other servers in its
+// datacenter.
+func (s *Server) DebugState() *DebugState {
+	state := &DebugState{
+		ConnPool: s.connPool.Stats(),
+	}
+	for _, srv := range s.serverLookup.Servers() {
+		state.Servers = append(state.Servers, srv.String())
+	}
+	sort.Strings(state.Servers)
+	return state
+}
@@ -0,0 +1,213 @@
+package consul
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-memdb"
+)
+
+// electionRetryInterval is how long to wait before looking at the elections
+// again after a failure.
+const electionRetryInterval = 5 * time.Second
+
+// startElections starts a goroutine that watches the elections and hands
+// leadership to the next candidate whenever an election is left without a
+// leader. This only runs on the leader.
+func (s *Server) startElections() {
+	s.electionsLock.Lock()
+	defer s.electionsLock.Unlock()
+
+	if s.electionsEnabled {
+		return
+	}
+
+	s.electionsCh = make(chan struct{})
+
+	go func(stopCh chan struct{}) {
+		for {
+			ws := memdb.NewWatchSet()
+			ws.Add(stopCh)
+
+			wait, err := s.electLeaders(ws)
+			if err != nil {
+				s.logger.Printf("[ERR] consul.election: Failed to elect leaders: %v", err)
+				wait = electionRetryInterval
+			}
+
+			var timeoutCh <-chan time.Time
+			if wait > 0 {
+				timeoutCh = time.After(wait)
+			}
+			ws.Watch(timeoutCh)
+
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+		}
+	}(s.electionsCh)
+
+	s.electionsEnabled = true
+}
+
+// stopElections stops the election goroutine.
+func (s *Server) stopElections() {
+	s.electionsLock.Lock()
+	defer s.electionsLock.Unlock()
+
+	if !s.electionsEnabled {
+		return
+	}
+
+	close(s.electionsCh)
+	s.electionsEnabled = false
+}
+
+// electLeaders looks at all the elections, removes candidates whose sessions
+// were invalidated, and elects a leader for every election that has
+// candidates but no leader. It returns how long to wait before trying again
+// if any election couldn't be decided yet because of a lock delay.
+func (s *Server) electLeaders(ws memdb.WatchSet) (time.Duration, error) {
+	_, entries, err := s.fsm.State().KVSList(ws, structs.ElectionKVPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	elections := make(map[string]structs.DirEntries)
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry.Key, structs.ElectionKVPrefix)
+		if i := strings.Index(name, "/"); i > 0 {
+			name = name[:i]
+			elections[name] = append(elections[name], entry)
+		}
+	}
+
+	var wait time.Duration
+	for name, entries := range elections {
+		// Candidate keys are left behind unlocked when a session with
+		// the release behavior is invalidated.
+		for _, entry := range entries {
+			if entry.Session == "" && strings.HasPrefix(entry.Key, structs.ElectionCandidatePrefix(name)) {
+				if _, err := s.applyElectionKVS(s.raftApplyInternal, api.KVDelete, entry.Key, nil, ""); err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		delay, err := s.electLeader(name, entries)
+		if err != nil {
+			return 0, err
+		}
+		if delay > 0 && (wait == 0 || delay < wait) {
+			wait = delay
+		}
+	}
+	return wait, nil
+}
+
+// electLeader makes the first registered candidate the leader of the given
+// election, if it doesn't have a leader. If the leader key is still in its
+// lock delay after the last leader's session was invalidated, nothing is done
+// and the remaining delay is returned.
+func (s *Server) electLeader(name string, entries structs.DirEntries) (time.Duration, error) {
+	election := electionFromEntries(name, entries)
+	if election.Leader != nil || len(election.Candidates) == 0 {
+		return 0, nil
+	}
+
+	key := structs.ElectionLeaderKey(name)
+	if expires := s.fsm.State().KVSLockDelay(key); expires.After(time.Now()) {
+		return time.Until(expires), nil
+	}
+
+	next := election.Candidates[0]
+	ok, err := s.applyElectionKVS(s.raftApplyInternal, api.KVLock, key, next.Value, next.Session)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		metrics.IncrCounter([]string{"election", "leader_change"}, 1)
+		s.logger.Printf("[INFO] consul.election: Session '%s' is the leader of election '%s'", next.Session, name)
+	}
+	return 0, nil
+}
+
+// applyElectionKVS applies a KV operation on one of the keys of an election
+// with the given apply function, which is raftApply for operations made by
+// clients and raftApplyInternal for the leader's own. It returns the result
+// of the operation for locks.
+func (s *Server) applyElectionKVS(apply raftApplyFunc, op api.KVOp, key string, value []byte, session string) (bool, error) {
+	req := structs.KVSRequest{
+		Datacenter: s.config.Datacenter,
+		Op:         op,
+		DirEnt: structs.DirEntry{
+			Key:     key,
+			Value:   value,
+			Session: session,
+		},
+	}
+	resp, err := apply(structs.KVSRequestType, &req)
+	if err != nil {
+		s.logger.Printf("[ERR] consul.election: Apply failed: %v", err)
+		return false, err
+	}
+	if respErr, ok := resp.(error); ok {
+		return false, respErr
+	}
+	ok, _ := resp.(bool)
+	return ok, nil
+}
+
+// electionFromEntries returns the state of an election from its KV entries.
+func electionFromEntries(name string, entries structs.DirEntries) *structs.Election {
+	election := &structs.Election{
+		Name:       name,
+		Candidates: []*structs.ElectionCandidate{},
+	}
+
+	var leader *structs.DirEntry
+	prefix := structs.ElectionCandidatePrefix(name)
+	for _, entry := range entries {
+		switch {
+		case entry.Key == structs.ElectionLeaderKey(name):
+			leader = entry
+			election.Term = entry.LockIndex
+
+		case strings.HasPrefix(entry.Key, prefix) && entry.Session != "":
+			election.Candidates = append(election.Candidates, &structs.ElectionCandidate{
+				Session:     entry.Session,
+				Value:       entry.Value,
+				CreateIndex: entry.CreateIndex,
+			})
+		}
+	}
+	sort.Slice(election.Candidates, func(i, j int) bool {
+		return election.Candidates[i].CreateIndex < election.Candidates[j].CreateIndex
+	})
+
+	if leader != nil && leader.Session != "" {
+		for _, candidate := range election.Candidates {
+			if candidate.Session == leader.Session {
+				election.Leader = candidate
+				break
+			}
+		}
+
+		// The leader stays the leader until its session is invalidated
+		// or it resigns, even if its candidate key was deleted.
+		if election.Leader == nil {
+			election.Leader = &structs.ElectionCandidate{
+				Session:     leader.Session,
+				Value:       leader.Value,
+				CreateIndex: leader.ModifyIndex,
+			}
+		}
+	}
+	return election
+}
@@ -0,0 +1,143 @@
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-memdb"
+)
+
+// Election manages leader elections between sessions. The elections are kept
+// in the KV store, but the servers do the locking and hand leadership to the
+// next candidate, so clients don't have to watch and re-acquire the lock.
+type Election struct {
+	// srv is a pointer back to the server.
+	srv *Server
+}
+
+// Apply registers a session as a candidate in an election, or removes it.
+// Candidates are elected in the order they registered. The reply is true if
+// the session is the leader after the operation. This requires write
+// privileges for the election's KV prefix.
+func (e *Election) Apply(args *structs.ElectionRequest, reply *bool) error {
+	if done, err := e.srv.forward("Election.Apply", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"election", "apply"}, time.Now())
+
+	if err := structs.ValidateElectionName(args.Name); err != nil {
+		return err
+	}
+	if args.Session == "" {
+		return fmt.Errorf("Must provide a session")
+	}
+	if args.Op != structs.ElectionCampaign && args.Op != structs.ElectionResign {
+		return fmt.Errorf("Invalid election operation %q", args.Op)
+	}
+
+	rule, err := e.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.KeyWritePrefix(structs.ElectionPrefix(args.Name)) {
+		return acl.ErrPermissionDenied
+	}
+
+	state := e.srv.fsm.State()
+	leaderKey := structs.ElectionLeaderKey(args.Name)
+	candidateKey := structs.ElectionCandidateKey(args.Name, args.Session)
+	_, leader, err := state.KVSGet(nil, leaderKey)
+	if err != nil {
+		return err
+	}
+	isLeader := leader != nil && leader.Session == args.Session
+
+	switch args.Op {
+	case structs.ElectionCampaign:
+		_, session, err := state.SessionGet(nil, args.Session)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return fmt.Errorf("Invalid session %q", args.Session)
+		}
+		if _, err := e.srv.applyElectionKVS(e.srv.raftApply, api.KVLock, candidateKey, args.Value, args.Session); err != nil {
+			return err
+		}
+
+		// Campaigning again updates the value, which the leader also
+		// publishes on the leader key.
+		if isLeader {
+			if _, err := e.srv.applyElectionKVS(e.srv.raftApply, api.KVLock, leaderKey, args.Value, args.Session); err != nil {
+				return err
+			}
+		}
+
+	case structs.ElectionResign:
+		if isLeader {
+			if _, err := e.srv.applyElectionKVS(e.srv.raftApply, api.KVUnlock, leaderKey, nil, args.Session); err != nil {
+				return err
+			}
+		}
+		if _, err := e.srv.applyElectionKVS(e.srv.raftApply, api.KVDelete, candidateKey, nil, ""); err != nil {
+			return err
+		}
+	}
+
+	// Decide the election now rather than waiting for the leader's watch
+	// to fire, so the reply says whether this session won.
+	_, entries, err := state.KVSList(nil, structs.ElectionPrefix(args.Name))
+	if err != nil {
+		return err
+	}
+	if _, err := e.srv.electLeader(args.Name, entries); err != nil {
+		return err
+	}
+
+	_, leader, err = state.KVSGet(nil, leaderKey)
+	if err != nil {
+		return err
+	}
+	*reply = leader != nil && leader.Session == args.Session
+	return nil
+}
+
+// Get returns the leader and candidates of an election. This requires read
+// privileges for the election's leader key.
+func (e *Election) Get(args *structs.ElectionSpecificRequest, reply *structs.IndexedElection) error {
+	if done, err := e.srv.forward("Election.Get", args, args, reply); done {
+		return err
+	}
+
+	if err := structs.ValidateElectionName(args.Name); err != nil {
+		return err
+	}
+
+	rule, err := e.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.KeyRead(structs.ElectionLeaderKey(args.Name)) {
+		return acl.ErrPermissionDenied
+	}
+
+	return e.srv.blockingQuery(
+		"kvs",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, entries, err := state.KVSList(ws, structs.ElectionPrefix(args.Name))
+			if err != nil {
+				return err
+			}
+
+			reply.Index = index
+			reply.Election = electionFromEntries(args.Name, entries)
+			return nil
+		})
+}
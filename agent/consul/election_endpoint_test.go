@@ -0,0 +1,151 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElection_Apply(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	require.NoError(t, s1.fsm.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+	createSession := func(lockDelay time.Duration) string {
+		arg := structs.SessionRequest{
+			Datacenter: "dc1",
+			Op:         structs.SessionCreate,
+			Session: structs.Session{
+				Node:      "foo",
+				LockDelay: lockDelay,
+			},
+		}
+		var id string
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &id))
+		return id
+	}
+	campaign := func(op structs.ElectionOp, session string, value string) bool {
+		arg := structs.ElectionRequest{
+			Datacenter: "dc1",
+			Op:         op,
+			Name:       "db",
+			Session:    session,
+			Value:      []byte(value),
+		}
+		var leader bool
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Election.Apply", &arg, &leader))
+		return leader
+	}
+	get := func() *structs.Election {
+		arg := structs.ElectionSpecificRequest{
+			Datacenter: "dc1",
+			Name:       "db",
+		}
+		var out structs.IndexedElection
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Election.Get", &arg, &out))
+		return out.Election
+	}
+
+	// An election nobody has joined has no leader.
+	election := get()
+	require.Nil(t, election.Leader)
+	require.Empty(t, election.Candidates)
+
+	// The first candidate wins and the second waits its turn.
+	id1 := createSession(0)
+	id2 := createSession(50 * time.Millisecond)
+	id3 := createSession(0)
+	require.True(t, campaign(structs.ElectionCampaign, id1, "one"))
+	require.False(t, campaign(structs.ElectionCampaign, id2, "two"))
+	election = get()
+	require.Equal(t, id1, election.Leader.Session)
+	require.Equal(t, []byte("one"), election.Leader.Value)
+	require.Equal(t, uint64(1), election.Term)
+	require.Len(t, election.Candidates, 2)
+	require.Equal(t, id2, election.Candidates[1].Session)
+
+	// Campaigning again updates the leader's value.
+	require.True(t, campaign(structs.ElectionCampaign, id1, "uno"))
+	_, ent, err := s1.fsm.State().KVSGet(nil, structs.ElectionLeaderKey("db"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("uno"), ent.Value)
+
+	// Resigning hands leadership to the next candidate straight away.
+	require.False(t, campaign(structs.ElectionResign, id1, ""))
+	election = get()
+	require.Equal(t, id2, election.Leader.Session)
+	require.Equal(t, uint64(2), election.Term)
+	require.Len(t, election.Candidates, 1)
+
+	// When the leader's session goes away, the servers elect the next
+	// candidate once the lock delay has passed.
+	require.False(t, campaign(structs.ElectionCampaign, id3, "three"))
+	arg := structs.SessionRequest{
+		Datacenter: "dc1",
+		Op:         structs.SessionDestroy,
+		Session:    structs.Session{ID: id2},
+	}
+	var out string
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out))
+	retry.Run(t, func(r *retry.R) {
+		election := get()
+		if election.Leader == nil || election.Leader.Session != id3 {
+			r.Fatalf("bad: %#v", election.Leader)
+		}
+		if len(election.Candidates) != 1 {
+			r.Fatalf("bad: %#v", election.Candidates)
+		}
+	})
+
+	// The candidate key left behind by the destroyed session is removed.
+	retry.Run(t, func(r *retry.R) {
+		_, ent, err := s1.fsm.State().KVSGet(nil, structs.ElectionCandidateKey("db", id2))
+		if err != nil {
+			r.Fatal(err)
+		}
+		if ent != nil {
+			r.Fatalf("bad: %#v", ent)
+		}
+	})
+}
+
+func TestElection_Apply_Invalid(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	cases := []struct {
+		req structs.ElectionRequest
+		err string
+	}{
+		{structs.ElectionRequest{Op: structs.ElectionCampaign, Session: "foo"}, "Must provide an election name"},
+		{structs.ElectionRequest{Op: structs.ElectionCampaign, Name: "a/b", Session: "foo"}, "can't contain '/'"},
+		{structs.ElectionRequest{Op: structs.ElectionCampaign, Name: "db"}, "Must provide a session"},
+		{structs.ElectionRequest{Op: "nope", Name: "db", Session: "foo"}, "Invalid election operation"},
+		{structs.ElectionRequest{Op: structs.ElectionCampaign, Name: "db", Session: "adf4238a-882b-9ddc-4a9d-5b6758e4159e"}, "Invalid session"},
+	}
+	for _, tc := range cases {
+		tc.req.Datacenter = "dc1"
+		var leader bool
+		err := msgpackrpc.CallWithCodec(codec, "Election.Apply", &tc.req, &leader)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tc.err)
+	}
+}
@@ -0,0 +1,396 @@
+package consul
+
+import (
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/checks"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/types"
+	"github.com/hashicorp/serf/serf"
+)
+
+const (
+	// externalNodeMetaKey is the node meta key that marks a catalog node as
+	// external. External nodes have no agent, so the servers run their HTTP
+	// and TCP checks instead.
+	externalNodeMetaKey = "external-node"
+
+	// externalProbeMetaKey is the node meta key an external node sets to be
+	// probed for liveness. The value is a tcp://, http:// or https:// target
+	// without a host, so "tcp://:22" probes port 22 of the node. The host is
+	// always the node's registered address, so node meta can't point the
+	// servers at anything else. The result is kept in a node-level
+	// structs.NodeProbeCheckID check, like the serfHealth check of nodes
+	// that run an agent.
+	externalProbeMetaKey = "external-probe"
+)
+
+// externalCheckRunner is the part of checks.CheckHTTP and checks.CheckTCP
+// that's needed to run an external check.
+type externalCheckRunner interface {
+	Start()
+	Stop()
+}
+
+// externalCheck is an external check that this server is running.
+type externalCheck struct {
+	check  *structs.HealthCheck
+	runner externalCheckRunner
+}
+
+// externalCheckKey identifies a check across all the external nodes.
+type externalCheckKey struct {
+	Node    string
+	CheckID types.CheckID
+}
+
+// runExternalChecks periodically loads the external checks from the catalog
+// and runs the ones this server is responsible for until the server shuts
+// down. The checks are shared out by hashing them over the alive servers that
+// run external checks, so every server reaches the same split without having
+// to coordinate.
+func (s *Server) runExternalChecks() {
+	running := make(map[externalCheckKey]*externalCheck)
+	defer func() {
+		for _, ec := range running {
+			ec.runner.Stop()
+		}
+	}()
+
+	ticker := time.NewTicker(s.config.ExternalCheckSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.syncExternalChecks(running); err != nil {
+			s.logger.Printf("[ERR] consul: error syncing external checks: %v", err)
+		}
+
+		select {
+		case <-s.shutdownCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncExternalChecks starts and stops the checks in running so it holds the
+// external checks this server is responsible for.
+func (s *Server) syncExternalChecks(running map[externalCheckKey]*externalCheck) error {
+	defer metrics.MeasureSince([]string{"external_checks", "sync"}, time.Now())
+
+	servers := externalCheckServers(s.serfLAN.Members())
+
+	state := s.fsm.State()
+	_, nodes, err := state.NodesByMeta(nil, map[string]string{externalNodeMetaKey: "true"})
+	if err != nil {
+		return err
+	}
+	wanted := make(map[externalCheckKey]*structs.HealthCheck)
+	for _, node := range nodes {
+		_, nodeChecks, err := state.NodeChecks(nil, node.Node)
+		if err != nil {
+			return err
+		}
+		for _, check := range nodeChecks {
+			def := check.Definition
+			if (def.HTTP == "" && def.TCP == "") || def.Interval <= 0 {
+				continue
+			}
+			key := externalCheckKey{Node: check.Node, CheckID: check.CheckID}
+			if externalCheckOwner(servers, key) == s.config.NodeName {
+				wanted[key] = check
+			}
+		}
+
+		key := externalCheckKey{Node: node.Node, CheckID: structs.NodeProbeCheckID}
+		if externalCheckOwner(servers, key) != s.config.NodeName {
+			continue
+		}
+		probe, err := s.syncNodeProbe(node, nodeChecks)
+		if err != nil {
+			return err
+		}
+		if probe != nil {
+			wanted[key] = probe
+		} else {
+			delete(wanted, key)
+		}
+	}
+
+	for key, ec := range running {
+		if check, ok := wanted[key]; ok && reflect.DeepEqual(check.Definition, ec.check.Definition) {
+			continue
+		}
+		ec.runner.Stop()
+		delete(running, key)
+	}
+	for key, check := range wanted {
+		if _, ok := running[key]; ok {
+			continue
+		}
+		ec := &externalCheck{
+			check:  check,
+			runner: s.newExternalCheckRunner(check),
+		}
+		ec.runner.Start()
+		running[key] = ec
+	}
+
+	metrics.SetGauge([]string{"external_checks", "running"}, float32(len(running)))
+	return nil
+}
+
+// syncNodeProbe registers, updates or deregisters the probe check of the
+// external node so it matches its probe target, and returns the check, or nil
+// if the node doesn't ask to be probed. Only the server that owns the probe
+// calls this.
+func (s *Server) syncNodeProbe(node *structs.Node, nodeChecks structs.HealthChecks) (*structs.HealthCheck, error) {
+	var existing *structs.HealthCheck
+	for _, check := range nodeChecks {
+		if check.CheckID == structs.NodeProbeCheckID {
+			existing = check
+		}
+	}
+
+	target := node.Meta[externalProbeMetaKey]
+	if target == "" {
+		if existing == nil {
+			return nil, nil
+		}
+		req := structs.DeregisterRequest{
+			Datacenter:   s.config.Datacenter,
+			Node:         node.Node,
+			CheckID:      structs.NodeProbeCheckID,
+			WriteRequest: structs.WriteRequest{Token: s.tokens.AgentToken()},
+		}
+		var reply struct{}
+		return nil, s.RPC("Catalog.Deregister", &req, &reply)
+	}
+
+	def, err := s.nodeProbeDefinition(node, target)
+	if err != nil {
+		s.logger.Printf("[WARN] consul: not probing external node %q: %v", node.Node, err)
+		return nil, nil
+	}
+	if existing != nil && reflect.DeepEqual(existing.Definition, def) {
+		return existing, nil
+	}
+
+	// The probe starts out critical until it first succeeds, and keeps its
+	// status when its target changes.
+	check := &structs.HealthCheck{
+		Node:       node.Node,
+		CheckID:    structs.NodeProbeCheckID,
+		Name:       structs.NodeProbeCheckName,
+		Status:     api.HealthCritical,
+		Definition: def,
+	}
+	if existing != nil {
+		check.Status = existing.Status
+		check.Output = existing.Output
+	}
+	req := structs.RegisterRequest{
+		Datacenter:     s.config.Datacenter,
+		Node:           node.Node,
+		SkipNodeUpdate: true,
+		Check:          check,
+		WriteRequest:   structs.WriteRequest{Token: s.tokens.AgentToken()},
+	}
+	var reply struct{}
+	if err := s.RPC("Catalog.Register", &req, &reply); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// nodeProbeDefinition returns the definition of the check that probes the
+// given target of an external node. Only the scheme, port and path are taken
+// from the target, and the host is always the node's address.
+func (s *Server) nodeProbeDefinition(node *structs.Node, target string) (structs.HealthCheckDefinition, error) {
+	def := structs.HealthCheckDefinition{
+		Interval: api.ReadableDuration(s.config.ExternalNodeProbeInterval),
+		Timeout:  api.ReadableDuration(s.config.ExternalNodeProbeTimeout),
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return def, fmt.Errorf("invalid probe target %q: %v", target, err)
+	}
+	if u.Scheme != "tcp" && u.Scheme != "http" && u.Scheme != "https" {
+		return def, fmt.Errorf("probe target %q must be tcp://, http:// or https://", target)
+	}
+	if u.Opaque != "" || u.User != nil || u.Hostname() != "" || u.RawQuery != "" || u.Fragment != "" {
+		return def, fmt.Errorf("probe target %q can only have a scheme, port and path, since the node's address is always probed", target)
+	}
+	port := u.Port()
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return def, fmt.Errorf("probe target %q has an invalid port", target)
+		}
+	}
+
+	host := node.Address
+	if port != "" {
+		host = net.JoinHostPort(node.Address, port)
+	} else if ip := net.ParseIP(node.Address); ip != nil && ip.To4() == nil {
+		host = "[" + node.Address + "]"
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		if port == "" {
+			return def, fmt.Errorf("probe target %q has no port", target)
+		}
+		if u.Path != "" {
+			return def, fmt.Errorf("probe target %q can't have a path", target)
+		}
+		def.TCP = host
+	default:
+		probe := url.URL{Scheme: u.Scheme, Host: host, Path: u.Path}
+		def.HTTP = probe.String()
+	}
+	return def, nil
+}
+
+// externalCheckServers returns the sorted names of the alive servers that
+// advertise that they run external checks. Servers that don't would never
+// run the checks hashed to them.
+func externalCheckServers(members []serf.Member) []string {
+	var servers []string
+	for _, member := range members {
+		if ok, parts := metadata.IsConsulServer(member); ok && member.Status == serf.StatusAlive && parts.ExternalChecks {
+			servers = append(servers, parts.Name)
+		}
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+// externalCheckOwner returns which of the given servers should run the check.
+// The servers must be sorted so every server picks the same one.
+func externalCheckOwner(servers []string, key externalCheckKey) string {
+	if len(servers) == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key.Node))
+	h.Write([]byte{0})
+	h.Write([]byte(key.CheckID))
+	return servers[h.Sum32()%uint32(len(servers))]
+}
+
+// newExternalCheckRunner returns an HTTP or TCP check for the given check's
+// definition, that updates its status in the catalog.
+func (s *Server) newExternalCheckRunner(check *structs.HealthCheck) externalCheckRunner {
+	notify := &externalCheckNotifier{srv: s, node: check.Node}
+	def := check.Definition
+	if def.HTTP != "" {
+		return &checks.CheckHTTP{
+			Notify:          notify,
+			CheckID:         check.CheckID,
+			HTTP:            def.HTTP,
+			Header:          def.Header,
+			Method:          def.Method,
+			Interval:        time.Duration(def.Interval),
+			Timeout:         time.Duration(def.Timeout),
+			Logger:          s.logger,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: def.TLSSkipVerify},
+			Control:         s.externalCheckControl,
+		}
+	}
+	return &checks.CheckTCP{
+		Notify:   notify,
+		CheckID:  check.CheckID,
+		TCP:      def.TCP,
+		Interval: time.Duration(def.Interval),
+		Timeout:  time.Duration(def.Timeout),
+		Logger:   s.logger,
+		Control:  s.externalCheckControl,
+	}
+}
+
+// externalCheckControl refuses the connections of external checks to
+// addresses they aren't allowed to reach. Anyone who can register a check in
+// the catalog picks its target, so without this they could use the servers to
+// reach services only the servers can, such as their own HTTP API or a cloud
+// metadata service. It's applied to the resolved address of every connection,
+// so host names and redirects can't get around it.
+func (s *Server) externalCheckControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !externalCheckAddrAllowed(s.config.ExternalCheckAllowedCIDRs, net.ParseIP(host)) {
+		metrics.IncrCounter([]string{"external_checks", "refused"}, 1)
+		return fmt.Errorf("external checks aren't allowed to connect to %s", host)
+	}
+	return nil
+}
+
+// externalCheckAddrAllowed returns whether an external check may connect to
+// the given IP. If there are allowed networks, the IP must be in one of them.
+// Otherwise any IP other than loopback, link-local, multicast and unspecified
+// ones is allowed.
+func externalCheckAddrAllowed(allowed []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if len(allowed) > 0 {
+		for _, network := range allowed {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// externalCheckNotifier writes the results of an external check back to the
+// catalog through Raft.
+type externalCheckNotifier struct {
+	srv  *Server
+	node string
+}
+
+// UpdateCheck is called by the check with its latest result. The catalog is
+// only updated when the status or output changes.
+func (n *externalCheckNotifier) UpdateCheck(checkID types.CheckID, status, output string) {
+	_, existing, err := n.srv.fsm.State().NodeCheck(n.node, checkID)
+	if err != nil {
+		n.srv.logger.Printf("[ERR] consul: failed to look up external check %q on node %q: %v", checkID, n.node, err)
+		return
+	}
+	if existing == nil || (existing.Status == status && existing.Output == output) {
+		return
+	}
+
+	check := existing.Clone()
+	check.Status = status
+	check.Output = output
+	req := structs.RegisterRequest{
+		Datacenter:     n.srv.config.Datacenter,
+		Node:           n.node,
+		SkipNodeUpdate: true,
+		Check:          check,
+		WriteRequest:   structs.WriteRequest{Token: n.srv.tokens.AgentToken()},
+	}
+	var reply struct{}
+	if err := n.srv.RPC("Catalog.Register", &req, &reply); err != nil {
+		n.srv.logger.Printf("[ERR] consul: failed to update external check %q on node %q: %v", checkID, n.node, err)
+		return
+	}
+	metrics.IncrCounter([]string{"external_checks", "update"}, 1)
+}
@@ -0,0 +1,374 @@
+package consul
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/hashicorp/serf/serf"
+)
+
+func TestExternalChecks(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ExternalChecksEnabled = true
+		c.ExternalCheckSyncInterval = 100 * time.Millisecond
+		_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+		c.ExternalCheckAllowedCIDRs = []*net.IPNet{loopback}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	// Redirects are checked against the allowed networks too.
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://[::1]:8500/v1/agent/self", http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	// Grab a port with nothing listening on it for the TCP check.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	closedAddr := ln.Addr().String()
+	ln.Close()
+
+	register := func(node string, meta map[string]string, check *structs.HealthCheck) {
+		t.Helper()
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			NodeMeta:   meta,
+			Check:      check,
+		}
+		var out struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	external := map[string]string{externalNodeMetaKey: "true"}
+	register("ext1", external, &structs.HealthCheck{
+		Node:    "ext1",
+		CheckID: "http",
+		Name:    "http",
+		Status:  api.HealthCritical,
+		Definition: structs.HealthCheckDefinition{
+			HTTP:     server.URL,
+			Interval: api.ReadableDuration(50 * time.Millisecond),
+		},
+	})
+	register("ext1", external, &structs.HealthCheck{
+		Node:    "ext1",
+		CheckID: "redirect",
+		Name:    "redirect",
+		Status:  api.HealthPassing,
+		Definition: structs.HealthCheckDefinition{
+			HTTP:     redirect.URL,
+			Interval: api.ReadableDuration(50 * time.Millisecond),
+		},
+	})
+	register("ext1", external, &structs.HealthCheck{
+		Node:    "ext1",
+		CheckID: "tcp",
+		Name:    "tcp",
+		Status:  api.HealthPassing,
+		Definition: structs.HealthCheckDefinition{
+			TCP:      closedAddr,
+			Interval: api.ReadableDuration(50 * time.Millisecond),
+		},
+	})
+
+	// Checks on nodes that aren't external are left alone.
+	register("other", nil, &structs.HealthCheck{
+		Node:    "other",
+		CheckID: "http",
+		Name:    "http",
+		Status:  api.HealthCritical,
+		Definition: structs.HealthCheckDefinition{
+			HTTP:     server.URL,
+			Interval: api.ReadableDuration(50 * time.Millisecond),
+		},
+	})
+
+	state := s1.fsm.State()
+	retry.Run(t, func(r *retry.R) {
+		_, check, err := state.NodeCheck("ext1", "http")
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if check.Status != api.HealthPassing {
+			r.Fatalf("bad: %#v", check)
+		}
+		_, check, err = state.NodeCheck("ext1", "tcp")
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if check.Status != api.HealthCritical || check.Output == "" {
+			r.Fatalf("bad: %#v", check)
+		}
+		_, check, err = state.NodeCheck("ext1", "redirect")
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if check.Status != api.HealthCritical || !strings.Contains(check.Output, "aren't allowed to connect to ::1") {
+			r.Fatalf("bad: %#v", check)
+		}
+	})
+
+	_, check, err := state.NodeCheck("other", "http")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if check.Status != api.HealthCritical {
+		t.Fatalf("bad: %#v", check)
+	}
+}
+
+func TestExternalCheckAddrAllowed(t *testing.T) {
+	t.Parallel()
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+
+	cases := []struct {
+		allowed []*net.IPNet
+		ip      string
+		want    bool
+	}{
+		{nil, "10.1.2.3", true},
+		{nil, "2001:db8::1", true},
+		{nil, "127.0.0.1", false},
+		{nil, "::1", false},
+		{nil, "169.254.169.254", false},
+		{nil, "fe80::1", false},
+		{nil, "224.0.0.1", false},
+		{nil, "0.0.0.0", false},
+		{nil, "", false},
+		{[]*net.IPNet{private}, "10.1.2.3", true},
+		{[]*net.IPNet{private}, "192.168.1.1", false},
+		{[]*net.IPNet{private, loopback}, "127.0.0.1", true},
+	}
+	for _, tc := range cases {
+		if got := externalCheckAddrAllowed(tc.allowed, net.ParseIP(tc.ip)); got != tc.want {
+			t.Fatalf("%v %q: got %v want %v", tc.allowed, tc.ip, got, tc.want)
+		}
+	}
+}
+
+func TestExternalCheckServers(t *testing.T) {
+	t.Parallel()
+
+	makeMember := func(name string, externalChecks bool, status serf.MemberStatus) serf.Member {
+		m := serf.Member{
+			Name: name,
+			Addr: net.IP([]byte{127, 0, 0, 1}),
+			Tags: map[string]string{
+				"role":  "consul",
+				"id":    name,
+				"dc":    "dc1",
+				"port":  "8300",
+				"build": "1.4.0",
+				"vsn":   "2",
+			},
+			Status: status,
+		}
+		if externalChecks {
+			m.Tags["ext_checks"] = "1"
+		}
+		return m
+	}
+	client := serf.Member{Name: "client", Tags: map[string]string{"role": "node"}, Status: serf.StatusAlive}
+	members := []serf.Member{
+		makeMember("s3", true, serf.StatusAlive),
+		makeMember("s1", true, serf.StatusAlive),
+		makeMember("s2", false, serf.StatusAlive),
+		makeMember("s4", true, serf.StatusFailed),
+		client,
+	}
+
+	// Checks are only hashed to servers that will run them.
+	servers := externalCheckServers(members)
+	if !reflect.DeepEqual(servers, []string{"s1", "s3"}) {
+		t.Fatalf("bad: %v", servers)
+	}
+}
+
+func TestExternalCheckOwner(t *testing.T) {
+	t.Parallel()
+	if owner := externalCheckOwner(nil, externalCheckKey{Node: "ext1", CheckID: "http"}); owner != "" {
+		t.Fatalf("bad: %q", owner)
+	}
+
+	// Every check gets an owner, and the checks are spread over the servers.
+	servers := []string{"server1", "server2", "server3"}
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		key := externalCheckKey{Node: fmt.Sprintf("ext%d", i), CheckID: "http"}
+		owner := externalCheckOwner(servers, key)
+		if owner != externalCheckOwner(servers, key) {
+			t.Fatalf("owner of %v changed", key)
+		}
+		counts[owner]++
+	}
+	for _, server := range servers {
+		if counts[server] == 0 {
+			t.Fatalf("no checks for %s: %v", server, counts)
+		}
+	}
+	if len(counts) != len(servers) {
+		t.Fatalf("bad: %v", counts)
+	}
+}
+
+func TestExternalChecks_NodeProbe(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ExternalChecksEnabled = true
+		c.ExternalCheckSyncInterval = 100 * time.Millisecond
+		c.ExternalNodeProbeInterval = 50 * time.Millisecond
+		_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+		c.ExternalCheckAllowedCIDRs = []*net.IPNet{loopback}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer ln.Close()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	register := func(meta map[string]string) {
+		t.Helper()
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "ext1",
+			Address:    "127.0.0.1",
+			NodeMeta:   meta,
+		}
+		var out struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The probe is registered and passes while the port is open.
+	register(map[string]string{
+		externalNodeMetaKey:  "true",
+		externalProbeMetaKey: "tcp://:" + port,
+	})
+	state := s1.fsm.State()
+	retry.Run(t, func(r *retry.R) {
+		_, check, err := state.NodeCheck("ext1", structs.NodeProbeCheckID)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if check == nil || check.Status != api.HealthPassing || check.ServiceID != "" {
+			r.Fatalf("bad: %#v", check)
+		}
+	})
+
+	// It fails once the port is closed.
+	ln.Close()
+	retry.Run(t, func(r *retry.R) {
+		_, check, err := state.NodeCheck("ext1", structs.NodeProbeCheckID)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if check == nil || check.Status != api.HealthCritical {
+			r.Fatalf("bad: %#v", check)
+		}
+	})
+
+	// It's removed when the node stops asking for it.
+	register(map[string]string{externalNodeMetaKey: "true"})
+	retry.Run(t, func(r *retry.R) {
+		_, check, err := state.NodeCheck("ext1", structs.NodeProbeCheckID)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if check != nil {
+			r.Fatalf("bad: %#v", check)
+		}
+	})
+}
+
+func TestNodeProbeDefinition(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ExternalNodeProbeInterval = 7 * time.Second
+		c.ExternalNodeProbeTimeout = 3 * time.Second
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	node := &structs.Node{Node: "ext1", Address: "10.0.0.5"}
+	cases := map[string]structs.HealthCheckDefinition{
+		"tcp://:22":           {TCP: "10.0.0.5:22"},
+		"http://:8080/health": {HTTP: "http://10.0.0.5:8080/health"},
+		"https:///health":     {HTTP: "https://10.0.0.5/health"},
+		"http://:81":          {HTTP: "http://10.0.0.5:81"},
+	}
+	for target, expected := range cases {
+		expected.Interval = api.ReadableDuration(7 * time.Second)
+		expected.Timeout = api.ReadableDuration(3 * time.Second)
+		def, err := s1.nodeProbeDefinition(node, target)
+		if err != nil {
+			t.Fatalf("%s: err: %v", target, err)
+		}
+		if !reflect.DeepEqual(def, expected) {
+			t.Fatalf("%s: bad: %#v", target, def)
+		}
+	}
+
+	def, err := s1.nodeProbeDefinition(&structs.Node{Address: "::1"}, "tcp://:22")
+	if err != nil || def.TCP != "[::1]:22" {
+		t.Fatalf("bad: %#v %v", def, err)
+	}
+
+	// The target can't send the probe anywhere but the node's address.
+	for _, target := range []string{
+		"tcp://:", "udp://:53", "%zz",
+		"tcp://10.0.0.6:22",
+		"http://example.com:81/ready",
+		"http://169.254.169.254/latest/meta-data",
+		"http://user@:80/",
+		"http://:80/ready?x",
+		"http://:80/#frag",
+		"http://:99999/",
+		"tcp://:22/path",
+		"http:10.0.0.6",
+	} {
+		if _, err := s1.nodeProbeDefinition(node, target); err == nil {
+			t.Fatalf("%s: expected an error", target)
+		}
+	}
+}
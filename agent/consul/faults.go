@@ -0,0 +1,133 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// errFaultsUnavailable is returned when fault rules are changed on an agent
+// that doesn't inject faults.
+var errFaultsUnavailable = errors.New("Fault injection is only available on servers in dev mode")
+
+// faultInjector adds latency and errors to the RPCs a server handles, and to
+// its Raft applies, according to the rules set on it.
+type faultInjector struct {
+	rules []structs.FaultRule
+	lock  sync.RWMutex
+
+	// sleep and random are replaced by the tests.
+	sleep  func(time.Duration)
+	random func() float64
+}
+
+// newFaultInjector returns an injector with the given rules.
+func newFaultInjector(rules []structs.FaultRule) (*faultInjector, error) {
+	f := &faultInjector{
+		sleep:  time.Sleep,
+		random: rand.Float64,
+	}
+	if err := f.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Rules returns a copy of the injector's rules.
+func (f *faultInjector) Rules() []structs.FaultRule {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	rules := make([]structs.FaultRule, len(f.rules))
+	copy(rules, f.rules)
+	return rules
+}
+
+// SetRules replaces the injector's rules, unless any of them is invalid.
+func (f *faultInjector) SetRules(rules []structs.FaultRule) error {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("Invalid fault rule %d: %v", i, err)
+		}
+	}
+	copied := make([]structs.FaultRule, len(rules))
+	copy(copied, rules)
+
+	f.lock.Lock()
+	f.rules = copied
+	f.lock.Unlock()
+	return nil
+}
+
+// inject applies the first rule matching the given method, if any. It sleeps
+// for the rule's latency and then returns an error if the call should fail.
+// A nil injector never injects anything.
+func (f *faultInjector) inject(method string) error {
+	if f == nil {
+		return nil
+	}
+
+	f.lock.RLock()
+	var rule *structs.FaultRule
+	for i := range f.rules {
+		if f.rules[i].Matches(method) {
+			r := f.rules[i]
+			rule = &r
+			break
+		}
+	}
+	f.lock.RUnlock()
+	if rule == nil {
+		return nil
+	}
+
+	labels := []metrics.Label{{Name: "method", Value: method}}
+	if rule.Latency > 0 {
+		metrics.IncrCounterWithLabels([]string{"faults", "latency"}, 1, labels)
+		f.sleep(rule.Latency)
+	}
+	if rule.ErrorRate > 0 && f.random() < rule.ErrorRate {
+		metrics.IncrCounterWithLabels([]string{"faults", "error"}, 1, labels)
+		if rule.Error != "" {
+			return errors.New(rule.Error)
+		}
+		return fmt.Errorf("Injected fault for %s", method)
+	}
+	return nil
+}
+
+// FaultRules returns the fault injection rules of the client. Clients never
+// inject faults.
+func (c *Client) FaultRules() ([]structs.FaultRule, error) {
+	return nil, errFaultsUnavailable
+}
+
+// SetFaultRules always fails, since clients never inject faults.
+func (c *Client) SetFaultRules(rules []structs.FaultRule) error {
+	return errFaultsUnavailable
+}
+
+// FaultRules returns the fault injection rules of the server.
+func (s *Server) FaultRules() ([]structs.FaultRule, error) {
+	if s.faults == nil {
+		return nil, errFaultsUnavailable
+	}
+	return s.faults.Rules(), nil
+}
+
+// SetFaultRules replaces the fault injection rules of the server. Rules set
+// this way last until the server restarts or its config is reloaded.
+func (s *Server) SetFaultRules(rules []structs.FaultRule) error {
+	if s.faults == nil {
+		return errFaultsUnavailable
+	}
+	if err := s.faults.SetRules(rules); err != nil {
+		return err
+	}
+	s.logger.Printf("[WARN] consul: Set %d fault injection rules", len(rules))
+	return nil
+}
@@ -0,0 +1,83 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjector(t *testing.T) {
+	t.Parallel()
+
+	f, err := newFaultInjector([]structs.FaultRule{
+		{Method: "Catalog.Register", ErrorRate: 1, Error: "nope"},
+		{Method: "Catalog.*", Latency: 2 * time.Second},
+		{Method: structs.FaultRaftApply, ErrorRate: 0.5},
+	})
+	require.NoError(t, err)
+
+	var slept time.Duration
+	f.sleep = func(d time.Duration) { slept += d }
+	random := 0.7
+	f.random = func() float64 { return random }
+
+	// The first matching rule applies.
+	require.EqualError(t, f.inject("Catalog.Register"), "nope")
+	require.Equal(t, time.Duration(0), slept)
+
+	require.NoError(t, f.inject("Catalog.ListNodes"))
+	require.Equal(t, 2*time.Second, slept)
+
+	// Errors are injected at the rule's rate.
+	require.NoError(t, f.inject(structs.FaultRaftApply))
+	random = 0.2
+	require.EqualError(t, f.inject(structs.FaultRaftApply), "Injected fault for Raft.Apply")
+
+	// Other methods are left alone.
+	require.NoError(t, f.inject("Health.ServiceNodes"))
+	require.Equal(t, 2*time.Second, slept)
+
+	// Invalid rules are rejected without changing the current ones.
+	err = f.SetRules([]structs.FaultRule{{Method: "[", Latency: time.Second}})
+	require.Error(t, err)
+	require.Len(t, f.Rules(), 3)
+	err = f.SetRules([]structs.FaultRule{{Method: "*", ErrorRate: 2}})
+	require.Error(t, err)
+
+	require.NoError(t, f.SetRules(nil))
+	require.NoError(t, f.inject("Catalog.Register"))
+
+	// A nil injector never injects faults.
+	var none *faultInjector
+	require.NoError(t, none.inject("Catalog.Register"))
+}
+
+func TestServer_ReloadConfig_FaultInjection(t *testing.T) {
+	t.Parallel()
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.DevMode = true
+		c.FaultInjection = []structs.FaultRule{{Method: "Catalog.*", Latency: time.Second}}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	rules, err := s1.FaultRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	// Reloading replaces the rules, including ones set through the API.
+	require.NoError(t, s1.SetFaultRules(nil))
+	conf := DefaultConfig()
+	conf.FaultInjection = []structs.FaultRule{
+		{Method: "KVS.Apply", ErrorRate: 1},
+		{Method: "KVS.Get", Latency: time.Second},
+	}
+	require.NoError(t, s1.ReloadConfig(conf))
+	rules, err = s1.FaultRules()
+	require.NoError(t, err)
+	require.Equal(t, conf.FaultInjection, rules)
+}
@@ -0,0 +1,42 @@
+package consul
+
+// FlushedCaches reports what was dropped when a client or server flushed its
+// caches.
+type FlushedCaches struct {
+	// ConnPoolConns is how many pooled RPC connections were closed.
+	ConnPoolConns int
+
+	// CoordinateUpdates is how many pending coordinate updates were
+	// applied. Only the leader batches coordinate updates.
+	CoordinateUpdates int
+}
+
+// FlushCaches drops the client's resolved ACLs and pooled RPC connections, so
+// changes to policies or servers take effect straight away.
+func (c *Client) FlushCaches() (*FlushedCaches, error) {
+	c.acls.cache.Purge()
+	flushed := &FlushedCaches{
+		ConnPoolConns: c.connPool.Flush(),
+	}
+	c.logger.Printf("[INFO] consul: Flushed ACL cache and %d pooled connections", flushed.ConnPoolConns)
+	return flushed, nil
+}
+
+// FlushCaches drops the server's resolved ACLs and pooled RPC connections,
+// and applies the pending coordinate updates instead of waiting for the next
+// batch.
+func (s *Server) FlushCaches() (*FlushedCaches, error) {
+	s.acls.cache.Purge()
+	flushed := &FlushedCaches{
+		ConnPoolConns: s.connPool.Flush(),
+	}
+
+	n, err := s.coordinate.batchApplyUpdates()
+	flushed.CoordinateUpdates = n
+	if err != nil {
+		return flushed, err
+	}
+	s.logger.Printf("[INFO] consul: Flushed ACL cache, %d pooled connections and %d coordinate updates",
+		flushed.ConnPoolConns, flushed.CoordinateUpdates)
+	return flushed, nil
+}
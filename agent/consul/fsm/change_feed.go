@@ -0,0 +1,217 @@
+package fsm
+
+import (
+	"sort"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+)
+
+// changeFeedEnabled returns true if catalog and KV changes are being recorded
+// in the change feed. The limit is written through Raft, so every server
+// agrees on it.
+func (c *FSM) changeFeedEnabled() bool {
+	return c.state.ChangeFeedMaxEntries() > 0
+}
+
+// recordChanges appends the changes made by the log at the given index to the
+// change feed, if it's enabled. Failures are logged rather than returned, since
+// the write itself has already been applied.
+func (c *FSM) recordChanges(index uint64, changes ...*structs.Change) {
+	maxEntries := c.state.ChangeFeedMaxEntries()
+	if maxEntries <= 0 {
+		return
+	}
+
+	var filtered []*structs.Change
+	for _, change := range changes {
+		if change != nil {
+			filtered = append(filtered, change)
+		}
+	}
+	if len(filtered) == 0 {
+		return
+	}
+
+	if err := c.state.ChangeFeedAppend(index, filtered, maxEntries); err != nil {
+		c.logger.Printf("[ERR] consul.fsm: Failed to record changes at index %d: %v", index, err)
+	}
+}
+
+// lockedKeys returns the keys held by sessions before a write that can
+// invalidate sessions, so the keys they release or delete can be recorded
+// afterwards by sessionChanges. It returns nil if the change feed is
+// disabled.
+func (c *FSM) lockedKeys() map[string]string {
+	if !c.changeFeedEnabled() {
+		return nil
+	}
+	locked, err := c.state.KVSLocked()
+	if err != nil {
+		c.logger.Printf("[ERR] consul.fsm: Failed to read locked keys for the change feed: %v", err)
+		return nil
+	}
+	return locked
+}
+
+// sessionChanges appends the changes to the keys in locked that were released
+// or deleted by invalidated sessions, skipping keys the given changes already
+// cover.
+func (c *FSM) sessionChanges(locked map[string]string, changes []*structs.Change) []*structs.Change {
+	if len(locked) == 0 {
+		return changes
+	}
+
+	seen := make(map[string]struct{})
+	for _, change := range changes {
+		if change != nil && change.Type == structs.ChangeKV {
+			seen[change.Key] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(locked))
+	for key := range locked {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		_, stored, err := c.state.KVSGet(nil, key)
+		if err != nil {
+			c.logger.Printf("[ERR] consul.fsm: Failed to read back key %q for the change feed: %v", key, err)
+			continue
+		}
+		switch {
+		case stored == nil:
+			changes = append(changes, &structs.Change{
+				Type: structs.ChangeKV,
+				Op:   structs.ChangeDelete,
+				Key:  key,
+			})
+		case stored.Session != locked[key]:
+			changes = append(changes, &structs.Change{
+				Type: structs.ChangeKV,
+				Op:   structs.ChangeUpsert,
+				Key:  key,
+				KV:   stored,
+			})
+		}
+	}
+	return changes
+}
+
+// applied returns true if the response from applying a command means the write
+// happened. Conditional writes return false when they aren't applied.
+func applied(resp interface{}) bool {
+	switch v := resp.(type) {
+	case nil:
+		return true
+	case bool:
+		return v
+	default:
+		return false
+	}
+}
+
+// kvsChange returns the change made by a KV operation, or nil for operations
+// that don't write.
+func (c *FSM) kvsChange(op api.KVOp, ent *structs.DirEntry) *structs.Change {
+	switch op {
+	case api.KVSet, api.KVCAS, api.KVLock, api.KVUnlock:
+		_, stored, err := c.state.KVSGet(nil, ent.Key)
+		if err != nil {
+			c.logger.Printf("[ERR] consul.fsm: Failed to read back key %q for the change feed: %v", ent.Key, err)
+			return nil
+		}
+		return &structs.Change{
+			Type: structs.ChangeKV,
+			Op:   structs.ChangeUpsert,
+			Key:  ent.Key,
+			KV:   stored,
+		}
+
+	case api.KVDelete, api.KVDeleteCAS:
+		return &structs.Change{
+			Type: structs.ChangeKV,
+			Op:   structs.ChangeDelete,
+			Key:  ent.Key,
+		}
+
+	case api.KVDeleteTree:
+		return &structs.Change{
+			Type: structs.ChangeKV,
+			Op:   structs.ChangeDeleteTree,
+			Key:  ent.Key,
+		}
+
+	default:
+		return nil
+	}
+}
+
+// registerChanges returns the changes made by a catalog registration.
+func registerChanges(req *structs.RegisterRequest) []*structs.Change {
+	var changes []*structs.Change
+	if !req.SkipNodeUpdate {
+		changes = append(changes, &structs.Change{
+			Type: structs.ChangeNode,
+			Op:   structs.ChangeUpsert,
+			Node: req.Node,
+			NodeEntry: &structs.Node{
+				ID:              req.ID,
+				Node:            req.Node,
+				Address:         req.Address,
+				Datacenter:      req.Datacenter,
+				TaggedAddresses: req.TaggedAddresses,
+				Meta:            req.NodeMeta,
+			},
+		})
+	}
+	if req.Service != nil {
+		changes = append(changes, &structs.Change{
+			Type:      structs.ChangeService,
+			Op:        structs.ChangeUpsert,
+			Node:      req.Node,
+			ServiceID: req.Service.ID,
+			Service:   req.Service,
+		})
+	}
+	checks := req.Checks
+	if req.Check != nil {
+		checks = append(structs.HealthChecks{req.Check}, checks...)
+	}
+	for _, check := range checks {
+		changes = append(changes, &structs.Change{
+			Type:      structs.ChangeCheck,
+			Op:        structs.ChangeUpsert,
+			Node:      req.Node,
+			ServiceID: check.ServiceID,
+			CheckID:   check.CheckID,
+			Check:     check,
+		})
+	}
+	return changes
+}
+
+// deregisterChange returns the change made by a catalog deregistration. The
+// precedence matches applyDeregister.
+func deregisterChange(req *structs.DeregisterRequest) *structs.Change {
+	change := &structs.Change{
+		Op:   structs.ChangeDelete,
+		Node: req.Node,
+	}
+	switch {
+	case req.ServiceID != "":
+		change.Type = structs.ChangeService
+		change.ServiceID = req.ServiceID
+	case req.CheckID != "":
+		change.Type = structs.ChangeCheck
+		change.CheckID = req.CheckID
+	default:
+		change.Type = structs.ChangeNode
+	}
+	return change
+}
@@ -0,0 +1,234 @@
+package fsm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/types"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSM_ChangeFeed(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+	require.NoError(fsm.State().ChangeFeedSetMaxEntries(0, 10))
+
+	var index uint64
+	apply := func(t structs.MessageType, msg interface{}) interface{} {
+		buf, err := structs.Encode(t, msg)
+		require.NoError(err)
+		index++
+		return fsm.Apply(&raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: buf})
+	}
+
+	// A registration records the node, service and check.
+	apply(structs.RegisterRequestType, &structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service:    &structs.NodeService{ID: "db", Service: "db"},
+		Check:      &structs.HealthCheck{Node: "foo", CheckID: "db", ServiceID: "db", ServiceName: "db"},
+	})
+
+	// KV writes record the stored entry, and failed CAS writes aren't
+	// recorded at all.
+	apply(structs.KVSRequestType, &structs.KVSRequest{
+		Op:     api.KVSet,
+		DirEnt: structs.DirEntry{Key: "foo/bar", Value: []byte("baz")},
+	})
+	require.Equal(false, apply(structs.KVSRequestType, &structs.KVSRequest{
+		Op:     api.KVCAS,
+		DirEnt: structs.DirEntry{Key: "foo/bar", Value: []byte("nope"), RaftIndex: structs.RaftIndex{ModifyIndex: 99}},
+	}))
+
+	// Transactions record their writes but not their reads.
+	apply(structs.TxnRequestType, &structs.TxnRequest{
+		Ops: structs.TxnOps{
+			{KV: &structs.TxnKVOp{Verb: api.KVGet, DirEnt: structs.DirEntry{Key: "foo/bar"}}},
+			{KV: &structs.TxnKVOp{Verb: api.KVDeleteTree, DirEnt: structs.DirEntry{Key: "foo/"}}},
+		},
+	})
+	apply(structs.DeregisterRequestType, &structs.DeregisterRequest{Node: "foo"})
+
+	_, _, entries, _, err := fsm.State().ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Len(entries, 4)
+
+	require.Equal(uint64(1), entries[0].Index)
+	require.Len(entries[0].Changes, 3)
+	require.Equal(structs.ChangeNode, entries[0].Changes[0].Type)
+	require.Equal("127.0.0.1", entries[0].Changes[0].NodeEntry.Address)
+	require.Equal(structs.ChangeService, entries[0].Changes[1].Type)
+	require.Equal("db", entries[0].Changes[1].ServiceID)
+	require.Equal(structs.ChangeCheck, entries[0].Changes[2].Type)
+
+	require.Equal(uint64(2), entries[1].Index)
+	require.Equal(structs.ChangeUpsert, entries[1].Changes[0].Op)
+	require.Equal([]byte("baz"), entries[1].Changes[0].KV.Value)
+	require.Equal(uint64(2), entries[1].Changes[0].KV.ModifyIndex)
+
+	require.Equal(uint64(4), entries[2].Index)
+	require.Len(entries[2].Changes, 1)
+	require.Equal(structs.ChangeDeleteTree, entries[2].Changes[0].Op)
+	require.Equal("foo/", entries[2].Changes[0].Key)
+
+	require.Equal(uint64(5), entries[3].Index)
+	require.Equal(&structs.Change{Type: structs.ChangeNode, Op: structs.ChangeDelete, Node: "foo"}, entries[3].Changes[0])
+}
+
+func TestFSM_ChangeFeed_SessionInvalidation(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+	require.NoError(fsm.State().ChangeFeedSetMaxEntries(0, 10))
+
+	var index uint64
+	apply := func(t structs.MessageType, msg interface{}) interface{} {
+		buf, err := structs.Encode(t, msg)
+		require.NoError(err)
+		index++
+		return fsm.Apply(&raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: buf})
+	}
+	lastEntry := func() *structs.ChangeFeedEntry {
+		_, _, entries, _, err := fsm.State().ChangeFeedList(nil, 0, 0)
+		require.NoError(err)
+		require.NotEmpty(entries)
+		return entries[len(entries)-1]
+	}
+
+	apply(structs.RegisterRequestType, &structs.RegisterRequest{
+		Node:    "foo",
+		Address: "127.0.0.1",
+		Check:   &structs.HealthCheck{Node: "foo", CheckID: "web", Status: api.HealthPassing},
+	})
+	session := func(behavior structs.SessionBehavior) string {
+		resp := apply(structs.SessionRequestType, &structs.SessionRequest{
+			Op: structs.SessionCreate,
+			Session: structs.Session{
+				ID:       generateUUID(),
+				Node:     "foo",
+				Checks:   []types.CheckID{"web"},
+				Behavior: behavior,
+			},
+		})
+		return resp.(string)
+	}
+	lock := func(key, id string) {
+		require.Equal(true, apply(structs.KVSRequestType, &structs.KVSRequest{
+			Op:     api.KVLock,
+			DirEnt: structs.DirEntry{Key: key, Session: id},
+		}))
+	}
+
+	// A critical check invalidates its sessions, which releases or deletes
+	// their keys along with the registration.
+	lock("release", session(structs.SessionKeysRelease))
+	lock("delete", session(structs.SessionKeysDelete))
+	apply(structs.RegisterRequestType, &structs.RegisterRequest{
+		Node:           "foo",
+		SkipNodeUpdate: true,
+		Check:          &structs.HealthCheck{Node: "foo", CheckID: "web", Status: api.HealthCritical},
+	})
+	entry := lastEntry()
+	require.Equal(index, entry.Index)
+	require.Len(entry.Changes, 3)
+	require.Equal(structs.ChangeCheck, entry.Changes[0].Type)
+	require.Equal(&structs.Change{Type: structs.ChangeKV, Op: structs.ChangeDelete, Key: "delete"}, entry.Changes[1])
+	require.Equal(structs.ChangeUpsert, entry.Changes[2].Op)
+	require.Equal("release", entry.Changes[2].Key)
+	require.Equal("", entry.Changes[2].KV.Session)
+
+	// Destroying a session releases its keys.
+	apply(structs.RegisterRequestType, &structs.RegisterRequest{
+		Node:           "foo",
+		SkipNodeUpdate: true,
+		Check:          &structs.HealthCheck{Node: "foo", CheckID: "web", Status: api.HealthPassing},
+	})
+	id := session(structs.SessionKeysRelease)
+	lock("release", id)
+	apply(structs.SessionRequestType, &structs.SessionRequest{
+		Op:      structs.SessionDestroy,
+		Session: structs.Session{ID: id},
+	})
+	entry = lastEntry()
+	require.Equal(index, entry.Index)
+	require.Len(entry.Changes, 1)
+	require.Equal("release", entry.Changes[0].Key)
+	require.Equal("", entry.Changes[0].KV.Session)
+
+	// Deregistering the node invalidates its sessions too.
+	lock("release", session(structs.SessionKeysRelease))
+	apply(structs.DeregisterRequestType, &structs.DeregisterRequest{Node: "foo"})
+	entry = lastEntry()
+	require.Equal(index, entry.Index)
+	require.Len(entry.Changes, 2)
+	require.Equal(structs.ChangeNode, entry.Changes[0].Type)
+	require.Equal("release", entry.Changes[1].Key)
+	require.Equal("", entry.Changes[1].KV.Session)
+}
+
+func TestFSM_ChangeFeed_Config(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+
+	var index uint64
+	apply := func(t structs.MessageType, msg interface{}) interface{} {
+		buf, err := structs.Encode(t, msg)
+		require.NoError(err)
+		index++
+		return fsm.Apply(&raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: buf})
+	}
+	setKey := func() {
+		apply(structs.KVSRequestType, &structs.KVSRequest{
+			Op:     api.KVSet,
+			DirEnt: structs.DirEntry{Key: "foo", Value: []byte("bar")},
+		})
+	}
+
+	// Enabling the feed marks everything before it as trimmed, since those
+	// writes weren't recorded.
+	setKey()
+	require.Nil(apply(structs.ChangeFeedConfigRequestType, &structs.ChangeFeedConfigRequest{MaxEntries: 2}))
+	require.Equal(2, fsm.State().ChangeFeedMaxEntries())
+	setKey()
+	setKey()
+	setKey()
+	_, trimmed, entries, _, err := fsm.State().ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Len(entries, 2)
+	require.Equal(uint64(4), entries[0].Index)
+	require.Equal(uint64(3), trimmed)
+
+	// Disabling it drops the entries.
+	require.Nil(apply(structs.ChangeFeedConfigRequestType, &structs.ChangeFeedConfigRequest{MaxEntries: 0}))
+	require.Equal(0, fsm.State().ChangeFeedMaxEntries())
+	setKey()
+	_, _, entries, _, err = fsm.State().ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Empty(entries)
+}
+
+func TestFSM_ChangeFeed_Disabled(t *testing.T) {
+	t.Parallel()
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(t, err)
+
+	buf, err := structs.Encode(structs.KVSRequestType, &structs.KVSRequest{
+		Op:     api.KVSet,
+		DirEnt: structs.DirEntry{Key: "foo", Value: []byte("bar")},
+	})
+	require.NoError(t, err)
+	require.Nil(t, fsm.Apply(makeLog(buf)))
+
+	_, _, entries, _, err := fsm.State().ChangeFeedList(nil, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -29,6 +29,14 @@ func init() {
 	registerCommand(structs.ACLPolicySetRequestType, (*FSM).applyACLPolicySetOperation)
 	registerCommand(structs.ACLPolicyDeleteRequestType, (*FSM).applyACLPolicyDeleteOperation)
 	registerCommand(structs.ConnectCALeafRequestType, (*FSM).applyConnectCALeafOperation)
+	registerCommand(structs.NamespaceRequestType, (*FSM).applyNamespaceOperation)
+	registerCommand(structs.ServiceFailoverRequestType, (*FSM).applyServiceFailoverOperation)
+	registerCommand(structs.ConfigEntryRequestType, (*FSM).applyConfigEntryOperation)
+	registerCommand(structs.ResponseSigningKeyType, (*FSM).applyResponseSigningKey)
+	registerCommand(structs.LeaderTransitionType, (*FSM).applyLeaderTransition)
+	registerCommand(structs.ACLTokenUsageRequestType, (*FSM).applyACLTokenUsage)
+	registerCommand(structs.ChangeFeedConfigRequestType, (*FSM).applyChangeFeedConfig)
+	registerCommand(structs.BootstrapDataRequestType, (*FSM).applyBootstrapData)
 }
 
 func (c *FSM) applyRegister(buf []byte, index uint64) interface{} {
@@ -38,11 +46,30 @@ func (c *FSM) applyRegister(buf []byte, index uint64) interface{} {
 		panic(fmt.Errorf("failed to decode request: %v", err))
 	}
 
+	// Critical checks invalidate their sessions, which releases or
+	// deletes the keys they hold.
+	locked := c.lockedKeys()
+
+	// Check-and-set registrations return whether they were applied, like
+	// the KV CAS operations do.
+	if req.CAS {
+		act, err := c.state.EnsureRegistrationCAS(index, req.ModifyIndex, &req)
+		if err != nil {
+			c.logger.Printf("[WARN] consul.fsm: EnsureRegistrationCAS failed: %v", err)
+			return err
+		}
+		if act {
+			c.recordChanges(index, c.sessionChanges(locked, registerChanges(&req))...)
+		}
+		return act
+	}
+
 	// Apply all updates in a single transaction
 	if err := c.state.EnsureRegistration(index, &req); err != nil {
 		c.logger.Printf("[WARN] consul.fsm: EnsureRegistration failed: %v", err)
 		return err
 	}
+	c.recordChanges(index, c.sessionChanges(locked, registerChanges(&req))...)
 	return nil
 }
 
@@ -56,6 +83,27 @@ func (c *FSM) applyDeregister(buf []byte, index uint64) interface{} {
 	// Either remove the service entry or the whole node. The precedence
 	// here is also baked into vetDeregisterWithACL() in acl.go, so if you
 	// make changes here, be sure to also adjust the code over there.
+	locked := c.lockedKeys()
+	if req.CAS {
+		var act bool
+		var err error
+		if req.ServiceID != "" {
+			act, err = c.state.DeleteServiceCAS(index, req.ModifyIndex, req.Node, req.ServiceID)
+		} else if req.CheckID != "" {
+			act, err = c.state.DeleteCheckCAS(index, req.ModifyIndex, req.Node, req.CheckID)
+		} else {
+			act, err = c.state.DeleteNodeCAS(index, req.ModifyIndex, req.Node)
+		}
+		if err != nil {
+			c.logger.Printf("[WARN] consul.fsm: Deregister CAS failed: %v", err)
+			return err
+		}
+		if act {
+			c.recordChanges(index, c.sessionChanges(locked, []*structs.Change{deregisterChange(&req)})...)
+		}
+		return act
+	}
+
 	if req.ServiceID != "" {
 		if err := c.state.DeleteService(index, req.Node, req.ServiceID); err != nil {
 			c.logger.Printf("[WARN] consul.fsm: DeleteNodeService failed: %v", err)
@@ -72,6 +120,7 @@ func (c *FSM) applyDeregister(buf []byte, index uint64) interface{} {
 			return err
 		}
 	}
+	c.recordChanges(index, c.sessionChanges(locked, []*structs.Change{deregisterChange(&req)})...)
 	return nil
 }
 
@@ -82,6 +131,16 @@ func (c *FSM) applyKVSOperation(buf []byte, index uint64) interface{} {
 	}
 	defer metrics.MeasureSinceWithLabels([]string{"fsm", "kvs"}, time.Now(),
 		[]metrics.Label{{Name: "op", Value: string(req.Op)}})
+
+	resp := c.applyKVSRequest(index, &req)
+	if c.changeFeedEnabled() && applied(resp) {
+		c.recordChanges(index, c.kvsChange(req.Op, &req.DirEnt))
+	}
+	return resp
+}
+
+// applyKVSRequest applies a KV operation to the state store.
+func (c *FSM) applyKVSRequest(index uint64, req *structs.KVSRequest) interface{} {
 	switch req.Op {
 	case api.KVSet:
 		return c.state.KVSSet(index, &req.DirEnt)
@@ -134,7 +193,12 @@ func (c *FSM) applySessionOperation(buf []byte, index uint64) interface{} {
 		}
 		return req.Session.ID
 	case structs.SessionDestroy:
-		return c.state.SessionDestroy(index, req.Session.ID)
+		locked := c.lockedKeys()
+		if err := c.state.SessionDestroy(index, req.Session.ID); err != nil {
+			return err
+		}
+		c.recordChanges(index, c.sessionChanges(locked, nil)...)
+		return nil
 	default:
 		c.logger.Printf("[WARN] consul.fsm: Invalid Session operation '%s'", req.Op)
 		return fmt.Errorf("Invalid Session operation '%s'", req.Op)
@@ -247,6 +311,15 @@ func (c *FSM) applyTxn(buf []byte, index uint64) interface{} {
 	}
 	defer metrics.MeasureSince([]string{"fsm", "txn"}, time.Now())
 	results, errors := c.state.TxnRW(index, req.Ops)
+	if c.changeFeedEnabled() && len(errors) == 0 {
+		var changes []*structs.Change
+		for _, op := range req.Ops {
+			if op.KV != nil {
+				changes = append(changes, c.kvsChange(op.KV.Verb, &op.KV.DirEnt))
+			}
+		}
+		c.recordChanges(index, changes...)
+	}
 	return structs.TxnResponse{
 		Results: results,
 		Errors:  errors,
@@ -292,6 +365,70 @@ func (c *FSM) applyIntentionOperation(buf []byte, index uint64) interface{} {
 	}
 }
 
+// applyNamespaceOperation applies the given namespace operation to the state
+// store.
+func (c *FSM) applyNamespaceOperation(buf []byte, index uint64) interface{} {
+	var req structs.NamespaceRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	defer metrics.MeasureSinceWithLabels([]string{"fsm", "namespace"}, time.Now(),
+		[]metrics.Label{{Name: "op", Value: string(req.Op)}})
+	switch req.Op {
+	case structs.NamespaceOpUpsert:
+		return c.state.NamespaceSet(index, req.Namespace)
+	case structs.NamespaceOpDelete:
+		return c.state.NamespaceDelete(index, req.Namespace.Name)
+	default:
+		c.logger.Printf("[WARN] consul.fsm: Invalid Namespace operation '%s'", req.Op)
+		return fmt.Errorf("Invalid Namespace operation '%s'", req.Op)
+	}
+}
+
+// applyServiceFailoverOperation applies the given service failover policy
+// operation to the state store.
+func (c *FSM) applyServiceFailoverOperation(buf []byte, index uint64) interface{} {
+	var req structs.ServiceFailoverRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	defer metrics.MeasureSinceWithLabels([]string{"fsm", "service_failover"}, time.Now(),
+		[]metrics.Label{{Name: "op", Value: string(req.Op)}})
+	switch req.Op {
+	case structs.ServiceFailoverOpUpsert:
+		return c.state.ServiceFailoverSet(index, req.Failover)
+	case structs.ServiceFailoverOpDelete:
+		return c.state.ServiceFailoverDelete(index, req.Failover.Service)
+	default:
+		c.logger.Printf("[WARN] consul.fsm: Invalid ServiceFailover operation '%s'", req.Op)
+		return fmt.Errorf("Invalid ServiceFailover operation '%s'", req.Op)
+	}
+}
+
+// applyConfigEntryOperation applies the given config entry operation to the
+// state store.
+func (c *FSM) applyConfigEntryOperation(buf []byte, index uint64) interface{} {
+	var req structs.ConfigEntryRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	defer metrics.MeasureSinceWithLabels([]string{"fsm", "config_entry"}, time.Now(),
+		[]metrics.Label{{Name: "op", Value: string(req.Op)}})
+	switch req.Op {
+	case structs.ConfigEntryOpUpsert:
+		req.Entry.NormalizeConfig()
+		return c.state.ConfigEntrySet(index, req.Entry)
+	case structs.ConfigEntryOpDelete:
+		return c.state.ConfigEntryDelete(index, req.Entry.Kind, req.Entry.Name)
+	default:
+		c.logger.Printf("[WARN] consul.fsm: Invalid ConfigEntry operation '%s'", req.Op)
+		return fmt.Errorf("Invalid ConfigEntry operation '%s'", req.Op)
+	}
+}
+
 // applyConnectCAOperation applies the given CA operation to the state store.
 func (c *FSM) applyConnectCAOperation(buf []byte, index uint64) interface{} {
 	var req structs.CARequest
@@ -428,3 +565,66 @@ func (c *FSM) applyACLPolicyDeleteOperation(buf []byte, index uint64) interface{
 
 	return c.state.ACLPolicyBatchDelete(index, req.PolicyIDs)
 }
+
+// applyResponseSigningKey sets the response signing key, unless there's one
+// already. It returns whether the key was set.
+func (c *FSM) applyResponseSigningKey(buf []byte, index uint64) interface{} {
+	var req structs.ResponseSigningKeyRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSince([]string{"fsm", "response_signing_key"}, time.Now())
+
+	set, err := c.state.ResponseSigningKeySet(index, req.Key)
+	if err != nil {
+		return err
+	}
+	return set
+}
+
+// applyLeaderTransition records a leader transition, trimming the history to
+// the size given in the request.
+func (c *FSM) applyLeaderTransition(buf []byte, index uint64) interface{} {
+	var req structs.LeaderTransitionRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSince([]string{"fsm", "leader_transition"}, time.Now())
+
+	return c.state.LeaderTransitionAppend(index, req.Transition, req.MaxEntries)
+}
+
+// applyACLTokenUsage records when tokens were last used.
+func (c *FSM) applyACLTokenUsage(buf []byte, index uint64) interface{} {
+	var req structs.ACLTokenUsageRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSinceWithLabels([]string{"fsm", "acl", "token"}, time.Now(),
+		[]metrics.Label{{Name: "op", Value: "usage"}})
+
+	return c.state.ACLTokenUsageSet(index, req.Usage)
+}
+
+// applyChangeFeedConfig sets how many entries are kept in the change feed.
+func (c *FSM) applyChangeFeedConfig(buf []byte, index uint64) interface{} {
+	var req structs.ChangeFeedConfigRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSince([]string{"fsm", "change_feed", "config"}, time.Now())
+
+	return c.state.ChangeFeedSetMaxEntries(index, req.MaxEntries)
+}
+
+// applyBootstrapData records that a part of the bootstrap data directory was
+// loaded.
+func (c *FSM) applyBootstrapData(buf []byte, index uint64) interface{} {
+	var req structs.BootstrapDataRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSince([]string{"fsm", "bootstrap_data"}, time.Now())
+
+	return c.state.BootstrapDataSetLoaded(index, req.Part)
+}
@@ -1,6 +1,7 @@
 package fsm
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/pascaldekloe/goe/verify"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func generateUUID() (ret string) {
@@ -1219,6 +1221,161 @@ func TestFSM_Intention_CRUD(t *testing.T) {
 	}
 }
 
+func TestFSM_Namespace_CRUD(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	fsm, err := New(nil, os.Stderr)
+	assert.Nil(err)
+
+	// Create a new namespace.
+	req := structs.NamespaceRequest{
+		Datacenter: "dc1",
+		Op:         structs.NamespaceOpUpsert,
+		Namespace:  &structs.Namespace{Name: "eng", Description: "Engineering"},
+	}
+	buf, err := structs.Encode(structs.NamespaceRequestType, req)
+	assert.Nil(err)
+	assert.Nil(fsm.Apply(makeLog(buf)))
+
+	_, ns, err := fsm.state.NamespaceGet(nil, "eng")
+	assert.Nil(err)
+	assert.NotNil(ns)
+	assert.Equal("Engineering", ns.Description)
+
+	// Delete it.
+	req.Op = structs.NamespaceOpDelete
+	buf, err = structs.Encode(structs.NamespaceRequestType, req)
+	assert.Nil(err)
+	assert.Nil(fsm.Apply(makeLog(buf)))
+
+	_, ns, err = fsm.state.NamespaceGet(nil, "eng")
+	assert.Nil(err)
+	assert.Nil(ns)
+}
+
+func TestFSM_ServiceFailover_CRUD(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	fsm, err := New(nil, os.Stderr)
+	assert.Nil(err)
+
+	// Create a new policy.
+	req := structs.ServiceFailoverRequest{
+		Datacenter: "dc1",
+		Op:         structs.ServiceFailoverOpUpsert,
+		Failover: &structs.ServiceFailover{
+			Service:  "web",
+			Failover: structs.QueryDatacenterOptions{Datacenters: []string{"dc2"}},
+		},
+	}
+	buf, err := structs.Encode(structs.ServiceFailoverRequestType, req)
+	assert.Nil(err)
+	assert.Nil(fsm.Apply(makeLog(buf)))
+
+	_, f, err := fsm.state.ServiceFailoverGet(nil, "web")
+	assert.Nil(err)
+	assert.NotNil(f)
+	assert.Equal([]string{"dc2"}, f.Failover.Datacenters)
+
+	// Delete it.
+	req.Op = structs.ServiceFailoverOpDelete
+	buf, err = structs.Encode(structs.ServiceFailoverRequestType, req)
+	assert.Nil(err)
+	assert.Nil(fsm.Apply(makeLog(buf)))
+
+	_, f, err = fsm.state.ServiceFailoverGet(nil, "web")
+	assert.Nil(err)
+	assert.Nil(f)
+}
+
+func TestFSM_ConfigEntry_CRUD(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	fsm, err := New(nil, os.Stderr)
+	assert.Nil(err)
+
+	// Create a new entry.
+	req := structs.ConfigEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ConfigEntryOpUpsert,
+		Entry: &structs.ConfigEntry{
+			Kind:   structs.ServiceDefaults,
+			Name:   "web",
+			Config: map[string]interface{}{"protocol": "http"},
+		},
+	}
+	buf, err := structs.Encode(structs.ConfigEntryRequestType, req)
+	assert.Nil(err)
+	assert.Nil(fsm.Apply(makeLog(buf)))
+
+	_, e, err := fsm.state.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	assert.Nil(err)
+	assert.NotNil(e)
+	assert.Contains(e.Config, "protocol")
+
+	// Delete it.
+	req.Op = structs.ConfigEntryOpDelete
+	buf, err = structs.Encode(structs.ConfigEntryRequestType, req)
+	assert.Nil(err)
+	assert.Nil(fsm.Apply(makeLog(buf)))
+
+	_, e, err = fsm.state.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	assert.Nil(err)
+	assert.Nil(e)
+}
+
+func TestFSM_ConfigEntry_RoundTrip(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+
+	config := map[string]interface{}{
+		"protocol": "http",
+		"weight":   1.5,
+		"tags":     []interface{}{"a", "b"},
+		"upstream": map[string]interface{}{
+			"name":    "db",
+			"enabled": true,
+		},
+	}
+	req := structs.ConfigEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ConfigEntryOpUpsert,
+		Entry: &structs.ConfigEntry{
+			Kind:   structs.ServiceDefaults,
+			Name:   "web",
+			Config: config,
+		},
+	}
+	buf, err := structs.Encode(structs.ConfigEntryRequestType, req)
+	require.NoError(err)
+	require.Nil(fsm.Apply(makeLog(buf)))
+
+	// The strings come back as strings rather than []uint8, however deep
+	// they are.
+	_, e, err := fsm.state.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	require.NoError(err)
+	require.NotNil(e)
+	require.Equal(config, e.Config)
+
+	// And they stay that way through a snapshot.
+	snap, err := fsm.Snapshot()
+	require.NoError(err)
+	defer snap.Release()
+	sink := &MockSink{bytes.NewBuffer(nil), false}
+	require.NoError(snap.Persist(sink))
+	fsm2, err := New(nil, os.Stderr)
+	require.NoError(err)
+	require.NoError(fsm2.Restore(sink))
+	_, e, err = fsm2.state.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	require.NoError(err)
+	require.Equal(config, e.Config)
+}
+
 func TestFSM_CAConfig(t *testing.T) {
 	t.Parallel()
 
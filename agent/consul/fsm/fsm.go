@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -60,6 +61,41 @@ type FSM struct {
 	state     *state.Store
 
 	gc *state.TombstoneGC
+
+	// snapshotTypeCheck reports whether every server can restore records of
+	// a given snapshot-only type. It's set by SetSnapshotTypeCheck and
+	// guarded by snapshotTypeLock, since snapshots are taken concurrently.
+	snapshotTypeCheck func(structs.MessageType) bool
+	snapshotTypeLock  sync.RWMutex
+}
+
+// SetSnapshotTypeCheck sets the function snapshots use to decide whether to
+// write records of the types older servers can't restore. Until it's set
+// those records are left out.
+func (c *FSM) SetSnapshotTypeCheck(fn func(structs.MessageType) bool) {
+	c.snapshotTypeLock.Lock()
+	defer c.snapshotTypeLock.Unlock()
+	c.snapshotTypeCheck = fn
+}
+
+// MessageTypes returns the message types the FSM can apply or restore from a
+// snapshot, sorted.
+func MessageTypes() []structs.MessageType {
+	seen := make(map[structs.MessageType]struct{})
+	for msg := range commands {
+		seen[msg] = struct{}{}
+	}
+	for msg := range restorers {
+		seen[msg] = struct{}{}
+	}
+	seen[structs.SnapshotChecksumType] = struct{}{}
+
+	out := make([]structs.MessageType, 0, len(seen))
+	for msg := range seen {
+		out = append(out, msg)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
 }
 
 // New is used to construct a new FSM with a blank state.
@@ -127,7 +163,11 @@ func (c *FSM) Snapshot() (raft.FSMSnapshot, error) {
 		c.logger.Printf("[INFO] consul.fsm: snapshot created in %v", time.Since(start))
 	}(time.Now())
 
-	return &snapshot{c.state.Snapshot()}, nil
+	c.snapshotTypeLock.RLock()
+	check := c.snapshotTypeCheck
+	c.snapshotTypeLock.RUnlock()
+
+	return &snapshot{state: c.state.Snapshot(), logger: c.logger, typeCheck: check}, nil
 }
 
 // Restore streams in the snapshot and replaces the current state store with a
@@ -145,8 +185,11 @@ func (c *FSM) Restore(old io.ReadCloser) error {
 	restore := stateNew.Restore()
 	defer restore.Abort()
 
-	// Create a decoder
-	dec := codec.NewDecoder(old, msgpackHandle)
+	// Create a decoder. Everything is read through the checksum so we can
+	// verify it against the one at the end of the snapshot. Snapshots from
+	// older versions don't have one.
+	r := newChecksumReader(old)
+	dec := codec.NewDecoder(r, msgpackHandle)
 
 	// Read in the header
 	var header snapshotHeader
@@ -156,18 +199,29 @@ func (c *FSM) Restore(old io.ReadCloser) error {
 
 	// Populate the new state
 	msgType := make([]byte, 1)
+	verified := false
 	for {
 		// Read the message type
-		_, err := old.Read(msgType)
+		_, err := r.Read(msgType)
 		if err == io.EOF {
 			break
 		} else if err != nil {
 			return err
 		}
 
+		// The checksum must be the last thing in the snapshot.
+		if verified {
+			return fmt.Errorf("unexpected data after snapshot checksum, the snapshot is corrupt")
+		}
+
 		// Decode
 		msg := structs.MessageType(msgType[0])
-		if fn := restorers[msg]; fn != nil {
+		if msg == structs.SnapshotChecksumType {
+			if err := r.verify(dec); err != nil {
+				return err
+			}
+			verified = true
+		} else if fn := restorers[msg]; fn != nil {
 			if err := fn(&header, restore, dec); err != nil {
 				return err
 			}
@@ -190,3 +244,47 @@ func (c *FSM) Restore(old io.ReadCloser) error {
 	stateOld.Abandon()
 	return nil
 }
+
+// VerifySnapshot reads through a snapshot the way Restore does, checking that
+// every record has a known type and decodes, and that the checksum at the end
+// matches, if there is one. The records are thrown away as they're read, so
+// it doesn't need the memory a restore would.
+func VerifySnapshot(in io.Reader) error {
+	r := newChecksumReader(in)
+	dec := codec.NewDecoder(r, msgpackHandle)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return err
+	}
+
+	msgType := make([]byte, 1)
+	verified := false
+	for {
+		_, err := r.Read(msgType)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if verified {
+			return fmt.Errorf("unexpected data after snapshot checksum, the snapshot is corrupt")
+		}
+
+		msg := structs.MessageType(msgType[0])
+		if msg == structs.SnapshotChecksumType {
+			if err := r.verify(dec); err != nil {
+				return err
+			}
+			verified = true
+		} else if restorers[msg] != nil {
+			var record interface{}
+			if err := dec.Decode(&record); err != nil {
+				return fmt.Errorf("failed to decode msg type %d: %v", msg, err)
+			}
+		} else {
+			return fmt.Errorf("Unrecognized msg type %d", msg)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package fsm
 
 import (
+	"bufio"
 	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"log"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -15,9 +20,31 @@ import (
 // state in a way that can be accessed concurrently with operations
 // that may modify the live state.
 type snapshot struct {
-	state *state.Snapshot
+	state  *state.Snapshot
+	logger *log.Logger
+
+	// out is the sink the snapshot is being persisted to, which keeps
+	// count of what has been written so far. It's set by Persist.
+	out *bufferedSink
+
+	// typeCheck reports whether every server can restore records of a
+	// snapshot-only type. If it's nil those records are left out.
+	typeCheck func(structs.MessageType) bool
+}
+
+// writes returns whether records of the given snapshot-only type should be
+// written. Older servers fail to restore a snapshot with a type they don't
+// know, so they're only written once every server supports them.
+func (s *snapshot) writes(t structs.MessageType) bool {
+	return s.typeCheck != nil && s.typeCheck(t)
 }
 
+// snapshotBufferSize is how much of a snapshot is buffered before it's written
+// to the sink. Records are encoded into the buffer one at a time straight from
+// the state store, so this bounds the memory used to persist a snapshot no
+// matter how large the state is.
+const snapshotBufferSize = 256 * 1024
+
 // snapshotHeader is the first entry in our snapshot
 type snapshotHeader struct {
 	// LastIndex is the last index that affects the data.
@@ -55,15 +82,127 @@ func registerRestorer(msg structs.MessageType, fn restorer) {
 	restorers[msg] = fn
 }
 
-// Persist saves the FSM snapshot out to the given sink.
+// checksumSink wraps a snapshot sink and keeps a running checksum of
+// everything written to it.
+type checksumSink struct {
+	raft.SnapshotSink
+	hash hash.Hash64
+}
+
+func newChecksumSink(sink raft.SnapshotSink) *checksumSink {
+	return &checksumSink{
+		SnapshotSink: sink,
+		hash:         crc64.New(crc64.MakeTable(crc64.ECMA)),
+	}
+}
+
+func (c *checksumSink) Write(p []byte) (int, error) {
+	n, err := c.SnapshotSink.Write(p)
+	c.hash.Write(p[:n])
+	return n, err
+}
+
+// bufferedSink wraps a snapshot sink and batches the many small writes made
+// while persisting into chunks of snapshotBufferSize. It also counts the bytes
+// written so progress can be reported.
+type bufferedSink struct {
+	raft.SnapshotSink
+	buf     *bufio.Writer
+	written uint64
+}
+
+func newBufferedSink(sink raft.SnapshotSink) *bufferedSink {
+	return &bufferedSink{
+		SnapshotSink: sink,
+		buf:          bufio.NewWriterSize(sink, snapshotBufferSize),
+	}
+}
+
+func (b *bufferedSink) Write(p []byte) (int, error) {
+	n, err := b.buf.Write(p)
+	b.written += uint64(n)
+	return n, err
+}
+
+// Flush writes out anything still buffered. It must be called before the
+// underlying sink is closed.
+func (b *bufferedSink) Flush() error {
+	return b.buf.Flush()
+}
+
+// checksumReader wraps a snapshot being restored and keeps a running
+// checksum of everything read from it, so it can be compared against the
+// checksum written at the end of the snapshot.
+type checksumReader struct {
+	r    io.Reader
+	br   io.ByteReader
+	hash hash.Hash64
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	br, _ := r.(io.ByteReader)
+	return &checksumReader{
+		r:    r,
+		br:   br,
+		hash: crc64.New(crc64.MakeTable(crc64.ECMA)),
+	}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	return n, err
+}
+
+// ReadByte lets the decoder use the underlying reader's buffering, if it has
+// any, since msgpack reads a lot of single bytes.
+func (c *checksumReader) ReadByte() (byte, error) {
+	if c.br == nil {
+		var b [1]byte
+		if _, err := io.ReadFull(c, b[:]); err != nil {
+			return 0, err
+		}
+		return b[0], nil
+	}
+
+	b, err := c.br.ReadByte()
+	if err == nil {
+		c.hash.Write([]byte{b})
+	}
+	return b, err
+}
+
+// verify decodes the checksum stored in the snapshot and compares it with
+// the checksum of everything read before it.
+func (c *checksumReader) verify(dec *codec.Decoder) error {
+	computed := c.hash.Sum64()
+	var stored uint64
+	if err := dec.Decode(&stored); err != nil {
+		return fmt.Errorf("failed to decode snapshot checksum: %v", err)
+	}
+	if stored != computed {
+		return fmt.Errorf("snapshot checksum mismatch (stored: %x computed: %x), the snapshot is corrupt", stored, computed)
+	}
+	return nil
+}
+
+// Persist saves the FSM snapshot out to the given sink. The state is streamed
+// out table by table through a fixed size buffer, so the snapshot is never
+// held in memory.
 func (s *snapshot) Persist(sink raft.SnapshotSink) error {
-	defer metrics.MeasureSince([]string{"fsm", "persist"}, time.Now())
+	start := time.Now()
+	defer metrics.MeasureSince([]string{"fsm", "persist"}, start)
+
+	// Everything goes through the checksum so we can write it out at the
+	// end, which lets a restore detect a corrupted snapshot.
+	s.out = newBufferedSink(sink)
+	csink := newChecksumSink(s.out)
 
 	// Write the header
 	header := snapshotHeader{
 		LastIndex: s.state.LastIndex(),
 	}
-	encoder := codec.NewEncoder(sink, msgpackHandle)
+	encoder := codec.NewEncoder(csink, msgpackHandle)
 	if err := encoder.Encode(&header); err != nil {
 		sink.Cancel()
 		return err
@@ -71,10 +210,50 @@ func (s *snapshot) Persist(sink raft.SnapshotSink) error {
 
 	// Run all the persisters to write the FSM state.
 	for _, fn := range persisters {
-		if err := fn(s, sink, encoder); err != nil {
+		if err := fn(s, csink, encoder); err != nil {
+			sink.Cancel()
+			return err
+		}
+	}
+
+	// Write the checksum last. It covers everything up to and including
+	// its message type.
+	if s.writes(structs.SnapshotChecksumType) {
+		if _, err := csink.Write([]byte{byte(structs.SnapshotChecksumType)}); err != nil {
 			sink.Cancel()
 			return err
 		}
+		if err := encoder.Encode(csink.hash.Sum64()); err != nil {
+			sink.Cancel()
+			return err
+		}
+	}
+	if err := s.out.Flush(); err != nil {
+		sink.Cancel()
+		return err
+	}
+
+	if s.logger != nil {
+		s.logger.Printf("[INFO] consul.fsm: snapshot persisted, wrote %d bytes in %v", s.out.written, time.Since(start))
+	}
+	return nil
+}
+
+// persistTable runs the persister for one table of the FSM state and reports
+// how long it took and how much of the snapshot has been written so far.
+func (s *snapshot) persistTable(name string, sink raft.SnapshotSink, encoder *codec.Encoder,
+	fn func(sink raft.SnapshotSink, encoder *codec.Encoder) error) error {
+	start := time.Now()
+	if err := fn(sink, encoder); err != nil {
+		return fmt.Errorf("failed to persist %s: %v", name, err)
+	}
+	metrics.MeasureSince([]string{"fsm", "persist", name}, start)
+
+	if s.out != nil {
+		metrics.SetGauge([]string{"fsm", "persist", "bytes"}, float32(s.out.written))
+		if s.logger != nil {
+			s.logger.Printf("[DEBUG] consul.fsm: persisted %s in %v, %d bytes written so far", name, time.Since(start), s.out.written)
+		}
 	}
 	return nil
 }
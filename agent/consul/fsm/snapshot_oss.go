@@ -27,44 +27,46 @@ func init() {
 	registerRestorer(structs.IndexRequestType, restoreIndex)
 	registerRestorer(structs.ACLTokenSetRequestType, restoreToken)
 	registerRestorer(structs.ACLPolicySetRequestType, restorePolicy)
+	registerRestorer(structs.NamespaceRequestType, restoreNamespace)
+	registerRestorer(structs.ServiceFailoverRequestType, restoreServiceFailover)
+	registerRestorer(structs.ConfigEntryRequestType, restoreConfigEntry)
+	registerRestorer(structs.ChangeFeedEntryType, restoreChangeFeedEntry)
+	registerRestorer(structs.ResponseSigningKeyType, restoreResponseSigningKey)
+	registerRestorer(structs.LeaderTransitionType, restoreLeaderTransition)
+	registerRestorer(structs.ACLTokenUsageRequestType, restoreACLTokenUsage)
 }
 
+// persistOSS writes out each table in turn, so progress can be reported as the
+// snapshot is persisted.
 func persistOSS(s *snapshot, sink raft.SnapshotSink, encoder *codec.Encoder) error {
-	if err := s.persistNodes(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistSessions(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistACLs(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistKVs(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistTombstones(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistPreparedQueries(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistAutopilot(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistIntentions(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistConnectCA(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistConnectCAProviderState(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistConnectCAConfig(sink, encoder); err != nil {
-		return err
-	}
-	if err := s.persistIndex(sink, encoder); err != nil {
-		return err
+	tables := []struct {
+		name string
+		fn   func(sink raft.SnapshotSink, encoder *codec.Encoder) error
+	}{
+		{"nodes", s.persistNodes},
+		{"sessions", s.persistSessions},
+		{"acls", s.persistACLs},
+		{"kvs", s.persistKVs},
+		{"tombstones", s.persistTombstones},
+		{"prepared-queries", s.persistPreparedQueries},
+		{"autopilot", s.persistAutopilot},
+		{"intentions", s.persistIntentions},
+		{"connect-ca-roots", s.persistConnectCA},
+		{"connect-ca-provider-state", s.persistConnectCAProviderState},
+		{"connect-ca-config", s.persistConnectCAConfig},
+		{"namespaces", s.persistNamespaces},
+		{"service-failovers", s.persistServiceFailovers},
+		{"config-entries", s.persistConfigEntries},
+		{"change-feed", s.persistChangeFeed},
+		{"response-signing-key", s.persistResponseSigningKey},
+		{"leader-history", s.persistLeaderHistory},
+		{"acl-token-usage", s.persistACLTokenUsage},
+		{"index", s.persistIndex},
+	}
+	for _, t := range tables {
+		if err := s.persistTable(t.name, sink, encoder, t.fn); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -360,6 +362,137 @@ func (s *snapshot) persistIntentions(sink raft.SnapshotSink,
 	return nil
 }
 
+func (s *snapshot) persistNamespaces(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	namespaces, err := s.state.Namespaces()
+	if err != nil {
+		return err
+	}
+
+	for _, ns := range namespaces {
+		if _, err := sink.Write([]byte{byte(structs.NamespaceRequestType)}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) persistServiceFailovers(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	failovers, err := s.state.ServiceFailovers()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range failovers {
+		if _, err := sink.Write([]byte{byte(structs.ServiceFailoverRequestType)}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) persistConfigEntries(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	entries, err := s.state.ConfigEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if _, err := sink.Write([]byte{byte(structs.ConfigEntryRequestType)}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) persistResponseSigningKey(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	key, err := s.state.ResponseSigningKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return nil
+	}
+
+	if _, err := sink.Write([]byte{byte(structs.ResponseSigningKeyType)}); err != nil {
+		return err
+	}
+	if err := encoder.Encode(key); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *snapshot) persistLeaderHistory(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	transitions, err := s.state.LeaderHistory()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range transitions {
+		if _, err := sink.Write([]byte{byte(structs.LeaderTransitionType)}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) persistACLTokenUsage(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	usage, err := s.state.ACLTokenUsage()
+	if err != nil {
+		return err
+	}
+
+	for u := usage.Next(); u != nil; u = usage.Next() {
+		if _, err := sink.Write([]byte{byte(structs.ACLTokenUsageRequestType)}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(u.(*structs.ACLTokenUsage)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) persistChangeFeed(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	if !s.writes(structs.ChangeFeedEntryType) {
+		return nil
+	}
+
+	entries, err := s.state.ChangeFeed()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if _, err := sink.Write([]byte{byte(structs.ChangeFeedEntryType)}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *snapshot) persistIndex(sink raft.SnapshotSink, encoder *codec.Encoder) error {
 	// Get all the indexes
 	iter, err := s.state.Indexes()
@@ -500,6 +633,73 @@ func restoreIntention(header *snapshotHeader, restore *state.Restore, decoder *c
 	return nil
 }
 
+func restoreNamespace(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.Namespace
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	if err := restore.Namespace(&req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func restoreServiceFailover(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.ServiceFailover
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	if err := restore.ServiceFailover(&req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func restoreResponseSigningKey(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.ResponseSigningKey
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	if err := restore.ResponseSigningKey(&req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func restoreLeaderTransition(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.LeaderTransition
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	if err := restore.LeaderTransition(&req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func restoreACLTokenUsage(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.ACLTokenUsage
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	if err := restore.ACLTokenUsage(&req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func restoreConfigEntry(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.ConfigEntry
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	req.NormalizeConfig()
+	if err := restore.ConfigEntry(&req); err != nil {
+		return err
+	}
+	return nil
+}
+
 func restoreConnectCA(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
 	var req structs.CARoot
 	if err := decoder.Decode(&req); err != nil {
@@ -565,3 +765,14 @@ func restorePolicy(header *snapshotHeader, restore *state.Restore, decoder *code
 	}
 	return restore.ACLPolicy(&req)
 }
+
+func restoreChangeFeedEntry(header *snapshotHeader, restore *state.Restore, decoder *codec.Decoder) error {
+	var req structs.ChangeFeedEntry
+	if err := decoder.Decode(&req); err != nil {
+		return err
+	}
+	if err := restore.ChangeFeedEntry(&req); err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package fsm
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"reflect"
 	"testing"
@@ -170,6 +171,50 @@ func TestFSM_SnapshotRestore_OSS(t *testing.T) {
 	}
 	assert.Nil(fsm.state.IntentionSet(14, ixn))
 
+	// Namespaces
+	ns := &structs.Namespace{Name: "eng", Description: "Engineering"}
+	assert.Nil(fsm.state.NamespaceSet(15, ns))
+
+	// Service failovers
+	failover := &structs.ServiceFailover{
+		Service:  "web",
+		Failover: structs.QueryDatacenterOptions{NearestN: 2},
+	}
+	assert.Nil(fsm.state.ServiceFailoverSet(16, failover))
+
+	// Config entries
+	entry := &structs.ConfigEntry{
+		Kind:   structs.ServiceDefaults,
+		Name:   "web",
+		Config: map[string]interface{}{"protocol": "http"},
+	}
+	assert.Nil(fsm.state.ConfigEntrySet(17, entry))
+
+	// Response signing key
+	signingKey := &structs.ResponseSigningKey{
+		ID:         "key1",
+		PublicKey:  []byte("public"),
+		PrivateKey: []byte("private"),
+	}
+	_, err = fsm.state.ResponseSigningKeySet(18, signingKey)
+	assert.Nil(err)
+
+	// Leader history
+	transition := &structs.LeaderTransition{
+		Time:           time.Now().UTC().Round(time.Second),
+		Term:           2,
+		Leader:         "server2",
+		PreviousLeader: "server1",
+		Reason:         "previous leader left the cluster",
+	}
+	assert.Nil(fsm.state.LeaderTransitionAppend(19, transition, 0))
+
+	// ACL token usage
+	tokenUsed := time.Now().UTC().Round(time.Second)
+	assert.Nil(fsm.state.ACLTokenUsageSet(20, []*structs.ACLTokenUsage{
+		{AccessorID: token.AccessorID, LastUsed: tokenUsed},
+	}))
+
 	// CA Roots
 	roots := []*structs.CARoot{
 		connect.TestCA(t, nil),
@@ -372,6 +417,37 @@ func TestFSM_SnapshotRestore_OSS(t *testing.T) {
 	assert.Len(ixns, 1)
 	assert.Equal(ixn, ixns[0])
 
+	// Verify namespaces are restored.
+	_, restoredNS, err := fsm2.state.NamespaceGet(nil, "eng")
+	assert.Nil(err)
+	assert.Equal(ns, restoredNS)
+
+	// Verify service failovers are restored.
+	_, restoredFailover, err := fsm2.state.ServiceFailoverGet(nil, "web")
+	assert.Nil(err)
+	assert.Equal(failover, restoredFailover)
+
+	// Verify config entries are restored.
+	_, restoredEntry, err := fsm2.state.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	assert.Nil(err)
+	assert.Equal(entry, restoredEntry)
+
+	// Verify the response signing key is restored.
+	_, restoredKey, err := fsm2.state.ResponseSigningKey(nil)
+	assert.Nil(err)
+	assert.Equal(signingKey, restoredKey)
+
+	// Verify the leader history is restored.
+	_, restoredHistory, err := fsm2.state.LeaderHistory(nil)
+	assert.Nil(err)
+	assert.Equal(structs.LeaderTransitions{transition}, restoredHistory)
+
+	// Verify the ACL token usage is restored.
+	usage, since, err := fsm2.state.ACLTokenUsageGet([]string{token.AccessorID})
+	assert.Nil(err)
+	assert.True(tokenUsed.Equal(usage[token.AccessorID]))
+	assert.True(tokenUsed.Equal(since))
+
 	// Verify CA roots are restored.
 	_, roots, err = fsm2.state.CARoots(nil)
 	assert.Nil(err)
@@ -454,6 +530,106 @@ func TestFSM_BadRestore_OSS(t *testing.T) {
 	}
 }
 
+func TestFSM_Restore_Checksum(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+	fsm.SetSnapshotTypeCheck(func(structs.MessageType) bool { return true })
+	require.NoError(fsm.state.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+
+	snap, err := fsm.Snapshot()
+	require.NoError(err)
+	defer snap.Release()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(snap.Persist(&MockSink{buf, false}))
+	data := buf.Bytes()
+
+	// A good snapshot restores.
+	fsm2, err := New(nil, os.Stderr)
+	require.NoError(err)
+	require.NoError(fsm2.Restore(&MockSink{bytes.NewBuffer(data), false}))
+
+	// Flip a byte in the middle of the node's address, which still
+	// decodes fine but doesn't match the checksum.
+	corrupt := append([]byte{}, data...)
+	i := bytes.Index(corrupt, []byte("127.0.0.1"))
+	require.True(i > 0)
+	corrupt[i] = '2'
+	err = fsm2.Restore(&MockSink{bytes.NewBuffer(corrupt), false})
+	require.Error(err)
+	require.Contains(err.Error(), "checksum mismatch")
+
+	// Nothing can come after the checksum.
+	trailing := append(append([]byte{}, data...), byte(structs.RegisterRequestType))
+	err = fsm2.Restore(&MockSink{bytes.NewBuffer(trailing), false})
+	require.Error(err)
+	require.Contains(err.Error(), "unexpected data after snapshot checksum")
+
+	// Verifying without restoring catches the same problems.
+	require.NoError(VerifySnapshot(bytes.NewReader(data)))
+	err = VerifySnapshot(bytes.NewReader(corrupt))
+	require.Error(err)
+	require.Contains(err.Error(), "checksum mismatch")
+	err = VerifySnapshot(bytes.NewReader(trailing))
+	require.Error(err)
+	require.Contains(err.Error(), "unexpected data after snapshot checksum")
+	err = VerifySnapshot(bytes.NewReader(append(append([]byte{}, data[:len(data)-1]...), 0xff)))
+	require.Error(err)
+
+	// The failed restores didn't touch the restored state.
+	_, nodes, err := fsm2.state.Nodes(nil)
+	require.NoError(err)
+	require.Len(nodes, 1)
+	require.Equal("127.0.0.1", nodes[0].Address)
+}
+
+func TestFSM_Snapshot_TypeCheck(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+	require.NoError(fsm.state.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+	require.NoError(fsm.state.ChangeFeedAppend(2, []*structs.Change{
+		{Type: structs.ChangeNode, Op: structs.ChangeDelete, Node: "bar"},
+	}, 10))
+
+	persist := func() []byte {
+		snap, err := fsm.Snapshot()
+		require.NoError(err)
+		defer snap.Release()
+		buf := bytes.NewBuffer(nil)
+		require.NoError(snap.Persist(&MockSink{buf, false}))
+		return buf.Bytes()
+	}
+	changeFeed := func(data []byte) int {
+		fsm2, err := New(nil, os.Stderr)
+		require.NoError(err)
+		require.NoError(fsm2.Restore(&MockSink{bytes.NewBuffer(data), false}))
+		_, _, entries, _, err := fsm2.state.ChangeFeedList(nil, 0, 0)
+		require.NoError(err)
+		return len(entries)
+	}
+
+	// Until every server supports them, the snapshot-only types are left
+	// out, so there's no change feed and no checksum to catch corruption.
+	fsm.SetSnapshotTypeCheck(func(t structs.MessageType) bool {
+		return t != structs.ChangeFeedEntryType && t != structs.SnapshotChecksumType
+	})
+	data := persist()
+	require.Equal(0, changeFeed(data))
+	i := bytes.Index(data, []byte("127.0.0.1"))
+	require.True(i > 0)
+	data[i] = '2'
+	require.Equal(0, changeFeed(data))
+
+	// Once they're supported they're written.
+	fsm.SetSnapshotTypeCheck(func(structs.MessageType) bool { return true })
+	require.Equal(1, changeFeed(persist()))
+}
+
 func TestFSM_BadSnapshot_NilCAConfig(t *testing.T) {
 	t.Parallel()
 
@@ -499,3 +675,53 @@ func TestFSM_BadSnapshot_NilCAConfig(t *testing.T) {
 		t.Fatalf("config should be nil")
 	}
 }
+
+// countingSink records the size of each write made to it.
+type countingSink struct {
+	*MockSink
+	writes []int
+}
+
+func (c *countingSink) Write(p []byte) (int, error) {
+	c.writes = append(c.writes, len(p))
+	return c.MockSink.Write(p)
+}
+
+func TestFSM_Persist_Buffered(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fsm, err := New(nil, os.Stderr)
+	require.NoError(err)
+	for i := 0; i < 5000; i++ {
+		require.NoError(fsm.state.KVSSet(uint64(i+1), &structs.DirEntry{
+			Key:   fmt.Sprintf("key/%d", i),
+			Value: []byte("value"),
+		}))
+	}
+
+	snap, err := fsm.Snapshot()
+	require.NoError(err)
+	defer snap.Release()
+	sink := &countingSink{MockSink: &MockSink{bytes.NewBuffer(nil), false}}
+	require.NoError(snap.Persist(sink))
+	require.False(sink.cancel)
+
+	// The small records are written to the sink in a few large chunks,
+	// rather than two writes per record.
+	require.True(len(sink.writes) < 10, "writes: %v", sink.writes)
+	total := 0
+	for _, n := range sink.writes {
+		require.True(n <= snapshotBufferSize, "write of %d bytes", n)
+		total += n
+	}
+	require.Equal(uint64(total), snap.(*snapshot).out.written)
+
+	// Everything made it out, including what was left in the buffer.
+	fsm2, err := New(nil, os.Stderr)
+	require.NoError(err)
+	require.NoError(fsm2.Restore(sink.MockSink))
+	_, entries, err := fsm2.state.KVSList(nil, "key/")
+	require.NoError(err)
+	require.Len(entries, 5000)
+}
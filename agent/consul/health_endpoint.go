@@ -7,6 +7,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/agent/consul/state"
 	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-memdb"
 )
 
@@ -23,6 +24,7 @@ func (h *Health) ChecksInState(args *structs.ChecksInStateRequest,
 	}
 
 	return h.srv.blockingQuery(
+		"checks",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -41,7 +43,11 @@ func (h *Health) ChecksInState(args *structs.ChecksInStateRequest,
 			if err := h.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
-			return h.srv.sortNodesByDistanceFrom(args.Source, reply.HealthChecks)
+			if err := h.srv.sortNodesByDistanceFrom(ws, &reply.QueryMeta, args.Source, reply.HealthChecks); err != nil {
+				return err
+			}
+			reply.HealthChecks = reply.HealthChecks[:h.srv.truncateResults(&args.QueryOptions, &reply.QueryMeta, len(reply.HealthChecks))]
+			return nil
 		})
 }
 
@@ -53,6 +59,7 @@ func (h *Health) NodeChecks(args *structs.NodeSpecificRequest,
 	}
 
 	return h.srv.blockingQuery(
+		"checks",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -79,6 +86,7 @@ func (h *Health) ServiceChecks(args *structs.ServiceSpecificRequest,
 	}
 
 	return h.srv.blockingQuery(
+		"checks",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -97,12 +105,19 @@ func (h *Health) ServiceChecks(args *structs.ServiceSpecificRequest,
 			if err := h.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
-			return h.srv.sortNodesByDistanceFrom(args.Source, reply.HealthChecks)
+			if err := h.srv.sortNodesByDistanceFrom(ws, &reply.QueryMeta, args.Source, reply.HealthChecks); err != nil {
+				return err
+			}
+			reply.HealthChecks = reply.HealthChecks[:h.srv.truncateResults(&args.QueryOptions, &reply.QueryMeta, len(reply.HealthChecks))]
+			return nil
 		})
 }
 
 // ServiceNodes returns all the nodes registered as part of a service including health info
 func (h *Health) ServiceNodes(args *structs.ServiceSpecificRequest, reply *structs.IndexedCheckServiceNodes) error {
+	if len(args.MultiDC) > 0 {
+		return h.serviceNodesMultiDC(args, reply)
+	}
 	if done, err := h.srv.forward("Health.ServiceNodes", args, args, reply); done {
 		return err
 	}
@@ -139,6 +154,7 @@ func (h *Health) ServiceNodes(args *structs.ServiceSpecificRequest, reply *struc
 	}
 
 	err := h.srv.blockingQuery(
+		"services",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -151,12 +167,33 @@ func (h *Health) ServiceNodes(args *structs.ServiceSpecificRequest, reply *struc
 			if len(args.NodeMetaFilters) > 0 {
 				reply.Nodes = nodeMetaFilter(args.NodeMetaFilters, reply.Nodes)
 			}
+			if args.OnlyPassing {
+				reply.Nodes = reply.Nodes.Filter(true)
+			}
 			if err := h.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
-			return h.srv.sortNodesByDistanceFrom(args.Source, reply.Nodes)
+			return h.srv.sortNodesByDistanceFrom(ws, &reply.QueryMeta, args.Source, reply.Nodes)
 		})
 
+	// Fall back to other datacenters if the service has a failover policy
+	// and there are no healthy instances here.
+	if err == nil && !args.SkipFailover && !hasHealthyNodes(reply.Nodes) {
+		err = h.serviceNodesFailover(args, reply)
+	}
+
+	// The limit is applied last so it doesn't hide healthy instances from
+	// the failover check.
+	if err == nil {
+		reply.Nodes = reply.Nodes[:h.srv.truncateResults(&args.QueryOptions, &reply.QueryMeta, len(reply.Nodes))]
+	}
+
+	// The checks are needed up to here to filter and fail over, so they're
+	// only summarized now.
+	if err == nil && args.SummarizeChecks {
+		reply.Nodes.SummarizeChecks()
+	}
+
 	// Provide some metrics
 	if err == nil {
 		// For metrics, we separate Connect-based lookups from non-Connect
@@ -189,9 +226,132 @@ func (h *Health) ServiceNodes(args *structs.ServiceSpecificRequest, reply *struc
 				[]metrics.Label{{Name: "service", Value: args.ServiceName}})
 		}
 	}
+
+	// The nodes are signed last, so the signature covers exactly what's
+	// returned. Nodes from another datacenter would be signed with this
+	// datacenter's key, as if they were its own, so a signed query that
+	// fails over is refused instead.
+	if err == nil && args.Sign {
+		if reply.FailoverDatacenter != "" {
+			return fmt.Errorf("Signed responses can't fail over to other datacenters, and service %q failed over to %q", args.ServiceName, reply.FailoverDatacenter)
+		}
+		reply.Signature, err = h.srv.signResponse("Health.ServiceNodes", args, reply.Index, signedCheckServiceNodes(reply.Nodes))
+	}
 	return err
 }
 
+// serviceNodesFailover replaces the nodes in the reply with the healthy nodes
+// from the first datacenter in the service's failover policy that has some.
+// The reply keeps the local index, so blocking queries wait for changes to the
+// local datacenter.
+func (h *Health) serviceNodesFailover(args *structs.ServiceSpecificRequest, reply *structs.IndexedCheckServiceNodes) error {
+	_, failover, err := h.srv.fsm.State().ServiceFailoverGet(nil, args.ServiceName)
+	if err != nil {
+		return err
+	}
+	if failover == nil {
+		return nil
+	}
+	dcs, err := h.srv.serviceFailoverDatacenters(failover)
+	if err != nil {
+		return err
+	}
+
+	for _, dc := range dcs {
+		// Don't block in the remote datacenter since the index is only
+		// meaningful here, and make sure it doesn't fail over again. The
+		// checks are needed to tell whether the remote nodes are healthy.
+		// Signed queries that fail over are refused, so the remote reply
+		// is never signed.
+		remoteArgs := *args
+		remoteArgs.Datacenter = dc
+		remoteArgs.SkipFailover = true
+		remoteArgs.SummarizeChecks = false
+		remoteArgs.MinQueryIndex = 0
+		remoteArgs.Sign = false
+
+		var remote structs.IndexedCheckServiceNodes
+		if err := h.srv.forwardDC("Health.ServiceNodes", dc, &remoteArgs, &remote); err != nil {
+			h.srv.logger.Printf("[WARN] consul.health: Failed querying for service '%s' in datacenter '%s': %s", args.ServiceName, dc, err)
+			continue
+		}
+		if hasHealthyNodes(remote.Nodes) {
+			metrics.IncrCounterWithLabels([]string{"health", "service", "failover"}, 1,
+				[]metrics.Label{{Name: "service", Value: args.ServiceName}, {Name: "datacenter", Value: dc}})
+			reply.Nodes = remote.Nodes
+			reply.FailoverDatacenter = dc
+			break
+		}
+	}
+	return nil
+}
+
+// serviceNodesMultiDC queries each of the datacenters in the request and
+// merges the nodes, with the nodes of the datacenters closest to us first.
+// The local query is run first, and the reply keeps its index, so blocking
+// queries wait for changes to the local datacenter.
+func (h *Health) serviceNodesMultiDC(args *structs.ServiceSpecificRequest, reply *structs.IndexedCheckServiceNodes) error {
+	dcs, err := h.srv.sortDatacentersByDistance(args.MultiDC)
+	if err != nil {
+		return err
+	}
+
+	localArgs := *args
+	localArgs.Datacenter = h.srv.config.Datacenter
+	localArgs.MultiDC = nil
+	localArgs.SkipFailover = true
+	var local structs.IndexedCheckServiceNodes
+	if err := h.ServiceNodes(&localArgs, &local); err != nil {
+		return err
+	}
+
+	replies := h.srv.forwardMultiDC("Health.ServiceNodes", dcs, args, func() interface{} {
+		return &structs.IndexedCheckServiceNodes{}
+	})
+	replies[h.srv.config.Datacenter] = &local
+
+	reply.QueryMeta = local.QueryMeta
+	reply.Nodes = nil
+	for _, dc := range dcs {
+		remote, ok := replies[dc]
+		if !ok {
+			continue
+		}
+		for _, node := range remote.(*structs.IndexedCheckServiceNodes).Nodes {
+			// The local nodes may point into the state store, so they're
+			// copied before tagging them with their datacenter.
+			n := *node.Node
+			n.Datacenter = dc
+			node.Node = &n
+			reply.Nodes = append(reply.Nodes, node)
+		}
+	}
+
+	// Each datacenter applies the limit itself, so it has to be applied
+	// again to the merged nodes.
+	truncated := false
+	for _, remote := range replies {
+		truncated = truncated || remote.(*structs.IndexedCheckServiceNodes).ResultsTruncated
+	}
+	reply.Nodes = reply.Nodes[:h.srv.truncateResults(&args.QueryOptions, &reply.QueryMeta, len(reply.Nodes))]
+	reply.ResultsTruncated = reply.ResultsTruncated || truncated
+	return nil
+}
+
+// hasHealthyNodes returns true if any of the nodes has no critical checks.
+func hasHealthyNodes(nodes structs.CheckServiceNodes) bool {
+OUTER:
+	for _, node := range nodes {
+		for _, check := range node.Checks {
+			if check.Status == api.HealthCritical {
+				continue OUTER
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // The serviceNodes* functions below are the various lookup methods that
 // can be used by the ServiceNodes endpoint.
 
@@ -205,11 +365,11 @@ func (h *Health) serviceNodesTagFilter(ws memdb.WatchSet, s *state.Store, args *
 	// Agents < v1.3.0 populate the ServiceTag field. In this case,
 	// use ServiceTag instead of the ServiceTags field.
 	if args.ServiceTag != "" {
-		return s.CheckServiceTagNodes(ws, args.ServiceName, []string{args.ServiceTag})
+		return h.srv.queryCache.checkServiceNodes(ws, s, args.ServiceName, []string{args.ServiceTag})
 	}
-	return s.CheckServiceTagNodes(ws, args.ServiceName, args.ServiceTags)
+	return h.srv.queryCache.checkServiceNodes(ws, s, args.ServiceName, args.ServiceTags)
 }
 
 func (h *Health) serviceNodesDefault(ws memdb.WatchSet, s *state.Store, args *structs.ServiceSpecificRequest) (uint64, structs.CheckServiceNodes, error) {
-	return s.CheckServiceNodes(ws, args.ServiceName)
+	return h.srv.queryCache.checkServiceNodes(ws, s, args.ServiceName, nil)
 }
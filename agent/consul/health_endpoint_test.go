@@ -519,6 +519,130 @@ func TestHealth_ServiceChecks_DistanceSort(t *testing.T) {
 	}
 }
 
+func TestHealth_MaxQueryResults(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.MaxQueryResults = 2
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	for _, node := range []string{"foo1", "foo2", "foo3"} {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "db",
+				Service: "db",
+			},
+			Check: &structs.HealthCheck{
+				Name:      "db connect",
+				Status:    api.HealthPassing,
+				ServiceID: "db",
+			},
+		}
+		var out struct{}
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+	}
+
+	// The server's limit applies without a limit in the request, and caps
+	// a larger one.
+	for _, maxResults := range []int{0, 1, 5} {
+		req := structs.ServiceSpecificRequest{
+			Datacenter:   "dc1",
+			ServiceName:  "db",
+			QueryOptions: structs.QueryOptions{MaxResults: maxResults},
+		}
+		var nodes structs.IndexedCheckServiceNodes
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &nodes))
+		want := 2
+		if maxResults == 1 {
+			want = 1
+		}
+		require.Len(t, nodes.Nodes, want)
+		require.True(t, nodes.ResultsTruncated)
+
+		var checks structs.IndexedHealthChecks
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceChecks", &req, &checks))
+		require.Len(t, checks.HealthChecks, want)
+		require.True(t, checks.ResultsTruncated)
+	}
+
+	// Nothing is truncated when there are few enough results.
+	req := structs.ChecksInStateRequest{
+		Datacenter: "dc1",
+		State:      api.HealthCritical,
+	}
+	var checks structs.IndexedHealthChecks
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ChecksInState", &req, &checks))
+	require.Len(t, checks.HealthChecks, 0)
+	require.False(t, checks.ResultsTruncated)
+}
+
+func TestHealth_ServiceNodes_QueryCache(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.QueryCacheSize = 10
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	register := func(status string) {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "db",
+				Service: "db",
+			},
+			Check: &structs.HealthCheck{
+				Name:      "db connect",
+				Status:    status,
+				ServiceID: "db",
+			},
+		}
+		var out struct{}
+		require.NoError(t, s1.RPC("Catalog.Register", &arg, &out))
+	}
+	register(api.HealthPassing)
+
+	// Repeated queries are answered from the cache.
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+	}
+	var first, second structs.IndexedCheckServiceNodes
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &first))
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &second))
+	require.Equal(t, first.Index, second.Index)
+	require.Equal(t, first.Nodes, second.Nodes)
+
+	// A blocking query answered from the cache still wakes up on changes.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		register(api.HealthCritical)
+	}()
+	req.MinQueryIndex = first.Index
+	req.MaxQueryTime = 5 * time.Second
+	var third structs.IndexedCheckServiceNodes
+	start := time.Now()
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &third))
+	require.True(t, time.Since(start) < 5*time.Second)
+	require.True(t, third.Index > first.Index)
+	require.Len(t, third.Nodes, 1)
+	require.Equal(t, api.HealthCritical, third.Nodes[0].Checks[0].Status)
+}
+
 func TestHealth_ServiceNodes(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServer(t)
@@ -835,6 +959,166 @@ func TestHealth_ServiceNodes_NodeMetaFilter(t *testing.T) {
 	}
 }
 
+func TestHealth_ServiceNodes_Failover(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.EnableResponseSigning = true
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc2"
+		c.Bootstrap = true
+		c.EnableResponseSigning = true
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+	testrpc.WaitForLeader(t, s2.RPC, "dc2")
+	joinWAN(t, s2, s1)
+
+	// The instance in dc1 is critical, and the one in dc2 is healthy.
+	register := func(dc, node string, status string) {
+		arg := structs.RegisterRequest{
+			Datacenter: dc,
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "web",
+				Service: "web",
+			},
+			Check: &structs.HealthCheck{
+				Name:      "web alive",
+				Status:    status,
+				ServiceID: "web",
+			},
+		}
+		var out struct{}
+		require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+	}
+	register("dc1", "foo", api.HealthCritical)
+	register("dc2", "bar", api.HealthPassing)
+
+	// Without a policy, the local instances are returned.
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "web",
+	}
+	var out structs.IndexedCheckServiceNodes
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	require.Len(out.Nodes, 1)
+	require.Equal("foo", out.Nodes[0].Node.Node)
+	require.Empty(out.FailoverDatacenter)
+	localIndex := out.Index
+
+	// With a policy, the healthy instances in dc2 are returned, but still
+	// with the local index.
+	arg := structs.ServiceFailoverRequest{
+		Datacenter: "dc1",
+		Op:         structs.ServiceFailoverOpUpsert,
+		Failover: &structs.ServiceFailover{
+			Service:  "web",
+			Failover: structs.QueryDatacenterOptions{Datacenters: []string{"dc3", "dc2"}},
+		},
+	}
+	var reply struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.Apply", &arg, &reply))
+
+	out = structs.IndexedCheckServiceNodes{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	require.Len(out.Nodes, 1)
+	require.Equal("bar", out.Nodes[0].Node.Node)
+	require.Equal("dc2", out.FailoverDatacenter)
+	require.Equal(localIndex, out.Index)
+
+	// Signed queries can't fail over, since the nodes would be signed with
+	// this datacenter's key.
+	req.Sign = true
+	err := msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out)
+	require.Error(err)
+	require.Contains(err.Error(), "can't fail over")
+	req.Sign = false
+
+	// Queries made for a failover don't fail over again.
+	req.SkipFailover = true
+	out = structs.IndexedCheckServiceNodes{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	require.Len(out.Nodes, 1)
+	require.Equal("foo", out.Nodes[0].Node.Node)
+	require.Empty(out.FailoverDatacenter)
+}
+
+func TestHealth_ServiceNodes_MultiDC(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	dir2, s2 := testServerDC(t, "dc2")
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+	testrpc.WaitForLeader(t, s2.RPC, "dc2")
+	joinWAN(t, s2, s1)
+
+	for dc, node := range map[string]string{"dc1": "foo", "dc2": "bar"} {
+		arg := structs.RegisterRequest{
+			Datacenter: dc,
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "web",
+				Service: "web",
+			},
+		}
+		var out struct{}
+		require.NoError(msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+	}
+
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "web",
+	}
+	var local structs.IndexedCheckServiceNodes
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &local))
+	require.Len(local.Nodes, 1)
+
+	// The local nodes come first, and unknown datacenters are skipped.
+	req.MultiDC = []string{"dc2", "dc3", "dc1", "dc2"}
+	var out structs.IndexedCheckServiceNodes
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	require.Len(out.Nodes, 2)
+	require.Equal("foo", out.Nodes[0].Node.Node)
+	require.Equal("dc1", out.Nodes[0].Node.Datacenter)
+	require.Equal("bar", out.Nodes[1].Node.Node)
+	require.Equal("dc2", out.Nodes[1].Node.Datacenter)
+	require.Equal(local.Index, out.Index)
+
+	// Only the given datacenters are queried.
+	req.MultiDC = []string{"dc2"}
+	out = structs.IndexedCheckServiceNodes{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	require.Len(out.Nodes, 1)
+	require.Equal("bar", out.Nodes[0].Node.Node)
+
+	// Tagging the local nodes doesn't change them in the state store.
+	req.MultiDC = nil
+	out = structs.IndexedCheckServiceNodes{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	require.Equal(local.Nodes, out.Nodes)
+}
+
 func TestHealth_ServiceNodes_DistanceSort(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServer(t)
@@ -924,6 +1208,85 @@ func TestHealth_ServiceNodes_DistanceSort(t *testing.T) {
 	}
 }
 
+func TestHealth_ServiceNodes_NearestPassing(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// The nearest instance is failing its check, and the others get
+	// further away in the reverse of their name order.
+	instances := []struct {
+		node   string
+		rtt    time.Duration
+		status string
+	}{
+		{"d", 1 * time.Millisecond, api.HealthWarning},
+		{"c", 2 * time.Millisecond, api.HealthPassing},
+		{"b", 3 * time.Millisecond, api.HealthPassing},
+		{"a", 4 * time.Millisecond, api.HealthPassing},
+	}
+	updates := structs.Coordinates{
+		{Node: "src", Coord: lib.GenerateCoordinate(0)},
+	}
+	for _, inst := range instances {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       inst.node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "db",
+				Service: "db",
+			},
+			Check: &structs.HealthCheck{
+				Name:      "db connect",
+				Status:    inst.status,
+				ServiceID: "db",
+			},
+		}
+		var out struct{}
+		require.NoError(t, msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out))
+		updates = append(updates, &structs.Coordinate{Node: inst.node, Coord: lib.GenerateCoordinate(inst.rtt)})
+	}
+	require.NoError(t, s1.fsm.State().EnsureNode(100, &structs.Node{Node: "src", Address: "127.0.0.2"}))
+	require.NoError(t, s1.fsm.State().CoordinateBatchUpdate(101, updates))
+
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+		OnlyPassing: true,
+		Source: structs.QuerySource{
+			Datacenter: "dc1",
+			Node:       "src",
+		},
+		QueryOptions: structs.QueryOptions{MaxResults: 2},
+	}
+	var out structs.IndexedCheckServiceNodes
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	var names []string
+	for _, node := range out.Nodes {
+		names = append(names, node.Node.Node)
+	}
+	require.Equal(t, []string{"c", "b"}, names)
+	require.True(t, out.ResultsTruncated)
+
+	// Without a source the passing nodes keep their usual order.
+	req.Source = structs.QuerySource{}
+	req.MaxResults = 0
+	out = structs.IndexedCheckServiceNodes{}
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out))
+	names = nil
+	for _, node := range out.Nodes {
+		names = append(names, node.Node.Node)
+	}
+	require.Equal(t, []string{"a", "b", "c"}, names)
+	require.False(t, out.ResultsTruncated)
+}
+
 func TestHealth_ServiceNodes_ConnectProxy_ACL(t *testing.T) {
 	t.Parallel()
 
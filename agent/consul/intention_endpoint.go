@@ -158,6 +158,7 @@ func (s *Intention) Get(
 	}
 
 	return s.srv.blockingQuery(
+		"connect-intentions",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -198,6 +199,7 @@ func (s *Intention) List(
 	}
 
 	return s.srv.blockingQuery(
+		"connect-intentions",
 		&args.QueryOptions, &reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
 			index, ixns, err := state.Intentions(ws)
@@ -242,6 +244,7 @@ func (s *Intention) Match(
 	}
 
 	return s.srv.blockingQuery(
+		"connect-intentions",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -26,6 +26,7 @@ func (m *Internal) NodeInfo(args *structs.NodeSpecificRequest,
 	}
 
 	return m.srv.blockingQuery(
+		"nodes",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -47,6 +48,7 @@ func (m *Internal) NodeDump(args *structs.DCSpecificRequest,
 	}
 
 	return m.srv.blockingQuery(
+		"nodes",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
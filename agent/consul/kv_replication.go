@@ -0,0 +1,235 @@
+package consul
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// kvReplicationBatchSize is the maximum number of keys written to Raft
+	// in a single transaction while replicating.
+	kvReplicationBatchSize = 64
+
+	// kvReplicationMaxRetryBackoff is the maximum number of seconds to wait
+	// between failed replication rounds.
+	kvReplicationMaxRetryBackoff = 64
+
+	// kvReplicationQueryTime is how long each blocking query against the
+	// source datacenter waits for changes.
+	kvReplicationQueryTime = 5 * time.Minute
+)
+
+// startKVReplication starts a goroutine that mirrors the configured KV prefix
+// from the source datacenter into this one. This only runs on the leader.
+func (s *Server) startKVReplication() {
+	s.kvReplicationLock.Lock()
+	defer s.kvReplicationLock.Unlock()
+
+	if s.kvReplicationEnabled || s.config.KVReplicationDatacenter == "" {
+		return
+	}
+
+	s.kvReplicationCh = make(chan struct{})
+	s.kvReplicationStatus = structs.KVReplicationStatus{
+		Enabled:          true,
+		Running:          true,
+		SourceDatacenter: s.config.KVReplicationDatacenter,
+		Prefix:           s.config.KVReplicationPrefix,
+	}
+
+	go func(stopCh chan struct{}) {
+		var failedAttempts uint
+		var lastRemoteIndex uint64
+		for {
+			index, exit, err := s.replicateKV(lastRemoteIndex, stopCh)
+			if exit {
+				return
+			}
+
+			if err != nil {
+				lastRemoteIndex = 0
+				s.updateKVReplicationStatusError(err)
+				s.logger.Printf("[WARN] consul: KV replication error (will retry if still leader): %v", err)
+				if (1 << failedAttempts) < kvReplicationMaxRetryBackoff {
+					failedAttempts++
+				}
+
+				select {
+				case <-stopCh:
+					return
+				case <-time.After((1 << failedAttempts) * time.Second):
+				}
+			} else {
+				lastRemoteIndex = index
+				failedAttempts = 0
+			}
+		}
+	}(s.kvReplicationCh)
+
+	s.logger.Printf("[INFO] consul: started KV replication of prefix %q from datacenter %q",
+		s.config.KVReplicationPrefix, s.config.KVReplicationDatacenter)
+	s.kvReplicationEnabled = true
+}
+
+// stopKVReplication stops KV replication when we lose leadership.
+func (s *Server) stopKVReplication() {
+	s.kvReplicationLock.Lock()
+	defer s.kvReplicationLock.Unlock()
+
+	if !s.kvReplicationEnabled {
+		return
+	}
+
+	close(s.kvReplicationCh)
+	s.kvReplicationStatus.Running = false
+	s.kvReplicationEnabled = false
+}
+
+// getKVReplicationStatus returns the status of KV replication.
+func (s *Server) getKVReplicationStatus() structs.KVReplicationStatus {
+	s.kvReplicationLock.RLock()
+	defer s.kvReplicationLock.RUnlock()
+	return s.kvReplicationStatus
+}
+
+func (s *Server) updateKVReplicationStatusError(err error) {
+	s.kvReplicationLock.Lock()
+	defer s.kvReplicationLock.Unlock()
+
+	s.kvReplicationStatus.LastError = time.Now().Round(time.Second).UTC()
+	s.kvReplicationStatus.LastErrorMessage = err.Error()
+}
+
+func (s *Server) updateKVReplicationStatusIndex(index uint64, lag time.Duration) {
+	s.kvReplicationLock.Lock()
+	defer s.kvReplicationLock.Unlock()
+
+	s.kvReplicationStatus.LastSuccess = time.Now().Round(time.Second).UTC()
+	s.kvReplicationStatus.ReplicatedIndex = index
+	s.kvReplicationStatus.Lag = lag
+}
+
+// replicateKV waits for the replicated prefix to change in the source
+// datacenter after the given index, and then brings the local copy in line
+// with it. It returns the remote index replicated up to, and whether
+// replication was stopped while it was waiting.
+func (s *Server) replicateKV(lastRemoteIndex uint64, stopCh chan struct{}) (uint64, bool, error) {
+	args := structs.KeyRequest{
+		Datacenter: s.config.KVReplicationDatacenter,
+		Key:        s.config.KVReplicationPrefix,
+		QueryOptions: structs.QueryOptions{
+			Token:         s.tokens.ACLReplicationToken(),
+			AllowStale:    true,
+			MinQueryIndex: lastRemoteIndex,
+			MaxQueryTime:  kvReplicationQueryTime,
+		},
+	}
+	var remote structs.IndexedDirEntries
+	err := s.RPC("KVS.List", &args, &remote)
+
+	// Don't touch the KV store if we lost leadership while waiting.
+	select {
+	case <-stopCh:
+		return 0, true, nil
+	default:
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list remote keys: %v", err)
+	}
+
+	// We diff against the local keys even if the query timed out without
+	// changes, so any local changes to the prefix get undone.
+	start := time.Now()
+	_, local, err := s.fsm.State().KVSList(nil, s.config.KVReplicationPrefix)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list local keys: %v", err)
+	}
+
+	ops := diffKVEntries(local, remote.Entries)
+	for len(ops) > 0 {
+		batch := ops
+		if len(batch) > kvReplicationBatchSize {
+			batch = batch[:kvReplicationBatchSize]
+		}
+		ops = ops[len(batch):]
+
+		req := structs.TxnRequest{
+			Datacenter: s.config.Datacenter,
+			Ops:        batch,
+		}
+		resp, err := s.raftApplyInternal(structs.TxnRequestType, &req)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to apply keys: %v", err)
+		}
+		if respErr, ok := resp.(error); ok {
+			return 0, false, fmt.Errorf("failed to apply keys: %v", respErr)
+		}
+		if txnResp, ok := resp.(structs.TxnResponse); ok && len(txnResp.Errors) > 0 {
+			return 0, false, fmt.Errorf("failed to apply keys: %v", txnResp.Errors[0].What)
+		}
+
+		for _, op := range batch {
+			if op.KV.Verb == api.KVDelete {
+				metrics.IncrCounter([]string{"leader", "kv_replication", "deletes"}, 1)
+			} else {
+				metrics.IncrCounter([]string{"leader", "kv_replication", "sets"}, 1)
+			}
+		}
+	}
+
+	// The lag is how stale the source server was, plus the time it took us
+	// to apply its changes.
+	lag := remote.LastContact + time.Since(start)
+	metrics.SetGauge([]string{"leader", "kv_replication", "lag"}, float32(lag.Seconds()*1000))
+	s.updateKVReplicationStatusIndex(remote.Index, lag)
+	s.logger.Printf("[DEBUG] consul: KV replication completed through remote index %d", remote.Index)
+	return remote.Index, false, nil
+}
+
+// diffKVEntries returns the transaction operations needed to make the local
+// entries match the remote ones. Sessions and lock indexes are local to each
+// datacenter, so only the values and flags are replicated.
+func diffKVEntries(local, remote structs.DirEntries) structs.TxnOps {
+	existing := make(map[string]*structs.DirEntry, len(local))
+	for _, entry := range local {
+		existing[entry.Key] = entry
+	}
+
+	var ops structs.TxnOps
+	for _, entry := range remote {
+		cur, ok := existing[entry.Key]
+		delete(existing, entry.Key)
+		if ok && cur.Flags == entry.Flags && bytes.Equal(cur.Value, entry.Value) {
+			continue
+		}
+		ops = append(ops, &structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb: api.KVSet,
+				DirEnt: structs.DirEntry{
+					Key:   entry.Key,
+					Flags: entry.Flags,
+					Value: entry.Value,
+				},
+			},
+		})
+	}
+
+	// Whatever is left doesn't exist in the source datacenter anymore.
+	for _, entry := range local {
+		if _, ok := existing[entry.Key]; !ok {
+			continue
+		}
+		ops = append(ops, &structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb:   api.KVDelete,
+				DirEnt: structs.DirEntry{Key: entry.Key},
+			},
+		})
+	}
+	return ops
+}
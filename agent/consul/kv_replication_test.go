@@ -0,0 +1,146 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVReplication(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc2"
+		c.KVReplicationDatacenter = "dc1"
+		c.KVReplicationPrefix = "global/"
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+	testrpc.WaitForLeader(t, s2.RPC, "dc2")
+
+	apply := func(s *Server, dc string, op api.KVOp, key, value string) {
+		t.Helper()
+		arg := structs.KVSRequest{
+			Datacenter: dc,
+			Op:         op,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Value: []byte(value),
+			},
+		}
+		var out bool
+		require.NoError(t, s.RPC("KVS.Apply", &arg, &out))
+	}
+
+	// Keys under the prefix that aren't in dc1 get removed, and keys
+	// outside of it are left alone.
+	apply(s2, "dc2", api.KVSet, "global/stale", "x")
+	apply(s2, "dc2", api.KVSet, "local/keep", "x")
+
+	apply(s1, "dc1", api.KVSet, "global/a", "1")
+	apply(s1, "dc1", api.KVSet, "global/b", "2")
+	apply(s1, "dc1", api.KVSet, "other/c", "3")
+
+	joinWAN(t, s2, s1)
+	testrpc.WaitForLeader(t, s1.RPC, "dc2")
+
+	waitForKeys := func(want map[string]string) {
+		t.Helper()
+		timer := &retry.Timer{Timeout: 30 * time.Second, Wait: 100 * time.Millisecond}
+		retry.RunWith(timer, t, func(r *retry.R) {
+			_, entries, err := s2.fsm.State().KVSList(nil, "")
+			if err != nil {
+				r.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, entry := range entries {
+				got[entry.Key] = string(entry.Value)
+			}
+			if len(got) != len(want) {
+				r.Fatalf("bad: %v", got)
+			}
+			for k, v := range want {
+				if got[k] != v {
+					r.Fatalf("bad: %v", got)
+				}
+			}
+		})
+	}
+	waitForKeys(map[string]string{
+		"global/a":   "1",
+		"global/b":   "2",
+		"local/keep": "x",
+	})
+
+	// Updates and deletes are replicated too.
+	apply(s1, "dc1", api.KVSet, "global/a", "4")
+	apply(s1, "dc1", api.KVDelete, "global/b", "")
+	waitForKeys(map[string]string{
+		"global/a":   "4",
+		"local/keep": "x",
+	})
+
+	args := structs.DCSpecificRequest{Datacenter: "dc2"}
+	var status structs.KVReplicationStatus
+	require.NoError(t, s2.RPC("Operator.KVReplicationStatus", &args, &status))
+	require.True(t, status.Enabled)
+	require.True(t, status.Running)
+	require.Equal(t, "dc1", status.SourceDatacenter)
+	require.Equal(t, "global/", status.Prefix)
+	require.NotZero(t, status.ReplicatedIndex)
+	require.False(t, status.LastSuccess.IsZero())
+}
+
+func TestKVReplication_Disabled(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	args := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var status structs.KVReplicationStatus
+	require.NoError(t, s1.RPC("Operator.KVReplicationStatus", &args, &status))
+	require.False(t, status.Enabled)
+	require.False(t, status.Running)
+}
+
+func TestDiffKVEntries(t *testing.T) {
+	t.Parallel()
+
+	local := structs.DirEntries{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2")},
+		{Key: "c", Value: []byte("3"), Flags: 1},
+		{Key: "d", Value: []byte("4")},
+	}
+	remote := structs.DirEntries{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("5")},
+		{Key: "c", Value: []byte("3"), Flags: 2},
+		{Key: "e", Value: []byte("6"), Session: "remote-session"},
+	}
+
+	ops := diffKVEntries(local, remote)
+	require.Len(t, ops, 4)
+	require.Equal(t, api.KVSet, ops[0].KV.Verb)
+	require.Equal(t, "b", ops[0].KV.DirEnt.Key)
+	require.Equal(t, api.KVSet, ops[1].KV.Verb)
+	require.Equal(t, "c", ops[1].KV.DirEnt.Key)
+	require.Equal(t, uint64(2), ops[1].KV.DirEnt.Flags)
+	require.Equal(t, api.KVSet, ops[2].KV.Verb)
+	require.Equal(t, "e", ops[2].KV.DirEnt.Key)
+	require.Empty(t, ops[2].KV.DirEnt.Session)
+	require.Equal(t, api.KVDelete, ops[3].KV.Verb)
+	require.Equal(t, "d", ops[3].KV.DirEnt.Key)
+}
@@ -67,6 +67,7 @@ func kvsPreApply(srv *Server, rule acl.Authorizer, op api.KVOp, dirEnt *structs.
 		state := srv.fsm.State()
 		expires := state.KVSLockDelay(dirEnt.Key)
 		if expires.After(time.Now()) {
+			metrics.IncrCounter([]string{"kvs", "lock_delay", "rejected"}, 1)
 			srv.logger.Printf("[WARN] consul.kvs: Rejecting lock of %s due to lock-delay until %v",
 				dirEnt.Key, expires)
 			return false, nil
@@ -97,6 +98,20 @@ func (k *KVS) Apply(args *structs.KVSRequest, reply *bool) error {
 		return nil
 	}
 
+	// Queued lock requests only go ahead once it's their turn.
+	if args.Op == api.KVLock && args.Queue && !k.lockAdmitted(&args.DirEnt) {
+		metrics.IncrCounter([]string{"kvs", "lock_queue", "wait"}, 1)
+		*reply = false
+		return nil
+	}
+
+	// Deletes are always let through so space can be freed.
+	if !isKVDelete(args.Op) {
+		if err := k.srv.admitStateWrite(args); err != nil {
+			return err
+		}
+	}
+
 	// Apply the update.
 	resp, err := k.srv.raftApply(structs.KVSRequestType, args)
 	if err != nil {
@@ -110,7 +125,53 @@ func (k *KVS) Apply(args *structs.KVSRequest, reply *bool) error {
 	// Check if the return type is a bool.
 	if respBool, ok := resp.(bool); ok {
 		*reply = respBool
+		if respBool && args.Op == api.KVLock {
+			k.srv.lockQueues.acquired(args.DirEnt.Key, args.DirEnt.Session)
+		}
+	}
+	return nil
+}
+
+// lockAdmitted puts the session of a lock request in the wait queue of its
+// key, and returns whether it's its turn to try to acquire the key.
+func (k *KVS) lockAdmitted(dirEnt *structs.DirEntry) bool {
+	state := k.srv.fsm.State()
+	holder := ""
+	if _, ent, err := state.KVSGet(nil, dirEnt.Key); err == nil && ent != nil {
+		holder = ent.Session
+	}
+	valid := func(id string) bool {
+		_, sess, err := state.SessionGet(nil, id)
+		return err == nil && sess != nil
+	}
+	return k.srv.lockQueues.admit(dirEnt.Key, dirEnt.Session, holder, valid, time.Now())
+}
+
+// LockQueue returns the sessions waiting in line to acquire a key.
+func (k *KVS) LockQueue(args *structs.KeyRequest, reply *structs.KVSLockQueue) error {
+	// The queues are only kept by the leader, so we fix the args since we
+	// are re-using a structure where we don't support all the options.
+	args.RequireConsistent = true
+	args.AllowStale = false
+	if done, err := k.srv.forward("KVS.LockQueue", args, args, reply); done {
+		return err
+	}
+
+	aclRule, err := k.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if aclRule != nil && !aclRule.KeyRead(args.Key) {
+		return acl.ErrPermissionDenied
 	}
+
+	index, _, err := k.srv.fsm.State().KVSGet(nil, args.Key)
+	if err != nil {
+		return err
+	}
+	reply.Index = index
+	reply.Sessions = k.srv.lockQueues.sessions(args.Key)
+	k.srv.setQueryMeta(&reply.QueryMeta)
 	return nil
 }
 
@@ -125,6 +186,7 @@ func (k *KVS) Get(args *structs.KeyRequest, reply *structs.IndexedDirEntries) er
 		return err
 	}
 	return k.srv.blockingQuery(
+		"kvs",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -135,6 +197,11 @@ func (k *KVS) Get(args *structs.KeyRequest, reply *structs.IndexedDirEntries) er
 			if aclRule != nil && !aclRule.KeyRead(args.Key) {
 				return acl.ErrPermissionDenied
 			}
+			if left := time.Until(state.KVSLockDelay(args.Key)); left > 0 {
+				reply.LockDelay = left
+			} else {
+				reply.LockDelay = 0
+			}
 
 			if ent == nil {
 				// Must provide non-zero index to prevent blocking
@@ -169,15 +236,24 @@ func (k *KVS) List(args *structs.KeyRequest, reply *structs.IndexedDirEntries) e
 	}
 
 	return k.srv.blockingQuery(
+		"kvs",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
-			index, ent, err := state.KVSList(ws, args.Key)
+			var index uint64
+			var ent structs.DirEntries
+			var err error
+			if args.Diff {
+				index, ent, reply.Removed, reply.Resync, err = state.KVSListDiff(ws, args.Key, args.MinQueryIndex)
+			} else {
+				index, ent, err = state.KVSList(ws, args.Key)
+			}
 			if err != nil {
 				return err
 			}
 			if aclToken != nil {
 				ent = FilterDirEnt(aclToken, ent)
+				reply.Removed = FilterKeys(aclToken, reply.Removed)
 			}
 
 			if len(ent) == 0 {
@@ -213,6 +289,7 @@ func (k *KVS) ListKeys(args *structs.KeyListRequest, reply *structs.IndexedKeyLi
 	}
 
 	return k.srv.blockingQuery(
+		"kvs",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
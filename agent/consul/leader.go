@@ -2,6 +2,7 @@ package consul
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -133,11 +134,21 @@ func (s *Server) leaderLoop(stopCh chan struct{}) {
 		}
 	}
 
-	// Reconcile channel is only used once initial reconcile
-	// has succeeded
-	var reconcileCh chan serf.Member
+	// Subscribe to member events for as long as we're the leader. The
+	// recent events are replayed in case they happened while there was no
+	// leader to reconcile them. The channel is only used once the initial
+	// reconcile has succeeded, and the events buffer until then.
+	reconcileSub := s.memberEvents.Subscribe("reconciler", reconcileChSize, true)
+	defer s.memberEvents.Unsubscribe(reconcileSub)
+	var reconcileCh <-chan serf.MemberEvent
 	establishedLeader := false
 
+	// Member events are queued and reconciled at a limited rate so a large
+	// number of members changing at once doesn't flood Raft. Writes from a
+	// full reconcile that are over the limit are queued as well.
+	reconcileQueue := newReconcileQueue()
+	var reconcileNext <-chan time.Time
+
 	reassert := func() error {
 		if !establishedLeader {
 			return fmt.Errorf("leadership has not been established")
@@ -185,14 +196,18 @@ RECONCILE:
 	}
 
 	// Reconcile any missing data
-	if err := s.reconcile(); err != nil {
+	if err := s.reconcile(reconcileQueue); err != nil {
 		s.logger.Printf("[ERR] consul: failed to reconcile: %v", err)
 		goto WAIT
 	}
+	s.syncChangeFeedConfig()
+	if err := s.loadBootstrapData(); err != nil {
+		s.logger.Printf("[ERR] consul.bootstrap: Failed to load bootstrap data: %v", err)
+	}
 
 	// Initial reconcile worked, now we can process the channel
 	// updates
-	reconcileCh = s.reconcileCh
+	reconcileCh = reconcileSub.Events()
 
 WAIT:
 	// Poll the stop channel to give it priority so we don't waste time
@@ -207,6 +222,16 @@ WAIT:
 	// Periodically reconcile as long as we are the leader,
 	// or when Serf events arrive
 	for {
+		// Schedule the next queued member event once the rate limit
+		// allows it.
+		if reconcileNext == nil && reconcileQueue.Len() > 0 {
+			r := s.reconcileLimiter.Reserve()
+			delay := r.Delay()
+			r.Cancel()
+			reconcileNext = time.After(delay)
+		}
+		metrics.SetGauge([]string{"leader", "reconcile_queue"}, float32(reconcileQueue.Len()))
+
 		select {
 		case <-stopCh:
 			return
@@ -214,8 +239,28 @@ WAIT:
 			return
 		case <-interval:
 			goto RECONCILE
-		case member := <-reconcileCh:
-			s.reconcileMember(member)
+		case e := <-reconcileCh:
+			// Events we couldn't keep up with are caught by a full
+			// reconcile.
+			if dropped := reconcileSub.takeDropped(); dropped > 0 {
+				s.logger.Printf("[WARN] consul: Dropped %d member events, doing a full reconcile", dropped)
+				goto RECONCILE
+			}
+			for _, member := range reconcileMembers(e) {
+				reconcileQueue.Push(member)
+			}
+		case <-reconcileNext:
+			reconcileNext = nil
+			for {
+				member, ok := reconcileQueue.Pop()
+				if !ok {
+					break
+				}
+				if err := s.reconcileMember(member); err == errReconcileThrottled {
+					reconcileQueue.Push(member)
+					break
+				}
+			}
 		case index := <-s.tombstoneGC.ExpireCh():
 			go s.reapTombstones(index)
 		case errCh := <-s.reassertLeaderCh:
@@ -264,6 +309,12 @@ func (s *Server) establishLeadership() error {
 	s.getOrCreateAutopilotConfig()
 	s.autopilot.Start()
 
+	s.getOrCreateResponseSigningKey()
+
+	s.recordLeaderTransition()
+
+	s.resetClockSkew()
+
 	// todo(kyhavlov): start a goroutine here for handling periodic CA rotation
 	if err := s.initializeCA(); err != nil {
 		return err
@@ -273,6 +324,28 @@ func (s *Server) establishLeadership() error {
 
 	s.startCARootPruning()
 
+	s.startCatalogCheck()
+
+	s.startStateMemoryAccounting()
+
+	s.startKVReplication()
+
+	s.startElections()
+
+	s.syncChangeFeedConfig()
+	s.startChangeSinks()
+
+	if s.ACLsEnabled() {
+		s.startACLTokenReaping()
+		s.startACLTokenUnusedCheck()
+	}
+
+	// A bad bootstrap data directory shouldn't keep the cluster from having
+	// a leader, so it's logged and tried again as the leader reconciles.
+	if err := s.loadBootstrapData(); err != nil {
+		s.logger.Printf("[ERR] consul.bootstrap: Failed to load bootstrap data: %v", err)
+	}
+
 	s.setConsistentReadReady()
 	return nil
 }
@@ -293,10 +366,26 @@ func (s *Server) revokeLeadership() error {
 
 	s.stopCARootPruning()
 
+	s.stopCatalogCheck()
+
+	s.stopStateMemoryAccounting()
+
+	s.stopKVReplication()
+
+	s.lockQueues.reset()
+
+	s.stopElections()
+
+	s.stopChangeSinks()
+
 	s.setCAProvider(nil, nil)
 
 	s.stopACLUpgrade()
 
+	s.stopACLTokenReaping()
+
+	s.stopACLTokenUnusedCheck()
+
 	s.resetConsistentReadReady()
 	s.autopilot.Stop()
 	return nil
@@ -326,7 +415,7 @@ func (s *Server) initializeLegacyACL() error {
 				Type: structs.ACLTokenTypeClient,
 			},
 		}
-		_, err := s.raftApply(structs.ACLRequestType, &req)
+		_, err := s.raftApplyInternal(structs.ACLRequestType, &req)
 		if err != nil {
 			return fmt.Errorf("failed to create anonymous token: %v", err)
 		}
@@ -349,7 +438,7 @@ func (s *Server) initializeLegacyACL() error {
 					Type: structs.ACLTokenTypeManagement,
 				},
 			}
-			_, err := s.raftApply(structs.ACLRequestType, &req)
+			_, err := s.raftApplyInternal(structs.ACLRequestType, &req)
 			if err != nil {
 				return fmt.Errorf("failed to create master token: %v", err)
 			}
@@ -373,7 +462,7 @@ func (s *Server) initializeLegacyACL() error {
 				Datacenter: authDC,
 				Op:         structs.ACLBootstrapInit,
 			}
-			resp, err := s.raftApply(structs.ACLRequestType, &req)
+			resp, err := s.raftApplyInternal(structs.ACLRequestType, &req)
 			if err != nil {
 				return fmt.Errorf("failed to initialize ACL bootstrap: %v", err)
 			}
@@ -436,7 +525,7 @@ func (s *Server) initializeACLs(upgrade bool) error {
 			req := structs.ACLPolicyBatchSetRequest{
 				Policies: structs.ACLPolicies{&policy},
 			}
-			_, err := s.raftApply(structs.ACLPolicySetRequestType, &req)
+			_, err := s.raftApplyInternal(structs.ACLPolicySetRequestType, &req)
 			if err != nil {
 				return fmt.Errorf("failed to create global-management policy: %v", err)
 			}
@@ -484,7 +573,7 @@ func (s *Server) initializeACLs(upgrade bool) error {
 						Token:      token,
 						ResetIndex: 0,
 					}
-					if _, err := s.raftApply(structs.ACLBootstrapRequestType, &req); err == nil {
+					if _, err := s.raftApplyInternal(structs.ACLBootstrapRequestType, &req); err == nil {
 						s.logger.Printf("[INFO] consul: Bootstrapped ACL master token from configuration")
 						done = true
 					} else {
@@ -501,7 +590,7 @@ func (s *Server) initializeACLs(upgrade bool) error {
 						Tokens: structs.ACLTokens{&token},
 						CAS:    false,
 					}
-					if _, err := s.raftApply(structs.ACLTokenSetRequestType, &req); err != nil {
+					if _, err := s.raftApplyInternal(structs.ACLTokenSetRequestType, &req); err != nil {
 						return fmt.Errorf("failed to create master token: %v", err)
 					}
 
@@ -537,7 +626,7 @@ func (s *Server) initializeACLs(upgrade bool) error {
 					Tokens: structs.ACLTokens{token},
 					CAS:    false,
 				}
-				_, err := s.raftApply(structs.ACLTokenSetRequestType, &req)
+				_, err := s.raftApplyInternal(structs.ACLTokenSetRequestType, &req)
 				if err != nil {
 					return fmt.Errorf("failed to create anonymous token: %v", err)
 				}
@@ -636,7 +725,7 @@ func (s *Server) startACLUpgrade() {
 
 			req := &structs.ACLTokenBatchSetRequest{Tokens: newTokens, CAS: true}
 
-			resp, err := s.raftApply(structs.ACLTokenSetRequestType, req)
+			resp, err := s.raftApplyInternal(structs.ACLTokenSetRequestType, req)
 			if err != nil {
 				s.logger.Printf("[ERR] acl: failed to apply acl token upgrade batch: %v", err)
 			}
@@ -663,6 +752,97 @@ func (s *Server) stopACLUpgrade() {
 	s.aclUpgradeEnabled = false
 }
 
+// startACLTokenReaping starts a goroutine that deletes the local tokens
+// created by logging in once they expire.
+func (s *Server) startACLTokenReaping() {
+	s.aclTokenReapLock.Lock()
+	defer s.aclTokenReapLock.Unlock()
+
+	if s.aclTokenReapEnabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.aclTokenReapCancel = cancel
+
+	go func() {
+		for {
+			ws := memdb.NewWatchSet()
+			state := s.fsm.State()
+			_, tokens, err := state.ACLTokenList(ws, true, false, "")
+			if err != nil {
+				s.logger.Printf("[ERR] acl: failed to list tokens for reaping: %v", err)
+			}
+
+			now := time.Now()
+			var expired []string
+			var next time.Time
+			for _, token := range tokens {
+				if token.ExpirationTime == nil {
+					continue
+				}
+				if token.IsExpired(now) {
+					expired = append(expired, token.AccessorID)
+				} else if next.IsZero() || token.ExpirationTime.Before(next) {
+					next = *token.ExpirationTime
+				}
+			}
+
+			// Deleting a batch changes the tokens, so any left over are
+			// picked up straight away
+			if len(expired) > aclBatchDeleteSize {
+				expired = expired[:aclBatchDeleteSize]
+			}
+			if len(expired) > 0 {
+				req := &structs.ACLTokenBatchDeleteRequest{TokenIDs: expired}
+				resp, err := s.raftApplyInternal(structs.ACLTokenDeleteRequestType, req)
+				if err == nil {
+					if respErr, ok := resp.(error); ok {
+						err = respErr
+					}
+				}
+				if err != nil {
+					s.logger.Printf("[ERR] acl: failed to reap expired tokens: %v", err)
+				} else {
+					metrics.IncrCounter([]string{"acl", "token", "reaped"}, float32(len(expired)))
+					s.logger.Printf("[DEBUG] acl: reaped %d expired tokens", len(expired))
+				}
+			}
+
+			// Wait for the next token to expire or the tokens to change
+			wait := aclTokenReapMaxWait
+			if !next.IsZero() && next.Sub(now) < wait {
+				wait = next.Sub(now)
+			}
+			ws.Add(state.AbandonCh())
+			ws.Add(ctx.Done())
+			ws.Watch(time.After(wait))
+
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+		}
+	}()
+
+	s.aclTokenReapEnabled = true
+}
+
+// stopACLTokenReaping stops reaping expired tokens when we lose leadership.
+func (s *Server) stopACLTokenReaping() {
+	s.aclTokenReapLock.Lock()
+	defer s.aclTokenReapLock.Unlock()
+
+	if !s.aclTokenReapEnabled {
+		return
+	}
+
+	s.aclTokenReapCancel()
+	s.aclTokenReapCancel = nil
+	s.aclTokenReapEnabled = false
+}
+
 func (s *Server) startLegacyACLReplication() {
 	s.aclReplicationLock.Lock()
 	defer s.aclReplicationLock.Unlock()
@@ -852,7 +1032,7 @@ func (s *Server) getOrCreateAutopilotConfig() *autopilot.Config {
 
 	config = s.config.AutopilotConfig
 	req := structs.AutopilotSetConfigRequest{Config: *config}
-	if _, err = s.raftApply(structs.AutopilotRequestType, req); err != nil {
+	if _, err = s.raftApplyInternal(structs.AutopilotRequestType, req); err != nil {
 		s.logger.Printf("[ERR] autopilot: failed to initialize config: %v", err)
 		return nil
 	}
@@ -885,7 +1065,7 @@ func (s *Server) initializeCAConfig() (*structs.CAConfiguration, error) {
 		Op:     structs.CAOpSetConfig,
 		Config: config,
 	}
-	if _, err = s.raftApply(structs.ConnectCARequestType, req); err != nil {
+	if _, err = s.raftApplyInternal(structs.ConnectCARequestType, req); err != nil {
 		return nil, err
 	}
 
@@ -942,7 +1122,7 @@ func (s *Server) initializeRootCA(provider ca.Provider, conf *structs.CAConfigur
 	}
 
 	// Store the root cert in raft
-	resp, err := s.raftApply(structs.ConnectCARequestType, &structs.CARequest{
+	resp, err := s.raftApplyInternal(structs.ConnectCARequestType, &structs.CARequest{
 		Op:    structs.CAOpSetRoots,
 		Index: idx,
 		Roots: []*structs.CARoot{rootCA},
@@ -1102,7 +1282,7 @@ func (s *Server) pruneCARoots() error {
 	args.Op = structs.CAOpSetRoots
 	args.Index = idx
 	args.Roots = newRoots
-	resp, err := s.raftApply(structs.ConnectCARequestType, args)
+	resp, err := s.raftApplyInternal(structs.ConnectCARequestType, args)
 	if err != nil {
 		return err
 	}
@@ -1128,13 +1308,19 @@ func (s *Server) stopCARootPruning() {
 
 // reconcileReaped is used to reconcile nodes that have failed and been reaped
 // from Serf but remain in the catalog. This is done by looking for unknown nodes with serfHealth checks registered.
-// We generate a "reap" event to cause the node to be cleaned up.
-func (s *Server) reconcileReaped(known map[string]struct{}) error {
+// We generate a "reap" event to cause the node to be cleaned up. Members that
+// can't be deregistered within the reconcile rate limit are pushed onto the
+// queue.
+func (s *Server) reconcileReaped(known map[string]struct{}, queue *reconcileQueue) error {
 	state := s.fsm.State()
 	_, checks, err := state.ChecksInState(nil, api.HealthAny)
 	if err != nil {
 		return err
 	}
+	panicking, err := s.reconcilePanicking()
+	if err != nil {
+		return err
+	}
 	for _, check := range checks {
 		// Ignore any non serf checks
 		if check.CheckID != structs.SerfCheckID {
@@ -1177,7 +1363,8 @@ func (s *Server) reconcileReaped(known map[string]struct{}) error {
 
 		// Create a fake member
 		member := serf.Member{
-			Name: check.Node,
+			Name:   check.Node,
+			Status: StatusReap,
 			Tags: map[string]string{
 				"dc":   s.config.Datacenter,
 				"role": "node",
@@ -1193,7 +1380,12 @@ func (s *Server) reconcileReaped(known map[string]struct{}) error {
 		}
 
 		// Attempt to reap this member
-		if err := s.handleReapMember(member); err != nil {
+		err = s.reapMember(member, panicking)
+		if err == errReconcileThrottled {
+			queue.Push(member)
+			continue
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -1220,6 +1412,9 @@ func (s *Server) reconcileMember(member serf.Member) error {
 	case StatusReap:
 		err = s.handleReapMember(member)
 	}
+	if err == errReconcileThrottled {
+		return err
+	}
 	if err != nil {
 		s.logger.Printf("[ERR] consul: failed to reconcile member: %v: %v",
 			member, err)
@@ -1322,8 +1517,7 @@ AFTER_CHECK:
 		// clobber it.
 		SkipNodeUpdate: true,
 	}
-	_, err = s.raftApply(structs.RegisterRequestType, &req)
-	return err
+	return s.reconcileApply(structs.RegisterRequestType, &req)
 }
 
 // handleFailedMember is used to mark the node's status
@@ -1367,8 +1561,7 @@ func (s *Server) handleFailedMember(member serf.Member) error {
 		// clobber it.
 		SkipNodeUpdate: true,
 	}
-	_, err = s.raftApply(structs.RegisterRequestType, &req)
-	return err
+	return s.reconcileApply(structs.RegisterRequestType, &req)
 }
 
 // handleLeftMember is used to handle members that gracefully
@@ -1380,9 +1573,68 @@ func (s *Server) handleLeftMember(member serf.Member) error {
 // handleReapMember is used to handle members that have been
 // reaped after a prolonged failure. They are deregistered.
 func (s *Server) handleReapMember(member serf.Member) error {
+	panicking, err := s.reconcilePanicking()
+	if err != nil {
+		return err
+	}
+	return s.reapMember(member, panicking)
+}
+
+// reapMember deregisters a reaped member, unless too many nodes are failing
+// for it to be safe.
+func (s *Server) reapMember(member serf.Member, panicking bool) error {
+	if panicking {
+		s.logger.Printf("[WARN] consul: too many nodes are failing, not deregistering reaped member %q", member.Name)
+		metrics.IncrCounter([]string{"leader", "reconcile", "panic"}, 1)
+		return nil
+	}
 	return s.handleDeregisterMember("reaped", member)
 }
 
+// reconcilePanicking returns true if the fraction of nodes with a critical
+// Serf health check is above the panic threshold. Reaped nodes are kept in
+// the catalog while this is the case, since a large outage is more likely
+// than that many nodes being gone for good.
+func (s *Server) reconcilePanicking() (bool, error) {
+	threshold := s.config.ReconcilePanicThreshold
+	if threshold <= 0 {
+		return false, nil
+	}
+
+	_, checks, err := s.fsm.State().ChecksInState(nil, api.HealthAny)
+	if err != nil {
+		return false, err
+	}
+	total, failing := 0, 0
+	for _, check := range checks {
+		if check.CheckID != structs.SerfCheckID {
+			continue
+		}
+		total++
+		if check.Status == api.HealthCritical {
+			failing++
+		}
+	}
+	if total == 0 {
+		return false, nil
+	}
+	return float64(failing)/float64(total) > threshold, nil
+}
+
+// errReconcileThrottled is returned when a reconcile write is over the
+// reconcile rate limit.
+var errReconcileThrottled = errors.New("reconcile rate limit exceeded")
+
+// reconcileApply applies a catalog write made while reconciling a member, as
+// long as the reconcile rate limit allows it.
+func (s *Server) reconcileApply(t structs.MessageType, msg interface{}) error {
+	if !s.reconcileLimiter.Allow() {
+		return errReconcileThrottled
+	}
+	_, err := s.raftApplyInternal(t, msg)
+	return err
+}
+
 // handleDeregisterMember is used to deregister a member of a given reason
 func (s *Server) handleDeregisterMember(reason string, member serf.Member) error {
 	// Do not deregister ourself. This can only happen if the current leader
@@ -1416,8 +1668,7 @@ func (s *Server) handleDeregisterMember(reason string, member serf.Member) error
 		Datacenter: s.config.Datacenter,
 		Node:       member.Name,
 	}
-	_, err = s.raftApply(structs.DeregisterRequestType, &req)
-	return err
+	return s.reconcileApply(structs.DeregisterRequestType, &req)
 }
 
 // joinConsulServer is used to try to join another consul server
@@ -1574,7 +1825,7 @@ func (s *Server) reapTombstones(index uint64) {
 		Op:         structs.TombstoneReap,
 		ReapIndex:  index,
 	}
-	_, err := s.raftApply(structs.TombstoneRequestType, &req)
+	_, err := s.raftApplyInternal(structs.TombstoneRequestType, &req)
 	if err != nil {
 		s.logger.Printf("[ERR] consul: failed to reap tombstones up to %d: %v",
 			index, err)
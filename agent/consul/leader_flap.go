@@ -0,0 +1,157 @@
+package consul
+
+import (
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// leaderFlapInterval is how often the leader flapping state is updated
+	// when there are no elections, so it clears once things settle down.
+	leaderFlapInterval = 10 * time.Second
+)
+
+// leaderFlapDetector keeps track of the leader elections a server has seen
+// within a window of time, and considers the leader to be flapping once there
+// have been at least a threshold number of them.
+//
+// Flapping is only detected and reported, not damped. Raft copies its
+// configuration when it starts and can't change its election timeouts while
+// it's running, so the operator has to raise them with raft_multiplier and
+// restart the servers.
+type leaderFlapDetector struct {
+	threshold int
+	window    time.Duration
+
+	// elections has the times of the elections seen within the window,
+	// oldest first.
+	elections []time.Time
+
+	// flapping is whether the threshold was reached at the last update.
+	flapping bool
+
+	lock sync.Mutex
+}
+
+// newLeaderFlapDetector returns a detector for the given threshold and
+// window. A threshold of zero disables the detection.
+func newLeaderFlapDetector(threshold int, window time.Duration) *leaderFlapDetector {
+	return &leaderFlapDetector{
+		threshold: threshold,
+		window:    window,
+	}
+}
+
+// enabled returns whether the detection is turned on.
+func (d *leaderFlapDetector) enabled() bool {
+	return d.threshold > 0 && d.window > 0
+}
+
+// recordElection adds an election seen at the given time.
+func (d *leaderFlapDetector) recordElection(now time.Time) {
+	if !d.enabled() {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.elections = append(d.elections, now)
+}
+
+// update drops the elections that have fallen out of the window and returns
+// the number left, whether the leader is flapping, and whether that changed
+// since the last update.
+func (d *leaderFlapDetector) update(now time.Time) (int, bool, bool) {
+	if !d.enabled() {
+		return 0, false, false
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	cutoff := now.Add(-d.window)
+	i := 0
+	for i < len(d.elections) && !d.elections[i].After(cutoff) {
+		i++
+	}
+	d.elections = d.elections[i:]
+
+	flapping := len(d.elections) >= d.threshold
+	changed := flapping != d.flapping
+	d.flapping = flapping
+	return len(d.elections), flapping, changed
+}
+
+// status returns the number of elections within the window as of the given
+// time and whether the leader is flapping, without updating the detector.
+func (d *leaderFlapDetector) status(now time.Time) (int, bool) {
+	if !d.enabled() {
+		return 0, false
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	cutoff := now.Add(-d.window)
+	elections := 0
+	for _, t := range d.elections {
+		if t.After(cutoff) {
+			elections++
+		}
+	}
+	return elections, elections >= d.threshold
+}
+
+// monitorLeaderFlapping watches Raft for leader elections and keeps the
+// leader flapping state, metrics and logs up to date. It runs until the
+// server shuts down.
+func (s *Server) monitorLeaderFlapping() {
+	if !s.leaderFlap.enabled() {
+		return
+	}
+
+	// Raft sends a leader observation whenever this server's idea of the
+	// leader changes, including when it loses track of the leader, which
+	// isn't an election on its own.
+	obsCh := make(chan raft.Observation, 16)
+	observer := raft.NewObserver(obsCh, false, func(o *raft.Observation) bool {
+		_, ok := o.Data.(raft.LeaderObservation)
+		return ok
+	})
+	s.raft.RegisterObserver(observer)
+	defer s.raft.DeregisterObserver(observer)
+
+	ticker := time.NewTicker(leaderFlapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-obsCh:
+			if s.raft.Leader() != "" {
+				s.leaderFlap.recordElection(time.Now())
+			}
+		case <-ticker.C:
+		case <-s.shutdownCh:
+			return
+		}
+
+		elections, flapping, changed := s.leaderFlap.update(time.Now())
+		metrics.SetGauge([]string{"raft", "leader", "recent_elections"}, float32(elections))
+		if flapping {
+			metrics.SetGauge([]string{"raft", "leader", "flapping"}, 1)
+		} else {
+			metrics.SetGauge([]string{"raft", "leader", "flapping"}, 0)
+		}
+		if !changed {
+			continue
+		}
+		if flapping {
+			s.logger.Printf("[WARN] consul: Raft leader is flapping, there were %d leader elections in the last %s. This is usually caused by network problems or overloaded servers missing heartbeats, check the logs of all servers for details. If the servers are healthy, raising performance.raft_multiplier makes them wait longer than the current election timeout of %s before starting an election",
+				elections, s.leaderFlap.window, s.config.RaftConfig.ElectionTimeout)
+		} else {
+			s.logger.Printf("[INFO] consul: Raft leader has stopped flapping")
+		}
+	}
+}
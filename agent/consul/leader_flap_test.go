@@ -0,0 +1,44 @@
+package consul
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaderFlapDetector(t *testing.T) {
+	t.Parallel()
+
+	d := newLeaderFlapDetector(3, time.Minute)
+	start := time.Now()
+	check := func(now time.Time, elections int, flapping, changed bool) {
+		t.Helper()
+		if e, f := d.status(now); e != elections || f != flapping {
+			t.Fatalf("bad status: %d %v", e, f)
+		}
+		e, f, c := d.update(now)
+		if e != elections || f != flapping || c != changed {
+			t.Fatalf("bad update: %d %v %v", e, f, c)
+		}
+	}
+
+	// Two elections aren't enough.
+	d.recordElection(start)
+	d.recordElection(start.Add(10 * time.Second))
+	check(start.Add(10*time.Second), 2, false, false)
+
+	// The third one starts the flapping, which only changes once.
+	d.recordElection(start.Add(20 * time.Second))
+	check(start.Add(20*time.Second), 3, true, true)
+	check(start.Add(30*time.Second), 3, true, false)
+
+	// Once the first one falls out of the window it stops.
+	check(start.Add(61*time.Second), 2, false, true)
+	check(start.Add(2*time.Minute), 0, false, false)
+
+	// A zero threshold disables the detection.
+	d = newLeaderFlapDetector(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		d.recordElection(start)
+	}
+	check(start, 0, false, false)
+}
@@ -0,0 +1,76 @@
+package consul
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/serf/serf"
+)
+
+// recordLeaderTransition records that this server became the leader in the
+// leader history. Leadership is established again within the same term after
+// a snapshot restore, so a transition that's already recorded for this server
+// and term isn't recorded again. Failures are logged, since the history is
+// only there for operators to look back at.
+func (s *Server) recordLeaderTransition() {
+	_, history, err := s.fsm.State().LeaderHistory(nil)
+	if err != nil {
+		s.logger.Printf("[ERR] consul: Failed to get leader history: %v", err)
+		return
+	}
+	var previous string
+	if len(history) > 0 {
+		previous = history[0].Leader
+	}
+
+	// The term isn't critical, so it's left out if Raft doesn't report it.
+	term, _ := strconv.ParseUint(s.raft.Stats()["term"], 10, 64)
+	if term > 0 && len(history) > 0 && history[0].Term == term && history[0].Leader == s.config.NodeName {
+		return
+	}
+
+	req := structs.LeaderTransitionRequest{
+		Datacenter: s.config.Datacenter,
+		Transition: &structs.LeaderTransition{
+			Time:           time.Now().UTC(),
+			Term:           term,
+			Leader:         s.config.NodeName,
+			PreviousLeader: previous,
+			Reason:         s.leaderTransitionReason(previous),
+		},
+		MaxEntries: structs.LeaderHistoryMaxEntries,
+	}
+	resp, err := s.raftApplyInternal(structs.LeaderTransitionType, &req)
+	if respErr, ok := resp.(error); ok {
+		err = respErr
+	}
+	if err != nil {
+		s.logger.Printf("[WARN] consul: Failed to record leader transition: %v", err)
+	}
+}
+
+// leaderTransitionReason returns what's known about why the given previous
+// leader isn't the leader anymore, from its Serf status.
+func (s *Server) leaderTransitionReason(previous string) string {
+	if previous == "" {
+		return ""
+	}
+	if previous == s.config.NodeName {
+		return "elected again after losing leadership"
+	}
+	for _, m := range s.serfLAN.Members() {
+		if m.Name != previous {
+			continue
+		}
+		switch m.Status {
+		case serf.StatusLeaving, serf.StatusLeft:
+			return "previous leader left the cluster"
+		case serf.StatusFailed:
+			return "previous leader failed"
+		default:
+			return "previous leader lost leadership while still a member"
+		}
+	}
+	return "previous leader is no longer a member"
+}
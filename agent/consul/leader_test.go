@@ -14,6 +14,7 @@ import (
 	"github.com/hashicorp/net-rpc-msgpackrpc"
 	"github.com/hashicorp/serf/serf"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 func TestLeader_RegisterMember(t *testing.T) {
@@ -233,11 +234,13 @@ func TestLeader_ReapMember(t *testing.T) {
 	for _, m := range mems {
 		if m.Name == c1.config.NodeName {
 			c1mem = m
-			c1mem.Status = StatusReap
 			break
 		}
 	}
-	s1.reconcileCh <- c1mem
+	s1.memberEvents.Publish(serf.MemberEvent{
+		Type:    serf.EventMemberReap,
+		Members: []serf.Member{c1mem},
+	})
 
 	// Should be deregistered; we have to poll quickly here because
 	// anti-entropy will put it back.
@@ -257,6 +260,83 @@ func TestLeader_ReapMember(t *testing.T) {
 	}
 }
 
+func TestLeader_ReapMember_PanicThreshold(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		// Keep the reconcile loop from reaping the nodes we register below.
+		c.ReconcileInterval = time.Hour
+		c.ReconcilePanicThreshold = 0.5
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	register := func(node, status string) {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.2",
+			Check: &structs.HealthCheck{
+				Node:    node,
+				CheckID: structs.SerfCheckID,
+				Name:    structs.SerfCheckName,
+				Status:  status,
+			},
+		}
+		var out struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// With two of the three nodes failing, reaped nodes should be kept.
+	register("foo", api.HealthCritical)
+	register("bar", api.HealthCritical)
+	retry.Run(t, func(r *retry.R) {
+		_, checks, err := s1.fsm.State().ChecksInState(nil, api.HealthPassing)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if len(checks) != 1 {
+			r.Fatalf("leader not registered: %#v", checks)
+		}
+	})
+
+	member := serf.Member{
+		Name: "foo",
+		Tags: map[string]string{
+			"dc":   "dc1",
+			"role": "node",
+		},
+	}
+	if err := s1.handleReapMember(member); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, node, err := s1.fsm.State().GetNode("foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if node == nil {
+		t.Fatalf("node should not have been deregistered")
+	}
+
+	// Once enough nodes recover, reaped nodes are deregistered again.
+	register("bar", api.HealthPassing)
+	if err := s1.handleReapMember(member); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, node, err = s1.fsm.State().GetNode("foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if node != nil {
+		t.Fatalf("node should have been deregistered")
+	}
+}
+
 func TestLeader_ReapServer(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
@@ -317,7 +397,7 @@ func TestLeader_ReapServer(t *testing.T) {
 	knownMembers[s1.config.NodeName] = struct{}{}
 	knownMembers[s2.config.NodeName] = struct{}{}
 
-	err := s1.reconcileReaped(knownMembers)
+	err := s1.reconcileReaped(knownMembers, newReconcileQueue())
 
 	if err != nil {
 		t.Fatalf("Unexpected error :%v", err)
@@ -370,7 +450,7 @@ func TestLeader_Reconcile_ReapMember(t *testing.T) {
 	}
 
 	// Force a reconciliation
-	if err := s1.reconcile(); err != nil {
+	if err := s1.reconcile(newReconcileQueue()); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -385,6 +465,62 @@ func TestLeader_Reconcile_ReapMember(t *testing.T) {
 	}
 }
 
+func TestLeader_Reconcile_Throttled(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ReconcileRate = rate.Limit(0.0001)
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register members that are no longer around.
+	for _, name := range []string{"dead1", "dead2"} {
+		dead := structs.RegisterRequest{
+			Datacenter: s1.config.Datacenter,
+			Node:       name,
+			Address:    "127.1.1.1",
+			Check: &structs.HealthCheck{
+				Node:    name,
+				CheckID: structs.SerfCheckID,
+				Name:    structs.SerfCheckName,
+				Status:  api.HealthCritical,
+			},
+		}
+		var out struct{}
+		if err := s1.RPC("Catalog.Register", &dead, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Use up the reconcile burst.
+	for s1.reconcileLimiter.Allow() {
+	}
+
+	// A full reconcile should queue the members instead of writing.
+	queue := newReconcileQueue()
+	if err := s1.reconcile(queue); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if queue.Len() != 2 {
+		t.Fatalf("bad: %d", queue.Len())
+	}
+	for _, name := range []string{"dead1", "dead2"} {
+		member, _ := queue.Pop()
+		if member.Name != name || member.Status != StatusReap {
+			t.Fatalf("bad: %v", member)
+		}
+		_, node, err := s1.fsm.State().GetNode(name)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if node == nil {
+			t.Fatalf("node %q should still be registered", name)
+		}
+	}
+}
+
 func TestLeader_Reconcile(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
@@ -477,7 +613,7 @@ func TestLeader_Reconcile_Races(t *testing.T) {
 	}
 
 	// Force a reconcile and make sure the metadata stuck around.
-	if err := s1.reconcile(); err != nil {
+	if err := s1.reconcile(newReconcileQueue()); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	_, node, err := state.GetNode(c1.config.NodeName)
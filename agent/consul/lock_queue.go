@@ -0,0 +1,161 @@
+package consul
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// lockQueueHeadWait is how long a free lock waits for the session at
+	// the head of its queue to acquire it before that session is skipped,
+	// so a contender that went away without destroying its session can't
+	// hold everyone else up.
+	lockQueueHeadWait = 10 * time.Second
+
+	// lockQueueReapInterval is how often queues are checked for sessions
+	// that no longer exist, at most.
+	lockQueueReapInterval = time.Minute
+)
+
+// lockQueue is the line of sessions waiting to acquire a single key.
+type lockQueue struct {
+	// sessions are the waiting sessions, in the order they first tried to
+	// acquire the key.
+	sessions []string
+
+	// freeSince is when the key was first seen free while its head session
+	// hadn't acquired it, or zero.
+	freeSince time.Time
+}
+
+// lockQueues keeps a wait queue for each key contended for by lock requests
+// that asked to be queued, so they acquire the key in the order they first
+// tried to. Queues are only kept in memory, by the leader, which handles all
+// the lock requests.
+type lockQueues struct {
+	queues   map[string]*lockQueue
+	lastReap time.Time
+	lock     sync.Mutex
+}
+
+// newLockQueues returns an empty set of queues.
+func newLockQueues() *lockQueues {
+	return &lockQueues{
+		queues: make(map[string]*lockQueue),
+	}
+}
+
+// admit adds the session to the queue of the key if it isn't in it yet, and
+// returns whether it may try to acquire the key now. That's the case if it
+// already holds the key, or if the key is free and the session is at the
+// head of the queue. holder is the session holding the key, if any, and
+// valid reports whether a session still exists, so the sessions that don't
+// can be dropped.
+func (l *lockQueues) admit(key, session, holder string, valid func(string) bool, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastReap) >= lockQueueReapInterval {
+		for k, q := range l.queues {
+			q.prune(valid)
+			if len(q.sessions) == 0 {
+				delete(l.queues, k)
+			}
+		}
+		l.lastReap = now
+	}
+
+	// A holder re-acquiring its own lock doesn't wait, or join the queue
+	// where it would stall the others once it releases the lock.
+	if holder == session {
+		return true
+	}
+
+	q, ok := l.queues[key]
+	if !ok {
+		q = &lockQueue{}
+		l.queues[key] = q
+	}
+	q.prune(valid)
+	if q.position(session) < 0 {
+		q.sessions = append(q.sessions, session)
+	}
+
+	if holder != "" {
+		q.freeSince = time.Time{}
+		return false
+	}
+
+	// The key is free, skip the head if it's had long enough to take it.
+	if q.sessions[0] != session {
+		if q.freeSince.IsZero() {
+			q.freeSince = now
+		} else if now.Sub(q.freeSince) >= lockQueueHeadWait {
+			q.sessions = q.sessions[1:]
+			q.freeSince = now
+		}
+	}
+	return q.sessions[0] == session
+}
+
+// acquired removes the session from the queue of the key once it has
+// acquired it.
+func (l *lockQueues) acquired(key, session string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	q, ok := l.queues[key]
+	if !ok {
+		return
+	}
+	if i := q.position(session); i >= 0 {
+		q.sessions = append(q.sessions[:i], q.sessions[i+1:]...)
+	}
+	q.freeSince = time.Time{}
+	if len(q.sessions) == 0 {
+		delete(l.queues, key)
+	}
+}
+
+// sessions returns the sessions waiting for the key, in order.
+func (l *lockQueues) sessions(key string) []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	q, ok := l.queues[key]
+	if !ok {
+		return nil
+	}
+	sessions := make([]string, len(q.sessions))
+	copy(sessions, q.sessions)
+	return sessions
+}
+
+// reset drops all the queues, when the server stops being the leader.
+func (l *lockQueues) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.queues = make(map[string]*lockQueue)
+}
+
+// position returns the index of the session in the queue, or -1 if it isn't
+// in it.
+func (q *lockQueue) position(session string) int {
+	for i, s := range q.sessions {
+		if s == session {
+			return i
+		}
+	}
+	return -1
+}
+
+// prune drops the sessions that no longer exist.
+func (q *lockQueue) prune(valid func(string) bool) {
+	sessions := q.sessions[:0]
+	for _, s := range q.sessions {
+		if valid(s) {
+			sessions = append(sessions, s)
+		}
+	}
+	q.sessions = sessions
+}
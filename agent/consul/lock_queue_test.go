@@ -0,0 +1,53 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockQueues(t *testing.T) {
+	t.Parallel()
+
+	l := newLockQueues()
+	now := time.Now()
+	gone := map[string]bool{}
+	valid := func(id string) bool { return !gone[id] }
+
+	// The first contender gets the free key.
+	require.True(t, l.admit("lock", "a", "", valid, now))
+	l.acquired("lock", "a")
+	require.Empty(t, l.sessions("lock"))
+
+	// The others wait in line while it's held, in the order they came.
+	require.False(t, l.admit("lock", "b", "a", valid, now))
+	require.False(t, l.admit("lock", "c", "a", valid, now))
+	require.False(t, l.admit("lock", "b", "a", valid, now))
+	require.Equal(t, []string{"b", "c"}, l.sessions("lock"))
+
+	// The holder can always re-acquire.
+	require.True(t, l.admit("lock", "a", "a", valid, now))
+
+	// Once it's free only the head gets it.
+	require.False(t, l.admit("lock", "c", "", valid, now))
+	require.True(t, l.admit("lock", "b", "", valid, now))
+	l.acquired("lock", "b")
+	require.Equal(t, []string{"c"}, l.sessions("lock"))
+
+	// Sessions that no longer exist are dropped.
+	require.False(t, l.admit("lock", "d", "b", valid, now))
+	gone["c"] = true
+	require.True(t, l.admit("lock", "d", "", valid, now))
+	require.Equal(t, []string{"d"}, l.sessions("lock"))
+
+	// A head that doesn't take the free key is skipped after a while.
+	require.False(t, l.admit("lock", "e", "", valid, now))
+	require.False(t, l.admit("lock", "e", "", valid, now.Add(lockQueueHeadWait/2)))
+	require.True(t, l.admit("lock", "e", "", valid, now.Add(lockQueueHeadWait)))
+	require.Equal(t, []string{"e"}, l.sessions("lock"))
+
+	// Queues are dropped when leadership is lost.
+	l.reset()
+	require.Empty(t, l.sessions("lock"))
+}
@@ -0,0 +1,172 @@
+package consul
+
+import (
+	"sync"
+	"sync/atomic"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/serf/serf"
+)
+
+const (
+	// memberEventHistorySize is how many of the latest member events the
+	// bus keeps to replay to new subscribers.
+	memberEventHistorySize = 256
+
+	// memberEventChSize is the size of the buffered channel of the
+	// subscribers that don't need as much as the reconciler.
+	memberEventChSize = 64
+)
+
+// memberEventBus fans the LAN Serf member events out to the parts of the
+// server that act on them, like the leader's reconciler, so the Serf event
+// handler doesn't need to know about them. Each subscriber has its own
+// buffered channel. Publishing never blocks Serf: when a subscriber falls
+// behind its events are dropped and counted, and it's up to the subscriber
+// to catch up another way, like the reconciler does with a full reconcile.
+type memberEventBus struct {
+	// subs are the current subscribers.
+	subs map[*memberEventSub]struct{}
+
+	// history holds the latest events, oldest first, to replay to new
+	// subscribers that ask for it.
+	history []serf.MemberEvent
+
+	lock sync.Mutex
+}
+
+// memberEventSub is a subscription to the member events of some types.
+type memberEventSub struct {
+	// name identifies the subscriber in metrics.
+	name string
+
+	// types are the event types the subscriber wants, or all of them if
+	// empty.
+	types map[serf.EventType]bool
+
+	// ch is where the events are delivered.
+	ch chan serf.MemberEvent
+
+	// dropped counts the events that didn't fit in ch since the last call
+	// to takeDropped. This is updated atomically.
+	dropped uint64
+}
+
+// newMemberEventBus returns a bus with no subscribers.
+func newMemberEventBus() *memberEventBus {
+	return &memberEventBus{
+		subs: make(map[*memberEventSub]struct{}),
+	}
+}
+
+// Publish delivers the event to the subscribers that want its type, and
+// records it in the history.
+func (b *memberEventBus) Publish(e serf.MemberEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.history = append(b.history, e)
+	if len(b.history) > memberEventHistorySize {
+		b.history = b.history[len(b.history)-memberEventHistorySize:]
+	}
+
+	for sub := range b.subs {
+		sub.deliver(e)
+	}
+}
+
+// Subscribe returns a subscription to the events of the given types, or of
+// all types if none are given, with a buffer of the given size. If replay
+// is true, the events in the history are delivered first, as far as they
+// fit in the buffer.
+func (b *memberEventBus) Subscribe(name string, size int, replay bool, types ...serf.EventType) *memberEventSub {
+	sub := &memberEventSub{
+		name: name,
+		ch:   make(chan serf.MemberEvent, size),
+	}
+	if len(types) > 0 {
+		sub.types = make(map[serf.EventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if replay {
+		history := b.history
+		if len(history) > size {
+			history = history[len(history)-size:]
+		}
+		for _, e := range history {
+			sub.deliver(e)
+		}
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivering events to the subscription. Events already
+// in its buffer can still be read.
+func (b *memberEventBus) Unsubscribe(sub *memberEventSub) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.subs, sub)
+}
+
+// Events returns the channel the subscription's events are delivered on.
+func (sub *memberEventSub) Events() <-chan serf.MemberEvent {
+	return sub.ch
+}
+
+// takeDropped returns how many events were dropped because the buffer was
+// full since the last call, and resets the count.
+func (sub *memberEventSub) takeDropped() uint64 {
+	return atomic.SwapUint64(&sub.dropped, 0)
+}
+
+// deliver sends the event to the subscription if it wants its type, without
+// blocking. The bus lock must be held.
+func (sub *memberEventSub) deliver(e serf.MemberEvent) {
+	if sub.types != nil && !sub.types[e.EventType()] {
+		return
+	}
+	select {
+	case sub.ch <- e:
+	default:
+		atomic.AddUint64(&sub.dropped, 1)
+		metrics.IncrCounterWithLabels([]string{"member_events", "dropped"}, 1,
+			[]metrics.Label{{Name: "subscriber", Value: sub.name}})
+	}
+}
+
+// reconcileMembers returns the members of the event in the form the leader
+// reconciles them, with the status of reaped members set to StatusReap.
+func reconcileMembers(e serf.MemberEvent) []serf.Member {
+	members := make([]serf.Member, len(e.Members))
+	copy(members, e.Members)
+	if e.EventType() == serf.EventMemberReap {
+		for i := range members {
+			members[i].Status = StatusReap
+		}
+	}
+	return members
+}
+
+// memberEventMetrics counts the LAN member events by type, for as long as
+// the server runs.
+func (s *Server) memberEventMetrics() {
+	sub := s.memberEvents.Subscribe("metrics", memberEventChSize, false)
+	defer s.memberEvents.Unsubscribe(sub)
+
+	for {
+		select {
+		case e := <-sub.Events():
+			metrics.IncrCounterWithLabels([]string{"member_events", "received"}, float32(len(e.Members)),
+				[]metrics.Label{{Name: "type", Value: e.EventType().String()}})
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/serf/serf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberEventBus(t *testing.T) {
+	t.Parallel()
+
+	event := func(t serf.EventType, name string) serf.MemberEvent {
+		return serf.MemberEvent{Type: t, Members: []serf.Member{{Name: name}}}
+	}
+	names := func(sub *memberEventSub) []string {
+		var names []string
+		for {
+			select {
+			case e := <-sub.Events():
+				names = append(names, e.Members[0].Name)
+			default:
+				return names
+			}
+		}
+	}
+
+	b := newMemberEventBus()
+	b.Publish(event(serf.EventMemberJoin, "a"))
+	b.Publish(event(serf.EventMemberLeave, "b"))
+
+	// Subscribers only get the types they asked for, and the history only
+	// if they asked for it.
+	all := b.Subscribe("all", 2, true)
+	leaves := b.Subscribe("leaves", 10, false, serf.EventMemberLeave)
+	require.Equal(t, []string{"a", "b"}, names(all))
+	require.Empty(t, names(leaves))
+
+	b.Publish(event(serf.EventMemberLeave, "c"))
+	b.Publish(event(serf.EventMemberJoin, "d"))
+	require.Equal(t, []string{"c", "d"}, names(all))
+	require.Equal(t, []string{"c"}, names(leaves))
+
+	// Events that don't fit are dropped and counted.
+	b.Publish(event(serf.EventMemberJoin, "e"))
+	b.Publish(event(serf.EventMemberJoin, "f"))
+	b.Publish(event(serf.EventMemberJoin, "g"))
+	require.Equal(t, uint64(1), all.takeDropped())
+	require.Equal(t, uint64(0), all.takeDropped())
+	require.Equal(t, []string{"e", "f"}, names(all))
+
+	// Only the end of the history that fits is replayed.
+	late := b.Subscribe("late", 3, true)
+	require.Equal(t, []string{"e", "f", "g"}, names(late))
+	require.Equal(t, uint64(0), late.takeDropped())
+
+	// Unsubscribed subscribers don't get anything.
+	b.Unsubscribe(all)
+	b.Publish(event(serf.EventMemberJoin, "h"))
+	require.Empty(t, names(all))
+}
+
+func TestReconcileMembers(t *testing.T) {
+	t.Parallel()
+
+	members := []serf.Member{
+		{Name: "a", Status: serf.StatusLeft},
+		{Name: "b", Status: serf.StatusFailed},
+	}
+	e := serf.MemberEvent{Type: serf.EventMemberLeave, Members: members}
+	require.Equal(t, members, reconcileMembers(e))
+
+	// Reaped members are marked as such, without changing the event.
+	e.Type = serf.EventMemberReap
+	reaped := reconcileMembers(e)
+	require.Len(t, reaped, 2)
+	for _, m := range reaped {
+		require.Equal(t, StatusReap, m.Status)
+	}
+	require.Equal(t, serf.StatusLeft, e.Members[0].Status)
+}
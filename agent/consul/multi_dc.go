@@ -0,0 +1,80 @@
+package consul
+
+import (
+	"sync"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// sortDatacentersByDistance returns the given datacenters without duplicates,
+// sorted by the estimated RTT from this server using the WAN coordinates, so
+// the local datacenter comes first. Datacenters we don't know about are put
+// at the end, in the order they were given.
+func (s *Server) sortDatacentersByDistance(dcs []string) ([]string, error) {
+	known, err := s.router.GetDatacentersByDistance()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, dc := range dcs {
+		wanted[dc] = true
+	}
+
+	sorted := make([]string, 0, len(wanted))
+	for _, dc := range known {
+		if wanted[dc] {
+			sorted = append(sorted, dc)
+			delete(wanted, dc)
+		}
+	}
+	for _, dc := range dcs {
+		if wanted[dc] {
+			sorted = append(sorted, dc)
+			delete(wanted, dc)
+		}
+	}
+	return sorted, nil
+}
+
+// forwardMultiDC sends a service query to each of the given datacenters other
+// than our own in parallel, and returns the replies by datacenter. The remote
+// queries don't block or fail over to other datacenters. Datacenters that
+// can't be queried are logged and left out of the replies, so one unreachable
+// datacenter doesn't fail the whole query.
+func (s *Server) forwardMultiDC(method string, dcs []string, args *structs.ServiceSpecificRequest, newReply func() interface{}) map[string]interface{} {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	replies := make(map[string]interface{})
+	for _, dc := range dcs {
+		if dc == s.config.Datacenter {
+			continue
+		}
+
+		wg.Add(1)
+		go func(dc string) {
+			defer wg.Done()
+
+			remoteArgs := *args
+			remoteArgs.Datacenter = dc
+			remoteArgs.MultiDC = nil
+			remoteArgs.SkipFailover = true
+			remoteArgs.MinQueryIndex = 0
+
+			reply := newReply()
+			if err := s.forwardDC(method, dc, &remoteArgs, reply); err != nil {
+				s.logger.Printf("[WARN] consul.rpc: Failed querying for service '%s' in datacenter '%s': %s", args.ServiceName, dc, err)
+				metrics.IncrCounterWithLabels([]string{"rpc", "multi-dc", "failed"}, 1,
+					[]metrics.Label{{Name: "datacenter", Value: dc}})
+				return
+			}
+
+			lock.Lock()
+			replies[dc] = reply
+			lock.Unlock()
+		}(dc)
+	}
+	wg.Wait()
+	return replies
+}
@@ -0,0 +1,136 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+var (
+	// ErrNamespaceNotFound is returned if the namespace lookup failed.
+	ErrNamespaceNotFound = errors.New("Namespace not found")
+)
+
+// Namespace manages the namespace registry. Namespaces are only recorded, and
+// aren't used by any other endpoint.
+type Namespace struct {
+	// srv is a pointer back to the server.
+	srv *Server
+}
+
+// Apply creates, updates or deletes a namespace. Managing namespaces requires
+// operator write privileges.
+func (n *Namespace) Apply(args *structs.NamespaceRequest, reply *struct{}) error {
+	if done, err := n.srv.forward("Namespace.Apply", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"namespace", "apply"}, time.Now())
+
+	rule, err := n.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorWrite() {
+		return acl.ErrPermissionDenied
+	}
+
+	if args.Namespace == nil {
+		return fmt.Errorf("Missing namespace")
+	}
+	switch args.Op {
+	case structs.NamespaceOpUpsert:
+		if err := args.Namespace.Validate(); err != nil {
+			return err
+		}
+	case structs.NamespaceOpDelete:
+		if strings.EqualFold(args.Namespace.Name, structs.NamespaceDefault) {
+			return fmt.Errorf("Cannot delete the default namespace")
+		}
+	default:
+		return fmt.Errorf("Invalid namespace operation %q", args.Op)
+	}
+
+	resp, err := n.srv.raftApply(structs.NamespaceRequestType, args)
+	if err != nil {
+		n.srv.logger.Printf("[ERR] consul.namespace: Apply failed: %v", err)
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
+
+// Get returns a single namespace by name.
+func (n *Namespace) Get(args *structs.NamespaceSpecificRequest, reply *structs.IndexedNamespaces) error {
+	if done, err := n.srv.forward("Namespace.Get", args, args, reply); done {
+		return err
+	}
+
+	rule, err := n.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	// Nothing else takes a namespace, so there's no request an empty name
+	// could fall back to the default namespace for.
+	if args.Name == "" {
+		return fmt.Errorf("Missing namespace name")
+	}
+	return n.srv.blockingQuery(
+		"namespaces",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, ns, err := state.NamespaceGet(ws, args.Name)
+			if err != nil {
+				return err
+			}
+			if ns == nil {
+				return ErrNamespaceNotFound
+			}
+
+			reply.Index = index
+			reply.Namespaces = structs.Namespaces{ns}
+			return nil
+		})
+}
+
+// List returns all the namespaces, including the default namespace.
+func (n *Namespace) List(args *structs.DCSpecificRequest, reply *structs.IndexedNamespaces) error {
+	if done, err := n.srv.forward("Namespace.List", args, args, reply); done {
+		return err
+	}
+
+	rule, err := n.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	return n.srv.blockingQuery(
+		"namespaces",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, namespaces, err := state.Namespaces(ws)
+			if err != nil {
+				return err
+			}
+
+			reply.Index, reply.Namespaces = index, namespaces
+			return nil
+		})
+}
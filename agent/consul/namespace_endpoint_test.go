@@ -0,0 +1,113 @@
+package consul
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace_Apply(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a namespace.
+	arg := structs.NamespaceRequest{
+		Datacenter: "dc1",
+		Op:         structs.NamespaceOpUpsert,
+		Namespace:  &structs.Namespace{Name: "eng", Description: "Engineering"},
+	}
+	var reply struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.Apply", &arg, &reply))
+
+	// Read it back.
+	get := structs.NamespaceSpecificRequest{Datacenter: "dc1", Name: "eng"}
+	var out structs.IndexedNamespaces
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.Get", &get, &out))
+	require.Len(out.Namespaces, 1)
+	require.Equal("Engineering", out.Namespaces[0].Description)
+
+	// The default namespace is always there, but has to be asked for by name.
+	get.Name = structs.NamespaceDefault
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.Get", &get, &out))
+	require.Equal(structs.NamespaceDefault, out.Namespaces[0].Name)
+	get.Name = ""
+	err := msgpackrpc.CallWithCodec(codec, "Namespace.Get", &get, &out)
+	require.Error(err)
+	require.Contains(err.Error(), "Missing namespace name")
+
+	list := structs.DCSpecificRequest{Datacenter: "dc1"}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.List", &list, &out))
+	require.Len(out.Namespaces, 2)
+
+	// Invalid names are rejected.
+	arg.Namespace = &structs.Namespace{Name: "-bad"}
+	err = msgpackrpc.CallWithCodec(codec, "Namespace.Apply", &arg, &reply)
+	require.Error(err)
+	require.Contains(err.Error(), "Invalid namespace name")
+
+	// The default namespace can't be deleted.
+	arg.Op = structs.NamespaceOpDelete
+	arg.Namespace = &structs.Namespace{Name: structs.NamespaceDefault}
+	err = msgpackrpc.CallWithCodec(codec, "Namespace.Apply", &arg, &reply)
+	require.Error(err)
+
+	// Delete the namespace.
+	arg.Namespace = &structs.Namespace{Name: "eng"}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.Apply", &arg, &reply))
+	get.Name = "eng"
+	err = msgpackrpc.CallWithCodec(codec, "Namespace.Get", &get, &out)
+	require.Error(err)
+	require.Equal(ErrNamespaceNotFound.Error(), err.Error())
+}
+
+func TestNamespace_ACLDeny(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.NamespaceRequest{
+		Datacenter: "dc1",
+		Op:         structs.NamespaceOpUpsert,
+		Namespace:  &structs.Namespace{Name: "eng"},
+	}
+	var reply struct{}
+	err := msgpackrpc.CallWithCodec(codec, "Namespace.Apply", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	list := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var out structs.IndexedNamespaces
+	err = msgpackrpc.CallWithCodec(codec, "Namespace.List", &list, &out)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	// The master token is allowed.
+	arg.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.Apply", &arg, &reply))
+	list.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Namespace.List", &list, &out))
+	require.Len(out.Namespaces, 2)
+}
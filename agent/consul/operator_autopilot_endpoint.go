@@ -2,6 +2,7 @@ package consul
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent/consul/autopilot"
@@ -98,6 +99,7 @@ func (op *Operator) ServerHealth(args *structs.DCSpecificRequest, reply *autopil
 	}
 
 	*reply = op.srv.autopilot.GetClusterHealth()
+	reply.RecentLeaderElections, reply.LeaderFlapping = op.srv.leaderFlap.status(time.Now())
 
 	return nil
 }
@@ -262,6 +262,38 @@ func TestOperator_ServerHealth(t *testing.T) {
 	})
 }
 
+func TestOperator_ServerHealth_LeaderFlapping(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc1"
+		c.RaftConfig.ProtocolVersion = 3
+		c.LeaderFlapThreshold = 3
+		c.LeaderFlapWindow = time.Hour
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Fake enough elections to reach the threshold.
+	for i := 0; i < 3; i++ {
+		s1.leaderFlap.recordElection(time.Now())
+	}
+
+	arg := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	var reply autopilot.OperatorHealthReply
+	if err := msgpackrpc.CallWithCodec(codec, "Operator.ServerHealth", &arg, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if reply.RecentLeaderElections < 3 || !reply.LeaderFlapping {
+		t.Fatalf("bad: %v", reply)
+	}
+}
+
 func TestOperator_ServerHealth_UnsupportedRaftVersion(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// CatalogConsistency returns the findings of the last catalog consistency
+// check run by the leader.
+func (op *Operator) CatalogConsistency(args *structs.DCSpecificRequest, reply *structs.CatalogConsistencyReport) error {
+	// This must be sent to the leader, so we fix the args since we are
+	// re-using a structure where we don't support all the options.
+	args.RequireConsistent = true
+	args.AllowStale = false
+	if done, err := op.srv.forward("Operator.CatalogConsistency", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	*reply = op.srv.getCatalogCheckReport()
+	return nil
+}
+
+// CatalogExport returns a point-in-time export of the catalog and KV store.
+func (op *Operator) CatalogExport(args *structs.DCSpecificRequest, reply *structs.CatalogExport) error {
+	if done, err := op.srv.forward("Operator.CatalogExport", args, args, reply); done {
+		return err
+	}
+
+	// This exports everything a snapshot has about the catalog and KV
+	// store, so it requires the same access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.Snapshot() {
+		return acl.ErrPermissionDenied
+	}
+
+	snap := op.srv.fsm.State().Snapshot()
+	defer snap.Close()
+	export, err := catalogExport(snap, op.srv.config.Datacenter)
+	if err != nil {
+		return err
+	}
+	*reply = *export
+	return nil
+}
+
+// catalogExport builds an export from a state store snapshot, so the nodes,
+// services, checks and keys are all consistent with each other.
+func catalogExport(snap *state.Snapshot, dc string) (*structs.CatalogExport, error) {
+	export := &structs.CatalogExport{
+		Version:    structs.CatalogExportVersion,
+		Datacenter: dc,
+		Index:      snap.LastIndex(),
+	}
+
+	nodes, err := snap.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	for raw := nodes.Next(); raw != nil; raw = nodes.Next() {
+		n := raw.(*structs.Node)
+		node := &structs.CatalogExportNode{
+			ID:              n.ID,
+			Node:            n.Node,
+			Address:         n.Address,
+			TaggedAddresses: n.TaggedAddresses,
+			Meta:            n.Meta,
+			Services:        []*structs.NodeService{},
+			Checks:          structs.HealthChecks{},
+		}
+
+		services, err := snap.Services(n.Node)
+		if err != nil {
+			return nil, err
+		}
+		for raw := services.Next(); raw != nil; raw = services.Next() {
+			svc := raw.(*structs.ServiceNode).ToNodeService()
+			svc.RaftIndex = structs.RaftIndex{}
+			node.Services = append(node.Services, svc)
+		}
+
+		checks, err := snap.Checks(n.Node)
+		if err != nil {
+			return nil, err
+		}
+		for raw := checks.Next(); raw != nil; raw = checks.Next() {
+			check := raw.(*structs.HealthCheck).Clone()
+			check.RaftIndex = structs.RaftIndex{}
+			node.Checks = append(node.Checks, check)
+		}
+		export.Nodes = append(export.Nodes, node)
+	}
+
+	entries, err := snap.KVs()
+	if err != nil {
+		return nil, err
+	}
+	for raw := entries.Next(); raw != nil; raw = entries.Next() {
+		e := raw.(*structs.DirEntry)
+		export.KV = append(export.KV, &structs.CatalogExportKV{
+			Key:   e.Key,
+			Flags: e.Flags,
+			Value: e.Value,
+		})
+	}
+	return export, nil
+}
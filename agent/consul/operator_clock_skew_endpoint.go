@@ -0,0 +1,29 @@
+package consul
+
+import (
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// ClockSkew returns how far the clocks of the nodes are from the leader's,
+// as measured from their coordinate updates.
+func (op *Operator) ClockSkew(args *structs.DCSpecificRequest, reply *structs.ClockSkewReport) error {
+	// The skews are only kept on the leader, so this must be sent there.
+	args.RequireConsistent = true
+	args.AllowStale = false
+	if done, err := op.srv.forward("Operator.ClockSkew", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	*reply = op.srv.getClockSkewReport()
+	return nil
+}
@@ -0,0 +1,49 @@
+package consul
+
+import (
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// ServerFeatures reports which of the optional protocol features are enabled
+// in the datacenter, and which servers are holding back the others. During a
+// rolling upgrade, features stay disabled until the last old server has been
+// upgraded.
+func (op *Operator) ServerFeatures(args *structs.DCSpecificRequest, reply *structs.ServerFeaturesResponse) error {
+	if done, err := op.srv.forward("Operator.ServerFeatures", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	gated := make(map[string]bool)
+	for _, feature := range fsmMessageFeatures {
+		gated[feature] = true
+	}
+
+	members := op.srv.serfLAN.Members()
+	for _, feature := range metadata.SupportedFeatures() {
+		entry := structs.ServerFeature{
+			Name:            feature,
+			GatesRaftWrites: gated[feature],
+		}
+		for _, server := range serversMissingFeature(members, op.srv.config.Datacenter, feature) {
+			entry.BlockedBy = append(entry.BlockedBy, structs.ServerFeatureBlocker{
+				Node:    server.Name,
+				Address: server.Addr.String(),
+				Version: server.Build.String(),
+			})
+		}
+		entry.Enabled = len(entry.BlockedBy) == 0
+		reply.Features = append(reply.Features, entry)
+	}
+	return nil
+}
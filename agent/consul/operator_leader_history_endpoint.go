@@ -0,0 +1,37 @@
+package consul
+
+import (
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+// LeaderHistory returns the recorded leader transitions, newest first.
+func (op *Operator) LeaderHistory(args *structs.DCSpecificRequest, reply *structs.IndexedLeaderTransitions) error {
+	if done, err := op.srv.forward("Operator.LeaderHistory", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	return op.srv.blockingQuery(
+		"leader-history",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, transitions, err := state.LeaderHistory(ws)
+			if err != nil {
+				return err
+			}
+			reply.Index, reply.Transitions = index, transitions
+			return nil
+		})
+}
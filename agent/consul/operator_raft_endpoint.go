@@ -202,3 +202,32 @@ REMOVE:
 	op.srv.logger.Printf("[WARN] consul.operator: Removed Raft peer with id %q", args.ID)
 	return nil
 }
+
+// RaftVerify is used to check the Raft log and latest snapshot on disk for
+// corruption. The leader does the check unless this is a stale request, in
+// which case the server that gets the request checks its own storage. This
+// reads the whole log and snapshot, so it can take a while on large clusters.
+func (op *Operator) RaftVerify(args *structs.DCSpecificRequest, reply *structs.RaftVerifyResponse) error {
+	if done, err := op.srv.forward("Operator.RaftVerify", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	result, err := verifyRaft(op.srv.raftLog, op.srv.raftSnapshots)
+	if err != nil {
+		op.srv.logger.Printf("[ERR] consul.operator: Raft storage failed verification: %v", err)
+		return fmt.Errorf("Raft storage failed verification on %s: %v", op.srv.config.NodeName, err)
+	}
+
+	*reply = *result
+	reply.Node = op.srv.config.NodeName
+	return nil
+}
@@ -11,9 +11,11 @@ import (
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/lib/freeport"
 	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
 	"github.com/hashicorp/raft"
 	"github.com/pascaldekloe/goe/verify"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOperator_RaftGetConfiguration(t *testing.T) {
@@ -370,3 +372,94 @@ func TestOperator_RaftRemovePeerByID_ACLDeny(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestOperator_RaftVerify(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForTestAgent(t, s1.RPC, "dc1")
+
+	// Make sure there's a snapshot to verify.
+	require.NoError(s1.raft.Snapshot().Error())
+
+	// Operator read access is required.
+	arg := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	var reply structs.RaftVerifyResponse
+	err := msgpackrpc.CallWithCodec(codec, "Operator.RaftVerify", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	arg.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Operator.RaftVerify", &arg, &reply))
+	require.Equal(s1.config.NodeName, reply.Node)
+	require.NotEmpty(reply.SnapshotID)
+	require.True(reply.SnapshotIndex > 0)
+	require.True(reply.LastIndex >= reply.SnapshotIndex)
+}
+
+func TestOperator_LeaderHistory(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForTestAgent(t, s1.RPC, "dc1")
+
+	// Operator read access is required.
+	arg := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	var reply structs.IndexedLeaderTransitions
+	err := msgpackrpc.CallWithCodec(codec, "Operator.LeaderHistory", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	// The first election is recorded without a previous leader.
+	arg.Token = "root"
+	retry.Run(t, func(r *retry.R) {
+		if err := msgpackrpc.CallWithCodec(codec, "Operator.LeaderHistory", &arg, &reply); err != nil {
+			r.Fatal(err)
+		}
+		if len(reply.Transitions) != 1 {
+			r.Fatalf("bad: %v", reply.Transitions)
+		}
+	})
+	first := reply.Transitions[0]
+	require.Equal(s1.config.NodeName, first.Leader)
+	require.Empty(first.PreviousLeader)
+	require.Empty(first.Reason)
+	require.True(first.Term > 0)
+	require.False(first.Time.IsZero())
+
+	// Leadership is established again in the same term after a snapshot
+	// restore, which isn't a new transition.
+	s1.recordLeaderTransition()
+	require.NoError(msgpackrpc.CallWithCodec(codec, "Operator.LeaderHistory", &arg, &reply))
+	require.Len(reply.Transitions, 1)
+	require.Equal(first, reply.Transitions[0])
+
+	// The same server being elected in a later term says it was elected
+	// again.
+	require.Equal("elected again after losing leadership", s1.leaderTransitionReason(s1.config.NodeName))
+
+	// A previous leader that's not a member anymore is reported as such.
+	require.Equal("previous leader is no longer a member", s1.leaderTransitionReason("nope"))
+}
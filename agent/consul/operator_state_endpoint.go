@@ -0,0 +1,37 @@
+package consul
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// StateMemory returns the state store memory usage found by the last
+// accounting run on the leader. There are no runs without a budget, so it's
+// an error to ask then.
+func (op *Operator) StateMemory(args *structs.DCSpecificRequest, reply *structs.StateMemoryReport) error {
+	// This must be sent to the leader, so we fix the args since we are
+	// re-using a structure where we don't support all the options.
+	args.RequireConsistent = true
+	args.AllowStale = false
+	if done, err := op.srv.forward("Operator.StateMemory", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	rule, err := op.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	if op.srv.config.StateMemoryBudget <= 0 {
+		return fmt.Errorf("State store memory accounting is disabled, since there's no state memory budget")
+	}
+
+	*reply = op.srv.getStateMemoryReport()
+	return nil
+}
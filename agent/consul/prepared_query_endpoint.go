@@ -10,6 +10,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/metadata"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/go-uuid"
@@ -103,6 +104,13 @@ func (p *PreparedQuery) Apply(args *structs.PreparedQueryRequest, reply *string)
 			return fmt.Errorf("Invalid prepared query: %v", err)
 		}
 
+		// Older servers would drop the pin TTL from the stored query.
+		if args.Query.Service.PinTTL != "" {
+			if err := p.srv.checkDatacenterFeature(metadata.FeatureQueryPinning, "Pinning query results"); err != nil {
+				return err
+			}
+		}
+
 	case structs.PreparedQueryDelete:
 		// Nothing else to verify here, just do the delete (we only look
 		// at the ID field for this op).
@@ -186,6 +194,21 @@ func parseService(svc *structs.ServiceQuery) error {
 		return err
 	}
 
+	if svc.PinTTL != "" {
+		ttl, err := time.ParseDuration(svc.PinTTL)
+		if err != nil {
+			return fmt.Errorf("Bad PinTTL '%s': %v", svc.PinTTL, err)
+		}
+
+		if ttl < 0 {
+			return fmt.Errorf("Bad PinTTL '%s', must be >= 0", svc.PinTTL)
+		}
+
+		if ttl > structs.MaxQueryPinTTL {
+			return fmt.Errorf("Bad PinTTL '%s', must be <= %v", svc.PinTTL, structs.MaxQueryPinTTL)
+		}
+	}
+
 	// We skip a few fields:
 	// - There's no validation for Datacenters; we skip any unknown entries
 	//   at execution time.
@@ -220,6 +243,7 @@ func (p *PreparedQuery) Get(args *structs.PreparedQuerySpecificRequest,
 	}
 
 	return p.srv.blockingQuery(
+		"prepared-queries",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -264,6 +288,7 @@ func (p *PreparedQuery) List(args *structs.DCSpecificRequest, reply *structs.Ind
 	}
 
 	return p.srv.blockingQuery(
+		"prepared-queries",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -418,7 +443,7 @@ func (p *PreparedQuery) Execute(args *structs.PreparedQueryExecuteRequest,
 	}
 
 	// Perform the distance sort
-	err = p.srv.sortNodesByDistanceFrom(qs, reply.Nodes)
+	err = p.srv.sortNodesByDistanceFrom(nil, nil, qs, reply.Nodes)
 	if err != nil {
 		return err
 	}
@@ -440,6 +465,29 @@ func (p *PreparedQuery) Execute(args *structs.PreparedQueryExecuteRequest,
 		}
 	}
 
+	// Nodes in drain mode go last so the limit drops them first.
+	reply.Nodes.SortDrainedLast()
+
+	// Return the same subset to the source node as last time if the results
+	// are pinned. The request's TTL takes priority over the query's.
+	if args.PinTTL > structs.MaxQueryPinTTL {
+		return fmt.Errorf("Bad PinTTL '%v', must be <= %v", args.PinTTL, structs.MaxQueryPinTTL)
+	}
+	pinTTL := args.PinTTL
+	if pinTTL == 0 && query.Service.PinTTL != "" {
+		if pinTTL, err = time.ParseDuration(query.Service.PinTTL); err != nil {
+			return fmt.Errorf("Bad PinTTL '%s': %v", query.Service.PinTTL, err)
+		}
+	}
+	pinSource := args.Source.Node
+	if pinSource == "" {
+		pinSource = args.Agent.Node
+	}
+	if pinTTL > 0 && pinSource != "" {
+		reply.Nodes = p.srv.queryPins.apply(pinSource, queryPinKey(query),
+			pinTTL, args.Limit, reply.Nodes, time.Now())
+	}
+
 	// Apply the limit if given.
 	if args.Limit > 0 && len(reply.Nodes) > args.Limit {
 		reply.Nodes = reply.Nodes[:args.Limit]
@@ -497,6 +545,7 @@ func (p *PreparedQuery) ExecuteRemote(args *structs.PreparedQueryExecuteRemoteRe
 	// definition in another DC. We just shuffle to make sure that we
 	// balance the load across the results.
 	reply.Nodes.Shuffle()
+	reply.Nodes.SortDrainedLast()
 
 	// Apply the limit if given.
 	if args.Limit > 0 && len(reply.Nodes) > args.Limit {
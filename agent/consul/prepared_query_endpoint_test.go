@@ -594,6 +594,29 @@ func TestPreparedQuery_parseQuery(t *testing.T) {
 			t.Fatalf("err: %v", err)
 		}
 
+		query.Service.PinTTL = "a while"
+		err = parseQuery(query, version8)
+		if err == nil || !strings.Contains(err.Error(), "Bad PinTTL") {
+			t.Fatalf("bad: %v", err)
+		}
+
+		query.Service.PinTTL = "-1m"
+		err = parseQuery(query, version8)
+		if err == nil || !strings.Contains(err.Error(), "must be >= 0") {
+			t.Fatalf("bad: %v", err)
+		}
+
+		query.Service.PinTTL = "25h"
+		err = parseQuery(query, version8)
+		if err == nil || !strings.Contains(err.Error(), "must be <=") {
+			t.Fatalf("bad: %v", err)
+		}
+
+		query.Service.PinTTL = "1m"
+		if err := parseQuery(query, version8); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
 		query.DNS.TTL = "two fortnights"
 		err = parseQuery(query, version8)
 		if err == nil || !strings.Contains(err.Error(), "Bad DNS TTL") {
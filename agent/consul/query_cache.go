@@ -0,0 +1,168 @@
+package consul
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/hashicorp/golang-lru"
+)
+
+// queryCache caches the results of catalog and health list queries. A result
+// is only reused while the indexes of the tables it was read from haven't
+// changed, so it's never staler than running the query again. This mostly
+// helps popular services: when one changes, every client watching it wakes
+// up at once, and only the first of them has to scan the state store.
+//
+// Cached results are shared, so the helpers below return copies of the
+// slices and maps that callers filter and sort in place. The elements are
+// shared like the ones in the state store, and must not be modified.
+type queryCache struct {
+	lock    sync.Mutex
+	entries *lru.Cache
+}
+
+// queryCacheEntry is a cached query result. It's added to the cache before
+// the query runs, and ready is closed once the result is filled in, so
+// concurrent callers wait for the query instead of running it again.
+type queryCacheEntry struct {
+	store       *state.Store
+	tablesIndex uint64
+	ready       chan struct{}
+
+	index   uint64
+	result  interface{}
+	watches memdb.WatchSet
+	err     error
+}
+
+// newQueryCache returns a cache holding up to size results. It returns nil if
+// the size is zero, which disables caching.
+func newQueryCache(size int) (*queryCache, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	entries, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &queryCache{entries: entries}, nil
+}
+
+// get returns the result of the query with the given key, only running it if
+// any of the tables changed since the result was cached. The watches the
+// query added are added to the watch set on a hit as well, so blocking
+// queries wake up as if it ran. It's safe to call on a nil cache.
+func (c *queryCache) get(ws memdb.WatchSet, store *state.Store, key string, tables []string,
+	query func(memdb.WatchSet) (uint64, interface{}, error)) (uint64, interface{}, error) {
+	if c == nil {
+		return query(ws)
+	}
+
+	// Read the indexes before running the query, so a result is never
+	// older than the indexes it's cached under.
+	tablesIndex := store.MaxIndexWatch(nil, tables...)
+
+	c.lock.Lock()
+	if raw, ok := c.entries.Get(key); ok {
+		e := raw.(*queryCacheEntry)
+		if e.store == store && e.tablesIndex == tablesIndex {
+			c.lock.Unlock()
+			<-e.ready
+			if e.err != nil {
+				return query(ws)
+			}
+			metrics.IncrCounter([]string{"query_cache", "hit"}, 1)
+			for ch := range e.watches {
+				ws.Add(ch)
+			}
+			return e.index, e.result, nil
+		}
+	}
+	e := &queryCacheEntry{
+		store:       store,
+		tablesIndex: tablesIndex,
+		ready:       make(chan struct{}),
+		watches:     memdb.NewWatchSet(),
+	}
+	c.entries.Add(key, e)
+	c.lock.Unlock()
+
+	metrics.IncrCounter([]string{"query_cache", "miss"}, 1)
+	e.index, e.result, e.err = query(e.watches)
+	close(e.ready)
+	for ch := range e.watches {
+		ws.Add(ch)
+	}
+	return e.index, e.result, e.err
+}
+
+// services returns the result of state.Services.
+func (c *queryCache) services(ws memdb.WatchSet, s *state.Store) (uint64, structs.Services, error) {
+	index, raw, err := c.get(ws, s, "services", []string{"services"},
+		func(ws memdb.WatchSet) (uint64, interface{}, error) {
+			return s.Services(ws)
+		})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	services := make(structs.Services)
+	for name, tags := range raw.(structs.Services) {
+		services[name] = tags
+	}
+	return index, services, nil
+}
+
+// serviceNodes returns the result of state.ServiceNodes, or of
+// state.ServiceTagNodes if any tags are given.
+func (c *queryCache) serviceNodes(ws memdb.WatchSet, s *state.Store, service string, tags []string) (uint64, structs.ServiceNodes, error) {
+	key := queryCacheKey("service_nodes", service, tags)
+	index, raw, err := c.get(ws, s, key, []string{"nodes", "services"},
+		func(ws memdb.WatchSet) (uint64, interface{}, error) {
+			if len(tags) > 0 {
+				return s.ServiceTagNodes(ws, service, tags)
+			}
+			return s.ServiceNodes(ws, service)
+		})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	nodes := raw.(structs.ServiceNodes)
+	if nodes == nil {
+		return index, nil, nil
+	}
+	return index, append(structs.ServiceNodes(nil), nodes...), nil
+}
+
+// checkServiceNodes returns the result of state.CheckServiceNodes, or of
+// state.CheckServiceTagNodes if any tags are given.
+func (c *queryCache) checkServiceNodes(ws memdb.WatchSet, s *state.Store, service string, tags []string) (uint64, structs.CheckServiceNodes, error) {
+	key := queryCacheKey("check_service_nodes", service, tags)
+	index, raw, err := c.get(ws, s, key, []string{"nodes", "services", "checks"},
+		func(ws memdb.WatchSet) (uint64, interface{}, error) {
+			if len(tags) > 0 {
+				return s.CheckServiceTagNodes(ws, service, tags)
+			}
+			return s.CheckServiceNodes(ws, service)
+		})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	nodes := raw.(structs.CheckServiceNodes)
+	if nodes == nil {
+		return index, nil, nil
+	}
+	return index, append(structs.CheckServiceNodes(nil), nodes...), nil
+}
+
+// queryCacheKey returns the cache key for a query about a service. The names
+// are quoted so that no two queries get the same key.
+func queryCacheKey(query, service string, tags []string) string {
+	return fmt.Sprintf("%s/%q/%q", query, service, tags)
+}
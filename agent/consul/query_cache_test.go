@@ -0,0 +1,124 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	t.Parallel()
+
+	s, err := state.NewStateStore(nil)
+	require.NoError(t, err)
+	require.NoError(t, s.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+	require.NoError(t, s.EnsureService(2, "foo", &structs.NodeService{ID: "web", Service: "web", Tags: []string{"primary"}}))
+
+	c, err := newQueryCache(10)
+	require.NoError(t, err)
+
+	runs := 0
+	query := func(ws memdb.WatchSet) (uint64, interface{}, error) {
+		runs++
+		return s.ServiceNodes(ws, "web")
+	}
+
+	// The first call runs the query, and later ones reuse its result.
+	index, raw, err := c.get(nil, s, "web", []string{"nodes", "services"}, query)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), index)
+	require.Len(t, raw.(structs.ServiceNodes), 1)
+	require.Equal(t, 1, runs)
+
+	ws := memdb.NewWatchSet()
+	index, _, err = c.get(ws, s, "web", []string{"nodes", "services"}, query)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), index)
+	require.Equal(t, 1, runs)
+
+	// A hit still watches the query's results, and a write to one of the
+	// tables makes the next call run the query again.
+	require.NoError(t, s.EnsureService(3, "foo", &structs.NodeService{ID: "web2", Service: "web"}))
+	require.False(t, ws.Watch(time.After(time.Second)))
+
+	index, raw, err = c.get(nil, s, "web", []string{"nodes", "services"}, query)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), index)
+	require.Len(t, raw.(structs.ServiceNodes), 2)
+	require.Equal(t, 2, runs)
+
+	// A restored state store is a different store, so doesn't share results.
+	s2, err := state.NewStateStore(nil)
+	require.NoError(t, err)
+	_, raw, err = c.get(nil, s2, "web", []string{"nodes", "services"}, func(ws memdb.WatchSet) (uint64, interface{}, error) {
+		runs++
+		return s2.ServiceNodes(ws, "web")
+	})
+	require.NoError(t, err)
+	require.Len(t, raw.(structs.ServiceNodes), 0)
+	require.Equal(t, 3, runs)
+}
+
+func TestQueryCache_Copies(t *testing.T) {
+	t.Parallel()
+
+	s, err := state.NewStateStore(nil)
+	require.NoError(t, err)
+	require.NoError(t, s.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+	require.NoError(t, s.EnsureNode(2, &structs.Node{Node: "bar", Address: "127.0.0.2"}))
+	require.NoError(t, s.EnsureService(3, "foo", &structs.NodeService{ID: "web", Service: "web", Tags: []string{"primary"}}))
+	require.NoError(t, s.EnsureService(4, "bar", &structs.NodeService{ID: "web", Service: "web"}))
+
+	c, err := newQueryCache(10)
+	require.NoError(t, err)
+
+	// Callers can filter the results in place without changing the cached
+	// ones.
+	_, services, err := c.services(nil, s)
+	require.NoError(t, err)
+	delete(services, "web")
+	_, services, err = c.services(nil, s)
+	require.NoError(t, err)
+	require.Contains(t, services, "web")
+
+	_, nodes, err := c.checkServiceNodes(nil, s, "web", nil)
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+	nodes[0], nodes[1] = nodes[1], nodes[0]
+	nodes = nodes[:1]
+	_, again, err := c.checkServiceNodes(nil, s, "web", nil)
+	require.NoError(t, err)
+	require.Len(t, again, 2)
+	require.NotEqual(t, nodes[0].Node.Node, again[0].Node.Node)
+
+	// Tag queries are cached separately.
+	_, tagged, err := c.serviceNodes(nil, s, "web", []string{"primary"})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	_, all, err := c.serviceNodes(nil, s, "web", nil)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+}
+
+func TestQueryCache_Disabled(t *testing.T) {
+	t.Parallel()
+
+	c, err := newQueryCache(0)
+	require.NoError(t, err)
+	require.Nil(t, c)
+
+	s, err := state.NewStateStore(nil)
+	require.NoError(t, err)
+	require.NoError(t, s.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+	require.NoError(t, s.EnsureService(2, "foo", &structs.NodeService{ID: "web", Service: "web"}))
+
+	// A nil cache runs every query.
+	index, nodes, err := c.serviceNodes(nil, s, "web", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), index)
+	require.Len(t, nodes, 1)
+}
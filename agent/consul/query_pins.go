@@ -0,0 +1,146 @@
+package consul
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+const (
+	// queryPinReapInterval is how often expired pins are dropped, at most.
+	queryPinReapInterval = time.Minute
+
+	// maxQueryPinsPerSource is the most pins kept for a single source node.
+	// Source nodes are given by the request, so without a cap a client could
+	// fill the server's memory by executing many queries.
+	maxQueryPinsPerSource = 64
+
+	// maxQueryPinSources is the most source nodes pins are kept for. Once
+	// there are this many, queries from new source nodes aren't pinned
+	// until some of the pins expire.
+	maxQueryPinSources = 16384
+)
+
+// queryPinInstance identifies a service instance returned by a query.
+type queryPinInstance struct {
+	Node      string
+	ServiceID string
+}
+
+// queryPin is the subset of results a query returned to a source node.
+type queryPin struct {
+	instances []queryPinInstance
+	expires   time.Time
+}
+
+// queryPins remembers the results prepared queries returned to each source
+// node, so the same subset can be returned to it again until the pin expires.
+// Pins are only kept in memory, by the server that executed the query.
+type queryPins struct {
+	// pins has the pins of each source node, by query key.
+	pins     map[string]map[string]*queryPin
+	lastReap time.Time
+	lock     sync.Mutex
+}
+
+// newQueryPins returns an empty set of pins.
+func newQueryPins() *queryPins {
+	return &queryPins{
+		pins: make(map[string]map[string]*queryPin),
+	}
+}
+
+// queryPinKey returns the key of the pins for the given query. Templates
+// render to queries with the same ID, so the service is part of the key too.
+func queryPinKey(query *structs.PreparedQuery) string {
+	return query.ID + "/" + query.Service.Service
+}
+
+// apply moves the instances pinned for the given source node and query key to
+// the front of nodes, in the order they were first returned, as long as
+// they're still in nodes and not drained. The first limit nodes (all of them
+// if limit is zero) are then pinned for ttl, unless there's an unexpired pin
+// already, in which case its instances are updated to replace the ones that
+// have gone but it keeps its expiry. If the source node already has the most
+// pins allowed, the one that expires first is dropped to make room. Nothing is
+// pinned for a new source node if there are too many source nodes already.
+// Returns the reordered nodes.
+func (q *queryPins) apply(source, key string, ttl time.Duration, limit int,
+	nodes structs.CheckServiceNodes, now time.Time) structs.CheckServiceNodes {
+	if ttl <= 0 || len(nodes) == 0 {
+		return nodes
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	_, known := q.pins[source]
+	if now.Sub(q.lastReap) >= queryPinReapInterval || (!known && len(q.pins) >= maxQueryPinSources) {
+		for s, pins := range q.pins {
+			for k, pin := range pins {
+				if !now.Before(pin.expires) {
+					delete(pins, k)
+				}
+			}
+			if len(pins) == 0 {
+				delete(q.pins, s)
+			}
+		}
+		q.lastReap = now
+	}
+
+	pins, ok := q.pins[source]
+	if !ok {
+		if len(q.pins) >= maxQueryPinSources {
+			return nodes
+		}
+		pins = make(map[string]*queryPin)
+		q.pins[source] = pins
+	}
+
+	pin, ok := pins[key]
+	if ok && now.Before(pin.expires) {
+		index := make(map[queryPinInstance]int, len(nodes))
+		for i, node := range nodes {
+			index[queryPinInstance{node.Node.Node, node.Service.ID}] = i
+		}
+
+		sorted := make(structs.CheckServiceNodes, 0, len(nodes))
+		used := make(map[int]bool, len(pin.instances))
+		for _, inst := range pin.instances {
+			if i, ok := index[inst]; ok && !nodes[i].Drained() {
+				sorted = append(sorted, nodes[i])
+				used[i] = true
+			}
+		}
+		for i, node := range nodes {
+			if !used[i] {
+				sorted = append(sorted, node)
+			}
+		}
+		nodes = sorted
+	} else {
+		if !ok && len(pins) >= maxQueryPinsPerSource {
+			var oldest string
+			for k, other := range pins {
+				if oldest == "" || other.expires.Before(pins[oldest].expires) {
+					oldest = k
+				}
+			}
+			delete(pins, oldest)
+		}
+		pin = &queryPin{expires: now.Add(ttl)}
+		pins[key] = pin
+	}
+
+	n := len(nodes)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	pin.instances = make([]queryPinInstance, 0, n)
+	for _, node := range nodes[:n] {
+		pin.instances = append(pin.instances, queryPinInstance{node.Node.Node, node.Service.ID})
+	}
+	return nodes
+}
@@ -0,0 +1,89 @@
+package consul
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryPins(t *testing.T) {
+	t.Parallel()
+
+	instance := func(node string) structs.CheckServiceNode {
+		return structs.CheckServiceNode{
+			Node:    &structs.Node{Node: node},
+			Service: &structs.NodeService{ID: "web"},
+		}
+	}
+	names := func(nodes structs.CheckServiceNodes) []string {
+		var out []string
+		for _, node := range nodes {
+			out = append(out, node.Node.Node)
+		}
+		return out
+	}
+
+	q := newQueryPins()
+	start := time.Now()
+	apply := func(now time.Time, nodes ...string) []string {
+		t.Helper()
+		var in structs.CheckServiceNodes
+		for _, node := range nodes {
+			in = append(in, instance(node))
+		}
+		return names(q.apply("client", "query/web", time.Minute, 2, in, now))
+	}
+
+	// The first execution pins the first two results.
+	require.Equal(t, []string{"a", "b", "c"}, apply(start, "a", "b", "c"))
+
+	// Later ones get them first, in the same order.
+	require.Equal(t, []string{"a", "b", "d", "c"}, apply(start.Add(10*time.Second), "d", "c", "b", "a"))
+
+	// An instance that's gone is replaced, and the replacement stays
+	// pinned.
+	require.Equal(t, []string{"b", "d", "c"}, apply(start.Add(20*time.Second), "d", "c", "b"))
+	require.Equal(t, []string{"b", "d", "a", "c"}, apply(start.Add(30*time.Second), "a", "c", "b", "d"))
+
+	// Once the pin expires the results are pinned afresh.
+	require.Equal(t, []string{"c", "a", "b", "d"}, apply(start.Add(time.Minute), "c", "a", "b", "d"))
+	require.Equal(t, []string{"c", "a", "d", "b"}, apply(start.Add(90*time.Second), "d", "b", "a", "c"))
+
+	// Other source nodes, and zero TTLs, aren't affected.
+	in := structs.CheckServiceNodes{instance("d"), instance("c")}
+	require.Equal(t, []string{"d", "c"}, names(q.apply("other", "query/web", time.Minute, 1, in, start)))
+	require.Equal(t, []string{"d", "c"}, names(q.apply("client", "query/web", 0, 1, in, start)))
+
+	// Drained instances aren't kept first.
+	drained := instance("c")
+	drained.Checks = structs.HealthChecks{&structs.HealthCheck{CheckID: structs.NodeDrain}}
+	in = structs.CheckServiceNodes{instance("b"), instance("a"), instance("d"), drained}
+	require.Equal(t, []string{"a", "b", "d", "c"}, names(q.apply("client", "query/web", time.Minute, 2, in, start.Add(100*time.Second))))
+
+	// A source node's pins are capped, dropping the one that expires first.
+	q = newQueryPins()
+	in = structs.CheckServiceNodes{instance("a"), instance("b")}
+	for i := 0; i < maxQueryPinsPerSource+1; i++ {
+		key := fmt.Sprintf("query%d/web", i)
+		q.apply("client", key, time.Minute+time.Duration(i)*time.Second, 1, in, start)
+	}
+	require.Len(t, q.pins["client"], maxQueryPinsPerSource)
+	require.NotContains(t, q.pins["client"], "query0/web")
+	require.Contains(t, q.pins["client"], "query1/web")
+
+	// The source nodes are capped too. New ones aren't pinned until pins
+	// expire to make room.
+	q = newQueryPins()
+	for i := 0; i < maxQueryPinSources; i++ {
+		q.apply(fmt.Sprintf("client%d", i), "query/web", time.Minute, 1, in, start)
+	}
+	q.apply("extra", "query/web", time.Minute, 1, in, start)
+	require.Len(t, q.pins, maxQueryPinSources)
+	require.NotContains(t, q.pins, "extra")
+	q.apply("extra", "query/web", time.Minute, 1, in, start.Add(time.Minute))
+	require.Len(t, q.pins, 1)
+	require.Contains(t, q.pins, "extra")
+}
@@ -0,0 +1,104 @@
+package consul
+
+import (
+	"sync"
+	"time"
+)
+
+// raftApplyWarnInterval limits how often a server logs that it is rejecting
+// Raft applies, since that tends to happen in bursts.
+const raftApplyWarnInterval = 10 * time.Second
+
+// RaftApplyLimiter bounds the number of Raft applies a server has in flight
+// at once, so a write storm can't make it buffer an unbounded number of
+// entries. Applies that find the queue full can wait a limited time for a
+// slot to free up before they are rejected. A zero depth disables the limit.
+// It is safe for concurrent use.
+type RaftApplyLimiter struct {
+	wait  time.Duration
+	slots chan struct{}
+
+	// shed counts the applies rejected since the last warning was logged.
+	shedLock sync.Mutex
+	shed     int
+	lastWarn time.Time
+}
+
+// NewRaftApplyLimiter returns a limiter allowing depth applies in flight,
+// where applies wait up to the given time for a slot.
+func NewRaftApplyLimiter(depth int, wait time.Duration) *RaftApplyLimiter {
+	l := &RaftApplyLimiter{
+		wait: wait,
+	}
+	if depth > 0 {
+		l.slots = make(chan struct{}, depth)
+	}
+	return l
+}
+
+// Acquire reserves a slot for an apply, waiting for one to free up if the
+// queue is full. The wait is cut short by the given deadline, if it's not
+// zero. It returns false if no slot could be had, in which case the apply
+// should be rejected. Every successful Acquire must be paired with a Release.
+func (l *RaftApplyLimiter) Acquire(deadline time.Time) bool {
+	if l.slots == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	wait := l.wait
+	if !deadline.IsZero() {
+		if left := time.Until(deadline); left < wait {
+			wait = left
+		}
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case l.slots <- struct{}{}:
+			return true
+		case <-timer.C:
+		}
+	}
+
+	l.shedLock.Lock()
+	l.shed++
+	l.shedLock.Unlock()
+	return false
+}
+
+// Release frees a slot reserved by Acquire.
+func (l *RaftApplyLimiter) Release() {
+	if l.slots == nil {
+		return
+	}
+	<-l.slots
+}
+
+// Len returns the number of applies currently in flight. This is always zero
+// if there's no limit.
+func (l *RaftApplyLimiter) Len() int {
+	return len(l.slots)
+}
+
+// Shed returns the number of applies rejected since the last call that
+// returned a non-zero count, at most once per raftApplyWarnInterval. This
+// lets callers log rejections without flooding the logs.
+func (l *RaftApplyLimiter) Shed() int {
+	l.shedLock.Lock()
+	defer l.shedLock.Unlock()
+
+	if l.shed == 0 || time.Since(l.lastWarn) < raftApplyWarnInterval {
+		return 0
+	}
+	n := l.shed
+	l.shed = 0
+	l.lastWarn = time.Now()
+	return n
+}
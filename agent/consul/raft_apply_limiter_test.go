@@ -0,0 +1,65 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaftApplyLimiter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewRaftApplyLimiter(2, 0)
+	require.True(l.Acquire(time.Time{}))
+	require.True(l.Acquire(time.Time{}))
+	require.False(l.Acquire(time.Time{}))
+	require.Equal(2, l.Len())
+
+	// The first shed count is reported, then throttled.
+	require.Equal(1, l.Shed())
+	require.False(l.Acquire(time.Time{}))
+	require.Equal(0, l.Shed())
+
+	// Releasing makes room again.
+	l.Release()
+	require.Equal(1, l.Len())
+	require.True(l.Acquire(time.Time{}))
+	l.Release()
+	l.Release()
+	require.Equal(0, l.Len())
+}
+
+func TestRaftApplyLimiter_wait(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewRaftApplyLimiter(1, time.Minute)
+	require.True(l.Acquire(time.Time{}))
+
+	// A deadline cuts the wait short.
+	start := time.Now()
+	require.False(l.Acquire(time.Now().Add(20 * time.Millisecond)))
+	require.True(time.Since(start) < time.Minute)
+
+	// Waiters get the slot once it's released.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Release()
+	}()
+	require.True(l.Acquire(time.Time{}))
+	require.Equal(1, l.Len())
+}
+
+func TestRaftApplyLimiter_unlimited(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewRaftApplyLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		require.True(l.Acquire(time.Time{}))
+	}
+	require.Equal(0, l.Len())
+	l.Release()
+}
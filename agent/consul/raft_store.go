@@ -0,0 +1,40 @@
+package consul
+
+import (
+	"path/filepath"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+// raftStoreFile is the BoltDB file in the Raft directory holding the Raft log
+// and stable state.
+const raftStoreFile = "raft.db"
+
+// RaftStore is the storage for Raft's log and stable state.
+type RaftStore interface {
+	raft.LogStore
+	raft.StableStore
+	Close() error
+}
+
+// openRaftStore opens the BoltDB store in the given Raft directory, creating
+// it if needed.
+func openRaftStore(dir string) (RaftStore, error) {
+	return raftboltdb.NewBoltStore(filepath.Join(dir, raftStoreFile))
+}
+
+// newInmemRaftStore returns a store that keeps everything in memory, so
+// it's all lost when the server stops. This is only used in dev mode.
+func newInmemRaftStore() RaftStore {
+	return &inmemRaftStore{raft.NewInmemStore()}
+}
+
+// inmemRaftStore adds a no-op Close to the in-memory store.
+type inmemRaftStore struct {
+	*raft.InmemStore
+}
+
+func (s *inmemRaftStore) Close() error {
+	return nil
+}
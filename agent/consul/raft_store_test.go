@@ -0,0 +1,78 @@
+package consul
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+)
+
+// fillRaftStore writes logs 1 through n and Raft's stable state to the store.
+func fillRaftStore(t *testing.T, store RaftStore, n uint64) {
+	t.Helper()
+	var logs []*raft.Log
+	for i := uint64(1); i <= n; i++ {
+		logs = append(logs, &raft.Log{Index: i, Term: i / 100, Type: raft.LogCommand, Data: []byte{byte(i)}})
+	}
+	require.NoError(t, store.StoreLogs(logs))
+	require.NoError(t, store.SetUint64([]byte("CurrentTerm"), 7))
+	require.NoError(t, store.SetUint64([]byte("LastVoteTerm"), 6))
+	require.NoError(t, store.Set([]byte("LastVoteCand"), []byte("node1")))
+}
+
+// verifyRaftStore checks the store has what fillRaftStore wrote.
+func verifyRaftStore(t *testing.T, store RaftStore, n uint64) {
+	t.Helper()
+	first, err := store.FirstIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), first)
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, n, last)
+	for i := uint64(1); i <= n; i++ {
+		var entry raft.Log
+		require.NoError(t, store.GetLog(i, &entry))
+		require.Equal(t, i/100, entry.Term)
+		require.Equal(t, []byte{byte(i)}, entry.Data)
+	}
+
+	term, err := store.GetUint64([]byte("CurrentTerm"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), term)
+	term, err = store.GetUint64([]byte("LastVoteTerm"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), term)
+	cand, err := store.Get([]byte("LastVoteCand"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("node1"), cand)
+}
+
+func TestOpenRaftStore(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "raft")
+	defer os.RemoveAll(dir)
+
+	store, err := openRaftStore(dir)
+	require.NoError(t, err)
+	fillRaftStore(t, store, 10)
+	require.NoError(t, store.Close())
+
+	// The data is kept in raft.db, and is there when it's opened again.
+	_, err = os.Stat(filepath.Join(dir, "raft.db"))
+	require.NoError(t, err)
+	store, err = openRaftStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	verifyRaftStore(t, store, 10)
+}
+
+func TestInmemRaftStore(t *testing.T) {
+	t.Parallel()
+	store := newInmemRaftStore()
+	fillRaftStore(t, store, 10)
+	verifyRaftStore(t, store, 10)
+	require.NoError(t, store.Close())
+}
@@ -0,0 +1,106 @@
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/consul/fsm"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/raft"
+)
+
+// verifyRaft checks that the given Raft log is readable and consistent, and
+// that the latest snapshot passes its checksums and decodes cleanly. This
+// reads the whole log and snapshot, so it's only done at startup and when an
+// operator asks for it.
+func verifyRaft(logs raft.LogStore, snaps raft.SnapshotStore) (*structs.RaftVerifyResponse, error) {
+	defer metrics.MeasureSince([]string{"raft", "verify"}, time.Now())
+
+	reply := &structs.RaftVerifyResponse{}
+	first, last, err := verifyRaftLog(logs)
+	if err != nil {
+		return nil, err
+	}
+	reply.FirstIndex, reply.LastIndex = first, last
+
+	metas, err := snaps.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %v", err)
+	}
+	if len(metas) == 0 {
+		// Without a snapshot the log has to go all the way back to the
+		// start, otherwise there's no way to rebuild the state.
+		if last > 0 && first > 1 {
+			return nil, fmt.Errorf("Raft log starts at index %d but there's no snapshot with the entries before it", first)
+		}
+		return reply, nil
+	}
+
+	// Raft only ever restores the latest snapshot and then replays the log
+	// after it, so that's the one that has to be good.
+	latest := metas[0]
+	reply.SnapshotID, reply.SnapshotIndex = latest.ID, latest.Index
+	if last > 0 && first > latest.Index+1 {
+		return nil, fmt.Errorf("Raft log starts at index %d but snapshot %s ends at index %d, the entries in between are missing",
+			first, latest.ID, latest.Index)
+	}
+
+	// Opening a snapshot from the file store checks the checksum of the whole
+	// file. Reading through the records checks the checksum at the end of the
+	// FSM data, which is only written once every server supports it, so older
+	// snapshots only get checked for records that don't decode.
+	_, source, err := snaps.Open(latest.ID)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s failed verification: %v", latest.ID, err)
+	}
+	defer source.Close()
+	if err := fsm.VerifySnapshot(source); err != nil {
+		return nil, fmt.Errorf("snapshot %s failed verification: %v", latest.ID, err)
+	}
+	return reply, nil
+}
+
+// verifyRaftLog reads every entry in the Raft log and makes sure they are all
+// there, in order, and their terms never go backwards. It returns the range
+// of the log that was checked.
+func verifyRaftLog(logs raft.LogStore) (uint64, uint64, error) {
+	first, err := logs.FirstIndex()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read first Raft log index: %v", err)
+	}
+	last, err := logs.LastIndex()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read last Raft log index: %v", err)
+	}
+	if last == 0 {
+		return 0, 0, nil
+	}
+
+	var term uint64
+	for i := first; i <= last; i++ {
+		var entry raft.Log
+		if err := logs.GetLog(i, &entry); err != nil {
+			// Raft may have compacted the log while we were reading it,
+			// in which case we just skip ahead.
+			if err == raft.ErrLogNotFound {
+				current, ferr := logs.FirstIndex()
+				if ferr == nil && current > i {
+					first, i = current, current-1
+					continue
+				}
+				return 0, 0, fmt.Errorf("Raft log index %d is missing (log range %d-%d)", i, first, last)
+			}
+			return 0, 0, fmt.Errorf("failed to read Raft log index %d: %v", i, err)
+		}
+		if entry.Index != i {
+			return 0, 0, fmt.Errorf("Raft log index %d holds the entry for index %d", i, entry.Index)
+		}
+		if entry.Term < term {
+			return 0, 0, fmt.Errorf("Raft log index %d has term %d, which is before term %d of the entry before it",
+				i, entry.Term, term)
+		}
+		term = entry.Term
+	}
+	return first, last, nil
+}
@@ -0,0 +1,155 @@
+package consul
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/consul/fsm"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+)
+
+// testRaftSnapshot writes a snapshot of an FSM with a single node into the
+// snapshot store at the given index, applying the given corruption to the
+// FSM data first. The FSM writes its checksum, as it does once every server
+// supports it.
+func testRaftSnapshot(t *testing.T, snaps raft.SnapshotStore, index uint64, corrupt func([]byte)) {
+	t.Helper()
+	require := require.New(t)
+
+	f, err := fsm.New(nil, os.Stderr)
+	require.NoError(err)
+	f.SetSnapshotTypeCheck(func(structs.MessageType) bool { return true })
+	require.NoError(f.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}))
+	snap, err := f.Snapshot()
+	require.NoError(err)
+	defer snap.Release()
+
+	sink, err := snaps.Create(raft.SnapshotVersionMax, index, 1, raft.Configuration{}, 0, nil)
+	require.NoError(err)
+	var buf bytes.Buffer
+	require.NoError(snap.Persist(&bufferSink{&buf, sink}))
+	data := buf.Bytes()
+	if corrupt != nil {
+		corrupt(data)
+	}
+	_, err = sink.Write(data)
+	require.NoError(err)
+	require.NoError(sink.Close())
+}
+
+// bufferSink captures a persisted snapshot so it can be altered before it's
+// written to the real sink.
+type bufferSink struct {
+	*bytes.Buffer
+	raft.SnapshotSink
+}
+
+func (b *bufferSink) Write(p []byte) (int, error) {
+	return b.Buffer.Write(p)
+}
+
+func TestVerifyRaft(t *testing.T) {
+	t.Parallel()
+
+	logs := func(entries ...raft.Log) raft.LogStore {
+		store := raft.NewInmemStore()
+		for i := range entries {
+			require.NoError(t, store.StoreLog(&entries[i]))
+		}
+		return store
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		reply, err := verifyRaft(logs(), raft.NewInmemSnapshotStore())
+		require.NoError(t, err)
+		require.Equal(t, &structs.RaftVerifyResponse{}, reply)
+	})
+
+	t.Run("good", func(t *testing.T) {
+		snaps := raft.NewInmemSnapshotStore()
+		testRaftSnapshot(t, snaps, 2, nil)
+		store := logs(raft.Log{Index: 3, Term: 1}, raft.Log{Index: 4, Term: 2})
+		reply, err := verifyRaft(store, snaps)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), reply.FirstIndex)
+		require.Equal(t, uint64(4), reply.LastIndex)
+		require.Equal(t, uint64(2), reply.SnapshotIndex)
+		require.NotEmpty(t, reply.SnapshotID)
+	})
+
+	t.Run("missing log entry", func(t *testing.T) {
+		store := logs(raft.Log{Index: 1, Term: 1}, raft.Log{Index: 3, Term: 1})
+		_, err := verifyRaft(store, raft.NewInmemSnapshotStore())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "index 2 is missing")
+	})
+
+	t.Run("term goes backwards", func(t *testing.T) {
+		store := logs(raft.Log{Index: 1, Term: 2}, raft.Log{Index: 2, Term: 1})
+		_, err := verifyRaft(store, raft.NewInmemSnapshotStore())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "index 2 has term 1")
+	})
+
+	t.Run("log without snapshot", func(t *testing.T) {
+		store := logs(raft.Log{Index: 5, Term: 1})
+		_, err := verifyRaft(store, raft.NewInmemSnapshotStore())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no snapshot")
+	})
+
+	t.Run("gap after snapshot", func(t *testing.T) {
+		snaps := raft.NewInmemSnapshotStore()
+		testRaftSnapshot(t, snaps, 2, nil)
+		store := logs(raft.Log{Index: 5, Term: 1})
+		_, err := verifyRaft(store, snaps)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "entries in between are missing")
+	})
+
+	t.Run("corrupt snapshot", func(t *testing.T) {
+		snaps := raft.NewInmemSnapshotStore()
+		testRaftSnapshot(t, snaps, 2, func(data []byte) {
+			i := bytes.Index(data, []byte("127.0.0.1"))
+			require.True(t, i > 0)
+			data[i] = '2'
+		})
+		store := logs(raft.Log{Index: 3, Term: 1})
+		_, err := verifyRaft(store, snaps)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "checksum mismatch")
+	})
+
+	t.Run("corrupt snapshot file", func(t *testing.T) {
+		dir := testutil.TempDir(t, "snapshots")
+		defer os.RemoveAll(dir)
+		snaps, err := raft.NewFileSnapshotStore(dir, 1, os.Stderr)
+		require.NoError(t, err)
+		testRaftSnapshot(t, snaps, 2, nil)
+
+		store := logs(raft.Log{Index: 3, Term: 1})
+		_, err = verifyRaft(store, snaps)
+		require.NoError(t, err)
+
+		// Flip a byte in the file after it was written, which the file
+		// store's own checksum catches before the FSM data is read.
+		metas, err := snaps.List()
+		require.NoError(t, err)
+		require.Len(t, metas, 1)
+		path := filepath.Join(dir, "snapshots", metas[0].ID, "state.bin")
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		data[len(data)/2] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+		_, err = verifyRaft(store, snaps)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "CRC mismatch")
+	})
+}
@@ -0,0 +1,118 @@
+package consul
+
+import (
+	"container/heap"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+// reconcileQueue holds the Serf member events waiting to be reconciled by the
+// leader. Only the latest event is kept for each member, so the queue is
+// bounded by the number of members. Members are handed out by priority so
+// alive members are registered before failed members are marked as critical,
+// and those before any members are deregistered. The events are kept in a
+// heap, so pushing and popping them is O(log n).
+type reconcileQueue struct {
+	heap    reconcileHeap
+	members map[string]*reconcileItem
+}
+
+// reconcileItem is a member event in the queue, along with its position in
+// the heap.
+type reconcileItem struct {
+	member serf.Member
+	index  int
+}
+
+// newReconcileQueue returns an empty reconcile queue.
+func newReconcileQueue() *reconcileQueue {
+	return &reconcileQueue{
+		members: make(map[string]*reconcileItem),
+	}
+}
+
+// Push adds a member event to the queue, replacing any pending event for the
+// same member.
+func (q *reconcileQueue) Push(member serf.Member) {
+	if item, ok := q.members[member.Name]; ok {
+		item.member = member
+		heap.Fix(&q.heap, item.index)
+		return
+	}
+	item := &reconcileItem{member: member}
+	heap.Push(&q.heap, item)
+	q.members[member.Name] = item
+}
+
+// Pop removes and returns the member event with the highest priority. Events
+// with the same priority are returned in order of member name.
+func (q *reconcileQueue) Pop() (serf.Member, bool) {
+	if len(q.heap) == 0 {
+		return serf.Member{}, false
+	}
+	item := heap.Pop(&q.heap).(*reconcileItem)
+	delete(q.members, item.member.Name)
+	return item.member, true
+}
+
+// Len returns the number of member events in the queue.
+func (q *reconcileQueue) Len() int {
+	return len(q.heap)
+}
+
+// reconcileHeap implements heap.Interface for the reconcile queue.
+type reconcileHeap []*reconcileItem
+
+func (h reconcileHeap) Len() int {
+	return len(h)
+}
+
+func (h reconcileHeap) Less(i, j int) bool {
+	return reconcileBefore(h[i].member, h[j].member)
+}
+
+func (h reconcileHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *reconcileHeap) Push(x interface{}) {
+	item := x.(*reconcileItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *reconcileHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// reconcileBefore returns true if the event for member a should be reconciled
+// before the one for member b.
+func reconcileBefore(a, b serf.Member) bool {
+	pa, pb := reconcilePriority(a), reconcilePriority(b)
+	if pa != pb {
+		return pa < pb
+	}
+	return a.Name < b.Name
+}
+
+// reconcilePriority returns the priority of a member event, where lower
+// values are more urgent.
+func reconcilePriority(member serf.Member) int {
+	switch member.Status {
+	case serf.StatusAlive:
+		return 0
+	case serf.StatusFailed:
+		return 1
+	case serf.StatusLeft:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,42 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+func TestReconcileQueue(t *testing.T) {
+	t.Parallel()
+	q := newReconcileQueue()
+	if _, ok := q.Pop(); ok {
+		t.Fatalf("queue should be empty")
+	}
+
+	q.Push(serf.Member{Name: "reaped", Status: StatusReap})
+	q.Push(serf.Member{Name: "left", Status: serf.StatusLeft})
+	q.Push(serf.Member{Name: "b", Status: serf.StatusFailed})
+	q.Push(serf.Member{Name: "a", Status: serf.StatusFailed})
+	q.Push(serf.Member{Name: "alive", Status: serf.StatusFailed})
+
+	// Only the latest event for a member is kept.
+	q.Push(serf.Member{Name: "alive", Status: serf.StatusAlive})
+	if q.Len() != 5 {
+		t.Fatalf("bad: %d", q.Len())
+	}
+
+	// Members come out by priority, then by name.
+	expected := []string{"alive", "a", "b", "left", "reaped"}
+	for _, name := range expected {
+		member, ok := q.Pop()
+		if !ok {
+			t.Fatalf("queue should not be empty")
+		}
+		if member.Name != name {
+			t.Fatalf("got %q want %q", member.Name, name)
+		}
+	}
+	if q.Len() != 0 {
+		t.Fatalf("bad: %d", q.Len())
+	}
+}
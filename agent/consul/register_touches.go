@@ -0,0 +1,82 @@
+package consul
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/golang-lru"
+)
+
+// registerTouchesSize is how many registrations' last writes are remembered.
+// Registrations that have been forgotten start over as if they were just
+// written, so this only needs to cover the registrations being re-sent.
+const registerTouchesSize = 65536
+
+// registerTouches remembers when each registration was last written through
+// Raft. Servers skip writing registrations that wouldn't change the catalog,
+// but still let one through every interval as a liveness touch, which bumps
+// the index of the checks table so watchers can tell the registrations are
+// still being refreshed.
+type registerTouches struct {
+	interval time.Duration
+	last     *lru.Cache
+}
+
+// newRegisterTouches returns a tracker that lets an unchanged registration
+// through once every interval. It returns nil if the interval is zero, which
+// disables the touches.
+func newRegisterTouches(interval time.Duration) (*registerTouches, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	last, err := lru.New(registerTouchesSize)
+	if err != nil {
+		return nil, err
+	}
+	return &registerTouches{interval: interval, last: last}, nil
+}
+
+// due returns true if the registration with the given key hasn't been written
+// for an interval. A registration that hasn't been seen before is treated as
+// just written, so a new leader doesn't write everything at once. It's safe
+// to call on a nil tracker, which never has touches due.
+func (t *registerTouches) due(key string) bool {
+	if t == nil {
+		return false
+	}
+	raw, ok := t.last.Get(key)
+	if !ok {
+		t.last.Add(key, time.Now())
+		return false
+	}
+	return time.Since(raw.(time.Time)) >= t.interval
+}
+
+// written records that the registration with the given key was just written.
+// It's safe to call on a nil tracker.
+func (t *registerTouches) written(key string) {
+	if t == nil {
+		return
+	}
+	t.last.Add(key, time.Now())
+}
+
+// registerTouchKey returns the key registrations are tracked under, made up
+// of the node and the IDs of the service and checks in the request.
+func registerTouchKey(args *structs.RegisterRequest) string {
+	parts := []string{args.Node}
+	if args.Service != nil {
+		parts = append(parts, "service:"+args.Service.ID)
+	}
+	var checks []string
+	if args.Check != nil {
+		checks = append(checks, "check:"+string(args.Check.CheckID))
+	}
+	for _, check := range args.Checks {
+		checks = append(checks, "check:"+string(check.CheckID))
+	}
+	sort.Strings(checks)
+	return strings.Join(append(parts, checks...), "/")
+}
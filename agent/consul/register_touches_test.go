@@ -0,0 +1,46 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTouches(t *testing.T) {
+	t.Parallel()
+
+	touches, err := newRegisterTouches(50 * time.Millisecond)
+	require.NoError(t, err)
+
+	// A registration seen for the first time is treated as just written.
+	require.False(t, touches.due("foo"))
+	require.False(t, touches.due("foo"))
+
+	// Once the interval has passed a touch is due, until it's written.
+	time.Sleep(60 * time.Millisecond)
+	require.True(t, touches.due("foo"))
+	touches.written("foo")
+	require.False(t, touches.due("foo"))
+
+	// A disabled tracker never has touches due.
+	touches, err = newRegisterTouches(0)
+	require.NoError(t, err)
+	require.Nil(t, touches)
+	require.False(t, touches.due("foo"))
+	touches.written("foo")
+}
+
+func TestRegisterTouchKey(t *testing.T) {
+	t.Parallel()
+
+	args := &structs.RegisterRequest{
+		Node:    "foo",
+		Service: &structs.NodeService{ID: "web"},
+		Check:   &structs.HealthCheck{CheckID: "b"},
+		Checks:  structs.HealthChecks{&structs.HealthCheck{CheckID: "a"}},
+	}
+	require.Equal(t, "foo/service:web/check:a/check:b", registerTouchKey(args))
+	require.Equal(t, "foo", registerTouchKey(&structs.RegisterRequest{Node: "foo"}))
+}
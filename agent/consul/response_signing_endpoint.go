@@ -0,0 +1,159 @@
+package consul
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ResponseSigning hands out the public part of the key the servers sign
+// catalog and health replies with.
+type ResponseSigning struct {
+	// srv is a pointer back to the server.
+	srv *Server
+}
+
+// PublicKey returns the public part of the response signing key. The key
+// isn't secret, so no ACL is needed to read it.
+func (r *ResponseSigning) PublicKey(args *structs.DCSpecificRequest, reply *structs.IndexedResponseSigningKey) error {
+	if done, err := r.srv.forward("ResponseSigning.PublicKey", args, args, reply); done {
+		return err
+	}
+
+	return r.srv.blockingQuery(
+		"response-signing-key",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, key, err := state.ResponseSigningKey(ws)
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				return structs.ErrResponseSigningDisabled
+			}
+			reply.Index = index
+			reply.ID = key.ID
+			reply.Algorithm = structs.ResponseSigningAlgorithm
+			reply.PublicKey = key.PublicKey
+			return nil
+		})
+}
+
+// getOrCreateResponseSigningKey creates the response signing key if response
+// signing is enabled and there isn't one yet. Failures are logged, and the
+// key is created on the next election instead.
+func (s *Server) getOrCreateResponseSigningKey() {
+	if !s.config.EnableResponseSigning {
+		return
+	}
+	_, key, err := s.fsm.State().ResponseSigningKey(nil)
+	if err != nil {
+		s.logger.Printf("[ERR] consul: Failed to get response signing key: %v", err)
+		return
+	}
+	if key != nil {
+		return
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		s.logger.Printf("[ERR] consul: Failed to generate response signing key: %v", err)
+		return
+	}
+	fingerprint := sha256.Sum256(pub)
+	req := structs.ResponseSigningKeyRequest{
+		Datacenter: s.config.Datacenter,
+		Key: &structs.ResponseSigningKey{
+			ID:         hex.EncodeToString(fingerprint[:8]),
+			PublicKey:  pub,
+			PrivateKey: priv,
+		},
+	}
+	resp, err := s.raftApply(structs.ResponseSigningKeyType, &req)
+	if respErr, ok := resp.(error); ok {
+		err = respErr
+	}
+	if err != nil {
+		s.logger.Printf("[WARN] consul: Failed to create response signing key: %v", err)
+		return
+	}
+	s.logger.Printf("[INFO] consul: Created response signing key %s", req.Key.ID)
+}
+
+// signResponse signs the JSON encoding of the given results, along with the
+// request and the index of the reply they're from.
+func (s *Server) signResponse(endpoint string, args *structs.ServiceSpecificRequest, index uint64, results interface{}) (*structs.ResponseSignature, error) {
+	if !s.config.EnableResponseSigning {
+		return nil, structs.ErrResponseSigningDisabled
+	}
+	_, key, err := s.fsm.State().ResponseSigningKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, structs.ErrResponseSigningDisabled
+	}
+
+	payload, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	req := structs.NewResponseSigningRequest(s.config.Datacenter, endpoint, key.ID, args)
+	msg, err := structs.ResponseSigningMessage(req, index, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &structs.ResponseSignature{
+		KeyID:     key.ID,
+		Payload:   payload,
+		Signature: ed25519.Sign(ed25519.PrivateKey(key.PrivateKey), msg),
+	}, nil
+}
+
+// signedServiceNodes returns the nodes as the HTTP API returns them, with
+// empty lists instead of nil ones. The nodes are copied rather than changed,
+// since they're shared with the state store.
+func signedServiceNodes(nodes structs.ServiceNodes) structs.ServiceNodes {
+	out := make(structs.ServiceNodes, 0, len(nodes))
+	for _, n := range nodes {
+		if n.ServiceTags == nil {
+			clone := *n
+			clone.ServiceTags = make([]string, 0)
+			n = &clone
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// signedCheckServiceNodes returns the nodes as the HTTP API returns them,
+// with empty lists instead of nil ones.
+func signedCheckServiceNodes(nodes structs.CheckServiceNodes) structs.CheckServiceNodes {
+	out := make(structs.CheckServiceNodes, 0, len(nodes))
+	for _, n := range nodes {
+		checks := make(structs.HealthChecks, 0, len(n.Checks))
+		for _, c := range n.Checks {
+			if c.ServiceTags == nil {
+				clone := *c
+				clone.ServiceTags = make([]string, 0)
+				c = &clone
+			}
+			checks = append(checks, c)
+		}
+		n.Checks = checks
+		if n.Service != nil && n.Service.Tags == nil {
+			clone := *n.Service
+			clone.Tags = make([]string, 0)
+			n.Service = &clone
+		}
+		out = append(out, n)
+	}
+	return out
+}
@@ -1,11 +1,13 @@
 package consul
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -17,11 +19,13 @@ import (
 	memdb "github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/hashicorp/raft"
 	"github.com/hashicorp/yamux"
 )
 
 const (
-	// maxQueryTime is used to bound the limit of a blocking query
+	// maxQueryTime is used to bound the limit of a blocking query, unless
+	// it's changed at runtime.
 	maxQueryTime = 600 * time.Second
 
 	// defaultQueryTime is the amount of time we block waiting for a change
@@ -43,6 +47,12 @@ const (
 	// value is ever reached. However, it prevents us from blocking
 	// the requesting goroutine forever.
 	enqueueLimit = 30 * time.Second
+
+	// Warn if running a query against the state store, or applying a
+	// Raft command, takes longer than this. The query threshold can be
+	// changed at runtime.
+	slowQueryThreshold = 1 * time.Second
+	slowApplyThreshold = 1 * time.Second
 )
 
 // listen is used to listen for incoming RPC connections
@@ -69,6 +79,12 @@ func logConn(conn net.Conn) string {
 	return memberlist.LogConn(conn)
 }
 
+// tlsHandshakeRecord is the first byte of a TLS connection. Connections from
+// other datacenters through WAN federation gateways start with TLS, rather
+// than the RPCTLS byte, since the gateways route them by the TLS server name.
+// The RPC type byte follows inside TLS.
+const tlsHandshakeRecord pool.RPCType = 0x16
+
 // handleConn is used to determine if this is a Raft or
 // Consul type RPC connection and invoke the correct handler
 func (s *Server) handleConn(conn net.Conn, isTLS bool) {
@@ -84,16 +100,29 @@ func (s *Server) handleConn(conn net.Conn, isTLS bool) {
 	typ := pool.RPCType(buf[0])
 
 	// Enforce TLS if VerifyIncoming is set
-	if s.config.VerifyIncoming && !isTLS && typ != pool.RPCTLS {
+	if s.config.VerifyIncoming && !isTLS && typ != pool.RPCTLS && typ != tlsHandshakeRecord {
 		s.logger.Printf("[WARN] consul.rpc: Non-TLS connection attempted with VerifyIncoming set %s", logConn(conn))
 		conn.Close()
 		return
 	}
 
+	// Refuse requests forwarded from datacenters we don't accept them from.
+	// Their servers are only known by their certificates, so this is checked
+	// once the TLS handshake is done. WAN gossip through gateways is allowed.
+	if isTLS && typ != pool.RPCRaft && typ != pool.RPCGossip && s.forwardingRestricted() {
+		if dc, ok := s.connDatacenter(conn); ok && !s.forwardingAllowed(dc) {
+			s.logger.Printf("[WARN] consul.rpc: Refusing connection from datacenter %q %s", dc, logConn(conn))
+			metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "refused"}, 1,
+				[]metrics.Label{{Name: "datacenter", Value: dc}})
+			conn.Close()
+			return
+		}
+	}
+
 	// Switch on the byte
 	switch typ {
 	case pool.RPCConsul:
-		s.handleConsulConn(conn)
+		s.handleConsulConn(conn, conn)
 
 	case pool.RPCRaft:
 		metrics.IncrCounter([]string{"rpc", "raft_handoff"}, 1)
@@ -108,6 +137,24 @@ func (s *Server) handleConn(conn net.Conn, isTLS bool) {
 		conn = tls.Server(conn, s.rpcTLS)
 		s.handleConn(conn, true)
 
+	case tlsHandshakeRecord:
+		if s.rpcTLS == nil || isTLS {
+			s.logger.Printf("[WARN] consul.rpc: TLS connection attempted, server not configured for TLS %s", logConn(conn))
+			conn.Close()
+			return
+		}
+		conn = &peekedConn{Conn: conn, r: io.MultiReader(bytes.NewReader(buf), conn)}
+		conn = tls.Server(conn, s.rpcTLS)
+		s.handleConn(conn, true)
+
+	case pool.RPCGossip:
+		if s.wanTransport == nil || !isTLS {
+			s.logger.Printf("[WARN] consul.rpc: Gossip connection attempted, server not using WAN federation gateways %s", logConn(conn))
+			conn.Close()
+			return
+		}
+		s.wanTransport.handleConn(conn)
+
 	case pool.RPCMultiplexV2:
 		s.handleMultiplexV2(conn)
 
@@ -128,6 +175,12 @@ func (s *Server) handleMultiplexV2(conn net.Conn) {
 	defer conn.Close()
 	conf := yamux.DefaultConfig()
 	conf.LogOutput = s.config.LogOutput
+	if s.config.RPCKeepAliveInterval > 0 {
+		conf.KeepAliveInterval = s.config.RPCKeepAliveInterval
+	}
+	if s.config.RPCConnectionWriteTimeout > 0 {
+		conf.ConnectionWriteTimeout = s.config.RPCConnectionWriteTimeout
+	}
 	server, _ := yamux.Server(conn, conf)
 	for {
 		sub, err := server.Accept()
@@ -137,14 +190,24 @@ func (s *Server) handleMultiplexV2(conn net.Conn) {
 			}
 			return
 		}
-		go s.handleConsulConn(sub)
+		go s.handleConsulConn(sub, conn)
 	}
 }
 
-// handleConsulConn is used to service a single Consul RPC connection
-func (s *Server) handleConsulConn(conn net.Conn) {
+// handleConsulConn is used to service a single Consul RPC connection. The
+// peer is the connection to the agent at the other end, which is conn itself
+// unless conn is a multiplexed stream.
+func (s *Server) handleConsulConn(conn, peer net.Conn) {
 	defer conn.Close()
-	rpcCodec := msgpackrpc.NewServerCodec(conn)
+	rpcCodec := &querySourceCodec{
+		ServerCodec: &sourceRateLimitCodec{
+			ServerCodec: msgpackrpc.NewServerCodec(conn),
+			srv:         s,
+			conn:        peer,
+		},
+		srv:  s,
+		conn: peer,
+	}
 	for {
 		select {
 		case <-s.shutdownCh:
@@ -153,6 +216,11 @@ func (s *Server) handleConsulConn(conn net.Conn) {
 		}
 
 		if err := s.rpcServer.ServeRequest(rpcCodec); err != nil {
+			// The error has been sent back as the reply, and the request
+			// was read in full, so the connection can still be used.
+			if structs.IsErrQuerySourceMismatch(err) || structs.IsErrRPCRateExceeded(err) {
+				continue
+			}
 			if err != io.EOF && !strings.Contains(err.Error(), "closed") {
 				s.logger.Printf("[ERR] consul.rpc: RPC error: %v %s", err, logConn(conn))
 				metrics.IncrCounter([]string{"rpc", "request_error"}, 1)
@@ -182,6 +250,11 @@ func canRetry(args interface{}, err error) bool {
 		return true
 	}
 
+	// So are requests the leader lost leadership before handling.
+	if isErrLeadershipChange(args, err) {
+		return true
+	}
+
 	// Reads are safe to retry for stream errors, such as if a server was
 	// being shut down.
 	info, ok := args.(structs.RPCInfo)
@@ -192,11 +265,58 @@ func canRetry(args interface{}, err error) bool {
 	return false
 }
 
+// isErrLeadershipChange returns true if the given error means the server that
+// handled the request lost leadership before it could, so the request can be
+// retried with the new leader. Writes are only retried if they never made it
+// into the Raft log, since losing leadership after that leaves it unknown
+// whether they'll be applied. Errors from other servers come back as strings,
+// so they're matched by their text.
+func isErrLeadershipChange(args interface{}, err error) bool {
+	if err == nil {
+		return false
+	}
+	if strings.Contains(err.Error(), raft.ErrNotLeader.Error()) {
+		return true
+	}
+	info, ok := args.(structs.RPCInfo)
+	return ok && info.IsRead() && strings.Contains(err.Error(), raft.ErrLeadershipLost.Error())
+}
+
+// pastDeadline returns true if the request has a deadline that has passed.
+func pastDeadline(info structs.RPCInfo) bool {
+	deadline := info.Deadline()
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+// setForwardTimeout sets the timeout of a request about to be sent to
+// another server to the time left before its deadline, so the deadline
+// holds end to end. It returns ErrRequestTimeout if there's no time left.
+func setForwardTimeout(args interface{}) error {
+	info, ok := args.(structs.RPCInfo)
+	if !ok {
+		return nil
+	}
+	deadline := info.Deadline()
+	if deadline.IsZero() {
+		return nil
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		metrics.IncrCounter([]string{"rpc", "request_timeout"}, 1)
+		return structs.ErrRequestTimeout
+	}
+	info.SetRequestTimeout(left)
+	return nil
+}
+
 // forward is used to forward to a remote DC or to forward to the local leader
 // Returns a bool of if forwarding was performed, as well as any error
 func (s *Server) forward(method string, info structs.RPCInfo, args interface{}, reply interface{}) (bool, error) {
 	var firstCheck time.Time
 
+	// The clock on the request's timeout starts when we first see it.
+	info.StartDeadline()
+
 	// Handle DC forwarding
 	dc := info.RequestDatacenter()
 	if dc != s.config.Datacenter {
@@ -206,7 +326,7 @@ func (s *Server) forward(method string, info structs.RPCInfo, args interface{},
 
 	// Check if we can allow a stale read, ensure our local DB is initialized
 	if info.IsRead() && info.AllowStaleRead() && !s.raft.LastContact().IsZero() {
-		return false, nil
+		return s.handleLocally(method, info)
 	}
 
 CHECK_LEADER:
@@ -222,12 +342,19 @@ CHECK_LEADER:
 
 	// Handle the case we are the leader
 	if isLeader {
-		return false, nil
+		return s.handleLocally(method, info)
 	}
 
 	// Handle the case of a known leader
 	rpcErr := structs.ErrNoLeader
 	if leader != nil {
+		if err := checkServerFeatures(leader, method, args); err != nil {
+			return true, err
+		}
+		if err := setForwardTimeout(args); err != nil {
+			return true, err
+		}
+		s.logger.Printf("[DEBUG] consul.rpc: Forwarding %q RPC to leader %s%s", method, leader.Addr, traceField(args))
 		rpcErr = s.connPool.RPC(s.config.Datacenter, leader.Addr,
 			leader.Version, method, leader.UseTLS, args, reply)
 		if rpcErr != nil && canRetry(info, rpcErr) {
@@ -237,12 +364,18 @@ CHECK_LEADER:
 	}
 
 RETRY:
-	// Gate the request until there is a leader
+	// Gate the request until there is a leader. A leader that just lost
+	// leadership is usually replaced quickly, so those retries are given
+	// less time.
 	if firstCheck.IsZero() {
 		firstCheck = time.Now()
 	}
-	if time.Since(firstCheck) < s.config.RPCHoldTimeout {
-		jitter := lib.RandomStagger(s.config.RPCHoldTimeout / jitterFraction)
+	hold := s.config.RPCHoldTimeout
+	if isErrLeadershipChange(args, rpcErr) {
+		hold = s.config.RPCLeaderRetryWait
+	}
+	if time.Since(firstCheck) < hold && !pastDeadline(info) {
+		jitter := lib.RandomStagger(hold / jitterFraction)
 		select {
 		case <-time.After(jitter):
 			goto CHECK_LEADER
@@ -251,10 +384,35 @@ RETRY:
 		}
 	}
 
-	// No leader found and hold time exceeded
+	// No leader found and hold time or deadline exceeded
 	return true, rpcErr
 }
 
+// handleLocally is called before a request is handled by this server rather
+// than forwarded. It enforces the rate limits and injects any faults. It has
+// the same return values as forward.
+func (s *Server) handleLocally(method string, info structs.RPCInfo) (bool, error) {
+	if done, err := s.rateLimitRPC(method, info); done {
+		return done, err
+	}
+	if err := s.faults.inject(method); err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+// rateLimitRPC enforces the RPC rate limits on a request this server is about
+// to handle itself. Requests are only counted by the server that handles them,
+// not by servers forwarding them. It has the same return values as forward.
+func (s *Server) rateLimitRPC(method string, info structs.RPCInfo) (bool, error) {
+	if s.rpcLimiter.Load().(*RPCRateLimiter).Allow(info.TokenSecret(), info.IsRead()) {
+		return false, nil
+	}
+	metrics.IncrCounterWithLabels([]string{"rpc", "rate_limit", "exceeded"}, 1,
+		[]metrics.Label{{Name: "method", Value: method}})
+	return true, structs.ErrRPCRateExceeded
+}
+
 // getLeader returns if the current node is the leader, and if not then it
 // returns the leader which is potentially nil if the cluster has not yet
 // elected a leader.
@@ -279,21 +437,60 @@ func (s *Server) getLeader() (bool, *metadata.Server) {
 
 // forwardDC is used to forward an RPC call to a remote DC, or fail if no servers
 func (s *Server) forwardDC(method, dc string, args interface{}, reply interface{}) error {
-	manager, server, ok := s.router.FindRoute(dc)
-	if !ok {
-		s.logger.Printf("[WARN] consul.rpc: RPC request for DC %q, no path found", dc)
-		return structs.ErrNoDCPath
+	if err := s.checkForwardingAllowed(dc); err != nil {
+		return err
 	}
 
-	metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc"}, 1,
-		[]metrics.Label{{Name: "datacenter", Value: dc}})
-	if err := s.connPool.RPC(dc, server.Addr, server.Version, method, server.UseTLS, args, reply); err != nil {
+	labels := []metrics.Label{{Name: "datacenter", Value: dc}}
+	metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc"}, 1, labels)
+
+	backoff := crossDCRetryBackoff
+	for attempt := 0; ; attempt++ {
+		// Fail fast while the datacenter is known to be unreachable, rather
+		// than have requests pile up waiting to time out.
+		if !s.dcBreakers.allow(dc) {
+			metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "fast_fail"}, 1, labels)
+			return structs.ErrDCUnavailable
+		}
+
+		manager, server, ok := s.router.FindRoute(dc)
+		if !ok {
+			s.logger.Printf("[WARN] consul.rpc: RPC request for DC %q, no path found", dc)
+			return structs.ErrNoDCPath
+		}
+
+		if err := checkServerFeatures(server, method, args); err != nil {
+			return err
+		}
+		if err := setForwardTimeout(args); err != nil {
+			return err
+		}
+		s.logger.Printf("[DEBUG] consul.rpc: Forwarding %q RPC to server %s in DC %q%s", method, server.Addr, dc, traceField(args))
+		err := s.connPool.RPC(dc, server.Addr, server.Version, method, server.UseTLS, args, reply)
+		s.recordForward(dc, err)
+		if err == nil {
+			return nil
+		}
 		manager.NotifyFailedServer(server)
-		s.logger.Printf("[ERR] consul: RPC failed to server %s in DC %q: %v", server.Addr, dc, err)
-		return err
-	}
+		s.logger.Printf("[ERR] consul: RPC failed to server %s in DC %q: %v%s", server.Addr, dc, err, traceField(args))
 
-	return nil
+		// Retry requests that didn't reach a server a bounded number of
+		// times, backing off in between. The failed server was moved to
+		// the back of the list, so the next attempt goes to another one.
+		if attempt >= crossDCMaxRetries || !isForwardTransportErr(err) || !canRetryForward(args, err) {
+			return err
+		}
+		if info, ok := args.(structs.RPCInfo); ok && pastDeadline(info) {
+			return err
+		}
+		select {
+		case <-time.After(backoff + lib.RandomStagger(backoff/jitterFraction)):
+		case <-s.shutdownCh:
+			return err
+		}
+		backoff *= 2
+		metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "retry"}, 1, labels)
+	}
 }
 
 // globalRPC is used to forward an RPC request to one server in each datacenter.
@@ -335,22 +532,113 @@ func (s *Server) globalRPC(method string, args interface{},
 // raftApply is used to encode a message, run it through raft, and return
 // the FSM response along with any errors
 func (s *Server) raftApply(t structs.MessageType, msg interface{}) (interface{}, error) {
-	buf, err := structs.Encode(t, msg)
+	return s.raftApplyWithLimit(t, msg, true)
+}
+
+// raftApplyInternal is raftApply for the writes servers make on their own,
+// such as reconciling the catalog or replicating from another datacenter.
+// These don't go through the apply queue, which only pushes back on writes
+// made by clients, so a write storm can't stall the leader's own work.
+func (s *Server) raftApplyInternal(t structs.MessageType, msg interface{}) (interface{}, error) {
+	return s.raftApplyWithLimit(t, msg, false)
+}
+
+// raftApplyFunc is the signature of raftApply and raftApplyInternal, for
+// helpers used both by endpoints and by the leader.
+type raftApplyFunc func(t structs.MessageType, msg interface{}) (interface{}, error)
+
+// raftApplyWithLimit is used by raftApply and raftApplyInternal, and only
+// waits for room in the apply queue if limit is true.
+func (s *Server) raftApplyWithLimit(t structs.MessageType, msg interface{}, limit bool) (interface{}, error) {
+	if err := s.checkFSMMessageFeatures(t); err != nil {
+		return nil, err
+	}
+	if err := s.faults.inject(structs.FaultRaftApply); err != nil {
+		return nil, err
+	}
+
+	buf, err := encodeRaftEntry(t, msg)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to encode request: %v", err)
 	}
 
 	// Warn if the command is very large
 	if n := len(buf); n > raftWarnSize {
-		s.logger.Printf("[WARN] consul: Attempting to apply large raft entry (%d bytes)", n)
+		s.logger.Printf("[WARN] consul: Attempting to apply large raft entry (%d bytes)%s", n, traceField(msg))
+	}
+
+	// Requests with a deadline only wait for the entry to be applied until
+	// then. Raft can't take the entry back, so it may still be applied
+	// after we've given up on it.
+	var deadline time.Time
+	if info, ok := msg.(structs.RPCInfo); ok {
+		deadline = info.Deadline()
+	}
+
+	// Make sure there's room for another entry in flight. Rejecting it with
+	// a retryable error keeps memory bounded during write storms, and tells
+	// clients to back off.
+	start := time.Now()
+	release := func() {}
+	if limit {
+		if !s.raftApplies.Acquire(deadline) {
+			metrics.IncrCounter([]string{"raft", "apply_queue", "rejected"}, 1)
+			if n := s.raftApplies.Shed(); n > 0 {
+				s.logger.Printf("[WARN] consul: Rejected %d Raft applies because the apply queue is full", n)
+			}
+			return nil, structs.ErrRaftApplyQueueFull
+		}
+		metrics.MeasureSince([]string{"raft", "apply_queue", "wait"}, start)
+		metrics.SetGauge([]string{"raft", "apply_queue", "depth"}, float32(s.raftApplies.Len()))
+		release = s.raftApplies.Release
 	}
+	defer func() {
+		if took := time.Since(start); took > slowApplyThreshold {
+			s.logger.Printf("[WARN] consul: Slow Raft apply of message type %d took %v%s", t, took, traceField(msg))
+		}
+	}()
 
-	future := s.raft.Apply(buf, enqueueLimit)
-	if err := future.Error(); err != nil {
-		return nil, err
+	if deadline.IsZero() {
+		future := s.raft.Apply(buf, enqueueLimit)
+		err := future.Error()
+		release()
+		if err != nil {
+			return nil, err
+		}
+		return future.Response(), nil
 	}
 
-	return future.Response(), nil
+	left := time.Until(deadline)
+	if left <= 0 {
+		release()
+		metrics.IncrCounter([]string{"rpc", "request_timeout"}, 1)
+		return nil, structs.ErrRequestTimeout
+	}
+	timeout := enqueueLimit
+	if left < timeout {
+		timeout = left
+	}
+	future := s.raft.Apply(buf, timeout)
+
+	// The slot is held until Raft is done with the entry, even if we give up
+	// waiting for it.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- future.Error()
+		release()
+	}()
+	timer := time.NewTimer(left)
+	defer timer.Stop()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return nil, err
+		}
+		return future.Response(), nil
+	case <-timer.C:
+		metrics.IncrCounter([]string{"rpc", "request_timeout"}, 1)
+		return nil, structs.ErrRequestTimeout
+	}
 }
 
 // queryFn is used to perform a query operation. If a re-query is needed, the
@@ -361,7 +649,9 @@ func (s *Server) raftApply(t structs.MessageType, msg interface{}) (interface{},
 type queryFn func(memdb.WatchSet, *state.Store) error
 
 // blockingQuery is used to process a potentially blocking query operation.
-func (s *Server) blockingQuery(queryOpts *structs.QueryOptions, queryMeta *structs.QueryMeta,
+// The table is the state store table the query mainly watches, which is used
+// to apply the per-table limits on concurrent blocking queries.
+func (s *Server) blockingQuery(table string, queryOpts *structs.QueryOptions, queryMeta *structs.QueryMeta,
 	fn queryFn) error {
 	var timeout *time.Timer
 
@@ -370,16 +660,48 @@ func (s *Server) blockingQuery(queryOpts *structs.QueryOptions, queryMeta *struc
 		goto RUN_QUERY
 	}
 
+	// An index from the future can't be satisfied by this server's state, for
+	// example if the client last talked to a cluster that has since been
+	// rebuilt. Rather than block until the timeout, answer right away so the
+	// client sees the lower index and resets. Waiting on the index just past
+	// the last one is how clients ask for the next change, so allow that.
+	if lastIndex := s.raft.LastIndex(); queryOpts.MinQueryIndex > lastIndex+1 {
+		s.logger.Printf("[DEBUG] consul.rpc: Blocking query index %d is newer than the last Raft index %d, not blocking",
+			queryOpts.MinQueryIndex, lastIndex)
+		queryOpts.MinQueryIndex = 0
+		goto RUN_QUERY
+	}
+
 	// Restrict the max query time, and ensure there is always one.
-	if queryOpts.MaxQueryTime > maxQueryTime {
-		queryOpts.MaxQueryTime = maxQueryTime
-	} else if queryOpts.MaxQueryTime <= 0 {
+	if queryOpts.MaxQueryTime <= 0 {
 		queryOpts.MaxQueryTime = defaultQueryTime
 	}
+	if limit := time.Duration(atomic.LoadInt64(&s.maxQueryTime)); queryOpts.MaxQueryTime > limit {
+		queryOpts.MaxQueryTime = limit
+	}
 
 	// Apply a small amount of jitter to the request.
 	queryOpts.MaxQueryTime += lib.RandomStagger(queryOpts.MaxQueryTime / jitterFraction)
 
+	// Don't block past the request's deadline, returning whatever we have
+	// by then.
+	if deadline := queryOpts.Deadline(); !deadline.IsZero() {
+		if left := time.Until(deadline); left < queryOpts.MaxQueryTime {
+			queryOpts.MaxQueryTime = left
+		}
+	}
+
+	// Make sure there's room to watch another query. Shedding it with a
+	// retryable error is better than letting watches starve other work.
+	if !s.blockingQueries.Acquire(table, queryOpts.Token) {
+		metrics.IncrCounter([]string{"rpc", "query", "shed"}, 1)
+		if n := s.blockingQueries.Shed(); n > 0 {
+			s.logger.Printf("[WARN] consul.rpc: Rejected %d blocking queries due to limits", n)
+		}
+		return structs.ErrBlockingQueryLimitExceeded
+	}
+	defer s.blockingQueries.Release(table, queryOpts.Token)
+
 	// Setup a query timeout.
 	timeout = time.NewTimer(queryOpts.MaxQueryTime)
 	defer timeout.Stop()
@@ -414,7 +736,11 @@ RUN_QUERY:
 	}
 
 	// Block up to the timeout if we didn't see anything fresh.
+	queryStart := time.Now()
 	err := fn(ws, state)
+	if took := time.Since(queryStart); took > time.Duration(atomic.LoadInt64(&s.slowQueryThreshold)) {
+		s.logger.Printf("[WARN] consul.rpc: Slow query took %v%s", took, traceField(queryOpts))
+	}
 	// Note we check queryOpts.MinQueryIndex is greater than zero to determine if
 	// blocking was requested by client, NOT meta.Index since the state function
 	// might return zero if something is not initialised and care wasn't taken to
@@ -443,15 +769,78 @@ RUN_QUERY:
 	return err
 }
 
+// encodeRaftEntry encodes a message for the Raft log. Trace IDs only tie log
+// lines together, and timeouts only matter while the request is in flight,
+// so they're left out of the entry and restored after.
+func encodeRaftEntry(t structs.MessageType, msg interface{}) ([]byte, error) {
+	type traceIDSetter interface {
+		RequestTraceID() string
+		SetTraceID(string)
+	}
+	if req, ok := msg.(traceIDSetter); ok && req.RequestTraceID() != "" {
+		traceID := req.RequestTraceID()
+		req.SetTraceID("")
+		defer req.SetTraceID(traceID)
+	}
+	type timeoutSetter interface {
+		RequestTimeout() time.Duration
+		SetRequestTimeout(time.Duration)
+	}
+	if req, ok := msg.(timeoutSetter); ok && req.RequestTimeout() != 0 {
+		timeout := req.RequestTimeout()
+		req.SetRequestTimeout(0)
+		defer req.SetRequestTimeout(timeout)
+	}
+	return structs.Encode(t, msg)
+}
+
+// traceField returns the trace ID of an RPC request formatted to be appended
+// to a log line, or an empty string if the request doesn't carry one.
+func traceField(args interface{}) string {
+	info, ok := args.(structs.RPCInfo)
+	if !ok || info.RequestTraceID() == "" {
+		return ""
+	}
+	return " trace_id=" + info.RequestTraceID()
+}
+
 // setQueryMeta is used to populate the QueryMeta data for an RPC call
 func (s *Server) setQueryMeta(m *structs.QueryMeta) {
 	if s.IsLeader() {
 		m.LastContact = 0
 		m.KnownLeader = true
+		m.Stale = false
 	} else {
 		m.LastContact = time.Since(s.raft.LastContact())
 		m.KnownLeader = (s.raft.Leader() != "")
+		m.Stale = true
+	}
+	m.ServedBy = s.config.NodeName
+
+	// The last index may include entries that aren't committed yet. The
+	// commit index is only available from raft's Stats, which is too costly
+	// to build on every query, so the gap is counted from the last index.
+	m.IndexGap = 0
+	if last, applied := s.raft.LastIndex(), s.raft.AppliedIndex(); last > applied {
+		m.IndexGap = last - applied
+	}
+}
+
+// truncateResults returns how many of n sorted results a query should return,
+// given the limit in the request and the server's own limit, and records in
+// the reply's meta whether any results are being left out.
+func (s *Server) truncateResults(q *structs.QueryOptions, m *structs.QueryMeta, n int) int {
+	limit := q.MaxResults
+	if max := s.config.MaxQueryResults; max > 0 && (limit <= 0 || limit > max) {
+		limit = max
+	}
+
+	m.ResultsTruncated = limit > 0 && n > limit
+	if m.ResultsTruncated {
+		metrics.IncrCounter([]string{"rpc", "query", "truncated"}, 1)
+		return limit
 	}
+	return n
 }
 
 // consistentRead is used to ensure we do not perform a stale
@@ -0,0 +1,186 @@
+package consul
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/serf/serf"
+)
+
+// rpcEndpointFeatures maps RPC endpoints to the feature a server needs to
+// support for them to exist.
+var rpcEndpointFeatures = map[string]string{
+	"ChangeFeed":      metadata.FeatureChangeFeed,
+	"ConfigEntry":     metadata.FeatureConfigEntries,
+	"Election":        metadata.FeatureElections,
+	"Namespace":       metadata.FeatureNamespaces,
+	"ResponseSigning": metadata.FeatureResponseSigning,
+	"ServiceFailover": metadata.FeatureServiceFailover,
+}
+
+// rpcMethodFeatures maps RPC methods added to existing endpoints to the
+// feature a server needs to support for them to exist.
+var rpcMethodFeatures = map[string]string{
+	"ACL.Login":              metadata.FeatureACLLogin,
+	"ACL.Logout":             metadata.FeatureACLLogin,
+	"ACL.TokenUsageUpdate":   metadata.FeatureACLTokenUsage,
+	"Operator.LeaderHistory": metadata.FeatureLeaderHistory,
+}
+
+// fsmMessageFeatures maps the FSM message types added after protocol version
+// negotiation to the feature a server needs to support to apply them. Older
+// servers can't apply them, so the leader doesn't write them to the Raft log
+// until every server in the datacenter supports the feature. Snapshot-only
+// types are left out of snapshots until then, since older servers can't
+// restore them either.
+var fsmMessageFeatures = map[structs.MessageType]string{
+	structs.NamespaceRequestType:        metadata.FeatureNamespaces,
+	structs.ServiceFailoverRequestType:  metadata.FeatureServiceFailover,
+	structs.ConfigEntryRequestType:      metadata.FeatureConfigEntries,
+	structs.SnapshotChecksumType:        metadata.FeatureSnapshotChecksum,
+	structs.ChangeFeedEntryType:         metadata.FeatureChangeFeed,
+	structs.ResponseSigningKeyType:      metadata.FeatureResponseSigning,
+	structs.LeaderTransitionType:        metadata.FeatureLeaderHistory,
+	structs.ACLTokenUsageRequestType:    metadata.FeatureACLTokenUsage,
+	structs.ChangeFeedConfigRequestType: metadata.FeatureChangeFeed,
+	structs.BootstrapDataRequestType:    metadata.FeatureBootstrapData,
+}
+
+// requiredFeature returns the feature the server handling the given request
+// needs to support, or an empty string if any server can handle it.
+func requiredFeature(method string, args interface{}) string {
+	if feature, ok := rpcMethodFeatures[method]; ok {
+		return feature
+	}
+	if i := strings.Index(method, "."); i > 0 {
+		if feature, ok := rpcEndpointFeatures[method[:i]]; ok {
+			return feature
+		}
+	}
+
+	// Older servers ignore fields they don't know about, so requests
+	// using newer fields need checking as well.
+	switch req := args.(type) {
+	case *structs.ServiceSpecificRequest:
+		switch {
+		case len(req.MultiDC) > 0:
+			return metadata.FeatureMultiDC
+		case req.SkipFailover:
+			return metadata.FeatureServiceFailover
+		case req.Sign:
+			return metadata.FeatureResponseSigning
+		}
+	case *structs.RegisterRequest:
+		if req.CAS {
+			return metadata.FeatureCatalogCAS
+		}
+	case *structs.DeregisterRequest:
+		if req.CAS {
+			return metadata.FeatureCatalogCAS
+		}
+	case *structs.PreparedQueryRequest:
+		if req.Query != nil && req.Query.Service.PinTTL != "" {
+			return metadata.FeatureQueryPinning
+		}
+	case *structs.PreparedQueryExecuteRequest:
+		if req.PinTTL > 0 {
+			return metadata.FeatureQueryPinning
+		}
+	}
+	return ""
+}
+
+// checkServerFeatures returns an error if the server doesn't support the
+// features needed to handle the given request.
+func checkServerFeatures(server *metadata.Server, method string, args interface{}) error {
+	feature := requiredFeature(method, args)
+	if feature == "" || server.SupportsFeature(feature) {
+		return nil
+	}
+	metrics.IncrCounterWithLabels([]string{"rpc", "feature_not_supported"}, 1,
+		[]metrics.Label{{Name: "feature", Value: feature}})
+	return fmt.Errorf("%v: %q RPC needs feature %q, which server %s (version %s) doesn't support",
+		structs.ErrFeatureNotSupported, method, feature, server.Name, server.Build.String())
+}
+
+// checkConnFeatures is the connection pool's FeatureCheck. It returns an
+// error if the server at the other end of a connection didn't report support
+// for the feature needed to handle the given request when the connection was
+// set up. Unlike checkServerFeatures it doesn't depend on the server's Serf
+// tags being up to date.
+func checkConnFeatures(features map[string]struct{}, method string, args interface{}) error {
+	feature := requiredFeature(method, args)
+	if feature == "" {
+		return nil
+	}
+	if _, ok := features[feature]; ok {
+		return nil
+	}
+	metrics.IncrCounterWithLabels([]string{"rpc", "feature_not_supported"}, 1,
+		[]metrics.Label{{Name: "feature", Value: feature}})
+	return fmt.Errorf("%v: %q RPC needs feature %q, which the server doesn't support",
+		structs.ErrFeatureNotSupported, method, feature)
+}
+
+// serversMissingFeature returns the servers in the given datacenter that
+// don't support the given feature, sorted by name. Failed servers count, since
+// they'll apply the Raft log when they come back, but servers that left don't.
+func serversMissingFeature(members []serf.Member, dc, feature string) []*metadata.Server {
+	var missing []*metadata.Server
+	for _, member := range members {
+		if member.Status != serf.StatusAlive && member.Status != serf.StatusFailed {
+			continue
+		}
+		valid, parts := metadata.IsConsulServer(member)
+		if !valid || parts.Datacenter != dc {
+			continue
+		}
+		if !parts.SupportsFeature(feature) {
+			missing = append(missing, parts)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Name < missing[j].Name
+	})
+	return missing
+}
+
+// checkFSMMessageFeatures returns an error if any server in the datacenter
+// doesn't support the feature the given FSM message type needs.
+func (s *Server) checkFSMMessageFeatures(t structs.MessageType) error {
+	feature, ok := fsmMessageFeatures[t&^structs.IgnoreUnknownTypeFlag]
+	if !ok {
+		return nil
+	}
+	return s.checkDatacenterFeature(feature, fmt.Sprintf("message type %d", t))
+}
+
+// checkDatacenterFeature returns an error if any server in the datacenter
+// doesn't support the given feature. It's for changes to existing FSM
+// messages, which older servers would apply differently, so the leader
+// refuses them until every server has been upgraded. The description says
+// what needs the feature, for the error.
+func (s *Server) checkDatacenterFeature(feature, desc string) error {
+	missing := serversMissingFeature(s.serfLAN.Members(), s.config.Datacenter, feature)
+	if len(missing) == 0 {
+		return nil
+	}
+	metrics.IncrCounterWithLabels([]string{"raft", "apply", "feature_blocked"}, 1,
+		[]metrics.Label{{Name: "feature", Value: feature}})
+	return fmt.Errorf("%v: %s needs feature %q, which server %s (version %s) doesn't support",
+		structs.ErrFeatureNotSupported, desc, feature, missing[0].Name, missing[0].Build.String())
+}
+
+// fsmMessageSupported returns whether every server in the datacenter supports
+// the given FSM message type. It's used to decide what goes in snapshots.
+func (s *Server) fsmMessageSupported(t structs.MessageType) bool {
+	feature, ok := fsmMessageFeatures[t]
+	if !ok {
+		return true
+	}
+	return len(serversMissingFeature(s.serfLAN.Members(), s.config.Datacenter, feature)) == 0
+}
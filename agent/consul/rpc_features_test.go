@@ -0,0 +1,140 @@
+package consul
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/consul/fsm"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/serf/serf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredFeature(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		method string
+		args   interface{}
+		want   string
+	}{
+		{"Status.Ping", struct{}{}, ""},
+		{"Catalog.ListNodes", &structs.DCSpecificRequest{}, ""},
+		{"Namespace.List", &structs.DCSpecificRequest{}, metadata.FeatureNamespaces},
+		{"ServiceFailover.Apply", &structs.ServiceFailoverRequest{}, metadata.FeatureServiceFailover},
+		{"Election.Get", &structs.ElectionSpecificRequest{}, metadata.FeatureElections},
+		{"ConfigEntry.Get", &structs.ConfigEntryQuery{}, metadata.FeatureConfigEntries},
+		{"Health.ServiceNodes", &structs.ServiceSpecificRequest{}, ""},
+		{"Health.ServiceNodes", &structs.ServiceSpecificRequest{SkipFailover: true}, metadata.FeatureServiceFailover},
+		{"Catalog.ServiceNodes", &structs.ServiceSpecificRequest{MultiDC: []string{"dc1", "dc2"}}, metadata.FeatureMultiDC},
+		{"Catalog.Register", &structs.RegisterRequest{}, ""},
+		{"Catalog.Register", &structs.RegisterRequest{CAS: true}, metadata.FeatureCatalogCAS},
+		{"Catalog.Deregister", &structs.DeregisterRequest{CAS: true}, metadata.FeatureCatalogCAS},
+		{"ACL.Login", &structs.ACLLoginRequest{}, metadata.FeatureACLLogin},
+		{"PreparedQuery.Apply", &structs.PreparedQueryRequest{Query: &structs.PreparedQuery{}}, ""},
+		{"PreparedQuery.Apply", &structs.PreparedQueryRequest{Query: &structs.PreparedQuery{Service: structs.ServiceQuery{PinTTL: "1m"}}}, metadata.FeatureQueryPinning},
+		{"PreparedQuery.Execute", &structs.PreparedQueryExecuteRequest{PinTTL: time.Minute}, metadata.FeatureQueryPinning},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, requiredFeature(tc.method, tc.args), tc.method)
+	}
+}
+
+func TestCheckServerFeatures(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	server := &metadata.Server{
+		Name:     "s1",
+		Features: map[string]struct{}{metadata.FeatureNamespaces: {}},
+	}
+	require.NoError(checkServerFeatures(server, "Status.Ping", struct{}{}))
+	require.NoError(checkServerFeatures(server, "Namespace.List", &structs.DCSpecificRequest{}))
+
+	err := checkServerFeatures(server, "ServiceFailover.List", &structs.DCSpecificRequest{})
+	require.True(structs.IsErrFeatureNotSupported(err), "err: %v", err)
+	require.Contains(err.Error(), "s1")
+}
+
+func TestCheckConnFeatures(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	features := map[string]struct{}{metadata.FeatureNamespaces: {}}
+	require.NoError(checkConnFeatures(features, "Status.Ping", struct{}{}))
+	require.NoError(checkConnFeatures(features, "Namespace.List", &structs.DCSpecificRequest{}))
+
+	err := checkConnFeatures(features, "ServiceFailover.List", &structs.DCSpecificRequest{})
+	require.True(structs.IsErrFeatureNotSupported(err), "err: %v", err)
+
+	// Servers that couldn't report their features support none.
+	err = checkConnFeatures(map[string]struct{}{}, "Namespace.List", &structs.DCSpecificRequest{})
+	require.True(structs.IsErrFeatureNotSupported(err), "err: %v", err)
+}
+
+func TestServersMissingFeature(t *testing.T) {
+	t.Parallel()
+
+	makeMember := func(name, dc, features string, status serf.MemberStatus) serf.Member {
+		return serf.Member{
+			Name: name,
+			Addr: net.IP([]byte{127, 0, 0, 1}),
+			Tags: map[string]string{
+				"role":  "consul",
+				"id":    name,
+				"dc":    dc,
+				"port":  "8300",
+				"build": "1.4.0",
+				"vsn":   "2",
+				"ft":    features,
+			},
+			Status: status,
+		}
+	}
+	members := []serf.Member{
+		makeMember("s3", "dc1", "", serf.StatusFailed),
+		makeMember("s1", "dc1", "ns,sfo", serf.StatusAlive),
+		makeMember("s2", "dc1", "ns", serf.StatusAlive),
+		makeMember("s4", "dc1", "", serf.StatusLeft),
+		makeMember("s5", "dc2", "", serf.StatusAlive),
+	}
+
+	names := func(servers []*metadata.Server) []string {
+		var out []string
+		for _, server := range servers {
+			out = append(out, server.Name)
+		}
+		return out
+	}
+	require.Equal(t, []string{"s3"}, names(serversMissingFeature(members, "dc1", metadata.FeatureNamespaces)))
+	require.Equal(t, []string{"s2", "s3"}, names(serversMissingFeature(members, "dc1", metadata.FeatureServiceFailover)))
+	require.Equal(t, []string{"s5"}, names(serversMissingFeature(members, "dc2", metadata.FeatureNamespaces)))
+}
+
+func TestFSMMessageFeatures(t *testing.T) {
+	t.Parallel()
+
+	// Every message type added after ConnectCALeafRequestType, including
+	// the snapshot-only ones, is unknown to older servers, which panic on
+	// it in the Raft log and fail to restore it from a snapshot. They all
+	// need a feature so they aren't written until every server has it.
+	supported := make(map[string]bool)
+	for _, feature := range metadata.SupportedFeatures() {
+		supported[feature] = true
+	}
+	for _, msg := range fsm.MessageTypes() {
+		if msg <= structs.ConnectCALeafRequestType {
+			continue
+		}
+		feature, ok := fsmMessageFeatures[msg]
+		if !ok {
+			t.Errorf("message type %d isn't gated on a feature", msg)
+			continue
+		}
+		if !supported[feature] {
+			t.Errorf("message type %d is gated on feature %q, which isn't supported", msg, feature)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package consul
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// forwardingRestricted returns true if forwarding to and from other
+// datacenters is limited by an allow or deny list.
+func (s *Server) forwardingRestricted() bool {
+	return len(s.config.DatacenterForwardingAllow) > 0 || len(s.config.DatacenterForwardingDeny) > 0
+}
+
+// forwardingAllowed returns true if requests may be forwarded to, and
+// accepted from, the given datacenter. The deny list takes precedence over
+// the allow list, and an empty allow list allows every datacenter.
+func (s *Server) forwardingAllowed(dc string) bool {
+	if dc == s.config.Datacenter {
+		return true
+	}
+	for _, denied := range s.config.DatacenterForwardingDeny {
+		if dc == denied {
+			return false
+		}
+	}
+	if len(s.config.DatacenterForwardingAllow) == 0 {
+		return true
+	}
+	for _, allowed := range s.config.DatacenterForwardingAllow {
+		if dc == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkForwardingAllowed returns an error if requests may not be forwarded
+// to the given datacenter.
+func (s *Server) checkForwardingAllowed(dc string) error {
+	if s.forwardingAllowed(dc) {
+		return nil
+	}
+	metrics.IncrCounterWithLabels([]string{"rpc", "cross-dc", "denied"}, 1,
+		[]metrics.Label{{Name: "datacenter", Value: dc}})
+	return fmt.Errorf("%v: %q", structs.ErrForwardingNotAllowed, dc)
+}
+
+// connDatacenter returns the datacenter of the server at the other end of
+// an incoming TLS connection, if its verified certificate is issued to a
+// server in another datacenter. Connections can't be attributed to a
+// datacenter any other way, which is why the allow and deny lists need
+// verify_incoming.
+func (s *Server) connDatacenter(conn net.Conn) (string, bool) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", false
+	}
+	chains := tlsConn.ConnectionState().VerifiedChains
+	if len(chains) == 0 {
+		return "", false
+	}
+
+	dc, ok := certDatacenter(chains[0][0], s.config.Domain)
+	if !ok || dc == s.config.Datacenter {
+		return "", false
+	}
+	return dc, true
+}
+
+// certDatacenter returns the datacenter from the server.<dc>.<domain> name a
+// server certificate is issued to.
+func certDatacenter(cert *x509.Certificate, domain string) (string, bool) {
+	if domain == "" {
+		domain = "consul."
+	}
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		if node, dc, ok := parseGatewayServerName(name, domain); ok && node == "" {
+			return strings.ToLower(dc), true
+		}
+	}
+	return "", false
+}
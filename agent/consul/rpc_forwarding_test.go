@@ -0,0 +1,141 @@
+package consul
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ForwardingAllowed(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		allow []string
+		deny  []string
+		want  map[string]bool
+	}{
+		{
+			"unrestricted",
+			nil,
+			nil,
+			map[string]bool{"dc1": true, "dc2": true, "dc3": true},
+		},
+		{
+			"allow list",
+			[]string{"dc2"},
+			nil,
+			map[string]bool{"dc1": true, "dc2": true, "dc3": false},
+		},
+		{
+			"deny list",
+			nil,
+			[]string{"dc2"},
+			map[string]bool{"dc1": true, "dc2": false, "dc3": true},
+		},
+		{
+			"deny wins",
+			[]string{"dc2", "dc3"},
+			[]string{"dc2"},
+			map[string]bool{"dc1": true, "dc2": false, "dc3": true},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Server{config: &Config{
+				Datacenter:                "dc1",
+				DatacenterForwardingAllow: tc.allow,
+				DatacenterForwardingDeny:  tc.deny,
+			}}
+			for dc, want := range tc.want {
+				require.Equal(t, want, s.forwardingAllowed(dc), dc)
+			}
+		})
+	}
+}
+
+func TestCertDatacenter(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		cn     string
+		dns    []string
+		domain string
+		dc     string
+		ok     bool
+	}{
+		{"common name", "server.dc2.consul", nil, "consul.", "dc2", true},
+		{"dns name", "Alice", []string{"localhost", "server.dc2.consul"}, "consul.", "dc2", true},
+		{"default domain", "server.dc2.consul", nil, "", "dc2", true},
+		{"other domain", "server.dc2.consul", nil, "example.", "", false},
+		{"client", "client.dc2.consul", nil, "consul.", "", false},
+		{"gateway name", "node1.server.dc2.consul", nil, "consul.", "", false},
+		{"no datacenter", "server.consul", nil, "consul.", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cert := &x509.Certificate{
+				Subject:  pkix.Name{CommonName: tc.cn},
+				DNSNames: tc.dns,
+			}
+			dc, ok := certDatacenter(cert, tc.domain)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.dc, dc)
+		})
+	}
+}
+
+func TestServer_ForwardingDenied(t *testing.T) {
+	t.Parallel()
+
+	// Both servers use a certificate issued to server.dc1.consul, so dc2
+	// sees requests from dc1 coming from there.
+	withTLS := func(c *Config) {
+		c.CAFile = "../../test/hostname/CertAuth.crt"
+		c.CertFile = "../../test/hostname/Alice.crt"
+		c.KeyFile = "../../test/hostname/Alice.key"
+		c.VerifyIncoming = true
+		c.VerifyOutgoing = true
+	}
+	dir1, s1 := testServerWithConfig(t, withTLS)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc2"
+		c.DatacenterForwardingDeny = []string{"dc1"}
+		withTLS(c)
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+	testrpc.WaitForLeader(t, s2.RPC, "dc2")
+
+	joinWAN(t, s2, s1)
+	retry.Run(t, func(r *retry.R) {
+		if _, _, ok := s1.router.FindRoute("dc2"); !ok {
+			r.Fatal("no route to dc2")
+		}
+		if _, _, ok := s2.router.FindRoute("dc1"); !ok {
+			r.Fatal("no route to dc1")
+		}
+	})
+
+	// dc2 won't forward requests to dc1.
+	args := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var out structs.IndexedNodes
+	err := s2.RPC("Catalog.ListNodes", &args, &out)
+	require.True(t, structs.IsErrForwardingNotAllowed(err), "err: %v", err)
+
+	// Nor accept requests forwarded from it.
+	args.Datacenter = "dc2"
+	err = s1.RPC("Catalog.ListNodes", &args, &out)
+	require.Error(t, err)
+}
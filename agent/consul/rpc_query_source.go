@@ -0,0 +1,210 @@
+package consul
+
+import (
+	"crypto/tls"
+	"net"
+	"net/rpc"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+const (
+	// QuerySourceTrust uses the query source of requests as given, which
+	// lets an agent sort results by the distance from any node.
+	QuerySourceTrust = "trust"
+
+	// QuerySourceOverride replaces a query source that doesn't match the
+	// agent making the request with that agent, or drops it if the agent
+	// can't be identified.
+	QuerySourceOverride = "override"
+
+	// QuerySourceReject fails requests whose query source doesn't match the
+	// agent making them.
+	QuerySourceReject = "reject"
+)
+
+// querySourcePeer is what a server knows about the agent at the other end of
+// an RPC connection.
+type querySourcePeer struct {
+	// server is true if the connection is from a Consul server, which only
+	// sends requests it has already checked, or that were made by its own
+	// agent.
+	server bool
+
+	// nodes are the LAN members the connection could be from: the ones its
+	// client certificate is issued to, or without one, the ones that gossip
+	// on its address. There can be more than one when agents share an
+	// address.
+	nodes []string
+}
+
+// has returns true if the connection could be from the given node.
+func (p *querySourcePeer) has(node string) bool {
+	for _, n := range p.nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// querySourceMembersTTL is how long the Serf members are cached for to
+// identify the agents making requests.
+const querySourceMembersTTL = 5 * time.Second
+
+// querySourceMembers are the Serf members, indexed to identify the agent at
+// the other end of a connection.
+type querySourceMembers struct {
+	// servers are the addresses of the Consul servers, in this datacenter
+	// and others.
+	servers map[string]bool
+
+	// nodes are the names of the LAN members by their address, and names
+	// are all of them.
+	nodes map[string][]string
+	names map[string]bool
+
+	built time.Time
+}
+
+// getQuerySourceMembers returns the cached Serf members, indexing them again
+// if the cache is too old.
+func (s *Server) getQuerySourceMembers() *querySourceMembers {
+	s.querySourceMembersLock.Lock()
+	defer s.querySourceMembersLock.Unlock()
+
+	if m := s.querySourceMembers; m != nil && time.Since(m.built) < querySourceMembersTTL {
+		return m
+	}
+
+	m := &querySourceMembers{
+		servers: make(map[string]bool),
+		nodes:   make(map[string][]string),
+		names:   make(map[string]bool),
+		built:   time.Now(),
+	}
+	for _, member := range s.LANMembers() {
+		addr := member.Addr.String()
+		if ok, _ := metadata.IsConsulServer(member); ok {
+			m.servers[addr] = true
+		}
+		m.nodes[addr] = append(m.nodes[addr], member.Name)
+		m.names[member.Name] = true
+	}
+	for _, member := range s.WANMembers() {
+		if ok, _ := metadata.IsConsulServer(member); ok {
+			m.servers[member.Addr.String()] = true
+		}
+	}
+	s.querySourceMembers = m
+	return m
+}
+
+// querySourcePeer works out who is at the other end of the connection.
+// Connections with a verified client certificate are identified by it
+// alone: the names it's issued to are matched against the LAN members, and
+// server.<dc>.<domain> certificates are from servers. Other connections are
+// identified by matching their address against the Serf members.
+func (s *Server) querySourcePeer(conn net.Conn) *querySourcePeer {
+	peer := &querySourcePeer{}
+	members := s.getQuerySourceMembers()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if chains := tlsConn.ConnectionState().VerifiedChains; len(chains) > 0 {
+			cert := chains[0][0]
+			_, peer.server = certDatacenter(cert, s.config.Domain)
+			seen := make(map[string]bool)
+			for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+				if members.names[name] && !seen[name] {
+					seen[name] = true
+					peer.nodes = append(peer.nodes, name)
+				}
+			}
+			return peer
+		}
+	}
+
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		peer.server = members.servers[addr.IP.String()]
+		peer.nodes = members.nodes[addr.IP.String()]
+	}
+	return peer
+}
+
+// querySources returns the query sources of the request that are used to
+// sort the results, if it has any.
+func querySources(args interface{}) []*structs.QuerySource {
+	switch args := args.(type) {
+	case *structs.DCSpecificRequest:
+		return []*structs.QuerySource{&args.Source}
+	case *structs.ServiceSpecificRequest:
+		return []*structs.QuerySource{&args.Source}
+	case *structs.ChecksInStateRequest:
+		return []*structs.QuerySource{&args.Source}
+	case *structs.PreparedQueryExecuteRequest:
+		// The magic "_agent" and "_ip" nodes are resolved by the server,
+		// from the agent and the source IP.
+		sources := []*structs.QuerySource{&args.Agent}
+		if args.Source.Node != "_agent" && args.Source.Node != "_ip" {
+			sources = append(sources, &args.Source)
+		}
+		return sources
+	}
+	return nil
+}
+
+// checkQuerySource checks the query sources of a request against the agent
+// that made it, according to the given mode. In override mode, sources that
+// don't match are changed in place.
+func checkQuerySource(mode, dc string, args interface{}, peerFn func() *querySourcePeer) error {
+	if mode != QuerySourceOverride && mode != QuerySourceReject {
+		return nil
+	}
+
+	var peer *querySourcePeer
+	for _, source := range querySources(args) {
+		if source.Node == "" {
+			continue
+		}
+		if peer == nil {
+			peer = peerFn()
+		}
+		if peer.server || (source.Datacenter == dc && peer.has(source.Node)) {
+			continue
+		}
+
+		metrics.IncrCounterWithLabels([]string{"rpc", "query_source", "mismatch"}, 1,
+			[]metrics.Label{{Name: "mode", Value: mode}})
+		if mode == QuerySourceReject {
+			return structs.ErrQuerySourceMismatch
+		}
+
+		// Only override with a node we're sure of, otherwise there's no sort.
+		source.Datacenter = dc
+		source.Node = ""
+		if len(peer.nodes) == 1 {
+			source.Node = peer.nodes[0]
+		}
+	}
+	return nil
+}
+
+// querySourceCodec checks the query source of the requests read from an RPC
+// connection against the agent at the other end of it.
+type querySourceCodec struct {
+	rpc.ServerCodec
+	srv  *Server
+	conn net.Conn
+}
+
+// ReadRequestBody reads the request and checks its query source.
+func (c *querySourceCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	return checkQuerySource(c.srv.config.QuerySourceValidation, c.srv.config.Datacenter, body,
+		func() *querySourcePeer { return c.srv.querySourcePeer(c.conn) })
+}
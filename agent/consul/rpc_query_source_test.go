@@ -0,0 +1,106 @@
+package consul
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckQuerySource(t *testing.T) {
+	t.Parallel()
+
+	agent := &querySourcePeer{nodes: []string{"node1"}}
+	shared := &querySourcePeer{nodes: []string{"node1", "node2"}}
+	server := &querySourcePeer{server: true, nodes: []string{"server1"}}
+
+	source := func(node string) structs.QuerySource {
+		return structs.QuerySource{Datacenter: "dc1", Node: node}
+	}
+
+	cases := []struct {
+		name   string
+		mode   string
+		peer   *querySourcePeer
+		source structs.QuerySource
+		want   structs.QuerySource
+		err    bool
+	}{
+		{"trust", QuerySourceTrust, agent, source("node2"), source("node2"), false},
+		{"no source", QuerySourceReject, agent, source(""), source(""), false},
+		{"match", QuerySourceReject, agent, source("node1"), source("node1"), false},
+		{"shared address", QuerySourceReject, shared, source("node2"), source("node2"), false},
+		{"server", QuerySourceReject, server, source("node2"), source("node2"), false},
+		{"reject", QuerySourceReject, agent, source("node2"), source("node2"), true},
+		{"reject other dc", QuerySourceReject, agent,
+			structs.QuerySource{Datacenter: "dc2", Node: "node1"},
+			structs.QuerySource{Datacenter: "dc2", Node: "node1"}, true},
+		{"override", QuerySourceOverride, agent, source("node2"), source("node1"), false},
+		{"override unknown", QuerySourceOverride, shared, source("node3"), source(""), false},
+		{"override other dc", QuerySourceOverride, agent,
+			structs.QuerySource{Datacenter: "dc2", Node: "node1"}, source("node1"), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := &structs.ServiceSpecificRequest{Source: tc.source}
+			err := checkQuerySource(tc.mode, "dc1", args, func() *querySourcePeer { return tc.peer })
+			if tc.err {
+				require.True(t, structs.IsErrQuerySourceMismatch(err))
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.want, args.Source)
+		})
+	}
+
+	// The magic prepared query nodes are left for the server to resolve, but
+	// the agent is still checked.
+	args := &structs.PreparedQueryExecuteRequest{
+		Source: source("_agent"),
+		Agent:  source("node2"),
+	}
+	require.NoError(t, checkQuerySource(QuerySourceOverride, "dc1", args, func() *querySourcePeer { return agent }))
+	require.Equal(t, source("_agent"), args.Source)
+	require.Equal(t, source("node1"), args.Agent)
+
+	// Requests without a query source don't need the peer.
+	err := checkQuerySource(QuerySourceReject, "dc1", &structs.KeyRequest{}, func() *querySourcePeer {
+		t.Fatal("peer shouldn't be looked up")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// addrConn is a connection from the given remote address.
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestServer_querySourcePeer(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Connections without a certificate are matched by their address.
+	conn := &addrConn{addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345}}
+	peer := s1.querySourcePeer(conn)
+	require.True(t, peer.server)
+	require.Equal(t, []string{s1.config.NodeName}, peer.nodes)
+
+	conn.addr = &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 12345}
+	peer = s1.querySourcePeer(conn)
+	require.False(t, peer.server)
+	require.Empty(t, peer.nodes)
+
+	// The members are cached rather than looked up for every request.
+	members := s1.getQuerySourceMembers()
+	require.True(t, members == s1.getQuerySourceMembers())
+}
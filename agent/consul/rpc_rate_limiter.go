@@ -0,0 +1,280 @@
+package consul
+
+import (
+	"net"
+	"net/rpc"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rpcRateLimitReportInterval is how often the server reports the top
+	// consumers of RPC requests. Per-token limiters that saw no requests
+	// during an interval are dropped at the same time.
+	rpcRateLimitReportInterval = time.Minute
+
+	// rpcRateLimitTopConsumers is the number of tokens reported as the top
+	// consumers of RPC requests.
+	rpcRateLimitTopConsumers = 5
+
+	// rpcRateLimitMaxKeys is the most tokens, and separately source IPs,
+	// that get a limiter of their own. Requests with any others share a
+	// single limiter, so made up tokens can't grow the limiter without
+	// bound.
+	rpcRateLimitMaxKeys = 10000
+)
+
+// RPCConsumer summarizes the RPC requests made with a single ACL token over
+// one report interval.
+type RPCConsumer struct {
+	Token    string
+	Requests int
+	Rejected int
+}
+
+// keyRateLimit tracks the limiter and request counts for a single token or
+// source IP. The limiter is nil if the rate is unlimited.
+type keyRateLimit struct {
+	limiter  *rate.Limiter
+	requests int
+	rejected int
+}
+
+// allow counts a request and reports whether it's within the limit.
+func (k *keyRateLimit) allow() bool {
+	k.requests++
+	if k.limiter != nil && !k.limiter.Allow() {
+		k.rejected++
+		return false
+	}
+	return true
+}
+
+// RPCRateLimiter limits the rate of RPC requests a server handles. Reads and
+// writes are each subject to a global limit, and requests made with any one
+// ACL token or from any one source IP are subject to further limits so a
+// single client can't use up the whole budget. All of the limiters share the
+// same burst size. It is safe for concurrent use.
+type RPCRateLimiter struct {
+	sync.Mutex
+
+	read  *rate.Limiter
+	write *rate.Limiter
+	burst int
+
+	tokenRate     rate.Limit
+	tokens        map[string]*keyRateLimit
+	tokenOverflow *keyRateLimit
+
+	ipRate     rate.Limit
+	ips        map[string]*keyRateLimit
+	ipOverflow *keyRateLimit
+}
+
+// NewRPCRateLimiter returns a limiter enforcing the given rates, in requests
+// per second. A rate of rate.Inf disables the respective limit.
+func NewRPCRateLimiter(readRate, writeRate, tokenRate, ipRate rate.Limit, burst int) *RPCRateLimiter {
+	l := &RPCRateLimiter{
+		read:      rate.NewLimiter(readRate, burst),
+		write:     rate.NewLimiter(writeRate, burst),
+		burst:     burst,
+		tokenRate: tokenRate,
+		tokens:    make(map[string]*keyRateLimit),
+		ipRate:    ipRate,
+		ips:       make(map[string]*keyRateLimit),
+	}
+	l.tokenOverflow = l.newKeyRateLimit(tokenRate)
+	l.ipOverflow = l.newKeyRateLimit(ipRate)
+	return l
+}
+
+// newKeyRateLimit returns the limit for a new token or source IP.
+func (l *RPCRateLimiter) newKeyRateLimit(limit rate.Limit) *keyRateLimit {
+	k := &keyRateLimit{}
+	if limit != rate.Inf {
+		k.limiter = rate.NewLimiter(limit, l.burst)
+	}
+	return k
+}
+
+// keyLimit returns the limit for the given key, adding it to keys unless
+// there are too many already, in which case the overflow limit is shared.
+func (l *RPCRateLimiter) keyLimit(keys map[string]*keyRateLimit, key string, limit rate.Limit, overflow *keyRateLimit) *keyRateLimit {
+	if k, ok := keys[key]; ok {
+		return k
+	}
+	if len(keys) >= rpcRateLimitMaxKeys {
+		return overflow
+	}
+	k := l.newKeyRateLimit(limit)
+	keys[key] = k
+	return k
+}
+
+// Allow reports whether a request made with the given token may be handled
+// now. Requests that aren't allowed should be rejected with a retryable
+// error.
+func (l *RPCRateLimiter) Allow(token string, isRead bool) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	// Check the token's limit first so requests rejected because of it
+	// don't use up the global budget.
+	t := l.keyLimit(l.tokens, token, l.tokenRate, l.tokenOverflow)
+	if !t.allow() {
+		return false
+	}
+	global := l.write
+	if isRead {
+		global = l.read
+	}
+	if !global.Allow() {
+		t.rejected++
+		return false
+	}
+	return true
+}
+
+// AllowSource reports whether a request from the given source IP may be
+// handled now. Requests that aren't allowed should be rejected with a
+// retryable error.
+func (l *RPCRateLimiter) AllowSource(ip string) bool {
+	if l.ipRate == rate.Inf {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	return l.keyLimit(l.ips, ip, l.ipRate, l.ipOverflow).allow()
+}
+
+// sourceLimited returns true if requests are limited by their source IP.
+func (l *RPCRateLimiter) sourceLimited() bool {
+	return l.ipRate != rate.Inf
+}
+
+// Report returns the tokens that made the most requests since the last call,
+// along with how many of their requests were rejected, and resets the counts.
+func (l *RPCRateLimiter) Report() []RPCConsumer {
+	l.Lock()
+	defer l.Unlock()
+
+	// Source IPs are only tracked to limit them, so idle ones are dropped
+	// without being reported.
+	for ip, k := range l.ips {
+		if k.requests == 0 {
+			delete(l.ips, ip)
+			continue
+		}
+		k.requests, k.rejected = 0, 0
+	}
+
+	var consumers []RPCConsumer
+	for token, t := range l.tokens {
+		if t.requests == 0 {
+			delete(l.tokens, token)
+			continue
+		}
+		consumers = append(consumers, RPCConsumer{
+			Token:    token,
+			Requests: t.requests,
+			Rejected: t.rejected,
+		})
+		t.requests, t.rejected = 0, 0
+	}
+
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Requests > consumers[j].Requests
+	})
+	if len(consumers) > rpcRateLimitTopConsumers {
+		consumers = consumers[:rpcRateLimitTopConsumers]
+	}
+	return consumers
+}
+
+// rpcRateLimitStats is a long running routine used to report the tokens
+// making the most RPC requests to this server. The tokens are identified by
+// their accessor IDs so no secrets end up in the logs or metrics. The top
+// consumers are only logged when some of their requests were rejected.
+func (s *Server) rpcRateLimitStats() {
+	for {
+		select {
+		case <-time.After(rpcRateLimitReportInterval):
+			for _, c := range s.rpcLimiter.Load().(*RPCRateLimiter).Report() {
+				accessor := s.rpcConsumerAccessor(c.Token)
+				labels := []metrics.Label{{Name: "accessor", Value: accessor}}
+				metrics.SetGaugeWithLabels([]string{"rpc", "rate_limit", "consumer", "requests"}, float32(c.Requests), labels)
+				metrics.SetGaugeWithLabels([]string{"rpc", "rate_limit", "consumer", "rejected"}, float32(c.Rejected), labels)
+				if c.Rejected > 0 {
+					s.logger.Printf("[WARN] consul.rpc: Token %q made %d RPC requests in the last %v, %d of which were rejected by rate limits",
+						accessor, c.Requests, rpcRateLimitReportInterval, c.Rejected)
+				}
+			}
+
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// rpcConsumerAccessor returns the accessor ID to report for the token with the
+// given secret.
+func (s *Server) rpcConsumerAccessor(secret string) string {
+	if secret == "" {
+		return "anonymous"
+	}
+	_, token, err := s.fsm.State().ACLTokenGetBySecret(nil, secret)
+	if err != nil || token == nil || token.AccessorID == "" {
+		return "unknown"
+	}
+	return token.AccessorID
+}
+
+// Limits returns the rates and burst size the limiter enforces.
+func (l *RPCRateLimiter) Limits() (readRate, writeRate, tokenRate, ipRate rate.Limit, burst int) {
+	l.Lock()
+	defer l.Unlock()
+	return l.read.Limit(), l.write.Limit(), l.tokenRate, l.ipRate, l.burst
+}
+
+// rateLimitSource enforces the per-source-IP RPC rate limit on a request read
+// from the given connection. Requests from other servers aren't limited,
+// since they were already counted by the server they arrived at.
+func (s *Server) rateLimitSource(conn net.Conn) error {
+	limiter := s.rpcLimiter.Load().(*RPCRateLimiter)
+	if !limiter.sourceLimited() {
+		return nil
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	ip := addr.IP.String()
+	if s.getQuerySourceMembers().servers[ip] || limiter.AllowSource(ip) {
+		return nil
+	}
+	metrics.IncrCounter([]string{"rpc", "rate_limit", "source_exceeded"}, 1)
+	return structs.ErrRPCRateExceeded
+}
+
+// sourceRateLimitCodec enforces the per-source-IP RPC rate limit on the
+// requests read from a connection.
+type sourceRateLimitCodec struct {
+	rpc.ServerCodec
+	srv  *Server
+	conn net.Conn
+}
+
+// ReadRequestBody reads the request and checks it against the rate limit.
+// A nil body means the request is being discarded, so it isn't counted.
+func (c *sourceRateLimitCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil || body == nil {
+		return err
+	}
+	return c.srv.rateLimitSource(c.conn)
+}
@@ -0,0 +1,139 @@
+package consul
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRPCRateLimiter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Use tiny rates so the buckets don't refill during the test.
+	l := NewRPCRateLimiter(0.001, 0.001, 0.001, rate.Inf, 2)
+
+	// Each token gets its own bucket.
+	require.True(l.Allow("a", false))
+	require.True(l.Allow("a", false))
+	require.False(l.Allow("a", false))
+
+	// The global write bucket is now empty, but reads have their own.
+	require.False(l.Allow("b", false))
+	require.True(l.Allow("b", true))
+	require.True(l.Allow("c", true))
+	require.False(l.Allow("d", true))
+
+	consumers := l.Report()
+	require.Len(consumers, 4)
+	require.Equal(RPCConsumer{Token: "a", Requests: 3, Rejected: 1}, consumers[0])
+	require.Equal(RPCConsumer{Token: "b", Requests: 2, Rejected: 1}, consumers[1])
+
+	// Counts are reset, and idle tokens are dropped on the next report.
+	require.Empty(l.Report())
+	require.Empty(l.tokens)
+}
+
+func TestRPCRateLimiter_unlimited(t *testing.T) {
+	t.Parallel()
+
+	l := NewRPCRateLimiter(rate.Inf, rate.Inf, rate.Inf, rate.Inf, 1)
+	for i := 0; i < 100; i++ {
+		require.True(t, l.Allow("a", i%2 == 0))
+		require.True(t, l.AllowSource("10.0.0.1"))
+	}
+
+	// No limiters are kept for unlimited rates.
+	require.Nil(t, l.tokens["a"].limiter)
+	require.Empty(t, l.ips)
+}
+
+func TestRPCRateLimiter_source(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewRPCRateLimiter(rate.Inf, rate.Inf, rate.Inf, 0.001, 1)
+
+	// Each source IP gets its own bucket.
+	require.True(l.AllowSource("10.0.0.1"))
+	require.False(l.AllowSource("10.0.0.1"))
+	require.True(l.AllowSource("10.0.0.2"))
+
+	// Idle source IPs are dropped on the next report.
+	l.Report()
+	require.Len(l.ips, 2)
+	l.Report()
+	require.Empty(l.ips)
+}
+
+func TestRPCRateLimiter_maxKeys(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	l := NewRPCRateLimiter(rate.Inf, rate.Inf, 0.001, rate.Inf, 1)
+	for i := 0; i < rpcRateLimitMaxKeys; i++ {
+		require.True(l.Allow(fmt.Sprintf("token-%d", i), true))
+	}
+
+	// Tokens past the limit share a single bucket.
+	require.True(l.Allow("extra-1", true))
+	require.False(l.Allow("extra-2", true))
+	require.Len(l.tokens, rpcRateLimitMaxKeys)
+}
+
+// remoteAddrConn is a connection with the given remote address.
+type remoteAddrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *remoteAddrConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func TestServer_rateLimitSource(t *testing.T) {
+	t.Parallel()
+	dir, s := testServerWithConfig(t, func(c *Config) {
+		c.RPCServerIPRate = 0.001
+		c.RPCServerMaxBurst = 1
+	})
+	defer os.RemoveAll(dir)
+	defer s.Shutdown()
+	testrpc.WaitForLeader(t, s.RPC, "dc1")
+
+	client := &remoteAddrConn{addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}}
+	require.NoError(t, s.rateLimitSource(client))
+	err := s.rateLimitSource(client)
+	require.True(t, structs.IsErrRPCRateExceeded(err), "err: %v", err)
+
+	// Requests forwarded by servers aren't limited.
+	server := &remoteAddrConn{addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.rateLimitSource(server))
+	}
+
+	// Reloading the config lifts the limit.
+	require.NoError(t, s.ReloadConfig(DefaultConfig()))
+	require.NoError(t, s.rateLimitSource(client))
+}
+
+func TestRPCRateLimiter_topConsumers(t *testing.T) {
+	t.Parallel()
+
+	l := NewRPCRateLimiter(rate.Inf, rate.Inf, rate.Inf, rate.Inf, 1)
+	for i := 0; i < 2*rpcRateLimitTopConsumers; i++ {
+		for j := 0; j <= i; j++ {
+			l.Allow(string('a'+rune(i)), true)
+		}
+	}
+
+	consumers := l.Report()
+	require.Len(t, consumers, rpcRateLimitTopConsumers)
+	require.Equal(t, 2*rpcRateLimitTopConsumers, consumers[0].Requests)
+}
@@ -2,16 +2,19 @@ package consul
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/consul/agent/consul/state"
 	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/testrpc"
 	"github.com/hashicorp/consul/testutil/retry"
 	"github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/hashicorp/raft"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -121,6 +124,36 @@ func TestRPC_NoLeader_Retry(t *testing.T) {
 	}
 }
 
+func TestRPC_raftApply_NotLeader(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerDCBootstrap(t, "dc1", false)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	// A server that isn't the leader can't apply anything, and the write
+	// can be retried since it never made it into the log.
+	args := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVSet,
+		DirEnt: structs.DirEntry{
+			Key:   "test",
+			Value: []byte("test"),
+		},
+	}
+	_, err := s1.raftApply(structs.KVSRequestType, &args)
+	require.Equal(t, raft.ErrNotLeader, err)
+	require.True(t, canRetry(&args, err))
+
+	// Errors from other servers come back as strings.
+	require.True(t, canRetry(&args, errors.New(raft.ErrNotLeader.Error())))
+
+	// Losing leadership after the write was added to the log leaves it
+	// unknown whether it will be applied, but reads can be retried.
+	lost := errors.New(raft.ErrLeadershipLost.Error())
+	require.False(t, canRetry(&args, lost))
+	require.True(t, canRetry(&structs.KeyRequest{Datacenter: "dc1"}, lost))
+}
+
 type MockSink struct {
 	*bytes.Buffer
 	cancel bool
@@ -144,6 +177,7 @@ func TestRPC_blockingQuery(t *testing.T) {
 	dir, s := testServer(t)
 	defer os.RemoveAll(dir)
 	defer s.Shutdown()
+	testrpc.WaitForLeader(t, s.RPC, "dc1")
 
 	require := require.New(t)
 	assert := assert.New(t)
@@ -159,7 +193,7 @@ func TestRPC_blockingQuery(t *testing.T) {
 			calls++
 			return nil
 		}
-		if err := s.blockingQuery(&opts, &meta, fn); err != nil {
+		if err := s.blockingQuery("kvs", &opts, &meta, fn); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 		if calls != 1 {
@@ -187,7 +221,7 @@ func TestRPC_blockingQuery(t *testing.T) {
 			calls++
 			return nil
 		}
-		if err := s.blockingQuery(&opts, &meta, fn); err != nil {
+		if err := s.blockingQuery("kvs", &opts, &meta, fn); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 		if calls != 2 {
@@ -223,7 +257,7 @@ func TestRPC_blockingQuery(t *testing.T) {
 			calls++
 			return nil
 		}
-		require.NoError(s.blockingQuery(&opts, &meta, fn))
+		require.NoError(s.blockingQuery("kvs", &opts, &meta, fn))
 		assert.Equal(1, calls)
 		assert.Equal(uint64(1), meta.Index,
 			"expect fake index of 1 to force client to block on next update")
@@ -234,7 +268,7 @@ func TestRPC_blockingQuery(t *testing.T) {
 
 		// This time we should block even though the func returns index 0 still
 		t0 := time.Now()
-		require.NoError(s.blockingQuery(&opts, &meta, fn))
+		require.NoError(s.blockingQuery("kvs", &opts, &meta, fn))
 		t1 := time.Now()
 		assert.Equal(2, calls)
 		assert.Equal(uint64(1), meta.Index,
@@ -244,6 +278,49 @@ func TestRPC_blockingQuery(t *testing.T) {
 
 	}
 
+	// Perform a blocking query with an index newer than anything in Raft,
+	// which should return right away instead of waiting for the timeout.
+	{
+		opts := structs.QueryOptions{
+			MinQueryIndex: 1 << 60,
+		}
+		var meta structs.QueryMeta
+		var calls int
+		fn := func(ws memdb.WatchSet, state *state.Store) error {
+			meta.Index = 3
+			ws.Add(make(chan struct{}))
+			calls++
+			return nil
+		}
+		require.NoError(s.blockingQuery("kvs", &opts, &meta, fn))
+		assert.Equal(1, calls)
+		assert.Equal(uint64(3), meta.Index)
+	}
+
+	// Perform a blocking query on the index just past the last Raft index,
+	// which is how clients wait for the next change, so it still blocks.
+	{
+		lastIndex := s.raft.LastIndex()
+		opts := structs.QueryOptions{
+			MinQueryIndex: lastIndex + 1,
+			MaxQueryTime:  20 * time.Millisecond,
+		}
+		var meta structs.QueryMeta
+		var calls int
+		fn := func(ws memdb.WatchSet, state *state.Store) error {
+			meta.Index = lastIndex
+			ws.Add(make(chan struct{}))
+			calls++
+			return nil
+		}
+		t0 := time.Now()
+		require.NoError(s.blockingQuery("kvs", &opts, &meta, fn))
+		assert.Equal(1, calls)
+		assert.Equal(lastIndex, meta.Index)
+		assert.True(time.Since(t0) > 20*time.Millisecond,
+			"should have actually blocked waiting for timeout")
+	}
+
 	// Perform a query that blocks and gets interrupted when the state store
 	// is abandoned.
 	{
@@ -275,7 +352,7 @@ func TestRPC_blockingQuery(t *testing.T) {
 			calls++
 			return nil
 		}
-		if err := s.blockingQuery(&opts, &meta, fn); err != nil {
+		if err := s.blockingQuery("kvs", &opts, &meta, fn); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 		if calls != 1 {
@@ -284,6 +361,243 @@ func TestRPC_blockingQuery(t *testing.T) {
 	}
 }
 
+func TestRPC_RequestTimeout(t *testing.T) {
+	t.Parallel()
+	dir, s := testServerWithConfig(t, func(c *Config) {
+		c.Bootstrap = false
+		c.RPCHoldTimeout = 10 * time.Second
+	})
+	defer os.RemoveAll(dir)
+	defer s.Shutdown()
+
+	// Without a leader, the request gives up at its deadline rather than
+	// waiting for the whole hold timeout.
+	args := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVSet,
+		DirEnt: structs.DirEntry{
+			Key:   "test",
+			Value: []byte("test"),
+		},
+		WriteRequest: structs.WriteRequest{Timeout: 100 * time.Millisecond},
+	}
+	var out bool
+	start := time.Now()
+	err := s.RPC("KVS.Apply", &args, &out)
+	require.True(t, structs.IsErrNoLeader(err), "err: %v", err)
+	require.True(t, time.Since(start) < 5*time.Second, "took %v", time.Since(start))
+
+	// Blocking queries return at the deadline.
+	opts := structs.QueryOptions{
+		MinQueryIndex: 3,
+		MaxQueryTime:  10 * time.Second,
+		Timeout:       100 * time.Millisecond,
+	}
+	opts.StartDeadline()
+	var meta structs.QueryMeta
+	fn := func(ws memdb.WatchSet, state *state.Store) error {
+		meta.Index = 3
+		return nil
+	}
+	start = time.Now()
+	require.NoError(t, s.blockingQuery("kvs", &opts, &meta, fn))
+	require.True(t, time.Since(start) < 5*time.Second, "took %v", time.Since(start))
+
+	// Writes aren't submitted once the deadline has passed.
+	args.WriteRequest = structs.WriteRequest{Timeout: time.Nanosecond}
+	args.StartDeadline()
+	time.Sleep(time.Millisecond)
+	_, err = s.raftApply(structs.KVSRequestType, &args)
+	require.True(t, structs.IsErrRequestTimeout(err), "err: %v", err)
+}
+
+func TestRPC_rateLimit(t *testing.T) {
+	t.Parallel()
+	dir, s := testServerWithConfig(t, func(c *Config) {
+		c.RPCServerWriteRate = 0.001
+		c.RPCServerMaxBurst = 1
+	})
+	defer os.RemoveAll(dir)
+	defer s.Shutdown()
+	testrpc.WaitForLeader(t, s.RPC, "dc1")
+
+	args := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVSet,
+		DirEnt: structs.DirEntry{
+			Key:   "test",
+			Value: []byte("test"),
+		},
+	}
+	var out bool
+	require.NoError(t, s.RPC("KVS.Apply", &args, &out))
+
+	// The write budget is used up, but reads are still allowed.
+	err := s.RPC("KVS.Apply", &args, &out)
+	require.True(t, structs.IsErrRPCRateExceeded(err), "err: %v", err)
+
+	getArgs := structs.KeyRequest{Datacenter: "dc1", Key: "test"}
+	var dirent structs.IndexedDirEntries
+	require.NoError(t, s.RPC("KVS.Get", &getArgs, &dirent))
+
+	// Reloading the config lifts the limit.
+	conf := DefaultConfig()
+	require.NoError(t, s.ReloadConfig(conf))
+	require.NoError(t, s.RPC("KVS.Apply", &args, &out))
+
+	// Limits changed at runtime are kept when the config is reloaded.
+	var settings structs.RuntimeSettings
+	s.RuntimeSettings(&settings)
+	settings.RPCServerWriteRate = 0.001
+	settings.RPCServerMaxBurst = 1
+	s.SetRuntimeSettings(&settings)
+	require.NoError(t, s.ReloadConfig(conf))
+	require.NoError(t, s.RPC("KVS.Apply", &args, &out))
+	err = s.RPC("KVS.Apply", &args, &out)
+	require.True(t, structs.IsErrRPCRateExceeded(err), "err: %v", err)
+}
+
+func TestRPC_raftApplyQueue(t *testing.T) {
+	t.Parallel()
+	dir, s := testServerWithConfig(t, func(c *Config) {
+		c.RaftApplyQueueDepth = 1
+		c.RaftApplyQueueWait = 10 * time.Millisecond
+	})
+	defer os.RemoveAll(dir)
+	defer s.Shutdown()
+	testrpc.WaitForLeader(t, s.RPC, "dc1")
+
+	args := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVSet,
+		DirEnt: structs.DirEntry{
+			Key:   "test",
+			Value: []byte("test"),
+		},
+	}
+	var out bool
+	require.NoError(t, s.RPC("KVS.Apply", &args, &out))
+
+	// Fill up the queue so the write gets rejected after waiting.
+	require.True(t, s.raftApplies.Acquire(time.Time{}))
+	err := s.RPC("KVS.Apply", &args, &out)
+	require.True(t, structs.IsErrRaftApplyQueueFull(err), "err: %v", err)
+
+	// The server's own writes don't wait for the queue.
+	_, err = s.raftApplyInternal(structs.KVSRequestType, &args)
+	require.NoError(t, err)
+
+	s.raftApplies.Release()
+	require.NoError(t, s.RPC("KVS.Apply", &args, &out))
+	require.Equal(t, 0, s.raftApplies.Len())
+}
+
+func TestRPC_encodeRaftEntry(t *testing.T) {
+	t.Parallel()
+	args := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         api.KVSet,
+		DirEnt: structs.DirEntry{
+			Key: "test",
+		},
+		WriteRequest: structs.WriteRequest{
+			TraceID: "my-trace",
+			Timeout: 5 * time.Second,
+		},
+	}
+	buf, err := encodeRaftEntry(structs.KVSRequestType, &args)
+	require.NoError(t, err)
+
+	// The trace ID and timeout aren't written to the Raft log, but the
+	// request keeps them.
+	var out structs.KVSRequest
+	require.NoError(t, structs.Decode(buf[1:], &out))
+	require.Equal(t, "test", out.DirEnt.Key)
+	require.Empty(t, out.TraceID)
+	require.Zero(t, out.Timeout)
+	require.Equal(t, "my-trace", args.TraceID)
+	require.Equal(t, 5*time.Second, args.Timeout)
+}
+
+func TestRPC_blockingQuery_limits(t *testing.T) {
+	t.Parallel()
+	dir, s := testServerWithConfig(t, func(c *Config) {
+		c.MaxBlockingQueries = 3
+		c.MaxBlockingQueriesPerTable = map[string]int{"kvs": 2}
+		c.MaxBlockingQueriesPerToken = 1
+	})
+	defer os.RemoveAll(dir)
+	defer s.Shutdown()
+	testrpc.WaitForLeader(t, s.RPC, "dc1")
+
+	require := require.New(t)
+
+	// Start a blocking query that waits until we release it.
+	releaseCh := make(chan struct{})
+	defer close(releaseCh)
+	startCh := make(chan struct{}, 3)
+	block := func(table, token string) chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			opts := structs.QueryOptions{
+				Token:         token,
+				MinQueryIndex: 3,
+			}
+			var meta structs.QueryMeta
+			fn := func(ws memdb.WatchSet, state *state.Store) error {
+				meta.Index = 3
+				ws.Add(releaseCh)
+				startCh <- struct{}{}
+				return nil
+			}
+			errCh <- s.blockingQuery(table, &opts, &meta, fn)
+		}()
+		<-startCh
+		return errCh
+	}
+	fn := func(ws memdb.WatchSet, state *state.Store) error {
+		return nil
+	}
+
+	block("kvs", "foo")
+	require.Equal(1, s.blockingQueries.Len())
+
+	// The same token is over its limit.
+	{
+		opts := structs.QueryOptions{Token: "foo", MinQueryIndex: 3}
+		var meta structs.QueryMeta
+		err := s.blockingQuery("kvs", &opts, &meta, fn)
+		require.True(structs.IsErrBlockingQueryLimitExceeded(err), "err: %v", err)
+	}
+
+	// Non-blocking queries are never limited.
+	{
+		opts := structs.QueryOptions{Token: "foo"}
+		var meta structs.QueryMeta
+		require.NoError(s.blockingQuery("kvs", &opts, &meta, fn))
+	}
+
+	// Another token gets a fair share, which fills up the kvs table.
+	block("kvs", "bar")
+	require.Equal(2, s.blockingQueries.Len())
+	{
+		opts := structs.QueryOptions{Token: "baz", MinQueryIndex: 3}
+		var meta structs.QueryMeta
+		err := s.blockingQuery("kvs", &opts, &meta, fn)
+		require.True(structs.IsErrBlockingQueryLimitExceeded(err), "err: %v", err)
+	}
+
+	// Other tables still have room, up to the global limit.
+	block("services", "baz")
+	require.Equal(3, s.blockingQueries.Len())
+	{
+		opts := structs.QueryOptions{Token: "qux", MinQueryIndex: 3}
+		var meta structs.QueryMeta
+		err := s.blockingQuery("nodes", &opts, &meta, fn)
+		require.True(structs.IsErrBlockingQueryLimitExceeded(err), "err: %v", err)
+	}
+}
+
 func TestRPC_ReadyForConsistentReads(t *testing.T) {
 	t.Parallel()
 	dir, s := testServerWithConfig(t, func(c *Config) {
@@ -2,10 +2,14 @@ package consul
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 	"sort"
+	"time"
 
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/lib"
+	"github.com/hashicorp/go-memdb"
 )
 
 // nodeSorter takes a list of nodes and a parallel vector of distances and
@@ -186,10 +190,12 @@ func (s *Server) newSorterByDistanceFrom(cs lib.CoordinateSet, subj interface{})
 
 // sortNodesByDistanceFrom is used to sort results from our service catalog based
 // on the round trip time from the given source node. Nodes with missing coordinates
-// will get stable sorted at the end of the list.
+// will get stable sorted at the end of the list, and nodes in drain mode after
+// them. Blocking queries pass their watch set and query meta so they're woken
+// up by drain mode changes, other callers can pass nil for both.
 //
 // If coordinates are disabled this will be a no-op.
-func (s *Server) sortNodesByDistanceFrom(source structs.QuerySource, subj interface{}) error {
+func (s *Server) sortNodesByDistanceFrom(ws memdb.WatchSet, meta *structs.QueryMeta, source structs.QuerySource, subj interface{}) error {
 	// We can't sort if there's no source node.
 	if source.Node == "" {
 		return nil
@@ -217,5 +223,99 @@ func (s *Server) sortNodesByDistanceFrom(source structs.QuerySource, subj interf
 		return err
 	}
 	sort.Stable(sorter)
+
+	// Label the results that carry a locality with how close they are.
+	if sorter, ok := sorter.(*checkServiceNodeSorter); ok {
+		for i := range sorter.Nodes {
+			sorter.Nodes[i].Locality = s.locality(sorter.Vec[i])
+		}
+	}
+
+	// Nodes in drain mode go last, however close they are.
+	return s.sortDrainedLast(ws, meta, subj)
+}
+
+// locality returns the label for a node the given distance in seconds away
+// from the source node, or an empty label if the distance isn't known.
+func (s *Server) locality(distance float64) string {
+	if math.IsInf(distance, 1) {
+		return ""
+	}
+
+	rtt := time.Duration(distance * float64(time.Second))
+	switch {
+	case rtt < s.config.LocalityHostRTT:
+		return structs.LocalityHost
+	case rtt < s.config.LocalityZoneRTT:
+		return structs.LocalityZone
+	case rtt < s.config.LocalityRegionRTT:
+		return structs.LocalityRegion
+	default:
+		return structs.LocalityRemote
+	}
+}
+
+// drainedSorter implements sort.Interface for results from our service
+// catalog, keeping the nodes of the results coherent with them, and moves the
+// ones from nodes in drain mode to the end.
+type drainedSorter struct {
+	nodes   []string
+	drained map[string]bool
+	swap    func(i, j int)
+}
+
+func (n *drainedSorter) Len() int {
+	return len(n.nodes)
+}
+
+func (n *drainedSorter) Swap(i, j int) {
+	n.nodes[i], n.nodes[j] = n.nodes[j], n.nodes[i]
+	n.swap(i, j)
+}
+
+func (n *drainedSorter) Less(i, j int) bool {
+	return !n.drained[n.nodes[i]] && n.drained[n.nodes[j]]
+}
+
+// sortDrainedLast does a stable sort of results from our service catalog that
+// moves the ones from nodes in drain mode to the end. The drain checks are
+// looked up in the state store and added to the watch set, and the index of
+// the lookup is folded into the query meta, so blocking queries see nodes
+// entering and leaving drain mode.
+func (s *Server) sortDrainedLast(ws memdb.WatchSet, meta *structs.QueryMeta, subj interface{}) error {
+	var nodes []string
+	switch v := subj.(type) {
+	case structs.CheckServiceNodes:
+		// These carry their checks, which the query already watches.
+		v.SortDrainedLast()
+		return nil
+
+	case structs.Nodes:
+		for _, node := range v {
+			nodes = append(nodes, node.Node)
+		}
+
+	case structs.ServiceNodes:
+		for _, node := range v {
+			nodes = append(nodes, node.Node)
+		}
+
+	case structs.HealthChecks:
+		for _, check := range v {
+			nodes = append(nodes, check.Node)
+		}
+
+	default:
+		return nil
+	}
+
+	idx, drained, err := s.fsm.State().NodesWithCheck(ws, nodes, structs.NodeDrain)
+	if err != nil {
+		return err
+	}
+	if meta != nil && idx > meta.Index {
+		meta.Index = idx
+	}
+	sort.Stable(&drainedSorter{nodes: nodes, drained: drained, swap: reflect.Swapper(subj)})
 	return nil
 }
@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
 )
 
@@ -152,7 +154,7 @@ func TestRTT_sortNodesByDistanceFrom(t *testing.T) {
 
 	// The zero value for the source should not trigger any sorting.
 	var source structs.QuerySource
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "apple,node1,node2,node3,node4,node5")
@@ -160,7 +162,7 @@ func TestRTT_sortNodesByDistanceFrom(t *testing.T) {
 	// Same for a source in some other DC.
 	source.Node = "node1"
 	source.Datacenter = "dc2"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "apple,node1,node2,node3,node4,node5")
@@ -168,7 +170,7 @@ func TestRTT_sortNodesByDistanceFrom(t *testing.T) {
 	// Same for a source node in our DC that we have no coordinate for.
 	source.Node = "apple"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "apple,node1,node2,node3,node4,node5")
@@ -177,7 +179,7 @@ func TestRTT_sortNodesByDistanceFrom(t *testing.T) {
 	// coordinate info so it should end up at the end, despite its lexical
 	// hegemony.
 	source.Node = "node1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "node1,node4,node5,node2,node3,apple")
@@ -209,7 +211,7 @@ func TestRTT_sortNodesByDistanceFrom_Nodes(t *testing.T) {
 	var source structs.QuerySource
 	source.Node = "node1"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "node1,node4,node5,node2,node3,apple")
@@ -219,7 +221,7 @@ func TestRTT_sortNodesByDistanceFrom_Nodes(t *testing.T) {
 	// they were in from the previous sort.
 	source.Node = "node2"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "node2,node5,node3,node4,node1,apple")
@@ -227,7 +229,7 @@ func TestRTT_sortNodesByDistanceFrom_Nodes(t *testing.T) {
 	// Let's exercise the stable sort explicitly to make sure we didn't
 	// just get lucky.
 	nodes[1], nodes[2] = nodes[2], nodes[1]
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyNodeSort(t, nodes, "node2,node3,node5,node4,node1,apple")
@@ -259,7 +261,7 @@ func TestRTT_sortNodesByDistanceFrom_ServiceNodes(t *testing.T) {
 	var source structs.QuerySource
 	source.Node = "node1"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyServiceNodeSort(t, nodes, "node1,node4,node5,node2,node3,apple")
@@ -269,7 +271,7 @@ func TestRTT_sortNodesByDistanceFrom_ServiceNodes(t *testing.T) {
 	// they were in from the previous sort.
 	source.Node = "node2"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyServiceNodeSort(t, nodes, "node2,node5,node3,node4,node1,apple")
@@ -277,7 +279,7 @@ func TestRTT_sortNodesByDistanceFrom_ServiceNodes(t *testing.T) {
 	// Let's exercise the stable sort explicitly to make sure we didn't
 	// just get lucky.
 	nodes[1], nodes[2] = nodes[2], nodes[1]
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyServiceNodeSort(t, nodes, "node2,node3,node5,node4,node1,apple")
@@ -309,7 +311,7 @@ func TestRTT_sortNodesByDistanceFrom_HealthChecks(t *testing.T) {
 	var source structs.QuerySource
 	source.Node = "node1"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, checks); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, checks); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyHealthCheckSort(t, checks, "node1,node4,node5,node2,node3,apple")
@@ -319,7 +321,7 @@ func TestRTT_sortNodesByDistanceFrom_HealthChecks(t *testing.T) {
 	// they were in from the previous sort.
 	source.Node = "node2"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, checks); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, checks); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyHealthCheckSort(t, checks, "node2,node5,node3,node4,node1,apple")
@@ -327,7 +329,7 @@ func TestRTT_sortNodesByDistanceFrom_HealthChecks(t *testing.T) {
 	// Let's exercise the stable sort explicitly to make sure we didn't
 	// just get lucky.
 	checks[1], checks[2] = checks[2], checks[1]
-	if err := server.sortNodesByDistanceFrom(source, checks); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, checks); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyHealthCheckSort(t, checks, "node2,node3,node5,node4,node1,apple")
@@ -359,7 +361,7 @@ func TestRTT_sortNodesByDistanceFrom_CheckServiceNodes(t *testing.T) {
 	var source structs.QuerySource
 	source.Node = "node1"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyCheckServiceNodeSort(t, nodes, "node1,node4,node5,node2,node3,apple")
@@ -369,7 +371,7 @@ func TestRTT_sortNodesByDistanceFrom_CheckServiceNodes(t *testing.T) {
 	// they were in from the previous sort.
 	source.Node = "node2"
 	source.Datacenter = "dc1"
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyCheckServiceNodeSort(t, nodes, "node2,node5,node3,node4,node1,apple")
@@ -377,8 +379,138 @@ func TestRTT_sortNodesByDistanceFrom_CheckServiceNodes(t *testing.T) {
 	// Let's exercise the stable sort explicitly to make sure we didn't
 	// just get lucky.
 	nodes[1], nodes[2] = nodes[2], nodes[1]
-	if err := server.sortNodesByDistanceFrom(source, nodes); err != nil {
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	verifyCheckServiceNodeSort(t, nodes, "node2,node3,node5,node4,node1,apple")
 }
+
+func TestRTT_sortNodesByDistanceFrom_Locality(t *testing.T) {
+	t.Parallel()
+	dir, server := testServerWithConfig(t, func(c *Config) {
+		c.LocalityRegionRTT = 7 * time.Millisecond
+	})
+	defer os.RemoveAll(dir)
+	defer server.Shutdown()
+
+	codec := rpcClient(t, server)
+	defer codec.Close()
+	testrpc.WaitForTestAgent(t, server.RPC, "dc1")
+
+	seedCoordinates(t, codec, server)
+	nodes := structs.CheckServiceNodes{
+		structs.CheckServiceNode{Node: &structs.Node{Node: "apple"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node1"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node2"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node3"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node4"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node5"}},
+	}
+
+	// Sorting from node2 labels each node by its distance, except for
+	// apple, which has no coordinate.
+	var source structs.QuerySource
+	source.Node = "node2"
+	source.Datacenter = "dc1"
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, nodes); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	verifyCheckServiceNodeSort(t, nodes, "node2,node3,node5,node4,node1,apple")
+
+	var labels []string
+	for _, node := range nodes {
+		labels = append(labels, node.Locality)
+	}
+	expected := []string{
+		structs.LocalityHost,
+		structs.LocalityZone,
+		structs.LocalityZone,
+		structs.LocalityRegion,
+		structs.LocalityRemote,
+		"",
+	}
+	if strings.Join(labels, ",") != strings.Join(expected, ",") {
+		t.Fatalf("bad: %v", labels)
+	}
+}
+
+func TestRTT_sortNodesByDistanceFrom_Drained(t *testing.T) {
+	t.Parallel()
+	dir, server := testServer(t)
+	defer os.RemoveAll(dir)
+	defer server.Shutdown()
+
+	codec := rpcClient(t, server)
+	defer codec.Close()
+	testrpc.WaitForTestAgent(t, server.RPC, "dc1")
+
+	seedCoordinates(t, codec, server)
+
+	// Put node4 into drain mode.
+	drain := &structs.HealthCheck{
+		Node:    "node4",
+		CheckID: structs.NodeDrain,
+		Name:    "Node Drain Mode",
+		Status:  api.HealthPassing,
+	}
+	req := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "node4",
+		Address:    "127.0.0.1",
+		Check:      drain,
+	}
+	var reply struct{}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &req, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Even though node4 is the closest to node1 after itself, it goes
+	// after the nodes without coordinates.
+	var source structs.QuerySource
+	source.Node = "node1"
+	source.Datacenter = "dc1"
+
+	nodes := structs.ServiceNodes{
+		&structs.ServiceNode{Node: "apple"},
+		&structs.ServiceNode{Node: "node1"},
+		&structs.ServiceNode{Node: "node2"},
+		&structs.ServiceNode{Node: "node3"},
+		&structs.ServiceNode{Node: "node4"},
+		&structs.ServiceNode{Node: "node5"},
+	}
+	ws := memdb.NewWatchSet()
+	var meta structs.QueryMeta
+	if err := server.sortNodesByDistanceFrom(ws, &meta, source, nodes); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	verifyServiceNodeSort(t, nodes, "node1,node5,node2,node3,apple,node4")
+	if meta.Index == 0 {
+		t.Fatalf("bad: %#v", meta)
+	}
+
+	checkNodes := structs.CheckServiceNodes{
+		structs.CheckServiceNode{Node: &structs.Node{Node: "apple"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node1"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node2"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node3"}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node4"}, Checks: structs.HealthChecks{drain}},
+		structs.CheckServiceNode{Node: &structs.Node{Node: "node5"}},
+	}
+	if err := server.sortNodesByDistanceFrom(nil, nil, source, checkNodes); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	verifyCheckServiceNodeSort(t, checkNodes, "node1,node5,node2,node3,apple,node4")
+
+	// Taking node4 out of drain mode wakes up blocking queries.
+	dereg := structs.DeregisterRequest{
+		Datacenter: "dc1",
+		Node:       "node4",
+		CheckID:    structs.NodeDrain,
+	}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Deregister", &dereg, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if timedOut := ws.Watch(time.After(5 * time.Second)); timedOut {
+		t.Fatalf("watch didn't fire")
+	}
+}
@@ -0,0 +1,66 @@
+package consul
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"golang.org/x/time/rate"
+)
+
+// MaxRuntimeQueryTime is the longest MaxQueryTime that can be set at runtime.
+// Clients and servers size the RPC timeouts of blocking queries for queries up
+// to maxQueryTime, so longer ones would time out before they return.
+const MaxRuntimeQueryTime = maxQueryTime
+
+// RuntimeSettings fills in the server settings that can be changed while
+// it's running. Unlimited rates are returned as zero.
+func (s *Server) RuntimeSettings(settings *structs.RuntimeSettings) {
+	settings.SlowQueryThreshold = time.Duration(atomic.LoadInt64(&s.slowQueryThreshold))
+	settings.MaxQueryTime = time.Duration(atomic.LoadInt64(&s.maxQueryTime))
+
+	read, write, token, _, burst := s.rpcLimiter.Load().(*RPCRateLimiter).Limits()
+	settings.RPCServerReadRate = rateToSetting(read)
+	settings.RPCServerWriteRate = rateToSetting(write)
+	settings.RPCServerTokenRate = rateToSetting(token)
+	settings.RPCServerMaxBurst = burst
+}
+
+// SetRuntimeSettings applies the server settings that can be changed while
+// it's running. The rate limiter is only replaced if its limits changed, so
+// the per-token counts aren't lost otherwise. Limits changed this way are kept
+// when the configuration is reloaded. The per-source-IP limit can only be set
+// in the configuration, so it's left as it is.
+func (s *Server) SetRuntimeSettings(settings *structs.RuntimeSettings) {
+	atomic.StoreInt64(&s.slowQueryThreshold, int64(settings.SlowQueryThreshold))
+	atomic.StoreInt64(&s.maxQueryTime, int64(settings.MaxQueryTime))
+
+	s.rpcLimiterLock.Lock()
+	defer s.rpcLimiterLock.Unlock()
+	readRate := settingToRate(settings.RPCServerReadRate)
+	writeRate := settingToRate(settings.RPCServerWriteRate)
+	tokenRate := settingToRate(settings.RPCServerTokenRate)
+	read, write, token, ip, burst := s.rpcLimiter.Load().(*RPCRateLimiter).Limits()
+	if read != readRate || write != writeRate || token != tokenRate || burst != settings.RPCServerMaxBurst {
+		s.rpcLimiter.Store(NewRPCRateLimiter(readRate, writeRate, tokenRate, ip, settings.RPCServerMaxBurst))
+		s.rpcLimitsOverridden = true
+	}
+}
+
+// rateToSetting converts a rate limit to a setting, where zero means there's
+// no limit, since infinity can't be encoded as JSON.
+func rateToSetting(limit rate.Limit) float64 {
+	if limit == rate.Inf {
+		return 0
+	}
+	return float64(limit)
+}
+
+// settingToRate is the inverse of rateToSetting.
+func settingToRate(setting float64) rate.Limit {
+	if setting <= 0 || math.IsInf(setting, 1) {
+		return rate.Inf
+	}
+	return rate.Limit(setting)
+}
@@ -57,13 +57,17 @@ func (s *Server) floodSegments(config *Config) {
 // reconcile is used to reconcile the differences between Serf membership and
 // what is reflected in our strongly consistent store. Mainly we need to ensure
 // all live nodes are registered, all failed nodes are marked as such, and all
-// left nodes are de-registered.
-func (s *Server) reconcile() (err error) {
+// left nodes are de-registered. Members that can't be reconciled within the
+// reconcile rate limit are pushed onto the queue.
+func (s *Server) reconcile(queue *reconcileQueue) (err error) {
 	defer metrics.MeasureSince([]string{"leader", "reconcile"}, time.Now())
 	members := s.serfLAN.Members()
 	knownMembers := make(map[string]struct{})
 	for _, member := range members {
-		if err := s.reconcileMember(member); err != nil {
+		err := s.reconcileMember(member)
+		if err == errReconcileThrottled {
+			queue.Push(member)
+		} else if err != nil {
 			return err
 		}
 		knownMembers[member.Name] = struct{}{}
@@ -71,5 +75,5 @@ func (s *Server) reconcile() (err error) {
 
 	// Reconcile any members that have been reaped while we were not the
 	// leader.
-	return s.reconcileReaped(knownMembers)
+	return s.reconcileReaped(knownMembers, queue)
 }
@@ -32,8 +32,8 @@ import (
 	"github.com/hashicorp/consul/tlsutil"
 	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/raft"
-	raftboltdb "github.com/hashicorp/raft-boltdb"
 	"github.com/hashicorp/serf/serf"
+	"golang.org/x/time/rate"
 )
 
 // These are the protocol versions that Consul can _understand_. These are
@@ -78,9 +78,9 @@ const (
 	// events. If this is exhausted we will block Serf and Memberlist.
 	serfEventChSize = 2048
 
-	// reconcileChSize is the size of the buffered channel reconcile updates
-	// from Serf with the Catalog. If this is exhausted we will drop updates,
-	// and wait for a periodic reconcile.
+	// reconcileChSize is the size of the buffered channel of member events
+	// the leader reconciles with the catalog. If this is exhausted we will
+	// drop updates, and do a full reconcile.
 	reconcileChSize = 256
 )
 
@@ -109,6 +109,28 @@ type Server struct {
 	aclReplicationLock    sync.RWMutex
 	aclReplicationEnabled bool
 
+	// aclTokenReapCancel is used to shut down the goroutine that deletes
+	// expired tokens when we lose leadership
+	aclTokenReapCancel  context.CancelFunc
+	aclTokenReapLock    sync.RWMutex
+	aclTokenReapEnabled bool
+
+	// aclTokenUsage has when the tokens this server resolved were last
+	// used, until it's sent to the leader.
+	aclTokenUsage     map[string]aclTokenUse
+	aclTokenUsageLock sync.Mutex
+
+	// aclTokenUnusedCh is used to shut down the goroutine that looks for
+	// unused tokens when we lose leadership. aclTokenUnusedFlagged has the
+	// unused tokens that have already been warned about, and
+	// aclTokenUsageReports has when each of the other datacenters last
+	// reported token usage to us.
+	aclTokenUnusedCh      chan struct{}
+	aclTokenUnusedLock    sync.Mutex
+	aclTokenUnusedEnabled bool
+	aclTokenUnusedFlagged map[string]struct{}
+	aclTokenUsageReports  map[string]time.Time
+
 	// DEPRECATED (ACL-Legacy-Compat) - only needed while we support both
 	// useNewACLs is used to determine whether we can use new ACLs or not
 	useNewACLs int32
@@ -135,6 +157,51 @@ type Server struct {
 	caPruningLock    sync.RWMutex
 	caPruningEnabled bool
 
+	// catalogCheckCh is used to shut down the catalog consistency check
+	// goroutine when we lose leadership. catalogCheckReport has the findings
+	// of the last run.
+	catalogCheckCh      chan struct{}
+	catalogCheckLock    sync.RWMutex
+	catalogCheckEnabled bool
+	catalogCheckReport  structs.CatalogConsistencyReport
+
+	// stateMemoryCh is used to shut down the state store memory accounting
+	// goroutine when we lose leadership. stateMemoryReport has the usage
+	// found by the last run, which writes are admitted against.
+	stateMemoryCh      chan struct{}
+	stateMemoryLock    sync.RWMutex
+	stateMemoryEnabled bool
+	stateMemoryReport  structs.StateMemoryReport
+
+	// kvReplicationCh is used to shut down the KV replication goroutine when
+	// we lose leadership. kvReplicationStatus has its progress.
+	kvReplicationCh      chan struct{}
+	kvReplicationLock    sync.RWMutex
+	kvReplicationEnabled bool
+	kvReplicationStatus  structs.KVReplicationStatus
+
+	// clockSkew has the clock skew of the nodes that sent a coordinate
+	// update to this server while it's been the leader.
+	clockSkew     map[string]*structs.NodeClockSkew
+	clockSkewLock sync.Mutex
+
+	// electionsCh is used to shut down the goroutine that elects the
+	// leaders of KV elections when we lose leadership.
+	electionsCh      chan struct{}
+	electionsLock    sync.Mutex
+	electionsEnabled bool
+
+	// changeSinksCh is used to shut down the goroutine that publishes
+	// catalog changes to the change sinks when we lose leadership.
+	changeSinksCh      chan struct{}
+	changeSinksLock    sync.Mutex
+	changeSinksEnabled bool
+
+	// coordinate is the Coordinate endpoint, which batches the coordinate
+	// updates sent to the leader. It's kept so the pending updates can be
+	// flushed on request.
+	coordinate *Coordinate
+
 	// Consul configuration
 	config *Config
 
@@ -166,18 +233,30 @@ type Server struct {
 	// the state directly.
 	raft          *raft.Raft
 	raftLayer     *RaftLayer
-	raftStore     *raftboltdb.BoltStore
+	raftStore     RaftStore
 	raftTransport *raft.NetworkTransport
-	raftInmem     *raft.InmemStore
+
+	// raftLog and raftSnapshots are the stores given to Raft, kept so the
+	// operator endpoint can verify them.
+	raftLog       raft.LogStore
+	raftSnapshots raft.SnapshotStore
 
 	// raftNotifyCh is set up by setupRaft() and ensures that we get reliable leader
 	// transition notifications from the Raft layer.
 	raftNotifyCh <-chan bool
 
-	// reconcileCh is used to pass events from the serf handler
-	// into the leader manager, so that the strong state can be
-	// updated
-	reconcileCh chan serf.Member
+	// leaderFlap keeps track of recent leader elections to detect when the
+	// leader is flapping.
+	leaderFlap *leaderFlapDetector
+
+	// dcBreakers fail requests to remote datacenters fast while they're
+	// unreachable.
+	dcBreakers *dcBreakers
+
+	// memberEvents passes the LAN member events from the serf handler
+	// to the parts of the server that act on them, like the leader
+	// manager, so that the strong state can be updated
+	memberEvents *memberEventBus
 
 	// readyForConsistentReads is used to track when the leader server is
 	// ready to serve consistent reads, after it has applied its initial
@@ -200,6 +279,13 @@ type Server struct {
 	// rpcTLS is the TLS config for incoming TLS requests
 	rpcTLS *tls.Config
 
+	// gatewayTLSWrap wraps connections to the WAN federation gateways of
+	// other datacenters, and wanTransport is the WAN memberlist transport
+	// that sends gossip through them. They're only set when WAN federation
+	// goes through gateways.
+	gatewayTLSWrap tlsutil.GatewayWrapper
+	wanTransport   *wanGatewayTransport
+
 	// serfLAN is the Serf cluster maintained inside the DC
 	// which contains all the DC nodes
 	serfLAN *serf.Serf
@@ -228,6 +314,55 @@ type Server struct {
 	// Consul router.
 	statsFetcher *StatsFetcher
 
+	// blockingQueries enforces the limits on concurrent blocking queries.
+	blockingQueries *BlockingQueryLimiter
+
+	// raftApplies bounds the number of Raft applies in flight.
+	raftApplies *RaftApplyLimiter
+
+	// querySourceMembers caches the Serf members indexed to identify the
+	// agents making requests, for query source validation.
+	querySourceMembers     *querySourceMembers
+	querySourceMembersLock sync.Mutex
+
+	// queryPins has the prepared query results pinned to source nodes.
+	queryPins *queryPins
+
+	// lockQueues has the sessions waiting in line to acquire KV locks.
+	lockQueues *lockQueues
+
+	// faults injects latency and errors into RPCs and Raft applies. It's
+	// only set in dev mode.
+	faults *faultInjector
+
+	// queryCache caches the results of catalog and health list queries. It's
+	// nil if caching is disabled.
+	queryCache *queryCache
+
+	// registerTouches tracks when unchanged catalog registrations were last
+	// written, so they can be let through as a liveness touch. It's nil if
+	// the touches are disabled.
+	registerTouches *registerTouches
+
+	// rpcLimiter holds the *RPCRateLimiter enforcing the limits on the rate
+	// of RPC requests. It is replaced when the configuration is reloaded,
+	// unless the limits were changed at runtime. rpcLimiterLock serializes
+	// those replacements, and rpcLimitsOverridden is set once the limits
+	// were changed at runtime.
+	rpcLimiter          atomic.Value
+	rpcLimiterLock      sync.Mutex
+	rpcLimitsOverridden bool
+
+	// reconcileLimiter limits the rate of the catalog writes the leader
+	// makes while reconciling Serf members. It's only used by the leader
+	// loop.
+	reconcileLimiter *rate.Limiter
+
+	// slowQueryThreshold and maxQueryTime are the time.Durations of the
+	// query settings that can be changed at runtime, accessed atomically.
+	slowQueryThreshold int64
+	maxQueryTime       int64
+
 	// reassertLeaderCh is used to signal the leader loop should re-run
 	// leadership actions after a snapshot restore.
 	reassertLeaderCh chan chan error
@@ -318,13 +453,24 @@ func NewServerLogger(config *Config, logger *log.Logger, tokens *token.Store) (*
 	// Create the shutdown channel - this is closed but never written to.
 	shutdownCh := make(chan struct{})
 
+	maxStreams := serverMaxStreams
+	if config.RPCMaxStreams > 0 && config.RPCMaxStreams < maxStreams {
+		maxStreams = config.RPCMaxStreams
+	}
 	connPool := &pool.ConnPool{
-		SrcAddr:    config.RPCSrcAddr,
-		LogOutput:  config.LogOutput,
-		MaxTime:    serverRPCCache,
-		MaxStreams: serverMaxStreams,
-		TLSWrapper: tlsWrap,
-		ForceTLS:   config.VerifyOutgoing,
+		SrcAddr:                config.RPCSrcAddr,
+		LogOutput:              config.LogOutput,
+		MaxTime:                serverRPCCache,
+		MaxStreams:             maxStreams,
+		MaxOpenStreams:         config.RPCMaxStreams,
+		KeepAliveInterval:      config.RPCKeepAliveInterval,
+		ConnectionWriteTimeout: config.RPCConnectionWriteTimeout,
+		StreamTimeout:          config.RPCStreamTimeout,
+		MaxQueryTime:           maxQueryTime,
+		DefaultQueryTime:       defaultQueryTime,
+		TLSWrapper:             tlsWrap,
+		ForceTLS:               config.VerifyOutgoing,
+		FeatureCheck:           checkConnFeatures,
 	}
 
 	// Create server.
@@ -336,18 +482,60 @@ func NewServerLogger(config *Config, logger *log.Logger, tokens *token.Store) (*
 		eventChWAN:       make(chan serf.Event, serfEventChSize),
 		logger:           logger,
 		leaveCh:          make(chan struct{}),
-		reconcileCh:      make(chan serf.Member, reconcileChSize),
+		memberEvents:     newMemberEventBus(),
 		router:           router.NewRouter(logger, config.Datacenter),
 		rpcServer:        rpc.NewServer(),
 		rpcTLS:           incomingTLS,
 		reassertLeaderCh: make(chan chan error),
 		segmentLAN:       make(map[string]*serf.Serf, len(config.Segments)),
 		sessionTimers:    NewSessionTimers(),
+		blockingQueries:  NewBlockingQueryLimiter(config.MaxBlockingQueries, config.MaxBlockingQueriesPerToken, config.MaxBlockingQueriesPerTable),
+		leaderFlap:       newLeaderFlapDetector(config.LeaderFlapThreshold, config.LeaderFlapWindow),
+		dcBreakers:       newDCBreakers(config.CrossDCBreakerThreshold, config.CrossDCBreakerCooldown),
+		raftApplies:      NewRaftApplyLimiter(config.RaftApplyQueueDepth, config.RaftApplyQueueWait),
+		queryPins:        newQueryPins(),
+		lockQueues:       newLockQueues(),
 		tombstoneGC:      gc,
 		serverLookup:     NewServerLookup(),
 		shutdownCh:       shutdownCh,
 	}
 
+	// Send requests to other datacenters through their gateways, if they
+	// have any.
+	if len(config.WANFederationLocalGateways) > 0 {
+		s.gatewayTLSWrap, err = tlsConf.OutgoingGatewayWrapper()
+		if err != nil {
+			return nil, err
+		}
+		s.connPool.GatewayDialer = s.dialDatacenterGateway
+	}
+
+	atomic.StoreInt64(&s.slowQueryThreshold, int64(slowQueryThreshold))
+	atomic.StoreInt64(&s.maxQueryTime, int64(maxQueryTime))
+
+	s.reconcileLimiter = rate.NewLimiter(config.ReconcileRate, config.ReconcileMaxBurst)
+
+	// Set up the limits on the rate of RPC requests.
+	s.rpcLimiter.Store(NewRPCRateLimiter(config.RPCServerReadRate, config.RPCServerWriteRate,
+		config.RPCServerTokenRate, config.RPCServerIPRate, config.RPCServerMaxBurst))
+
+	if s.queryCache, err = newQueryCache(config.QueryCacheSize); err != nil {
+		s.Shutdown()
+		return nil, fmt.Errorf("Failed to create query cache: %v", err)
+	}
+	if s.registerTouches, err = newRegisterTouches(config.CheckLivenessInterval); err != nil {
+		s.Shutdown()
+		return nil, fmt.Errorf("Failed to create register touch tracker: %v", err)
+	}
+
+	// Fault injection is only for testing applications against a dev agent.
+	if config.DevMode {
+		if s.faults, err = newFaultInjector(config.FaultInjection); err != nil {
+			s.Shutdown()
+			return nil, fmt.Errorf("Failed to set up fault injection: %v", err)
+		}
+	}
+
 	// Initialize enterprise specific server functionality
 	if err := s.initEnterprise(); err != nil {
 		s.Shutdown()
@@ -404,6 +592,15 @@ func NewServerLogger(config *Config, logger *log.Logger, tokens *token.Store) (*
 	// Initialize the WAN Serf if enabled
 	serfBindPortWAN := -1
 	if config.SerfWANConfig != nil {
+		if s.wanGatewaysEnabled() {
+			s.wanTransport, err = newWANGatewayTransport(s, config.SerfWANConfig.MemberlistConfig)
+			if err != nil {
+				s.Shutdown()
+				return nil, fmt.Errorf("Failed to start WAN gossip transport: %v", err)
+			}
+			config.SerfWANConfig.MemberlistConfig.Transport = s.wanTransport
+		}
+
 		serfBindPortWAN = config.SerfWANConfig.MemberlistConfig.BindPort
 		s.serfWAN, err = s.setupSerf(config.SerfWANConfig, s.eventChWAN, serfWANSnapshot, true, serfBindPortWAN, "", s.Listener)
 		if err != nil {
@@ -433,7 +630,12 @@ func NewServerLogger(config *Config, logger *log.Logger, tokens *token.Store) (*
 		s.Shutdown()
 		return nil, fmt.Errorf("Failed to start LAN Serf: %v", err)
 	}
+
+	// Snapshots can include the record types older servers can't restore
+	// once every server supports them, which needs the LAN members.
+	s.fsm.SetSnapshotTypeCheck(s.fsmMessageSupported)
 	go s.lanEventHandler()
+	go s.memberEventMetrics()
 
 	// Start the flooders after the LAN event handler is wired up.
 	s.floodSegments(config)
@@ -466,6 +668,9 @@ func NewServerLogger(config *Config, logger *log.Logger, tokens *token.Store) (*
 	// since it can fire events when leadership is obtained.
 	go s.monitorLeadership()
 
+	// Start watching for the leader flapping.
+	go s.monitorLeaderFlapping()
+
 	// Start listening for RPC requests.
 	go s.listen(s.Listener)
 
@@ -477,6 +682,19 @@ func NewServerLogger(config *Config, logger *log.Logger, tokens *token.Store) (*
 	// Start the metrics handlers.
 	go s.sessionStats()
 
+	// Start reporting the top consumers of RPC requests.
+	go s.rpcRateLimitStats()
+
+	// Start sending the last-used times of tokens to the leader.
+	if s.ACLsEnabled() {
+		go s.runACLTokenUsageFlush()
+	}
+
+	// Start running the health checks of external nodes.
+	if s.config.ExternalChecksEnabled {
+		go s.runExternalChecks()
+	}
+
 	// Initialize Autopilot
 	s.initAutopilot(config)
 
@@ -534,8 +752,8 @@ func (s *Server) setupRaft() error {
 	var stable raft.StableStore
 	var snap raft.SnapshotStore
 	if s.config.DevMode {
-		store := raft.NewInmemStore()
-		s.raftInmem = store
+		store := newInmemRaftStore()
+		s.raftStore = store
 		stable = store
 		log = store
 		snap = raft.NewInmemSnapshotStore()
@@ -547,7 +765,7 @@ func (s *Server) setupRaft() error {
 		}
 
 		// Create the backend raft store for logs and stable storage.
-		store, err := raftboltdb.NewBoltStore(filepath.Join(path, "raft.db"))
+		store, err := openRaftStore(path)
 		if err != nil {
 			return err
 		}
@@ -568,6 +786,15 @@ func (s *Server) setupRaft() error {
 		}
 		snap = snapshots
 
+		// Make sure the log and the latest snapshot are intact before we
+		// start serving, since Raft will otherwise fall back to an older
+		// snapshot or replay a broken log and silently diverge from the
+		// rest of the cluster.
+		if _, err := verifyRaft(store, snapshots); err != nil {
+			return fmt.Errorf("Raft storage in %q failed verification, refusing to start: %v", path, err)
+		}
+		s.logger.Printf("[INFO] consul: verified Raft log and snapshot")
+
 		// For an existing cluster being upgraded to the new version of
 		// Raft, we almost never want to run recovery based on the old
 		// peers.json file. We create a peers.info file with a helpful
@@ -619,6 +846,9 @@ func (s *Server) setupRaft() error {
 		}
 	}
 
+	s.raftLog = log
+	s.raftSnapshots = snap
+
 	// If we are in bootstrap or dev mode and the state is clean then we can
 	// bootstrap now.
 	if s.config.Bootstrap || s.config.DevMode {
@@ -1120,6 +1350,25 @@ func (s *Server) GetLANCoordinate() (lib.CoordinateSet, error) {
 // ReloadConfig is used to have the Server do an online reload of
 // relevant configuration information
 func (s *Server) ReloadConfig(config *Config) error {
+	s.rpcLimiterLock.Lock()
+	read, write, token, ip, burst := s.rpcLimiter.Load().(*RPCRateLimiter).Limits()
+	changed := read != config.RPCServerReadRate || write != config.RPCServerWriteRate ||
+		token != config.RPCServerTokenRate || ip != config.RPCServerIPRate ||
+		burst != config.RPCServerMaxBurst
+	switch {
+	case changed && s.rpcLimitsOverridden:
+		s.logger.Printf("[WARN] consul: Keeping the RPC rate limits changed at runtime, the configured ones apply after a restart")
+	case changed:
+		s.rpcLimiter.Store(NewRPCRateLimiter(config.RPCServerReadRate, config.RPCServerWriteRate,
+			config.RPCServerTokenRate, config.RPCServerIPRate, config.RPCServerMaxBurst))
+	}
+	s.rpcLimiterLock.Unlock()
+
+	if s.faults != nil {
+		if err := s.faults.SetRules(config.FaultInjection); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -36,6 +36,9 @@ func (s *Server) setupSerf(conf *serf.Config, ch chan serf.Event, path string, w
 
 	if wan {
 		conf.NodeName = fmt.Sprintf("%s.%s", s.config.NodeName, s.config.Datacenter)
+		if s.wanGatewaysEnabled() {
+			conf.Tags["gw"] = strings.Join(s.config.WANFederationLocalGateways, ",")
+		}
 	} else {
 		conf.NodeName = s.config.NodeName
 		if wanPort > 0 {
@@ -56,6 +59,7 @@ func (s *Server) setupSerf(conf *serf.Config, ch chan serf.Event, path string, w
 	conf.Tags["vsn_max"] = fmt.Sprintf("%d", ProtocolVersionMax)
 	conf.Tags["raft_vsn"] = fmt.Sprintf("%d", s.config.RaftConfig.ProtocolVersion)
 	conf.Tags["build"] = s.config.Build
+	conf.Tags["ft"] = metadata.EncodeFeatures(metadata.SupportedFeatures())
 	addr := listener.Addr().(*net.TCPAddr)
 	conf.Tags["port"] = fmt.Sprintf("%d", addr.Port)
 	if s.config.Bootstrap {
@@ -70,6 +74,9 @@ func (s *Server) setupSerf(conf *serf.Config, ch chan serf.Event, path string, w
 	if s.config.UseTLS {
 		conf.Tags["use_tls"] = "1"
 	}
+	if !wan && s.config.ExternalChecksEnabled {
+		conf.Tags["ext_checks"] = "1"
+	}
 
 	if s.acls.ACLsEnabled() {
 		// we start in legacy mode and allow upgrading later
@@ -135,18 +142,19 @@ func (s *Server) lanEventHandler() {
 			switch e.EventType() {
 			case serf.EventMemberJoin:
 				s.lanNodeJoin(e.(serf.MemberEvent))
-				s.localMemberEvent(e.(serf.MemberEvent))
+				s.memberEvents.Publish(e.(serf.MemberEvent))
 
 			case serf.EventMemberLeave, serf.EventMemberFailed:
 				s.lanNodeFailed(e.(serf.MemberEvent))
-				s.localMemberEvent(e.(serf.MemberEvent))
+				s.memberEvents.Publish(e.(serf.MemberEvent))
 
 			case serf.EventMemberReap:
-				s.localMemberEvent(e.(serf.MemberEvent))
+				s.memberEvents.Publish(e.(serf.MemberEvent))
 			case serf.EventUser:
 				s.localEvent(e.(serf.UserEvent))
 			case serf.EventMemberUpdate:
-				s.localMemberEvent(e.(serf.MemberEvent))
+				s.lanNodeUpdate(e.(serf.MemberEvent))
+				s.memberEvents.Publish(e.(serf.MemberEvent))
 			case serf.EventQuery: // Ignore
 			default:
 				s.logger.Printf("[WARN] consul: Unhandled LAN Serf Event: %#v", e)
@@ -158,30 +166,6 @@ func (s *Server) lanEventHandler() {
 	}
 }
 
-// localMemberEvent is used to reconcile Serf events with the strongly
-// consistent store if we are the current leader
-func (s *Server) localMemberEvent(me serf.MemberEvent) {
-	// Do nothing if we are not the leader
-	if !s.IsLeader() {
-		return
-	}
-
-	// Check if this is a reap event
-	isReap := me.EventType() == serf.EventMemberReap
-
-	// Queue the members for reconciliation
-	for _, m := range me.Members {
-		// Change the status if this is a reap event
-		if isReap {
-			m.Status = StatusReap
-		}
-		select {
-		case s.reconcileCh <- m:
-		default:
-		}
-	}
-}
-
 // localEvent is called when we receive an event on the local Serf
 func (s *Server) localEvent(event serf.UserEvent) {
 	// Handle only consul events
@@ -234,6 +218,18 @@ func (s *Server) lanNodeJoin(me serf.MemberEvent) {
 	}
 }
 
+// lanNodeUpdate is used to handle update events on the LAN pool, so changes
+// to a server's tags, such as the features it supports, are picked up.
+func (s *Server) lanNodeUpdate(me serf.MemberEvent) {
+	for _, m := range me.Members {
+		ok, serverMeta := metadata.IsConsulServer(m)
+		if !ok || serverMeta.Segment != "" {
+			continue
+		}
+		s.serverLookup.AddServer(serverMeta)
+	}
+}
+
 // maybeBootstrap is used to handle bootstrapping when a new consul server joins.
 func (s *Server) maybeBootstrap() {
 	// Bootstrap can only be done if there are no committed logs, remove our
@@ -0,0 +1,175 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/state"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+var (
+	// ErrServiceFailoverNotFound is returned if the service has no failover
+	// policy.
+	ErrServiceFailoverNotFound = errors.New("Service failover not found")
+)
+
+// ServiceFailover manages the failover policies of services.
+type ServiceFailover struct {
+	// srv is a pointer back to the server.
+	srv *Server
+}
+
+// Apply creates, updates or deletes the failover policy of a service. This
+// requires write privileges for the service.
+func (f *ServiceFailover) Apply(args *structs.ServiceFailoverRequest, reply *struct{}) error {
+	if done, err := f.srv.forward("ServiceFailover.Apply", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"service_failover", "apply"}, time.Now())
+
+	if args.Failover == nil {
+		return fmt.Errorf("Missing service failover")
+	}
+	switch args.Op {
+	case structs.ServiceFailoverOpUpsert:
+		if err := args.Failover.Validate(); err != nil {
+			return err
+		}
+	case structs.ServiceFailoverOpDelete:
+		if args.Failover.Service == "" {
+			return fmt.Errorf("Must provide a service name")
+		}
+	default:
+		return fmt.Errorf("Invalid service failover operation %q", args.Op)
+	}
+
+	rule, err := f.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.ServiceWrite(args.Failover.Service, nil) {
+		return acl.ErrPermissionDenied
+	}
+
+	resp, err := f.srv.raftApply(structs.ServiceFailoverRequestType, args)
+	if err != nil {
+		f.srv.logger.Printf("[ERR] consul.service_failover: Apply failed: %v", err)
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
+
+// Get returns the failover policy of a single service.
+func (f *ServiceFailover) Get(args *structs.ServiceSpecificRequest, reply *structs.IndexedServiceFailovers) error {
+	if done, err := f.srv.forward("ServiceFailover.Get", args, args, reply); done {
+		return err
+	}
+
+	if args.ServiceName == "" {
+		return fmt.Errorf("Must provide service name")
+	}
+
+	rule, err := f.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if rule != nil && !rule.ServiceRead(args.ServiceName) {
+		return acl.ErrPermissionDenied
+	}
+
+	return f.srv.blockingQuery(
+		"service-failovers",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, failover, err := state.ServiceFailoverGet(ws, args.ServiceName)
+			if err != nil {
+				return err
+			}
+			if failover == nil {
+				return ErrServiceFailoverNotFound
+			}
+
+			reply.Index = index
+			reply.Failovers = structs.ServiceFailovers{failover}
+			return nil
+		})
+}
+
+// List returns the failover policies of all the services the token can read.
+func (f *ServiceFailover) List(args *structs.DCSpecificRequest, reply *structs.IndexedServiceFailovers) error {
+	if done, err := f.srv.forward("ServiceFailover.List", args, args, reply); done {
+		return err
+	}
+
+	rule, err := f.srv.ResolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+
+	return f.srv.blockingQuery(
+		"service-failovers",
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		func(ws memdb.WatchSet, state *state.Store) error {
+			index, failovers, err := state.ServiceFailovers(ws)
+			if err != nil {
+				return err
+			}
+
+			reply.Index = index
+			reply.Failovers = structs.ServiceFailovers{}
+			for _, failover := range failovers {
+				if rule != nil && !rule.ServiceRead(failover.Service) {
+					continue
+				}
+				reply.Failovers = append(reply.Failovers, failover)
+			}
+			return nil
+		})
+}
+
+// serviceFailoverDatacenters returns the datacenters to try for the given
+// failover policy, in order. This follows the same rules as prepared query
+// failover: the nearest N datacenters come first, followed by the listed
+// datacenters that weren't already picked.
+func (s *Server) serviceFailoverDatacenters(failover *structs.ServiceFailover) ([]string, error) {
+	dcs, err := s.router.GetDatacentersByDistance()
+	if err != nil {
+		return nil, err
+	}
+	var nearest []string
+	known := make(map[string]struct{})
+	for _, dc := range dcs {
+		if dc != s.config.Datacenter {
+			nearest = append(nearest, dc)
+			known[dc] = struct{}{}
+		}
+	}
+
+	var result []string
+	index := make(map[string]struct{})
+	for i := 0; i < failover.Failover.NearestN && i < len(nearest); i++ {
+		result = append(result, nearest[i])
+		index[nearest[i]] = struct{}{}
+	}
+	for _, dc := range failover.Failover.Datacenters {
+		if _, ok := known[dc]; !ok {
+			s.logger.Printf("[DEBUG] consul.service_failover: Skipping unknown datacenter '%s' for service '%s'", dc, failover.Service)
+			continue
+		}
+		if _, ok := index[dc]; !ok {
+			result = append(result, dc)
+			index[dc] = struct{}{}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package consul
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceFailover_Apply(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a policy.
+	arg := structs.ServiceFailoverRequest{
+		Datacenter: "dc1",
+		Op:         structs.ServiceFailoverOpUpsert,
+		Failover: &structs.ServiceFailover{
+			Service:  "web",
+			Failover: structs.QueryDatacenterOptions{NearestN: 1, Datacenters: []string{"dc2"}},
+		},
+	}
+	var reply struct{}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.Apply", &arg, &reply))
+
+	// Read it back.
+	get := structs.ServiceSpecificRequest{Datacenter: "dc1", ServiceName: "web"}
+	var out structs.IndexedServiceFailovers
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.Get", &get, &out))
+	require.Len(out.Failovers, 1)
+	require.Equal(1, out.Failovers[0].Failover.NearestN)
+	require.Equal([]string{"dc2"}, out.Failovers[0].Failover.Datacenters)
+
+	list := structs.DCSpecificRequest{Datacenter: "dc1"}
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.List", &list, &out))
+	require.Len(out.Failovers, 1)
+
+	// Policies must have somewhere to fail over to.
+	arg.Failover = &structs.ServiceFailover{Service: "web"}
+	err := msgpackrpc.CallWithCodec(codec, "ServiceFailover.Apply", &arg, &reply)
+	require.Error(err)
+	require.Contains(err.Error(), "Must provide NearestN or Datacenters")
+
+	// Delete the policy.
+	arg.Op = structs.ServiceFailoverOpDelete
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.Apply", &arg, &reply))
+	err = msgpackrpc.CallWithCodec(codec, "ServiceFailover.Get", &get, &out)
+	require.Error(err)
+	require.Equal(ErrServiceFailoverNotFound.Error(), err.Error())
+}
+
+func TestServiceFailover_ACLDeny(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.ServiceFailoverRequest{
+		Datacenter: "dc1",
+		Op:         structs.ServiceFailoverOpUpsert,
+		Failover: &structs.ServiceFailover{
+			Service:  "web",
+			Failover: structs.QueryDatacenterOptions{NearestN: 1},
+		},
+	}
+	var reply struct{}
+	err := msgpackrpc.CallWithCodec(codec, "ServiceFailover.Apply", &arg, &reply)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+
+	// The master token is allowed.
+	arg.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.Apply", &arg, &reply))
+
+	// Policies for services the token can't read are left out of the list.
+	list := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var out structs.IndexedServiceFailovers
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.List", &list, &out))
+	require.Len(out.Failovers, 0)
+	list.Token = "root"
+	require.NoError(msgpackrpc.CallWithCodec(codec, "ServiceFailover.List", &list, &out))
+	require.Len(out.Failovers, 1)
+
+	get := structs.ServiceSpecificRequest{Datacenter: "dc1", ServiceName: "web"}
+	err = msgpackrpc.CallWithCodec(codec, "ServiceFailover.Get", &get, &out)
+	require.True(acl.IsErrPermissionDenied(err), "err: %v", err)
+}
@@ -74,6 +74,13 @@ func (s *Session) Apply(args *structs.SessionRequest, reply *string) error {
 		return fmt.Errorf("Invalid Behavior setting '%s'", args.Session.Behavior)
 	}
 
+	// Ensure the metadata is valid
+	if args.Op == structs.SessionCreate {
+		if err := structs.ValidateMetadata(args.Session.Meta, false); err != nil {
+			return fmt.Errorf("Invalid session metadata: %v", err)
+		}
+	}
+
 	// Ensure the Session TTL is valid if provided
 	if args.Session.TTL != "" {
 		ttl, err := time.ParseDuration(args.Session.TTL)
@@ -87,6 +94,23 @@ func (s *Session) Apply(args *structs.SessionRequest, reply *string) error {
 		}
 	}
 
+	// Enforce the server's lock-delay bounds. This is done here, on the
+	// leader, so the raft log has the lock-delay the session ends up with.
+	if args.Op == structs.SessionCreate {
+		delay := args.Session.LockDelay
+		if delay < s.srv.config.SessionLockDelayMin {
+			delay = s.srv.config.SessionLockDelayMin
+		}
+		if delay > s.srv.config.SessionLockDelayMax {
+			delay = s.srv.config.SessionLockDelayMax
+		}
+		if delay != args.Session.LockDelay {
+			s.srv.logger.Printf("[DEBUG] consul.session: Lock-delay %v is out of bounds, using %v",
+				args.Session.LockDelay, delay)
+			args.Session.LockDelay = delay
+		}
+	}
+
 	// If this is a create, we must generate the Session ID. This must
 	// be done prior to appending to the raft log, because the ID is not
 	// deterministic. Once the entry is in the log, the state update MUST
@@ -146,6 +170,7 @@ func (s *Session) Get(args *structs.SessionSpecificRequest,
 	}
 
 	return s.srv.blockingQuery(
+		"sessions",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -175,6 +200,7 @@ func (s *Session) List(args *structs.DCSpecificRequest,
 	}
 
 	return s.srv.blockingQuery(
+		"sessions",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -182,6 +208,15 @@ func (s *Session) List(args *structs.DCSpecificRequest,
 			if err != nil {
 				return err
 			}
+			if len(args.SessionMetaFilters) > 0 {
+				var filtered structs.Sessions
+				for _, session := range sessions {
+					if structs.SatisfiesMetaFilters(session.Meta, args.SessionMetaFilters) {
+						filtered = append(filtered, session)
+					}
+				}
+				sessions = filtered
+			}
 
 			reply.Index, reply.Sessions = index, sessions
 			if err := s.srv.filterACL(args.Token, reply); err != nil {
@@ -199,6 +234,7 @@ func (s *Session) NodeSessions(args *structs.NodeSpecificRequest,
 	}
 
 	return s.srv.blockingQuery(
+		"sessions",
 		&args.QueryOptions,
 		&reply.QueryMeta,
 		func(ws memdb.WatchSet, state *state.Store) error {
@@ -72,6 +72,48 @@ func TestSession_Apply(t *testing.T) {
 	}
 }
 
+func TestSession_Apply_LockDelayBounds(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.SessionLockDelayMin = 5 * time.Second
+		c.SessionLockDelayMax = 30 * time.Second
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+	s1.fsm.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"})
+
+	cases := map[time.Duration]time.Duration{
+		0:                5 * time.Second,
+		10 * time.Second: 10 * time.Second,
+		45 * time.Second: 30 * time.Second,
+	}
+	for delay, want := range cases {
+		arg := structs.SessionRequest{
+			Datacenter: "dc1",
+			Op:         structs.SessionCreate,
+			Session: structs.Session{
+				Node:      "foo",
+				LockDelay: delay,
+			},
+		}
+		var id string
+		if err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &id); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		_, s, err := s1.fsm.State().SessionGet(nil, id)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if s.LockDelay != want {
+			t.Fatalf("lock-delay %v: got %v, want %v", delay, s.LockDelay, want)
+		}
+	}
+}
+
 func TestSession_DeleteApply(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServer(t)
@@ -328,6 +370,72 @@ func TestSession_List(t *testing.T) {
 	}
 }
 
+func TestSession_List_Meta(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	s1.fsm.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"})
+	create := func(meta map[string]string) (string, error) {
+		arg := structs.SessionRequest{
+			Datacenter: "dc1",
+			Op:         structs.SessionCreate,
+			Session: structs.Session{
+				Node: "foo",
+				Meta: meta,
+			},
+		}
+		var out string
+		err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out)
+		return out, err
+	}
+	backup, err := create(map[string]string{"owner": "ops", "purpose": "backup"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := create(map[string]string{"owner": "ops", "purpose": "deploy"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := create(nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Invalid metadata is rejected.
+	if _, err := create(map[string]string{"consul-owner": "ops"}); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	list := func(filters map[string]string) structs.Sessions {
+		getR := structs.DCSpecificRequest{
+			Datacenter:         "dc1",
+			SessionMetaFilters: filters,
+		}
+		var sessions structs.IndexedSessions
+		if err := msgpackrpc.CallWithCodec(codec, "Session.List", &getR, &sessions); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return sessions.Sessions
+	}
+	if sessions := list(nil); len(sessions) != 3 {
+		t.Fatalf("bad: %v", sessions)
+	}
+	if sessions := list(map[string]string{"owner": "ops"}); len(sessions) != 2 {
+		t.Fatalf("bad: %v", sessions)
+	}
+	sessions := list(map[string]string{"owner": "ops", "purpose": "backup"})
+	if len(sessions) != 1 || sessions[0].ID != backup || sessions[0].Meta["purpose"] != "backup" {
+		t.Fatalf("bad: %v", sessions)
+	}
+	if sessions := list(map[string]string{"owner": "dev"}); len(sessions) != 0 {
+		t.Fatalf("bad: %v", sessions)
+	}
+}
+
 func TestSession_Get_List_NodeSessions_ACLFilter(t *testing.T) {
 	t.Parallel()
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
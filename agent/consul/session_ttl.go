@@ -100,7 +100,7 @@ func (s *Server) invalidateSession(id string) {
 
 	// Retry with exponential backoff to invalidate the session
 	for attempt := uint(0); attempt < maxInvalidateAttempts; attempt++ {
-		_, err := s.raftApply(structs.SessionRequestType, args)
+		_, err := s.raftApplyInternal(structs.SessionRequestType, args)
 		if err == nil {
 			s.logger.Printf("[DEBUG] consul.state: Session %s TTL expired", id)
 			return
@@ -592,6 +592,9 @@ func (s *Store) aclTokenDeleteTxn(tx *memdb.Txn, idx uint64, value, index string
 	if err := tx.Delete("acl-tokens", token); err != nil {
 		return fmt.Errorf("failed deleting acl token: %v", err)
 	}
+	if err := aclTokenUsageDeleteTxn(tx, token.(*structs.ACLToken).AccessorID); err != nil {
+		return err
+	}
 	if err := indexUpdateMaxTxn(tx, idx, "acl-tokens"); err != nil {
 		return fmt.Errorf("failed updating index: %v", err)
 	}
@@ -819,6 +819,24 @@ func TestStateStore_ACLToken_Delete(t *testing.T) {
 		require.Nil(t, rtoken)
 	})
 
+	t.Run("Legacy", func(t *testing.T) {
+		t.Parallel()
+		s := testACLTokensStateStore(t)
+
+		token := &structs.ACLToken{
+			SecretID: "2989e271-6169-4f34-8fec-4618d70008fb",
+			Type:     structs.ACLTokenTypeClient,
+			Rules:    `service "" { policy = "read" }`,
+		}
+
+		require.NoError(t, s.ACLTokenSet(2, token, true))
+		require.NoError(t, s.ACLTokenDeleteBySecret(3, "2989e271-6169-4f34-8fec-4618d70008fb"))
+
+		_, rtoken, err := s.ACLTokenGetBySecret(nil, "2989e271-6169-4f34-8fec-4618d70008fb")
+		require.NoError(t, err)
+		require.Nil(t, rtoken)
+	})
+
 	t.Run("Secret", func(t *testing.T) {
 		t.Parallel()
 		s := testACLTokensStateStore(t)
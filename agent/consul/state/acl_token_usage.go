@@ -0,0 +1,145 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	aclTokenUsageTableName = "acl-token-usage"
+
+	// aclTokenUsageSinceIndex is kept in the index table so it's saved in
+	// snapshots. It holds the Unix time tokens have been tracked since,
+	// which is when the first usage was recorded.
+	aclTokenUsageSinceIndex = "acl-token-usage-since"
+)
+
+// aclTokenUsageTableSchema returns a new table schema used for storing when
+// ACL tokens were last used. It's kept apart from the tokens so recording
+// usage doesn't change them, which would wake their watchers and make them
+// replicate again.
+func aclTokenUsageTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: aclTokenUsageTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.UUIDFieldIndex{
+					Field: "AccessorID",
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(aclTokenUsageTableSchema)
+}
+
+// ACLTokenUsage is used to pull the token usage from the snapshot.
+func (s *Snapshot) ACLTokenUsage() (memdb.ResultIterator, error) {
+	iter, err := s.tx.Get(aclTokenUsageTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// ACLTokenUsage is used when restoring from a snapshot.
+func (s *Restore) ACLTokenUsage(usage *structs.ACLTokenUsage) error {
+	if err := s.tx.Insert(aclTokenUsageTableName, usage); err != nil {
+		return fmt.Errorf("failed restoring acl token usage: %s", err)
+	}
+	return nil
+}
+
+// ACLTokenUsageGet returns when the given tokens were last used, by accessor
+// ID, and the time tokens have been tracked since. The time is zero if no
+// usage has been recorded yet.
+func (s *Store) ACLTokenUsageGet(accessors []string) (map[string]time.Time, time.Time, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	usage := make(map[string]time.Time, len(accessors))
+	for _, accessor := range accessors {
+		u, err := tx.First(aclTokenUsageTableName, "id", accessor)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed acl token usage lookup: %s", err)
+		}
+		if u != nil {
+			usage[accessor] = u.(*structs.ACLTokenUsage).LastUsed
+		}
+	}
+
+	var since time.Time
+	if unix := maxIndexTxn(tx, aclTokenUsageSinceIndex); unix > 0 {
+		since = time.Unix(int64(unix), 0)
+	}
+	return usage, since, nil
+}
+
+// ACLTokenUsageSet records when tokens were last used. Usage of tokens that
+// don't exist is ignored, as is usage older than what's already recorded.
+func (s *Store) ACLTokenUsageSet(idx uint64, usage []*structs.ACLTokenUsage) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	var earliest time.Time
+	for _, u := range usage {
+		token, err := tx.First("acl-tokens", "accessor", u.AccessorID)
+		if err != nil {
+			return fmt.Errorf("failed acl token lookup: %s", err)
+		}
+		if token == nil {
+			continue
+		}
+
+		existing, err := tx.First(aclTokenUsageTableName, "id", u.AccessorID)
+		if err != nil {
+			return fmt.Errorf("failed acl token usage lookup: %s", err)
+		}
+		if existing != nil && !u.LastUsed.After(existing.(*structs.ACLTokenUsage).LastUsed) {
+			continue
+		}
+		if err := tx.Insert(aclTokenUsageTableName, &structs.ACLTokenUsage{
+			AccessorID: u.AccessorID,
+			LastUsed:   u.LastUsed,
+		}); err != nil {
+			return fmt.Errorf("failed inserting acl token usage: %s", err)
+		}
+		if earliest.IsZero() || u.LastUsed.Before(earliest) {
+			earliest = u.LastUsed
+		}
+	}
+
+	// Tokens are tracked from the first usage recorded. The time is kept as
+	// an index so it never goes back.
+	if !earliest.IsZero() && maxIndexTxn(tx, aclTokenUsageSinceIndex) == 0 {
+		if err := indexUpdateMaxTxn(tx, uint64(earliest.Unix()), aclTokenUsageSinceIndex); err != nil {
+			return fmt.Errorf("failed updating index: %s", err)
+		}
+	}
+	if err := indexUpdateMaxTxn(tx, idx, aclTokenUsageTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// aclTokenUsageDeleteTxn forgets the usage of a deleted token. Legacy tokens
+// have no accessor ID, so there's no usage to forget.
+func aclTokenUsageDeleteTxn(tx *memdb.Txn, accessor string) error {
+	if accessor == "" {
+		return nil
+	}
+	if _, err := tx.DeleteAll(aclTokenUsageTableName, "id", accessor); err != nil {
+		return fmt.Errorf("failed deleting acl token usage: %s", err)
+	}
+	return nil
+}
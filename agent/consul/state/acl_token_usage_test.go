@@ -0,0 +1,86 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore_ACLTokenUsage(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	s := testACLTokensStateStore(t)
+
+	token := &structs.ACLToken{
+		AccessorID: "f1093997-b6c7-496d-bfb8-6b1b1895641b",
+		SecretID:   "34ec8eb3-095d-417a-a937-b439af7a8e8b",
+	}
+	require.NoError(s.ACLTokenSet(3, token, false))
+
+	accessors := []string{token.AccessorID, "a0d7b8b4-2d5f-4b5a-9a1c-7c1a0cbbd3c3"}
+	usage, since, err := s.ACLTokenUsageGet(accessors)
+	require.NoError(err)
+	require.Empty(usage)
+	require.True(since.IsZero())
+
+	// Usage of tokens that don't exist is ignored.
+	first := time.Now().Add(-time.Hour).Round(time.Second)
+	require.NoError(s.ACLTokenUsageSet(4, []*structs.ACLTokenUsage{
+		{AccessorID: token.AccessorID, LastUsed: first},
+		{AccessorID: accessors[1], LastUsed: first},
+	}))
+	usage, since, err = s.ACLTokenUsageGet(accessors)
+	require.NoError(err)
+	require.Len(usage, 1)
+	require.True(first.Equal(usage[token.AccessorID]))
+	require.True(first.Equal(since))
+
+	// Older usage doesn't replace newer usage, and the time tokens have been
+	// tracked since doesn't move.
+	latest := first.Add(30 * time.Minute)
+	require.NoError(s.ACLTokenUsageSet(5, []*structs.ACLTokenUsage{
+		{AccessorID: token.AccessorID, LastUsed: latest},
+	}))
+	require.NoError(s.ACLTokenUsageSet(6, []*structs.ACLTokenUsage{
+		{AccessorID: token.AccessorID, LastUsed: first},
+	}))
+	usage, since, err = s.ACLTokenUsageGet(accessors)
+	require.NoError(err)
+	require.True(latest.Equal(usage[token.AccessorID]))
+	require.True(first.Equal(since))
+
+	// Recording usage doesn't change the token.
+	_, rtoken, err := s.ACLTokenGetByAccessor(nil, token.AccessorID)
+	require.NoError(err)
+	require.Equal(uint64(3), rtoken.ModifyIndex)
+
+	// Snapshot and restore the usage.
+	snap := s.Snapshot()
+	defer snap.Close()
+	iter, err := snap.ACLTokenUsage()
+	require.NoError(err)
+	var dump []*structs.ACLTokenUsage
+	for u := iter.Next(); u != nil; u = iter.Next() {
+		dump = append(dump, u.(*structs.ACLTokenUsage))
+	}
+	require.Len(dump, 1)
+
+	s2 := testACLTokensStateStore(t)
+	require.NoError(s2.ACLTokenSet(3, token, false))
+	restore := s2.Restore()
+	for _, u := range dump {
+		require.NoError(restore.ACLTokenUsage(u))
+	}
+	restore.Commit()
+	usage, _, err = s2.ACLTokenUsageGet(accessors)
+	require.NoError(err)
+	require.True(latest.Equal(usage[token.AccessorID]))
+
+	// Deleting the token forgets its usage.
+	require.NoError(s.ACLTokenDeleteByAccessor(7, token.AccessorID))
+	usage, _, err = s.ACLTokenUsageGet(accessors)
+	require.NoError(err)
+	require.Empty(usage)
+}
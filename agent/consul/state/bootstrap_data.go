@@ -0,0 +1,34 @@
+package state
+
+import (
+	"fmt"
+)
+
+// bootstrapDataIndexName returns the name of the entry in the index table
+// that records when the given part of the bootstrap data directory was
+// loaded. The markers are kept in the index table, which is saved in
+// snapshots, rather than in the KV store where they could be changed or
+// deleted like any other key.
+func bootstrapDataIndexName(part string) string {
+	return "bootstrap-data." + part
+}
+
+// BootstrapDataLoaded returns the Raft index at which the given part of the
+// bootstrap data directory was recorded as loaded, or 0 if it hasn't been.
+func (s *Store) BootstrapDataLoaded(part string) uint64 {
+	return s.maxIndex(bootstrapDataIndexName(part))
+}
+
+// BootstrapDataSetLoaded records that the given part of the bootstrap data
+// directory was loaded.
+func (s *Store) BootstrapDataSetLoaded(idx uint64, part string) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := indexUpdateMaxTxn(tx, idx, bootstrapDataIndexName(part)); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Commit()
+	return nil
+}
@@ -0,0 +1,31 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore_BootstrapData(t *testing.T) {
+	s := testStateStore(t)
+
+	require.Equal(t, uint64(0), s.BootstrapDataLoaded(structs.BootstrapDataPartCatalog))
+	require.NoError(t, s.BootstrapDataSetLoaded(3, structs.BootstrapDataPartCatalog))
+	require.Equal(t, uint64(3), s.BootstrapDataLoaded(structs.BootstrapDataPartCatalog))
+	require.Equal(t, uint64(0), s.BootstrapDataLoaded(structs.BootstrapDataPartACLs))
+
+	// The markers are saved in snapshots.
+	snap := s.Snapshot()
+	defer snap.Close()
+	indexes, err := snap.Indexes()
+	require.NoError(t, err)
+
+	restored := testStateStore(t)
+	restore := restored.Restore()
+	for idx := indexes.Next(); idx != nil; idx = indexes.Next() {
+		require.NoError(t, restore.IndexRestore(idx.(*IndexEntry)))
+	}
+	restore.Commit()
+	require.Equal(t, uint64(3), restored.BootstrapDataLoaded(structs.BootstrapDataPartCatalog))
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/hashicorp/consul/agent/structs"
@@ -102,6 +103,20 @@ func servicesTableSchema() *memdb.TableSchema {
 				Unique:       false,
 				Indexer:      &IndexConnectService{},
 			},
+			"service_tag": &memdb.IndexSchema{
+				Name:         "service_tag",
+				AllowMissing: true,
+				Unique:       false,
+				Indexer:      &IndexServiceTag{},
+			},
+			"address": &memdb.IndexSchema{
+				Name:         "address",
+				AllowMissing: true,
+				Unique:       false,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "ServiceAddress",
+				},
+			},
 		},
 	}
 }
@@ -271,6 +286,62 @@ func (s *Store) EnsureRegistration(idx uint64, req *structs.RegisterRequest) err
 	return nil
 }
 
+// EnsureRegistrationCAS is used to do a check-and-set registration. The
+// service in the request, or the node if there's no service, is only written
+// if its ModifyIndex matches the given index. An index of 0 means that it is
+// only written if it doesn't exist yet. Returns false if nothing was written.
+func (s *Store) EnsureRegistrationCAS(idx, cidx uint64, req *structs.RegisterRequest) (bool, error) {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	// Anything that exists has a non-zero ModifyIndex, so this takes care
+	// of the set-if-not-exists case as well.
+	var modifyIndex uint64
+	if req.Service != nil {
+		service, err := tx.First("services", "id", req.Node, req.Service.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed service lookup: %s", err)
+		}
+		if service != nil {
+			modifyIndex = service.(*structs.ServiceNode).ModifyIndex
+		}
+	} else {
+		node, err := tx.First("nodes", "id", req.Node)
+		if err != nil {
+			return false, fmt.Errorf("node lookup failed: %s", err)
+		}
+		if node != nil {
+			modifyIndex = node.(*structs.Node).ModifyIndex
+		}
+	}
+
+	// The checks are written as well, so they're compared too. The index
+	// has to match the most recently changed of everything written.
+	checks := req.Checks
+	if req.Check != nil {
+		checks = append(structs.HealthChecks{req.Check}, checks...)
+	}
+	for _, check := range checks {
+		existing, err := tx.First("checks", "id", req.Node, string(check.CheckID))
+		if err != nil {
+			return false, fmt.Errorf("failed health check lookup: %s", err)
+		}
+		if existing != nil && existing.(*structs.HealthCheck).ModifyIndex > modifyIndex {
+			modifyIndex = existing.(*structs.HealthCheck).ModifyIndex
+		}
+	}
+	if modifyIndex != cidx {
+		return false, nil
+	}
+
+	if err := s.ensureRegistrationTxn(tx, idx, req); err != nil {
+		return false, err
+	}
+
+	tx.Commit()
+	return true, nil
+}
+
 func (s *Store) ensureCheckIfNodeMatches(tx *memdb.Txn, idx uint64, node string, check *structs.HealthCheck) error {
 	if check.Node != node {
 		return fmt.Errorf("check node %q does not match node %q",
@@ -344,6 +415,77 @@ func (s *Store) ensureRegistrationTxn(tx *memdb.Txn, idx uint64, req *structs.Re
 	return nil
 }
 
+// RegistrationIsCurrent returns true if applying the given registration
+// wouldn't change the node, service or checks already in the catalog. This
+// lets servers skip the Raft write for registrations that are re-sent with
+// the same contents, such as checks reporting the same status and output.
+func (s *Store) RegistrationIsCurrent(req *structs.RegisterRequest) (bool, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	node, err := tx.First("nodes", "id", req.Node)
+	if err != nil {
+		return false, fmt.Errorf("node lookup failed: %s", err)
+	}
+	if node == nil || req.ChangesNode(node.(*structs.Node)) {
+		return false, nil
+	}
+
+	if req.Service != nil {
+		service, err := tx.First("services", "id", req.Node, req.Service.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed service lookup: %s", err)
+		}
+		if service == nil {
+			return false, nil
+		}
+
+		// Round trip the service so it gets the same defaults it would
+		// have once stored.
+		ns := req.Service.ToServiceNode(req.Node).ToNodeService()
+		if !service.(*structs.ServiceNode).ToNodeService().IsSame(ns) {
+			return false, nil
+		}
+	}
+
+	checks := req.Checks
+	if req.Check != nil {
+		checks = append([]*structs.HealthCheck{req.Check}, checks...)
+	}
+	for _, check := range checks {
+		existing, err := tx.First("checks", "id", req.Node, string(check.CheckID))
+		if err != nil {
+			return false, fmt.Errorf("failed health check lookup: %s", err)
+		}
+		if existing == nil {
+			return false, nil
+		}
+
+		// Fill in the same fields that ensureCheckTxn does before comparing.
+		hc := check.Clone()
+		if hc.Status == "" {
+			hc.Status = api.HealthCritical
+		}
+		if hc.ServiceID != "" {
+			service, err := tx.First("services", "id", req.Node, hc.ServiceID)
+			if err != nil {
+				return false, fmt.Errorf("failed service lookup: %s", err)
+			}
+			if service == nil {
+				return false, nil
+			}
+			svc := service.(*structs.ServiceNode)
+			hc.ServiceName = svc.ServiceName
+			hc.ServiceTags = svc.ServiceTags
+		}
+		existingCheck := existing.(*structs.HealthCheck)
+		if !existingCheck.IsSame(hc) || !reflect.DeepEqual(existingCheck.Definition, hc.Definition) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // EnsureNode is used to upsert node registration or modification.
 func (s *Store) EnsureNode(idx uint64, node *structs.Node) error {
 	tx := s.db.Txn(true)
@@ -369,7 +511,16 @@ func (s *Store) ensureNoNodeWithSimilarNameTxn(tx *memdb.Txn, node *structs.Node
 	for nodeIt := enodes.Next(); nodeIt != nil; nodeIt = enodes.Next() {
 		enode := nodeIt.(*structs.Node)
 		if strings.EqualFold(node.Node, enode.Node) && node.ID != enode.ID {
-			if !(enode.ID == "" && allowClashWithoutID) {
+			if enode.ID == "" && allowClashWithoutID {
+				continue
+			}
+			// A failed node can have its name taken over by a node with a
+			// new ID, which happens when hostnames are recycled.
+			failed, err := nodeFailedTxn(tx, enode.Node)
+			if err != nil {
+				return err
+			}
+			if !failed {
 				return fmt.Errorf("Node name %s is reserved by node %s with name %s", node.Node, enode.ID, enode.Node)
 			}
 		}
@@ -377,6 +528,20 @@ func (s *Store) ensureNoNodeWithSimilarNameTxn(tx *memdb.Txn, node *structs.Node
 	return nil
 }
 
+// nodeFailedTxn returns true if the Serf health check of the given node is
+// critical. Nodes without a Serf health check, such as external nodes, are
+// never considered failed.
+func nodeFailedTxn(tx *memdb.Txn, node string) (bool, error) {
+	check, err := tx.First("checks", "id", node, string(structs.SerfCheckID))
+	if err != nil {
+		return false, fmt.Errorf("failed check lookup: %s", err)
+	}
+	if check == nil {
+		return false, nil
+	}
+	return check.(*structs.HealthCheck).Status == api.HealthCritical, nil
+}
+
 // ensureNodeTxn is the inner function called to actually create a node
 // registration or modify an existing one in the state store. It allows
 // passing in a memdb transaction so it may be part of a larger txn.
@@ -412,6 +577,21 @@ func (s *Store) ensureNodeTxn(tx *memdb.Txn, idx uint64, node *structs.Node) err
 				return fmt.Errorf("Error while renaming Node ID: %q: %s", node.ID, dupNameError)
 			}
 		}
+
+		// If we are taking over the name of a failed node with another ID,
+		// remove it first so its services and checks aren't inherited by
+		// this node.
+		clash, err := tx.First("nodes", "id", node.Node)
+		if err != nil {
+			return fmt.Errorf("node name lookup failed: %s", err)
+		}
+		if clash != nil {
+			if old := clash.(*structs.Node); old.ID != "" && old.ID != node.ID {
+				if err := s.deleteNodeTxn(tx, idx, old.Node); err != nil {
+					return fmt.Errorf("Error while replacing failed node %q (%s): %s", old.Node, old.ID, err)
+				}
+			}
+		}
 	}
 	// TODO: else Node.ID == "" should be forbidden in future Consul releases
 	// See https://github.com/hashicorp/consul/pull/3983 for context
@@ -445,10 +625,21 @@ func (s *Store) ensureNodeTxn(tx *memdb.Txn, idx uint64, node *structs.Node) err
 		node.ModifyIndex = idx
 	}
 
-	// Insert the node and update the index.
+	// Insert the node and update the index. The node count only changes if
+	// there wasn't already an entry with this name, which isn't the same as
+	// n being nil when a node is being renamed.
+	existing, err := tx.First("nodes", "id", node.Node)
+	if err != nil {
+		return fmt.Errorf("node name lookup failed: %s", err)
+	}
 	if err := tx.Insert("nodes", node); err != nil {
 		return fmt.Errorf("failed inserting node: %s", err)
 	}
+	if existing == nil {
+		if err := updateTableCountTxn(tx, "nodes", 1); err != nil {
+			return err
+		}
+	}
 	if err := tx.Insert("index", &IndexEntry{"nodes", idx}); err != nil {
 		return fmt.Errorf("failed updating index: %s", err)
 	}
@@ -505,6 +696,15 @@ func (s *Store) GetNodeID(id types.NodeID) (uint64, *structs.Node, error) {
 	return idx, node, err
 }
 
+// NodeCount returns the number of registered nodes. Unlike Nodes it doesn't
+// have to look at every node.
+func (s *Store) NodeCount() (int, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	return tableCountTxn(tx, "nodes")
+}
+
 // Nodes is used to return all of the known nodes.
 func (s *Store) Nodes(ws memdb.WatchSet) (uint64, structs.Nodes, error) {
 	tx := s.db.Txn(false)
@@ -559,6 +759,63 @@ func (s *Store) NodesByMeta(ws memdb.WatchSet, filters map[string]string) (uint6
 	return idx, results, nil
 }
 
+// NodesWithoutService returns the nodes that don't have a passing instance of
+// the given service: ones without an instance at all, and ones where all of
+// the instances have a check that isn't passing. If any filters are given,
+// only the nodes with the given metadata key/value pairs are returned.
+func (s *Store) NodesWithoutService(ws memdb.WatchSet, serviceName string, filters map[string]string) (uint64, structs.Nodes, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Find the nodes that have a passing instance.
+	iter, err := tx.Get("services", "service", serviceName)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed service lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+	var services structs.ServiceNodes
+	for service := iter.Next(); service != nil; service = iter.Next() {
+		services = append(services, service.(*structs.ServiceNode))
+	}
+	idx := maxIndexForService(tx, serviceName, len(services) > 0, true)
+	idx, csns, err := s.parseCheckServiceNodes(tx, ws, idx, serviceName, services, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	passing := make(map[string]bool)
+	for _, csn := range csns.Filter(true) {
+		passing[csn.Node.Node] = true
+	}
+
+	// Get the table index.
+	if nodesIdx := maxIndexTxn(tx, "nodes"); nodesIdx > idx {
+		idx = nodesIdx
+	}
+
+	// Retrieve the nodes, using the index for the first filter.
+	var args []interface{}
+	index := "id"
+	for key, value := range filters {
+		index, args = "meta", []interface{}{key, value}
+		break
+	}
+	nodes, err := tx.Get("nodes", index, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed nodes lookup: %s", err)
+	}
+	ws.Add(nodes.WatchCh())
+
+	var results structs.Nodes
+	for node := nodes.Next(); node != nil; node = nodes.Next() {
+		n := node.(*structs.Node)
+		if passing[n.Node] || !structs.SatisfiesMetaFilters(n.Meta, filters) {
+			continue
+		}
+		results = append(results, n)
+	}
+	return idx, results, nil
+}
+
 // DeleteNode is used to delete a given node by its ID.
 func (s *Store) DeleteNode(idx uint64, nodeName string) error {
 	tx := s.db.Txn(true)
@@ -573,6 +830,29 @@ func (s *Store) DeleteNode(idx uint64, nodeName string) error {
 	return nil
 }
 
+// DeleteNodeCAS is used to delete a node only if its ModifyIndex matches the
+// given index. Returns false if the node exists but wasn't deleted.
+func (s *Store) DeleteNodeCAS(idx, cidx uint64, nodeName string) (bool, error) {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	node, err := tx.First("nodes", "id", nodeName)
+	if err != nil {
+		return false, fmt.Errorf("node lookup failed: %s", err)
+	}
+	n, ok := node.(*structs.Node)
+	if !ok || n.ModifyIndex != cidx {
+		return node == nil, nil
+	}
+
+	if err := s.deleteNodeTxn(tx, idx, nodeName); err != nil {
+		return false, err
+	}
+
+	tx.Commit()
+	return true, nil
+}
+
 // deleteNodeTxn is the inner method used for removing a node from
 // the store within a given transaction.
 func (s *Store) deleteNodeTxn(tx *memdb.Txn, idx uint64, nodeName string) error {
@@ -642,6 +922,9 @@ func (s *Store) deleteNodeTxn(tx *memdb.Txn, idx uint64, nodeName string) error
 	if err := tx.Delete("nodes", node); err != nil {
 		return fmt.Errorf("failed deleting node: %s", err)
 	}
+	if err := updateTableCountTxn(tx, "nodes", -1); err != nil {
+		return err
+	}
 	if err := tx.Insert("index", &IndexEntry{"nodes", idx}); err != nil {
 		return fmt.Errorf("failed updating index: %s", err)
 	}
@@ -930,22 +1213,10 @@ func (s *Store) ServiceTagNodes(ws memdb.WatchSet, service string, tags []string
 	tx := s.db.Txn(false)
 	defer tx.Abort()
 
-	// List all the services.
-	services, err := tx.Get("services", "service", service)
+	// List the services with the tags.
+	results, serviceExists, err := serviceTagLookup(tx, ws, service, tags)
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed service lookup: %s", err)
-	}
-	ws.Add(services.WatchCh())
-
-	// Gather all the services and apply the tag filter.
-	serviceExists := false
-	var results structs.ServiceNodes
-	for service := services.Next(); service != nil; service = services.Next() {
-		svc := service.(*structs.ServiceNode)
-		serviceExists = true
-		if !serviceTagsFilter(svc, tags) {
-			results = append(results, svc)
-		}
+		return 0, nil, err
 	}
 
 	// Fill in the node details.
@@ -959,6 +1230,43 @@ func (s *Store) ServiceTagNodes(ws memdb.WatchSet, service string, tags []string
 	return idx, results, nil
 }
 
+// serviceTagLookup returns the instances of a service that have all of the
+// given tags, and whether the service has any instances at all. Rather than
+// scanning every instance, the first tag is looked up in the service_tag
+// index and the instances found are filtered by the rest.
+func serviceTagLookup(tx *memdb.Txn, ws memdb.WatchSet, service string, tags []string) (structs.ServiceNodes, bool, error) {
+	var iter memdb.ResultIterator
+	var err error
+	if len(tags) == 0 {
+		iter, err = tx.Get("services", "service", service)
+	} else {
+		iter, err = tx.Get("services", "service_tag", service, tags[0])
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed service lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+
+	var results structs.ServiceNodes
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		svc := raw.(*structs.ServiceNode)
+		if len(tags) == 0 || !serviceTagsFilter(svc, tags[1:]) {
+			results = append(results, svc)
+		}
+	}
+
+	// Instances without the first tag weren't visited, so look for any
+	// instance if we didn't find one above.
+	if len(results) > 0 {
+		return results, true, nil
+	}
+	existing, err := tx.First("services", "service", service)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed service lookup: %s", err)
+	}
+	return results, existing != nil, nil
+}
+
 // serviceTagFilter returns true (should filter) if the given service node
 // doesn't contain the given tag.
 func serviceTagFilter(sn *structs.ServiceNode, tag string) bool {
@@ -995,14 +1303,21 @@ func (s *Store) ServiceAddressNodes(ws memdb.WatchSet, address string) (uint64,
 	tx := s.db.Txn(false)
 	defer tx.Abort()
 
-	// List all the services.
-	services, err := tx.Get("services", "id")
+	// The address index leaves out services without an address, so those
+	// have to be found by scanning all of them.
+	var services memdb.ResultIterator
+	var err error
+	if address != "" {
+		services, err = tx.Get("services", "address", address)
+	} else {
+		services, err = tx.Get("services", "id")
+	}
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed service lookup: %s", err)
 	}
 	ws.Add(services.WatchCh())
 
-	// Gather all the services and apply the tag filter.
+	// Gather all the services and apply the address filter.
 	var results structs.ServiceNodes
 	for service := services.Next(); service != nil; service = services.Next() {
 		svc := service.(*structs.ServiceNode)
@@ -1169,6 +1484,30 @@ func (s *Store) DeleteService(idx uint64, nodeName, serviceID string) error {
 	return nil
 }
 
+// DeleteServiceCAS is used to delete a service only if its ModifyIndex
+// matches the given index. Returns false if the service exists but wasn't
+// deleted.
+func (s *Store) DeleteServiceCAS(idx, cidx uint64, nodeName, serviceID string) (bool, error) {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	service, err := tx.First("services", "id", nodeName, serviceID)
+	if err != nil {
+		return false, fmt.Errorf("failed service lookup: %s", err)
+	}
+	svc, ok := service.(*structs.ServiceNode)
+	if !ok || svc.ModifyIndex != cidx {
+		return service == nil, nil
+	}
+
+	if err := s.deleteServiceTxn(tx, idx, nodeName, serviceID); err != nil {
+		return false, err
+	}
+
+	tx.Commit()
+	return true, nil
+}
+
 func serviceIndexName(name string) string {
 	return fmt.Sprintf("service.%s", name)
 }
@@ -1404,6 +1743,31 @@ func (s *Store) NodeCheck(nodeName string, checkID types.CheckID) (uint64, *stru
 	return idx, nil, nil
 }
 
+// NodesWithCheck returns which of the given nodes have the check with the
+// given ID registered. The lookups are added to the watch set, so it fires
+// when the check is registered or deregistered on any of the nodes.
+func (s *Store) NodesWithCheck(ws memdb.WatchSet, nodes []string, checkID types.CheckID) (uint64, map[string]bool, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the table index.
+	idx := maxIndexTxn(tx, "checks")
+
+	found := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if _, ok := found[node]; ok {
+			continue
+		}
+		watchCh, check, err := tx.FirstWatch("checks", "id", node, string(checkID))
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed check lookup: %s", err)
+		}
+		ws.Add(watchCh)
+		found[node] = check != nil
+	}
+	return idx, found, nil
+}
+
 // NodeChecks is used to retrieve checks associated with the
 // given node from the state store.
 func (s *Store) NodeChecks(ws memdb.WatchSet, nodeName string) (uint64, structs.HealthChecks, error) {
@@ -1578,6 +1942,30 @@ func (s *Store) DeleteCheck(idx uint64, node string, checkID types.CheckID) erro
 	return nil
 }
 
+// DeleteCheckCAS is used to delete a health check only if its ModifyIndex
+// matches the given index. Returns false if the check exists but wasn't
+// deleted.
+func (s *Store) DeleteCheckCAS(idx, cidx uint64, node string, checkID types.CheckID) (bool, error) {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	check, err := tx.First("checks", "id", node, string(checkID))
+	if err != nil {
+		return false, fmt.Errorf("check lookup failed: %s", err)
+	}
+	hc, ok := check.(*structs.HealthCheck)
+	if !ok || hc.ModifyIndex != cidx {
+		return check == nil, nil
+	}
+
+	if err := s.deleteCheckTxn(tx, idx, node, checkID); err != nil {
+		return false, err
+	}
+
+	tx.Commit()
+	return true, nil
+}
+
 // deleteCheckTxn is the inner method used to call a health
 // check deletion within an existing transaction.
 func (s *Store) deleteCheckTxn(tx *memdb.Txn, idx uint64, node string, checkID types.CheckID) error {
@@ -1687,22 +2075,10 @@ func (s *Store) CheckServiceTagNodes(ws memdb.WatchSet, serviceName string, tags
 	tx := s.db.Txn(false)
 	defer tx.Abort()
 
-	// Query the state store for the service.
-	iter, err := tx.Get("services", "service", serviceName)
+	// Query the state store for the services with the tags.
+	results, serviceExists, err := serviceTagLookup(tx, ws, serviceName, tags)
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed service lookup: %s", err)
-	}
-	ws.Add(iter.WatchCh())
-
-	// Return the results, filtering by tag.
-	serviceExists := false
-	var results structs.ServiceNodes
-	for service := iter.Next(); service != nil; service = iter.Next() {
-		svc := service.(*structs.ServiceNode)
-		serviceExists = true
-		if !serviceTagsFilter(svc, tags) {
-			results = append(results, svc)
-		}
+		return 0, nil, err
 	}
 
 	// Get the table index.
@@ -1889,3 +2265,55 @@ func (s *Store) parseNodes(tx *memdb.Txn, ws memdb.WatchSet, idx uint64,
 	}
 	return idx, results, nil
 }
+
+// CatalogOrphans returns the services whose node doesn't exist, and the checks
+// whose node or service doesn't exist. These should never be present, but are
+// looked for by the leader so they can be cleaned up if they are.
+func (s *Store) CatalogOrphans() (structs.ServiceNodes, structs.HealthChecks, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	var services structs.ServiceNodes
+	iter, err := tx.Get("services", "id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed services lookup: %s", err)
+	}
+	for service := iter.Next(); service != nil; service = iter.Next() {
+		sn := service.(*structs.ServiceNode)
+		node, err := tx.First("nodes", "id", sn.Node)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed node lookup: %s", err)
+		}
+		if node == nil {
+			services = append(services, sn)
+		}
+	}
+
+	var checks structs.HealthChecks
+	iter, err = tx.Get("checks", "id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed checks lookup: %s", err)
+	}
+	for check := iter.Next(); check != nil; check = iter.Next() {
+		hc := check.(*structs.HealthCheck)
+		node, err := tx.First("nodes", "id", hc.Node)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed node lookup: %s", err)
+		}
+		if node == nil {
+			checks = append(checks, hc)
+			continue
+		}
+		if hc.ServiceID == "" {
+			continue
+		}
+		service, err := tx.First("services", "id", hc.Node, hc.ServiceID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed service lookup: %s", err)
+		}
+		if service == nil {
+			checks = append(checks, hc)
+		}
+	}
+	return services, checks, nil
+}
@@ -121,6 +121,76 @@ func TestStateStore_ensureNoNodeWithSimilarNameTxn(t *testing.T) {
 
 }
 
+func TestStateStore_EnsureNode_ReplaceFailedNode(t *testing.T) {
+	t.Parallel()
+	s := testStateStore(t)
+
+	// Register a node with a service and a passing Serf check.
+	oldID := makeRandomNodeID(t)
+	req := &structs.RegisterRequest{
+		ID:      oldID,
+		Node:    "node1",
+		Address: "1.2.3.4",
+		Service: &structs.NodeService{
+			ID:      "redis1",
+			Service: "redis",
+		},
+		Check: &structs.HealthCheck{
+			Node:    "node1",
+			CheckID: structs.SerfCheckID,
+			Status:  api.HealthPassing,
+		},
+	}
+	if err := s.EnsureRegistration(1, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A new node with the same name but another ID can't take over the
+	// name while the existing node is healthy.
+	newID := makeRandomNodeID(t)
+	node := &structs.Node{ID: newID, Node: "node1", Address: "2.3.4.5"}
+	if err := s.EnsureNode(2, node); err == nil {
+		t.Fatalf("expected an error since the name is reserved")
+	}
+
+	// Once the existing node has failed, the name can be taken over.
+	req.Service = nil
+	req.Check.Status = api.HealthCritical
+	if err := s.EnsureRegistration(3, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	node = &structs.Node{ID: newID, Node: "node1", Address: "2.3.4.5"}
+	if err := s.EnsureNode(4, node); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The new node shouldn't inherit the services and checks of the old one.
+	_, out, err := s.GetNode("node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.ID != newID || out.Address != "2.3.4.5" || out.CreateIndex != 4 {
+		t.Fatalf("bad node: %#v", out)
+	}
+	_, services, err := s.NodeServices(nil, "node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(services.Services) != 0 {
+		t.Fatalf("bad: %#v", services.Services)
+	}
+	_, checks, err := s.NodeChecks(nil, "node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(checks) != 0 {
+		t.Fatalf("bad: %#v", checks)
+	}
+	if _, n, err := s.GetNodeID(oldID); err != nil || n != nil {
+		t.Fatalf("expected old node to be removed, got: (%#v, %#v)", n, err)
+	}
+}
+
 func TestStateStore_EnsureRegistration(t *testing.T) {
 	t.Parallel()
 	s := testStateStore(t)
@@ -351,6 +421,163 @@ func TestStateStore_EnsureRegistration(t *testing.T) {
 	verifyChecks()
 }
 
+func TestStateStore_EnsureRegistrationCAS(t *testing.T) {
+	s := testStateStore(t)
+
+	req := &structs.RegisterRequest{
+		Node:    "node1",
+		Address: "1.2.3.4",
+	}
+
+	// A non-zero index when the node doesn't exist is a no-op.
+	ok, err := s.EnsureRegistrationCAS(1, 1, req)
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, node, err := s.GetNode("node1")
+	require.NoError(t, err)
+	require.Nil(t, node)
+
+	// An index of zero creates the node.
+	ok, err = s.EnsureRegistrationCAS(2, 0, req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, node, err = s.GetNode("node1")
+	require.NoError(t, err)
+	require.NotNil(t, node)
+	require.Equal(t, uint64(2), node.ModifyIndex)
+
+	// But not a second time.
+	req.Address = "1.1.1.1"
+	ok, err = s.EnsureRegistrationCAS(3, 0, req)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// A stale index doesn't update the node.
+	ok, err = s.EnsureRegistrationCAS(3, 1, req)
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, node, err = s.GetNode("node1")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", node.Address)
+
+	// The current index does.
+	ok, err = s.EnsureRegistrationCAS(3, 2, req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, node, err = s.GetNode("node1")
+	require.NoError(t, err)
+	require.Equal(t, "1.1.1.1", node.Address)
+	require.Equal(t, uint64(3), node.ModifyIndex)
+
+	// With a service, the index of the service is checked instead of the
+	// node's.
+	req.Service = &structs.NodeService{
+		ID:      "redis1",
+		Service: "redis",
+		Port:    8080,
+	}
+	ok, err = s.EnsureRegistrationCAS(4, 3, req)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = s.EnsureRegistrationCAS(4, 0, req)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	req.Service.Port = 9090
+	ok, err = s.EnsureRegistrationCAS(5, 3, req)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = s.EnsureRegistrationCAS(5, 4, req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, svc, err := s.NodeService("node1", "redis1")
+	require.NoError(t, err)
+	require.Equal(t, 9090, svc.Port)
+	require.Equal(t, uint64(5), svc.ModifyIndex)
+
+	// The checks being written are compared too, so a check that changed
+	// since the service did makes the service's index stale.
+	req.Checks = structs.HealthChecks{
+		&structs.HealthCheck{
+			Node:      "node1",
+			CheckID:   "check1",
+			Name:      "check",
+			Status:    api.HealthPassing,
+			ServiceID: "redis1",
+		},
+	}
+	ok, err = s.EnsureRegistrationCAS(6, 5, req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	testRegisterCheck(t, s, 7, "node1", "redis1", "check1", api.HealthCritical)
+
+	req.Service.Port = 7070
+	ok, err = s.EnsureRegistrationCAS(8, 6, req)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = s.EnsureRegistrationCAS(8, 7, req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, svc, err = s.NodeService("node1", "redis1")
+	require.NoError(t, err)
+	require.Equal(t, 7070, svc.Port)
+}
+
+func TestStateStore_DeregisterCAS(t *testing.T) {
+	s := testStateStore(t)
+
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterService(t, s, 2, "node1", "service1")
+	testRegisterCheck(t, s, 3, "node1", "", "check1", api.HealthPassing)
+
+	// Deleting things that don't exist succeeds.
+	ok, err := s.DeleteServiceCAS(4, 1, "node1", "nope")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = s.DeleteCheckCAS(4, 1, "node1", "nope")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = s.DeleteNodeCAS(4, 1, "nope")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Stale indexes leave things alone.
+	ok, err = s.DeleteServiceCAS(4, 1, "node1", "service1")
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = s.DeleteCheckCAS(4, 1, "node1", "check1")
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = s.DeleteNodeCAS(4, 2, "node1")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, ns, err := s.NodeService("node1", "service1")
+	require.NoError(t, err)
+	require.NotNil(t, ns)
+	_, hc, err := s.NodeCheck("node1", "check1")
+	require.NoError(t, err)
+	require.NotNil(t, hc)
+
+	// The current indexes delete them.
+	ok, err = s.DeleteServiceCAS(4, 2, "node1", "service1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = s.DeleteCheckCAS(5, 3, "node1", "check1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = s.DeleteNodeCAS(6, 1, "node1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, node, err := s.GetNode("node1")
+	require.NoError(t, err)
+	require.Nil(t, node)
+	if idx := s.maxIndex("nodes"); idx != 6 {
+		t.Fatalf("bad index: %d", idx)
+	}
+}
+
 func TestStateStore_EnsureRegistration_Restore(t *testing.T) {
 	s := testStateStore(t)
 
@@ -956,6 +1183,70 @@ func TestStateStore_EnsureNode(t *testing.T) {
 	}
 }
 
+func TestStateStore_NodeCount(t *testing.T) {
+	s := testStateStore(t)
+
+	checkCount := func(expected int) {
+		t.Helper()
+		count, err := s.NodeCount()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if count != expected {
+			t.Fatalf("bad node count: %d", count)
+		}
+	}
+	checkCount(0)
+
+	// Registering new nodes adds to the count, but updates don't.
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterNode(t, s, 2, "node2")
+	checkCount(2)
+	if err := s.EnsureNode(3, &structs.Node{Node: "node1", Address: "1.2.3.4"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	checkCount(2)
+
+	// Renaming a node doesn't change the count.
+	id := types.NodeID("cda916bc-a357-4a19-b886-59419fcee50c")
+	if err := s.EnsureNode(4, &structs.Node{ID: id, Node: "node3", Address: "1.2.3.4"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	checkCount(3)
+	if err := s.EnsureNode(5, &structs.Node{ID: id, Node: "node4", Address: "1.2.3.4"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	checkCount(3)
+
+	// Deleting nodes takes them off the count.
+	if err := s.DeleteNode(6, "node1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.DeleteNode(7, "node4"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	checkCount(1)
+
+	// The count is rebuilt when restoring a snapshot.
+	snap := s.Snapshot()
+	defer snap.Close()
+	nodes, err := snap.Nodes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s = testStateStore(t)
+	restore := s.Restore()
+	for node := nodes.Next(); node != nil; node = nodes.Next() {
+		n := node.(*structs.Node)
+		req := &structs.RegisterRequest{Node: n.Node, Address: n.Address}
+		if err := restore.Registration(n.ModifyIndex, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	restore.Commit()
+	checkCount(1)
+}
+
 func TestStateStore_GetNodes(t *testing.T) {
 	s := testStateStore(t)
 
@@ -1213,6 +1504,74 @@ func TestStateStore_NodeServices(t *testing.T) {
 	}
 }
 
+func TestStateStore_NodesWithoutService(t *testing.T) {
+	s := testStateStore(t)
+
+	// Listing with no nodes returns nil
+	ws := memdb.NewWatchSet()
+	idx, res, err := s.NodesWithoutService(ws, "web", nil)
+	if idx != 0 || res != nil || err != nil {
+		t.Fatalf("expected (0, nil, nil), got: (%d, %#v, %#v)", idx, res, err)
+	}
+
+	// node0 has a passing instance, node1 a critical one, and node2 none.
+	testRegisterNodeWithMeta(t, s, 1, "node0", map[string]string{"role": "web"})
+	testRegisterNodeWithMeta(t, s, 2, "node1", map[string]string{"role": "web"})
+	testRegisterNodeWithMeta(t, s, 3, "node2", map[string]string{"role": "db"})
+	testRegisterService(t, s, 4, "node0", "web")
+	testRegisterCheck(t, s, 5, "node0", "web", "check0", api.HealthPassing)
+	testRegisterService(t, s, 6, "node1", "web")
+	testRegisterCheck(t, s, 7, "node1", "web", "check1", api.HealthCritical)
+	if !watchFired(ws) {
+		t.Fatalf("bad")
+	}
+
+	cases := []struct {
+		filters map[string]string
+		nodes   []string
+	}{
+		{nil, []string{"node1", "node2"}},
+		{map[string]string{"role": "web"}, []string{"node1"}},
+		{map[string]string{"role": "nope"}, []string{}},
+	}
+	for _, tc := range cases {
+		idx, result, err := s.NodesWithoutService(nil, "web", tc.filters)
+		if err != nil {
+			t.Fatalf("bad: %v", err)
+		}
+		if idx != 7 {
+			t.Fatalf("bad index: %d", idx)
+		}
+		if len(result) != len(tc.nodes) {
+			t.Fatalf("bad: %v %v", result, tc.nodes)
+		}
+		for i, node := range result {
+			if node.Node != tc.nodes[i] {
+				t.Fatalf("bad: %v %v", node.Node, tc.nodes[i])
+			}
+		}
+	}
+
+	// The nodes with a passing instance are left out, whatever else they
+	// have.
+	testRegisterServiceWithChange(t, s, 8, "node1", "web2", true)
+	ws = memdb.NewWatchSet()
+	_, result, err := s.NodesWithoutService(ws, "web", nil)
+	if err != nil || len(result) != 2 {
+		t.Fatalf("bad: %v %v", result, err)
+	}
+
+	// A check passing again fires the watch and changes the results.
+	testRegisterCheck(t, s, 9, "node1", "web", "check1", api.HealthPassing)
+	if !watchFired(ws) {
+		t.Fatalf("bad")
+	}
+	_, result, err = s.NodesWithoutService(nil, "web", nil)
+	if err != nil || len(result) != 1 || result[0].Node != "node2" {
+		t.Fatalf("bad: %v %v", result, err)
+	}
+}
+
 func TestStateStore_DeleteNode(t *testing.T) {
 	s := testStateStore(t)
 
@@ -1960,6 +2319,79 @@ func TestStateStore_ServiceTagNodes_MultipleTags(t *testing.T) {
 	require.Equal(t, nodes[0].Address, "127.0.0.1")
 	require.Contains(t, nodes[0].ServiceTags, "dev")
 	require.Equal(t, nodes[0].ServicePort, 8001)
+
+	// Tags are matched regardless of case.
+	idx, nodes, err = s.ServiceTagNodes(nil, "DB", []string{"MASTER", "V2"})
+	require.NoError(t, err)
+	require.Equal(t, int(idx), 19)
+	require.Len(t, nodes, 1)
+	require.Equal(t, nodes[0].ServiceID, "db")
+	require.Equal(t, nodes[0].Node, "foo")
+
+	// The index for the service is returned even when no instance has
+	// the first tag.
+	idx, nodes, err = s.ServiceTagNodes(nil, "db", []string{"canary", "v2"})
+	require.NoError(t, err)
+	require.Equal(t, int(idx), 19)
+	require.Len(t, nodes, 0)
+}
+
+func TestStateStore_ServiceAddressNodes(t *testing.T) {
+	s := testStateStore(t)
+
+	testRegisterNode(t, s, 1, "foo")
+	testRegisterNode(t, s, 2, "bar")
+	require.NoError(t, s.EnsureService(3, "foo", &structs.NodeService{ID: "db", Service: "db", Address: "10.0.0.1", Port: 8000}))
+	require.NoError(t, s.EnsureService(4, "bar", &structs.NodeService{ID: "db", Service: "db", Address: "10.0.0.2", Port: 8000}))
+	require.NoError(t, s.EnsureService(5, "bar", &structs.NodeService{ID: "web", Service: "web", Port: 80}))
+
+	ws := memdb.NewWatchSet()
+	_, nodes, err := s.ServiceAddressNodes(ws, "10.0.0.2")
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	require.Equal(t, "bar", nodes[0].Node)
+	require.Equal(t, "db", nodes[0].ServiceID)
+
+	// Services without an address are found too.
+	_, nodes, err = s.ServiceAddressNodes(nil, "")
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	require.Equal(t, "web", nodes[0].ServiceID)
+
+	// Moving a service to the address fires the watch.
+	require.NoError(t, s.EnsureService(6, "foo", &structs.NodeService{ID: "db", Service: "db", Address: "10.0.0.2", Port: 8000}))
+	require.True(t, watchFired(ws))
+	_, nodes, err = s.ServiceAddressNodes(nil, "10.0.0.2")
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+}
+
+func BenchmarkServiceTagNodes(b *testing.B) {
+	s, err := NewStateStore(nil)
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	// A large service where only a few instances have the tag being
+	// looked for.
+	if err := s.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}); err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10000; i++ {
+		tags := []string{fmt.Sprintf("instance-%d", i)}
+		if i%1000 == 0 {
+			tags = append(tags, "master")
+		}
+		svc := &structs.NodeService{ID: fmt.Sprintf("db%d", i), Service: "db", Tags: tags, Port: 8000 + i}
+		if err := s.EnsureService(uint64(2+i), "foo", svc); err != nil {
+			b.Fatalf("err: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.ServiceTagNodes(nil, "db", []string{"master"})
+	}
 }
 
 func TestStateStore_DeleteService(t *testing.T) {
@@ -2264,6 +2696,44 @@ func TestStateStore_EnsureCheck_defaultStatus(t *testing.T) {
 	}
 }
 
+func TestStateStore_NodesWithCheck(t *testing.T) {
+	s := testStateStore(t)
+
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterNode(t, s, 2, "node2")
+	testRegisterCheck(t, s, 3, "node1", "", "drain", api.HealthPassing)
+
+	ws := memdb.NewWatchSet()
+	idx, found, err := s.NodesWithCheck(ws, []string{"node1", "node2", "node1"}, "drain")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 3 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	if !reflect.DeepEqual(found, map[string]bool{"node1": true, "node2": false}) {
+		t.Fatalf("bad: %v", found)
+	}
+
+	// Registering the check on another of the nodes fires the watch.
+	testRegisterCheck(t, s, 5, "node2", "", "drain", api.HealthPassing)
+	if !watchFired(ws) {
+		t.Fatalf("bad")
+	}
+
+	// So does deregistering it.
+	ws = memdb.NewWatchSet()
+	if _, _, err := s.NodesWithCheck(ws, []string{"node1", "node2"}, "drain"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.DeleteCheck(6, "node1", "drain"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !watchFired(ws) {
+		t.Fatalf("bad")
+	}
+}
+
 func TestStateStore_NodeChecks(t *testing.T) {
 	s := testStateStore(t)
 
@@ -3453,3 +3923,140 @@ func TestStateStore_NodeInfo_NodeDump(t *testing.T) {
 		t.Fatalf("bad")
 	}
 }
+
+func TestStateStore_CatalogOrphans(t *testing.T) {
+	t.Parallel()
+	s := testStateStore(t)
+
+	// Register a node with a service and checks, which aren't orphans.
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterService(t, s, 2, "node1", "service1")
+	testRegisterCheck(t, s, 3, "node1", "service1", "check1", api.HealthPassing)
+	testRegisterCheck(t, s, 4, "node1", "", "check2", api.HealthPassing)
+
+	services, checks, err := s.CatalogOrphans()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(services) != 0 || len(checks) != 0 {
+		t.Fatalf("bad: %#v %#v", services, checks)
+	}
+
+	// Sneak in some orphans, since the state store won't allow them to be
+	// registered normally.
+	tx := s.db.Txn(true)
+	orphanService := &structs.ServiceNode{
+		Node:        "node2",
+		ServiceID:   "service2",
+		ServiceName: "service2",
+	}
+	if err := tx.Insert("services", orphanService); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	orphanNodeCheck := &structs.HealthCheck{
+		Node:    "node2",
+		CheckID: "check3",
+		Status:  api.HealthPassing,
+	}
+	if err := tx.Insert("checks", orphanNodeCheck); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	orphanServiceCheck := &structs.HealthCheck{
+		Node:      "node1",
+		CheckID:   "check4",
+		ServiceID: "nope",
+		Status:    api.HealthPassing,
+	}
+	if err := tx.Insert("checks", orphanServiceCheck); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tx.Commit()
+
+	services, checks, err = s.CatalogOrphans()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(services) != 1 || services[0] != orphanService {
+		t.Fatalf("bad: %#v", services)
+	}
+	if len(checks) != 2 || checks[0] != orphanServiceCheck || checks[1] != orphanNodeCheck {
+		t.Fatalf("bad: %#v", checks)
+	}
+}
+
+func TestStateStore_RegistrationIsCurrent(t *testing.T) {
+	t.Parallel()
+	s := testStateStore(t)
+
+	req := &structs.RegisterRequest{
+		ID:      makeRandomNodeID(t),
+		Node:    "node1",
+		Address: "1.2.3.4",
+		Service: &structs.NodeService{
+			ID:      "redis1",
+			Service: "redis",
+			Port:    8000,
+		},
+		Checks: structs.HealthChecks{
+			&structs.HealthCheck{
+				Node:      "node1",
+				CheckID:   "check1",
+				Name:      "check",
+				ServiceID: "redis1",
+				Status:    api.HealthPassing,
+				Output:    "ok",
+			},
+		},
+	}
+
+	// Nothing is current before the first registration.
+	current, err := s.RegistrationIsCurrent(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if current {
+		t.Fatalf("registration should not be current")
+	}
+
+	// The registration is current once applied, even though the state
+	// store filled in the service weights and the check's service name.
+	if err := s.EnsureRegistration(1, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	current, err = s.RegistrationIsCurrent(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !current {
+		t.Fatalf("registration should be current")
+	}
+
+	// Any change to the node, service or checks is noticed.
+	changes := []func(r *structs.RegisterRequest){
+		func(r *structs.RegisterRequest) { r.Address = "2.3.4.5" },
+		func(r *structs.RegisterRequest) { r.Service.Port = 8001 },
+		func(r *structs.RegisterRequest) { r.Checks[0].Status = api.HealthCritical },
+		func(r *structs.RegisterRequest) { r.Checks[0].Output = "still ok" },
+		func(r *structs.RegisterRequest) { r.Checks[0].Definition.HTTP = "http://localhost" },
+		func(r *structs.RegisterRequest) { r.Checks[0].CheckID = "check2" },
+	}
+	for i, change := range changes {
+		changed := &structs.RegisterRequest{
+			ID:      req.ID,
+			Node:    req.Node,
+			Address: req.Address,
+			Service: &structs.NodeService{},
+			Checks:  structs.HealthChecks{req.Checks[0].Clone()},
+		}
+		*changed.Service = *req.Service
+		change(changed)
+
+		current, err := s.RegistrationIsCurrent(changed)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if current {
+			t.Fatalf("%d: registration should not be current", i)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package state
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	changeFeedTableName = "change-feed"
+
+	// changeFeedSeqIndex and changeFeedTrimmedIndex are kept in the index
+	// table so they are saved in snapshots along with the entries. They
+	// hold the sequence number of the newest entry, and the Raft index of
+	// the newest entry that was trimmed from the feed.
+	changeFeedSeqIndex     = "change-feed-seq"
+	changeFeedTrimmedIndex = "change-feed-trimmed"
+
+	// changeFeedMaxEntriesIndex holds the most entries kept in the feed, or
+	// 0 if it's disabled. It's written through Raft so every server keeps
+	// the same history.
+	changeFeedMaxEntriesIndex = "change-feed-max-entries"
+)
+
+// changeFeedTableSchema returns a new table schema used for storing the
+// change feed.
+func changeFeedTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: changeFeedTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer:      &changeFeedIndexer{},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(changeFeedTableSchema)
+}
+
+// changeFeedIndexer indexes change feed entries by their Raft index. Unlike
+// memdb.UintFieldIndex it uses a fixed size big-endian encoding, so iterating
+// the index returns the entries in order.
+type changeFeedIndexer struct{}
+
+func (*changeFeedIndexer) FromObject(obj interface{}) (bool, []byte, error) {
+	e, ok := obj.(*structs.ChangeFeedEntry)
+	if !ok {
+		return false, nil, fmt.Errorf("Object must be ChangeFeedEntry, got %T", obj)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, e.Index)
+	return true, buf, nil
+}
+
+func (*changeFeedIndexer) FromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("must provide only a single argument")
+	}
+	idx, ok := args[0].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a uint64: %#v", args[0])
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, idx)
+	return buf, nil
+}
+
+// ChangeFeed is used to pull all the change feed entries from the snapshot.
+func (s *Snapshot) ChangeFeed() (structs.ChangeFeedEntries, error) {
+	iter, err := s.tx.Get(changeFeedTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret structs.ChangeFeedEntries
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		ret = append(ret, e.(*structs.ChangeFeedEntry))
+	}
+	return ret, nil
+}
+
+// ChangeFeedEntry is used when restoring from a snapshot.
+func (s *Restore) ChangeFeedEntry(e *structs.ChangeFeedEntry) error {
+	if err := s.tx.Insert(changeFeedTableName, e); err != nil {
+		return fmt.Errorf("failed restoring change feed entry: %s", err)
+	}
+	if err := indexUpdateMaxTxn(s.tx, e.Index, changeFeedTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// ChangeFeedMaxEntries returns the most entries kept in the change feed, or 0
+// if the change feed is disabled.
+func (s *Store) ChangeFeedMaxEntries() int {
+	return int(s.maxIndex(changeFeedMaxEntriesIndex))
+}
+
+// ChangeFeedSetMaxEntries sets the most entries kept in the change feed. A
+// maximum of 0 disables the feed and drops its entries. Since nothing is
+// recorded while the feed is disabled, enabling it marks everything before
+// idx as trimmed so consumers know to resynchronize.
+func (s *Store) ChangeFeedSetMaxEntries(idx uint64, maxEntries int) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if maxEntries < 0 {
+		maxEntries = 0
+	}
+	prev := maxIndexTxn(tx, changeFeedMaxEntriesIndex)
+	if err := tx.Insert("index", &IndexEntry{changeFeedMaxEntriesIndex, uint64(maxEntries)}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	switch {
+	case maxEntries == 0 && prev > 0:
+		if _, err := tx.DeleteAll(changeFeedTableName, "id"); err != nil {
+			return fmt.Errorf("failed clearing change feed: %s", err)
+		}
+		if err := indexUpdateMaxTxn(tx, idx, changeFeedTableName); err != nil {
+			return fmt.Errorf("failed updating index: %s", err)
+		}
+	case maxEntries > 0 && prev == 0:
+		if err := indexUpdateMaxTxn(tx, idx, changeFeedTrimmedIndex); err != nil {
+			return fmt.Errorf("failed updating index: %s", err)
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// ChangeFeedAppend records the changes made by the write at the given index,
+// and then trims the oldest entries so the feed has at most maxEntries.
+func (s *Store) ChangeFeedAppend(idx uint64, changes []*structs.Change, maxEntries int) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	seq := maxIndexTxn(tx, changeFeedSeqIndex) + 1
+	entry := &structs.ChangeFeedEntry{
+		Index:   idx,
+		Seq:     seq,
+		Changes: changes,
+	}
+	if err := tx.Insert(changeFeedTableName, entry); err != nil {
+		return fmt.Errorf("failed inserting change feed entry: %s", err)
+	}
+	if err := indexUpdateMaxTxn(tx, idx, changeFeedTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	if err := indexUpdateMaxTxn(tx, seq, changeFeedSeqIndex); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	if maxEntries > 0 && seq > uint64(maxEntries) {
+		oldest := seq - uint64(maxEntries)
+		iter, err := tx.Get(changeFeedTableName, "id")
+		if err != nil {
+			return fmt.Errorf("failed change feed lookup: %s", err)
+		}
+		var trim structs.ChangeFeedEntries
+		for e := iter.Next(); e != nil; e = iter.Next() {
+			entry := e.(*structs.ChangeFeedEntry)
+			if entry.Seq > oldest {
+				break
+			}
+			trim = append(trim, entry)
+		}
+		for _, entry := range trim {
+			if err := tx.Delete(changeFeedTableName, entry); err != nil {
+				return fmt.Errorf("failed trimming change feed: %s", err)
+			}
+			if err := indexUpdateMaxTxn(tx, entry.Index, changeFeedTrimmedIndex); err != nil {
+				return fmt.Errorf("failed updating index: %s", err)
+			}
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// ChangeFeedList returns the change feed entries after the given index, up to
+// limit of them if limit is positive. It also returns the Raft index of the
+// newest entry that was trimmed from the feed, so callers can tell if entries
+// after the given index are missing, and whether there are more entries past
+// the limit.
+func (s *Store) ChangeFeedList(ws memdb.WatchSet, since uint64, limit int) (uint64, uint64, structs.ChangeFeedEntries, bool, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, changeFeedTableName)
+	if idx < 1 {
+		idx = 1
+	}
+	trimmed := maxIndexTxn(tx, changeFeedTrimmedIndex)
+
+	iter, err := tx.Get(changeFeedTableName, "id")
+	if err != nil {
+		return 0, 0, nil, false, fmt.Errorf("failed change feed lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+
+	results := structs.ChangeFeedEntries{}
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		entry := e.(*structs.ChangeFeedEntry)
+		if entry.Index <= since {
+			continue
+		}
+		if limit > 0 && len(results) == limit {
+			return idx, trimmed, results, true, nil
+		}
+		results = append(results, entry)
+	}
+	return idx, trimmed, results, false, nil
+}
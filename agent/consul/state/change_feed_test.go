@@ -0,0 +1,94 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ChangeFeed(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	ws := memdb.NewWatchSet()
+	idx, trimmed, entries, more, err := s.ChangeFeedList(ws, 0, 0)
+	require.NoError(err)
+	require.Equal(uint64(1), idx)
+	require.Zero(trimmed)
+	require.Empty(entries)
+	require.False(more)
+
+	change := func(key string) []*structs.Change {
+		return []*structs.Change{{Type: structs.ChangeKV, Op: structs.ChangeDelete, Key: key}}
+	}
+
+	// Indexes are sparse and ordered numerically, including across byte
+	// boundaries.
+	require.NoError(s.ChangeFeedAppend(5, change("a"), 3))
+	require.True(watchFired(ws))
+	require.NoError(s.ChangeFeedAppend(255, change("b"), 3))
+	require.NoError(s.ChangeFeedAppend(256, change("c"), 3))
+
+	idx, trimmed, entries, more, err = s.ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Equal(uint64(256), idx)
+	require.Zero(trimmed)
+	require.False(more)
+	require.Len(entries, 3)
+	require.Equal(uint64(5), entries[0].Index)
+	require.Equal(uint64(1), entries[0].Seq)
+	require.Equal("c", entries[2].Changes[0].Key)
+
+	// Reads start after the given index and stop at the limit.
+	_, _, entries, more, err = s.ChangeFeedList(nil, 5, 1)
+	require.NoError(err)
+	require.True(more)
+	require.Len(entries, 1)
+	require.Equal(uint64(255), entries[0].Index)
+
+	// Appending past the maximum trims the oldest entry and remembers
+	// its index.
+	require.NoError(s.ChangeFeedAppend(300, change("d"), 3))
+	idx, trimmed, entries, more, err = s.ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Equal(uint64(300), idx)
+	require.Equal(uint64(5), trimmed)
+	require.False(more)
+	require.Len(entries, 3)
+	require.Equal(uint64(255), entries[0].Index)
+	require.Equal(uint64(4), entries[2].Seq)
+
+	// Snapshot and restore keep the entries and the trim position.
+	snap := s.Snapshot()
+	defer snap.Close()
+	dump, err := snap.ChangeFeed()
+	require.NoError(err)
+	require.Equal(entries, dump)
+
+	s2 := testStateStore(t)
+	restore := s2.Restore()
+	for _, e := range dump {
+		require.NoError(restore.ChangeFeedEntry(e))
+	}
+	indexes, err := snap.Indexes()
+	require.NoError(err)
+	for raw := indexes.Next(); raw != nil; raw = indexes.Next() {
+		require.NoError(restore.IndexRestore(raw.(*IndexEntry)))
+	}
+	restore.Commit()
+
+	idx, trimmed, restored, _, err := s2.ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Equal(uint64(300), idx)
+	require.Equal(uint64(5), trimmed)
+	require.Equal(entries, restored)
+
+	require.NoError(s2.ChangeFeedAppend(301, change("e"), 3))
+	_, trimmed, restored, _, err = s2.ChangeFeedList(nil, 0, 0)
+	require.NoError(err)
+	require.Equal(uint64(255), trimmed)
+	require.Len(restored, 3)
+	require.Equal(uint64(5), restored[2].Seq)
+}
@@ -0,0 +1,203 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	configEntriesTableName = "config-entries"
+)
+
+// configEntriesTableSchema returns a new table schema used for storing config
+// entries, which are looked up by kind and name.
+func configEntriesTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: configEntriesTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.CompoundIndex{
+					Indexes: []memdb.Indexer{
+						&memdb.StringFieldIndex{
+							Field:     "Kind",
+							Lowercase: true,
+						},
+						&memdb.StringFieldIndex{
+							Field:     "Name",
+							Lowercase: true,
+						},
+					},
+				},
+			},
+			"kind": &memdb.IndexSchema{
+				Name:         "kind",
+				AllowMissing: false,
+				Unique:       false,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "Kind",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(configEntriesTableSchema)
+}
+
+// ConfigEntries is used to pull all the config entries from the snapshot.
+func (s *Snapshot) ConfigEntries() (structs.ConfigEntries, error) {
+	iter, err := s.tx.Get(configEntriesTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret structs.ConfigEntries
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		ret = append(ret, e.(*structs.ConfigEntry))
+	}
+	return ret, nil
+}
+
+// ConfigEntry is used when restoring from a snapshot.
+func (s *Restore) ConfigEntry(e *structs.ConfigEntry) error {
+	if err := s.tx.Insert(configEntriesTableName, e); err != nil {
+		return fmt.Errorf("failed restoring config entry: %s", err)
+	}
+	if err := indexUpdateMaxTxn(s.tx, e.ModifyIndex, configEntriesTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// ConfigEntries returns the config entries of the given kind, or all of them
+// if the kind is empty, sorted by kind and name.
+func (s *Store) ConfigEntries(ws memdb.WatchSet, kind string) (uint64, structs.ConfigEntries, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, configEntriesTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	var iter memdb.ResultIterator
+	var err error
+	if kind == "" {
+		iter, err = tx.Get(configEntriesTableName, "id")
+	} else {
+		iter, err = tx.Get(configEntriesTableName, "kind", kind)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed config entry lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+
+	results := structs.ConfigEntries{}
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		results = append(results, e.(*structs.ConfigEntry))
+	}
+	return idx, results, nil
+}
+
+// ConfigEntry returns the config entry with the given kind and name, or nil
+// if there isn't one.
+func (s *Store) ConfigEntry(ws memdb.WatchSet, kind, name string) (uint64, *structs.ConfigEntry, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, configEntriesTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	watchCh, e, err := tx.FirstWatch(configEntriesTableName, "id", kind, name)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed config entry lookup: %s", err)
+	}
+	ws.Add(watchCh)
+
+	if e == nil {
+		return idx, nil, nil
+	}
+	return idx, e.(*structs.ConfigEntry), nil
+}
+
+// ConfigEntrySet creates or updates a config entry.
+func (s *Store) ConfigEntrySet(idx uint64, e *structs.ConfigEntry) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.configEntrySetTxn(tx, idx, e); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// configEntrySetTxn is the inner method used to insert a config entry with
+// the proper indexes into the state store.
+func (s *Store) configEntrySetTxn(tx *memdb.Txn, idx uint64, e *structs.ConfigEntry) error {
+	if e.Kind == "" || e.Name == "" {
+		return fmt.Errorf("Missing config entry kind or name")
+	}
+
+	existing, err := tx.First(configEntriesTableName, "id", e.Kind, e.Name)
+	if err != nil {
+		return fmt.Errorf("failed config entry lookup: %s", err)
+	}
+	if existing != nil {
+		e.CreateIndex = existing.(*structs.ConfigEntry).CreateIndex
+	} else {
+		e.CreateIndex = idx
+	}
+	e.ModifyIndex = idx
+
+	if err := tx.Insert(configEntriesTableName, e); err != nil {
+		return fmt.Errorf("failed inserting config entry: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{configEntriesTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// ConfigEntryDelete deletes the config entry with the given kind and name.
+func (s *Store) ConfigEntryDelete(idx uint64, kind, name string) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.configEntryDeleteTxn(tx, idx, kind, name); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// configEntryDeleteTxn is the inner method used to delete a config entry
+// with the proper indexes into the state store.
+func (s *Store) configEntryDeleteTxn(tx *memdb.Txn, idx uint64, kind, name string) error {
+	e, err := tx.First(configEntriesTableName, "id", kind, name)
+	if err != nil {
+		return fmt.Errorf("failed config entry lookup: %s", err)
+	}
+	if e == nil {
+		return nil
+	}
+
+	if err := tx.Delete(configEntriesTableName, e); err != nil {
+		return fmt.Errorf("failed deleting config entry: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{configEntriesTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ConfigEntry_CRUD(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	ws := memdb.NewWatchSet()
+	idx, e, err := s.ConfigEntry(ws, structs.ServiceDefaults, "web")
+	require.NoError(err)
+	require.Equal(uint64(1), idx)
+	require.Nil(e)
+
+	// Create an entry.
+	require.NoError(s.ConfigEntrySet(2, &structs.ConfigEntry{
+		Kind:   structs.ServiceDefaults,
+		Name:   "web",
+		Config: map[string]interface{}{"protocol": "http"},
+	}))
+	require.True(watchFired(ws))
+
+	// Lookups are case-insensitive.
+	ws = memdb.NewWatchSet()
+	idx, e, err = s.ConfigEntry(ws, structs.ServiceDefaults, "WEB")
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Equal(&structs.ConfigEntry{
+		Kind:      structs.ServiceDefaults,
+		Name:      "web",
+		Config:    map[string]interface{}{"protocol": "http"},
+		RaftIndex: structs.RaftIndex{CreateIndex: 2, ModifyIndex: 2},
+	}, e)
+
+	// Update it, which keeps the create index.
+	require.NoError(s.ConfigEntrySet(3, &structs.ConfigEntry{
+		Kind:   structs.ServiceDefaults,
+		Name:   "web",
+		Config: map[string]interface{}{"protocol": "grpc"},
+	}))
+	require.True(watchFired(ws))
+	_, e, err = s.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	require.NoError(err)
+	require.Equal("grpc", e.Config["protocol"])
+	require.Equal(structs.RaftIndex{CreateIndex: 2, ModifyIndex: 3}, e.RaftIndex)
+
+	// The same name can be used by another kind.
+	require.NoError(s.ConfigEntrySet(4, &structs.ConfigEntry{
+		Kind: "router",
+		Name: "web",
+	}))
+	require.NoError(s.ConfigEntrySet(5, &structs.ConfigEntry{
+		Kind: structs.ServiceDefaults,
+		Name: "api",
+	}))
+
+	// Listing is sorted by kind and name, and can be filtered by kind.
+	idx, entries, err := s.ConfigEntries(nil, "")
+	require.NoError(err)
+	require.Equal(uint64(5), idx)
+	require.Len(entries, 3)
+	require.Equal("router", entries[0].Kind)
+	require.Equal("api", entries[1].Name)
+	require.Equal("web", entries[2].Name)
+
+	_, entries, err = s.ConfigEntries(nil, structs.ServiceDefaults)
+	require.NoError(err)
+	require.Len(entries, 2)
+	require.Equal("api", entries[0].Name)
+	require.Equal("web", entries[1].Name)
+
+	// Delete one. Deleting it again is a no-op.
+	require.NoError(s.ConfigEntryDelete(6, structs.ServiceDefaults, "web"))
+	require.NoError(s.ConfigEntryDelete(7, structs.ServiceDefaults, "web"))
+	idx, e, err = s.ConfigEntry(nil, structs.ServiceDefaults, "web")
+	require.NoError(err)
+	require.Equal(uint64(6), idx)
+	require.Nil(e)
+
+	_, e, err = s.ConfigEntry(nil, "router", "web")
+	require.NoError(err)
+	require.NotNil(e)
+
+	// A kind and name are required.
+	require.Error(s.ConfigEntrySet(8, &structs.ConfigEntry{Kind: "router"}))
+	require.Error(s.ConfigEntrySet(8, &structs.ConfigEntry{Name: "web"}))
+}
+
+func TestStore_ConfigEntry_Snapshot_Restore(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	require.NoError(s.ConfigEntrySet(1, &structs.ConfigEntry{
+		Kind: structs.ServiceDefaults,
+		Name: "web",
+	}))
+	require.NoError(s.ConfigEntrySet(2, &structs.ConfigEntry{
+		Kind: "router",
+		Name: "web",
+	}))
+
+	snap := s.Snapshot()
+	defer snap.Close()
+
+	// Alter the real state store.
+	require.NoError(s.ConfigEntryDelete(3, structs.ServiceDefaults, "web"))
+
+	dump, err := snap.ConfigEntries()
+	require.NoError(err)
+	require.Len(dump, 2)
+
+	s2 := testStateStore(t)
+	restore := s2.Restore()
+	for _, e := range dump {
+		require.NoError(restore.ConfigEntry(e))
+	}
+	restore.Commit()
+
+	idx, entries, err := s2.ConfigEntries(nil, "")
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Len(entries, 2)
+}
@@ -6,6 +6,13 @@ import (
 	"github.com/hashicorp/go-memdb"
 )
 
+const (
+	// tombstonesReapedIndexName keeps track of the highest index passed to
+	// ReapTxn. Any tombstone at or below this index may have been reaped, so
+	// deletions older than it can no longer be reported reliably.
+	tombstonesReapedIndexName = "tombstones_reaped"
+)
+
 // Tombstone is the internal type used to track tombstones.
 type Tombstone struct {
 	Key   string
@@ -110,5 +117,22 @@ func (g *Graveyard) ReapTxn(tx *memdb.Txn, idx uint64) error {
 			return fmt.Errorf("failed deleting tombstone: %s", err)
 		}
 	}
+
+	if err := indexUpdateMaxTxn(tx, idx, tombstonesReapedIndexName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
 	return nil
 }
+
+// GetReapedIndexTxn returns the highest index that tombstones have been
+// reaped up to, or zero if no tombstones have ever been reaped.
+func (g *Graveyard) GetReapedIndexTxn(tx *memdb.Txn) (uint64, error) {
+	ti, err := tx.First("index", "id", tombstonesReapedIndexName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve reaped index: %s", err)
+	}
+	if ti == nil {
+		return 0, nil
+	}
+	return ti.(*IndexEntry).Value, nil
+}
@@ -0,0 +1,62 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// IndexServiceTag indexes a *struct.ServiceNode by each of its tags, scoped
+// to the service's name, so instances of a service with a given tag can be
+// found without scanning every instance of the service. Both the name and
+// the tags are lower cased to match the tag filtering in queries.
+type IndexServiceTag struct{}
+
+func (idx *IndexServiceTag) FromObject(obj interface{}) (bool, [][]byte, error) {
+	sn, ok := obj.(*structs.ServiceNode)
+	if !ok {
+		return false, nil, fmt.Errorf("Object must be ServiceNode, got %T", obj)
+	}
+	if sn.ServiceName == "" || len(sn.ServiceTags) == 0 {
+		return false, nil, nil
+	}
+
+	// Tags only differing by case map to the same value, which should only
+	// be indexed once.
+	seen := make(map[string]struct{}, len(sn.ServiceTags))
+	vals := make([][]byte, 0, len(sn.ServiceTags))
+	for _, tag := range sn.ServiceTags {
+		tag = strings.ToLower(tag)
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		vals = append(vals, serviceTagIndexValue(sn.ServiceName, tag))
+	}
+	return true, vals, nil
+}
+
+func (idx *IndexServiceTag) FromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("must provide a service name and a tag")
+	}
+
+	service, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string: %#v", args[0])
+	}
+	tag, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string: %#v", args[1])
+	}
+	return serviceTagIndexValue(service, tag), nil
+}
+
+// serviceTagIndexValue returns the index value for a service and tag. Both
+// are null terminated so we can differentiate prefix vs. non-prefix matches.
+func serviceTagIndexValue(service, tag string) []byte {
+	val := append([]byte(strings.ToLower(service)), '\x00')
+	val = append(val, strings.ToLower(tag)...)
+	return append(val, '\x00')
+}
@@ -0,0 +1,121 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexServiceTag_FromObject(t *testing.T) {
+	cases := []struct {
+		Name        string
+		Input       interface{}
+		ExpectMatch bool
+		ExpectVals  [][]byte
+		ExpectErr   string
+	}{
+		{
+			"not a ServiceNode",
+			42,
+			false,
+			nil,
+			"ServiceNode",
+		},
+
+		{
+			"no tags",
+			&structs.ServiceNode{
+				ServiceName: "db",
+			},
+			false,
+			nil,
+			"",
+		},
+
+		{
+			"tags",
+			&structs.ServiceNode{
+				ServiceName: "dB",
+				ServiceTags: []string{"Primary", "v1"},
+			},
+			true,
+			[][]byte{[]byte("db\x00primary\x00"), []byte("db\x00v1\x00")},
+			"",
+		},
+
+		{
+			"tags differing by case",
+			&structs.ServiceNode{
+				ServiceName: "db",
+				ServiceTags: []string{"primary", "PRIMARY"},
+			},
+			true,
+			[][]byte{[]byte("db\x00primary\x00")},
+			"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			require := require.New(t)
+
+			var idx IndexServiceTag
+			match, vals, err := idx.FromObject(tc.Input)
+			if tc.ExpectErr != "" {
+				require.Error(err)
+				require.Contains(err.Error(), tc.ExpectErr)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tc.ExpectMatch, match)
+			require.Equal(tc.ExpectVals, vals)
+		})
+	}
+}
+
+func TestIndexServiceTag_FromArgs(t *testing.T) {
+	cases := []struct {
+		Name      string
+		Args      []interface{}
+		ExpectVal []byte
+		ExpectErr string
+	}{
+		{
+			"single argument",
+			[]interface{}{"foo"},
+			nil,
+			"service name and a tag",
+		},
+
+		{
+			"not a string",
+			[]interface{}{"foo", 42},
+			nil,
+			"must be a string",
+		},
+
+		{
+			"strings",
+			[]interface{}{"fOO", "Bar"},
+			[]byte("foo\x00bar\x00"),
+			"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			require := require.New(t)
+
+			var idx IndexServiceTag
+			val, err := idx.FromArgs(tc.Args...)
+			if tc.ExpectErr != "" {
+				require.Error(err)
+				require.Contains(err.Error(), tc.ExpectErr)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tc.ExpectVal, val)
+		})
+	}
+}
@@ -248,6 +248,55 @@ func (s *Store) kvsListTxn(tx *memdb.Txn, ws memdb.WatchSet, prefix string) (uin
 	return idx, ents, nil
 }
 
+// KVSListDiff is used to list the changes to the KVS entries matching a
+// prefix since the given index. It returns the entries that were created or
+// modified after that index, along with the keys of the entries that were
+// deleted. If the changes can't be computed, because no index was given or
+// the tombstones needed to find deletions have been reaped, then resync is
+// true and the full list of entries is returned instead.
+func (s *Store) KVSListDiff(ws memdb.WatchSet, prefix string, since uint64) (uint64, structs.DirEntries, []string, bool, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx, ents, err := s.kvsListTxn(tx, ws, prefix)
+	if err != nil {
+		return 0, nil, nil, false, err
+	}
+
+	reaped, err := s.kvsGraveyard.GetReapedIndexTxn(tx)
+	if err != nil {
+		return 0, nil, nil, false, err
+	}
+	if since == 0 || since < reaped {
+		return idx, ents, nil, true, nil
+	}
+
+	var changed structs.DirEntries
+	live := make(map[string]struct{}, len(ents))
+	for _, e := range ents {
+		live[e.Key] = struct{}{}
+		if e.ModifyIndex > since {
+			changed = append(changed, e)
+		}
+	}
+
+	// Deleted keys only show up in the graveyard. A key may have been
+	// deleted and then recreated, in which case it's reported as changed.
+	stones, err := tx.Get("tombstones", "id_prefix", prefix)
+	if err != nil {
+		return 0, nil, nil, false, fmt.Errorf("failed querying tombstones: %s", err)
+	}
+	var removed []string
+	for stone := stones.Next(); stone != nil; stone = stones.Next() {
+		t := stone.(*Tombstone)
+		if _, ok := live[t.Key]; ok || t.Index <= since {
+			continue
+		}
+		removed = append(removed, t.Key)
+	}
+	return idx, changed, removed, false, nil
+}
+
 // KVSListKeys is used to query the KV store for keys matching the given prefix.
 // An optional separator may be specified, which can be used to slice off a part
 // of the response so that only a subset of the prefix is returned. In this
@@ -499,6 +548,30 @@ func (s *Store) KVSLockDelay(key string) time.Time {
 	return s.lockDelay.GetExpiration(key)
 }
 
+// KVSLocked returns the keys held by sessions, mapped to the session holding
+// each of them.
+func (s *Store) KVSLocked() (map[string]string, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	sessions, err := tx.Get("sessions", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed session lookup: %s", err)
+	}
+	locked := make(map[string]string)
+	for sess := sessions.Next(); sess != nil; sess = sessions.Next() {
+		id := sess.(*structs.Session).ID
+		entries, err := tx.Get("kvs", "session", id)
+		if err != nil {
+			return nil, fmt.Errorf("failed kvs lookup: %s", err)
+		}
+		for entry := entries.Next(); entry != nil; entry = entries.Next() {
+			locked[entry.(*structs.DirEntry).Key] = id
+		}
+	}
+	return locked, nil
+}
+
 // KVSLock is similar to KVSSet but only performs the set if the lock can be
 // acquired.
 func (s *Store) KVSLock(idx uint64, entry *structs.DirEntry) (bool, error) {
@@ -470,6 +470,77 @@ func TestStateStore_KVSList(t *testing.T) {
 	}
 }
 
+func TestStateStore_KVSListDiff(t *testing.T) {
+	s := testStateStore(t)
+
+	testSetKey(t, s, 1, "foo/a", "a")
+	testSetKey(t, s, 2, "foo/b", "b")
+	testSetKey(t, s, 3, "foo/c", "c")
+	testSetKey(t, s, 4, "bar/a", "a")
+
+	// A zero index can't be diffed, so everything is returned.
+	idx, entries, removed, resync, err := s.KVSListDiff(nil, "foo/", 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 3 || len(entries) != 3 || removed != nil || !resync {
+		t.Fatalf("bad: %d %v %v %v", idx, entries, removed, resync)
+	}
+
+	// Update one key, delete another, and delete and recreate a third.
+	testSetKey(t, s, 5, "foo/a", "a2")
+	if err := s.KVSDelete(6, "foo/b"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSDelete(7, "foo/c"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testSetKey(t, s, 8, "foo/c", "c2")
+
+	idx, entries, removed, resync, err = s.KVSListDiff(nil, "foo/", 3)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 8 || resync {
+		t.Fatalf("bad: %d %v", idx, resync)
+	}
+	if len(entries) != 2 || entries[0].Key != "foo/a" || entries[1].Key != "foo/c" {
+		t.Fatalf("bad: %v", entries)
+	}
+	if !reflect.DeepEqual(removed, []string{"foo/b"}) {
+		t.Fatalf("bad: %v", removed)
+	}
+
+	// Changes at or before the given index aren't reported.
+	idx, entries, removed, resync, err = s.KVSListDiff(nil, "foo/", 6)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 8 || len(entries) != 1 || entries[0].Key != "foo/c" || removed != nil || resync {
+		t.Fatalf("bad: %d %v %v %v", idx, entries, removed, resync)
+	}
+
+	// Once tombstones newer than the given index are reaped, deletions
+	// can't be tracked anymore and a resync is needed.
+	if err := s.ReapTombstones(6); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, entries, removed, resync, err = s.KVSListDiff(nil, "foo/", 3)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 2 || removed != nil || !resync {
+		t.Fatalf("bad: %v %v %v", entries, removed, resync)
+	}
+	_, _, _, resync, err = s.KVSListDiff(nil, "foo/", 6)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resync {
+		t.Fatalf("should not resync")
+	}
+}
+
 func TestStateStore_KVSListKeys(t *testing.T) {
 	s := testStateStore(t)
 
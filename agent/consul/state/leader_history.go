@@ -0,0 +1,132 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	leaderHistoryTableName = "leader-history"
+)
+
+// leaderHistoryTableSchema returns a new table schema used for storing the
+// leader transitions.
+func leaderHistoryTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: leaderHistoryTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.UintFieldIndex{
+					Field: "CreateIndex",
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(leaderHistoryTableSchema)
+}
+
+// LeaderHistory is used to pull all the leader transitions from the
+// snapshot.
+func (s *Snapshot) LeaderHistory() (structs.LeaderTransitions, error) {
+	iter, err := s.tx.Get(leaderHistoryTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret structs.LeaderTransitions
+	for t := iter.Next(); t != nil; t = iter.Next() {
+		ret = append(ret, t.(*structs.LeaderTransition))
+	}
+	return ret, nil
+}
+
+// LeaderTransition is used when restoring from a snapshot.
+func (s *Restore) LeaderTransition(t *structs.LeaderTransition) error {
+	if err := s.tx.Insert(leaderHistoryTableName, t); err != nil {
+		return fmt.Errorf("failed restoring leader transition: %s", err)
+	}
+	if err := indexUpdateMaxTxn(s.tx, t.ModifyIndex, leaderHistoryTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// LeaderHistory returns the recorded leader transitions, newest first.
+func (s *Store) LeaderHistory(ws memdb.WatchSet) (uint64, structs.LeaderTransitions, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, leaderHistoryTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	transitions, err := leaderHistoryTxn(tx, ws)
+	if err != nil {
+		return 0, nil, err
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].CreateIndex > transitions[j].CreateIndex
+	})
+	return idx, transitions, nil
+}
+
+func leaderHistoryTxn(tx *memdb.Txn, ws memdb.WatchSet) (structs.LeaderTransitions, error) {
+	iter, err := tx.Get(leaderHistoryTableName, "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed leader history lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+
+	var transitions structs.LeaderTransitions
+	for t := iter.Next(); t != nil; t = iter.Next() {
+		transitions = append(transitions, t.(*structs.LeaderTransition))
+	}
+	return transitions, nil
+}
+
+// LeaderTransitionAppend records a leader transition, and then trims the
+// oldest ones so there are at most maxEntries.
+func (s *Store) LeaderTransitionAppend(idx uint64, t *structs.LeaderTransition, maxEntries int) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	t.CreateIndex = idx
+	t.ModifyIndex = idx
+	if err := tx.Insert(leaderHistoryTableName, t); err != nil {
+		return fmt.Errorf("failed inserting leader transition: %s", err)
+	}
+
+	if maxEntries > 0 {
+		transitions, err := leaderHistoryTxn(tx, nil)
+		if err != nil {
+			return err
+		}
+		if len(transitions) > maxEntries {
+			sort.Slice(transitions, func(i, j int) bool {
+				return transitions[i].CreateIndex < transitions[j].CreateIndex
+			})
+			for _, old := range transitions[:len(transitions)-maxEntries] {
+				if err := tx.Delete(leaderHistoryTableName, old); err != nil {
+					return fmt.Errorf("failed trimming leader history: %s", err)
+				}
+			}
+		}
+	}
+
+	if err := tx.Insert("index", &IndexEntry{leaderHistoryTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Commit()
+	return nil
+}
@@ -0,0 +1,58 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_LeaderHistory(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	ws := memdb.NewWatchSet()
+	idx, transitions, err := s.LeaderHistory(ws)
+	require.NoError(err)
+	require.Equal(uint64(1), idx)
+	require.Empty(transitions)
+
+	// Record a few transitions, keeping at most two.
+	for i, leader := range []string{"server1", "server2", "server3"} {
+		err := s.LeaderTransitionAppend(uint64(i+2), &structs.LeaderTransition{
+			Term:   uint64(i + 1),
+			Leader: leader,
+		}, 2)
+		require.NoError(err)
+	}
+	require.True(watchFired(ws))
+
+	// The oldest one was trimmed, and the rest are newest first.
+	idx, transitions, err = s.LeaderHistory(nil)
+	require.NoError(err)
+	require.Equal(uint64(4), idx)
+	require.Len(transitions, 2)
+	require.Equal("server3", transitions[0].Leader)
+	require.Equal(uint64(4), transitions[0].CreateIndex)
+	require.Equal("server2", transitions[1].Leader)
+
+	// Snapshot and restore the history.
+	snap := s.Snapshot()
+	defer snap.Close()
+	dump, err := snap.LeaderHistory()
+	require.NoError(err)
+	require.Len(dump, 2)
+
+	s2 := testStateStore(t)
+	restore := s2.Restore()
+	for _, t := range dump {
+		require.NoError(restore.LeaderTransition(t))
+	}
+	restore.Commit()
+
+	idx, restored, err := s2.LeaderHistory(nil)
+	require.NoError(err)
+	require.Equal(uint64(4), idx)
+	require.Equal(transitions, restored)
+}
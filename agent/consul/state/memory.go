@@ -0,0 +1,39 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// memoryTables are the tables whose memory usage is accounted for. These
+// are the ones that clients can grow without bound.
+var memoryTables = []string{"nodes", "services", "checks", "kvs"}
+
+// MemoryUsage returns the estimated memory used by the entries of the
+// accounted tables. The estimate is the encoded size of the entries, so it
+// has to look at every one of them and shouldn't be called often.
+func (s *Store) MemoryUsage() ([]structs.StateTableMemory, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	usage := make([]structs.StateTableMemory, 0, len(memoryTables))
+	for _, table := range memoryTables {
+		iter, err := tx.Get(table, "id")
+		if err != nil {
+			return nil, fmt.Errorf("failed %s lookup: %s", table, err)
+		}
+
+		t := structs.StateTableMemory{Table: table}
+		for entry := iter.Next(); entry != nil; entry = iter.Next() {
+			n, err := structs.EncodedSize(entry)
+			if err != nil {
+				return nil, fmt.Errorf("failed sizing %s entry: %s", table, err)
+			}
+			t.Entries++
+			t.Bytes += int64(n)
+		}
+		usage = append(usage, t)
+	}
+	return usage, nil
+}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore_MemoryUsage(t *testing.T) {
+	s := testStateStore(t)
+
+	// An empty store has all the tables, with nothing in them.
+	usage, err := s.MemoryUsage()
+	require.NoError(t, err)
+	require.Len(t, usage, 4)
+	for _, table := range usage {
+		require.Zero(t, table.Entries, table.Table)
+		require.Zero(t, table.Bytes, table.Table)
+	}
+
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterService(t, s, 2, "node1", "service1")
+	testRegisterCheck(t, s, 3, "node1", "service1", "check1", "passing")
+	testSetKey(t, s, 4, "foo", "bar")
+
+	usage, err = s.MemoryUsage()
+	require.NoError(t, err)
+	for _, table := range usage {
+		require.Equal(t, 1, table.Entries, table.Table)
+		require.True(t, table.Bytes > 0, table.Table)
+	}
+
+	// The estimate is the encoded size of the entries, so bigger values
+	// take up more memory.
+	before := usage[3].Bytes
+	require.Equal(t, "kvs", usage[3].Table)
+	require.NoError(t, s.KVSSet(5, &structs.DirEntry{Key: "foo", Value: make([]byte, 1024)}))
+	_, entry, err := s.KVSGet(nil, "foo")
+	require.NoError(t, err)
+	size, err := structs.EncodedSize(entry)
+	require.NoError(t, err)
+	usage, err = s.MemoryUsage()
+	require.NoError(t, err)
+	require.Equal(t, int64(size), usage[3].Bytes)
+	require.True(t, usage[3].Bytes > before)
+}
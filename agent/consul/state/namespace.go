@@ -0,0 +1,208 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	namespacesTableName = "namespaces"
+)
+
+// namespacesTableSchema returns a new table schema used for storing
+// namespaces.
+func namespacesTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: namespacesTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "Name",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(namespacesTableSchema)
+}
+
+// Namespaces is used to pull all the namespaces from the snapshot.
+func (s *Snapshot) Namespaces() (structs.Namespaces, error) {
+	iter, err := s.tx.Get(namespacesTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret structs.Namespaces
+	for ns := iter.Next(); ns != nil; ns = iter.Next() {
+		ret = append(ret, ns.(*structs.Namespace))
+	}
+	return ret, nil
+}
+
+// Namespace is used when restoring from a snapshot.
+func (s *Restore) Namespace(ns *structs.Namespace) error {
+	if err := s.tx.Insert(namespacesTableName, ns); err != nil {
+		return fmt.Errorf("failed restoring namespace: %s", err)
+	}
+	if err := indexUpdateMaxTxn(s.tx, ns.ModifyIndex, namespacesTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// defaultNamespace returns the entry for the default namespace, which exists
+// even if it was never written.
+func defaultNamespace() *structs.Namespace {
+	return &structs.Namespace{Name: structs.NamespaceDefault}
+}
+
+// Namespaces returns all the namespaces, sorted by name. The default namespace
+// is always included.
+func (s *Store) Namespaces(ws memdb.WatchSet) (uint64, structs.Namespaces, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, namespacesTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	iter, err := tx.Get(namespacesTableName, "id")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed namespace lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+
+	results := structs.Namespaces{}
+	hasDefault := false
+	for ns := iter.Next(); ns != nil; ns = iter.Next() {
+		n := ns.(*structs.Namespace)
+		if strings.EqualFold(n.Name, structs.NamespaceDefault) {
+			hasDefault = true
+		}
+		results = append(results, n)
+	}
+	if !hasDefault {
+		results = append(results, defaultNamespace())
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return strings.ToLower(results[i].Name) < strings.ToLower(results[j].Name)
+	})
+	return idx, results, nil
+}
+
+// NamespaceGet returns the namespace with the given name, or nil if it doesn't
+// exist.
+func (s *Store) NamespaceGet(ws memdb.WatchSet, name string) (uint64, *structs.Namespace, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, namespacesTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	watchCh, ns, err := tx.FirstWatch(namespacesTableName, "id", name)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed namespace lookup: %s", err)
+	}
+	ws.Add(watchCh)
+
+	if ns == nil {
+		if strings.EqualFold(name, structs.NamespaceDefault) {
+			return idx, defaultNamespace(), nil
+		}
+		return idx, nil, nil
+	}
+	return idx, ns.(*structs.Namespace), nil
+}
+
+// NamespaceSet creates or updates a namespace.
+func (s *Store) NamespaceSet(idx uint64, ns *structs.Namespace) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.namespaceSetTxn(tx, idx, ns); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// namespaceSetTxn is the inner method used to insert a namespace with the
+// proper indexes into the state store.
+func (s *Store) namespaceSetTxn(tx *memdb.Txn, idx uint64, ns *structs.Namespace) error {
+	if ns.Name == "" {
+		return fmt.Errorf("Missing namespace name")
+	}
+
+	existing, err := tx.First(namespacesTableName, "id", ns.Name)
+	if err != nil {
+		return fmt.Errorf("failed namespace lookup: %s", err)
+	}
+	if existing != nil {
+		ns.CreateIndex = existing.(*structs.Namespace).CreateIndex
+	} else {
+		ns.CreateIndex = idx
+	}
+	ns.ModifyIndex = idx
+
+	if err := tx.Insert(namespacesTableName, ns); err != nil {
+		return fmt.Errorf("failed inserting namespace: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{namespacesTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// NamespaceDelete deletes the namespace with the given name. The default
+// namespace can't be deleted.
+func (s *Store) NamespaceDelete(idx uint64, name string) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.namespaceDeleteTxn(tx, idx, name); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// namespaceDeleteTxn is the inner method used to delete a namespace with the
+// proper indexes into the state store.
+func (s *Store) namespaceDeleteTxn(tx *memdb.Txn, idx uint64, name string) error {
+	if strings.EqualFold(name, structs.NamespaceDefault) {
+		return fmt.Errorf("Cannot delete the default namespace")
+	}
+
+	ns, err := tx.First(namespacesTableName, "id", name)
+	if err != nil {
+		return fmt.Errorf("failed namespace lookup: %s", err)
+	}
+	if ns == nil {
+		return nil
+	}
+
+	if err := tx.Delete(namespacesTableName, ns); err != nil {
+		return fmt.Errorf("failed deleting namespace: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{namespacesTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
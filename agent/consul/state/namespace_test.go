@@ -0,0 +1,113 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Namespace_DefaultAlwaysExists(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	_, ns, err := s.NamespaceGet(nil, structs.NamespaceDefault)
+	require.NoError(err)
+	require.Equal(structs.NamespaceDefault, ns.Name)
+
+	_, namespaces, err := s.Namespaces(nil)
+	require.NoError(err)
+	require.Len(namespaces, 1)
+	require.Equal(structs.NamespaceDefault, namespaces[0].Name)
+
+	// It can be given a description, but never deleted.
+	require.NoError(s.NamespaceSet(1, &structs.Namespace{Name: structs.NamespaceDefault, Description: "Default"}))
+	_, namespaces, err = s.Namespaces(nil)
+	require.NoError(err)
+	require.Len(namespaces, 1)
+	require.Equal("Default", namespaces[0].Description)
+	require.Error(s.NamespaceDelete(2, structs.NamespaceDefault))
+}
+
+func TestStore_Namespace_CRUD(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	ws := memdb.NewWatchSet()
+	idx, ns, err := s.NamespaceGet(ws, "eng")
+	require.NoError(err)
+	require.Equal(uint64(1), idx)
+	require.Nil(ns)
+
+	// Create a namespace.
+	require.NoError(s.NamespaceSet(2, &structs.Namespace{Name: "eng", Description: "Engineering"}))
+	require.True(watchFired(ws))
+
+	// Lookups are case-insensitive.
+	ws = memdb.NewWatchSet()
+	idx, ns, err = s.NamespaceGet(ws, "ENG")
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Equal(&structs.Namespace{
+		Name:        "eng",
+		Description: "Engineering",
+		RaftIndex:   structs.RaftIndex{CreateIndex: 2, ModifyIndex: 2},
+	}, ns)
+
+	// Update it, which keeps the create index.
+	require.NoError(s.NamespaceSet(3, &structs.Namespace{Name: "eng", Description: "Eng"}))
+	require.True(watchFired(ws))
+	_, ns, err = s.NamespaceGet(nil, "eng")
+	require.NoError(err)
+	require.Equal("Eng", ns.Description)
+	require.Equal(structs.RaftIndex{CreateIndex: 2, ModifyIndex: 3}, ns.RaftIndex)
+
+	// Listing is sorted by name.
+	require.NoError(s.NamespaceSet(4, &structs.Namespace{Name: "apps"}))
+	idx, namespaces, err := s.Namespaces(nil)
+	require.NoError(err)
+	require.Equal(uint64(4), idx)
+	require.Len(namespaces, 3)
+	require.Equal("apps", namespaces[0].Name)
+	require.Equal(structs.NamespaceDefault, namespaces[1].Name)
+	require.Equal("eng", namespaces[2].Name)
+
+	// Delete it. Deleting it again is a no-op.
+	require.NoError(s.NamespaceDelete(5, "eng"))
+	require.NoError(s.NamespaceDelete(6, "eng"))
+	idx, ns, err = s.NamespaceGet(nil, "eng")
+	require.NoError(err)
+	require.Equal(uint64(5), idx)
+	require.Nil(ns)
+}
+
+func TestStore_Namespace_Snapshot_Restore(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	require.NoError(s.NamespaceSet(1, &structs.Namespace{Name: "eng"}))
+	require.NoError(s.NamespaceSet(2, &structs.Namespace{Name: "apps"}))
+
+	snap := s.Snapshot()
+	defer snap.Close()
+
+	// Alter the real state store.
+	require.NoError(s.NamespaceDelete(3, "eng"))
+
+	dump, err := snap.Namespaces()
+	require.NoError(err)
+	require.Len(dump, 2)
+
+	s2 := testStateStore(t)
+	restore := s2.Restore()
+	for _, ns := range dump {
+		require.NoError(restore.Namespace(ns))
+	}
+	restore.Commit()
+
+	idx, namespaces, err := s2.Namespaces(nil)
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Len(namespaces, 3)
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	responseSigningKeyTableName = "response-signing-key"
+)
+
+// responseSigningKeyTableSchema returns a new table schema used for storing
+// the response signing key. There's only ever one key.
+func responseSigningKeyTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: responseSigningKeyTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: true,
+				Unique:       true,
+				Indexer: &memdb.ConditionalIndex{
+					Conditional: func(obj interface{}) (bool, error) { return true, nil },
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(responseSigningKeyTableSchema)
+}
+
+// ResponseSigningKey is used to pull the response signing key from the
+// snapshot.
+func (s *Snapshot) ResponseSigningKey() (*structs.ResponseSigningKey, error) {
+	k, err := s.tx.First(responseSigningKeyTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := k.(*structs.ResponseSigningKey)
+	if !ok {
+		return nil, nil
+	}
+	return key, nil
+}
+
+// ResponseSigningKey is used when restoring from a snapshot.
+func (s *Restore) ResponseSigningKey(key *structs.ResponseSigningKey) error {
+	if err := s.tx.Insert(responseSigningKeyTableName, key); err != nil {
+		return fmt.Errorf("failed restoring response signing key: %s", err)
+	}
+	if err := indexUpdateMaxTxn(s.tx, key.ModifyIndex, responseSigningKeyTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// ResponseSigningKey returns the response signing key, or nil if there isn't
+// one yet.
+func (s *Store) ResponseSigningKey(ws memdb.WatchSet) (uint64, *structs.ResponseSigningKey, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, responseSigningKeyTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	watchCh, k, err := tx.FirstWatch(responseSigningKeyTableName, "id")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed response signing key lookup: %s", err)
+	}
+	ws.Add(watchCh)
+
+	if k == nil {
+		return idx, nil, nil
+	}
+	return idx, k.(*structs.ResponseSigningKey), nil
+}
+
+// ResponseSigningKeySet sets the response signing key if there isn't one
+// already, and returns whether it did. Keys are never replaced, so replies
+// signed by any server in the datacenter verify with the same public key.
+func (s *Store) ResponseSigningKeySet(idx uint64, key *structs.ResponseSigningKey) (bool, error) {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	existing, err := tx.First(responseSigningKeyTableName, "id")
+	if err != nil {
+		return false, fmt.Errorf("failed response signing key lookup: %s", err)
+	}
+	if existing != nil {
+		return false, nil
+	}
+
+	if key.ID == "" {
+		return false, fmt.Errorf("Missing response signing key ID")
+	}
+	key.CreateIndex = idx
+	key.ModifyIndex = idx
+	if err := tx.Insert(responseSigningKeyTableName, key); err != nil {
+		return false, fmt.Errorf("failed inserting response signing key: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{responseSigningKeyTableName, idx}); err != nil {
+		return false, fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Commit()
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ResponseSigningKey(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	ws := memdb.NewWatchSet()
+	idx, key, err := s.ResponseSigningKey(ws)
+	require.NoError(err)
+	require.Equal(uint64(1), idx)
+	require.Nil(key)
+
+	// A key needs an ID.
+	_, err = s.ResponseSigningKeySet(2, &structs.ResponseSigningKey{})
+	require.Error(err)
+
+	// Set the key.
+	ok, err := s.ResponseSigningKeySet(2, &structs.ResponseSigningKey{
+		ID:         "key1",
+		PublicKey:  []byte("public"),
+		PrivateKey: []byte("private"),
+	})
+	require.NoError(err)
+	require.True(ok)
+	require.True(watchFired(ws))
+
+	idx, key, err = s.ResponseSigningKey(nil)
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Equal(&structs.ResponseSigningKey{
+		ID:         "key1",
+		PublicKey:  []byte("public"),
+		PrivateKey: []byte("private"),
+		RaftIndex:  structs.RaftIndex{CreateIndex: 2, ModifyIndex: 2},
+	}, key)
+
+	// The key is never replaced.
+	ok, err = s.ResponseSigningKeySet(3, &structs.ResponseSigningKey{ID: "key2"})
+	require.NoError(err)
+	require.False(ok)
+	idx, key, err = s.ResponseSigningKey(nil)
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Equal("key1", key.ID)
+
+	// Snapshot and restore it.
+	snap := s.Snapshot()
+	defer snap.Close()
+	dump, err := snap.ResponseSigningKey()
+	require.NoError(err)
+	require.Equal(key, dump)
+
+	s2 := testStateStore(t)
+	restore := s2.Restore()
+	require.NoError(restore.ResponseSigningKey(dump))
+	restore.Commit()
+	idx, key, err = s2.ResponseSigningKey(nil)
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Equal(dump, key)
+}
@@ -0,0 +1,181 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+const (
+	serviceFailoversTableName = "service-failovers"
+)
+
+// serviceFailoversTableSchema returns a new table schema used for storing
+// service failover policies.
+func serviceFailoversTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: serviceFailoversTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "Service",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(serviceFailoversTableSchema)
+}
+
+// ServiceFailovers is used to pull all the service failover policies from the
+// snapshot.
+func (s *Snapshot) ServiceFailovers() (structs.ServiceFailovers, error) {
+	iter, err := s.tx.Get(serviceFailoversTableName, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret structs.ServiceFailovers
+	for f := iter.Next(); f != nil; f = iter.Next() {
+		ret = append(ret, f.(*structs.ServiceFailover))
+	}
+	return ret, nil
+}
+
+// ServiceFailover is used when restoring from a snapshot.
+func (s *Restore) ServiceFailover(f *structs.ServiceFailover) error {
+	if err := s.tx.Insert(serviceFailoversTableName, f); err != nil {
+		return fmt.Errorf("failed restoring service failover: %s", err)
+	}
+	if err := indexUpdateMaxTxn(s.tx, f.ModifyIndex, serviceFailoversTableName); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// ServiceFailovers returns all the service failover policies, sorted by
+// service name.
+func (s *Store) ServiceFailovers(ws memdb.WatchSet) (uint64, structs.ServiceFailovers, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, serviceFailoversTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	iter, err := tx.Get(serviceFailoversTableName, "id")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed service failover lookup: %s", err)
+	}
+	ws.Add(iter.WatchCh())
+
+	results := structs.ServiceFailovers{}
+	for f := iter.Next(); f != nil; f = iter.Next() {
+		results = append(results, f.(*structs.ServiceFailover))
+	}
+	return idx, results, nil
+}
+
+// ServiceFailoverGet returns the failover policy for the given service, or nil
+// if it doesn't have one.
+func (s *Store) ServiceFailoverGet(ws memdb.WatchSet, service string) (uint64, *structs.ServiceFailover, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, serviceFailoversTableName)
+	if idx < 1 {
+		idx = 1
+	}
+
+	watchCh, f, err := tx.FirstWatch(serviceFailoversTableName, "id", service)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed service failover lookup: %s", err)
+	}
+	ws.Add(watchCh)
+
+	if f == nil {
+		return idx, nil, nil
+	}
+	return idx, f.(*structs.ServiceFailover), nil
+}
+
+// ServiceFailoverSet creates or updates a service failover policy.
+func (s *Store) ServiceFailoverSet(idx uint64, f *structs.ServiceFailover) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.serviceFailoverSetTxn(tx, idx, f); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// serviceFailoverSetTxn is the inner method used to insert a service failover
+// policy with the proper indexes into the state store.
+func (s *Store) serviceFailoverSetTxn(tx *memdb.Txn, idx uint64, f *structs.ServiceFailover) error {
+	if f.Service == "" {
+		return fmt.Errorf("Missing service name")
+	}
+
+	existing, err := tx.First(serviceFailoversTableName, "id", f.Service)
+	if err != nil {
+		return fmt.Errorf("failed service failover lookup: %s", err)
+	}
+	if existing != nil {
+		f.CreateIndex = existing.(*structs.ServiceFailover).CreateIndex
+	} else {
+		f.CreateIndex = idx
+	}
+	f.ModifyIndex = idx
+
+	if err := tx.Insert(serviceFailoversTableName, f); err != nil {
+		return fmt.Errorf("failed inserting service failover: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{serviceFailoversTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// ServiceFailoverDelete deletes the failover policy for the given service.
+func (s *Store) ServiceFailoverDelete(idx uint64, service string) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.serviceFailoverDeleteTxn(tx, idx, service); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// serviceFailoverDeleteTxn is the inner method used to delete a service
+// failover policy with the proper indexes into the state store.
+func (s *Store) serviceFailoverDeleteTxn(tx *memdb.Txn, idx uint64, service string) error {
+	f, err := tx.First(serviceFailoversTableName, "id", service)
+	if err != nil {
+		return fmt.Errorf("failed service failover lookup: %s", err)
+	}
+	if f == nil {
+		return nil
+	}
+
+	if err := tx.Delete(serviceFailoversTableName, f); err != nil {
+		return fmt.Errorf("failed deleting service failover: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{serviceFailoversTableName, idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
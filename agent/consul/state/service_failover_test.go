@@ -0,0 +1,109 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/go-memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ServiceFailover_CRUD(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	ws := memdb.NewWatchSet()
+	idx, f, err := s.ServiceFailoverGet(ws, "web")
+	require.NoError(err)
+	require.Equal(uint64(1), idx)
+	require.Nil(f)
+
+	// Create a policy.
+	require.NoError(s.ServiceFailoverSet(2, &structs.ServiceFailover{
+		Service:  "web",
+		Failover: structs.QueryDatacenterOptions{Datacenters: []string{"dc2", "dc3"}},
+	}))
+	require.True(watchFired(ws))
+
+	// Lookups are case-insensitive.
+	ws = memdb.NewWatchSet()
+	idx, f, err = s.ServiceFailoverGet(ws, "WEB")
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Equal(&structs.ServiceFailover{
+		Service:   "web",
+		Failover:  structs.QueryDatacenterOptions{Datacenters: []string{"dc2", "dc3"}},
+		RaftIndex: structs.RaftIndex{CreateIndex: 2, ModifyIndex: 2},
+	}, f)
+
+	// Update it, which keeps the create index.
+	require.NoError(s.ServiceFailoverSet(3, &structs.ServiceFailover{
+		Service:  "web",
+		Failover: structs.QueryDatacenterOptions{NearestN: 2},
+	}))
+	require.True(watchFired(ws))
+	_, f, err = s.ServiceFailoverGet(nil, "web")
+	require.NoError(err)
+	require.Equal(2, f.Failover.NearestN)
+	require.Empty(f.Failover.Datacenters)
+	require.Equal(structs.RaftIndex{CreateIndex: 2, ModifyIndex: 3}, f.RaftIndex)
+
+	// Listing is sorted by service.
+	require.NoError(s.ServiceFailoverSet(4, &structs.ServiceFailover{
+		Service:  "api",
+		Failover: structs.QueryDatacenterOptions{NearestN: 1},
+	}))
+	idx, failovers, err := s.ServiceFailovers(nil)
+	require.NoError(err)
+	require.Equal(uint64(4), idx)
+	require.Len(failovers, 2)
+	require.Equal("api", failovers[0].Service)
+	require.Equal("web", failovers[1].Service)
+
+	// Delete it. Deleting it again is a no-op.
+	require.NoError(s.ServiceFailoverDelete(5, "web"))
+	require.NoError(s.ServiceFailoverDelete(6, "web"))
+	idx, f, err = s.ServiceFailoverGet(nil, "web")
+	require.NoError(err)
+	require.Equal(uint64(5), idx)
+	require.Nil(f)
+
+	// A service name is required.
+	require.Error(s.ServiceFailoverSet(7, &structs.ServiceFailover{}))
+}
+
+func TestStore_ServiceFailover_Snapshot_Restore(t *testing.T) {
+	require := require.New(t)
+	s := testStateStore(t)
+
+	require.NoError(s.ServiceFailoverSet(1, &structs.ServiceFailover{
+		Service:  "web",
+		Failover: structs.QueryDatacenterOptions{NearestN: 1},
+	}))
+	require.NoError(s.ServiceFailoverSet(2, &structs.ServiceFailover{
+		Service:  "api",
+		Failover: structs.QueryDatacenterOptions{Datacenters: []string{"dc2"}},
+	}))
+
+	snap := s.Snapshot()
+	defer snap.Close()
+
+	// Alter the real state store.
+	require.NoError(s.ServiceFailoverDelete(3, "web"))
+
+	dump, err := snap.ServiceFailovers()
+	require.NoError(err)
+	require.Len(dump, 2)
+
+	s2 := testStateStore(t)
+	restore := s2.Restore()
+	for _, f := range dump {
+		require.NoError(restore.ServiceFailover(f))
+	}
+	restore.Commit()
+
+	idx, failovers, err := s2.ServiceFailovers(nil)
+	require.NoError(err)
+	require.Equal(uint64(2), idx)
+	require.Len(failovers, 2)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-memdb"
@@ -388,6 +389,9 @@ func (s *Store) deleteSessionTxn(tx *memdb.Txn, idx uint64, sessionID string) er
 	default:
 		return fmt.Errorf("unknown session behavior %#v", session.Behavior)
 	}
+	if delay > 0 && len(kvs) > 0 {
+		metrics.IncrCounter([]string{"session", "lock_delay", "triggered"}, float32(len(kvs)))
+	}
 
 	// Delete any check mappings.
 	mappings, err := tx.Get("session_checks", "session", sessionID)
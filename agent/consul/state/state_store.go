@@ -227,6 +227,26 @@ func maxIndexTxn(tx *memdb.Txn, tables ...string) uint64 {
 	return lindex
 }
 
+// MaxIndexWatch returns the highest known index amongst a set of tables, and
+// adds watches that fire when any of their indexes change to the watch set.
+func (s *Store) MaxIndexWatch(ws memdb.WatchSet, tables ...string) uint64 {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	var lindex uint64
+	for _, table := range tables {
+		watchCh, ti, err := tx.FirstWatch("index", "id", table)
+		if err != nil {
+			panic(fmt.Sprintf("unknown index: %s err: %s", table, err))
+		}
+		ws.Add(watchCh)
+		if idx, ok := ti.(*IndexEntry); ok && idx.Value > lindex {
+			lindex = idx.Value
+		}
+	}
+	return lindex
+}
+
 // indexUpdateMaxTxn is used when restoring entries and sets the table's index to
 // the given idx only if it's greater than the current index.
 func indexUpdateMaxTxn(tx *memdb.Txn, idx uint64, table string) error {
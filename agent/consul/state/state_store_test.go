@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/go-memdb"
 )
@@ -210,6 +211,27 @@ func TestStateStore_maxIndex(t *testing.T) {
 	}
 }
 
+func TestStateStore_MaxIndexWatch(t *testing.T) {
+	s := testStateStore(t)
+
+	testRegisterNode(t, s, 0, "foo")
+	testRegisterService(t, s, 1, "foo", "consul")
+
+	ws := memdb.NewWatchSet()
+	if max := s.MaxIndexWatch(ws, "nodes", "services", "checks"); max != 1 {
+		t.Fatalf("bad max: %d", max)
+	}
+
+	// Changing any of the tables fires the watch.
+	testRegisterCheck(t, s, 2, "foo", "", "check1", api.HealthPassing)
+	if !watchFired(ws) {
+		t.Fatalf("bad")
+	}
+	if max := s.MaxIndexWatch(memdb.NewWatchSet(), "nodes", "services", "checks"); max != 2 {
+		t.Fatalf("bad max: %d", max)
+	}
+}
+
 func TestStateStore_indexUpdateMaxTxn(t *testing.T) {
 	s := testStateStore(t)
 
@@ -0,0 +1,64 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+const tableCountTableName = "table-counts"
+
+// tableCount holds the number of entries in a table, so limits can be checked
+// without scanning the table. It isn't saved in snapshots since restoring the
+// entries rebuilds it.
+type tableCount struct {
+	Table string
+	Count int
+}
+
+// tableCountTableSchema returns a new table schema used for tracking the
+// number of entries in other tables.
+func tableCountTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: tableCountTableName,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "Table",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
+func init() {
+	registerSchema(tableCountTableSchema)
+}
+
+// tableCountTxn returns the number of entries in the given table.
+func tableCountTxn(tx *memdb.Txn, table string) (int, error) {
+	count, err := tx.First(tableCountTableName, "id", table)
+	if err != nil {
+		return 0, fmt.Errorf("failed table count lookup: %s", err)
+	}
+	if count == nil {
+		return 0, nil
+	}
+	return count.(*tableCount).Count, nil
+}
+
+// updateTableCountTxn adds delta to the number of entries in the given table.
+func updateTableCountTxn(tx *memdb.Txn, table string, delta int) error {
+	count, err := tableCountTxn(tx, table)
+	if err != nil {
+		return err
+	}
+	if err := tx.Insert(tableCountTableName, &tableCount{Table: table, Count: count + delta}); err != nil {
+		return fmt.Errorf("failed updating table count: %s", err)
+	}
+	return nil
+}
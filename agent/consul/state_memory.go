@@ -0,0 +1,145 @@
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+)
+
+// startStateMemoryAccounting starts a goroutine that periodically estimates
+// the memory used by the state store, which writes are then admitted
+// against.
+func (s *Server) startStateMemoryAccounting() {
+	s.stateMemoryLock.Lock()
+	defer s.stateMemoryLock.Unlock()
+
+	// Walking the tables isn't free, so there's no point doing it when
+	// there's no budget to admit writes against.
+	if s.stateMemoryEnabled || s.config.StateMemoryInterval <= 0 || s.config.StateMemoryBudget <= 0 {
+		return
+	}
+
+	s.stateMemoryCh = make(chan struct{})
+
+	go func(stopCh chan struct{}) {
+		ticker := time.NewTicker(s.config.StateMemoryInterval)
+		defer ticker.Stop()
+
+		// Take the first measurement right away so the budget applies as
+		// soon as we're the leader.
+		for {
+			if err := s.measureStateMemory(); err != nil {
+				s.logger.Printf("[ERR] consul: error measuring state store memory: %v", err)
+			}
+
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(s.stateMemoryCh)
+
+	s.stateMemoryEnabled = true
+}
+
+// stopStateMemoryAccounting stops the state store memory accounting and
+// clears its last report, since it's only meaningful on the leader.
+func (s *Server) stopStateMemoryAccounting() {
+	s.stateMemoryLock.Lock()
+	defer s.stateMemoryLock.Unlock()
+
+	if !s.stateMemoryEnabled {
+		return
+	}
+
+	close(s.stateMemoryCh)
+	s.stateMemoryEnabled = false
+	s.stateMemoryReport = structs.StateMemoryReport{}
+}
+
+// getStateMemoryReport returns the state store memory usage found by the
+// last accounting run.
+func (s *Server) getStateMemoryReport() structs.StateMemoryReport {
+	s.stateMemoryLock.RLock()
+	defer s.stateMemoryLock.RUnlock()
+
+	report := s.stateMemoryReport
+	report.Budget = s.config.StateMemoryBudget
+	return report
+}
+
+// measureStateMemory estimates the memory used by each of the accounted
+// state store tables, and records it for the admission checks.
+func (s *Server) measureStateMemory() error {
+	defer metrics.MeasureSince([]string{"leader", "state_memory"}, time.Now())
+
+	tables, err := s.fsm.State().MemoryUsage()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, table := range tables {
+		labels := []metrics.Label{{Name: "table", Value: table.Table}}
+		metrics.SetGaugeWithLabels([]string{"state", "memory", "bytes"}, float32(table.Bytes), labels)
+		metrics.SetGaugeWithLabels([]string{"state", "memory", "entries"}, float32(table.Entries), labels)
+		total += table.Bytes
+	}
+	metrics.SetGauge([]string{"state", "memory", "total_bytes"}, float32(total))
+
+	s.stateMemoryLock.Lock()
+	defer s.stateMemoryLock.Unlock()
+	s.stateMemoryReport.LastRun = time.Now()
+	s.stateMemoryReport.Tables = tables
+	s.stateMemoryReport.TotalBytes = total
+	return nil
+}
+
+// admitStateWrite makes sure that applying the given write won't take the
+// state store over the configured memory budget. The size of the write is
+// added to the last estimate of the store's usage, so writes are rejected
+// rather than anything being evicted to make room. Writes are admitted
+// until the first estimate is in.
+func (s *Server) admitStateWrite(args interface{}) error {
+	budget := s.config.StateMemoryBudget
+	if budget <= 0 {
+		return nil
+	}
+
+	s.stateMemoryLock.RLock()
+	measured := !s.stateMemoryReport.LastRun.IsZero()
+	used := s.stateMemoryReport.TotalBytes
+	s.stateMemoryLock.RUnlock()
+	if !measured {
+		return nil
+	}
+
+	size, err := structs.EncodedSize(args)
+	if err != nil {
+		return err
+	}
+	if used+int64(size) <= budget {
+		return nil
+	}
+
+	metrics.IncrCounter([]string{"state", "memory", "rejected"}, 1)
+	s.stateMemoryLock.Lock()
+	s.stateMemoryReport.Rejected++
+	s.stateMemoryLock.Unlock()
+	return fmt.Errorf("%v: the state store uses about %d bytes, and a %d byte write would go over the budget of %d bytes",
+		structs.ErrStateMemoryBudgetExceeded, used, size, budget)
+}
+
+// isKVDelete returns true if the given KV operation removes keys.
+func isKVDelete(op api.KVOp) bool {
+	switch op {
+	case api.KVDelete, api.KVDeleteCAS, api.KVDeleteTree:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,94 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateMemory_Budget(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.StateMemoryInterval = 50 * time.Millisecond
+		c.StateMemoryBudget = 64 * 1024
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	set := func(op api.KVOp, key string, size int) error {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         op,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Value: make([]byte, size),
+			},
+		}
+		var out bool
+		return msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out)
+	}
+
+	register := func(node, service string, size int) error {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				Service: service,
+				Tags:    []string{string(make([]byte, size))},
+			},
+		}
+		var out struct{}
+		return msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	}
+	require.NoError(t, register("node1", "web", 0))
+
+	// Fill up most of the budget, and wait for it to be accounted for.
+	require.NoError(t, set(api.KVSet, "foo", 48*1024))
+	retry.Run(t, func(r *retry.R) {
+		var out structs.StateMemoryReport
+		args := structs.DCSpecificRequest{Datacenter: "dc1"}
+		if err := msgpackrpc.CallWithCodec(codec, "Operator.StateMemory", &args, &out); err != nil {
+			r.Fatal(err)
+		}
+		if out.TotalBytes < 48*1024 {
+			r.Fatalf("bad: %v", out)
+		}
+		if out.Budget != 64*1024 {
+			r.Fatalf("bad: %v", out)
+		}
+	})
+
+	// Small writes still fit, but one that would go over is rejected.
+	require.NoError(t, set(api.KVSet, "bar", 1024))
+	err := set(api.KVSet, "baz", 32*1024)
+	require.True(t, structs.IsErrStateMemoryBudgetExceeded(err), "err: %v", err)
+	require.Equal(t, uint64(1), s1.getStateMemoryReport().Rejected)
+
+	// Catalog registrations that only update what's there are let
+	// through, but new entries aren't.
+	require.NoError(t, register("node1", "web", 16*1024))
+	err = register("node1", "api", 16*1024)
+	require.True(t, structs.IsErrStateMemoryBudgetExceeded(err), "err: %v", err)
+	err = register("node2", "web", 16*1024)
+	require.True(t, structs.IsErrStateMemoryBudgetExceeded(err), "err: %v", err)
+
+	// Deletes are always allowed, and free up the space.
+	require.NoError(t, set(api.KVDelete, "foo", 0))
+	retry.Run(t, func(r *retry.R) {
+		if err := set(api.KVSet, "baz", 32*1024); err != nil {
+			r.Fatal(err)
+		}
+	})
+}
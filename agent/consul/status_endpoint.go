@@ -1,12 +1,21 @@
 package consul
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"time"
 
-	"github.com/hashicorp/consul/agent/consul/autopilot"
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/consul/agent/structs"
 )
 
+// clusterRaftStatsTimeout bounds how long ClusterRaftStats waits for the other
+// servers to reply, so one slow server doesn't hold up the whole response.
+const clusterRaftStatsTimeout = 5 * time.Second
+
 // Status endpoint is used to check on server status
 type Status struct {
 	server *Server
@@ -17,6 +26,14 @@ func (s *Status) Ping(args struct{}, reply *struct{}) error {
 	return nil
 }
 
+// Features returns the features this server supports. Agents and other
+// servers ask for them when they set up a connection, and check each RPC
+// they send over it against them.
+func (s *Status) Features(args struct{}, reply *[]string) error {
+	*reply = metadata.SupportedFeatures()
+	return nil
+}
+
 // Leader is used to get the address of the leader
 func (s *Status) Leader(args struct{}, reply *string) error {
 	leader := string(s.server.raft.Leader())
@@ -41,20 +58,103 @@ func (s *Status) Peers(args struct{}, reply *[]string) error {
 	return nil
 }
 
-// Used by Autopilot to query the raft stats of the local server.
-func (s *Status) RaftStats(args struct{}, reply *autopilot.ServerStats) error {
+// RaftStats returns the Raft stats of the local server. It's used by
+// Autopilot, which decodes the reply as an autopilot.ServerStats, and by
+// ClusterRaftStats.
+func (s *Status) RaftStats(args struct{}, reply *structs.RaftStats) error {
 	stats := s.server.raft.Stats()
 
-	var err error
+	reply.ID = string(s.server.config.NodeID)
+	reply.Name = s.server.config.NodeName
+	if s.server.config.RPCAdvertise != nil {
+		reply.Address = s.server.config.RPCAdvertise.String()
+	}
+	reply.State = stats["state"]
 	reply.LastContact = stats["last_contact"]
-	reply.LastIndex, err = strconv.ParseUint(stats["last_log_index"], 10, 64)
-	if err != nil {
-		return fmt.Errorf("error parsing server's last_log_index value: %s", err)
+
+	indexes := map[string]*uint64{
+		"term":                &reply.Term,
+		"last_log_index":      &reply.LastIndex,
+		"last_log_term":       &reply.LastTerm,
+		"commit_index":        &reply.CommitIndex,
+		"applied_index":       &reply.AppliedIndex,
+		"last_snapshot_index": &reply.LastSnapshotIndex,
+		"last_snapshot_term":  &reply.LastSnapshotTerm,
+		"fsm_pending":         &reply.FSMPending,
+	}
+	for key, val := range indexes {
+		var err error
+		*val, err = strconv.ParseUint(stats[key], 10, 64)
+		if err != nil {
+			return fmt.Errorf("error parsing server's %s value: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// ClusterRaftStats returns the Raft stats of all the servers in the
+// datacenter, so monitoring can work out the replication lag of each of them
+// from a single request.
+func (s *Status) ClusterRaftStats(args *structs.DCSpecificRequest, reply *structs.RaftStatsResponse) error {
+	if done, err := s.server.forward("Status.ClusterRaftStats", args, args, reply); done {
+		return err
 	}
-	reply.LastTerm, err = strconv.ParseUint(stats["last_log_term"], 10, 64)
+
+	rule, err := s.server.ResolveToken(args.Token)
 	if err != nil {
-		return fmt.Errorf("error parsing server's last_log_term value: %s", err)
+		return err
 	}
+	if rule != nil && !rule.OperatorRead() {
+		return acl.ErrPermissionDenied
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterRaftStatsTimeout)
+	defer cancel()
 
+	servers := s.server.serverLookup.Servers()
+	replyChs := make([]chan *structs.RaftStats, len(servers))
+	for i, server := range servers {
+		replyChs[i] = make(chan *structs.RaftStats, 1)
+		go s.fetchRaftStats(server, replyChs[i])
+	}
+
+	for i, server := range servers {
+		select {
+		case stats := <-replyChs[i]:
+			reply.Servers = append(reply.Servers, stats)
+		case <-ctx.Done():
+			reply.Servers = append(reply.Servers, &structs.RaftStats{
+				ID:      server.ID,
+				Name:    server.Name,
+				Address: server.Addr.String(),
+				Error:   ctx.Err().Error(),
+			})
+		}
+	}
+	sort.Slice(reply.Servers, func(i, j int) bool {
+		return reply.Servers[i].Name < reply.Servers[j].Name
+	})
 	return nil
 }
+
+// fetchRaftStats gets the Raft stats of a single server and sends them on the
+// channel, with the error set if they couldn't be fetched.
+func (s *Status) fetchRaftStats(server *metadata.Server, replyCh chan<- *structs.RaftStats) {
+	var reply structs.RaftStats
+	var err error
+	if server.Name == s.server.config.NodeName {
+		err = s.RaftStats(struct{}{}, &reply)
+	} else {
+		err = s.server.connPool.RPC(s.server.config.Datacenter, server.Addr, server.Version,
+			"Status.RaftStats", server.UseTLS, struct{}{}, &reply)
+	}
+	if err != nil {
+		reply = structs.RaftStats{
+			ID:      server.ID,
+			Name:    server.Name,
+			Address: server.Addr.String(),
+			Error:   err.Error(),
+		}
+	}
+	replyCh <- &reply
+}
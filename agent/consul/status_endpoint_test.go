@@ -7,9 +7,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/agent/consul/autopilot"
+	"github.com/hashicorp/consul/agent/metadata"
 	"github.com/hashicorp/consul/agent/pool"
+	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/stretchr/testify/require"
 )
 
 func rpcClient(t *testing.T, s *Server) rpc.ClientCodec {
@@ -68,3 +74,106 @@ func TestStatusPeers(t *testing.T) {
 		t.Fatalf("no peers: %v", peers)
 	}
 }
+
+func TestStatusFeatures(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	// A pool with a feature check gets the features when it connects, and
+	// checks each RPC against them.
+	var got map[string]struct{}
+	p := &pool.ConnPool{
+		FeatureCheck: func(features map[string]struct{}, method string, args interface{}) error {
+			got = features
+			if method == "Status.Leader" {
+				return structs.ErrFeatureNotSupported
+			}
+			return nil
+		},
+	}
+	defer p.Shutdown()
+
+	var out struct{}
+	require.NoError(p.RPC("dc1", s1.config.RPCAddr, 2, "Status.Ping", false, struct{}{}, &out))
+	require.Len(got, len(metadata.SupportedFeatures()))
+	for _, feature := range metadata.SupportedFeatures() {
+		require.Contains(got, feature)
+	}
+
+	var leader string
+	err := p.RPC("dc1", s1.config.RPCAddr, 2, "Status.Leader", false, struct{}{}, &leader)
+	require.True(structs.IsErrFeatureNotSupported(err), "err: %v", err)
+	require.Empty(leader)
+}
+
+func TestStatusRaftStats(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	dir2, s2 := testServerDCBootstrap(t, "dc1", false)
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	joinLAN(t, s2, s1)
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	// The local stats decode as Autopilot's too.
+	var local structs.RaftStats
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Status.RaftStats", struct{}{}, &local))
+	require.Equal(t, s1.config.NodeName, local.Name)
+	require.Equal(t, "Leader", local.State)
+	require.NotZero(t, local.CommitIndex)
+	require.NotZero(t, local.AppliedIndex)
+	var ap autopilot.ServerStats
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Status.RaftStats", struct{}{}, &ap))
+	require.NotZero(t, ap.LastIndex)
+
+	retry.Run(t, func(r *retry.R) {
+		arg := structs.DCSpecificRequest{Datacenter: "dc1"}
+		var out structs.RaftStatsResponse
+		if err := msgpackrpc.CallWithCodec(codec, "Status.ClusterRaftStats", &arg, &out); err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if len(out.Servers) != 2 {
+			r.Fatalf("bad: %v", out.Servers)
+		}
+		for _, stats := range out.Servers {
+			if stats.Error != "" || stats.LastIndex == 0 {
+				r.Fatalf("bad: %#v", stats)
+			}
+		}
+	})
+}
+
+func TestStatusClusterRaftStats_ACLDeny(t *testing.T) {
+	t.Parallel()
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLsEnabled = true
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var out structs.RaftStatsResponse
+	err := msgpackrpc.CallWithCodec(codec, "Status.ClusterRaftStats", &arg, &out)
+	if !acl.IsErrPermissionDenied(err) {
+		t.Fatalf("err: %v", err)
+	}
+
+	arg.Token = "root"
+	require.NoError(t, msgpackrpc.CallWithCodec(codec, "Status.ClusterRaftStats", &arg, &out))
+	require.Len(t, out.Servers, 1)
+}
@@ -58,6 +58,17 @@ func (t *Txn) Apply(args *structs.TxnRequest, reply *structs.TxnResponse) error
 		return nil
 	}
 
+	// Transactions that only delete keys are always let through so space
+	// can be freed.
+	for _, op := range args.Ops {
+		if op.KV == nil || !isKVDelete(op.KV.Verb) {
+			if err := t.srv.admitStateWrite(args); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
 	// Apply the update.
 	resp, err := t.srv.raftApply(structs.TxnRequestType, args)
 	if err != nil {
@@ -470,6 +470,7 @@ func TestTxn_Read(t *testing.T) {
 		},
 		QueryMeta: structs.QueryMeta{
 			KnownLeader: true,
+			ServedBy:    s1.config.NodeName,
 		},
 	}
 	if !reflect.DeepEqual(out, expected) {
@@ -571,6 +572,7 @@ func TestTxn_Read_ACLDeny(t *testing.T) {
 	expected := structs.TxnReadResponse{
 		QueryMeta: structs.QueryMeta{
 			KnownLeader: true,
+			ServedBy:    s1.config.NodeName,
 		},
 	}
 	for i, op := range arg.Ops {
@@ -0,0 +1,162 @@
+package consul
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/serf/serf"
+)
+
+// MaxWANGatewaysTagSize is the longest the comma separated list of a server's
+// WAN federation gateways can be. It's advertised as the "gw" Serf tag, and
+// Serf limits a node's encoded tags to 512 bytes, so this leaves room for the
+// server's other tags.
+const MaxWANGatewaysTagSize = 256
+
+// wanGatewaysEnabled returns true if this server reaches other datacenters
+// through their WAN federation gateways.
+func (s *Server) wanGatewaysEnabled() bool {
+	return len(s.config.WANFederationLocalGateways) > 0
+}
+
+// datacenterGateways returns the gateways the servers in the given
+// datacenter advertise. Until we've joined the primary datacenter, its
+// gateways come from the configuration.
+func (s *Server) datacenterGateways(dc string) []string {
+	seen := make(map[string]struct{})
+	var gateways []string
+	for _, m := range s.WANMembers() {
+		ok, parts := metadata.IsConsulServer(m)
+		if !ok || parts.Datacenter != dc || m.Status != serf.StatusAlive {
+			continue
+		}
+		for _, gateway := range parts.Gateways {
+			if _, ok := seen[gateway]; !ok {
+				seen[gateway] = struct{}{}
+				gateways = append(gateways, gateway)
+			}
+		}
+	}
+	if len(gateways) == 0 && dc == s.config.PrimaryDatacenter {
+		gateways = s.config.WANFederationPrimaryGateways
+	}
+	return gateways
+}
+
+// dialGateway connects to one of the given gateways, and through it to the
+// named server in the given datacenter, or any server there if the node is
+// empty. The returned connection is already TLS.
+func (s *Server) dialGateway(gateways []string, dc, node string, timeout time.Duration) (net.Conn, error) {
+	if len(gateways) == 0 {
+		return nil, fmt.Errorf("no gateways known for datacenter %q", dc)
+	}
+	gateway := gateways[rand.Intn(len(gateways))]
+
+	d := &net.Dialer{LocalAddr: s.config.RPCSrcAddr, Timeout: timeout}
+	conn, err := d.Dial("tcp", gateway)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gateway %s: %v", gateway, err)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetKeepAlive(true)
+		tcp.SetNoDelay(true)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	tlsConn, err := s.gatewayTLSWrap(dc, node, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect through gateway %s: %v", gateway, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// dialDatacenterGateway is the gateway dialer of the connection pool. It
+// connects to any server in datacenters that advertise gateways, and
+// returns false for the ones that should be dialed directly.
+func (s *Server) dialDatacenterGateway(dc string, timeout time.Duration) (net.Conn, bool, error) {
+	if dc == s.config.Datacenter {
+		return nil, false, nil
+	}
+	gateways := s.datacenterGateways(dc)
+	if len(gateways) == 0 {
+		return nil, false, nil
+	}
+	conn, err := s.dialGateway(gateways, dc, "", timeout)
+	if err != nil {
+		return nil, false, err
+	}
+	return conn, true, nil
+}
+
+// isPrimaryGateway returns true if the given address, as resolved by
+// memberlist, is one of the configured gateways of the primary datacenter.
+func (s *Server) isPrimaryGateway(addr string) bool {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, gateway := range s.config.WANFederationPrimaryGateways {
+		gatewayHost, gatewayPort, err := net.SplitHostPort(gateway)
+		if err != nil || gatewayPort != portStr {
+			continue
+		}
+		if gatewayIP := net.ParseIP(gatewayHost); gatewayIP != nil {
+			if gatewayIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		ips, err := net.LookupIP(gatewayHost)
+		if err != nil {
+			continue
+		}
+		for _, gatewayIP := range ips {
+			if gatewayIP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wanGatewayRoute returns the datacenter, node name and gateways to send WAN
+// gossip for the given address through. It returns false if the gossip
+// should be sent to the address directly.
+func (s *Server) wanGatewayRoute(addr string) (string, string, []string, bool) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", nil, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", "", nil, false
+	}
+	ip := net.ParseIP(host)
+
+	for _, m := range s.WANMembers() {
+		if !m.Addr.Equal(ip) || int(m.Port) != port {
+			continue
+		}
+		ok, parts := metadata.IsConsulServer(m)
+		if !ok || parts.Datacenter == s.config.Datacenter || len(parts.Gateways) == 0 {
+			return "", "", nil, false
+		}
+		node := strings.TrimSuffix(m.Name, "."+parts.Datacenter)
+		return parts.Datacenter, node, parts.Gateways, true
+	}
+
+	// Joins through the gateways of the primary datacenter go to any of
+	// its servers.
+	if s.isPrimaryGateway(addr) {
+		return s.config.PrimaryDatacenter, "", []string{addr}, true
+	}
+	return "", "", nil, false
+}
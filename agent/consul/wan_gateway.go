@@ -0,0 +1,252 @@
+package consul
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/metadata"
+	"github.com/hashicorp/serf/serf"
+)
+
+const (
+	// gatewayHandshakeTimeout is how long a client has to send its TLS
+	// client hello after connecting to a gateway.
+	gatewayHandshakeTimeout = 10 * time.Second
+
+	// gatewayDialTimeout is how long a gateway waits to connect to a
+	// server in its datacenter.
+	gatewayDialTimeout = 10 * time.Second
+)
+
+// errServerNamePeeked is used to abort the TLS handshake once the client
+// hello has been read.
+var errServerNamePeeked = errors.New("server name peeked")
+
+// WANGateway accepts connections from servers in other datacenters on a
+// single port, and passes them on to the servers in its own datacenter. The
+// connections are TLS, and are routed by the server name in the TLS client
+// hello without being decrypted, so servers only need to be able to reach
+// the gateways of other datacenters, rather than all of their servers.
+type WANGateway struct {
+	datacenter string
+	domain     string
+	listener   net.Listener
+	logger     *log.Logger
+
+	// membersFn returns the LAN members, used to find the servers in the
+	// datacenter.
+	membersFn func() []serf.Member
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+// NewWANGateway returns a gateway routing the connections accepted by the
+// given listener to the servers in the datacenter. Serve must be called to
+// start accepting connections.
+func NewWANGateway(listener net.Listener, datacenter, domain string, membersFn func() []serf.Member, logger *log.Logger) *WANGateway {
+	return &WANGateway{
+		datacenter: datacenter,
+		domain:     domain,
+		listener:   listener,
+		logger:     logger,
+		membersFn:  membersFn,
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+// Serve accepts connections until the gateway is shut down.
+func (g *WANGateway) Serve() {
+	for {
+		conn, err := g.listener.Accept()
+		if err != nil {
+			select {
+			case <-g.shutdownCh:
+				return
+			default:
+			}
+			g.logger.Printf("[ERR] consul.gateway: failed to accept conn: %v", err)
+			continue
+		}
+		go g.handleConn(conn)
+	}
+}
+
+// Shutdown stops accepting connections and closes the ones being proxied.
+func (g *WANGateway) Shutdown() error {
+	g.shutdownLock.Lock()
+	defer g.shutdownLock.Unlock()
+
+	if g.shutdown {
+		return nil
+	}
+	g.shutdown = true
+	close(g.shutdownCh)
+	return g.listener.Close()
+}
+
+// handleConn routes a single connection to the server named by its TLS
+// client hello.
+func (g *WANGateway) handleConn(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(gatewayHandshakeTimeout))
+	serverName, peeked, err := peekServerName(conn)
+	if err != nil {
+		g.logger.Printf("[WARN] consul.gateway: failed to read server name: %v %s", err, logConn(conn))
+		metrics.IncrCounter([]string{"wan_gateway", "rejected"}, 1)
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	node, dc, ok := parseGatewayServerName(serverName, g.domain)
+	if !ok || !strings.EqualFold(dc, g.datacenter) {
+		g.logger.Printf("[WARN] consul.gateway: unknown server name %q %s", serverName, logConn(conn))
+		metrics.IncrCounter([]string{"wan_gateway", "rejected"}, 1)
+		conn.Close()
+		return
+	}
+
+	addr, ok := g.serverAddr(node)
+	if !ok {
+		g.logger.Printf("[WARN] consul.gateway: no server found for %q %s", serverName, logConn(conn))
+		metrics.IncrCounter([]string{"wan_gateway", "rejected"}, 1)
+		conn.Close()
+		return
+	}
+
+	server, err := net.DialTimeout("tcp", addr.String(), gatewayDialTimeout)
+	if err != nil {
+		g.logger.Printf("[ERR] consul.gateway: failed to connect to server %s for %q: %v", addr, serverName, err)
+		metrics.IncrCounter([]string{"wan_gateway", "rejected"}, 1)
+		conn.Close()
+		return
+	}
+
+	metrics.IncrCounter([]string{"wan_gateway", "conn"}, 1)
+	g.proxy(conn, peeked, server)
+}
+
+// serverAddr returns the RPC address of the named server in the datacenter,
+// or of a random one if the name is empty.
+func (g *WANGateway) serverAddr(node string) (net.Addr, bool) {
+	var servers []*metadata.Server
+	for _, m := range g.membersFn() {
+		ok, parts := metadata.IsConsulServer(m)
+		if !ok || parts.Datacenter != g.datacenter || m.Status != serf.StatusAlive {
+			continue
+		}
+		if node != "" && !strings.EqualFold(m.Name, node) {
+			continue
+		}
+		servers = append(servers, parts)
+	}
+	if len(servers) == 0 {
+		return nil, false
+	}
+	return servers[rand.Intn(len(servers))].Addr, true
+}
+
+// proxy copies data between the client and server connections until both
+// sides are done, or the gateway is shut down. The client is read from
+// through peeked, so the bytes of the client hello are passed on as well.
+func (g *WANGateway) proxy(client, peeked, server net.Conn) {
+	var wg sync.WaitGroup
+	copyFn := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+
+		// Pass on half-closes, so the other side can finish writing.
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	wg.Add(2)
+	go copyFn(server, peeked)
+	go copyFn(client, server)
+
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+	case <-g.shutdownCh:
+	}
+	client.Close()
+	server.Close()
+}
+
+// peekServerName reads the TLS client hello from the connection and returns
+// the server name in it, along with a connection that will read the client
+// hello again before the rest of the data.
+func peekServerName(conn net.Conn) (string, net.Conn, error) {
+	var buf bytes.Buffer
+	var serverName string
+	var sawHello bool
+	hello := tls.Server(&readOnlyConn{Conn: conn, r: io.TeeReader(conn, &buf)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = info.ServerName
+			sawHello = true
+			return nil, errServerNamePeeked
+		},
+	})
+	if err := hello.Handshake(); !sawHello {
+		return "", nil, err
+	}
+	if serverName == "" {
+		return "", nil, fmt.Errorf("no server name in TLS client hello")
+	}
+	return serverName, &peekedConn{Conn: conn, r: io.MultiReader(&buf, conn)}, nil
+}
+
+// parseGatewayServerName splits a server name of the form
+// <node>.server.<dc>.<domain> into its node and datacenter. The node is
+// empty for server.<dc>.<domain>, which is any server in the datacenter.
+func parseGatewayServerName(name, domain string) (string, string, bool) {
+	suffix := "." + strings.TrimSuffix(domain, ".")
+	if !strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix)) {
+		return "", "", false
+	}
+
+	labels := strings.Split(name[:len(name)-len(suffix)], ".")
+	n := len(labels)
+	if n < 2 || !strings.EqualFold(labels[n-2], "server") || labels[n-1] == "" {
+		return "", "", false
+	}
+	return strings.Join(labels[:n-2], "."), labels[n-1], true
+}
+
+// readOnlyConn is used to read a TLS client hello from a connection without
+// answering it.
+type readOnlyConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *readOnlyConn) Read(b []byte) (int, error)  { return c.r.Read(b) }
+func (c *readOnlyConn) Write(b []byte) (int, error) { return 0, io.ErrClosedPipe }
+func (c *readOnlyConn) Close() error                { return nil }
+
+// peekedConn is a connection some data was already read from, which is
+// read again before the rest of the connection.
+type peekedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
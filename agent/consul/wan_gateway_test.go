@@ -0,0 +1,182 @@
+package consul
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/connect"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGatewayServerName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		node string
+		dc   string
+		ok   bool
+	}{
+		{"server.dc1.consul", "", "dc1", true},
+		{"node1.server.dc1.consul", "node1", "dc1", true},
+		{"node.with.dots.server.dc1.consul", "node.with.dots", "dc1", true},
+		{"Node1.Server.DC1.Consul", "Node1", "DC1", true},
+		{"server.dc1.other", "", "", false},
+		{"node1.client.dc1.consul", "", "", false},
+		{"dc1.consul", "", "", false},
+		{"server..consul", "", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			node, dc, ok := parseGatewayServerName(tc.name, "consul.")
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.node, node)
+			require.Equal(t, tc.dc, dc)
+		})
+	}
+}
+
+func TestPeekServerName(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go tls.Client(client, &tls.Config{
+		ServerName:         "node1.server.dc1.consul",
+		InsecureSkipVerify: true,
+	}).Handshake()
+
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	name, peeked, err := peekServerName(server)
+	require.NoError(t, err)
+	require.Equal(t, "node1.server.dc1.consul", name)
+
+	// The client hello is read again, starting with its record type.
+	buf := make([]byte, 1)
+	_, err = peeked.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, byte(tlsHandshakeRecord), buf[0])
+}
+
+// testWANGatewayTLS writes a CA and a certificate signed by it for the
+// servers of TestServer_WANFederationGateways to use.
+func testWANGatewayTLS(t *testing.T, dir string) func(c *Config) {
+	root := connect.TestCA(t, nil)
+	cert, key := connect.TestLeaf(t, "server", root)
+
+	files := map[string]string{
+		"ca.pem":   root.RootCert,
+		"cert.pem": cert,
+		"key.pem":  key,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return func(c *Config) {
+		c.Domain = "consul."
+		c.CAFile = filepath.Join(dir, "ca.pem")
+		c.CertFile = filepath.Join(dir, "cert.pem")
+		c.KeyFile = filepath.Join(dir, "key.pem")
+		c.VerifyIncoming = true
+		c.VerifyOutgoing = true
+	}
+}
+
+func TestServer_WANFederationGateways(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "consul")
+	defer os.RemoveAll(dir)
+	tlsConfig := testWANGatewayTLS(t, dir)
+
+	// The gateways need to listen first, so the servers can be told where
+	// they are.
+	ln1, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	// The servers advertise WAN addresses nothing listens on, so they can
+	// only reach each other through the gateways.
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		tlsConfig(c)
+		c.SerfWANConfig.MemberlistConfig.AdvertiseAddr = "127.0.0.3"
+		c.WANFederationLocalGateways = []string{ln1.Addr().String()}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testrpc.WaitForLeader(t, s1.RPC, "dc1")
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		tlsConfig(c)
+		c.Datacenter = "dc2"
+		c.PrimaryDatacenter = "dc1"
+		c.SerfWANConfig.MemberlistConfig.AdvertiseAddr = "127.0.0.4"
+		c.WANFederationLocalGateways = []string{ln2.Addr().String()}
+		c.WANFederationPrimaryGateways = []string{ln1.Addr().String()}
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+	testrpc.WaitForLeader(t, s2.RPC, "dc2")
+
+	gw1 := NewWANGateway(ln1, "dc1", "consul", s1.LANMembers, s1.logger)
+	go gw1.Serve()
+	defer gw1.Shutdown()
+	gw2 := NewWANGateway(ln2, "dc2", "consul", s2.LANMembers, s2.logger)
+	go gw2.Serve()
+	defer gw2.Shutdown()
+
+	// Join through the primary datacenter's gateway.
+	_, err = s2.JoinWAN([]string{ln1.Addr().String()})
+	require.NoError(t, err)
+	retry.Run(t, func(r *retry.R) {
+		if got, want := len(s1.WANMembers()), 2; got != want {
+			r.Fatalf("got %d WAN members for s1, want %d", got, want)
+		}
+		if got, want := len(s2.WANMembers()), 2; got != want {
+			r.Fatalf("got %d WAN members for s2, want %d", got, want)
+		}
+	})
+
+	// Requests are forwarded both ways.
+	retry.Run(t, func(r *retry.R) {
+		args := structs.DCSpecificRequest{Datacenter: "dc1"}
+		var out structs.IndexedNodes
+		if err := s2.RPC("Catalog.ListNodes", &args, &out); err != nil {
+			r.Fatal(err)
+		}
+		if len(out.Nodes) != 1 || out.Nodes[0].Node != s1.config.NodeName {
+			r.Fatalf("bad: %v", out.Nodes)
+		}
+
+		args.Datacenter = "dc2"
+		if err := s1.RPC("Catalog.ListNodes", &args, &out); err != nil {
+			r.Fatal(err)
+		}
+		if len(out.Nodes) != 1 || out.Nodes[0].Node != s2.config.NodeName {
+			r.Fatalf("bad: %v", out.Nodes)
+		}
+	})
+
+	// Gossip keeps going through the gateways, so the servers stay alive
+	// to each other.
+	time.Sleep(time.Second)
+	for _, m := range s1.WANMembers() {
+		require.Equal(t, "alive", m.Status.String(), m.Name)
+	}
+	for _, m := range s2.WANMembers() {
+		require.Equal(t, "alive", m.Status.String(), m.Name)
+	}
+}
@@ -0,0 +1,424 @@
+package consul
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/pool"
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	// gossipPacket, gossipStream and gossipPackets follow the RPCGossip byte
+	// on connections carrying WAN gossip through gateways, to tell a single
+	// memberlist packet, a stream, and a series of packets apart. Single
+	// packets are only accepted from servers that don't send a series yet.
+	gossipPacket  byte = 0
+	gossipStream  byte = 1
+	gossipPackets byte = 2
+
+	// maxGossipPacketSize is the largest gossip packet accepted through a
+	// gateway, which is the largest UDP packet memberlist could send.
+	maxGossipPacketSize = 65536
+
+	// gossipPacketTimeout is how long connecting to a server through a
+	// gateway, or sending a single gossip packet to it, may take.
+	gossipPacketTimeout = 5 * time.Second
+
+	// gossipPacketIdleTimeout is how long a connection for gossip packets is
+	// kept open without any packets being sent over it.
+	gossipPacketIdleTimeout = 30 * time.Second
+
+	// gossipPacketQueueSize is how many packets may be waiting to be sent
+	// to a server. Any more are dropped, as they would be over UDP.
+	gossipPacketQueueSize = 64
+
+	// maxGossipPacketSenders is the most servers gossip packets are sent to
+	// through gateways at once, each by a goroutine of its own. Packets to
+	// other servers are dropped until a sender goes idle.
+	maxGossipPacketSenders = 256
+)
+
+// wanGatewayTransport is the memberlist transport of the WAN pool when WAN
+// federation goes through gateways. Gossip for servers in datacenters with
+// gateways is sent through them over TLS, with the packets for each server
+// sent in order over a connection that's kept open while they keep coming,
+// and everything else goes over the wrapped network transport. Gossip
+// arriving through our own gateways is handed to it by the RPC listener.
+type wanGatewayTransport struct {
+	server *Server
+	net    *memberlist.NetTransport
+
+	packetCh chan *memberlist.Packet
+	streamCh chan net.Conn
+
+	// senders send the packets for each server, by its memberlist address.
+	senders     map[string]chan []byte
+	sendersLock sync.Mutex
+
+	// advertiseAddr is the address memberlist advertises for us, which is
+	// sent along with packets so replies can be routed back.
+	advertiseAddr     string
+	advertiseAddrLock sync.RWMutex
+
+	shutdownCh chan struct{}
+}
+
+// newWANGatewayTransport returns a transport for the given memberlist
+// configuration. Like memberlist does for its own transport, it picks the
+// port if the configured one is zero.
+func newWANGatewayTransport(s *Server, conf *memberlist.Config) (*wanGatewayTransport, error) {
+	nt, err := memberlist.NewNetTransport(&memberlist.NetTransportConfig{
+		BindAddrs: []string{conf.BindAddr},
+		BindPort:  conf.BindPort,
+		Logger:    s.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if conf.BindPort == 0 {
+		port := nt.GetAutoBindPort()
+		conf.BindPort = port
+		conf.AdvertisePort = port
+	}
+
+	t := &wanGatewayTransport{
+		server:     s,
+		net:        nt,
+		packetCh:   make(chan *memberlist.Packet),
+		streamCh:   make(chan net.Conn),
+		senders:    make(map[string]chan []byte),
+		shutdownCh: make(chan struct{}),
+	}
+	go t.forward()
+	return t, nil
+}
+
+// forward passes on what arrives over the network transport.
+func (t *wanGatewayTransport) forward() {
+	for {
+		select {
+		case packet := <-t.net.PacketCh():
+			select {
+			case t.packetCh <- packet:
+			case <-t.shutdownCh:
+				return
+			}
+		case conn := <-t.net.StreamCh():
+			select {
+			case t.streamCh <- conn:
+			case <-t.shutdownCh:
+				conn.Close()
+				return
+			}
+		case <-t.shutdownCh:
+			return
+		}
+	}
+}
+
+// FinalAdvertiseAddr is part of the memberlist.Transport interface.
+func (t *wanGatewayTransport) FinalAdvertiseAddr(ip string, port int) (net.IP, int, error) {
+	addr, port, err := t.net.FinalAdvertiseAddr(ip, port)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	t.advertiseAddrLock.Lock()
+	t.advertiseAddr = net.JoinHostPort(addr.String(), strconv.Itoa(port))
+	t.advertiseAddrLock.Unlock()
+	return addr, port, nil
+}
+
+// WriteTo is part of the memberlist.Transport interface. Packets sent
+// through gateways are queued for the server's sender, so a slow gateway
+// doesn't hold up gossip with other servers.
+func (t *wanGatewayTransport) WriteTo(b []byte, addr string) (time.Time, error) {
+	if _, _, _, ok := t.server.wanGatewayRoute(addr); !ok {
+		return t.net.WriteTo(b, addr)
+	}
+
+	t.sendersLock.Lock()
+	defer t.sendersLock.Unlock()
+	ch, ok := t.senders[addr]
+	if !ok {
+		if len(t.senders) >= maxGossipPacketSenders {
+			metrics.IncrCounter([]string{"wan_gateway", "gossip", "packet_dropped"}, 1)
+			return time.Now(), nil
+		}
+		ch = make(chan []byte, gossipPacketQueueSize)
+		t.senders[addr] = ch
+		go t.sendPackets(addr, ch)
+	}
+
+	// The buffer is copied since we return before it's sent.
+	select {
+	case ch <- append([]byte(nil), b...):
+	default:
+		metrics.IncrCounter([]string{"wan_gateway", "gossip", "packet_dropped"}, 1)
+	}
+	return time.Now(), nil
+}
+
+// sendPackets sends the packets queued for the server with the given address
+// through a gateway. The connection is opened for the first packet and kept
+// open for the ones after, until it's been idle for gossipPacketIdleTimeout,
+// at which point the sender exits.
+func (t *wanGatewayTransport) sendPackets(addr string, ch chan []byte) {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	idle := time.NewTimer(gossipPacketIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case b := <-ch:
+			var err error
+			if conn == nil {
+				conn, err = t.dialPackets(addr)
+			}
+			if err == nil {
+				conn.SetWriteDeadline(time.Now().Add(gossipPacketTimeout))
+				if err = writeGossipPacket(conn, b); err != nil {
+					conn.Close()
+					conn = nil
+				}
+			}
+			if err != nil {
+				t.server.logger.Printf("[DEBUG] consul: failed to send gossip packet to %s through gateway: %v", addr, err)
+				metrics.IncrCounter([]string{"wan_gateway", "gossip", "packet_failed"}, 1)
+			}
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(gossipPacketIdleTimeout)
+
+		case <-idle.C:
+			t.sendersLock.Lock()
+			if len(ch) > 0 {
+				t.sendersLock.Unlock()
+				idle.Reset(gossipPacketIdleTimeout)
+				continue
+			}
+			delete(t.senders, addr)
+			t.sendersLock.Unlock()
+			return
+
+		case <-t.shutdownCh:
+			return
+		}
+	}
+}
+
+// dialPackets opens a connection for gossip packets to the server with the
+// given address through a gateway, and sends the header identifying us as
+// the sender.
+func (t *wanGatewayTransport) dialPackets(addr string) (net.Conn, error) {
+	dc, node, gateways, ok := t.server.wanGatewayRoute(addr)
+	if !ok {
+		return nil, fmt.Errorf("no gateway route")
+	}
+	conn, err := t.server.dialGateway(gateways, dc, node, gossipPacketTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	t.advertiseAddrLock.RLock()
+	from := t.advertiseAddr
+	t.advertiseAddrLock.RUnlock()
+
+	conn.SetWriteDeadline(time.Now().Add(gossipPacketTimeout))
+	header := []byte{byte(pool.RPCGossip), gossipPackets, byte(len(from))}
+	header = append(header, from...)
+	if _, err := conn.Write(header); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// writeGossipPacket writes a packet to a connection for gossip packets,
+// prefixed by its length.
+func writeGossipPacket(w io.Writer, b []byte) error {
+	buf := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	_, err := w.Write(append(buf, b...))
+	return err
+}
+
+// PacketCh is part of the memberlist.Transport interface.
+func (t *wanGatewayTransport) PacketCh() <-chan *memberlist.Packet {
+	return t.packetCh
+}
+
+// DialTimeout is part of the memberlist.Transport interface.
+func (t *wanGatewayTransport) DialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	dc, node, gateways, ok := t.server.wanGatewayRoute(addr)
+	if !ok {
+		return t.net.DialTimeout(addr, timeout)
+	}
+
+	conn, err := t.server.dialGateway(gateways, dc, node, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{byte(pool.RPCGossip), gossipStream}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// StreamCh is part of the memberlist.Transport interface.
+func (t *wanGatewayTransport) StreamCh() <-chan net.Conn {
+	return t.streamCh
+}
+
+// Shutdown is part of the memberlist.Transport interface.
+func (t *wanGatewayTransport) Shutdown() error {
+	close(t.shutdownCh)
+	return t.net.Shutdown()
+}
+
+// handleConn takes a connection carrying gossip that arrived through one of
+// our gateways, after its RPCGossip byte.
+func (t *wanGatewayTransport) handleConn(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(gatewayHandshakeTimeout))
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.server.logger.Printf("[ERR] consul.rpc: failed to read gossip header: %v %s", err, logConn(conn))
+		conn.Close()
+		return
+	}
+
+	switch buf[0] {
+	case gossipPacket:
+		packet, err := readGossipPacket(conn)
+		conn.Close()
+		if err != nil {
+			t.server.logger.Printf("[ERR] consul.rpc: failed to read gossip packet: %v %s", err, logConn(conn))
+			return
+		}
+		select {
+		case t.packetCh <- packet:
+		case <-t.shutdownCh:
+		}
+
+	case gossipPackets:
+		defer conn.Close()
+		from, err := readGossipSender(conn)
+		if err != nil {
+			t.server.logger.Printf("[ERR] consul.rpc: failed to read gossip header: %v %s", err, logConn(conn))
+			return
+		}
+		for {
+			// The sender closes the connection once it's idle, so this
+			// only times out if it's gone.
+			conn.SetReadDeadline(time.Now().Add(2 * gossipPacketIdleTimeout))
+			b, err := readFramedGossipPacket(conn)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.server.logger.Printf("[ERR] consul.rpc: failed to read gossip packet: %v %s", err, logConn(conn))
+				return
+			}
+			packet := &memberlist.Packet{Buf: b, From: from, Timestamp: time.Now()}
+			select {
+			case t.packetCh <- packet:
+			case <-t.shutdownCh:
+				return
+			}
+		}
+
+	case gossipStream:
+		conn.SetReadDeadline(time.Time{})
+		select {
+		case t.streamCh <- conn:
+		case <-t.shutdownCh:
+			conn.Close()
+		}
+
+	default:
+		t.server.logger.Printf("[ERR] consul.rpc: unrecognized gossip byte: %v %s", buf[0], logConn(conn))
+		conn.Close()
+	}
+}
+
+// readGossipPacket reads the sender's address, prefixed by its length, and
+// the rest of the connection as the packet.
+func readGossipPacket(conn net.Conn) (*memberlist.Packet, error) {
+	from, err := readGossipSender(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(conn, maxGossipPacketSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxGossipPacketSize {
+		return nil, fmt.Errorf("packet too large")
+	}
+	return &memberlist.Packet{
+		Buf:       b,
+		From:      from,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// readFramedGossipPacket reads a packet prefixed by its length, as written by
+// writeGossipPacket. It returns io.EOF if the connection is closed before the
+// next packet.
+func readFramedGossipPacket(r io.Reader) ([]byte, error) {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size)
+	if n > maxGossipPacketSize {
+		return nil, fmt.Errorf("packet too large")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readGossipSender reads the sender's address, prefixed by its length.
+func readGossipSender(conn net.Conn) (*net.UDPAddr, error) {
+	fromLen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, fromLen); err != nil {
+		return nil, err
+	}
+	from := make([]byte, fromLen[0])
+	if _, err := io.ReadFull(conn, from); err != nil {
+		return nil, err
+	}
+	host, portStr, err := net.SplitHostPort(string(from))
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid sender address %q", from)
+	}
+	return &net.UDPAddr{IP: ip, Port: port}, nil
+}
@@ -0,0 +1,39 @@
+package consul
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWANGatewayTransport_framedPackets(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeGossipPacket(&buf, []byte("hello")))
+	require.NoError(t, writeGossipPacket(&buf, []byte{}))
+	require.NoError(t, writeGossipPacket(&buf, []byte("world")))
+
+	// Packets come out in order, with io.EOF once they run out.
+	for _, want := range []string{"hello", "", "world"} {
+		b, err := readFramedGossipPacket(&buf)
+		require.NoError(t, err)
+		require.Equal(t, want, string(b))
+	}
+	_, err := readFramedGossipPacket(&buf)
+	require.Equal(t, io.EOF, err)
+
+	// Packets that are too large are refused before they're read.
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, maxGossipPacketSize+1)
+	_, err = readFramedGossipPacket(bytes.NewReader(size))
+	require.Error(t, err)
+
+	// A packet cut short isn't mistaken for the end of the connection.
+	require.NoError(t, writeGossipPacket(&buf, []byte("hello")))
+	_, err = readFramedGossipPacket(bytes.NewReader(buf.Bytes()[:6]))
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
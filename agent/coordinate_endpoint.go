@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/agent/structs"
 )
@@ -150,7 +151,7 @@ func (s *HTTPServer) CoordinateUpdate(resp http.ResponseWriter, req *http.Reques
 	}
 
 	args := structs.CoordinateUpdateRequest{}
-	if err := decodeBody(req, &args, nil); err != nil {
+	if err := decodeBody(req, &args, fixupSentAt); err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(resp, "Request decode failed: %v", err)
 		return nil, nil
@@ -165,3 +166,49 @@ func (s *HTTPServer) CoordinateUpdate(resp http.ResponseWriter, req *http.Reques
 
 	return nil, nil
 }
+
+// fixupSentAt parses the SentAt time of a coordinate update from its JSON
+// string form, which the body decoder can't do by itself.
+func fixupSentAt(raw interface{}) error {
+	rawMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for k, v := range rawMap {
+		if strings.ToLower(k) != "sentat" {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			sentAt, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fmt.Errorf("invalid SentAt: %v", err)
+			}
+			rawMap[k] = sentAt
+		}
+	}
+	return nil
+}
+
+// CoordinateBatchUpdate inserts or updates the LAN coordinates of several
+// nodes that don't run Serf.
+func (s *HTTPServer) CoordinateBatchUpdate(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if s.checkCoordinateDisabled(resp, req) {
+		return nil, nil
+	}
+
+	args := structs.CoordinateBatchUpdateRequest{}
+	if err := decodeBody(req, &args.Coordinates, nil); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "Request decode failed: %v", err)
+		return nil, nil
+	}
+	s.parseDC(req, &args.Datacenter)
+	s.parseToken(req, &args.Token)
+
+	var reply struct{}
+	if err := s.agent.RPC("Coordinate.BatchUpdate", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -331,6 +331,27 @@ func TestCoordinate_Update(t *testing.T) {
 		coordinates[0].Node != "foo" {
 		t.Fatalf("bad: %v", coordinates)
 	}
+
+	// The time the update was sent is parsed from its string form.
+	body.SentAt = time.Now()
+	req, _ = http.NewRequest("PUT", "/v1/coordinate/update", jsonReader(body))
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.CoordinateUpdate(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != http.StatusOK {
+		t.Fatalf("bad: %d %s", resp.Code, resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("PUT", "/v1/coordinate/update",
+		strings.NewReader(`{"Node": "foo", "SentAt": "yesterday"}`))
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.CoordinateUpdate(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("bad: %d", resp.Code)
+	}
 }
 
 func TestCoordinate_Update_ACLDeny(t *testing.T) {
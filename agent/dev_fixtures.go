@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+)
+
+// devFixtures is the data a dev mode agent is seeded with at startup, so
+// applications can be developed and tested against a known state.
+type devFixtures struct {
+	// KV are the keys to write.
+	KV []devFixtureKV
+
+	// Services are the services to register. Services without a node are
+	// registered with the agent itself, and the others are registered in
+	// the catalog along with their node.
+	Services []devFixtureService
+}
+
+// devFixtureKV is a key to write, with a plain text value.
+type devFixtureKV struct {
+	Key   string
+	Value string
+	Flags uint64
+}
+
+// devFixtureService is a service to register.
+type devFixtureService struct {
+	Node    string
+	Address string
+	ID      string
+	Name    string
+	Tags    []string
+	Port    int
+	Meta    map[string]string
+}
+
+// readDevFixtures reads and validates the fixtures in the given JSON file.
+func readDevFixtures(file string) (*devFixtures, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading dev fixtures: %v", err)
+	}
+	var f devFixtures
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("Failed decoding dev fixtures %q: %v", file, err)
+	}
+
+	for i, kv := range f.KV {
+		if kv.Key == "" {
+			return nil, fmt.Errorf("Dev fixture KV[%d] must have a key", i)
+		}
+	}
+	for i, svc := range f.Services {
+		if svc.Name == "" {
+			return nil, fmt.Errorf("Dev fixture Services[%d] must have a name", i)
+		}
+		if svc.Node != "" && svc.Address == "" {
+			return nil, fmt.Errorf("Dev fixture Services[%d] must have an address for node %q", i, svc.Node)
+		}
+	}
+	return &f, nil
+}
+
+// loadDevFixtures seeds the agent with the fixtures in the file it was
+// started with, if any. Since a dev agent's state is only kept in memory,
+// this happens every time it starts.
+func (a *Agent) loadDevFixtures(file string) error {
+	if file == "" {
+		return nil
+	}
+	f, err := readDevFixtures(file)
+	if err != nil {
+		return err
+	}
+	token := a.tokens.AgentToken()
+
+	for _, svc := range f.Services {
+		ns := &structs.NodeService{
+			ID:      svc.ID,
+			Service: svc.Name,
+			Tags:    svc.Tags,
+			Port:    svc.Port,
+			Meta:    svc.Meta,
+		}
+		if ns.ID == "" {
+			ns.ID = ns.Service
+		}
+
+		if svc.Node == "" {
+			if err := a.AddService(ns, nil, false, token, ConfigSourceLocal); err != nil {
+				return fmt.Errorf("Failed to register dev fixture service %q: %v", ns.ID, err)
+			}
+			continue
+		}
+
+		args := structs.RegisterRequest{
+			Datacenter:   a.config.Datacenter,
+			Node:         svc.Node,
+			Address:      svc.Address,
+			Service:      ns,
+			WriteRequest: structs.WriteRequest{Token: token},
+		}
+		var out struct{}
+		if err := a.RPC("Catalog.Register", &args, &out); err != nil {
+			return fmt.Errorf("Failed to register dev fixture service %q on node %q: %v", ns.ID, svc.Node, err)
+		}
+	}
+
+	for _, kv := range f.KV {
+		args := structs.KVSRequest{
+			Datacenter: a.config.Datacenter,
+			Op:         api.KVSet,
+			DirEnt: structs.DirEntry{
+				Key:   kv.Key,
+				Value: []byte(kv.Value),
+				Flags: kv.Flags,
+			},
+			WriteRequest: structs.WriteRequest{Token: token},
+		}
+		var ok bool
+		if err := a.RPC("KVS.Apply", &args, &ok); err != nil {
+			return fmt.Errorf("Failed to write dev fixture key %q: %v", kv.Key, err)
+		}
+	}
+
+	a.logger.Printf("[INFO] agent: Loaded %d services and %d keys from dev fixtures %q",
+		len(f.Services), len(f.KV), file)
+	return nil
+}
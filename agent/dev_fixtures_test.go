@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDevFixtures(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "fixtures")
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) string {
+		file := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(file, []byte(contents), 0600))
+		return file
+	}
+
+	_, err := readDevFixtures(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+
+	_, err = readDevFixtures(write("unknown.json", `{"Keys": []}`))
+	require.Error(t, err)
+
+	_, err = readDevFixtures(write("nokey.json", `{"KV": [{"Value": "bar"}]}`))
+	require.EqualError(t, err, "Dev fixture KV[0] must have a key")
+
+	_, err = readDevFixtures(write("noaddr.json", `{"Services": [{"Node": "ext", "Name": "db"}]}`))
+	require.EqualError(t, err, `Dev fixture Services[0] must have an address for node "ext"`)
+
+	f, err := readDevFixtures(write("ok.json", `{
+		"KV": [{"Key": "config/web/port", "Value": "8080", "Flags": 42}],
+		"Services": [{"Name": "web", "Port": 8080, "Tags": ["v1"]}]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []devFixtureKV{{Key: "config/web/port", Value: "8080", Flags: 42}}, f.KV)
+	require.Equal(t, []devFixtureService{{Name: "web", Port: 8080, Tags: []string{"v1"}}}, f.Services)
+}
+
+func TestAgent_loadDevFixtures(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	dir := testutil.TempDir(t, "fixtures")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "fixtures.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{
+		"KV": [{"Key": "config/web/port", "Value": "8080"}],
+		"Services": [
+			{"Name": "web", "Port": 8080},
+			{"Node": "ext", "Address": "10.1.2.3", "ID": "db1", "Name": "db", "Port": 5432}
+		]
+	}`), 0600))
+	require.NoError(t, a.loadDevFixtures(file))
+
+	// Services without a node are registered with the agent.
+	require.Contains(t, a.State.Services(), "web")
+
+	// The others are registered in the catalog.
+	var nodes structs.IndexedServiceNodes
+	req := structs.ServiceSpecificRequest{Datacenter: "dc1", ServiceName: "db"}
+	require.NoError(t, a.RPC("Catalog.ServiceNodes", &req, &nodes))
+	require.Len(t, nodes.ServiceNodes, 1)
+	require.Equal(t, "ext", nodes.ServiceNodes[0].Node)
+	require.Equal(t, "10.1.2.3", nodes.ServiceNodes[0].Address)
+	require.Equal(t, "db1", nodes.ServiceNodes[0].ServiceID)
+
+	var entries structs.IndexedDirEntries
+	get := structs.KeyRequest{Datacenter: "dc1", Key: "config/web/port"}
+	require.NoError(t, a.RPC("KVS.Get", &get, &entries))
+	require.Len(t, entries.Entries, 1)
+	require.Equal(t, []byte("8080"), entries.Entries[0].Value)
+}
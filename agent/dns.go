@@ -14,6 +14,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/armon/go-radix"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	cachetype "github.com/hashicorp/consul/agent/cache-types"
 	"github.com/hashicorp/consul/agent/config"
 	"github.com/hashicorp/consul/agent/consul"
 	"github.com/hashicorp/consul/agent/structs"
@@ -63,6 +64,15 @@ type dnsConfig struct {
 	ARecordLimit    int
 	NodeMetaTXT     bool
 	dnsSOAConfig    dnsSOAConfig
+
+	// UseCache answers service lookups from the agent's cache, going to
+	// the servers only when results are older than CacheMaxAge.
+	UseCache    bool
+	CacheMaxAge time.Duration
+
+	// TaggedAddress is the tagged address node lookups answer with, for
+	// nodes that have it.
+	TaggedAddress string
 }
 
 // DNSServer is used to wrap an Agent and expose various
@@ -140,6 +150,9 @@ func GetDNSConfig(conf *config.RuntimeConfig) *dnsConfig {
 		ServiceTTL:      conf.DNSServiceTTL,
 		UDPAnswerLimit:  conf.DNSUDPAnswerLimit,
 		NodeMetaTXT:     conf.DNSNodeMetaTXT,
+		UseCache:        conf.DNSUseCache,
+		CacheMaxAge:     conf.DNSCacheMaxAge,
+		TaggedAddress:   conf.DNSNodeTaggedAddress,
 		dnsSOAConfig: dnsSOAConfig{
 			Expire:  conf.DNSSOA.Expire,
 			Minttl:  conf.DNSSOA.Minttl,
@@ -219,8 +232,48 @@ func setEDNS(request *dns.Msg, response *dns.Msg, ecsGlobal bool) {
 	}
 }
 
+// dualStackAddr returns the address of the node in the family asked for by an
+// A or AAAA query. Nodes with both IPv4 and IPv6 addresses publish the one that
+// isn't their address as a tagged address: "lan_ipv4" or "lan_ipv6" for the
+// LAN address, and "wan_ipv4" or "wan_ipv6" for the WAN address. Addresses that
+// aren't the node's, such as service addresses, are returned as they are.
+func dualStackAddr(node *structs.Node, addr string, qType uint16) string {
+	if node == nil || (qType != dns.TypeA && qType != dns.TypeAAAA) {
+		return addr
+	}
+	wantIPv4 := qType == dns.TypeA
+	ip := net.ParseIP(addr)
+	if ip == nil || (ip.To4() != nil) == wantIPv4 {
+		return addr
+	}
+
+	var prefix string
+	switch addr {
+	case node.Address:
+		prefix = "lan"
+	case node.TaggedAddresses["wan"]:
+		prefix = "wan"
+	default:
+		return addr
+	}
+	suffix := "_ipv6"
+	if wantIPv4 {
+		suffix = "_ipv4"
+	}
+	other := net.ParseIP(node.TaggedAddresses[prefix+suffix])
+	if other == nil || (other.To4() != nil) != wantIPv4 {
+		return addr
+	}
+	return other.String()
+}
+
 // recursorAddr is used to add a port to the recursor if omitted.
 func recursorAddr(recursor string) (string, error) {
+	// A bare IPv6 address has too many colons to tell that it has no port
+	if ip := net.ParseIP(recursor); ip != nil && ip.To4() == nil {
+		recursor = net.JoinHostPort(recursor, "53")
+	}
+
 	// Add the port if none
 START:
 	_, _, err := net.SplitHostPort(recursor)
@@ -685,6 +738,9 @@ RPC:
 	n := out.NodeServices.Node
 	edns := req.IsEdns0() != nil
 	addr := d.agent.TranslateAddress(datacenter, n.Address, n.TaggedAddresses)
+	if tagged := n.TaggedAddresses[d.config.TaggedAddress]; d.config.TaggedAddress != "" && tagged != "" {
+		addr = tagged
+	}
 	records, meta := d.formatNodeRecord(out.NodeServices.Node, addr, req.Question[0].Name, qType, d.config.NodeTTL, edns, maxRecursionLevel)
 	if records != nil {
 		resp.Answer = append(resp.Answer, records...)
@@ -724,6 +780,7 @@ func encodeKVasRFC1464(key, value string) (txt string) {
 // generated RRs should go and if they should be used at all.
 func (d *DNSServer) formatNodeRecord(node *structs.Node, addr, qName string, qType uint16, ttl time.Duration, edns bool, maxRecursionLevel int) (records, meta []dns.RR) {
 	// Parse the IP
+	addr = dualStackAddr(node, addr, qType)
 	ip := net.ParseIP(addr)
 	var ipv4 net.IP
 	if ip != nil {
@@ -1024,6 +1081,10 @@ func (d *DNSServer) lookupServiceNodes(datacenter, service, tag string, connect
 		},
 	}
 
+	if d.config.UseCache {
+		return d.lookupServiceNodesCached(&args)
+	}
+
 	var out structs.IndexedCheckServiceNodes
 	if err := d.agent.RPC("Health.ServiceNodes", &args, &out); err != nil {
 		return structs.IndexedCheckServiceNodes{}, err
@@ -1048,6 +1109,40 @@ func (d *DNSServer) lookupServiceNodes(datacenter, service, tag string, connect
 	return out, nil
 }
 
+// lookupServiceNodesCached returns nodes with a given service from the agent's
+// cache. The cache keeps a blocking query running for each service looked up,
+// so results are only as stale as that query. If the servers can't be reached
+// the last known results are served instead of failing the lookup, and how old
+// they are is logged and reported in metrics.
+func (d *DNSServer) lookupServiceNodesCached(args *structs.ServiceSpecificRequest) (structs.IndexedCheckServiceNodes, error) {
+	args.MaxAge = d.config.CacheMaxAge
+	raw, m, err := d.agent.cache.Get(cachetype.HealthServicesName, args)
+	if err != nil && raw == nil {
+		return structs.IndexedCheckServiceNodes{}, err
+	}
+	reply, ok := raw.(*structs.IndexedCheckServiceNodes)
+	if !ok {
+		// This should never happen, but we want to protect against panics
+		return structs.IndexedCheckServiceNodes{}, fmt.Errorf("internal error: response type not correct")
+	}
+
+	if m.Age > 0 {
+		metrics.AddSample([]string{"dns", "cache_age"}, float32(m.Age.Seconds()*1000))
+	}
+	if err != nil {
+		metrics.IncrCounter([]string{"dns", "stale_cache_queries"}, 1)
+		d.logger.Printf("[WARN] dns: Serving cached results for service %q which are %v old, failed to refresh them: %v",
+			args.ServiceName, m.Age, err)
+	}
+
+	// The cached value is shared, so filter a copy of the nodes.
+	out := *reply
+	out.Nodes = make(structs.CheckServiceNodes, len(reply.Nodes))
+	copy(out.Nodes, reply.Nodes)
+	out.Nodes = out.Nodes.Filter(d.config.OnlyPassing)
+	return out, nil
+}
+
 // serviceLookup is used to handle a service query
 func (d *DNSServer) serviceLookup(network, datacenter, service, tag string, connect bool, req, resp *dns.Msg, maxRecursionLevel int) {
 	out, err := d.lookupServiceNodes(datacenter, service, tag, connect, maxRecursionLevel)
@@ -1064,8 +1159,14 @@ func (d *DNSServer) serviceLookup(network, datacenter, service, tag string, conn
 		return
 	}
 
-	// Perform a random shuffle
+	// Nodes from a failover datacenter need its addresses.
+	if out.FailoverDatacenter != "" {
+		datacenter = out.FailoverDatacenter
+	}
+
+	// Perform a random shuffle, keeping nodes in drain mode last
 	out.Nodes.Shuffle()
+	out.Nodes.SortDrainedLast()
 
 	// Determine the TTL
 	ttl, _ := d.GetTTLForService(service)
@@ -1104,6 +1205,26 @@ func ednsSubnetForRequest(req *dns.Msg) *dns.EDNS0_SUBNET {
 	return nil
 }
 
+// dnsSourceIP returns the IP address of the client a request was made on
+// behalf of. An EDNS client subnet option takes precedence over the remote
+// address, unless it has a zero source prefix length which per RFC 7871 means
+// the client asked for its address not to be used.
+func dnsSourceIP(req *dns.Msg, remoteAddr net.Addr) string {
+	if subnet := ednsSubnetForRequest(req); subnet != nil && subnet.SourceNetmask > 0 && subnet.Address != nil {
+		return subnet.Address.String()
+	}
+
+	switch v := remoteAddr.(type) {
+	case *net.UDPAddr:
+		return v.IP.String()
+	case *net.TCPAddr:
+		return v.IP.String()
+	case *net.IPAddr:
+		return v.IP.String()
+	}
+	return ""
+}
+
 // preparedQueryLookup is used to handle a prepared query.
 func (d *DNSServer) preparedQueryLookup(network, datacenter, query string, remoteAddr net.Addr, req, resp *dns.Msg, maxRecursionLevel int) {
 	// Execute the prepared query.
@@ -1126,20 +1247,9 @@ func (d *DNSServer) preparedQueryLookup(network, datacenter, query string, remot
 		},
 	}
 
-	subnet := ednsSubnetForRequest(req)
-
-	if subnet != nil {
-		args.Source.Ip = subnet.Address.String()
-	} else {
-		switch v := remoteAddr.(type) {
-		case *net.UDPAddr:
-			args.Source.Ip = v.IP.String()
-		case *net.TCPAddr:
-			args.Source.Ip = v.IP.String()
-		case *net.IPAddr:
-			args.Source.Ip = v.IP.String()
-		}
-	}
+	// Recursive resolvers can convey the true client through the EDNS
+	// client subnet option, otherwise we fall back to the peer address.
+	args.Source.Ip = dnsSourceIP(req, remoteAddr)
 
 	// TODO (slackpad) - What's a safe limit we can set here? It seems like
 	// with dup filtering done at this level we need to get everything to
@@ -1199,6 +1309,9 @@ RPC:
 		return
 	}
 
+	// Nodes in drain mode go last, in case the answer gets trimmed.
+	out.Nodes.SortDrainedLast()
+
 	// Add various responses depending on the request.
 	qType := req.Question[0].Qtype
 	if qType == dns.TypeSRV {
@@ -1285,6 +1398,11 @@ func (d *DNSServer) serviceNodeRecords(dc string, nodes structs.CheckServiceNode
 }
 
 func findWeight(node structs.CheckServiceNode) int {
+	// Nodes in drain mode get no new connections if clients honor weights
+	if node.Drained() {
+		return 0
+	}
+
 	// By default, when only_passing is false, warning and passing nodes are returned
 	// Those values will be used if using a client with support while server has no
 	// support for weights
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// dnsForwardingFormats are the resolvers dnsForwardingConfig can write
+// configuration for.
+var dnsForwardingFormats = []string{"bind", "dnsmasq", "systemd-resolved"}
+
+// dnsForwardingTargets returns the addresses a resolver on this host should
+// forward Consul queries to, given the addresses the DNS server listens on.
+// Wildcard addresses are replaced with the loopback address, and the TCP and
+// UDP listeners on the same address are only returned once.
+func dnsForwardingTargets(addrs []net.Addr) []*net.UDPAddr {
+	var targets []*net.UDPAddr
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		var ip net.IP
+		var port int
+		switch a := addr.(type) {
+		case *net.UDPAddr:
+			ip, port = a.IP, a.Port
+		case *net.TCPAddr:
+			ip, port = a.IP, a.Port
+		default:
+			continue
+		}
+
+		if ip.IsUnspecified() {
+			if ip.To4() != nil {
+				ip = net.IPv4(127, 0, 0, 1)
+			} else {
+				ip = net.IPv6loopback
+			}
+		}
+
+		target := &net.UDPAddr{IP: ip, Port: port}
+		if seen[target.String()] {
+			continue
+		}
+		seen[target.String()] = true
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// dnsForwardingConfig returns a snippet of configuration for the given
+// resolver that forwards the queries for the Consul domain to the agent's DNS
+// server.
+func dnsForwardingConfig(format, domain string, addrs []net.Addr) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	targets := dnsForwardingTargets(addrs)
+	if len(targets) == 0 {
+		return "", fmt.Errorf("DNS interface is disabled")
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "bind":
+		fmt.Fprintf(&buf, "# Add to named.conf. Consul doesn't sign its responses, so DNSSEC\n")
+		fmt.Fprintf(&buf, "# validation must be disabled with \"dnssec-validation no;\".\n")
+		fmt.Fprintf(&buf, "zone %q IN {\n", domain)
+		fmt.Fprintf(&buf, "  type forward;\n")
+		fmt.Fprintf(&buf, "  forward only;\n")
+		fmt.Fprintf(&buf, "  forwarders {")
+		for _, t := range targets {
+			fmt.Fprintf(&buf, " %s port %d;", t.IP, t.Port)
+		}
+		fmt.Fprintf(&buf, " };\n")
+		fmt.Fprintf(&buf, "};\n")
+
+	case "dnsmasq":
+		fmt.Fprintf(&buf, "# Add to a file in /etc/dnsmasq.d.\n")
+		for _, t := range targets {
+			fmt.Fprintf(&buf, "server=/%s/%s#%d\n", domain, t.IP, t.Port)
+		}
+
+	case "systemd-resolved":
+		fmt.Fprintf(&buf, "# Add to a file in /etc/systemd/resolved.conf.d. DNS servers on a port\n")
+		fmt.Fprintf(&buf, "# other than 53 need systemd 246 or later.\n")
+		fmt.Fprintf(&buf, "[Resolve]\n")
+		var servers []string
+		for _, t := range targets {
+			servers = append(servers, net.JoinHostPort(t.IP.String(), strconv.Itoa(t.Port)))
+		}
+		fmt.Fprintf(&buf, "DNS=%s\n", strings.Join(servers, " "))
+		fmt.Fprintf(&buf, "DNSSEC=false\n")
+		fmt.Fprintf(&buf, "Domains=~%s\n", domain)
+
+	default:
+		return "", fmt.Errorf("Unknown format %q, must be one of %s", format, strings.Join(dnsForwardingFormats, ", "))
+	}
+	return buf.String(), nil
+}
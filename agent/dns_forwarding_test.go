@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSForwardingConfig(t *testing.T) {
+	t.Parallel()
+
+	addrs := []net.Addr{
+		&net.TCPAddr{IP: net.ParseIP("0.0.0.0"), Port: 8600},
+		&net.UDPAddr{IP: net.ParseIP("0.0.0.0"), Port: 8600},
+		&net.UDPAddr{IP: net.ParseIP("::"), Port: 8600},
+		&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 53},
+	}
+
+	out, err := dnsForwardingConfig("bind", "consul.", addrs)
+	require.NoError(t, err)
+	require.Contains(t, out, `zone "consul" IN {`)
+	require.Contains(t, out, "forwarders { 127.0.0.1 port 8600; ::1 port 8600; 10.0.0.1 port 53; };")
+
+	out, err = dnsForwardingConfig("dnsmasq", "consul.", addrs)
+	require.NoError(t, err)
+	require.Contains(t, out, "server=/consul/127.0.0.1#8600\nserver=/consul/::1#8600\nserver=/consul/10.0.0.1#53\n")
+
+	out, err = dnsForwardingConfig("systemd-resolved", "consul.", addrs)
+	require.NoError(t, err)
+	require.Contains(t, out, "DNS=127.0.0.1:8600 [::1]:8600 10.0.0.1:53\n")
+	require.Contains(t, out, "Domains=~consul\n")
+
+	_, err = dnsForwardingConfig("unbound", "consul.", addrs)
+	require.Error(t, err)
+
+	_, err = dnsForwardingConfig("dnsmasq", "consul.", nil)
+	require.Error(t, err)
+}
+
+func TestAgent_DNSForwarding(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `domain = "example."`)
+	defer a.Shutdown()
+
+	req, _ := http.NewRequest("GET", "/v1/agent/dns-forwarding?format=dnsmasq", nil)
+	resp := httptest.NewRecorder()
+	obj, err := a.srv.AgentDNSForwarding(resp, req)
+	require.NoError(t, err)
+	require.Nil(t, obj)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Contains(t, resp.Body.String(), "server=/example/127.0.0.1#")
+
+	req, _ = http.NewRequest("GET", "/v1/agent/dns-forwarding", nil)
+	resp = httptest.NewRecorder()
+	_, err = a.srv.AgentDNSForwarding(resp, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+}
@@ -9,12 +9,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul/agent/cache"
+	cachetype "github.com/hashicorp/consul/agent/cache-types"
 	"github.com/hashicorp/consul/agent/config"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/testrpc"
 	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/serf/coordinate"
 	"github.com/miekg/dns"
 	"github.com/pascaldekloe/goe/verify"
@@ -109,6 +112,14 @@ func TestRecursorAddr(t *testing.T) {
 	if addr != "8.8.8.8:53" {
 		t.Fatalf("bad: %v", addr)
 	}
+
+	addr, err = recursorAddr("2001:4860:4860::8888")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if addr != "[2001:4860:4860::8888]:53" {
+		t.Fatalf("bad: %v", addr)
+	}
 }
 
 func TestEncodeKVasRFC1464(t *testing.T) {
@@ -383,6 +394,52 @@ func TestDNS_NodeLookup_AAAA(t *testing.T) {
 	}
 }
 
+func TestDNS_NodeLookup_DualStack(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	// Register a node with an IPv4 address, and an IPv6 one as well
+	args := &structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "bar",
+		Address:    "127.0.0.2",
+		TaggedAddresses: map[string]string{
+			"lan_ipv6": "::4242:4242",
+		},
+	}
+
+	var out struct{}
+	if err := a.RPC("Catalog.Register", args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	lookup := func(qType uint16) []dns.RR {
+		m := new(dns.Msg)
+		m.SetQuestion("bar.node.consul.", qType)
+
+		c := new(dns.Client)
+		in, _, err := c.Exchange(m, a.DNSAddr())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(in.Answer) != 1 {
+			t.Fatalf("Bad: %#v", in)
+		}
+		return in.Answer
+	}
+
+	aRec, ok := lookup(dns.TypeA)[0].(*dns.A)
+	if !ok || aRec.A.String() != "127.0.0.2" {
+		t.Fatalf("Bad: %#v", aRec)
+	}
+	aaaaRec, ok := lookup(dns.TypeAAAA)[0].(*dns.AAAA)
+	if !ok || aaaaRec.AAAA.String() != "::4242:4242" {
+		t.Fatalf("Bad: %#v", aaaaRec)
+	}
+}
+
 func TestDNSCycleRecursorCheck(t *testing.T) {
 	t.Parallel()
 	// Start a DNS recursor that returns a SERVFAIL
@@ -715,6 +772,55 @@ func TestDNS_NodeLookup_A_SuppressTXT(t *testing.T) {
 	require.Len(t, in.Extra, 0)
 }
 
+func TestDNS_NodeLookup_TaggedAddress(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `dns_config = { node_tagged_address = "wan" }`)
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	for _, args := range []*structs.RegisterRequest{
+		{
+			Datacenter:      "dc1",
+			Node:            "foo",
+			Address:         "127.0.0.1",
+			TaggedAddresses: map[string]string{"wan": "127.0.0.2"},
+			NodeMeta:        map[string]string{"key": "value"},
+		},
+		{
+			Datacenter: "dc1",
+			Node:       "bar",
+			Address:    "127.0.0.3",
+		},
+	} {
+		var out struct{}
+		require.NoError(t, a.RPC("Catalog.Register", args, &out))
+	}
+
+	// Nodes with the tagged address are answered with it, and the others
+	// with their address.
+	for node, want := range map[string]string{"foo": "127.0.0.2", "bar": "127.0.0.3"} {
+		m := new(dns.Msg)
+		m.SetQuestion(node+".node.consul.", dns.TypeA)
+
+		c := new(dns.Client)
+		in, _, err := c.Exchange(m, a.DNSAddr())
+		require.NoError(t, err)
+		require.Len(t, in.Answer, 1)
+		require.Equal(t, want, in.Answer[0].(*dns.A).A.String())
+	}
+
+	// The node metadata is still available as TXT records.
+	m := new(dns.Msg)
+	m.SetQuestion("foo.node.consul.", dns.TypeANY)
+
+	c := new(dns.Client)
+	in, _, err := c.Exchange(m, a.DNSAddr())
+	require.NoError(t, err)
+	require.Len(t, in.Answer, 2)
+	require.Equal(t, "127.0.0.2", in.Answer[0].(*dns.A).A.String())
+	require.Equal(t, []string{"key=value"}, in.Answer[1].(*dns.TXT).Txt)
+}
+
 func TestDNS_EDNS0(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -1383,6 +1489,91 @@ func TestDNS_ServiceLookupMultiAddrNoCNAME(t *testing.T) {
 	require.IsType(t, &dns.A{}, in.Answer[1])
 }
 
+// failingHealthServices is a health services cache type which answers with a
+// fixed result until fail is closed, and then errors as if the servers can't
+// be reached.
+type failingHealthServices struct {
+	reply *structs.IndexedCheckServiceNodes
+	fail  chan struct{}
+}
+
+func (f *failingHealthServices) Fetch(opts cache.FetchOptions, req cache.Request) (cache.FetchResult, error) {
+	if opts.MinIndex > 0 {
+		select {
+		case <-f.fail:
+		case <-time.After(opts.Timeout):
+		}
+	}
+	select {
+	case <-f.fail:
+		return cache.FetchResult{}, fmt.Errorf("no servers")
+	default:
+		return cache.FetchResult{Value: f.reply, Index: f.reply.Index}, nil
+	}
+}
+
+func (f *failingHealthServices) SupportsBlocking() bool {
+	return true
+}
+
+func TestDNS_ServiceLookup_UseCache(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `
+		dns_config {
+			use_cache = true
+			cache_max_age = "10ms"
+		}
+	`)
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	typ := &failingHealthServices{
+		reply: &structs.IndexedCheckServiceNodes{
+			Nodes: structs.CheckServiceNodes{
+				{
+					Node:    &structs.Node{Node: "foo", Address: "127.0.0.1"},
+					Service: &structs.NodeService{Service: "db", Port: 12345},
+				},
+			},
+			QueryMeta: structs.QueryMeta{Index: 1},
+		},
+		fail: make(chan struct{}),
+	}
+	a.cache.RegisterType(cachetype.HealthServicesName, typ, &cache.RegisterOptions{
+		Refresh:        true,
+		RefreshTimeout: 10 * time.Minute,
+	})
+
+	lookup := func() {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion("db.service.consul.", dns.TypeSRV)
+		c := new(dns.Client)
+		in, _, err := c.Exchange(m, a.DNSAddr())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(in.Answer) != 1 {
+			t.Fatalf("Bad: %#v", in)
+		}
+		srvRec, ok := in.Answer[0].(*dns.SRV)
+		if !ok {
+			t.Fatalf("Bad: %#v", in.Answer[0])
+		}
+		if srvRec.Port != 12345 || srvRec.Target != "foo.node.dc1.consul." {
+			t.Fatalf("Bad: %#v", srvRec)
+		}
+	}
+	lookup()
+
+	// Once the servers can't be reached the cached results keep being
+	// served, even after they are older than the max age.
+	close(typ.fail)
+	time.Sleep(50 * time.Millisecond)
+	lookup()
+	lookup()
+}
+
 func TestDNS_ServiceLookup(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -2802,6 +2993,48 @@ func TestDNS_PreparedQueryNearIPEDNS(t *testing.T) {
 	})
 }
 
+func TestDNS_dnsSourceIP(t *testing.T) {
+	t.Parallel()
+
+	withSubnet := func(netmask uint8, addr net.IP) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("some.query.we.like.query.consul.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		e := new(dns.EDNS0_SUBNET)
+		e.Code = dns.EDNS0SUBNET
+		e.Family = 1
+		e.SourceNetmask = netmask
+		e.Address = addr
+		o := m.IsEdns0()
+		o.Option = append(o.Option, e)
+		return m
+	}
+	plain := new(dns.Msg)
+	plain.SetQuestion("some.query.we.like.query.consul.", dns.TypeA)
+
+	udp := &net.UDPAddr{IP: net.ParseIP("198.18.0.1"), Port: 5353}
+	tcp := &net.TCPAddr{IP: net.ParseIP("198.18.0.2"), Port: 5353}
+
+	cases := []struct {
+		name string
+		req  *dns.Msg
+		addr net.Addr
+		want string
+	}{
+		{"udp peer", plain, udp, "198.18.0.1"},
+		{"tcp peer", plain, tcp, "198.18.0.2"},
+		{"ecs", withSubnet(24, net.ParseIP("198.18.1.0").To4()), udp, "198.18.1.0"},
+		{"ecs zero prefix", withSubnet(0, net.ParseIP("0.0.0.0").To4()), udp, "198.18.0.1"},
+		{"ecs no address", withSubnet(24, nil), tcp, "198.18.0.2"},
+		{"no peer", plain, nil, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, dnsSourceIP(tc.req, tc.addr))
+		})
+	}
+}
+
 func TestDNS_PreparedQueryNearIP(t *testing.T) {
 	ipCoord := lib.GenerateCoordinate(1 * time.Millisecond)
 	serviceNodes := []struct {
@@ -3231,6 +3464,64 @@ func TestDNS_ServiceLookup_Dedup_SRV(t *testing.T) {
 	}
 }
 
+func TestDNS_ServiceLookup_FilterCritical_Instance(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	// Register two instances of a service on the same node, each with its
+	// own check, where only the second instance is failing.
+	for i, status := range []string{api.HealthPassing, api.HealthCritical} {
+		id := fmt.Sprintf("db%d", i+1)
+		args := &structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      id,
+				Service: "db",
+				Port:    12345 + i,
+			},
+			Check: &structs.HealthCheck{
+				CheckID:   types.CheckID(id),
+				Name:      id,
+				ServiceID: id,
+				Status:    status,
+			},
+		}
+
+		var out struct{}
+		if err := a.RPC("Catalog.Register", args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Only the healthy instance should be returned, with its own port.
+	m := new(dns.Msg)
+	m.SetQuestion("db.service.consul.", dns.TypeSRV)
+
+	c := new(dns.Client)
+	in, _, err := c.Exchange(m, a.DNSAddr())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(in.Answer) != 1 {
+		t.Fatalf("Bad: %#v", in)
+	}
+	srvRec, ok := in.Answer[0].(*dns.SRV)
+	if !ok {
+		t.Fatalf("Bad: %#v", in.Answer[0])
+	}
+	if srvRec.Port != 12345 {
+		t.Fatalf("Bad: %#v", srvRec)
+	}
+	if srvRec.Target != "foo.node.dc1.consul." {
+		t.Fatalf("Bad: %#v", srvRec)
+	}
+}
+
 func TestDNS_Recurse(t *testing.T) {
 	t.Parallel()
 	recursor := makeRecursor(t, dns.Msg{
@@ -5063,6 +5354,67 @@ func TestDNS_ServiceLookup_SRV_RFC(t *testing.T) {
 
 }
 
+func TestDNS_ServiceLookup_Drained(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	// Register a few nodes, and put the first one into drain mode
+	for i := 0; i < 3; i++ {
+		args := &structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       fmt.Sprintf("foo%d", i),
+			Address:    fmt.Sprintf("127.0.0.%d", i+1),
+			Service: &structs.NodeService{
+				Service: "db",
+				Port:    12345,
+			},
+		}
+		if i == 0 {
+			args.Check = &structs.HealthCheck{
+				CheckID: structs.NodeDrain,
+				Name:    "Node Drain Mode",
+				Status:  api.HealthPassing,
+			}
+		}
+
+		var out struct{}
+		if err := a.RPC("Catalog.Register", args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The drained node is still answered, but always last and with a
+	// weight of zero
+	for i := 0; i < 10; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("db.service.consul.", dns.TypeSRV)
+
+		c := new(dns.Client)
+		in, _, err := c.Exchange(m, a.DNSAddr())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(in.Answer) != 3 {
+			t.Fatalf("Bad: %#v", in)
+		}
+		for j, rr := range in.Answer {
+			srvRec, ok := rr.(*dns.SRV)
+			if !ok {
+				t.Fatalf("Bad: %#v", rr)
+			}
+			drained := srvRec.Target == "foo0.node.dc1.consul."
+			if drained != (j == 2) {
+				t.Fatalf("Bad: %#v", in.Answer)
+			}
+			if drained && srvRec.Weight != 0 || !drained && srvRec.Weight != 1 {
+				t.Fatalf("Bad: %#v", srvRec)
+			}
+		}
+	}
+}
+
 func TestDNS_ServiceLookup_SRV_RFC_TCP_Default(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
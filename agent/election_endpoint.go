@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// ElectionEndpoint handles the GET, PUT and DELETE operations on an election
+// at /v1/election/:name.
+func (s *HTTPServer) ElectionEndpoint(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	name := strings.TrimPrefix(req.URL.Path, "/v1/election/")
+	if name == "" {
+		return nil, BadRequestError{Reason: "Missing election name"}
+	}
+
+	switch req.Method {
+	case "GET":
+		return s.electionGet(name, resp, req)
+	case "PUT":
+		return s.electionApply(structs.ElectionCampaign, name, resp, req)
+	case "DELETE":
+		return s.electionApply(structs.ElectionResign, name, resp, req)
+	default:
+		return nil, MethodNotAllowedError{req.Method, []string{"GET", "PUT", "DELETE"}}
+	}
+}
+
+// GET /v1/election/:name
+func (s *HTTPServer) electionGet(name string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ElectionSpecificRequest{
+		Name: name,
+	}
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedElection
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("Election.Get", &args, &reply); err != nil {
+		if strings.Contains(err.Error(), "election name") || strings.Contains(err.Error(), "Election names") {
+			return nil, BadRequestError{Reason: err.Error()}
+		}
+		return nil, err
+	}
+	return reply.Election, nil
+}
+
+// PUT and DELETE /v1/election/:name
+func (s *HTTPServer) electionApply(op structs.ElectionOp, name string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ElectionRequest{
+		Op:      op,
+		Name:    name,
+		Session: req.URL.Query().Get("session"),
+	}
+	s.parseDC(req, &args.Datacenter)
+	s.parseToken(req, &args.Token)
+
+	if op == structs.ElectionCampaign {
+		// The value is limited in the same way as KV values, since it
+		// ends up stored in the KV store.
+		if req.ContentLength > maxKVSize {
+			resp.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprintf(resp, "Value exceeds %d byte limit", maxKVSize)
+			return nil, nil
+		}
+		buf := bytes.NewBuffer(nil)
+		if _, err := io.Copy(buf, req.Body); err != nil {
+			return nil, err
+		}
+		args.Value = buf.Bytes()
+	}
+
+	var leader bool
+	if err := s.agent.RPC("Election.Apply", &args, &leader); err != nil {
+		if strings.Contains(err.Error(), "Must provide") || strings.Contains(err.Error(), "Election names") ||
+			strings.Contains(err.Error(), "Invalid session") {
+			return nil, BadRequestError{Reason: err.Error()}
+		}
+		return nil, err
+	}
+
+	// Resigning always succeeds, so only campaigns say whether the
+	// session is the leader.
+	if op == structs.ElectionResign {
+		return true, nil
+	}
+	return leader, nil
+}
@@ -157,6 +157,7 @@ func (s *HTTPServer) healthServiceNodes(resp http.ResponseWriter, req *http.Requ
 	args := structs.ServiceSpecificRequest{Connect: connect}
 	s.parseSource(req, &args.Source)
 	args.NodeMetaFilters = s.parseMetaFilter(req)
+	args.MultiDC = s.parseMultiDC(req)
 	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
 		return nil, nil
 	}
@@ -182,6 +183,54 @@ func (s *HTTPServer) healthServiceNodes(resp http.ResponseWriter, req *http.Requ
 		return nil, nil
 	}
 
+	// Signed responses are signed by the servers of a single datacenter.
+	args.Sign = parseSigned(req)
+	if args.Sign && len(args.MultiDC) > 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Signed responses can't span several datacenters")
+		return nil, nil
+	}
+
+	// Check for the passing filter
+	var filter bool
+	if _, ok := params[api.HealthPassing]; ok {
+		val := params.Get(api.HealthPassing)
+		// Backwards-compat to allow users to specify ?passing without a value. This
+		// should be removed in Consul 0.10.
+		if val == "" {
+			filter = true
+		} else {
+			var err error
+			filter, err = strconv.ParseBool(val)
+			if err != nil {
+				resp.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(resp, "Invalid value for ?passing")
+				return nil, nil
+			}
+		}
+	}
+
+	// The servers filter the nodes before sorting and limiting them, so
+	// with ?near and ?max_results this returns the nearest passing nodes.
+	args.OnlyPassing = filter
+
+	// Check for the summary flag, which returns the aggregated status of
+	// each node's checks instead of the checks.
+	if _, ok := params["summary"]; ok {
+		val := params.Get("summary")
+		if val == "" {
+			args.SummarizeChecks = true
+		} else {
+			var err error
+			args.SummarizeChecks, err = strconv.ParseBool(val)
+			if err != nil {
+				resp.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(resp, "Invalid value for ?summary")
+				return nil, nil
+			}
+		}
+	}
+
 	// Make the RPC request
 	var out structs.IndexedCheckServiceNodes
 	defer setMeta(resp, &out.QueryMeta)
@@ -189,7 +238,11 @@ func (s *HTTPServer) healthServiceNodes(resp http.ResponseWriter, req *http.Requ
 	if args.QueryOptions.UseCache {
 		raw, m, err := s.agent.cache.Get(cachetype.HealthServicesName, &args)
 		if err != nil {
-			return nil, err
+			// Don't return error if StaleIfError is set and we are within it and had
+			// a cached value.
+			if raw == nil || !m.Hit || args.QueryOptions.StaleIfError <= m.Age {
+				return nil, err
+			}
 		}
 		defer setCacheMeta(resp, &m)
 		reply, ok := raw.(*structs.IndexedCheckServiceNodes)
@@ -211,31 +264,43 @@ func (s *HTTPServer) healthServiceNodes(resp http.ResponseWriter, req *http.Requ
 	}
 	out.ConsistencyLevel = args.QueryOptions.ConsistencyLevel()
 
-	// Filter to only passing if specified
-	if _, ok := params[api.HealthPassing]; ok {
-		val := params.Get(api.HealthPassing)
-		// Backwards-compat to allow users to specify ?passing without a value. This
-		// should be removed in Consul 0.10.
-		var filter bool
-		if val == "" {
-			filter = true
-		} else {
-			var err error
-			filter, err = strconv.ParseBool(val)
-			if err != nil {
-				resp.WriteHeader(http.StatusBadRequest)
-				fmt.Fprint(resp, "Invalid value for ?passing")
-				return nil, nil
-			}
-		}
+	// Let the client know if the nodes came from another datacenter because
+	// of the service's failover policy.
+	dc := args.Datacenter
+	if out.FailoverDatacenter != "" {
+		dc = out.FailoverDatacenter
+		resp.Header().Set("X-Consul-Failover-Datacenter", dc)
+	}
 
-		if filter {
-			out.Nodes = filterNonPassing(out.Nodes)
-		}
+	// The payload the servers signed is returned as-is, since they have
+	// already filtered and summarized the nodes, so its addresses aren't
+	// translated.
+	if args.Sign {
+		return nil, writeSignedResponse(resp, &out.QueryMeta, out.Signature)
 	}
 
-	// Translate addresses after filtering so we don't waste effort.
-	s.agent.TranslateAddresses(args.Datacenter, out.Nodes)
+	// Filter to only passing if specified. The servers have done this
+	// already, unless they are too old to know how.
+	if filter {
+		out.Nodes = filterNonPassing(out.Nodes)
+	}
+
+	// Summarize the checks if the servers didn't, because they're too old
+	// to know how.
+	if args.SummarizeChecks {
+		out.Nodes.SummarizeChecks()
+	}
+
+	// Translate addresses after filtering so we don't waste effort. Nodes
+	// from several datacenters are translated for the one they came from.
+	if len(args.MultiDC) > 0 {
+		for i := range out.Nodes {
+			s.agent.TranslateAddresses(out.Nodes[i].Node.Datacenter, out.Nodes[i:i+1])
+		}
+	} else {
+		s.agent.TranslateAddresses(dc, out.Nodes)
+	}
+	setAddressFamilies(out.Nodes)
 
 	// Use empty list instead of nil
 	if out.Nodes == nil {
@@ -659,6 +659,79 @@ func TestHealthServiceNodes_DistanceSort(t *testing.T) {
 	})
 }
 
+func TestHealthServiceNodes_MaxResults(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	// Register three passing instances and a critical one, which sorts
+	// first by node name.
+	for _, node := range []string{"a-critical", "b", "c", "d"} {
+		status := api.HealthPassing
+		if node == "a-critical" {
+			status = api.HealthCritical
+		}
+		args := &structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "web",
+				Service: "web",
+			},
+			Check: &structs.HealthCheck{
+				Name:      "web check",
+				ServiceID: "web",
+				Status:    status,
+			},
+		}
+		var out struct{}
+		if err := a.RPC("Catalog.Register", args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	cases := []struct {
+		query     string
+		nodes     []string
+		truncated bool
+	}{
+		{"max_results=2", []string{"a-critical", "b"}, true},
+		{"max_results=4", []string{"a-critical", "b", "c", "d"}, false},
+		// The limit applies to the passing instances, which the filter
+		// doesn't keep in order.
+		{"passing&max_results=2", nil, true},
+		{"passing&max_results=3", []string{"b", "c", "d"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/v1/health/service/web?"+tc.query, nil)
+			resp := httptest.NewRecorder()
+			obj, err := a.srv.HealthServiceNodes(resp, req)
+			require.NoError(t, err)
+
+			var names []string
+			for _, node := range obj.(structs.CheckServiceNodes) {
+				names = append(names, node.Node.Node)
+			}
+			if tc.nodes != nil {
+				require.ElementsMatch(t, tc.nodes, names)
+			} else {
+				require.Len(t, names, 2)
+				require.NotContains(t, names, "a-critical")
+			}
+			require.Equal(t, tc.truncated, resp.Header().Get("X-Consul-Results-Truncated") == "true")
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/health/service/web?max_results=0", nil)
+	resp := httptest.NewRecorder()
+	_, err := a.srv.HealthServiceNodes(resp, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
 func TestHealthServiceNodes_PassingFilter(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -755,6 +828,61 @@ func TestHealthServiceNodes_PassingFilter(t *testing.T) {
 	})
 }
 
+func TestHealthServiceNodes_Summary(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	dc := "dc1"
+	// Create a warning service check
+	args := &structs.RegisterRequest{
+		Datacenter: dc,
+		Node:       a.Config.NodeName,
+		Address:    "127.0.0.1",
+		Check: &structs.HealthCheck{
+			Node:      a.Config.NodeName,
+			Name:      "consul check",
+			ServiceID: "consul",
+			Status:    api.HealthWarning,
+			Output:    "lots of output",
+		},
+	}
+
+	testrpc.WaitForLeader(t, a.RPC, dc)
+	var out struct{}
+	if err := a.RPC("Catalog.Register", args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/health/service/consul?summary", nil)
+	resp := httptest.NewRecorder()
+	obj, err := a.srv.HealthServiceNodes(resp, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	assertIndex(t, resp)
+
+	nodes := obj.(structs.CheckServiceNodes)
+	if len(nodes) != 1 {
+		t.Fatalf("bad: %v", obj)
+	}
+	if len(nodes[0].Checks) != 0 {
+		t.Fatalf("bad: %v", nodes[0].Checks)
+	}
+	if nodes[0].Status != api.HealthWarning {
+		t.Fatalf("bad: %v", nodes[0].Status)
+	}
+
+	req, _ = http.NewRequest("GET", "/v1/health/service/consul?summary=nope", nil)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.HealthServiceNodes(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 400 {
+		t.Fatalf("bad: %v", resp.Code)
+	}
+}
+
 func TestHealthServiceNodes_WanTranslation(t *testing.T) {
 	t.Parallel()
 	a1 := NewTestAgent(t.Name(), `
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/agent/local"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/types"
+	"github.com/hashicorp/consul/watch"
+)
+
+const (
+	// HookServiceRegister is the hook event for a service being registered
+	// with the agent, or an existing registration changing.
+	HookServiceRegister = "service_register"
+
+	// HookServiceDeregister is the hook event for a service being removed
+	// from the agent.
+	HookServiceDeregister = "service_deregister"
+
+	// HookCheckUpdate is the hook event for the status of one of the agent's
+	// checks changing.
+	HookCheckUpdate = "check_update"
+)
+
+// hookQueueSize is the number of hook runs that can be waiting for the worker
+// before new ones are dropped.
+const hookQueueSize = 256
+
+// HookEvent is passed to hooks as JSON, on stdin for scripts and as the
+// request body for HTTP hooks.
+type HookEvent struct {
+	Event   string
+	Node    string
+	Service *structs.NodeService `json:",omitempty"`
+	Check   *structs.HealthCheck `json:",omitempty"`
+}
+
+// hookJob is a single run of a hook, waiting in the queue for the worker.
+type hookJob struct {
+	fn    watch.HandlerFunc
+	index uint64
+	event *HookEvent
+}
+
+// hookRunner runs the configured hooks when the agent's local services or
+// check statuses change. Rather than being called from every place that
+// changes the local state, it compares the local state each time it is
+// notified of a change, so services that are removed and added straight back
+// during a config reload don't run any hooks.
+type hookRunner struct {
+	node      string
+	state     *local.State
+	logOutput io.Writer
+	logger    *log.Logger
+
+	// notifyCh is registered with the local state for change notifications.
+	notifyCh chan struct{}
+
+	// queueCh holds the hook runs waiting for the worker. Hooks are run one
+	// at a time, so a slow hook can't pile up goroutines.
+	queueCh chan hookJob
+
+	// lock protects the fields below, and is held by the agent for the whole
+	// of a config reload.
+	lock     sync.Mutex
+	handlers map[string][]watch.HandlerFunc
+	index    uint64
+	services map[string]*structs.NodeService
+	checks   map[types.CheckID]string
+}
+
+// newHookRunner returns a hook runner that takes the current local state as
+// its starting point, so changes made before Run is called still run hooks.
+func newHookRunner(node string, state *local.State, logOutput io.Writer, logger *log.Logger) *hookRunner {
+	h := &hookRunner{
+		node:      node,
+		state:     state,
+		logOutput: logOutput,
+		logger:    logger,
+		notifyCh:  make(chan struct{}, 1),
+		queueCh:   make(chan hookJob, hookQueueSize),
+		services:  state.Services(),
+		checks:    make(map[types.CheckID]string),
+	}
+	for id, check := range state.Checks() {
+		h.checks[id] = check.Status
+	}
+	state.Notify(h.notifyCh)
+	return h
+}
+
+// setHooks replaces the hooks that are run. It must be called with the lock
+// held.
+func (h *hookRunner) setHooks(hooks []config.RuntimeHook) {
+	h.handlers = make(map[string][]watch.HandlerFunc)
+	for _, hook := range hooks {
+		var fn watch.HandlerFunc
+		if len(hook.Args) > 0 {
+			fn = makeWatchHandler(h.logOutput, hook.Args)
+		} else {
+			fn = makeHTTPWatchHandler(h.logOutput, &watch.HttpHandlerConfig{
+				Path:          hook.HTTP,
+				Method:        hook.Method,
+				Timeout:       hook.Timeout,
+				Header:        hook.Header,
+				TLSSkipVerify: hook.TLSSkipVerify,
+			})
+		}
+		for _, event := range hook.Events {
+			h.handlers[event] = append(h.handlers[event], fn)
+		}
+	}
+}
+
+// Run runs hooks for changes to the local state until stopCh is closed.
+func (h *hookRunner) Run(stopCh <-chan struct{}) {
+	defer h.state.StopNotify(h.notifyCh)
+	go h.work(stopCh)
+	for {
+		select {
+		case <-h.notifyCh:
+			h.sync()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// sync compares the local state with what it was last time and runs hooks
+// for any differences.
+func (h *hookRunner) sync() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	services := h.state.Services()
+	for id, svc := range services {
+		if old, ok := h.services[id]; !ok || !old.IsSame(svc) {
+			h.fire(HookEvent{Event: HookServiceRegister, Service: svc})
+		}
+	}
+	for id, svc := range h.services {
+		if _, ok := services[id]; !ok {
+			h.fire(HookEvent{Event: HookServiceDeregister, Service: svc})
+		}
+	}
+	h.services = services
+
+	checks := make(map[types.CheckID]string)
+	for id, check := range h.state.Checks() {
+		checks[id] = check.Status
+		if old, ok := h.checks[id]; ok && old != check.Status {
+			h.fire(HookEvent{Event: HookCheckUpdate, Check: check})
+		}
+	}
+	h.checks = checks
+}
+
+// work runs queued hooks in order until stopCh is closed.
+func (h *hookRunner) work(stopCh <-chan struct{}) {
+	for {
+		select {
+		case job := <-h.queueCh:
+			job.fn(job.index, job.event)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// fire queues the hooks for the event to be run by the worker. Each run gets
+// the next index, so handlers can tell if any were dropped because the queue
+// was full. It must be called with the lock held.
+func (h *hookRunner) fire(event HookEvent) {
+	event.Node = h.node
+	for _, fn := range h.handlers[event.Event] {
+		h.index++
+		select {
+		case h.queueCh <- hookJob{fn: fn, index: h.index, event: &event}:
+		default:
+			h.logger.Printf("[WARN] agent: Hook queue is full, dropping %s hook", event.Event)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/consul/watch"
+)
+
+func TestAgent_Hooks(t *testing.T) {
+	t.Parallel()
+	eventCh := make(chan HookEvent, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event HookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+		eventCh <- event
+	}))
+	defer server.Close()
+
+	a := NewTestAgent(t.Name(), fmt.Sprintf(`
+		hooks = [
+			{
+				events = ["service_register", "service_deregister", "check_update"]
+				http = "%s"
+			}
+		]
+	`, server.URL))
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// waitFor waits for the given event, skipping any others.
+	waitFor := func(event string) HookEvent {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case e := <-eventCh:
+				if e.Event == event {
+					if e.Node != a.Config.NodeName {
+						t.Fatalf("bad: %#v", e)
+					}
+					return e
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", event)
+			}
+		}
+	}
+
+	srv := &structs.NodeService{
+		ID:      "web",
+		Service: "web",
+		Port:    8000,
+	}
+	chkTypes := []*structs.CheckType{&structs.CheckType{TTL: time.Minute}}
+	if err := a.AddService(srv, chkTypes, false, "", ConfigSourceLocal); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if e := waitFor(HookServiceRegister); e.Service == nil || e.Service.ID != "web" {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	if err := a.updateTTLCheck("service:web", api.HealthPassing, "ok"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if e := waitFor(HookCheckUpdate); e.Check == nil || e.Check.CheckID != "service:web" || e.Check.Status != api.HealthPassing {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	if err := a.RemoveService("web", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if e := waitFor(HookServiceDeregister); e.Service == nil || e.Service.ID != "web" {
+		t.Fatalf("bad: %#v", e)
+	}
+}
+
+func TestAgent_Hooks_Reload(t *testing.T) {
+	t.Parallel()
+	eventCh := make(chan HookEvent, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event HookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+		eventCh <- event
+	}))
+	defer server.Close()
+
+	a := NewTestAgent(t.Name(), fmt.Sprintf(`
+		hooks = [
+			{
+				events = ["service_register", "service_deregister"]
+				http = "%s"
+			}
+		]
+		services = [
+			{
+				name = "web"
+				port = 8000
+			}
+		]
+	`, server.URL))
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// The service from the config is registered when the agent starts.
+	select {
+	case e := <-eventCh:
+		if e.Event != HookServiceRegister || e.Service == nil || e.Service.ID != "web" {
+			t.Fatalf("bad: %#v", e)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for hook")
+	}
+
+	// Reloading the same config shouldn't run any hooks.
+	if err := a.ReloadConfig(a.Config); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case e := <-eventCh:
+		t.Fatalf("unexpected hook: %#v", e)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestHookRunner_Queue(t *testing.T) {
+	t.Parallel()
+	h := &hookRunner{
+		node:    "node1",
+		logger:  log.New(os.Stderr, "", log.LstdFlags),
+		queueCh: make(chan hookJob, hookQueueSize),
+	}
+
+	// The hook blocks until released, so runs pile up in the queue.
+	var running, maxRunning int32
+	releaseCh := make(chan struct{})
+	var indexes []uint64
+	var lock sync.Mutex
+	h.handlers = map[string][]watch.HandlerFunc{
+		HookServiceRegister: {func(index uint64, _ interface{}) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			lock.Lock()
+			if n > maxRunning {
+				maxRunning = n
+			}
+			indexes = append(indexes, index)
+			lock.Unlock()
+			<-releaseCh
+		}},
+	}
+
+	// Fill the queue before starting the worker, so the extra runs are
+	// dropped rather than starting more goroutines.
+	for i := 0; i < hookQueueSize+10; i++ {
+		h.fire(HookEvent{Event: HookServiceRegister})
+	}
+	if got := len(h.queueCh); got != hookQueueSize {
+		t.Fatalf("bad: %d", got)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go h.work(stopCh)
+	close(releaseCh)
+	retry.Run(t, func(r *retry.R) {
+		lock.Lock()
+		defer lock.Unlock()
+		if len(indexes) != hookQueueSize {
+			r.Fatalf("bad: %d", len(indexes))
+		}
+	})
+
+	lock.Lock()
+	defer lock.Unlock()
+	if maxRunning != 1 {
+		t.Fatalf("bad: %d", maxRunning)
+	}
+	for i, index := range indexes {
+		if index != uint64(i+1) {
+			t.Fatalf("bad: %v", indexes)
+		}
+	}
+}
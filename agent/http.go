@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net"
 	"net/http"
 	"net/http/pprof"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -20,6 +22,8 @@ import (
 	"github.com/hashicorp/consul/agent/cache"
 	"github.com/hashicorp/consul/agent/structs"
 	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-msgpack/codec"
+	"github.com/hashicorp/go-uuid"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 )
@@ -56,9 +60,14 @@ func (e CodeWithPayloadError) Error() string {
 }
 
 type ForbiddenError struct {
+	// Reason is why access was denied, if there's more to say.
+	Reason string
 }
 
 func (e ForbiddenError) Error() string {
+	if e.Reason != "" {
+		return "Access is restricted: " + e.Reason
+	}
 	return "Access is restricted"
 }
 
@@ -69,6 +78,10 @@ type HTTPServer struct {
 	agent     *Agent
 	blacklist *Blacklist
 
+	// authorizer is the external authorizer for sensitive requests, or nil
+	// if there isn't one.
+	authorizer *httpAuthorizer
+
 	// proto is filled by the agent to "http" or "https".
 	proto string
 }
@@ -284,6 +297,10 @@ var (
 	aclEndpointRE = regexp.MustCompile("^(/v1/acl/(create|update|destroy|info|clone|list)/)([^?]+)([?]?.*)$")
 )
 
+// traceIDRE matches the trace IDs clients may send, which end up in the logs
+// of every agent the request goes through.
+var traceIDRE = regexp.MustCompile("^[A-Za-z0-9-]{1,64}$")
+
 // wrap is used to wrap functions to make them more convenient
 func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 	return func(resp http.ResponseWriter, req *http.Request) {
@@ -309,6 +326,25 @@ func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 		}
 		logURL = aclEndpointRE.ReplaceAllString(logURL, "$1<hidden>$4")
 
+		// Tag the request with a trace ID so it can be followed through the
+		// RPCs it makes. Callers can pass their own to tie it to their logs.
+		traceID := req.Header.Get(traceIDHeader)
+		if traceID != "" && !traceIDRE.MatchString(traceID) {
+			s.agent.logger.Printf("[ERR] http: Request %s %v, error: invalid trace ID from=%s", req.Method, logURL, req.RemoteAddr)
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(resp, "Invalid %s header: must be 1 to 64 letters, digits or dashes", traceIDHeader)
+			return
+		}
+		if traceID == "" {
+			if traceID, err = uuid.GenerateUUID(); err != nil {
+				s.agent.logger.Printf("[ERR] http: Failed to generate trace ID: %v from=%s", err, req.RemoteAddr)
+				resp.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			req.Header.Set(traceIDHeader, traceID)
+		}
+		resp.Header().Set(traceIDHeader, traceID)
+
 		if s.blacklist.Block(req.URL.Path) {
 			errMsg := "Endpoint is blocked by agent configuration"
 			s.agent.logger.Printf("[ERR] http: Request %s %v, error: %v from=%s", req.Method, logURL, err, req.RemoteAddr)
@@ -340,22 +376,35 @@ func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 		}
 
 		handleErr := func(err error) {
-			s.agent.logger.Printf("[ERR] http: Request %s %v, error: %v from=%s", req.Method, logURL, err, req.RemoteAddr)
+			s.agent.logger.Printf("[ERR] http: Request %s %v, error: %v from=%s trace_id=%s", req.Method, logURL, err, req.RemoteAddr, traceID)
 			switch {
 			case isForbidden(err):
 				resp.WriteHeader(http.StatusForbidden)
 				fmt.Fprint(resp, err.Error())
 			case structs.IsErrRPCRateExceeded(err):
 				resp.WriteHeader(http.StatusTooManyRequests)
+			case structs.IsErrBlockingQueryLimitExceeded(err), structs.IsErrRaftApplyQueueFull(err):
+				resp.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(resp, err.Error())
+			case structs.IsErrRequestTimeout(err):
+				resp.WriteHeader(http.StatusGatewayTimeout)
+				fmt.Fprint(resp, err.Error())
+			case structs.IsErrDCUnavailable(err):
+				resp.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(resp, err.Error())
 			case isMethodNotAllowed(err):
 				// RFC2616 states that for 405 Method Not Allowed the response
 				// MUST include an Allow header containing the list of valid
 				// methods for the requested resource.
 				// https://www.w3.org/Protocols/rfc2616/rfc2616-sec10.html
-				addAllowHeader(err.(MethodNotAllowedError).Allow)
+				allow := err.(MethodNotAllowedError).Allow
+				if !hasMethod(allow, "OPTIONS") {
+					allow = append([]string{"OPTIONS"}, allow...)
+				}
+				addAllowHeader(allow)
 				resp.WriteHeader(http.StatusMethodNotAllowed) // 405
 				fmt.Fprint(resp, err.Error())
-			case isBadRequest(err):
+			case isBadRequest(err), structs.IsErrResponseSigningDisabled(err):
 				resp.WriteHeader(http.StatusBadRequest)
 				fmt.Fprint(resp, err.Error())
 			default:
@@ -366,11 +415,20 @@ func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 
 		start := time.Now()
 		defer func() {
-			s.agent.logger.Printf("[DEBUG] http: Request %s %v (%v) from=%s", req.Method, logURL, time.Since(start), req.RemoteAddr)
+			s.agent.logger.Printf("[DEBUG] http: Request %s %v (%v) from=%s trace_id=%s", req.Method, logURL, time.Since(start), req.RemoteAddr, traceID)
 		}()
 
 		var obj interface{}
 
+		// Clients behind proxies which only pass GET and POST can send the
+		// other verbs as a POST with the override header.
+		if override := req.Header.Get(methodOverrideHeader); override != "" {
+			if err := overrideMethod(req, override); err != nil {
+				handleErr(err)
+				return
+			}
+		}
+
 		// if this endpoint has declared methods, respond appropriately to OPTIONS requests. Otherwise let the endpoint handle that.
 		if req.Method == "OPTIONS" && len(methods) > 0 {
 			addAllowHeader(append([]string{"OPTIONS"}, methods...))
@@ -378,18 +436,13 @@ func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 		}
 
 		// if this endpoint has declared methods, check the request method. Otherwise let the endpoint handle that.
-		methodFound := len(methods) == 0
-		for _, method := range methods {
-			if method == req.Method {
-				methodFound = true
-				break
-			}
-		}
-
-		if !methodFound {
+		if len(methods) > 0 && !hasMethod(methods, req.Method) {
 			err = MethodNotAllowedError{req.Method, append([]string{"OPTIONS"}, methods...)}
 		} else {
 			err = s.checkWriteAccess(req)
+			if err == nil {
+				err = s.checkExternalAuthorizer(req)
+			}
 
 			if err == nil {
 				// Invoke the handler
@@ -416,7 +469,14 @@ func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 			return
 		}
 		var buf []byte
-		if contentType == "application/json" {
+		if contentType == "application/json" && acceptsMsgpack(req) {
+			contentType = msgpackContentType
+			buf, err = encodeMsgPack(obj)
+			if err != nil {
+				handleErr(err)
+				return
+			}
+		} else if contentType == "application/json" {
 			buf, err = s.marshalJSON(req, obj)
 			if err != nil {
 				handleErr(err)
@@ -435,6 +495,93 @@ func (s *HTTPServer) wrap(handler endpoint, methods []string) http.HandlerFunc {
 	}
 }
 
+// msgpackContentType is the media type of MessagePack request and response
+// bodies.
+const msgpackContentType = "application/msgpack"
+
+// httpMsgpackHandle decodes MessagePack request bodies into the same generic
+// values as JSON ones, so they go through the same callbacks and decoding.
+var httpMsgpackHandle = &codec.MsgpackHandle{
+	RawToString: true,
+	MapType:     reflect.TypeOf(map[string]interface{}(nil)),
+}
+
+// isMsgpack returns true if the media type is MessagePack. The unregistered
+// x- variant is accepted too since many clients still send it.
+func isMsgpack(mediaType string) bool {
+	return mediaType == msgpackContentType || mediaType == "application/x-msgpack"
+}
+
+// acceptsMsgpack returns true if the client listed MessagePack in its Accept
+// header, in which case responses that would be JSON are encoded with the
+// same codec the agents and servers use for RPC.
+func acceptsMsgpack(req *http.Request) bool {
+	for _, accept := range req.Header["Accept"] {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err == nil && isMsgpack(mediaType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// msgpackNumbersToFloat converts the numbers in a decoded MessagePack value to
+// float64, which is what the JSON decoder produces and the callbacks expect.
+func msgpackNumbersToFloat(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = msgpackNumbersToFloat(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = msgpackNumbersToFloat(e)
+		}
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+// traceIDHeader carries the trace ID of a request. The agent generates one
+// if the client didn't send it, and always returns it in the response.
+const traceIDHeader = "X-Consul-Trace-ID"
+
+// methodOverrideHeader is the header a POST request can set to be handled
+// as a request with another method.
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// overrideMethod changes the method of a POST request to the one given in
+// its override header. Only PUT and DELETE can be requested this way since
+// those are the verbs some proxies don't pass.
+func overrideMethod(req *http.Request, method string) error {
+	if req.Method != "POST" {
+		return BadRequestError{fmt.Sprintf("%s is only allowed on POST requests", methodOverrideHeader)}
+	}
+	method = strings.ToUpper(method)
+	if method != "PUT" && method != "DELETE" {
+		return BadRequestError{fmt.Sprintf("%s cannot be %q. Must be PUT or DELETE", methodOverrideHeader, method)}
+	}
+	req.Method = method
+	return nil
+}
+
+// hasMethod returns true if method is one of methods.
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
 // marshalJSON marshals the object into JSON, respecting the user's pretty-ness
 // configuration.
 func (s *HTTPServer) marshalJSON(req *http.Request, obj interface{}) ([]byte, error) {
@@ -478,7 +625,7 @@ func (s *HTTPServer) Index(resp http.ResponseWriter, req *http.Request) {
 	http.Redirect(resp, req, "/ui/", http.StatusMovedPermanently) // 301
 }
 
-// decodeBody is used to decode a JSON request body
+// decodeBody is used to decode a JSON or MessagePack request body
 func decodeBody(req *http.Request, out interface{}, cb func(interface{}) error) error {
 	// This generally only happens in tests since real HTTP requests set
 	// a non-nil body with no content. We guard against it anyways to prevent
@@ -488,9 +635,18 @@ func decodeBody(req *http.Request, out interface{}, cb func(interface{}) error)
 	}
 
 	var raw interface{}
-	dec := json.NewDecoder(req.Body)
-	if err := dec.Decode(&raw); err != nil {
-		return err
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if isMsgpack(mediaType) {
+		dec := codec.NewDecoder(req.Body, httpMsgpackHandle)
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		raw = msgpackNumbersToFloat(raw)
+	} else {
+		dec := json.NewDecoder(req.Body)
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
 	}
 
 	// Invoke the callback prior to decode
@@ -553,6 +709,22 @@ func setMeta(resp http.ResponseWriter, m *structs.QueryMeta) {
 	setLastContact(resp, m.LastContact)
 	setKnownLeader(resp, m.KnownLeader)
 	setConsistency(resp, m.ConsistencyLevel)
+	if m.ResultsTruncated {
+		resp.Header().Set("X-Consul-Results-Truncated", "true")
+	}
+	setServedBy(resp, m)
+}
+
+// setServedBy is used to set the headers saying which server answered the
+// query, and how fresh its results were. Queries answered by the agent itself
+// don't have a server, so they don't get these headers.
+func setServedBy(resp http.ResponseWriter, m *structs.QueryMeta) {
+	if m.ServedBy == "" {
+		return
+	}
+	resp.Header().Set("X-Consul-Served-By", m.ServedBy)
+	resp.Header().Set("X-Consul-Stale", strconv.FormatBool(m.Stale))
+	resp.Header().Set("X-Consul-Index-Gap", strconv.FormatUint(m.IndexGap, 10))
 }
 
 // setCacheMeta sets http response headers to indicate cache status.
@@ -602,8 +774,52 @@ func parseWait(resp http.ResponseWriter, req *http.Request, b *structs.QueryOpti
 	return false
 }
 
+// parseTimeout is used to parse the ?timeout query param, which bounds how
+// long the servers may take to answer the query. It's ignored for cached
+// queries, since the agent answers those itself.
+func parseTimeout(resp http.ResponseWriter, req *http.Request, b *structs.QueryOptions) bool {
+	if b.UseCache {
+		return false
+	}
+	return parseTimeoutParam(resp, req, &b.Timeout)
+}
+
+// parseTimeoutParam parses the ?timeout query param into timeout, if it's
+// given.
+func parseTimeoutParam(resp http.ResponseWriter, req *http.Request, timeout *time.Duration) bool {
+	raw := req.URL.Query().Get("timeout")
+	if raw == "" {
+		return false
+	}
+	dur, err := time.ParseDuration(raw)
+	if err != nil || dur <= 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Invalid timeout")
+		return true
+	}
+	*timeout = dur
+	return false
+}
+
+// parseMaxResults is used to parse the ?max_results query param, which limits
+// how many results catalog and health queries return.
+func parseMaxResults(resp http.ResponseWriter, req *http.Request, b *structs.QueryOptions) bool {
+	maxResults := req.URL.Query().Get("max_results")
+	if maxResults == "" {
+		return false
+	}
+	n, err := strconv.Atoi(maxResults)
+	if err != nil || n <= 0 {
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(resp, "Invalid max_results")
+		return true
+	}
+	b.MaxResults = n
+	return false
+}
+
 // parseCacheControl parses the CacheControl HTTP header value. So far we only
-// support maxage directive.
+// support the max-age, must-revalidate, no-cache and stale-if-error directives.
 func parseCacheControl(resp http.ResponseWriter, req *http.Request, b *structs.QueryOptions) bool {
 	raw := strings.ToLower(req.Header.Get("Cache-Control"))
 
@@ -631,7 +847,7 @@ func parseCacheControl(resp http.ResponseWriter, req *http.Request, b *structs.Q
 	for _, d := range directives {
 		d = strings.ToLower(strings.TrimSpace(d))
 
-		if d == "must-revalidate" {
+		if d == "must-revalidate" || d == "no-cache" {
 			b.MustRevalidate = true
 		}
 
@@ -825,7 +1041,13 @@ func (s *HTTPServer) parseSource(req *http.Request, source *structs.QuerySource)
 // parseMetaFilter is used to parse the ?node-meta=key:value query parameter, used for
 // filtering results to nodes with the given metadata key/value
 func (s *HTTPServer) parseMetaFilter(req *http.Request) map[string]string {
-	if filterList, ok := req.URL.Query()["node-meta"]; ok {
+	return parseMetaPairs(req, "node-meta")
+}
+
+// parseMetaPairs parses the key:value pairs given in a query parameter,
+// which can be given more than once.
+func parseMetaPairs(req *http.Request, param string) map[string]string {
+	if filterList, ok := req.URL.Query()[param]; ok {
 		filters := make(map[string]string)
 		for _, filter := range filterList {
 			key, value := ParseMetaPair(filter)
@@ -836,17 +1058,52 @@ func (s *HTTPServer) parseMetaFilter(req *http.Request) map[string]string {
 	return nil
 }
 
+// parseMultiDC is used to parse the ?multi-dc=dc1,dc2 query parameter, used
+// to query several datacenters at once. It can be given more than once.
+func (s *HTTPServer) parseMultiDC(req *http.Request) []string {
+	var dcs []string
+	for _, list := range req.URL.Query()["multi-dc"] {
+		for _, dc := range strings.Split(list, ",") {
+			if dc = strings.TrimSpace(dc); dc != "" {
+				dcs = append(dcs, dc)
+			}
+		}
+	}
+	return dcs
+}
+
+// parseTraceID is used to parse the trace ID that wrap tagged the request
+// with, so it can be passed along with the RPCs made for the request.
+func (s *HTTPServer) parseTraceID(req *http.Request, traceID *string) {
+	*traceID = req.Header.Get(traceIDHeader)
+}
+
+// parseWriteRequest is used to parse the token, the trace ID and the
+// ?timeout query param of a write.
+func (s *HTTPServer) parseWriteRequest(resp http.ResponseWriter, req *http.Request, w *structs.WriteRequest) bool {
+	s.parseToken(req, &w.Token)
+	s.parseTraceID(req, &w.TraceID)
+	return parseTimeoutParam(resp, req, &w.Timeout)
+}
+
 // parseInternal is a convenience method for endpoints that need
 // to use both parseWait and parseDC.
 func (s *HTTPServer) parseInternal(resp http.ResponseWriter, req *http.Request, dc *string, b *structs.QueryOptions, resolveProxyToken bool) bool {
 	s.parseDC(req, dc)
 	s.parseTokenInternal(req, &b.Token, resolveProxyToken)
+	s.parseTraceID(req, &b.TraceID)
 	if s.parseConsistency(resp, req, b) {
 		return true
 	}
 	if parseCacheControl(resp, req, b) {
 		return true
 	}
+	if parseTimeout(resp, req, b) {
+		return true
+	}
+	if parseMaxResults(resp, req, b) {
+		return true
+	}
 	return parseWait(resp, req, b)
 }
 
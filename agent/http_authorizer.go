@@ -0,0 +1,214 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/agent/structs"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+)
+
+// httpAuthorizerCategories are the endpoints, by path prefix, whose writes can
+// be sent to the external authorizer. Force-leave removes a server from the
+// Raft peers just like the Raft peer endpoint, so it's in the same category.
+var httpAuthorizerCategories = map[string][]string{
+	"acl":     {"/v1/acl/"},
+	"keyring": {"/v1/operator/keyring"},
+	"raft":    {"/v1/operator/raft/", "/v1/agent/force-leave/"},
+}
+
+// httpAuthorizerMaxReason caps how much of the authorizer's response is used
+// as the reason a request was denied.
+const httpAuthorizerMaxReason = 1024
+
+// httpAuthorizerRequest is the summary of a request that's sent to the
+// external authorizer. The request's token is never sent, only the accessor
+// ID it resolves to, and the body is sent as a SHA-256 digest so that secrets
+// in it aren't passed on, while still letting the authorizer tie an approval
+// to the exact change.
+type httpAuthorizerRequest struct {
+	Category   string
+	Method     string
+	Path       string
+	Query      string
+	BodySHA256 string
+	AccessorID string
+	RemoteAddr string
+	Node       string
+	Datacenter string
+	TraceID    string
+}
+
+// httpAuthorizer asks an external HTTP service whether sensitive requests
+// should go ahead, on top of the ACLs. This lets organizations put their own
+// approval systems in front of changes like ACL updates, keyring operations
+// and Raft peer removal.
+//
+// This is only checked by the agent the request is made to. Nothing stops a
+// client with a valid token from sending the same request to an agent that
+// doesn't have an authorizer configured, or calling the servers' RPC
+// endpoints directly, so it has to be configured on every agent whose HTTP
+// API can be reached, with ACLs doing the enforcement everywhere else.
+type httpAuthorizer struct {
+	url        string
+	categories []string
+	client     *http.Client
+}
+
+// newHTTPAuthorizer returns the external authorizer for the given URL, or nil
+// if there's no URL.
+func newHTTPAuthorizer(url string, categories []string, timeout time.Duration) *httpAuthorizer {
+	if url == "" {
+		return nil
+	}
+	client := cleanhttp.DefaultPooledClient()
+	client.Timeout = timeout
+	return &httpAuthorizer{
+		url:        url,
+		categories: categories,
+		client:     client,
+	}
+}
+
+// category returns the category of the request, or "" if the authorizer
+// doesn't need to be asked about it. Only writes are checked.
+func (a *httpAuthorizer) category(req *http.Request) string {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ""
+	}
+	for _, category := range a.categories {
+		for _, prefix := range httpAuthorizerCategories[category] {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				return category
+			}
+		}
+	}
+	return ""
+}
+
+// authorizerQuery returns the request's query string without the token.
+func authorizerQuery(req *http.Request) string {
+	query := req.URL.Query()
+	query.Del("token")
+	return query.Encode()
+}
+
+// authorizerBodyDigest reads the request's body and returns its SHA-256,
+// putting the body back for the handler.
+func authorizerBodyDigest(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// authorizerAccessorID returns the accessor ID of the request's token, or ""
+// if ACLs are off or it can't be resolved. The handler still does the ACL
+// check, so a token that can't be resolved doesn't get anywhere.
+func (s *HTTPServer) authorizerAccessorID(req *http.Request) string {
+	if !s.agent.delegate.ACLsEnabled() || s.agent.delegate.UseLegacyACLs() {
+		return ""
+	}
+	var token string
+	s.parseToken(req, &token)
+	if token == "" {
+		return ""
+	}
+
+	args := structs.ACLTokenGetRequest{
+		Datacenter:  s.agent.config.Datacenter,
+		TokenID:     token,
+		TokenIDType: structs.ACLTokenSecret,
+		QueryOptions: structs.QueryOptions{
+			Token:      token,
+			AllowStale: true,
+		},
+	}
+	var out structs.ACLTokenResponse
+	if err := s.agent.RPC("ACL.TokenRead", &args, &out); err != nil || out.Token == nil {
+		return ""
+	}
+	return out.Token.AccessorID
+}
+
+// checkExternalAuthorizer asks the external authorizer, if there is one,
+// whether the request can go ahead. The authorizer allows it by responding
+// with a 200, and any other response denies it, with the body as the reason.
+// If the authorizer can't be reached, the request is denied.
+func (s *HTTPServer) checkExternalAuthorizer(req *http.Request) error {
+	a := s.authorizer
+	if a == nil {
+		return nil
+	}
+	category := a.category(req)
+	if category == "" {
+		return nil
+	}
+
+	defer metrics.MeasureSinceWithLabels([]string{"http", "authorizer"}, time.Now(),
+		[]metrics.Label{{Name: "category", Value: category}})
+
+	digest, err := authorizerBodyDigest(req)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(&httpAuthorizerRequest{
+		Category:   category,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Query:      authorizerQuery(req),
+		BodySHA256: digest,
+		AccessorID: s.authorizerAccessorID(req),
+		RemoteAddr: req.RemoteAddr,
+		Node:       s.agent.config.NodeName,
+		Datacenter: s.agent.config.Datacenter,
+		TraceID:    req.Header.Get(traceIDHeader),
+	})
+	if err != nil {
+		return err
+	}
+
+	authReq, err := http.NewRequest("POST", a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	authReq.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(authReq)
+	if err != nil {
+		metrics.IncrCounterWithLabels([]string{"http", "authorizer", "error"}, 1,
+			[]metrics.Label{{Name: "category", Value: category}})
+		s.agent.logger.Printf("[ERR] http: Failed to reach external authorizer: %v", err)
+		return ForbiddenError{Reason: "External authorizer is unavailable"}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	metrics.IncrCounterWithLabels([]string{"http", "authorizer", "denied"}, 1,
+		[]metrics.Label{{Name: "category", Value: category}})
+	reason, _ := ioutil.ReadAll(io.LimitReader(resp.Body, httpAuthorizerMaxReason))
+	if len(bytes.TrimSpace(reason)) == 0 {
+		reason = []byte(fmt.Sprintf("External authorizer responded with %d", resp.StatusCode))
+	}
+	return ForbiddenError{Reason: string(bytes.TrimSpace(reason))}
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPAPI_ExternalAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	var lock sync.Mutex
+	var seen []httpAuthorizerRequest
+	deny := false
+	authorizer := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var args httpAuthorizerRequest
+		if err := json.NewDecoder(req.Body).Decode(&args); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		seen = append(seen, args)
+		if deny {
+			resp.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(resp, "change window is closed")
+		}
+	}))
+	defer authorizer.Close()
+
+	a := NewTestAgent(t.Name(), fmt.Sprintf(`
+		http_config {
+			authorizer_url = %q
+			authorizer_categories = ["raft", "keyring"]
+		}
+	`, authorizer.URL))
+	defer a.Shutdown()
+
+	var handled []string
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		if req.Body != nil {
+			body, _ := ioutil.ReadAll(req.Body)
+			handled = append(handled, string(body))
+		}
+		return nil, nil
+	}
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		a.srv.wrap(handler, []string{"GET", "PUT", "DELETE"})(resp, req)
+		return resp
+	}
+
+	// Writes to the configured categories are sent to the authorizer, with
+	// the query string minus the token.
+	resp := do("DELETE", "/v1/operator/raft/peer?id=foo&token=secret")
+	require.Equal(t, http.StatusOK, resp.Code)
+	lock.Lock()
+	require.Len(t, seen, 1)
+	require.Equal(t, "raft", seen[0].Category)
+	require.Equal(t, "DELETE", seen[0].Method)
+	require.Equal(t, "/v1/operator/raft/peer", seen[0].Path)
+	require.Equal(t, "id=foo", seen[0].Query)
+	require.Equal(t, a.config.NodeName, seen[0].Node)
+	require.Equal(t, "dc1", seen[0].Datacenter)
+	require.NotEmpty(t, seen[0].TraceID)
+	lock.Unlock()
+
+	// Force-leave removes peers too. The body is sent as a digest, and is
+	// still there for the handler.
+	req, _ := http.NewRequest("PUT", "/v1/agent/force-leave/node1", strings.NewReader("hello"))
+	resp = httptest.NewRecorder()
+	a.srv.wrap(handler, []string{"PUT"})(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "hello", handled[len(handled)-1])
+	lock.Lock()
+	require.Len(t, seen, 2)
+	require.Equal(t, "raft", seen[1].Category)
+	require.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", seen[1].BodySHA256)
+	lock.Unlock()
+
+	// Reads and other categories aren't.
+	require.Equal(t, http.StatusOK, do("GET", "/v1/operator/raft/configuration").Code)
+	require.Equal(t, http.StatusOK, do("PUT", "/v1/acl/bootstrap").Code)
+	lock.Lock()
+	require.Len(t, seen, 2)
+	deny = true
+	lock.Unlock()
+
+	// A denial is a 403 with the authorizer's reason.
+	resp = do("PUT", "/v1/operator/keyring")
+	require.Equal(t, http.StatusForbidden, resp.Code)
+	require.Equal(t, "Access is restricted: change window is closed", resp.Body.String())
+
+	// An authorizer that can't be reached denies the request.
+	authorizer.Close()
+	resp = do("PUT", "/v1/operator/keyring")
+	require.Equal(t, http.StatusForbidden, resp.Code)
+	require.True(t, strings.Contains(resp.Body.String(), "External authorizer is unavailable"))
+}
@@ -10,6 +10,8 @@ func init() {
 	registerEndpoint("/v1/acl/info/", []string{"GET"}, (*HTTPServer).ACLGet)
 	registerEndpoint("/v1/acl/clone/", []string{"PUT"}, (*HTTPServer).ACLClone)
 	registerEndpoint("/v1/acl/list", []string{"GET"}, (*HTTPServer).ACLList)
+	registerEndpoint("/v1/acl/login", []string{"POST"}, (*HTTPServer).ACLLogin)
+	registerEndpoint("/v1/acl/logout", []string{"POST"}, (*HTTPServer).ACLLogout)
 	registerEndpoint("/v1/acl/replication", []string{"GET"}, (*HTTPServer).ACLReplicationStatus)
 	registerEndpoint("/v1/acl/policies", []string{"GET"}, (*HTTPServer).ACLPolicyList)
 	registerEndpoint("/v1/acl/policy", []string{"PUT"}, (*HTTPServer).ACLPolicyCreate)
@@ -23,8 +25,15 @@ func init() {
 	registerEndpoint("/v1/agent/token/", []string{"PUT"}, (*HTTPServer).AgentToken)
 	registerEndpoint("/v1/agent/self", []string{"GET"}, (*HTTPServer).AgentSelf)
 	registerEndpoint("/v1/agent/host", []string{"GET"}, (*HTTPServer).AgentHost)
+	registerEndpoint("/v1/agent/dns-forwarding", []string{"GET"}, (*HTTPServer).AgentDNSForwarding)
 	registerEndpoint("/v1/agent/maintenance", []string{"PUT"}, (*HTTPServer).AgentNodeMaintenance)
+	registerEndpoint("/v1/agent/drain", []string{"PUT"}, (*HTTPServer).AgentNodeDrain)
 	registerEndpoint("/v1/agent/reload", []string{"PUT"}, (*HTTPServer).AgentReload)
+	registerEndpoint("/v1/agent/state-dump", []string{"PUT"}, (*HTTPServer).AgentStateDump)
+	registerEndpoint("/v1/agent/flush-caches", []string{"PUT"}, (*HTTPServer).AgentFlushCaches)
+	registerEndpoint("/v1/agent/bench", []string{"PUT"}, (*HTTPServer).AgentBench)
+	registerEndpoint("/v1/agent/bench/stop", []string{"PUT"}, (*HTTPServer).AgentBenchStop)
+	registerEndpoint("/v1/agent/faults", []string{"GET", "PUT"}, (*HTTPServer).AgentFaults)
 	registerEndpoint("/v1/agent/monitor", []string{"GET"}, (*HTTPServer).AgentMonitor)
 	registerEndpoint("/v1/agent/metrics", []string{"GET"}, (*HTTPServer).AgentMetrics)
 	registerEndpoint("/v1/agent/services", []string{"GET"}, (*HTTPServer).AgentServices)
@@ -52,11 +61,17 @@ func init() {
 	registerEndpoint("/v1/catalog/register", []string{"PUT"}, (*HTTPServer).CatalogRegister)
 	registerEndpoint("/v1/catalog/connect/", []string{"GET"}, (*HTTPServer).CatalogConnectServiceNodes)
 	registerEndpoint("/v1/catalog/deregister", []string{"PUT"}, (*HTTPServer).CatalogDeregister)
+	registerEndpoint("/v1/catalog/check/update", []string{"PUT"}, (*HTTPServer).CatalogUpdateCheck)
 	registerEndpoint("/v1/catalog/datacenters", []string{"GET"}, (*HTTPServer).CatalogDatacenters)
 	registerEndpoint("/v1/catalog/nodes", []string{"GET"}, (*HTTPServer).CatalogNodes)
+	registerEndpoint("/v1/catalog/nodes-without-service/", []string{"GET"}, (*HTTPServer).CatalogNodesWithoutService)
 	registerEndpoint("/v1/catalog/services", []string{"GET"}, (*HTTPServer).CatalogServices)
+	registerEndpoint("/v1/catalog/signing-key", []string{"GET"}, (*HTTPServer).CatalogSigningKey)
 	registerEndpoint("/v1/catalog/service/", []string{"GET"}, (*HTTPServer).CatalogServiceNodes)
 	registerEndpoint("/v1/catalog/node/", []string{"GET"}, (*HTTPServer).CatalogNodeServices)
+	registerEndpoint("/v1/changes", []string{"GET"}, (*HTTPServer).ChangeFeedList)
+	registerEndpoint("/v1/config", []string{"GET"}, (*HTTPServer).ConfigEntryList)
+	registerEndpoint("/v1/config/", []string{"GET", "PUT", "DELETE"}, (*HTTPServer).ConfigEntrySpecific)
 	registerEndpoint("/v1/connect/ca/configuration", []string{"GET", "PUT"}, (*HTTPServer).ConnectCAConfiguration)
 	registerEndpoint("/v1/connect/ca/roots", []string{"GET"}, (*HTTPServer).ConnectCARoots)
 	registerEndpoint("/v1/connect/intentions", []string{"GET", "POST"}, (*HTTPServer).IntentionEndpoint)
@@ -67,6 +82,8 @@ func init() {
 	registerEndpoint("/v1/coordinate/nodes", []string{"GET"}, (*HTTPServer).CoordinateNodes)
 	registerEndpoint("/v1/coordinate/node/", []string{"GET"}, (*HTTPServer).CoordinateNode)
 	registerEndpoint("/v1/coordinate/update", []string{"PUT"}, (*HTTPServer).CoordinateUpdate)
+	registerEndpoint("/v1/coordinate/update-batch", []string{"PUT"}, (*HTTPServer).CoordinateBatchUpdate)
+	registerEndpoint("/v1/election/", []string{"GET", "PUT", "DELETE"}, (*HTTPServer).ElectionEndpoint)
 	registerEndpoint("/v1/event/fire/", []string{"PUT"}, (*HTTPServer).EventFire)
 	registerEndpoint("/v1/event/list", []string{"GET"}, (*HTTPServer).EventList)
 	registerEndpoint("/v1/health/node/", []string{"GET"}, (*HTTPServer).HealthNodeChecks)
@@ -78,15 +95,28 @@ func init() {
 	registerEndpoint("/v1/internal/ui/node/", []string{"GET"}, (*HTTPServer).UINodeInfo)
 	registerEndpoint("/v1/internal/ui/services", []string{"GET"}, (*HTTPServer).UIServices)
 	registerEndpoint("/v1/kv/", []string{"GET", "PUT", "DELETE"}, (*HTTPServer).KVSEndpoint)
+	registerEndpoint("/v1/namespaces", []string{"GET"}, (*HTTPServer).NamespaceList)
+	registerEndpoint("/v1/namespace/", []string{"GET", "PUT", "DELETE"}, (*HTTPServer).NamespaceSpecific)
 	registerEndpoint("/v1/operator/raft/configuration", []string{"GET"}, (*HTTPServer).OperatorRaftConfiguration)
 	registerEndpoint("/v1/operator/raft/peer", []string{"DELETE"}, (*HTTPServer).OperatorRaftPeer)
+	registerEndpoint("/v1/operator/raft/verify", []string{"GET"}, (*HTTPServer).OperatorRaftVerify)
+	registerEndpoint("/v1/operator/raft/leader-history", []string{"GET"}, (*HTTPServer).OperatorRaftLeaderHistory)
 	registerEndpoint("/v1/operator/keyring", []string{"GET", "POST", "PUT", "DELETE"}, (*HTTPServer).OperatorKeyringEndpoint)
 	registerEndpoint("/v1/operator/autopilot/configuration", []string{"GET", "PUT"}, (*HTTPServer).OperatorAutopilotConfiguration)
 	registerEndpoint("/v1/operator/autopilot/health", []string{"GET"}, (*HTTPServer).OperatorServerHealth)
+	registerEndpoint("/v1/operator/catalog/consistency", []string{"GET"}, (*HTTPServer).OperatorCatalogConsistency)
+	registerEndpoint("/v1/operator/catalog/export", []string{"GET"}, (*HTTPServer).OperatorCatalogExport)
+	registerEndpoint("/v1/operator/state/memory", []string{"GET"}, (*HTTPServer).OperatorStateMemory)
+	registerEndpoint("/v1/operator/features", []string{"GET"}, (*HTTPServer).OperatorServerFeatures)
+	registerEndpoint("/v1/operator/runtime", []string{"GET", "PUT"}, (*HTTPServer).OperatorRuntimeSettings)
+	registerEndpoint("/v1/operator/kv/replication", []string{"GET"}, (*HTTPServer).OperatorKVReplicationStatus)
+	registerEndpoint("/v1/operator/clock-skew", []string{"GET"}, (*HTTPServer).OperatorClockSkew)
 	registerEndpoint("/v1/query", []string{"GET", "POST"}, (*HTTPServer).PreparedQueryGeneral)
 	// specific prepared query endpoints have more complex rules for allowed methods, so
 	// the prefix is registered with no methods.
 	registerEndpoint("/v1/query/", []string{}, (*HTTPServer).PreparedQuerySpecific)
+	registerEndpoint("/v1/service-failovers", []string{"GET"}, (*HTTPServer).ServiceFailoverList)
+	registerEndpoint("/v1/service-failover/", []string{"GET", "PUT", "DELETE"}, (*HTTPServer).ServiceFailoverSpecific)
 	registerEndpoint("/v1/session/create", []string{"PUT"}, (*HTTPServer).SessionCreate)
 	registerEndpoint("/v1/session/destroy/", []string{"PUT"}, (*HTTPServer).SessionDestroy)
 	registerEndpoint("/v1/session/renew/", []string{"PUT"}, (*HTTPServer).SessionRenew)
@@ -95,6 +125,7 @@ func init() {
 	registerEndpoint("/v1/session/list", []string{"GET"}, (*HTTPServer).SessionList)
 	registerEndpoint("/v1/status/leader", []string{"GET"}, (*HTTPServer).StatusLeader)
 	registerEndpoint("/v1/status/peers", []string{"GET"}, (*HTTPServer).StatusPeers)
+	registerEndpoint("/v1/status/raft-stats", []string{"GET"}, (*HTTPServer).StatusRaftStats)
 	registerEndpoint("/v1/snapshot", []string{"GET", "PUT"}, (*HTTPServer).Snapshot)
 	registerEndpoint("/v1/txn", []string{"PUT"}, (*HTTPServer).Txn)
 }
@@ -266,6 +266,9 @@ func TestSetMeta(t *testing.T) {
 		Index:       1000,
 		KnownLeader: true,
 		LastContact: 123456 * time.Microsecond,
+		ServedBy:    "server1",
+		Stale:       true,
+		IndexGap:    7,
 	}
 	resp := httptest.NewRecorder()
 	setMeta(resp, &meta)
@@ -281,6 +284,25 @@ func TestSetMeta(t *testing.T) {
 	if header != "123" {
 		t.Fatalf("Bad: %v", header)
 	}
+	header = resp.Header().Get("X-Consul-Served-By")
+	if header != "server1" {
+		t.Fatalf("Bad: %v", header)
+	}
+	header = resp.Header().Get("X-Consul-Stale")
+	if header != "true" {
+		t.Fatalf("Bad: %v", header)
+	}
+	header = resp.Header().Get("X-Consul-Index-Gap")
+	if header != "7" {
+		t.Fatalf("Bad: %v", header)
+	}
+
+	// Queries the agent answers itself don't say who served them.
+	resp = httptest.NewRecorder()
+	setMeta(resp, &structs.QueryMeta{Index: 1000})
+	if header := resp.Header().Get("X-Consul-Served-By"); header != "" {
+		t.Fatalf("Bad: %v", header)
+	}
 }
 
 func TestHTTPAPI_BlockEndpoints(t *testing.T) {
@@ -318,6 +340,101 @@ func TestHTTPAPI_BlockEndpoints(t *testing.T) {
 	}
 }
 
+func TestHTTPAPI_MethodOverride(t *testing.T) {
+	t.Parallel()
+
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	var method string
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		method = req.Method
+		return nil, nil
+	}
+
+	do := func(method, override string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/v1/kv/foo", nil)
+		if override != "" {
+			req.Header.Set("X-HTTP-Method-Override", override)
+		}
+		resp := httptest.NewRecorder()
+		a.srv.wrap(handler, []string{"GET", "PUT", "DELETE"})(resp, req)
+		return resp
+	}
+
+	// A POST is handled as the method it overrides.
+	resp := do("POST", "delete")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "DELETE", method)
+
+	// Without the header it is checked against the allowed methods.
+	resp = do("POST", "")
+	require.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	require.Equal(t, "OPTIONS,GET,PUT,DELETE", resp.Header().Get("Allow"))
+
+	// Only a POST can be overridden, and only to PUT or DELETE.
+	resp = do("GET", "DELETE")
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = do("POST", "GET")
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestHTTPAPI_MethodNotAllowed_Handler(t *testing.T) {
+	t.Parallel()
+
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	// A 405 returned by the handler itself lists OPTIONS like one from
+	// the registered methods does.
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return nil, MethodNotAllowedError{req.Method, []string{"GET"}}
+	}
+	req, _ := http.NewRequest("PUT", "/v1/query/foo/execute", nil)
+	resp := httptest.NewRecorder()
+	a.srv.wrap(handler, []string{"GET", "PUT"})(resp, req)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	require.Equal(t, "OPTIONS,GET", resp.Header().Get("Allow"))
+}
+
+func TestHTTPAPI_TraceID(t *testing.T) {
+	t.Parallel()
+
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	var q structs.QueryOptions
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		var dc string
+		a.srv.parse(resp, req, &dc, &q)
+		return nil, nil
+	}
+
+	// A trace ID is generated when the client doesn't send one.
+	req, _ := http.NewRequest("GET", "/v1/catalog/nodes", nil)
+	resp := httptest.NewRecorder()
+	a.srv.wrap(handler, []string{"GET"})(resp, req)
+	require.NotEmpty(t, q.TraceID)
+	require.Equal(t, q.TraceID, resp.Header().Get("X-Consul-Trace-ID"))
+
+	// A trace ID from the client is used as is.
+	req, _ = http.NewRequest("GET", "/v1/catalog/nodes", nil)
+	req.Header.Set("X-Consul-Trace-ID", "my-trace")
+	resp = httptest.NewRecorder()
+	a.srv.wrap(handler, []string{"GET"})(resp, req)
+	require.Equal(t, "my-trace", q.TraceID)
+	require.Equal(t, "my-trace", resp.Header().Get("X-Consul-Trace-ID"))
+
+	// Trace IDs that are too long or could forge log fields are rejected.
+	for _, traceID := range []string{strings.Repeat("a", 65), "a trace_id=b", "a\nb"} {
+		req, _ = http.NewRequest("GET", "/v1/catalog/nodes", nil)
+		req.Header.Set("X-Consul-Trace-ID", traceID)
+		resp = httptest.NewRecorder()
+		a.srv.wrap(handler, []string{"GET"})(resp, req)
+		require.Equal(t, http.StatusBadRequest, resp.Code, traceID)
+	}
+}
+
 func TestHTTPAPI_Ban_Nonprintable_Characters(t *testing.T) {
 	a := NewTestAgent(t.Name(), "")
 	defer a.Shutdown()
@@ -438,6 +555,53 @@ func TestContentTypeIsJSON(t *testing.T) {
 	}
 }
 
+func TestHTTPAPI_Msgpack(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		var session structs.Session
+		if err := decodeBody(req, &session, FixupLockDelay); err != nil {
+			return nil, err
+		}
+		return &session, nil
+	}
+
+	body, err := encodeMsgPack(map[string]interface{}{
+		"Name":      "foo",
+		"LockDelay": 15,
+		"Checks":    []string{"a", "b"},
+	})
+	require.NoError(t, err)
+
+	// A MessagePack body is decoded like a JSON one, and the response is
+	// encoded as MessagePack when the client accepts it.
+	req, _ := http.NewRequest("PUT", "/v1/session/create", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "text/html, application/msgpack;q=0.9")
+	resp := httptest.NewRecorder()
+	a.srv.wrap(handler, []string{"PUT"})(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "application/msgpack", resp.Header().Get("Content-Type"))
+
+	var out structs.Session
+	require.NoError(t, decodeMsgPack(resp.Body.Bytes(), &out))
+	require.Equal(t, "foo", out.Name)
+	require.Equal(t, 15*time.Second, out.LockDelay)
+	require.Len(t, out.Checks, 2)
+
+	// Otherwise it's JSON.
+	req, _ = http.NewRequest("PUT", "/v1/session/create", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/msgpack")
+	resp = httptest.NewRecorder()
+	a.srv.wrap(handler, []string{"PUT"})(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &out))
+	require.Equal(t, "foo", out.Name)
+}
+
 func TestHTTP_wrap_obfuscateLog(t *testing.T) {
 	t.Parallel()
 	buf := new(bytes.Buffer)
@@ -569,6 +733,20 @@ func TestParseSource(t *testing.T) {
 	}
 }
 
+func TestParseMultiDC(t *testing.T) {
+	t.Parallel()
+	s := &HTTPServer{}
+
+	req, _ := http.NewRequest("GET", "/v1/health/service/web", nil)
+	if dcs := s.parseMultiDC(req); dcs != nil {
+		t.Fatalf("bad: %v", dcs)
+	}
+
+	// Lists can be given more than once.
+	req, _ = http.NewRequest("GET", "/v1/health/service/web?multi-dc=dc1,%20dc2,&multi-dc=dc3", nil)
+	require.Equal(t, []string{"dc1", "dc2", "dc3"}, s.parseMultiDC(req))
+}
+
 func TestParseCacheControl(t *testing.T) {
 
 	tests := []struct {
@@ -657,14 +835,22 @@ func TestParseCacheControl(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name:      "unsupported directive",
+			name:      "no-cache",
 			headerVal: "no-cache",
+			want: structs.QueryOptions{
+				MustRevalidate: true,
+			},
+			wantErr: false,
+		},
+		{
+			name:      "unsupported directive",
+			headerVal: "no-store",
 			want:      structs.QueryOptions{},
 			wantErr:   false,
 		},
 		{
 			name:      "mixed unsupported directive",
-			headerVal: "no-cache, max-age=120",
+			headerVal: "no-store, max-age=120",
 			want: structs.QueryOptions{
 				MaxAge: 120 * time.Second,
 			},
@@ -825,6 +1011,52 @@ func TestPProfHandlers_ACLs(t *testing.T) {
 	}
 }
 
+func TestParseTimeout(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		query string
+		b     structs.QueryOptions
+		done  bool
+		want  time.Duration
+	}{
+		{"", structs.QueryOptions{}, false, 0},
+		{"?timeout=5s", structs.QueryOptions{}, false, 5 * time.Second},
+		{"?timeout=5foo", structs.QueryOptions{}, true, 0},
+		{"?timeout=-5s", structs.QueryOptions{}, true, 0},
+		{"?timeout=5s", structs.QueryOptions{UseCache: true}, false, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/v1/catalog/nodes"+tc.query, nil)
+			b := tc.b
+			require.Equal(t, tc.done, parseTimeout(resp, req, &b))
+			require.Equal(t, tc.want, b.Timeout)
+			if tc.done {
+				require.Equal(t, 400, resp.Code)
+			}
+		})
+	}
+}
+
+func TestParseWriteRequest(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/v1/catalog/register?timeout=5s&token=foo", nil)
+	req.Header.Set(traceIDHeader, "my-trace")
+	var w structs.WriteRequest
+	require.False(t, a.srv.parseWriteRequest(resp, req, &w))
+	require.Equal(t, structs.WriteRequest{Token: "foo", TraceID: "my-trace", Timeout: 5 * time.Second}, w)
+
+	req, _ = http.NewRequest("PUT", "/v1/catalog/register?timeout=-5s", nil)
+	require.True(t, a.srv.parseWriteRequest(resp, req, &w))
+	require.Equal(t, 400, resp.Code)
+}
+
 func TestParseWait_InvalidTime(t *testing.T) {
 	t.Parallel()
 	resp := httptest.NewRecorder()
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/api"
@@ -42,6 +43,9 @@ func (s *HTTPServer) KVSEndpoint(resp http.ResponseWriter, req *http.Request) (i
 		if keyList {
 			return s.KVSGetKeys(resp, req, &args)
 		}
+		if _, ok := params["lock-queue"]; ok {
+			return s.KVSLockQueue(resp, req, &args)
+		}
 		return s.KVSGet(resp, req, &args)
 	case "PUT":
 		return s.KVSPut(resp, req, &args)
@@ -59,6 +63,7 @@ func (s *HTTPServer) KVSGet(resp http.ResponseWriter, req *http.Request, args *s
 	params := req.URL.Query()
 	if _, ok := params["recurse"]; ok {
 		method = "KVS.List"
+		_, args.Diff = params["diff"]
 	} else if missingKey(resp, args) {
 		return nil, nil
 	}
@@ -69,6 +74,15 @@ func (s *HTTPServer) KVSGet(resp http.ResponseWriter, req *http.Request, args *s
 		return nil, err
 	}
 	setMeta(resp, &out.QueryMeta)
+	if out.LockDelay > 0 {
+		setLockDelay(resp, out.LockDelay)
+	}
+
+	// A diff is returned even if nothing changed, so the client can tell
+	// that apart from everything having been deleted.
+	if args.Diff {
+		return kvsDiff(&out), nil
+	}
 
 	// Check if we get a not found
 	if len(out.Entries) == 0 {
@@ -88,6 +102,30 @@ func (s *HTTPServer) KVSGet(resp http.ResponseWriter, req *http.Request, args *s
 	return out.Entries, nil
 }
 
+// kvsDiffResponse is the body returned for a recursive GET in diff mode.
+type kvsDiffResponse struct {
+	Entries structs.DirEntries
+	Removed []string
+	Resync  bool
+}
+
+// kvsDiff converts the result of a diff request into its HTTP response, using
+// empty lists instead of null.
+func kvsDiff(out *structs.IndexedDirEntries) *kvsDiffResponse {
+	diff := &kvsDiffResponse{
+		Entries: out.Entries,
+		Removed: out.Removed,
+		Resync:  out.Resync,
+	}
+	if diff.Entries == nil {
+		diff.Entries = structs.DirEntries{}
+	}
+	if diff.Removed == nil {
+		diff.Removed = []string{}
+	}
+	return diff
+}
+
 // KVSGetKeys handles a GET request for keys
 func (s *HTTPServer) KVSGetKeys(resp http.ResponseWriter, req *http.Request, args *structs.KeyRequest) (interface{}, error) {
 	// Check for a separator, due to historic spelling error,
@@ -130,6 +168,25 @@ func (s *HTTPServer) KVSGetKeys(resp http.ResponseWriter, req *http.Request, arg
 	return out.Keys, nil
 }
 
+// KVSLockQueue handles a GET request for the sessions waiting to acquire a
+// key
+func (s *HTTPServer) KVSLockQueue(resp http.ResponseWriter, req *http.Request, args *structs.KeyRequest) (interface{}, error) {
+	if missingKey(resp, args) {
+		return nil, nil
+	}
+
+	var out structs.KVSLockQueue
+	if err := s.agent.RPC("KVS.LockQueue", args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+
+	if out.Sessions == nil {
+		out.Sessions = []string{}
+	}
+	return out.Sessions, nil
+}
+
 // KVSPut handles a PUT request
 func (s *HTTPServer) KVSPut(resp http.ResponseWriter, req *http.Request, args *structs.KeyRequest) (interface{}, error) {
 	if missingKey(resp, args) {
@@ -148,6 +205,8 @@ func (s *HTTPServer) KVSPut(resp http.ResponseWriter, req *http.Request, args *s
 		},
 	}
 	applyReq.Token = args.Token
+	applyReq.TraceID = args.TraceID
+	applyReq.Timeout = args.Timeout
 
 	// Check for flags
 	params := req.URL.Query()
@@ -173,6 +232,7 @@ func (s *HTTPServer) KVSPut(resp http.ResponseWriter, req *http.Request, args *s
 	if _, ok := params["acquire"]; ok {
 		applyReq.DirEnt.Session = params.Get("acquire")
 		applyReq.Op = api.KVLock
+		_, applyReq.Queue = params["queue"]
 	}
 
 	// Check for lock release
@@ -221,6 +281,8 @@ func (s *HTTPServer) KVSDelete(resp http.ResponseWriter, req *http.Request, args
 		},
 	}
 	applyReq.Token = args.Token
+	applyReq.TraceID = args.TraceID
+	applyReq.Timeout = args.Timeout
 
 	// Check for recurse
 	params := req.URL.Query()
@@ -253,6 +315,13 @@ func (s *HTTPServer) KVSDelete(resp http.ResponseWriter, req *http.Request, args
 	return true, nil
 }
 
+// setLockDelay is used to set the X-Consul-LockDelay header with the time
+// left before a key can be acquired, in milliseconds rounded up.
+func setLockDelay(resp http.ResponseWriter, left time.Duration) {
+	msec := uint64((left + time.Millisecond - 1) / time.Millisecond)
+	resp.Header().Set("X-Consul-LockDelay", strconv.FormatUint(msec, 10))
+}
+
 // missingKey checks if the key is missing
 func missingKey(resp http.ResponseWriter, args *structs.KeyRequest) bool {
 	if args.Key == "" {
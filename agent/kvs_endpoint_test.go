@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/hashicorp/consul/testrpc"
@@ -398,6 +399,51 @@ func TestKVSEndpoint_AcquireRelease(t *testing.T) {
 	}
 }
 
+func TestKVSEndpoint_GET_LockDelay(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// Acquire the lock with a session that has the default lock-delay.
+	id := makeTestSession(t, a.srv)
+	req, _ := http.NewRequest("PUT", "/v1/kv/test?acquire="+id, bytes.NewReader(nil))
+	resp := httptest.NewRecorder()
+	if _, err := a.srv.KVSEndpoint(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// There's no lock-delay while the lock is held.
+	req, _ = http.NewRequest("GET", "/v1/kv/test", nil)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.KVSEndpoint(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v := resp.Header().Get("X-Consul-LockDelay"); v != "" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	// Destroying the session starts it.
+	req, _ = http.NewRequest("PUT", "/v1/session/destroy/"+id, nil)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.SessionDestroy(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req, _ = http.NewRequest("GET", "/v1/kv/test", nil)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.KVSEndpoint(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	delay, err := strconv.Atoi(resp.Header().Get("X-Consul-LockDelay"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if delay <= 0 || delay > 15000 {
+		t.Fatalf("bad: %d", delay)
+	}
+}
+
 func TestKVSEndpoint_GET_Raw(t *testing.T) {
 	t.Parallel()
 	a := NewTestAgent(t.Name(), "")
@@ -1,6 +1,7 @@
 package local
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"math/rand"
@@ -26,6 +27,7 @@ import (
 type Config struct {
 	AdvertiseAddr       string
 	CheckUpdateInterval time.Duration
+	CheckSyncLimit      int
 	Datacenter          string
 	DiscardCheckOutput  bool
 	NodeID              types.NodeID
@@ -192,8 +194,9 @@ type State struct {
 
 	// notifyHandlers is a map of registered channel listeners that are sent
 	// messages whenever state changes occur. For now these events only include
-	// service registration and deregistration since that is all that is needed
-	// but the same mechanism could be used for other state changes.
+	// service registration and deregistration, and check status changes, since
+	// that is all that is needed but the same mechanism could be used for other
+	// state changes.
 	//
 	// Note that we haven't refactored managedProxyHandlers into this mechanism
 	// yet because that is soon to be deprecated and removed so it's easier to
@@ -511,6 +514,10 @@ func (l *State) UpdateCheck(id types.CheckID, status, output string) {
 	if l.discardCheckOutput.Load().(bool) {
 		output = ""
 	}
+	if limit := l.config.CheckSyncLimit; limit > 0 && len(output) > limit {
+		output = truncateCheckOutput(output, limit)
+		metrics.IncrCounter([]string{"agent", "check", "output", "truncated"}, 1)
+	}
 
 	// Update the critical time tracking (this doesn't cause a server updates
 	// so we can always keep this up to date).
@@ -569,6 +576,11 @@ func (l *State) UpdateCheck(id types.CheckID, status, output string) {
 		}
 	}
 
+	// Let anyone watching the local state know about status changes.
+	if c.Check.Status != status {
+		defer l.broadcastUpdateLocked()
+	}
+
 	// Update status and mark out of sync
 	c.Check.Status = status
 	c.Check.Output = output
@@ -576,6 +588,15 @@ func (l *State) UpdateCheck(id types.CheckID, status, output string) {
 	l.TriggerSyncChanges()
 }
 
+// truncateCheckOutput cuts output down to its first limit bytes and appends
+// a hash of the full output. The result only depends on the output, so
+// running a check that keeps producing the same long output is still
+// idempotent, while a change anywhere in the output is synced.
+func truncateCheckOutput(output string, limit int) string {
+	sum := sha256.Sum256([]byte(output))
+	return fmt.Sprintf("%s\n... (captured %d of %d bytes, sha256 %x)", output[:limit], limit, len(output), sum)
+}
+
 // Check returns the locally registered check that the
 // agent is aware of and are being kept in sync with the server
 func (l *State) Check(id types.CheckID) *structs.HealthCheck {
@@ -855,8 +876,8 @@ func (l *State) broadcastUpdateLocked() {
 }
 
 // Notify will register a channel to receive messages when the local state
-// changes. Only service add/remove are supported for now. See notes on
-// l.notifyHandlers for more details.
+// changes. Only service add/remove and check status changes are supported for
+// now. See notes on l.notifyHandlers for more details.
 //
 // This will not block on channel send so ensure the channel has a buffer. Note
 // that any buffer size is generally fine since actual data is not sent over the
@@ -973,12 +994,12 @@ func (l *State) updateSyncState() error {
 	}
 
 	var out1 structs.IndexedNodeServices
-	if err := l.Delegate.RPC("Catalog.NodeServices", &req, &out1); err != nil {
+	if err := l.rpc("Catalog.NodeServices", &req, &out1, &req.Token); err != nil {
 		return err
 	}
 
 	var out2 structs.IndexedHealthChecks
-	if err := l.Delegate.RPC("Health.NodeChecks", &req, &out2); err != nil {
+	if err := l.rpc("Health.NodeChecks", &req, &out2, &req.Token); err != nil {
 		return err
 	}
 
@@ -1203,7 +1224,7 @@ func (l *State) deleteService(id string) error {
 		WriteRequest: structs.WriteRequest{Token: l.serviceToken(id)},
 	}
 	var out struct{}
-	err := l.Delegate.RPC("Catalog.Deregister", &req, &out)
+	err := l.rpc("Catalog.Deregister", &req, &out, &req.Token)
 	switch {
 	case err == nil || strings.Contains(err.Error(), "Unknown service"):
 		delete(l.services, id)
@@ -1237,7 +1258,7 @@ func (l *State) deleteCheck(id types.CheckID) error {
 		WriteRequest: structs.WriteRequest{Token: l.checkToken(id)},
 	}
 	var out struct{}
-	err := l.Delegate.RPC("Catalog.Deregister", &req, &out)
+	err := l.rpc("Catalog.Deregister", &req, &out, &req.Token)
 	switch {
 	case err == nil || strings.Contains(err.Error(), "Unknown check"):
 		c := l.checks[id]
@@ -1303,7 +1324,7 @@ func (l *State) syncService(id string) error {
 	}
 
 	var out struct{}
-	err := l.Delegate.RPC("Catalog.Register", &req, &out)
+	err := l.rpc("Catalog.Register", &req, &out, &req.Token)
 	switch {
 	case err == nil:
 		l.services[id].InSync = true
@@ -1355,7 +1376,7 @@ func (l *State) syncCheck(id types.CheckID) error {
 	}
 
 	var out struct{}
-	err := l.Delegate.RPC("Catalog.Register", &req, &out)
+	err := l.rpc("Catalog.Register", &req, &out, &req.Token)
 	switch {
 	case err == nil:
 		l.checks[id].InSync = true
@@ -1390,7 +1411,7 @@ func (l *State) syncNodeInfo() error {
 		WriteRequest:    structs.WriteRequest{Token: l.tokens.AgentToken()},
 	}
 	var out struct{}
-	err := l.Delegate.RPC("Catalog.Register", &req, &out)
+	err := l.rpc("Catalog.Register", &req, &out, &req.Token)
 	switch {
 	case err == nil:
 		l.nodeInfoInSync = true
@@ -1410,3 +1431,18 @@ func (l *State) syncNodeInfo() error {
 		return err
 	}
 }
+
+// rpc makes an RPC to the servers with the token the args point to. If the
+// token was just rotated and the servers don't know it yet, the RPC is retried
+// with the previous token so syncing carries on during the rotation.
+func (l *State) rpc(method string, args, reply interface{}, token *string) error {
+	err := l.Delegate.RPC(method, args, reply)
+	if acl.IsErrNotFound(err) {
+		if previous, ok := l.tokens.PreviousToken(*token); ok {
+			l.logger.Printf("[DEBUG] agent: Retrying %s with the previous token", method)
+			*token = previous
+			err = l.Delegate.RPC(method, args, reply)
+		}
+	}
+	return err
+}
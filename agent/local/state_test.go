@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 
 	"github.com/hashicorp/go-memdb"
 
+	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent"
 	"github.com/hashicorp/consul/agent/config"
 	"github.com/hashicorp/consul/agent/local"
@@ -1381,6 +1383,41 @@ func TestAgent_UpdateCheck_DiscardOutput(t *testing.T) {
 	}
 }
 
+func TestAgent_UpdateCheck_SyncLimit(t *testing.T) {
+	t.Parallel()
+	a := agent.NewTestAgent(t.Name(), `
+		check_output_sync_limit = 10
+		check_update_interval = "0s" # set to "0s" since otherwise output checks are deferred
+	`)
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	// register a check with long output
+	long := strings.Repeat("x", 100)
+	check := &structs.HealthCheck{
+		Node:    a.Config.NodeName,
+		CheckID: "web",
+		Name:    "web",
+		Status:  api.HealthPassing,
+	}
+	require.NoError(t, a.State.AddCheck(check, ""))
+	a.State.UpdateCheck(check.CheckID, api.HealthPassing, long)
+	require.NoError(t, a.State.SyncFull())
+
+	// the output is truncated to the limit and keeps a hash of the rest.
+	s := a.State.CheckState(check.CheckID)
+	require.True(t, s.InSync)
+	require.True(t, strings.HasPrefix(s.Check.Output, "xxxxxxxxxx\n... (captured 10 of 100 bytes, sha256 "), s.Check.Output)
+
+	// the same output again doesn't need a sync.
+	a.State.UpdateCheck(check.CheckID, api.HealthPassing, long)
+	require.True(t, a.State.CheckState(check.CheckID).InSync)
+
+	// a change past the limit does.
+	a.State.UpdateCheck(check.CheckID, api.HealthPassing, long+"y")
+	require.False(t, a.State.CheckState(check.CheckID).InSync)
+}
+
 func TestAgentAntiEntropy_Check_DeferSync(t *testing.T) {
 	t.Parallel()
 	a := &agent.TestAgent{Name: t.Name(), HCL: `
@@ -2221,3 +2258,38 @@ func drainCh(ch chan struct{}) {
 		}
 	}
 }
+
+// tokenDelegate fails RPCs made with the rejected token as if the servers
+// didn't know it, and records the tokens of the RPCs that succeed.
+type tokenDelegate struct {
+	rejected string
+	accepted []string
+}
+
+func (d *tokenDelegate) RPC(method string, args interface{}, reply interface{}) error {
+	token := args.(*structs.RegisterRequest).Token
+	if token == d.rejected {
+		return acl.ErrNotFound
+	}
+	d.accepted = append(d.accepted, token)
+	return nil
+}
+
+func TestState_SyncChanges_TokenRotation(t *testing.T) {
+	t.Parallel()
+
+	tokens := new(token.Store)
+	tokens.SetRotationGrace(time.Hour)
+	tokens.UpdateAgentToken("old")
+	tokens.UpdateAgentToken("new")
+
+	state := local.NewState(local.Config{}, log.New(os.Stderr, "", log.LstdFlags), tokens)
+	state.TriggerSyncChanges = func() {}
+	d := &tokenDelegate{rejected: "new"}
+	state.Delegate = d
+
+	// The servers don't know the new token yet, so the previous one is
+	// used instead.
+	require.NoError(t, state.SyncChanges())
+	require.Equal(t, []string{"old"}, d.accepted)
+}
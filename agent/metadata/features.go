@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"sort"
+	"strings"
+)
+
+// Features are optional parts of the RPC protocol that were added after
+// protocol version negotiation. Servers advertise the features they support
+// in the "ft" Serf tag, so agents and other servers can check a server will
+// understand a request before sending it, rather than having an older server
+// silently ignore new fields or fail with an unknown method.
+const (
+	// FeatureNamespaces is the Namespace RPC endpoint of the namespace registry.
+	FeatureNamespaces = "ns"
+
+	// FeatureServiceFailover is the ServiceFailover RPC endpoint and the
+	// SkipFailover field of service queries.
+	FeatureServiceFailover = "sfo"
+
+	// FeatureMultiDC is the MultiDC field of service queries.
+	FeatureMultiDC = "mdc"
+
+	// FeatureElections is the Election RPC endpoint.
+	FeatureElections = "elec"
+
+	// FeatureChangeFeed is the ChangeFeed RPC endpoint.
+	FeatureChangeFeed = "cf"
+
+	// FeatureConfigEntries is the ConfigEntry RPC endpoint and the config
+	// entries written to the Raft log.
+	FeatureConfigEntries = "ce"
+
+	// FeatureSnapshotChecksum is the checksum at the end of FSM snapshots.
+	// Older servers fail to restore a snapshot with a record type they
+	// don't know, so it's only written once every server supports it.
+	FeatureSnapshotChecksum = "sck"
+
+	// FeatureResponseSigning is the ResponseSigning RPC endpoint and the Sign
+	// field of service queries.
+	FeatureResponseSigning = "rsig"
+
+	// FeatureLeaderHistory is the Operator.LeaderHistory RPC and the leader
+	// transitions written to the Raft log.
+	FeatureLeaderHistory = "lh"
+
+	// FeatureACLTokenUsage is the ACL.TokenUsageUpdate RPC and the token
+	// usage written to the Raft log.
+	FeatureACLTokenUsage = "atu"
+
+	// FeatureCatalogCAS is the CAS field of catalog registrations and
+	// deregistrations. Older servers apply them unconditionally, so it's
+	// refused until every server supports it.
+	FeatureCatalogCAS = "ccas"
+
+	// FeatureACLLogin is the ACL.Login and ACL.Logout RPCs. Older servers
+	// ignore the expiration time of the tokens they create, so logging in
+	// is refused until every server supports it.
+	FeatureACLLogin = "alog"
+
+	// FeatureBootstrapData is the record of which parts of the bootstrap
+	// data directory were loaded, which is written to the Raft log.
+	FeatureBootstrapData = "bsd"
+
+	// FeatureQueryPinning is the PinTTL field of prepared queries and
+	// prepared query executions. Older servers would drop it from the
+	// queries they store, so queries using it are refused until every
+	// server supports it.
+	FeatureQueryPinning = "qpin"
+)
+
+// SupportedFeatures returns the features supported by this version of Consul.
+func SupportedFeatures() []string {
+	return []string{
+		FeatureNamespaces,
+		FeatureServiceFailover,
+		FeatureMultiDC,
+		FeatureElections,
+		FeatureChangeFeed,
+		FeatureConfigEntries,
+		FeatureSnapshotChecksum,
+		FeatureResponseSigning,
+		FeatureLeaderHistory,
+		FeatureACLTokenUsage,
+		FeatureCatalogCAS,
+		FeatureACLLogin,
+		FeatureBootstrapData,
+		FeatureQueryPinning,
+	}
+}
+
+// EncodeFeatures returns the value of the Serf tag for the given features.
+func EncodeFeatures(features []string) string {
+	sorted := append([]string(nil), features...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// decodeFeatures parses the value of the features Serf tag.
+func decodeFeatures(tag string) map[string]struct{} {
+	features := make(map[string]struct{})
+	for _, feature := range strings.Split(tag, ",") {
+		if feature != "" {
+			features[feature] = struct{}{}
+		}
+	}
+	return features
+}
+
+// SupportsFeature returns true if the server advertised support for the given
+// feature.
+func (s *Server) SupportsFeature(feature string) bool {
+	_, ok := s.Features[feature]
+	return ok
+}
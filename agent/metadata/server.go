@@ -42,8 +42,19 @@ type Server struct {
 	NonVoter     bool
 	ACLs         structs.ACLMode
 
+	// Features is the set of optional RPC features the server supports.
+	Features map[string]struct{}
+
+	// Gateways are the addresses of the WAN federation gateways that other
+	// datacenters reach this server's datacenter through, if any.
+	Gateways []string
+
 	// If true, use TLS when connecting to this server
 	UseTLS bool
+
+	// ExternalChecks is true if the server takes part in running the
+	// checks of external nodes.
+	ExternalChecks bool
 }
 
 // Key returns the corresponding Key
@@ -152,27 +163,37 @@ func IsConsulServer(m serf.Member) (bool, *Server) {
 	// Check if the server is a non voter
 	_, nonVoter := m.Tags["nonvoter"]
 
+	_, externalChecks := m.Tags["ext_checks"]
+
+	var gateways []string
+	if gatewaysStr := m.Tags["gw"]; gatewaysStr != "" {
+		gateways = strings.Split(gatewaysStr, ",")
+	}
+
 	addr := &net.TCPAddr{IP: m.Addr, Port: port}
 
 	parts := &Server{
-		Name:         m.Name,
-		ID:           m.Tags["id"],
-		Datacenter:   datacenter,
-		Segment:      segment,
-		Port:         port,
-		SegmentAddrs: segmentAddrs,
-		SegmentPorts: segmentPorts,
-		WanJoinPort:  wanJoinPort,
-		Bootstrap:    bootstrap,
-		Expect:       expect,
-		Addr:         addr,
-		Build:        *buildVersion,
-		Version:      vsn,
-		RaftVersion:  raftVsn,
-		Status:       m.Status,
-		UseTLS:       useTLS,
-		NonVoter:     nonVoter,
-		ACLs:         acls,
+		Name:           m.Name,
+		ID:             m.Tags["id"],
+		Datacenter:     datacenter,
+		Segment:        segment,
+		Port:           port,
+		SegmentAddrs:   segmentAddrs,
+		SegmentPorts:   segmentPorts,
+		WanJoinPort:    wanJoinPort,
+		Bootstrap:      bootstrap,
+		Expect:         expect,
+		Addr:           addr,
+		Build:          *buildVersion,
+		Version:        vsn,
+		RaftVersion:    raftVsn,
+		Status:         m.Status,
+		UseTLS:         useTLS,
+		NonVoter:       nonVoter,
+		ACLs:           acls,
+		Features:       decodeFeatures(m.Tags["ft"]),
+		Gateways:       gateways,
+		ExternalChecks: externalChecks,
 	}
 	return true, parts
 }
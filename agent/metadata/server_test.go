@@ -2,6 +2,7 @@ package metadata_test
 
 import (
 	"net"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/consul/agent/metadata"
@@ -214,3 +215,74 @@ func TestIsConsulServer_Optional(t *testing.T) {
 		t.Fatalf("unexpected ok server")
 	}
 }
+
+func TestIsConsulServer_Features(t *testing.T) {
+	m := serf.Member{
+		Name: "foo",
+		Addr: net.IP([]byte{127, 0, 0, 1}),
+		Tags: map[string]string{
+			"role":  "consul",
+			"dc":    "east-aws",
+			"port":  "10000",
+			"build": "1.4.0",
+			"vsn":   "1",
+			"ft":    metadata.EncodeFeatures([]string{"sfo", "ns"}),
+		},
+	}
+	if m.Tags["ft"] != "ns,sfo" {
+		t.Fatalf("bad: %v", m.Tags["ft"])
+	}
+
+	ok, parts := metadata.IsConsulServer(m)
+	if !ok {
+		t.Fatalf("expected server")
+	}
+	if !parts.SupportsFeature(metadata.FeatureNamespaces) ||
+		!parts.SupportsFeature(metadata.FeatureServiceFailover) {
+		t.Fatalf("bad: %v", parts.Features)
+	}
+	if parts.SupportsFeature("nope") {
+		t.Fatalf("bad: %v", parts.Features)
+	}
+
+	// Servers from before features were advertised don't support any.
+	delete(m.Tags, "ft")
+	ok, parts = metadata.IsConsulServer(m)
+	if !ok {
+		t.Fatalf("expected server")
+	}
+	if parts.SupportsFeature(metadata.FeatureNamespaces) {
+		t.Fatalf("bad: %v", parts.Features)
+	}
+}
+
+func TestIsConsulServer_Gateways(t *testing.T) {
+	m := serf.Member{
+		Name: "foo",
+		Addr: net.IP([]byte{127, 0, 0, 1}),
+		Tags: map[string]string{
+			"role":  "consul",
+			"dc":    "east-aws",
+			"port":  "10000",
+			"build": "1.4.0",
+			"vsn":   "1",
+			"gw":    "10.0.0.1:8443,10.0.0.2:8443",
+		},
+	}
+	ok, parts := metadata.IsConsulServer(m)
+	if !ok {
+		t.Fatalf("expected server")
+	}
+	if !reflect.DeepEqual(parts.Gateways, []string{"10.0.0.1:8443", "10.0.0.2:8443"}) {
+		t.Fatalf("bad: %v", parts.Gateways)
+	}
+
+	delete(m.Tags, "gw")
+	ok, parts = metadata.IsConsulServer(m)
+	if !ok {
+		t.Fatalf("expected server")
+	}
+	if len(parts.Gateways) != 0 {
+		t.Fatalf("bad: %v", parts.Gateways)
+	}
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/consul"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// GET /v1/namespaces
+func (s *HTTPServer) NamespaceList(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedNamespaces
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("Namespace.List", &args, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Namespaces, nil
+}
+
+// NamespaceSpecific handles the GET, PUT and DELETE operations on a single
+// namespace at /v1/namespace/:name.
+func (s *HTTPServer) NamespaceSpecific(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	name := strings.TrimPrefix(req.URL.Path, "/v1/namespace/")
+	if name == "" {
+		return nil, BadRequestError{Reason: "Missing namespace name"}
+	}
+
+	switch req.Method {
+	case "GET":
+		return s.namespaceGet(name, resp, req)
+	case "PUT":
+		return s.namespaceApply(structs.NamespaceOpUpsert, name, resp, req)
+	case "DELETE":
+		return s.namespaceApply(structs.NamespaceOpDelete, name, resp, req)
+	default:
+		return nil, MethodNotAllowedError{req.Method, []string{"GET", "PUT", "DELETE"}}
+	}
+}
+
+// GET /v1/namespace/:name
+func (s *HTTPServer) namespaceGet(name string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.NamespaceSpecificRequest{
+		Name: name,
+	}
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedNamespaces
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("Namespace.Get", &args, &reply); err != nil {
+		// We have to check the string since the RPC sheds the error type
+		if err.Error() == consul.ErrNamespaceNotFound.Error() {
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(resp, err.Error())
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// This shouldn't happen since the RPC returns an error if the namespace
+	// doesn't exist.
+	if len(reply.Namespaces) != 1 {
+		return nil, fmt.Errorf("internal error loading namespace")
+	}
+	return reply.Namespaces[0], nil
+}
+
+// PUT and DELETE /v1/namespace/:name
+func (s *HTTPServer) namespaceApply(op structs.NamespaceOp, name string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.NamespaceRequest{
+		Op:        op,
+		Namespace: &structs.Namespace{},
+	}
+	s.parseDC(req, &args.Datacenter)
+	s.parseToken(req, &args.Token)
+
+	// The body is optional since a namespace only needs a name.
+	if op == structs.NamespaceOpUpsert {
+		if err := decodeBody(req, args.Namespace, nil); err != nil && err != io.EOF {
+			return nil, BadRequestError{Reason: fmt.Sprintf("Request decode failed: %v", err)}
+		}
+	}
+	if args.Namespace.Name != "" && args.Namespace.Name != name {
+		return nil, BadRequestError{Reason: "Namespace name in URL and payload do not match"}
+	}
+	args.Namespace.Name = name
+
+	var reply struct{}
+	if err := s.agent.RPC("Namespace.Apply", &args, &reply); err != nil {
+		if strings.Contains(err.Error(), "Invalid namespace name") {
+			return nil, BadRequestError{Reason: err.Error()}
+		}
+		return nil, err
+	}
+	return true, nil
+}
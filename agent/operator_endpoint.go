@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent/consul/autopilot"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/api"
@@ -29,6 +30,44 @@ func (s *HTTPServer) OperatorRaftConfiguration(resp http.ResponseWriter, req *ht
 	return reply, nil
 }
 
+// OperatorRaftVerify is used to check the Raft log and latest snapshot on
+// disk for corruption. This supports the stale query mode to check the server
+// that gets the request instead of the leader.
+func (s *HTTPServer) OperatorRaftVerify(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.RaftVerifyResponse
+	if err := s.agent.RPC("Operator.RaftVerify", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// OperatorRaftLeaderHistory is used to get the recorded leader transitions,
+// newest first.
+func (s *HTTPServer) OperatorRaftLeaderHistory(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedLeaderTransitions
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("Operator.LeaderHistory", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	// Use empty list instead of nil
+	if reply.Transitions == nil {
+		reply.Transitions = make(structs.LeaderTransitions, 0)
+	}
+	return reply.Transitions, nil
+}
+
 // OperatorRaftPeer supports actions on Raft peers. Currently we only support
 // removing peers by address.
 func (s *HTTPServer) OperatorRaftPeer(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
@@ -276,8 +315,10 @@ func (s *HTTPServer) OperatorServerHealth(resp http.ResponseWriter, req *http.Re
 	}
 
 	out := &api.OperatorHealthReply{
-		Healthy:          reply.Healthy,
-		FailureTolerance: reply.FailureTolerance,
+		Healthy:               reply.Healthy,
+		FailureTolerance:      reply.FailureTolerance,
+		RecentLeaderElections: reply.RecentLeaderElections,
+		LeaderFlapping:        reply.LeaderFlapping,
 	}
 	for _, server := range reply.Servers {
 		out.Servers = append(out.Servers, api.ServerHealth{
@@ -298,3 +339,187 @@ func (s *HTTPServer) OperatorServerHealth(resp http.ResponseWriter, req *http.Re
 
 	return out, nil
 }
+
+// OperatorCatalogConsistency is used to get the findings of the last catalog
+// consistency check run by the leader.
+func (s *HTTPServer) OperatorCatalogConsistency(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.CatalogConsistencyReport
+	if err := s.agent.RPC("Operator.CatalogConsistency", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// OperatorCatalogExport is used to export the catalog and KV store.
+func (s *HTTPServer) OperatorCatalogExport(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.CatalogExport
+	if err := s.agent.RPC("Operator.CatalogExport", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	// Use empty lists instead of null, so the schema holds even for an
+	// empty datacenter.
+	if reply.Nodes == nil {
+		reply.Nodes = []*structs.CatalogExportNode{}
+	}
+	for _, node := range reply.Nodes {
+		if node.Services == nil {
+			node.Services = []*structs.NodeService{}
+		}
+		if node.Checks == nil {
+			node.Checks = structs.HealthChecks{}
+		}
+	}
+	if reply.KV == nil {
+		reply.KV = []*structs.CatalogExportKV{}
+	}
+	return reply, nil
+}
+
+// OperatorKVReplicationStatus is used to get the status of KV replication
+// from another datacenter.
+func (s *HTTPServer) OperatorKVReplicationStatus(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.KVReplicationStatus
+	if err := s.agent.RPC("Operator.KVReplicationStatus", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// OperatorClockSkew is used to get how far the clocks of the nodes are from
+// the leader's.
+func (s *HTTPServer) OperatorClockSkew(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.ClockSkewReport
+	if err := s.agent.RPC("Operator.ClockSkew", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// OperatorStateMemory is used to get the state store memory usage found by
+// the last accounting run on the leader.
+func (s *HTTPServer) OperatorStateMemory(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.StateMemoryReport
+	if err := s.agent.RPC("Operator.StateMemory", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// OperatorServerFeatures is used to get the optional protocol features of a
+// datacenter's servers, and which servers don't support them.
+func (s *HTTPServer) OperatorServerFeatures(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.ServerFeaturesResponse
+	if err := s.agent.RPC("Operator.ServerFeatures", &args, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// OperatorRuntimeSettings is used to read and change the settings of this
+// agent that can be changed while it's running.
+func (s *HTTPServer) OperatorRuntimeSettings(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var token string
+	s.parseToken(req, &token)
+	rule, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "GET":
+		if rule != nil && !rule.OperatorRead() {
+			return nil, acl.ErrPermissionDenied
+		}
+
+	case "PUT":
+		if rule != nil && !rule.OperatorWrite() {
+			return nil, acl.ErrPermissionDenied
+		}
+
+		var update api.RuntimeSettings
+		durations := NewDurationFixer("slowquerythreshold", "maxquerytime")
+		if err := decodeBody(req, &update, durations.FixupDurations); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(resp, "Error parsing runtime settings: %v", err)
+			return nil, nil
+		}
+
+		settings := s.agent.RuntimeSettings()
+		if update.LogLevel != "" {
+			settings.LogLevel = update.LogLevel
+		}
+		if update.SlowQueryThreshold != nil {
+			settings.SlowQueryThreshold = update.SlowQueryThreshold.Duration()
+		}
+		if update.MaxQueryTime != nil {
+			settings.MaxQueryTime = update.MaxQueryTime.Duration()
+		}
+		if update.RPCServerReadRate != nil {
+			settings.RPCServerReadRate = *update.RPCServerReadRate
+		}
+		if update.RPCServerWriteRate != nil {
+			settings.RPCServerWriteRate = *update.RPCServerWriteRate
+		}
+		if update.RPCServerTokenRate != nil {
+			settings.RPCServerTokenRate = *update.RPCServerTokenRate
+		}
+		if update.RPCServerMaxBurst != nil {
+			settings.RPCServerMaxBurst = *update.RPCServerMaxBurst
+		}
+		if err := s.agent.SetRuntimeSettings(settings); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(resp, err.Error())
+			return nil, nil
+		}
+	}
+
+	settings := s.agent.RuntimeSettings()
+	out := api.RuntimeSettings{
+		LogLevel: settings.LogLevel,
+	}
+	if s.agent.config.ServerMode {
+		out.SlowQueryThreshold = api.NewReadableDuration(settings.SlowQueryThreshold)
+		out.MaxQueryTime = api.NewReadableDuration(settings.MaxQueryTime)
+		out.RPCServerReadRate = &settings.RPCServerReadRate
+		out.RPCServerWriteRate = &settings.RPCServerWriteRate
+		out.RPCServerTokenRate = &settings.RPCServerTokenRate
+		out.RPCServerMaxBurst = &settings.RPCServerMaxBurst
+	}
+	return out, nil
+}
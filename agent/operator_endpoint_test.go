@@ -7,9 +7,11 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/testrpc"
 
+	"github.com/hashicorp/consul/acl"
 	"github.com/hashicorp/consul/agent/consul/autopilot"
 	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/api"
@@ -42,6 +44,29 @@ func TestOperator_RaftConfiguration(t *testing.T) {
 	}
 }
 
+func TestOperator_RaftVerify(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	req, _ := http.NewRequest("GET", "/v1/operator/raft/verify", nil)
+	resp := httptest.NewRecorder()
+	obj, err := a.srv.OperatorRaftVerify(resp, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 200 {
+		t.Fatalf("bad code: %d", resp.Code)
+	}
+	out, ok := obj.(structs.RaftVerifyResponse)
+	if !ok {
+		t.Fatalf("unexpected: %T", obj)
+	}
+	if out.Node != a.Config.NodeName || out.LastIndex == 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
 func TestOperator_RaftPeer(t *testing.T) {
 	t.Parallel()
 	t.Run("", func(t *testing.T) {
@@ -473,3 +498,80 @@ func TestOperator_ServerHealth_Unhealthy(t *testing.T) {
 		}
 	})
 }
+
+func TestOperator_RuntimeSettings(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+
+	body := bytes.NewBuffer([]byte(`{"SlowQueryThreshold": "250ms", "RPCServerReadRate": 100}`))
+	req, _ := http.NewRequest("PUT", "/v1/operator/runtime", body)
+	resp := httptest.NewRecorder()
+	obj, err := a.srv.OperatorRuntimeSettings(resp, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 200 {
+		t.Fatalf("bad code: %d", resp.Code)
+	}
+	out := obj.(api.RuntimeSettings)
+	if out.SlowQueryThreshold.Duration() != 250*time.Millisecond || *out.RPCServerReadRate != 100 {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	settings := a.RuntimeSettings()
+	if settings.SlowQueryThreshold != 250*time.Millisecond || settings.RPCServerReadRate != 100 ||
+		settings.RPCServerWriteRate != 0 || settings.MaxQueryTime == 0 {
+		t.Fatalf("bad: %#v", settings)
+	}
+
+	// Invalid settings are rejected as a whole.
+	body = bytes.NewBuffer([]byte(`{"SlowQueryThreshold": "1s", "RPCServerMaxBurst": 0}`))
+	req, _ = http.NewRequest("PUT", "/v1/operator/runtime", body)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.OperatorRuntimeSettings(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 400 {
+		t.Fatalf("bad code: %d", resp.Code)
+	}
+	if settings := a.RuntimeSettings(); settings.SlowQueryThreshold != 250*time.Millisecond {
+		t.Fatalf("bad: %#v", settings)
+	}
+
+	// Blocking queries can't be allowed to run longer than the RPC timeouts
+	// are sized for.
+	body = bytes.NewBuffer([]byte(`{"MaxQueryTime": "11m"}`))
+	req, _ = http.NewRequest("PUT", "/v1/operator/runtime", body)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.OperatorRuntimeSettings(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 400 {
+		t.Fatalf("bad code: %d", resp.Code)
+	}
+}
+
+func TestOperator_RuntimeSettings_ACLDeny(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), TestACLConfig())
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	body := bytes.NewBuffer([]byte(`{"SlowQueryThreshold": "250ms"}`))
+	req, _ := http.NewRequest("PUT", "/v1/operator/runtime", body)
+	resp := httptest.NewRecorder()
+	if _, err := a.srv.OperatorRuntimeSettings(resp, req); !acl.IsErrPermissionDenied(err) {
+		t.Fatalf("err: %v", err)
+	}
+
+	body = bytes.NewBuffer([]byte(`{"SlowQueryThreshold": "250ms"}`))
+	req, _ = http.NewRequest("PUT", "/v1/operator/runtime?token=root", body)
+	resp = httptest.NewRecorder()
+	if _, err := a.srv.OperatorRuntimeSettings(resp, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Code != 200 {
+		t.Fatalf("bad code: %d", resp.Code)
+	}
+}
@@ -0,0 +1,321 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/types"
+)
+
+// persistedStateIssue is a problem with one of the files the agent persists
+// services, checks and proxies to in its data dir.
+type persistedStateIssue struct {
+	// File is the path of the file with the problem.
+	File string
+
+	// Problem describes what's wrong with the file.
+	Problem string
+
+	// Action describes what repairing the file does, and repair does it.
+	// Problems that can't be repaired by the agent have no repair.
+	Action string
+	repair func() error
+}
+
+func (i persistedStateIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.File, i.Problem)
+}
+
+// checkPersistedState looks for problems with the services, checks and
+// proxies persisted in the data dir that would otherwise make the agent fail
+// to start, or restore them in an unexpected way. It doesn't change
+// anything, but returns how each problem can be repaired.
+//
+// The same rules decide which definition is kept every time:
+//
+//   - definitions from the config files take precedence over persisted
+//     ones with the same ID
+//   - a persisted definition must be in the file named after its ID. One in
+//     another file is moved there, unless that file already exists, in which
+//     case it's a duplicate and removed
+//   - checks and proxies of services that aren't defined anywhere, and check
+//     states of checks that aren't defined anywhere, are removed
+//   - files that can't be decoded, or were only partially written, are
+//     removed
+//
+// Script checks whose executable doesn't exist are reported, but kept.
+func (a *Agent) checkPersistedState(conf *config.RuntimeConfig) ([]persistedStateIssue, error) {
+	var issues []persistedStateIssue
+	remove := func(file, problem string) {
+		issues = append(issues, persistedStateIssue{
+			File:    file,
+			Problem: problem,
+			Action:  "remove it",
+			repair:  func() error { return os.Remove(file) },
+		})
+	}
+	move := func(file, to, problem string) {
+		issues = append(issues, persistedStateIssue{
+			File:    file,
+			Problem: problem,
+			Action:  fmt.Sprintf("move it to %q", to),
+			repair:  func() error { return os.Rename(file, to) },
+		})
+	}
+
+	// Gather the IDs defined in the config files.
+	configServices := make(map[string]bool)
+	configChecks := make(map[types.CheckID]bool)
+	for _, svc := range conf.Services {
+		ns := svc.NodeService()
+		if ns.ID == "" {
+			ns.ID = ns.Service
+		}
+		configServices[ns.ID] = true
+		if ns.Connect.SidecarService != nil {
+			configServices[a.sidecarServiceID(ns.ID)] = true
+		}
+
+		chkTypes, err := svc.CheckTypes()
+		if err != nil {
+			// This is reported when the service is loaded.
+			continue
+		}
+		for i, chkType := range chkTypes {
+			checkID := chkType.CheckID
+			if checkID == "" {
+				checkID = types.CheckID(fmt.Sprintf("service:%s", ns.ID))
+				if len(chkTypes) > 1 {
+					checkID += types.CheckID(fmt.Sprintf(":%d", i+1))
+				}
+			}
+			configChecks[checkID] = true
+		}
+	}
+	for _, check := range conf.Checks {
+		configChecks[check.HealthCheck(conf.NodeName).CheckID] = true
+	}
+
+	// placed keeps track of the files that hold a definition, including
+	// the ones definitions will be moved to, so duplicates are found.
+	placed := make(map[string]bool)
+	place := func(dir, name, file, kind, id string) bool {
+		want := filepath.Join(dir, name)
+		if file == want {
+			placed[want] = true
+			return true
+		}
+		if placed[want] || fileExists(want) {
+			remove(file, fmt.Sprintf("%s %q is a duplicate of the one in %q", kind, id, want))
+			return false
+		}
+		placed[want] = true
+		move(file, want, fmt.Sprintf("%s %q isn't in the file named after its ID", kind, id))
+		return true
+	}
+
+	// Services.
+	services := make(map[string]bool)
+	for id := range configServices {
+		services[id] = true
+	}
+	dir := filepath.Join(conf.DataDir, servicesDir)
+	files, err := persistedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "tmp") {
+			remove(file, "service file was only partially written")
+			continue
+		}
+		var p persistedService
+		err := decodePersistedFile(file, &p)
+		if err == nil && p.Service == nil {
+			// Pre-0.5.1 services were persisted without the wrapper.
+			err = decodePersistedFile(file, &p.Service)
+		}
+		if err != nil {
+			remove(file, fmt.Sprintf("service file can't be decoded: %v", err))
+			continue
+		}
+		if p.Service == nil || p.Service.Service == "" {
+			remove(file, "service has no name")
+			continue
+		}
+		id := p.Service.ID
+		if id == "" {
+			id = p.Service.Service
+		}
+		if configServices[id] {
+			remove(file, fmt.Sprintf("service %q is also defined in the config files, which take precedence", id))
+			continue
+		}
+		if place(dir, stringHash(id), file, "service", id) {
+			services[id] = true
+		}
+	}
+
+	// Proxies.
+	dir = filepath.Join(conf.DataDir, proxyDir)
+	if files, err = persistedFiles(dir); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "tmp") {
+			remove(file, "proxy file was only partially written")
+			continue
+		}
+		var p persistedProxy
+		if err := decodePersistedFile(file, &p); err != nil {
+			remove(file, fmt.Sprintf("proxy file can't be decoded: %v", err))
+			continue
+		}
+		if p.Proxy == nil || p.Proxy.ProxyService == nil {
+			remove(file, "proxy has no service")
+			continue
+		}
+		id := p.Proxy.ProxyService.ID
+		if !services[p.Proxy.TargetServiceID] {
+			remove(file, fmt.Sprintf("proxy %q is for service %q, which isn't defined", id, p.Proxy.TargetServiceID))
+			continue
+		}
+		place(dir, stringHash(id), file, "proxy", id)
+	}
+
+	// Checks.
+	checks := make(map[types.CheckID]bool)
+	for id := range configChecks {
+		checks[id] = true
+	}
+	dir = filepath.Join(conf.DataDir, checksDir)
+	if files, err = persistedFiles(dir); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "tmp") {
+			remove(file, "check file was only partially written")
+			continue
+		}
+		var p persistedCheck
+		if err := decodePersistedFile(file, &p); err != nil {
+			remove(file, fmt.Sprintf("check file can't be decoded: %v", err))
+			continue
+		}
+		if p.Check == nil || p.Check.CheckID == "" {
+			remove(file, "check has no ID")
+			continue
+		}
+		id := p.Check.CheckID
+		if configChecks[id] {
+			remove(file, fmt.Sprintf("check %q is also defined in the config files, which take precedence", id))
+			continue
+		}
+		if p.Check.ServiceID != "" && !services[p.Check.ServiceID] {
+			remove(file, fmt.Sprintf("check %q is for service %q, which isn't defined", id, p.Check.ServiceID))
+			continue
+		}
+		if !place(dir, checkIDHash(id), file, "check", string(id)) {
+			continue
+		}
+		checks[id] = true
+
+		if p.ChkType != nil && len(p.ChkType.ScriptArgs) > 0 {
+			if script := p.ChkType.ScriptArgs[0]; filepath.IsAbs(script) && !fileExists(script) {
+				issues = append(issues, persistedStateIssue{
+					File:    file,
+					Problem: fmt.Sprintf("check %q runs %q, which doesn't exist", id, script),
+				})
+			}
+		}
+	}
+
+	// Check states.
+	dir = filepath.Join(conf.DataDir, checkStateDir)
+	if files, err = persistedFiles(dir); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "tmp") {
+			remove(file, "check state file was only partially written")
+			continue
+		}
+		var p persistedCheckState
+		if err := decodePersistedFile(file, &p); err != nil {
+			remove(file, fmt.Sprintf("check state file can't be decoded: %v", err))
+			continue
+		}
+		if !checks[p.CheckID] {
+			remove(file, fmt.Sprintf("check state is for check %q, which isn't defined", p.CheckID))
+			continue
+		}
+		place(dir, checkIDHash(p.CheckID), file, "check state", string(p.CheckID))
+	}
+
+	return issues, nil
+}
+
+// reconcilePersistedState checks the persisted services, checks and proxies
+// before they're loaded. Problems are repaired if the agent was started with
+// -repair, and otherwise only logged along with how they would be repaired.
+func (a *Agent) reconcilePersistedState(conf *config.RuntimeConfig) error {
+	issues, err := a.checkPersistedState(conf)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		switch {
+		case issue.repair == nil:
+			a.logger.Printf("[WARN] agent: Persisted state problem: %s", issue)
+		case !conf.RepairPersistedState:
+			a.logger.Printf("[WARN] agent: Persisted state problem: %s. Start with -repair to %s", issue, issue.Action)
+		default:
+			if err := issue.repair(); err != nil {
+				return fmt.Errorf("failed repairing %s: %v", issue, err)
+			}
+			a.logger.Printf("[INFO] agent: Repaired persisted state problem: %s. Did %s", issue, issue.Action)
+		}
+	}
+	return nil
+}
+
+// persistedFiles returns the paths of the files in one of the persisted state
+// dirs, sorted by name. A missing dir has no files.
+func persistedFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Failed reading dir %q: %s", dir, err)
+	}
+
+	var files []string
+	for _, fi := range infos {
+		if !fi.IsDir() {
+			files = append(files, filepath.Join(dir, fi.Name()))
+		}
+	}
+	return files, nil
+}
+
+// decodePersistedFile decodes the JSON in the given file.
+func decodePersistedFile(file string, out interface{}) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, out)
+}
+
+// fileExists returns true if there's a file at the given path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
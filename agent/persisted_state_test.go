@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_CheckPersistedState(t *testing.T) {
+	t.Parallel()
+	dataDir := testutil.TempDir(t, "agent")
+	defer os.RemoveAll(dataDir)
+
+	write := func(dir, name string, v interface{}) string {
+		require.NoError(t, os.MkdirAll(filepath.Join(dataDir, dir), 0700))
+		file := filepath.Join(dataDir, dir, name)
+		buf, err := json.Marshal(v)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(file, buf, 0600))
+		return file
+	}
+	service := func(id string) persistedService {
+		return persistedService{Service: &structs.NodeService{ID: id, Service: id}}
+	}
+	check := func(id, serviceID string) persistedCheck {
+		return persistedCheck{Check: &structs.HealthCheck{CheckID: types.CheckID(id), ServiceID: serviceID}}
+	}
+
+	// Services.
+	write(servicesDir, stringHash("web"), service("web"))
+	configured := write(servicesDir, stringHash("db"), service("db"))
+	misplaced := write(servicesDir, "misplaced", service("api"))
+	duplicate := write(servicesDir, "duplicate", service("web"))
+	corrupt := filepath.Join(dataDir, servicesDir, "corrupt")
+	require.NoError(t, ioutil.WriteFile(corrupt, []byte("{"), 0600))
+	partial := write(servicesDir, stringHash("cache")+".tmp", service("cache"))
+
+	// Checks.
+	write(checksDir, checkIDHash("web-check"), check("web-check", "web"))
+	orphaned := write(checksDir, checkIDHash("gone-check"), check("gone-check", "gone"))
+	write(checksDir, checkIDHash("api-check"), check("api-check", "api"))
+
+	// Check states.
+	write(checkStateDir, checkIDHash("web-check"), persistedCheckState{CheckID: "web-check"})
+	stale := write(checkStateDir, checkIDHash("gone-check"), persistedCheckState{CheckID: "gone-check"})
+
+	conf := &config.RuntimeConfig{
+		DataDir: dataDir,
+		Services: []*structs.ServiceDefinition{
+			{ID: "db", Name: "db"},
+		},
+	}
+	a := &Agent{logger: log.New(os.Stderr, "", log.LstdFlags)}
+
+	issues, err := a.checkPersistedState(conf)
+	require.NoError(t, err)
+	found := make(map[string]string)
+	for _, issue := range issues {
+		found[issue.File] = issue.Action
+	}
+	require.Equal(t, map[string]string{
+		configured: "remove it",
+		misplaced:  "move it to " + `"` + filepath.Join(dataDir, servicesDir, stringHash("api")) + `"`,
+		duplicate:  "remove it",
+		corrupt:    "remove it",
+		partial:    "remove it",
+		orphaned:   "remove it",
+		stale:      "remove it",
+	}, found)
+
+	// Nothing is changed until the problems are repaired.
+	require.True(t, fileExists(corrupt))
+	require.NoError(t, a.reconcilePersistedState(conf))
+	require.True(t, fileExists(corrupt))
+
+	conf.RepairPersistedState = true
+	require.NoError(t, a.reconcilePersistedState(conf))
+	for file := range found {
+		require.False(t, fileExists(file), file)
+	}
+	require.True(t, fileExists(filepath.Join(dataDir, servicesDir, stringHash("api"))))
+	require.True(t, fileExists(filepath.Join(dataDir, servicesDir, stringHash("web"))))
+
+	// Once repaired, there's nothing left to do.
+	issues, err = a.checkPersistedState(conf)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
@@ -2,37 +2,83 @@ package pool
 
 import (
 	"container/list"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/rpc"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/tlsutil"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
 	"github.com/hashicorp/yamux"
 )
 
-const defaultDialTimeout = 10 * time.Second
+const (
+	defaultDialTimeout = 10 * time.Second
+
+	// reconnectBackoffBase and reconnectBackoffMax bound how long we wait
+	// before dialing a server again after failing to connect to it. The
+	// wait doubles with each consecutive failure.
+	reconnectBackoffBase = 100 * time.Millisecond
+	reconnectBackoffMax  = 5 * time.Second
+
+	// featuresMethod is the RPC used to ask a server which features it
+	// supports when a connection to it is set up.
+	featuresMethod = "Status.Features"
+)
 
 // muxSession is used to provide an interface for a stream multiplexer.
 type muxSession interface {
 	Open() (net.Conn, error)
 	Close() error
+	IsClosed() bool
+}
+
+// blockingRequest is implemented by requests that the server may hold
+// open while waiting for changes.
+type blockingRequest interface {
+	BlockingTimeout(maxQueryTime, defaultQueryTime time.Duration) time.Duration
+}
+
+// timeoutRequest is implemented by requests that can carry their own
+// end-to-end timeout.
+type timeoutRequest interface {
+	RequestTimeout() time.Duration
+}
+
+// dialBackoff tracks the consecutive failures to connect to a server.
+type dialBackoff struct {
+	failures int
+	until    time.Time
 }
 
+// errStreamLimit is returned when no stream could be opened on a connection
+// because it already has the most open streams allowed.
+var errStreamLimit = errors.New("too many open streams to the server")
+
 // streamClient is used to wrap a stream with an RPC client
 type StreamClient struct {
 	stream net.Conn
 	codec  rpc.ClientCodec
+
+	// release frees the stream's slot on its connection, if the pool
+	// limits how many streams can be open.
+	release func()
 }
 
 func (sc *StreamClient) Close() {
 	sc.stream.Close()
 	sc.codec.Close()
+	if sc.release != nil {
+		sc.release()
+	}
 }
 
 // Conn is a pooled connection to a Consul server
@@ -45,6 +91,15 @@ type Conn struct {
 	lastUsed time.Time
 	version  int
 
+	// features are the features the server reported when the connection
+	// was set up, if the pool has a FeatureCheck.
+	features map[string]struct{}
+
+	// streams has a slot for each open stream if the pool limits how many
+	// can be open, and streamWaiters counts the RPCs waiting for one.
+	streams       chan struct{}
+	streamWaiters int32
+
 	pool *ConnPool
 
 	clients    *list.List
@@ -55,22 +110,30 @@ func (c *Conn) Close() error {
 	return c.session.Close()
 }
 
-// getClient is used to get a cached or new client
-func (c *Conn) getClient() (*StreamClient, error) {
+// getClient is used to get a cached or new client. If the pool limits the
+// open streams and they're all in use, it waits up to the given timeout for
+// one to be closed, or for ever if it's zero.
+func (c *Conn) getClient(timeout time.Duration) (*StreamClient, error) {
 	// Check for cached client
-	c.clientLock.Lock()
-	front := c.clients.Front()
-	if front != nil {
-		c.clients.Remove(front)
+	if sc := c.popClient(); sc != nil {
+		return sc, nil
 	}
-	c.clientLock.Unlock()
-	if front != nil {
-		return front.Value.(*StreamClient), nil
+
+	// Take a slot for the new stream.
+	var release func()
+	if c.streams != nil {
+		if err := c.acquireStream(timeout); err != nil {
+			return nil, err
+		}
+		release = func() { <-c.streams }
 	}
 
 	// Open a new session
 	stream, err := c.session.Open()
 	if err != nil {
+		if release != nil {
+			release()
+		}
 		return nil, err
 	}
 
@@ -79,18 +142,67 @@ func (c *Conn) getClient() (*StreamClient, error) {
 
 	// Return a new stream client
 	sc := &StreamClient{
-		stream: stream,
-		codec:  codec,
+		stream:  stream,
+		codec:   codec,
+		release: release,
 	}
 	return sc, nil
 }
 
+// popClient removes a cached client and returns it, or returns nil if there
+// aren't any.
+func (c *Conn) popClient() *StreamClient {
+	c.clientLock.Lock()
+	defer c.clientLock.Unlock()
+	front := c.clients.Front()
+	if front == nil {
+		return nil
+	}
+	c.clients.Remove(front)
+	return front.Value.(*StreamClient)
+}
+
+// acquireStream takes a slot for a new stream, waiting up to the given
+// timeout for one if they're all taken. Idle streams hold slots too, so one
+// is closed to make room, and no more are cached while anyone is waiting.
+func (c *Conn) acquireStream(timeout time.Duration) error {
+	select {
+	case c.streams <- struct{}{}:
+		return nil
+	default:
+	}
+
+	metrics.IncrCounter([]string{"rpc", "pool", "stream_wait"}, 1)
+	atomic.AddInt32(&c.streamWaiters, 1)
+	defer atomic.AddInt32(&c.streamWaiters, -1)
+	if sc := c.popClient(); sc != nil {
+		sc.Close()
+	}
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case c.streams <- struct{}{}:
+		return nil
+	case <-timeoutCh:
+		metrics.IncrCounter([]string{"rpc", "pool", "stream_limit"}, 1)
+		return errStreamLimit
+	case <-c.pool.shutdownCh:
+		return fmt.Errorf("connection pool is shut down")
+	}
+}
+
 // returnStream is used when done with a stream
 // to allow re-use by a future RPC
 func (c *Conn) returnClient(client *StreamClient) {
 	didSave := false
 	c.clientLock.Lock()
-	if c.clients.Len() < c.pool.MaxStreams && atomic.LoadInt32(&c.shouldClose) == 0 {
+	if c.clients.Len() < c.pool.MaxStreams && atomic.LoadInt32(&c.shouldClose) == 0 &&
+		atomic.LoadInt32(&c.streamWaiters) == 0 {
 		c.clients.PushFront(client)
 		didSave = true
 
@@ -130,15 +242,47 @@ type ConnPool struct {
 	// The maximum time to keep a connection open
 	MaxTime time.Duration
 
-	// The maximum number of open streams to keep
+	// The maximum number of idle streams to keep
 	MaxStreams int
 
+	// MaxOpenStreams is the most streams that can be open on a connection
+	// at once, idle ones included. RPCs wait for a stream to be closed when
+	// there are this many, up to their stream timeout. Zero means there's
+	// no limit.
+	MaxOpenStreams int
+
+	// KeepAliveInterval is how often the multiplexed sessions ping the
+	// server, and ConnectionWriteTimeout is how long a write or ping can
+	// take before the session is considered broken and closed. Zero uses
+	// the Yamux defaults.
+	KeepAliveInterval      time.Duration
+	ConnectionWriteTimeout time.Duration
+
+	// StreamTimeout is how long a single RPC can take before it is failed.
+	// Blocking queries get the time they may block for on top of this,
+	// bounded by MaxQueryTime and defaulting to DefaultQueryTime. Zero
+	// disables the timeout.
+	StreamTimeout    time.Duration
+	MaxQueryTime     time.Duration
+	DefaultQueryTime time.Duration
+
 	// TLS wrapper
 	TLSWrapper tlsutil.DCWrapper
 
 	// ForceTLS is used to enforce outgoing TLS verification
 	ForceTLS bool
 
+	// GatewayDialer, if set, is used to connect to servers in datacenters
+	// that are reached through WAN federation gateways. It returns false
+	// if the datacenter should be dialed directly instead.
+	GatewayDialer func(dc string, timeout time.Duration) (net.Conn, bool, error)
+
+	// FeatureCheck, if set, is called before each RPC with the features
+	// the server reported when the connection to it was set up, and the RPC
+	// fails with the error it returns. Servers that don't know how to
+	// report their features are taken to support none.
+	FeatureCheck func(features map[string]struct{}, method string, args interface{}) error
+
 	sync.Mutex
 
 	// pool maps an address to a open connection
@@ -150,6 +294,10 @@ type ConnPool struct {
 	// on to close.
 	limiter map[string]chan struct{}
 
+	// backoff maps an address to the failures connecting to it, so we
+	// don't redial a server that is down on every request.
+	backoff map[string]*dialBackoff
+
 	// Used to indicate the pool is shutdown
 	shutdown   bool
 	shutdownCh chan struct{}
@@ -164,6 +312,7 @@ type ConnPool struct {
 func (p *ConnPool) init() {
 	p.pool = make(map[string]*Conn)
 	p.limiter = make(map[string]chan struct{})
+	p.backoff = make(map[string]*dialBackoff)
 	p.shutdownCh = make(chan struct{})
 	if p.MaxTime > 0 {
 		go p.reap()
@@ -202,11 +351,23 @@ func (p *ConnPool) acquire(dc string, addr net.Addr, version int, useTLS bool) (
 	// of the code here.
 	p.Lock()
 	c := p.pool[addrStr]
+	if c != nil && c.session.IsClosed() {
+		// The session was closed underneath us, most likely because a
+		// keepalive failed, so dial a new one.
+		metrics.IncrCounter([]string{"rpc", "pool", "session_closed"}, 1)
+		delete(p.pool, addrStr)
+		c = nil
+	}
 	if c != nil {
 		c.markForUse()
 		p.Unlock()
 		return c, nil
 	}
+	if b, ok := p.backoff[addrStr]; ok && time.Now().Before(b.until) {
+		p.Unlock()
+		metrics.IncrCounter([]string{"rpc", "pool", "backoff"}, 1)
+		return nil, fmt.Errorf("rpc error: backing off reconnecting to %s after %d failed attempts", addrStr, b.failures)
+	}
 
 	// If not (while we are still locked), set up the throttling structure
 	// for this address, which will make everyone else wait until our
@@ -228,10 +389,12 @@ func (p *ConnPool) acquire(dc string, addr net.Addr, version int, useTLS bool) (
 		delete(p.limiter, addrStr)
 		close(wait)
 		if err != nil {
+			p.failedDial(addrStr)
 			p.Unlock()
 			return nil, err
 		}
 
+		delete(p.backoff, addrStr)
 		p.pool[addrStr] = c
 		p.Unlock()
 		return c, nil
@@ -257,6 +420,28 @@ func (p *ConnPool) acquire(dc string, addr net.Addr, version int, useTLS bool) (
 	return nil, fmt.Errorf("rpc error: lead thread didn't get connection")
 }
 
+// failedDial records a failure to connect to the given address, so the next
+// attempt waits for an exponentially increasing backoff. The lock must be
+// held.
+func (p *ConnPool) failedDial(addrStr string) {
+	metrics.IncrCounter([]string{"rpc", "pool", "dial_failed"}, 1)
+
+	b, ok := p.backoff[addrStr]
+	if !ok {
+		b = &dialBackoff{}
+		p.backoff[addrStr] = b
+	}
+	b.failures++
+
+	wait := reconnectBackoffMax
+	if shift := uint(b.failures - 1); shift < 16 {
+		if d := reconnectBackoffBase << shift; d < wait {
+			wait = d
+		}
+	}
+	b.until = time.Now().Add(wait)
+}
+
 // HalfCloser is an interface that exposes a TCP half-close. We need this
 // because we want to expose the raw TCP connection underlying a TLS one in a
 // way that's hard to screw up and use for anything else. There's a change
@@ -271,6 +456,19 @@ type HalfCloser interface {
 func (p *ConnPool) DialTimeout(dc string, addr net.Addr, timeout time.Duration, useTLS bool) (net.Conn, HalfCloser, error) {
 	p.once.Do(p.init)
 
+	// Connections through gateways are always TLS, which the gateway dialer
+	// takes care of.
+	if p.GatewayDialer != nil {
+		conn, ok, err := p.GatewayDialer(dc, timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			hc, _ := conn.(HalfCloser)
+			return conn, hc, nil
+		}
+	}
+
 	// Try to dial the conn
 	d := &net.Dialer{LocalAddr: p.SrcAddr, Timeout: timeout}
 	conn, err := d.Dial("tcp", addr.String())
@@ -330,9 +528,20 @@ func (p *ConnPool) getNewConn(dc string, addr net.Addr, version int, useTLS bool
 	// Setup the logger
 	conf := yamux.DefaultConfig()
 	conf.LogOutput = p.LogOutput
+	if p.KeepAliveInterval > 0 {
+		conf.KeepAliveInterval = p.KeepAliveInterval
+	}
+	if p.ConnectionWriteTimeout > 0 {
+		conf.ConnectionWriteTimeout = p.ConnectionWriteTimeout
+	}
 
 	// Create a multiplexed session
-	session, _ = yamux.Client(conn, conf)
+	session, err = yamux.Client(conn, conf)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	metrics.IncrCounter([]string{"rpc", "pool", "dial"}, 1)
 
 	// Wrap the connection
 	c := &Conn{
@@ -344,9 +553,57 @@ func (p *ConnPool) getNewConn(dc string, addr net.Addr, version int, useTLS bool
 		version:  version,
 		pool:     p,
 	}
+	if p.MaxOpenStreams > 0 {
+		c.streams = make(chan struct{}, p.MaxOpenStreams)
+	}
+
+	// Ask the server which features it supports over this connection,
+	// rather than trusting what it last gossiped, since it may have been
+	// downgraded since.
+	if p.FeatureCheck != nil {
+		features, err := c.getFeatures()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.features = features
+	}
 	return c, nil
 }
 
+// getFeatures asks the server at the other end of the connection which
+// features it supports. Servers from before features were negotiated don't
+// have the RPC, so they're taken to support none.
+func (c *Conn) getFeatures() (map[string]struct{}, error) {
+	sc, err := c.getClient(c.pool.StreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.pool.StreamTimeout > 0 {
+		sc.stream.SetDeadline(time.Now().Add(c.pool.StreamTimeout))
+	}
+
+	var reply []string
+	err = msgpackrpc.CallWithCodec(sc.codec, featuresMethod, struct{}{}, &reply)
+	if err != nil {
+		sc.Close()
+		if _, ok := err.(rpc.ServerError); ok && strings.Contains(err.Error(), "can't find method") {
+			return map[string]struct{}{}, nil
+		}
+		return nil, fmt.Errorf("failed to get server features: %v", err)
+	}
+	if c.pool.StreamTimeout > 0 {
+		sc.stream.SetDeadline(time.Time{})
+	}
+	c.returnClient(sc)
+
+	features := make(map[string]struct{}, len(reply))
+	for _, feature := range reply {
+		features[feature] = struct{}{}
+	}
+	return features, nil
+}
+
 // clearConn is used to clear any cached connection, potentially in response to an error
 func (p *ConnPool) clearConn(conn *Conn) {
 	// Ensure returned streams are closed
@@ -374,8 +631,10 @@ func (p *ConnPool) releaseConn(conn *Conn) {
 	}
 }
 
-// getClient is used to get a usable client for an address and protocol version
-func (p *ConnPool) getClient(dc string, addr net.Addr, version int, useTLS bool) (*Conn, *StreamClient, error) {
+// getClient is used to get a usable client for an address and protocol
+// version, waiting up to the given timeout for a stream if the connection
+// has the most open streams allowed.
+func (p *ConnPool) getClient(dc string, addr net.Addr, version int, useTLS bool, timeout time.Duration) (*Conn, *StreamClient, error) {
 	retries := 0
 START:
 	// Try to get a conn first
@@ -385,7 +644,11 @@ START:
 	}
 
 	// Get a client
-	client, err := conn.getClient()
+	client, err := conn.getClient(timeout)
+	if err == errStreamLimit {
+		p.releaseConn(conn)
+		return nil, nil, err
+	}
 	if err != nil {
 		p.clearConn(conn)
 		p.releaseConn(conn)
@@ -404,17 +667,40 @@ START:
 func (p *ConnPool) RPC(dc string, addr net.Addr, version int, method string, useTLS bool, args interface{}, reply interface{}) error {
 	p.once.Do(p.init)
 
+	// Bound how long the call can take, so a stalled connection doesn't
+	// hang the caller forever.
+	timeout := p.streamTimeout(args)
+
 	// Get a usable client
-	conn, sc, err := p.getClient(dc, addr, version, useTLS)
+	conn, sc, err := p.getClient(dc, addr, version, useTLS, timeout)
 	if err != nil {
 		return fmt.Errorf("rpc error getting client: %v", err)
 	}
 
+	// Make sure the server supports what the request needs.
+	if p.FeatureCheck != nil {
+		if err := p.FeatureCheck(conn.features, method, args); err != nil {
+			conn.returnClient(sc)
+			p.releaseConn(conn)
+			return fmt.Errorf("rpc error: %v at %s", err, addr)
+		}
+	}
+
+	if timeout > 0 {
+		sc.stream.SetDeadline(time.Now().Add(timeout))
+	}
+
 	// Make the RPC call
 	err = msgpackrpc.CallWithCodec(sc.codec, method, args, reply)
 	if err != nil {
 		sc.Close()
 
+		if err == yamux.ErrTimeout {
+			metrics.IncrCounter([]string{"rpc", "pool", "stream_timeout"}, 1)
+			p.releaseConn(conn)
+			return fmt.Errorf("rpc error making call: %s timed out after %s", method, timeout)
+		}
+
 		// See the comment in leader_test.go TestLeader_ChangeServerID
 		// about how we found this. The tldr is that if we see this
 		// error, we know this connection is toast, so we should clear
@@ -428,11 +714,37 @@ func (p *ConnPool) RPC(dc string, addr net.Addr, version int, method string, use
 	}
 
 	// Done with the connection
+	if timeout > 0 {
+		sc.stream.SetDeadline(time.Time{})
+	}
 	conn.returnClient(sc)
 	p.releaseConn(conn)
 	return nil
 }
 
+// streamTimeout returns how long the given request can take, or zero if it
+// can take as long as it likes. Requests with their own timeout get the same
+// 1/16th extra servers add to blocking queries, so an answer sent at the
+// deadline still makes it back.
+func (p *ConnPool) streamTimeout(args interface{}) time.Duration {
+	var timeout time.Duration
+	if p.StreamTimeout > 0 {
+		timeout = p.StreamTimeout
+		if req, ok := args.(blockingRequest); ok {
+			timeout += req.BlockingTimeout(p.MaxQueryTime, p.DefaultQueryTime)
+		}
+	}
+	if req, ok := args.(timeoutRequest); ok {
+		if t := req.RequestTimeout(); t > 0 {
+			t += t / 16
+			if timeout == 0 || t < timeout {
+				timeout = t
+			}
+		}
+	}
+	return timeout
+}
+
 // Ping sends a Status.Ping message to the specified server and
 // returns true if healthy, false if an error occurred
 func (p *ConnPool) Ping(dc string, addr net.Addr, version int, useTLS bool) (bool, error) {
@@ -441,6 +753,87 @@ func (p *ConnPool) Ping(dc string, addr net.Addr, version int, useTLS bool) (boo
 	return err == nil, err
 }
 
+// ConnStats describes an open connection in the pool.
+type ConnStats struct {
+	Addr        string
+	Version     int
+	ActiveRPCs  int32
+	IdleStreams int
+	LastUsed    time.Time
+	Closed      bool
+}
+
+// BackoffStats describes a server that the pool is backing off reconnecting
+// to after failed dials.
+type BackoffStats struct {
+	Addr     string
+	Failures int
+	Until    time.Time
+}
+
+// PoolStats is a point in time view of the connections in the pool.
+type PoolStats struct {
+	Conns   []ConnStats
+	Backoff []BackoffStats
+}
+
+// Stats returns the connections currently in the pool and the servers that
+// are being backed off, sorted by address.
+func (p *ConnPool) Stats() PoolStats {
+	p.once.Do(p.init)
+
+	p.Lock()
+	defer p.Unlock()
+
+	var stats PoolStats
+	for addr, conn := range p.pool {
+		conn.clientLock.Lock()
+		idle := conn.clients.Len()
+		conn.clientLock.Unlock()
+
+		stats.Conns = append(stats.Conns, ConnStats{
+			Addr:        addr,
+			Version:     conn.version,
+			ActiveRPCs:  atomic.LoadInt32(&conn.refCount),
+			IdleStreams: idle,
+			LastUsed:    conn.lastUsed,
+			Closed:      conn.session.IsClosed(),
+		})
+	}
+	for addr, b := range p.backoff {
+		stats.Backoff = append(stats.Backoff, BackoffStats{
+			Addr:     addr,
+			Failures: b.failures,
+			Until:    b.until,
+		})
+	}
+	sort.Slice(stats.Conns, func(i, j int) bool { return stats.Conns[i].Addr < stats.Conns[j].Addr })
+	sort.Slice(stats.Backoff, func(i, j int) bool { return stats.Backoff[i].Addr < stats.Backoff[j].Addr })
+	return stats
+}
+
+// Flush closes all the pooled connections and forgets the failed dials, so
+// the next RPC to each server dials a new connection straight away. Idle
+// connections are closed now, and ones in use are closed once their RPCs
+// finish. It returns how many connections were removed from the pool.
+func (p *ConnPool) Flush() int {
+	p.once.Do(p.init)
+
+	p.Lock()
+	defer p.Unlock()
+
+	flushed := len(p.pool)
+	for _, conn := range p.pool {
+		atomic.StoreInt32(&conn.shouldClose, 1)
+		if atomic.LoadInt32(&conn.refCount) == 0 {
+			conn.Close()
+		}
+	}
+	p.pool = make(map[string]*Conn)
+	p.backoff = make(map[string]*dialBackoff)
+	return flushed
+}
+
 // Reap is used to close conns open over maxTime
 func (p *ConnPool) reap() {
 	for {
@@ -453,6 +846,7 @@ func (p *ConnPool) reap() {
 
 		// Reap all old conns
 		p.Lock()
+		metrics.SetGauge([]string{"rpc", "pool", "conns"}, float32(len(p.pool)))
 		var removed []string
 		now := time.Now()
 		for host, conn := range p.pool {
@@ -475,6 +869,13 @@ func (p *ConnPool) reap() {
 		for _, host := range removed {
 			delete(p.pool, host)
 		}
+
+		// Forget failures to servers we haven't tried in a while.
+		for host, b := range p.backoff {
+			if now.Sub(b.until) > reconnectBackoffMax {
+				delete(p.backoff, host)
+			}
+		}
 		p.Unlock()
 	}
 }
@@ -0,0 +1,185 @@
+package pool
+
+import (
+	"container/list"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+func TestConnPool_DialBackoff(t *testing.T) {
+	t.Parallel()
+
+	// Grab a free port and close it, so dials to it fail.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := l.Addr()
+	l.Close()
+
+	p := &ConnPool{}
+	defer p.Shutdown()
+
+	// The first attempt dials and fails.
+	_, err = p.Ping("dc1", addr, 2, false)
+	if err == nil || strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The next attempt fails straight away rather than dialing again.
+	_, err = p.Ping("dc1", addr, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestConnPool_Flush(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := l.Addr()
+	l.Close()
+
+	p := &ConnPool{}
+	defer p.Shutdown()
+
+	if _, err := p.Ping("dc1", addr, 2, false); err == nil {
+		t.Fatalf("should fail")
+	}
+	if stats := p.Stats(); len(stats.Backoff) != 1 {
+		t.Fatalf("bad: %#v", stats)
+	}
+
+	// Flushing forgets the failure, so the next attempt dials again.
+	if n := p.Flush(); n != 0 {
+		t.Fatalf("bad: %d", n)
+	}
+	if stats := p.Stats(); len(stats.Backoff) != 0 {
+		t.Fatalf("bad: %#v", stats)
+	}
+	_, err = p.Ping("dc1", addr, 2, false)
+	if err == nil || strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestConnPool_streamTimeout(t *testing.T) {
+	t.Parallel()
+
+	p := &ConnPool{
+		StreamTimeout:    time.Minute,
+		MaxQueryTime:     10 * time.Minute,
+		DefaultQueryTime: 5 * time.Minute,
+	}
+
+	cases := []struct {
+		name string
+		args interface{}
+		want time.Duration
+	}{
+		{"no options", struct{}{}, time.Minute},
+		{"write", &structs.WriteRequest{}, time.Minute},
+		{"write with timeout", &structs.WriteRequest{Timeout: 16 * time.Second}, 17 * time.Second},
+		{"blocking", &structs.QueryOptions{MinQueryIndex: 1, MaxQueryTime: 16 * time.Second}, time.Minute + 17*time.Second},
+		{"blocking with timeout", &structs.QueryOptions{MinQueryIndex: 1, MaxQueryTime: 16 * time.Second, Timeout: 32 * time.Second}, 34 * time.Second},
+		{"timeout past the limit", &structs.QueryOptions{Timeout: time.Hour}, time.Minute},
+	}
+	for _, tc := range cases {
+		if got := p.streamTimeout(tc.args); got != tc.want {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Without a stream timeout, only the request's own timeout applies.
+	p.StreamTimeout = 0
+	if got := p.streamTimeout(&structs.QueryOptions{}); got != 0 {
+		t.Fatalf("got %v, want 0", got)
+	}
+	if got, want := p.streamTimeout(&structs.QueryOptions{Timeout: 16 * time.Second}), 17*time.Second; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// pipeSession is a muxSession that opens in-memory streams.
+type pipeSession struct {
+	opened int32
+}
+
+func (s *pipeSession) Open() (net.Conn, error) {
+	atomic.AddInt32(&s.opened, 1)
+	conn, _ := net.Pipe()
+	return conn, nil
+}
+
+func (s *pipeSession) Close() error   { return nil }
+func (s *pipeSession) IsClosed() bool { return false }
+
+func TestConn_MaxOpenStreams(t *testing.T) {
+	t.Parallel()
+
+	p := &ConnPool{MaxStreams: 1, MaxOpenStreams: 2}
+	p.once.Do(p.init)
+	defer p.Shutdown()
+	session := &pipeSession{}
+	c := &Conn{
+		session: session,
+		clients: list.New(),
+		streams: make(chan struct{}, p.MaxOpenStreams),
+		pool:    p,
+	}
+
+	// Two streams can be open, but not a third.
+	sc1, err := c.getClient(0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sc2, err := c.getClient(0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.getClient(10 * time.Millisecond); err != errStreamLimit {
+		t.Fatalf("err: %v", err)
+	}
+
+	// An idle stream still counts, but gets reused.
+	c.returnClient(sc1)
+	sc1, err = c.getClient(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A stream returned while someone is waiting is closed to make room.
+	errCh := make(chan error, 1)
+	go func() {
+		sc, err := c.getClient(time.Second)
+		if err == nil {
+			sc.Close()
+		}
+		errCh <- err
+	}()
+	for atomic.LoadInt32(&c.streamWaiters) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.returnClient(sc2)
+	if err := <-errCh; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.clients.Len() != 0 {
+		t.Fatalf("bad: %d idle", c.clients.Len())
+	}
+	sc1.Close()
+	if n := atomic.LoadInt32(&session.opened); n != 3 {
+		t.Fatalf("bad: %d opened", n)
+	}
+	if n := len(c.streams); n != 0 {
+		t.Fatalf("bad: %d slots taken", n)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	cachetype "github.com/hashicorp/consul/agent/cache-types"
 	"github.com/hashicorp/consul/agent/consul"
@@ -118,6 +119,15 @@ func (s *HTTPServer) preparedQueryExecute(id string, resp http.ResponseWriter, r
 		args.Connect = val
 	}
 
+	if raw := params.Get("pin"); raw != "" {
+		val, err := time.ParseDuration(raw)
+		if err != nil || val < 0 || val > structs.MaxQueryPinTTL {
+			return nil, fmt.Errorf("Bad pin: %s", raw)
+		}
+
+		args.PinTTL = val
+	}
+
 	var reply structs.PreparedQueryExecuteResponse
 	defer setMeta(resp, &reply.QueryMeta)
 
@@ -164,6 +174,7 @@ func (s *HTTPServer) preparedQueryExecute(id string, resp http.ResponseWriter, r
 	// was sent to. That's why we use the reply's DC and not the one from
 	// the args.
 	s.agent.TranslateAddresses(reply.Datacenter, reply.Nodes)
+	setAddressFamilies(reply.Nodes)
 
 	// Use empty list instead of nil.
 	if reply.Nodes == nil {
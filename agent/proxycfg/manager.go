@@ -326,6 +326,9 @@ func (m *Manager) Close() error {
 	defer m.mu.Unlock()
 
 	if m.stateCh != nil {
+		// Stop the local state sending to the channel before closing it, as
+		// check status changes can notify at any time.
+		m.State.StopNotify(m.stateCh)
 		close(m.stateCh)
 		m.stateCh = nil
 	}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+const (
+	// signatureHeader carries the base64 encoded signature of a signed
+	// response.
+	signatureHeader = "X-Consul-Signature"
+
+	// signatureKeyIDHeader carries the ID of the key a signed response was
+	// signed with.
+	signatureKeyIDHeader = "X-Consul-Signature-Key-ID"
+)
+
+// responseSigningKey is the public part of the response signing key, as the
+// HTTP API returns it.
+type responseSigningKey struct {
+	ID        string
+	Algorithm string
+	PublicKey []byte
+}
+
+// CatalogSigningKey returns the public key that signed catalog and health
+// responses can be verified with.
+func (s *HTTPServer) CatalogSigningKey(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var out structs.IndexedResponseSigningKey
+	defer setMeta(resp, &out.QueryMeta)
+	if err := s.agent.RPC("ResponseSigning.PublicKey", &args, &out); err != nil {
+		return nil, err
+	}
+	return responseSigningKey{
+		ID:        out.ID,
+		Algorithm: out.Algorithm,
+		PublicKey: out.PublicKey,
+	}, nil
+}
+
+// parseSigned returns true if the request asked for a signed response.
+func parseSigned(req *http.Request) bool {
+	_, ok := req.URL.Query()["signed"]
+	return ok
+}
+
+// writeSignedResponse writes the payload the servers signed as the response
+// body, as-is, along with the signature. The payload is already JSON, so it
+// doesn't go through the usual encoding, and the query meta is set here since
+// the headers can't be changed once the body is written.
+func writeSignedResponse(resp http.ResponseWriter, meta *structs.QueryMeta, sig *structs.ResponseSignature) error {
+	if sig == nil {
+		return fmt.Errorf("The servers didn't sign the response")
+	}
+	setMeta(resp, meta)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set(signatureHeader, base64.StdEncoding.EncodeToString(sig.Signature))
+	resp.Header().Set(signatureKeyIDHeader, sig.KeyID)
+	_, err := resp.Write(sig.Payload)
+	return err
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseSigning(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), `enable_response_signing = true`)
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// The leader creates the key once it's elected.
+	var key *api.SigningKey
+	retry.Run(t, func(r *retry.R) {
+		req, _ := http.NewRequest("GET", "/v1/catalog/signing-key", nil)
+		resp := httptest.NewRecorder()
+		obj, err := a.srv.CatalogSigningKey(resp, req)
+		if err != nil {
+			r.Fatal(err)
+		}
+		out := obj.(responseSigningKey)
+		key = &api.SigningKey{ID: out.ID, Algorithm: out.Algorithm, PublicKey: out.PublicKey}
+	})
+	require.Equal(t, "ed25519", key.Algorithm)
+
+	args := &structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "bar",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			ID:      "web",
+			Service: "web",
+		},
+	}
+	var out struct{}
+	require.NoError(t, a.RPC("Catalog.Register", args, &out))
+
+	verify := func(resp *httptest.ResponseRecorder, signed *api.SignedRequest) {
+		t.Helper()
+		signed.Datacenter = "dc1"
+		signed.KeyID = key.ID
+		require.Equal(t, key.ID, resp.Header().Get("X-Consul-Signature-Key-ID"))
+		index, err := strconv.ParseUint(resp.Header().Get("X-Consul-Index"), 10, 64)
+		require.NoError(t, err)
+		body := resp.Body.Bytes()
+		sig := resp.Header().Get("X-Consul-Signature")
+		require.NoError(t, api.VerifyResponseSignature(key, signed, index, body, sig))
+
+		// A changed body, a replayed index or a different query don't
+		// verify.
+		tampered := append([]byte(nil), body...)
+		tampered[len(tampered)-2] ^= 1
+		require.Error(t, api.VerifyResponseSignature(key, signed, index, tampered, sig))
+		require.Error(t, api.VerifyResponseSignature(key, signed, index+1, body, sig))
+		other := *signed
+		other.ServiceName = "api"
+		require.Error(t, api.VerifyResponseSignature(key, &other, index, body, sig))
+		other = *signed
+		other.Datacenter = "dc2"
+		require.Error(t, api.VerifyResponseSignature(key, &other, index, body, sig))
+	}
+
+	// Catalog responses are signed.
+	{
+		req, _ := http.NewRequest("GET", "/v1/catalog/service/web?signed", nil)
+		resp := httptest.NewRecorder()
+		obj, err := a.srv.CatalogServiceNodes(resp, req)
+		require.NoError(t, err)
+		require.Nil(t, obj)
+		verify(resp, &api.SignedRequest{Endpoint: "Catalog.ServiceNodes", ServiceName: "web"})
+
+		var nodes []*api.CatalogService
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &nodes))
+		require.Len(t, nodes, 1)
+		require.Equal(t, "bar", nodes[0].Node)
+		require.NotNil(t, nodes[0].ServiceTags)
+	}
+
+	// So are health responses.
+	{
+		req, _ := http.NewRequest("GET", "/v1/health/service/web?signed&passing", nil)
+		resp := httptest.NewRecorder()
+		obj, err := a.srv.HealthServiceNodes(resp, req)
+		require.NoError(t, err)
+		require.Nil(t, obj)
+		verify(resp, &api.SignedRequest{Endpoint: "Health.ServiceNodes", ServiceName: "web", OnlyPassing: true})
+
+		var nodes []*api.ServiceEntry
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &nodes))
+		require.Len(t, nodes, 1)
+		require.Equal(t, "web", nodes[0].Service.ID)
+	}
+
+	// Signed responses can't span datacenters.
+	{
+		req, _ := http.NewRequest("GET", "/v1/health/service/web?signed&multi-dc=dc1", nil)
+		resp := httptest.NewRecorder()
+		_, err := a.srv.HealthServiceNodes(resp, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+	}
+}
+
+func TestResponseSigning_Disabled(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	req, _ := http.NewRequest("GET", "/v1/catalog/service/consul?signed", nil)
+	_, err := a.srv.CatalogServiceNodes(httptest.NewRecorder(), req)
+	require.True(t, structs.IsErrResponseSigningDisabled(err), "err: %v", err)
+
+	req, _ = http.NewRequest("GET", "/v1/catalog/signing-key", nil)
+	_, err = a.srv.CatalogSigningKey(httptest.NewRecorder(), req)
+	require.True(t, structs.IsErrResponseSigningDisabled(err), "err: %v", err)
+}
@@ -282,6 +282,15 @@ func (m *Manager) NumServers() int {
 	return len(l.servers)
 }
 
+// Servers returns a copy of the servers in the order they will be tried for
+// RPCs, including both healthy and unhealthy servers.
+func (m *Manager) Servers() []*metadata.Server {
+	l := m.getServerList()
+	servers := make([]*metadata.Server, len(l.servers))
+	copy(servers, l.servers)
+	return servers
+}
+
 // RebalanceServers shuffles the list of servers on this metadata.  The server
 // at the front of the list is selected for the next RPC.  RPC calls that
 // fail for a particular server are rotated to the end of the list.  This
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/consul"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/logger"
+	"github.com/hashicorp/logutils"
+)
+
+// RuntimeSettings returns the current values of the settings that can be
+// changed while the agent is running. The server settings are left zero on
+// clients.
+func (a *Agent) RuntimeSettings() structs.RuntimeSettings {
+	a.runtimeSettingsLock.Lock()
+	defer a.runtimeSettingsLock.Unlock()
+	return a.runtimeSettings()
+}
+
+// runtimeSettings is RuntimeSettings without the lock.
+func (a *Agent) runtimeSettings() structs.RuntimeSettings {
+	settings := structs.RuntimeSettings{
+		LogLevel: strings.ToUpper(a.config.LogLevel),
+	}
+	if a.LogFilter != nil {
+		settings.LogLevel = string(a.LogFilter.MinLevel)
+	}
+	if srv, ok := a.delegate.(*consul.Server); ok {
+		srv.RuntimeSettings(&settings)
+	}
+	return settings
+}
+
+// SetRuntimeSettings changes the settings of the running agent, and logs
+// each setting that changed so there's a record of them. The settings are
+// validated first, so either all of them or none are applied.
+func (a *Agent) SetRuntimeSettings(settings structs.RuntimeSettings) error {
+	a.runtimeSettingsLock.Lock()
+	defer a.runtimeSettingsLock.Unlock()
+
+	old := a.runtimeSettings()
+	srv, isServer := a.delegate.(*consul.Server)
+
+	level := logutils.LogLevel(strings.ToUpper(settings.LogLevel))
+	if !logger.ValidateLevelFilter(level, logger.LevelFilter()) {
+		return fmt.Errorf("Invalid log level: %s. Valid log levels are: %v",
+			settings.LogLevel, logger.LevelFilter().Levels)
+	}
+	if level != logutils.LogLevel(old.LogLevel) && a.LogFilter == nil {
+		return fmt.Errorf("The log level of this agent can't be changed")
+	}
+	if isServer {
+		if settings.SlowQueryThreshold <= 0 {
+			return fmt.Errorf("SlowQueryThreshold must be greater than zero")
+		}
+		if settings.MaxQueryTime <= 0 || settings.MaxQueryTime > consul.MaxRuntimeQueryTime {
+			return fmt.Errorf("MaxQueryTime must be greater than zero and at most %s", consul.MaxRuntimeQueryTime)
+		}
+		if settings.RPCServerReadRate < 0 || settings.RPCServerWriteRate < 0 || settings.RPCServerTokenRate < 0 {
+			return fmt.Errorf("RPC rate limits must be greater than or equal to zero")
+		}
+		if settings.RPCServerMaxBurst < 1 {
+			return fmt.Errorf("RPCServerMaxBurst must be greater than zero")
+		}
+	} else if settings.SlowQueryThreshold != old.SlowQueryThreshold || settings.MaxQueryTime != old.MaxQueryTime ||
+		settings.RPCServerReadRate != old.RPCServerReadRate || settings.RPCServerWriteRate != old.RPCServerWriteRate ||
+		settings.RPCServerTokenRate != old.RPCServerTokenRate || settings.RPCServerMaxBurst != old.RPCServerMaxBurst {
+		return fmt.Errorf("Only the log level can be changed on clients")
+	}
+
+	if a.LogFilter != nil {
+		a.LogFilter.SetMinLevel(level)
+	}
+	if isServer {
+		srv.SetRuntimeSettings(&settings)
+	}
+
+	settings.LogLevel = string(level)
+	for _, change := range []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"LogLevel", old.LogLevel, settings.LogLevel},
+		{"SlowQueryThreshold", old.SlowQueryThreshold, settings.SlowQueryThreshold},
+		{"MaxQueryTime", old.MaxQueryTime, settings.MaxQueryTime},
+		{"RPCServerReadRate", old.RPCServerReadRate, settings.RPCServerReadRate},
+		{"RPCServerWriteRate", old.RPCServerWriteRate, settings.RPCServerWriteRate},
+		{"RPCServerTokenRate", old.RPCServerTokenRate, settings.RPCServerTokenRate},
+		{"RPCServerMaxBurst", old.RPCServerMaxBurst, settings.RPCServerMaxBurst},
+	} {
+		if change.old != change.new {
+			a.logger.Printf("[INFO] agent: Runtime setting %s changed from %v to %v", change.name, change.old, change.new)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/consul/agent/consul"
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// GET /v1/service-failovers
+func (s *HTTPServer) ServiceFailoverList(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedServiceFailovers
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("ServiceFailover.List", &args, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Failovers, nil
+}
+
+// ServiceFailoverSpecific handles the GET, PUT and DELETE operations on the
+// failover policy of a single service at /v1/service-failover/:service.
+func (s *HTTPServer) ServiceFailoverSpecific(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	service := strings.TrimPrefix(req.URL.Path, "/v1/service-failover/")
+	if service == "" {
+		return nil, BadRequestError{Reason: "Missing service name"}
+	}
+
+	switch req.Method {
+	case "GET":
+		return s.serviceFailoverGet(service, resp, req)
+	case "PUT":
+		return s.serviceFailoverApply(structs.ServiceFailoverOpUpsert, service, resp, req)
+	case "DELETE":
+		return s.serviceFailoverApply(structs.ServiceFailoverOpDelete, service, resp, req)
+	default:
+		return nil, MethodNotAllowedError{req.Method, []string{"GET", "PUT", "DELETE"}}
+	}
+}
+
+// GET /v1/service-failover/:service
+func (s *HTTPServer) serviceFailoverGet(service string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ServiceSpecificRequest{
+		ServiceName: service,
+	}
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedServiceFailovers
+	defer setMeta(resp, &reply.QueryMeta)
+	if err := s.agent.RPC("ServiceFailover.Get", &args, &reply); err != nil {
+		// We have to check the string since the RPC sheds the error type
+		if err.Error() == consul.ErrServiceFailoverNotFound.Error() {
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(resp, err.Error())
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// This shouldn't happen since the RPC returns an error if the policy
+	// doesn't exist.
+	if len(reply.Failovers) != 1 {
+		return nil, fmt.Errorf("internal error loading service failover")
+	}
+	return reply.Failovers[0], nil
+}
+
+// PUT and DELETE /v1/service-failover/:service
+func (s *HTTPServer) serviceFailoverApply(op structs.ServiceFailoverOp, service string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ServiceFailoverRequest{
+		Op:       op,
+		Failover: &structs.ServiceFailover{},
+	}
+	s.parseDC(req, &args.Datacenter)
+	s.parseToken(req, &args.Token)
+
+	if op == structs.ServiceFailoverOpUpsert {
+		if err := decodeBody(req, args.Failover, nil); err != nil {
+			return nil, BadRequestError{Reason: fmt.Sprintf("Request decode failed: %v", err)}
+		}
+	}
+	if args.Failover.Service != "" && args.Failover.Service != service {
+		return nil, BadRequestError{Reason: "Service name in URL and payload do not match"}
+	}
+	args.Failover.Service = service
+
+	var reply struct{}
+	if err := s.agent.RPC("ServiceFailover.Apply", &args, &reply); err != nil {
+		if strings.Contains(err.Error(), "Must provide") || strings.Contains(err.Error(), "Bad NearestN") ||
+			strings.Contains(err.Error(), "Datacenters can't") {
+			return nil, BadRequestError{Reason: err.Error()}
+		}
+		return nil, err
+	}
+	return true, nil
+}
@@ -0,0 +1,289 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/types"
+)
+
+// servicesDirPollInterval is how often the services directory is scanned for
+// changes. A change is only acted on once the directory has looked the same
+// for a whole interval, so files that are still being written aren't loaded.
+const servicesDirPollInterval = 2 * time.Second
+
+// servicesDirFile is a file in the services directory, and what was
+// registered from it.
+type servicesDirFile struct {
+	modTime time.Time
+	size    int64
+
+	services []string
+	checks   []types.CheckID
+}
+
+// changed returns true if the file looks different to the given one.
+func (f *servicesDirFile) changed(other *servicesDirFile) bool {
+	return other == nil || !f.modTime.Equal(other.modTime) || f.size != other.size
+}
+
+// servicesDirWatcher registers the services and checks defined in the files
+// of a directory, and keeps them up to date as the files are added, changed
+// and removed, so config management can register them by dropping in a file
+// rather than reloading the agent or using the API. The files have the same
+// format as service and check definitions in the agent's configuration.
+type servicesDirWatcher struct {
+	agent  *Agent
+	dir    string
+	logger *log.Logger
+
+	// files are the files that were last synced, by name.
+	files map[string]*servicesDirFile
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// newServicesDirWatcher returns a watcher for the given directory. Run must
+// be called to start watching it.
+func newServicesDirWatcher(a *Agent, dir string) *servicesDirWatcher {
+	return &servicesDirWatcher{
+		agent:  a,
+		dir:    dir,
+		logger: a.logger,
+		files:  make(map[string]*servicesDirFile),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Run syncs the directory each time it changes until Stop is called.
+func (w *servicesDirWatcher) Run() {
+	defer close(w.doneCh)
+
+	last := w.scan()
+	for {
+		select {
+		case <-time.After(servicesDirPollInterval):
+		case <-w.stopCh:
+			return
+		}
+
+		// Wait for the directory to settle before syncing.
+		files := w.scan()
+		if servicesDirChanged(files, last) {
+			last = files
+			continue
+		}
+		if servicesDirChanged(files, w.files) {
+			w.sync(files)
+		}
+	}
+}
+
+// Stop stops watching the directory and waits for any sync in progress to
+// finish. The services and checks that were registered are left alone.
+func (w *servicesDirWatcher) Stop() {
+	close(w.stopCh)
+	<-w.doneCh
+}
+
+// scan returns the definition files in the directory.
+func (w *servicesDirWatcher) scan() map[string]*servicesDirFile {
+	infos, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		w.logger.Printf("[ERR] agent: Failed to read services dir %q: %v", w.dir, err)
+		return w.files
+	}
+
+	files := make(map[string]*servicesDirFile)
+	for _, fi := range infos {
+		ext := filepath.Ext(fi.Name())
+		if !fi.Mode().IsRegular() || (ext != ".json" && ext != ".hcl") {
+			continue
+		}
+		files[fi.Name()] = &servicesDirFile{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return files
+}
+
+// servicesDirChanged returns true if any file was added, changed or removed.
+func servicesDirChanged(files, last map[string]*servicesDirFile) bool {
+	if len(files) != len(last) {
+		return true
+	}
+	for name, f := range files {
+		if f.changed(last[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+// sync registers the services and checks of the files that were added or
+// changed, and deregisters the ones that are no longer defined.
+func (w *servicesDirWatcher) sync(files map[string]*servicesDirFile) {
+	for name, f := range files {
+		old := w.files[name]
+		if !f.changed(old) {
+			f.services, f.checks = old.services, old.checks
+			continue
+		}
+
+		// A file that doesn't parse is left as it was, until it changes.
+		path := filepath.Join(w.dir, name)
+		cfg, err := w.parse(path)
+		if err != nil {
+			w.logger.Printf("[ERR] agent: Failed to parse services file %q: %v", path, err)
+			if old != nil {
+				f.services, f.checks = old.services, old.checks
+			}
+			continue
+		}
+
+		if err := w.load(cfg, f); err != nil {
+			w.logger.Printf("[ERR] agent: Failed to load services file %q: %v", path, err)
+		} else {
+			w.logger.Printf("[INFO] agent: Loaded %d services and %d checks from %q",
+				len(f.services), len(f.checks), path)
+		}
+		if old != nil {
+			w.remove(old, f)
+		}
+	}
+
+	for name, old := range w.files {
+		if _, ok := files[name]; !ok {
+			w.logger.Printf("[INFO] agent: Services file %q was removed", filepath.Join(w.dir, name))
+			w.remove(old, nil)
+		}
+	}
+	w.files = files
+}
+
+// parse reads the service and check definitions in a file.
+func (w *servicesDirWatcher) parse(path string) (*config.RuntimeConfig, error) {
+	// Dev mode gives a valid configuration without any other settings, and
+	// doesn't define any services or checks of its own.
+	devMode := true
+	b, err := config.NewBuilder(config.Flags{
+		ConfigFiles: []string{path},
+		DevMode:     &devMode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := b.BuildAndValidate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// load registers the services and checks defined in a file, and records
+// their IDs in f. Whatever was registered before an error is still recorded.
+func (w *servicesDirWatcher) load(cfg *config.RuntimeConfig, f *servicesDirFile) error {
+	a := w.agent
+	for _, service := range cfg.Services {
+		ns := service.NodeService()
+		chkTypes, err := service.CheckTypes()
+		if err != nil {
+			return fmt.Errorf("invalid checks for service %q: %v", service.Name, err)
+		}
+		sidecar, sidecarChecks, sidecarToken, err := a.sidecarServiceFromNodeService(ns, service.Token)
+		if err != nil {
+			return fmt.Errorf("invalid sidecar for service %q: %v", service.Name, err)
+		}
+		ns.Connect.SidecarService = nil
+
+		if err := a.AddService(ns, chkTypes, false, service.Token, ConfigSourceLocal); err != nil {
+			return fmt.Errorf("failed to register service %q: %v", service.Name, err)
+		}
+		f.services = append(f.services, ns.ID)
+
+		if sidecar != nil {
+			if err := a.AddService(sidecar, sidecarChecks, false, sidecarToken, ConfigSourceLocal); err != nil {
+				return fmt.Errorf("failed to register sidecar for service %q: %v", service.Name, err)
+			}
+			f.services = append(f.services, sidecar.ID)
+		}
+	}
+
+	for _, check := range cfg.Checks {
+		health := check.HealthCheck(a.config.NodeName)
+		if err := a.AddCheck(health, check.CheckType(), false, check.Token, ConfigSourceLocal); err != nil {
+			return fmt.Errorf("failed to register check %q: %v", check.Name, err)
+		}
+		f.checks = append(f.checks, health.CheckID)
+	}
+	return nil
+}
+
+// remove deregisters the services and checks of the old version of a file
+// that aren't in the new one, which is nil if the file was removed.
+func (w *servicesDirWatcher) remove(old, f *servicesDirFile) {
+	keepServices := make(map[string]bool)
+	keepChecks := make(map[types.CheckID]bool)
+	if f != nil {
+		for _, id := range f.services {
+			keepServices[id] = true
+		}
+		for _, id := range f.checks {
+			keepChecks[id] = true
+		}
+	}
+
+	for _, id := range old.checks {
+		if !keepChecks[id] {
+			if err := w.agent.RemoveCheck(id, false); err != nil {
+				w.logger.Printf("[WARN] agent: Failed to deregister check %q: %v", id, err)
+			}
+		}
+	}
+	for _, id := range old.services {
+		if !keepServices[id] {
+			if err := w.agent.RemoveService(id, false); err != nil {
+				w.logger.Printf("[WARN] agent: Failed to deregister service %q: %v", id, err)
+			}
+		}
+	}
+}
+
+// reloadServicesDir stops watching the current services directory, if any,
+// and starts watching the configured one, after registering what it already
+// defines. The services and checks from the old directory are left alone,
+// since a reload unloads them anyway.
+func (a *Agent) reloadServicesDir(cfg *config.RuntimeConfig) {
+	a.servicesDirLock.Lock()
+	defer a.servicesDirLock.Unlock()
+
+	a.stopServicesDirLocked()
+	if cfg.ServicesDir == "" {
+		return
+	}
+
+	w := newServicesDirWatcher(a, cfg.ServicesDir)
+	w.sync(w.scan())
+	a.servicesDir = w
+	go w.Run()
+}
+
+// stopServicesDir stops watching the services directory.
+func (a *Agent) stopServicesDir() {
+	a.servicesDirLock.Lock()
+	defer a.servicesDirLock.Unlock()
+	a.stopServicesDirLocked()
+}
+
+// stopServicesDirLocked stops watching the services directory. The services
+// dir lock must be held.
+func (a *Agent) stopServicesDirLocked() {
+	if a.servicesDir != nil {
+		a.servicesDir.Stop()
+		a.servicesDir = nil
+	}
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/hashicorp/consul/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_ServicesDir(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "services")
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) {
+		t.Helper()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+	}
+	write("web.json", `{ "service": { "name": "web", "port": 8080 } }`)
+	write("ignored.txt", `{ "service": { "name": "ignored" } }`)
+
+	a := NewTestAgent(t.Name(), fmt.Sprintf(`services_dir = %q`, dir))
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// The files that are already there are loaded on start.
+	require.NotNil(t, a.State.Service("web"))
+	require.Nil(t, a.State.Service("ignored"))
+
+	waitFor := func(services []string, checks []types.CheckID, absent ...string) {
+		t.Helper()
+		timer := &retry.Timer{Timeout: 15 * time.Second, Wait: 100 * time.Millisecond}
+		retry.RunWith(timer, t, func(r *retry.R) {
+			for _, id := range services {
+				if a.State.Service(id) == nil {
+					r.Fatalf("service %q is missing", id)
+				}
+			}
+			for _, id := range checks {
+				if a.State.Check(id) == nil {
+					r.Fatalf("check %q is missing", id)
+				}
+			}
+			for _, id := range absent {
+				if a.State.Service(id) != nil || a.State.Check(types.CheckID(id)) != nil {
+					r.Fatalf("%q is still registered", id)
+				}
+			}
+		})
+	}
+
+	// Added files are loaded.
+	write("db.hcl", `
+		service {
+			name = "db"
+			port = 5432
+		}
+		check {
+			id = "disk"
+			name = "disk"
+			ttl = "30s"
+		}
+	`)
+	waitFor([]string{"web", "db"}, []types.CheckID{"disk"})
+
+	// Changed files are reloaded, and what they no longer define is removed.
+	write("db.hcl", `
+		service {
+			name = "database"
+			port = 5432
+		}
+	`)
+	waitFor([]string{"web", "database"}, nil, "db", "disk")
+
+	// Removed files are unloaded.
+	require.NoError(t, os.Remove(filepath.Join(dir, "web.json")))
+	waitFor([]string{"database"}, nil, "web")
+
+	// Reloading keeps what's in the directory.
+	require.NoError(t, a.ReloadConfig(a.config))
+	require.NotNil(t, a.State.Service("database"))
+	require.Nil(t, a.State.Service("web"))
+}
@@ -40,7 +40,9 @@ func (s *HTTPServer) SessionCreate(resp http.ResponseWriter, req *http.Request)
 		},
 	}
 	s.parseDC(req, &args.Datacenter)
-	s.parseToken(req, &args.Token)
+	if s.parseWriteRequest(resp, req, &args.WriteRequest) {
+		return nil, nil
+	}
 
 	// Handle optional request body
 	if req.ContentLength > 0 {
@@ -136,7 +138,9 @@ func (s *HTTPServer) SessionDestroy(resp http.ResponseWriter, req *http.Request)
 		Op: structs.SessionDestroy,
 	}
 	s.parseDC(req, &args.Datacenter)
-	s.parseToken(req, &args.Token)
+	if s.parseWriteRequest(resp, req, &args.WriteRequest) {
+		return nil, nil
+	}
 
 	// Pull out the session id
 	args.Session.ID = strings.TrimPrefix(req.URL.Path, "/v1/session/destroy/")
@@ -214,6 +218,7 @@ func (s *HTTPServer) SessionList(resp http.ResponseWriter, req *http.Request) (i
 	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
 		return nil, nil
 	}
+	args.SessionMetaFilters = parseMetaPairs(req, "session-meta")
 
 	var out structs.IndexedSessions
 	defer setMeta(resp, &out.QueryMeta)
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/consul/agent/pool"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/lib"
+	"github.com/hashicorp/consul/lib/file"
+)
+
+// StateDump is the agent's in-memory view of the cluster and its local state.
+// It is written to the data dir on request to debug the agent disagreeing with
+// the servers about something, so it deliberately doesn't include any tokens.
+type StateDump struct {
+	Time       time.Time
+	Node       string
+	Datacenter string
+	Server     bool
+
+	// Servers are the servers known to the agent, in the order a client
+	// will try them for RPCs.
+	Servers []string
+
+	// Services and Checks are the local registrations, and whether the
+	// agent believes they are in sync with the catalog.
+	Services []StateDumpService
+	Checks   []StateDumpCheck
+
+	// Watches are the watch plans that are running.
+	Watches []StateDumpWatch
+
+	// ConnPool describes the open RPC connections to servers.
+	ConnPool pool.PoolStats
+
+	// Coordinates are the agent's network coordinates for each segment.
+	Coordinates lib.CoordinateSet
+}
+
+// StateDumpService is a local service in a state dump.
+type StateDumpService struct {
+	Service *structs.NodeService
+	InSync  bool
+}
+
+// StateDumpCheck is a local check in a state dump. Deferred is true if an
+// update to the check's output is waiting to be synced.
+type StateDumpCheck struct {
+	Check    *structs.HealthCheck
+	InSync   bool
+	Deferred bool
+}
+
+// StateDumpWatch is a running watch plan in a state dump.
+type StateDumpWatch struct {
+	Type        string
+	Datacenter  string
+	HandlerType string
+}
+
+// StateDump returns the agent's current in-memory state.
+func (a *Agent) StateDump() *StateDump {
+	dump := &StateDump{
+		Time:       time.Now().UTC(),
+		Node:       a.config.NodeName,
+		Datacenter: a.config.Datacenter,
+		Server:     a.config.ServerMode,
+	}
+
+	debug := a.delegate.DebugState()
+	dump.Servers = debug.Servers
+	dump.ConnPool = debug.ConnPool
+
+	for _, s := range a.State.ServiceStates() {
+		dump.Services = append(dump.Services, StateDumpService{
+			Service: s.Service,
+			InSync:  s.InSync,
+		})
+	}
+	sort.Slice(dump.Services, func(i, j int) bool {
+		return dump.Services[i].Service.ID < dump.Services[j].Service.ID
+	})
+
+	for _, c := range a.State.CheckStates() {
+		dump.Checks = append(dump.Checks, StateDumpCheck{
+			Check:    c.Check,
+			InSync:   c.InSync,
+			Deferred: c.DeferCheck != nil,
+		})
+	}
+	sort.Slice(dump.Checks, func(i, j int) bool {
+		return dump.Checks[i].Check.CheckID < dump.Checks[j].Check.CheckID
+	})
+
+	a.watchPlansLock.Lock()
+	for _, wp := range a.watchPlans {
+		dump.Watches = append(dump.Watches, StateDumpWatch{
+			Type:        wp.Type,
+			Datacenter:  wp.Datacenter,
+			HandlerType: wp.HandlerType,
+		})
+	}
+	a.watchPlansLock.Unlock()
+
+	if coords, err := a.delegate.GetLANCoordinate(); err == nil {
+		dump.Coordinates = coords
+	} else {
+		a.logger.Printf("[WARN] agent: Failed to get coordinates for state dump: %v", err)
+	}
+
+	return dump
+}
+
+// WriteStateDump writes the agent's current in-memory state to a timestamped
+// JSON file in the data dir and returns its path along with the dump.
+func (a *Agent) WriteStateDump() (string, *StateDump, error) {
+	if a.config.DataDir == "" {
+		return "", nil, fmt.Errorf("state dumps require a data dir")
+	}
+
+	dump := a.StateDump()
+	buf, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := fmt.Sprintf("state-dump-%s.json", dump.Time.Format("20060102T150405.000Z"))
+	path := filepath.Join(a.config.DataDir, "debug", name)
+	if err := file.WriteAtomic(path, buf); err != nil {
+		return "", nil, err
+	}
+	a.logger.Printf("[INFO] agent: Wrote state dump to %q", path)
+	return path, dump, nil
+}
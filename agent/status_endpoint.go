@@ -2,6 +2,8 @@ package agent
 
 import (
 	"net/http"
+
+	"github.com/hashicorp/consul/agent/structs"
 )
 
 func (s *HTTPServer) StatusLeader(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
@@ -19,3 +21,19 @@ func (s *HTTPServer) StatusPeers(resp http.ResponseWriter, req *http.Request) (i
 	}
 	return out, nil
 }
+
+func (s *HTTPServer) StatusRaftStats(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var out structs.RaftStatsResponse
+	if err := s.agent.RPC("Status.ClusterRaftStats", &args, &out); err != nil {
+		return nil, err
+	}
+	if out.Servers == nil {
+		out.Servers = make([]*structs.RaftStats, 0)
+	}
+	return out.Servers, nil
+}
@@ -2,8 +2,10 @@ package agent
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/hashicorp/consul/agent/structs"
 	"github.com/hashicorp/consul/testrpc"
 )
 
@@ -40,3 +42,25 @@ func TestStatusPeers(t *testing.T) {
 		t.Fatalf("bad peers: %v", peers)
 	}
 }
+
+func TestStatusRaftStats(t *testing.T) {
+	t.Parallel()
+	a := NewTestAgent(t.Name(), "")
+	defer a.Shutdown()
+	testrpc.WaitForLeader(t, a.RPC, "dc1")
+
+	req, _ := http.NewRequest("GET", "/v1/status/raft-stats", nil)
+	resp := httptest.NewRecorder()
+	obj, err := a.srv.StatusRaftStats(resp, req)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	servers := obj.([]*structs.RaftStats)
+	if len(servers) != 1 {
+		t.Fatalf("bad servers: %v", servers)
+	}
+	if servers[0].Name != a.Config.NodeName || servers[0].State != "Leader" || servers[0].AppliedIndex == 0 {
+		t.Fatalf("bad stats: %#v", servers[0])
+	}
+}
@@ -153,6 +153,15 @@ type ACLToken struct {
 	// The time when this token was created
 	CreateTime time.Time `json:",omitempty"`
 
+	// ExpirationTime is when the token stops being valid, after which the
+	// leader deletes it. Only tokens created by logging in expire.
+	ExpirationTime *time.Time `json:",omitempty"`
+
+	// AuthMethod is the name of the auth method this token was created by
+	// logging in to, if any. These tokens can delete themselves by
+	// logging out.
+	AuthMethod string `json:",omitempty"`
+
 	// Hash of the contents of the token
 	//
 	// This is needed mainly for replication purposes. When replicating from
@@ -172,6 +181,12 @@ func (t *ACLToken) SecretToken() string {
 	return t.SecretID
 }
 
+// IsExpired returns true if the token has an expiration time that has
+// passed.
+func (t *ACLToken) IsExpired(now time.Time) bool {
+	return t.ExpirationTime != nil && !now.Before(*t.ExpirationTime)
+}
+
 func (t *ACLToken) PolicyIDs() []string {
 	var ids []string
 	for _, link := range t.Policies {
@@ -233,6 +248,11 @@ func (t *ACLToken) SetHash(force bool) []byte {
 			hash.Write([]byte(link.ID))
 		}
 
+		if t.ExpirationTime != nil {
+			hash.Write([]byte(t.ExpirationTime.UTC().Format(time.RFC3339Nano)))
+		}
+		hash.Write([]byte(t.AuthMethod))
+
 		// Finalize the hash
 		hashVal := hash.Sum(nil)
 
@@ -244,7 +264,7 @@ func (t *ACLToken) SetHash(force bool) []byte {
 
 func (t *ACLToken) EstimateSize() int {
 	// 33 = 16 (RaftIndex) + 8 (Hash) + 8 (CreateTime) + 1 (Local)
-	size := 33 + len(t.AccessorID) + len(t.SecretID) + len(t.Description) + len(t.Type) + len(t.Rules)
+	size := 33 + len(t.AccessorID) + len(t.SecretID) + len(t.Description) + len(t.Type) + len(t.Rules) + len(t.AuthMethod)
 	for _, link := range t.Policies {
 		size += len(link.ID) + len(link.Name)
 	}
@@ -264,6 +284,14 @@ type ACLTokenListStub struct {
 	CreateIndex uint64
 	ModifyIndex uint64
 	Legacy      bool `json:",omitempty"`
+
+	ExpirationTime *time.Time `json:",omitempty"`
+	AuthMethod     string     `json:",omitempty"`
+
+	// LastUsed is when the token was last used to resolve ACLs in the
+	// datacenter, if it's been used since the servers started tracking it.
+	// It's only accurate to within an hour.
+	LastUsed *time.Time `json:",omitempty"`
 }
 
 type ACLTokenListStubs []*ACLTokenListStub
@@ -279,6 +307,9 @@ func (token *ACLToken) Stub() *ACLTokenListStub {
 		CreateIndex: token.CreateIndex,
 		ModifyIndex: token.ModifyIndex,
 		Legacy:      token.Rules != "",
+
+		ExpirationTime: token.ExpirationTime,
+		AuthMethod:     token.AuthMethod,
 	}
 }
 
@@ -587,6 +618,42 @@ type ACLTokenBatchDeleteRequest struct {
 	TokenIDs []string // Tokens to delete
 }
 
+// ACLTokenUsage is when a token was last used to resolve ACLs in a
+// datacenter.
+type ACLTokenUsage struct {
+	AccessorID string
+	LastUsed   time.Time
+}
+
+// ACLTokenUsageRequest is used by the servers to report when the tokens they
+// resolved were last used.
+type ACLTokenUsageRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Node is the name of the server reporting the usage.
+	Node string
+
+	// SourceDatacenter is the datacenter of the server reporting the
+	// usage. The ACL datacenter only deletes unused global tokens while
+	// every datacenter is reporting.
+	SourceDatacenter string
+
+	// Usage is when each of the tokens was last used. It can be empty, in
+	// which case the request just reports that the server is still
+	// reporting.
+	Usage []*ACLTokenUsage
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ACLTokenUsageRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
 // ACLTokenBootstrapRequest is used only at the Raft layer
 // for ACL bootstrapping
 //
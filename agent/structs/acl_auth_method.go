@@ -0,0 +1,224 @@
+package structs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// ACLAuthMethodTypeJWT is the type of auth method that logs in with a
+	// JSON Web Token signed by one of the configured keys.
+	ACLAuthMethodTypeJWT = "jwt"
+
+	// defaultACLAuthMethodMaxTokenTTL is how long tokens created by logging
+	// in are valid for by default.
+	defaultACLAuthMethodMaxTokenTTL = time.Hour
+)
+
+// aclBindVariable matches the variables that can be used in the policies of
+// a binding rule, such as ${service}.
+var aclBindVariable = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// ACLAuthMethodConfig is the body of a config entry of the acl-auth-method
+// kind. Workloads log in to an auth method with a credential issued by a
+// third party, and get back a Consul token with the policies chosen by the
+// binding rules.
+type ACLAuthMethodConfig struct {
+	// Type is the kind of credential accepted. Only "jwt" is supported.
+	Type string
+
+	// JWTValidationPubKeys are the PEM encoded RSA or ECDSA public keys
+	// that credentials must be signed by.
+	JWTValidationPubKeys []string
+
+	// BoundIssuer, if set, must match the "iss" claim of credentials.
+	BoundIssuer string
+
+	// BoundAudiences, if set, must include one of the "aud" claims of
+	// credentials.
+	BoundAudiences []string
+
+	// ClaimMappings maps the names of claims in credentials to the names
+	// of variables the binding rules can use. Only mapped claims can be
+	// used.
+	ClaimMappings map[string]string
+
+	// MaxTokenTTL is how long the tokens created by logging in are valid.
+	MaxTokenTTL time.Duration
+
+	// BindingRules choose the policies of the tokens created by logging
+	// in. The policies of every matching rule are granted.
+	BindingRules []ACLBindingRule
+}
+
+// ACLBindingRule grants policies to the tokens created by logging in to an
+// auth method.
+type ACLBindingRule struct {
+	// Description is a human readable description of the rule.
+	Description string
+
+	// Match has the values variables must have for the rule to apply. A
+	// rule without any applies to every login.
+	Match map[string]string
+
+	// Policies are the names of the policies granted. They can use
+	// variables, such as "service-${service}". Policies that don't exist
+	// are skipped.
+	Policies []string
+}
+
+// ParseACLAuthMethodConfig decodes and validates the body of an
+// acl-auth-method config entry, filling in the defaults.
+func ParseACLAuthMethodConfig(raw map[string]interface{}) (*ACLAuthMethodConfig, error) {
+	config := ACLAuthMethodConfig{
+		Type:        ACLAuthMethodTypeJWT,
+		MaxTokenTTL: defaultACLAuthMethodMaxTokenTTL,
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       ParseDurationFunc(),
+		ErrorUnused:      true,
+		Result:           &config,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return nil, fmt.Errorf("error decoding auth method config: %s", err)
+	}
+
+	if config.Type != ACLAuthMethodTypeJWT {
+		return nil, fmt.Errorf("Invalid auth method type %q", config.Type)
+	}
+	if len(config.JWTValidationPubKeys) == 0 {
+		return nil, fmt.Errorf("Auth method must have at least one JWT validation public key")
+	}
+	if _, err := config.PublicKeys(); err != nil {
+		return nil, err
+	}
+	if config.MaxTokenTTL <= 0 {
+		return nil, fmt.Errorf("Auth method max token TTL must be positive")
+	}
+
+	vars := make(map[string]bool)
+	for _, name := range config.ClaimMappings {
+		vars[name] = true
+	}
+	for i, rule := range config.BindingRules {
+		if len(rule.Policies) == 0 {
+			return nil, fmt.Errorf("Binding rule %d must grant at least one policy", i)
+		}
+		for name := range rule.Match {
+			if !vars[name] {
+				return nil, fmt.Errorf("Binding rule %d matches unknown variable %q", i, name)
+			}
+		}
+		for _, policy := range rule.Policies {
+			for _, m := range aclBindVariable.FindAllStringSubmatch(policy, -1) {
+				if !vars[m[1]] {
+					return nil, fmt.Errorf("Binding rule %d uses unknown variable %q", i, m[1])
+				}
+			}
+		}
+	}
+	return &config, nil
+}
+
+// PublicKeys parses the JWT validation public keys.
+func (c *ACLAuthMethodConfig) PublicKeys() ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for i, raw := range c.JWTValidationPubKeys {
+		block, _ := pem.Decode([]byte(raw))
+		if block == nil {
+			return nil, fmt.Errorf("JWT validation public key %d isn't PEM encoded", i)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse JWT validation public key %d: %v", i, err)
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("JWT validation public key %d must be an RSA or ECDSA key", i)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// BindPolicies returns the names of the policies granted by the binding rules
+// that match the given variables, with the variables filled in.
+func (c *ACLAuthMethodConfig) BindPolicies(vars map[string]string) []string {
+	seen := make(map[string]bool)
+	var policies []string
+	for _, rule := range c.BindingRules {
+		matched := true
+		for name, value := range rule.Match {
+			if vars[name] != value {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		for _, policy := range rule.Policies {
+			name := aclBindVariable.ReplaceAllStringFunc(policy, func(v string) string {
+				return vars[strings.TrimSuffix(strings.TrimPrefix(v, "${"), "}")]
+			})
+			if !seen[name] {
+				seen[name] = true
+				policies = append(policies, name)
+			}
+		}
+	}
+	return policies
+}
+
+// ACLLoginParams are the details a workload logs in with.
+type ACLLoginParams struct {
+	// AuthMethod is the name of the auth method to log in to.
+	AuthMethod string
+
+	// BearerToken is the credential issued by the third party.
+	BearerToken string
+
+	// Meta is added to the description of the token, such as to say
+	// which workload logged in.
+	Meta map[string]string `json:",omitempty"`
+}
+
+// ACLLoginRequest is used to log in to an auth method.
+type ACLLoginRequest struct {
+	Datacenter string
+	Auth       *ACLLoginParams
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ACLLoginRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// ACLLogoutRequest is used to delete a token created by logging in. The token
+// to delete is the one the request is made with.
+type ACLLogoutRequest struct {
+	Datacenter string
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ACLLogoutRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
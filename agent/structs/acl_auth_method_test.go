@@ -0,0 +1,68 @@
+package structs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructs_ParseACLAuthMethodConfig(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pubKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := ParseACLAuthMethodConfig(map[string]interface{}{
+			"JWTValidationPubKeys": []interface{}{pubKey},
+		})
+		require.NoError(t, err)
+		require.Equal(t, ACLAuthMethodTypeJWT, config.Type)
+		require.Equal(t, time.Hour, config.MaxTokenTTL)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, raw := range map[string]map[string]interface{}{
+			"no keys":  {},
+			"bad key":  {"JWTValidationPubKeys": []interface{}{"nope"}},
+			"bad type": {"JWTValidationPubKeys": []interface{}{pubKey}, "Type": "ldap"},
+			"bad ttl":  {"JWTValidationPubKeys": []interface{}{pubKey}, "MaxTokenTTL": "-1s"},
+			"unknown":  {"JWTValidationPubKeys": []interface{}{pubKey}, "Foo": "bar"},
+			"unmapped variable": {
+				"JWTValidationPubKeys": []interface{}{pubKey},
+				"BindingRules": []interface{}{
+					map[string]interface{}{"Policies": []interface{}{"service-${service}"}},
+				},
+			},
+		} {
+			_, err := ParseACLAuthMethodConfig(raw)
+			require.Error(t, err, name)
+		}
+	})
+
+	t.Run("BindPolicies", func(t *testing.T) {
+		config, err := ParseACLAuthMethodConfig(map[string]interface{}{
+			"JWTValidationPubKeys": []interface{}{pubKey},
+			"ClaimMappings":        map[string]interface{}{"svc": "service", "env": "env"},
+			"BindingRules": []interface{}{
+				map[string]interface{}{"Policies": []interface{}{"service-${service}"}},
+				map[string]interface{}{
+					"Match":    map[string]interface{}{"env": "prod"},
+					"Policies": []interface{}{"prod", "service-${service}"},
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"service-web"}, config.BindPolicies(map[string]string{"service": "web", "env": "dev"}))
+		require.Equal(t, []string{"service-web", "prod"}, config.BindPolicies(map[string]string{"service": "web", "env": "prod"}))
+	})
+}
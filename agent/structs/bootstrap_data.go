@@ -0,0 +1,30 @@
+package structs
+
+// The parts of the bootstrap data directory that the leader records as loaded.
+// The catalog and KV part is loaded on the first leadership, while the ACL part
+// can have to wait for the ACL system to leave legacy mode, or for policies to
+// be replicated from the primary datacenter.
+const (
+	BootstrapDataPartCatalog = "catalog"
+	BootstrapDataPartACLs    = "acls"
+)
+
+// BootstrapDataRequest is used to record that a part of the bootstrap data
+// directory was loaded, so it's never loaded again, even by a different
+// leader.
+type BootstrapDataRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Part is the part that was loaded.
+	Part string
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *BootstrapDataRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
@@ -13,6 +13,14 @@ const (
 	SerfCheckFailedOutput               = "Agent not live or unreachable"
 )
 
+// These are used to manage the built-in "nodeProbe" check the servers attach
+// to external nodes that ask to be probed for liveness. It stands in for the
+// "serfHealth" check of nodes that run an agent.
+const (
+	NodeProbeCheckID   types.CheckID = "nodeProbe"
+	NodeProbeCheckName               = "Node Probe Status"
+)
+
 const (
 	// These are used to manage the "consul" service that's attached to every
 	// Consul server node in the catalog.
@@ -0,0 +1,120 @@
+package structs
+
+import (
+	"github.com/hashicorp/consul/types"
+)
+
+// ChangeType is the kind of data a change feed entry is about.
+type ChangeType string
+
+const (
+	ChangeKV      ChangeType = "kv"
+	ChangeNode    ChangeType = "node"
+	ChangeService ChangeType = "service"
+	ChangeCheck   ChangeType = "check"
+)
+
+// ChangeOp is the kind of mutation recorded in the change feed.
+type ChangeOp string
+
+const (
+	ChangeUpsert     ChangeOp = "upsert"
+	ChangeDelete     ChangeOp = "delete"
+	ChangeDeleteTree ChangeOp = "delete-tree"
+)
+
+// Change is a single catalog or KV mutation recorded in the change feed.
+type Change struct {
+	// Type is the kind of data that changed.
+	Type ChangeType
+
+	// Op is the mutation that was made.
+	Op ChangeOp
+
+	// Key is the KV key that changed, or the prefix for tree deletes.
+	Key string `json:",omitempty"`
+
+	// Node is the node a catalog change is for.
+	Node string `json:",omitempty"`
+
+	// ServiceID and CheckID identify the service or check that changed.
+	ServiceID string        `json:",omitempty"`
+	CheckID   types.CheckID `json:",omitempty"`
+
+	// KV, NodeEntry, Service and Check hold the written values for
+	// upserts. KV entries are read back after the write, so they include
+	// the new indexes and lock state.
+	KV        *DirEntry    `json:",omitempty"`
+	NodeEntry *Node        `json:",omitempty"`
+	Service   *NodeService `json:",omitempty"`
+	Check     *HealthCheck `json:",omitempty"`
+}
+
+// ChangeFeedEntry has the changes made by a single Raft log entry.
+type ChangeFeedEntry struct {
+	// Index is the Raft index of the write, which consumers use as their
+	// position in the feed.
+	Index uint64
+
+	// Seq numbers the entries in the order they were recorded, and is
+	// used to trim the feed to its maximum size.
+	Seq uint64
+
+	// Changes are the mutations made by the write, in order.
+	Changes []*Change
+}
+
+// ChangeFeedEntries is a list of change feed entries.
+type ChangeFeedEntries []*ChangeFeedEntry
+
+// ChangeFeedRequest is used to read the change feed. The MinQueryIndex of the
+// query options is the consumer's position: entries after it are returned,
+// and the query blocks if there aren't any yet.
+type ChangeFeedRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Limit is the most entries to return, or 0 for no limit.
+	Limit int
+
+	QueryOptions
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ChangeFeedRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// ChangeFeedConfigRequest is used to set how many entries the servers keep
+// in the change feed.
+type ChangeFeedConfigRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// MaxEntries is the most entries to keep, or 0 to disable the feed.
+	MaxEntries int
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ChangeFeedConfigRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedChangeFeed is the response for change feed queries.
+type IndexedChangeFeed struct {
+	// Entries are the entries after the requested index, oldest first.
+	Entries ChangeFeedEntries
+
+	// NextIndex is the position to read from next. It can be past the
+	// last returned entry when entries were left out by ACLs.
+	NextIndex uint64
+
+	// More is true if the entries were cut short by the limit.
+	More bool
+
+	QueryMeta
+}
@@ -0,0 +1,110 @@
+package structs
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// ChangeSinkWebhook is the type of change sink that POSTs batches of
+	// changes as JSON to a URL.
+	ChangeSinkWebhook = "webhook"
+
+	// ChangeSinkPositionKVPrefix is the KV prefix the leader keeps the
+	// position of each change sink under. The position is the change feed
+	// index the sink has delivered up to, so a new leader carries on where
+	// the last one stopped.
+	ChangeSinkPositionKVPrefix = "_consul/change-sink/"
+
+	// defaultChangeSinkBatchSize is how many change feed entries are sent
+	// in a batch by default.
+	defaultChangeSinkBatchSize = 64
+
+	// defaultChangeSinkTimeout is how long a sink waits for each batch to
+	// be accepted by default.
+	defaultChangeSinkTimeout = 10 * time.Second
+)
+
+// ChangeSinkPositionKey returns the KV key holding the position of the given
+// change sink.
+func ChangeSinkPositionKey(name string) string {
+	return ChangeSinkPositionKVPrefix + strings.ToLower(name)
+}
+
+// ChangeSinkConfig is the body of a config entry of the change-sink kind,
+// which has the leader publish catalog changes to an external system.
+type ChangeSinkConfig struct {
+	// Type is the kind of system the changes are published to. Only
+	// webhooks are supported, which is the default.
+	Type string
+
+	// URL is the address batches are POSTed to.
+	URL string
+
+	// Headers are added to every request, such as for authentication.
+	Headers map[string]string
+
+	// BatchSize is the most change feed entries sent in a single batch.
+	BatchSize int
+
+	// Timeout is how long to wait for a batch to be accepted before
+	// trying again.
+	Timeout time.Duration
+}
+
+// ParseChangeSinkConfig decodes and validates the body of a change-sink config
+// entry, filling in the defaults.
+func ParseChangeSinkConfig(raw map[string]interface{}) (*ChangeSinkConfig, error) {
+	config := ChangeSinkConfig{
+		Type:      ChangeSinkWebhook,
+		BatchSize: defaultChangeSinkBatchSize,
+		Timeout:   defaultChangeSinkTimeout,
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       ParseDurationFunc(),
+		ErrorUnused:      true,
+		Result:           &config,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return nil, fmt.Errorf("error decoding change sink config: %s", err)
+	}
+
+	if config.Type != ChangeSinkWebhook {
+		return nil, fmt.Errorf("Invalid change sink type %q", config.Type)
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("Change sink URL must be an absolute http or https URL")
+	}
+	if config.BatchSize < 1 {
+		return nil, fmt.Errorf("Change sink batch size must be at least 1")
+	}
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("Change sink timeout must be positive")
+	}
+	return &config, nil
+}
+
+// ChangeSinkBatch is the body of a request sent by a webhook change sink.
+// Batches are delivered at least once, so consumers should skip entries
+// with an index they've already processed.
+type ChangeSinkBatch struct {
+	// Sink is the name of the sink's config entry.
+	Sink string
+
+	// Datacenter is where the changes were made.
+	Datacenter string
+
+	// Entries are the change feed entries with catalog changes, oldest
+	// first. KV changes are left out.
+	Entries ChangeFeedEntries
+}
@@ -0,0 +1,193 @@
+package structs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/consul/acl"
+)
+
+const (
+	// ServiceDefaults is the kind of config entry that holds the defaults
+	// for a single service. Entries of this kind are named after their
+	// service, and their ACLs follow the service rules.
+	ServiceDefaults string = "service-defaults"
+
+	// ChangeSink is the kind of config entry that has the leader publish
+	// catalog changes to an external system. The body is decoded by
+	// ParseChangeSinkConfig.
+	ChangeSink string = "change-sink"
+
+	// ACLAuthMethod is the kind of config entry that lets workloads log in
+	// for an ACL token. The body is decoded by ParseACLAuthMethodConfig, and
+	// the entries need ACL privileges to read or write.
+	ACLAuthMethod string = "acl-auth-method"
+)
+
+// validConfigEntryKind is used to validate config entry kinds. Kinds are used
+// in URLs, so they are restricted to lowercase letters, digits and dashes.
+var validConfigEntryKind = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,62}[a-z0-9])?$`)
+
+// ConfigEntry is a generic piece of configuration stored by the servers,
+// identified by its kind and name. The body is opaque to the state store, so
+// new kinds of centrally managed configuration don't each need their own
+// table, Raft operation and endpoints.
+type ConfigEntry struct {
+	// Kind is the type of configuration, such as "service-defaults".
+	Kind string
+
+	// Name identifies the entry within its kind.
+	Name string
+
+	// Config is the body of the entry. Its format depends on the kind.
+	Config map[string]interface{}
+
+	RaftIndex
+}
+
+// Validate returns an error if the entry is invalid for inserting or
+// updating.
+func (e *ConfigEntry) Validate() error {
+	if !validConfigEntryKind.MatchString(e.Kind) {
+		return fmt.Errorf("Invalid config entry kind %q: must be 1-64 lowercase alphanumeric characters or dashes, and can't start or end with a dash", e.Kind)
+	}
+	if e.Name == "" {
+		return fmt.Errorf("Must provide a config entry name")
+	}
+	switch e.Kind {
+	case ChangeSink:
+		if strings.Contains(e.Name, "/") {
+			return fmt.Errorf("Change sink names can't contain '/'")
+		}
+		if _, err := ParseChangeSinkConfig(e.Config); err != nil {
+			return err
+		}
+	case ACLAuthMethod:
+		if _, err := ParseACLAuthMethodConfig(e.Config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NormalizeConfig undoes what msgpack does to the opaque body, turning the
+// []uint8 values it decodes strings into back into strings and nested maps
+// with interface{} keys back into maps with string keys, all the way down.
+// The body is decoded without knowing its types, so it's needed wherever an
+// entry is decoded: from the Raft log, from a snapshot and from an RPC reply,
+// so that entries render the same JSON that was put in.
+func (e *ConfigEntry) NormalizeConfig() {
+	for k, v := range e.Config {
+		e.Config[k] = normalizeConfigValue(v)
+	}
+}
+
+// normalizeConfigValue returns the given config value with any []uint8 in it
+// turned into strings.
+func normalizeConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []uint8:
+		return string(v)
+	case map[string]interface{}:
+		for k, elem := range v {
+			v[k] = normalizeConfigValue(elem)
+		}
+		return v
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			out[fmt.Sprintf("%v", normalizeConfigValue(k))] = normalizeConfigValue(elem)
+		}
+		return out
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = normalizeConfigValue(elem)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// CanRead returns true if the given ACL rule allows reading the entry.
+// Service defaults follow the rules of their service, auth methods need ACL
+// read privileges, change sinks need operator write privileges since their
+// headers usually hold credentials, and all other kinds need operator read
+// privileges.
+func (e *ConfigEntry) CanRead(rule acl.Authorizer) bool {
+	switch e.Kind {
+	case ServiceDefaults:
+		return rule.ServiceRead(e.Name)
+	case ACLAuthMethod:
+		return rule.ACLRead()
+	case ChangeSink:
+		return rule.OperatorWrite()
+	}
+	return rule.OperatorRead()
+}
+
+// CanWrite returns true if the given ACL rule allows creating, updating or
+// deleting the entry.
+func (e *ConfigEntry) CanWrite(rule acl.Authorizer) bool {
+	switch e.Kind {
+	case ServiceDefaults:
+		return rule.ServiceWrite(e.Name, nil)
+	case ACLAuthMethod:
+		return rule.ACLWrite()
+	}
+	return rule.OperatorWrite()
+}
+
+// ConfigEntries is a list of config entries.
+type ConfigEntries []*ConfigEntry
+
+// ConfigEntryOp is the operation for a request related to config entries.
+type ConfigEntryOp string
+
+const (
+	ConfigEntryOpUpsert ConfigEntryOp = "upsert"
+	ConfigEntryOpDelete ConfigEntryOp = "delete"
+)
+
+// ConfigEntryRequest is used to create, update, and delete config entries.
+type ConfigEntryRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Op is the type of operation being requested.
+	Op ConfigEntryOp
+
+	// Entry is the config entry to operate on. Only the kind and name are
+	// needed for deletes.
+	Entry *ConfigEntry
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ConfigEntryRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// ConfigEntryQuery is used to read config entries. The kind is required to
+// get a single entry by name, and is optional when listing.
+type ConfigEntryQuery struct {
+	Datacenter string
+	Kind       string
+	Name       string
+	QueryOptions
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ConfigEntryQuery) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedConfigEntries is the response for config entry queries.
+type IndexedConfigEntries struct {
+	Entries ConfigEntries
+	QueryMeta
+}
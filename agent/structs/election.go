@@ -0,0 +1,135 @@
+package structs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ElectionKVPrefix is the KV prefix elections keep their state under. Each
+// election has a "leader" key held by the leader's session, and a key per
+// candidate under "candidates/" held by that candidate's session.
+const ElectionKVPrefix = "_consul/election/"
+
+// ElectionPrefix returns the KV prefix of the given election.
+func ElectionPrefix(name string) string {
+	return ElectionKVPrefix + name + "/"
+}
+
+// ElectionLeaderKey returns the KV key held by the leader of the given
+// election.
+func ElectionLeaderKey(name string) string {
+	return ElectionPrefix(name) + "leader"
+}
+
+// ElectionCandidatePrefix returns the KV prefix of the candidates of the given
+// election.
+func ElectionCandidatePrefix(name string) string {
+	return ElectionPrefix(name) + "candidates/"
+}
+
+// ElectionCandidateKey returns the KV key held by the given candidate session.
+func ElectionCandidateKey(name, session string) string {
+	return ElectionCandidatePrefix(name) + session
+}
+
+// ValidateElectionName returns an error if the name can't be used for an
+// election.
+func ValidateElectionName(name string) error {
+	if name == "" {
+		return fmt.Errorf("Must provide an election name")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("Election names can't contain '/'")
+	}
+	return nil
+}
+
+// ElectionCandidate is a session taking part in an election.
+type ElectionCandidate struct {
+	// Session is the session of the candidate. The candidate drops out of
+	// the election when the session is invalidated.
+	Session string
+
+	// Value is opaque data the candidate supplied when it registered, such
+	// as its address. It is published as the leader's value if the
+	// candidate is elected.
+	Value []byte
+
+	// CreateIndex is when the candidate registered. Candidates are elected
+	// in the order they registered.
+	CreateIndex uint64
+}
+
+// Election is the state of a named election.
+type Election struct {
+	// Name is the name of the election.
+	Name string
+
+	// Leader is the current leader, or nil if there isn't one.
+	Leader *ElectionCandidate
+
+	// Term is increased each time a leader is elected, so it can be used to
+	// tell leaders apart even when the same session is elected again.
+	Term uint64
+
+	// Candidates are the registered candidates, including the leader, in
+	// the order they will be elected.
+	Candidates []*ElectionCandidate
+}
+
+// ElectionOp is the operation for an election request.
+type ElectionOp string
+
+const (
+	ElectionCampaign ElectionOp = "campaign"
+	ElectionResign   ElectionOp = "resign"
+)
+
+// ElectionRequest is used to register and deregister election candidates.
+type ElectionRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Op is the type of operation being requested.
+	Op ElectionOp
+
+	// Name is the name of the election.
+	Name string
+
+	// Session is the session of the candidate.
+	Session string
+
+	// Value is the candidate's value for campaigns.
+	Value []byte
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ElectionRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// ElectionSpecificRequest is used to read the state of an election.
+type ElectionSpecificRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Name is the name of the election.
+	Name string
+
+	QueryOptions
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ElectionSpecificRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedElection is the response for election queries.
+type IndexedElection struct {
+	Election *Election
+	QueryMeta
+}
@@ -12,7 +12,19 @@ const (
 	errNotReadyForConsistentReads = "Not ready to serve consistent reads"
 	errSegmentsNotSupported       = "Network segments are not supported in this version of Consul"
 	errRPCRateExceeded            = "RPC rate limit exceeded"
+	errBlockingQueryLimitExceeded = "Blocking query limit exceeded"
 	errServiceNotFound            = "Service not found: "
+	errFeatureNotSupported        = "Feature not supported by server"
+	errForwardingNotAllowed       = "Forwarding to datacenter not allowed"
+	errRequestTimeout             = "Request timed out"
+	errCatalogLimitExceeded       = "Catalog limit exceeded"
+	errCASFailed                  = "Check-and-set failed"
+	errRaftApplyQueueFull         = "Raft apply queue is full"
+	errStateMemoryBudgetExceeded  = "State store memory budget exceeded"
+	errInvalidRegistration        = "Invalid registration"
+	errDCUnavailable              = "Datacenter unavailable"
+	errQuerySourceMismatch        = "Query source doesn't match the agent making the request"
+	errResponseSigningDisabled    = "Response signing is not enabled"
 )
 
 var (
@@ -22,6 +34,18 @@ var (
 	ErrNotReadyForConsistentReads = errors.New(errNotReadyForConsistentReads)
 	ErrSegmentsNotSupported       = errors.New(errSegmentsNotSupported)
 	ErrRPCRateExceeded            = errors.New(errRPCRateExceeded)
+	ErrBlockingQueryLimitExceeded = errors.New(errBlockingQueryLimitExceeded)
+	ErrFeatureNotSupported        = errors.New(errFeatureNotSupported)
+	ErrForwardingNotAllowed       = errors.New(errForwardingNotAllowed)
+	ErrRequestTimeout             = errors.New(errRequestTimeout)
+	ErrCatalogLimitExceeded       = errors.New(errCatalogLimitExceeded)
+	ErrCASFailed                  = errors.New(errCASFailed)
+	ErrRaftApplyQueueFull         = errors.New(errRaftApplyQueueFull)
+	ErrStateMemoryBudgetExceeded  = errors.New(errStateMemoryBudgetExceeded)
+	ErrInvalidRegistration        = errors.New(errInvalidRegistration)
+	ErrDCUnavailable              = errors.New(errDCUnavailable)
+	ErrQuerySourceMismatch        = errors.New(errQuerySourceMismatch)
+	ErrResponseSigningDisabled    = errors.New(errResponseSigningDisabled)
 )
 
 func IsErrNoLeader(err error) bool {
@@ -32,6 +56,54 @@ func IsErrRPCRateExceeded(err error) bool {
 	return err != nil && strings.Contains(err.Error(), errRPCRateExceeded)
 }
 
+func IsErrBlockingQueryLimitExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errBlockingQueryLimitExceeded)
+}
+
+func IsErrFeatureNotSupported(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errFeatureNotSupported)
+}
+
+func IsErrForwardingNotAllowed(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errForwardingNotAllowed)
+}
+
+func IsErrRequestTimeout(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errRequestTimeout)
+}
+
+func IsErrCatalogLimitExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errCatalogLimitExceeded)
+}
+
+func IsErrStateMemoryBudgetExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errStateMemoryBudgetExceeded)
+}
+
+func IsErrCASFailed(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errCASFailed)
+}
+
+func IsErrRaftApplyQueueFull(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errRaftApplyQueueFull)
+}
+
 func IsErrServiceNotFound(err error) bool {
 	return err != nil && strings.Contains(err.Error(), errServiceNotFound)
 }
+
+func IsErrInvalidRegistration(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errInvalidRegistration)
+}
+
+func IsErrDCUnavailable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errDCUnavailable)
+}
+
+func IsErrQuerySourceMismatch(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errQuerySourceMismatch)
+}
+
+func IsErrResponseSigningDisabled(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errResponseSigningDisabled)
+}
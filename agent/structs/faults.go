@@ -0,0 +1,54 @@
+package structs
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// FaultRaftApply is the method name fault rules use to match Raft applies.
+const FaultRaftApply = "Raft.Apply"
+
+// FaultRule injects latency and errors into the RPCs a server handles, so
+// applications can be tested against a slow or flaky cluster. Fault
+// injection is only available in dev mode.
+type FaultRule struct {
+	// Method is a glob matched against the RPC method, such as
+	// "Catalog.Register", "Health.*" or "*". Raft applies match
+	// "Raft.Apply".
+	Method string
+
+	// Latency is added to each matching call before it's handled.
+	Latency time.Duration
+
+	// ErrorRate is the fraction of matching calls, from 0 to 1, that fail
+	// instead of being handled.
+	ErrorRate float64
+
+	// Error is the error failed calls return. A generic error is returned
+	// if it's empty.
+	Error string
+}
+
+// Validate returns an error if the rule can't be used.
+func (r *FaultRule) Validate() error {
+	if r.Method == "" {
+		return fmt.Errorf("Must provide a method")
+	}
+	if _, err := path.Match(r.Method, ""); err != nil {
+		return fmt.Errorf("Invalid method %q: %v", r.Method, err)
+	}
+	if r.Latency < 0 {
+		return fmt.Errorf("Latency cannot be %s. Must be greater than or equal to zero", r.Latency)
+	}
+	if r.ErrorRate < 0 || r.ErrorRate > 1 {
+		return fmt.Errorf("Error rate cannot be %v. Must be between 0 and 1", r.ErrorRate)
+	}
+	return nil
+}
+
+// Matches returns true if the rule applies to the given method.
+func (r *FaultRule) Matches(method string) bool {
+	ok, _ := path.Match(r.Method, method)
+	return ok
+}
@@ -0,0 +1,64 @@
+package structs
+
+import (
+	"time"
+)
+
+// LeaderHistoryMaxEntries is how many leader transitions are kept. Older
+// ones are trimmed as new ones are recorded.
+const LeaderHistoryMaxEntries = 100
+
+// LeaderTransition is a change of leader, recorded by the new leader once it
+// has established its leadership.
+type LeaderTransition struct {
+	// Time is when the new leader established its leadership, by its own
+	// clock.
+	Time time.Time
+
+	// Term is the Raft term the new leader was elected in.
+	Term uint64
+
+	// Leader is the name of the new leader, and PreviousLeader is the name
+	// of the leader of the last recorded transition, if there is one. They
+	// are the same when a leader is elected again after losing leadership.
+	Leader         string
+	PreviousLeader string `json:",omitempty"`
+
+	// Reason is what the new leader found out about the previous one, if
+	// anything: whether it left the cluster, failed, or is still a member.
+	Reason string `json:",omitempty"`
+
+	RaftIndex
+}
+
+// LeaderTransitions is a list of leader transitions.
+type LeaderTransitions []*LeaderTransition
+
+// LeaderTransitionRequest is used to record a leader transition.
+type LeaderTransitionRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Transition is the transition to record.
+	Transition *LeaderTransition
+
+	// MaxEntries is how many transitions to keep, including this one. It's
+	// in the request so every server trims the history the same way.
+	MaxEntries int
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *LeaderTransitionRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedLeaderTransitions is the response for a leader history query. The
+// transitions are newest first.
+type IndexedLeaderTransitions struct {
+	Transitions LeaderTransitions
+	QueryMeta
+}
@@ -0,0 +1,92 @@
+package structs
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// NamespaceDefault is the namespace that is always in the registry. It
+	// can't be deleted.
+	NamespaceDefault = "default"
+)
+
+// validNamespaceName is used to validate namespace names. They are used in
+// URLs and ACL rules, so they are restricted to a DNS-label-like format.
+var validNamespaceName = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,62}[a-zA-Z0-9])?$`)
+
+// Namespace is an entry in the namespace registry. The registry only records
+// names and descriptions for tools built on top of Consul: the catalog, KV
+// store and ACLs don't use namespaces, so registering one doesn't partition
+// anything.
+type Namespace struct {
+	// Name is the unique name of the namespace. It is case-insensitive.
+	Name string
+
+	// Description is a human-friendly description of the namespace. It is
+	// opaque to Consul.
+	Description string
+
+	RaftIndex
+}
+
+// Validate returns an error if the namespace is invalid for inserting or
+// updating.
+func (n *Namespace) Validate() error {
+	if !validNamespaceName.MatchString(n.Name) {
+		return fmt.Errorf("Invalid namespace name %q: must be 1-64 alphanumeric characters or dashes, and can't start or end with a dash", n.Name)
+	}
+	return nil
+}
+
+
+// Namespaces is a list of namespaces.
+type Namespaces []*Namespace
+
+// NamespaceOp is the operation for a request related to namespaces.
+type NamespaceOp string
+
+const (
+	NamespaceOpUpsert NamespaceOp = "upsert"
+	NamespaceOpDelete NamespaceOp = "delete"
+)
+
+// NamespaceRequest is used to create, update, and delete namespaces.
+type NamespaceRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Op is the type of operation being requested.
+	Op NamespaceOp
+
+	// Namespace is the namespace to operate on. Only the name is needed
+	// for deletes.
+	Namespace *Namespace
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *NamespaceRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// NamespaceSpecificRequest is used to request a single namespace by name.
+type NamespaceSpecificRequest struct {
+	Datacenter string
+	Name       string
+	QueryOptions
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *NamespaceSpecificRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedNamespaces is the response for namespace queries.
+type IndexedNamespaces struct {
+	Namespaces Namespaces
+	QueryMeta
+}
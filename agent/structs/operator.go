@@ -2,8 +2,10 @@ package structs
 
 import (
 	"net"
+	"time"
 
 	"github.com/hashicorp/consul/agent/consul/autopilot"
+	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/raft"
 )
 
@@ -44,6 +46,54 @@ type RaftConfigurationResponse struct {
 	Index uint64
 }
 
+// RaftStats are the Raft indexes of a server, which monitoring can compare
+// across the servers to work out how far each is behind the leader. The
+// LastContact, LastTerm and LastIndex fields match autopilot.ServerStats, so
+// Autopilot can decode them from the same RPC.
+type RaftStats struct {
+	// ID, Name and Address identify the server.
+	ID      string
+	Name    string
+	Address string
+
+	// State is the server's Raft state, like "Leader" or "Follower", and
+	// Term its current term.
+	State string
+	Term  uint64
+
+	// LastContact is the time since this server's last contact with the
+	// leader, or "never" or "0" for the leader itself.
+	LastContact string
+
+	// LastIndex and LastTerm are those of the last entry in the server's
+	// Raft log.
+	LastIndex uint64
+	LastTerm  uint64
+
+	// CommitIndex is the latest index the server knows to be committed,
+	// and AppliedIndex the latest index it has applied to its FSM.
+	CommitIndex  uint64
+	AppliedIndex uint64
+
+	// LastSnapshotIndex and LastSnapshotTerm are those of the server's
+	// latest snapshot.
+	LastSnapshotIndex uint64
+	LastSnapshotTerm  uint64
+
+	// FSMPending is the number of committed batches waiting to be applied
+	// to the FSM.
+	FSMPending uint64
+
+	// Error is set when the server's stats couldn't be fetched, in which
+	// case only ID, Name and Address are set.
+	Error string `json:",omitempty"`
+}
+
+// RaftStatsResponse has the Raft stats of all the servers in a datacenter.
+type RaftStatsResponse struct {
+	Servers []*RaftStats
+}
+
 // RaftRemovePeerRequest is used by the Operator endpoint to apply a Raft
 // operation on a specific Raft peer by address in the form of "IP:port".
 type RaftRemovePeerRequest struct {
@@ -65,6 +115,23 @@ func (op *RaftRemovePeerRequest) RequestDatacenter() string {
 	return op.Datacenter
 }
 
+// RaftVerifyResponse is returned when verifying a server's Raft log and
+// latest snapshot.
+type RaftVerifyResponse struct {
+	// Node is the name of the server that did the verification.
+	Node string
+
+	// FirstIndex and LastIndex are the range of the Raft log that was
+	// verified. These are zero if the log is empty.
+	FirstIndex uint64
+	LastIndex  uint64
+
+	// SnapshotID and SnapshotIndex identify the snapshot that was verified.
+	// These are empty if the server has no snapshot yet.
+	SnapshotID    string
+	SnapshotIndex uint64
+}
+
 // AutopilotSetConfigRequest is used by the Operator endpoint to update the
 // current Autopilot configuration of the cluster.
 type AutopilotSetConfigRequest struct {
@@ -86,6 +153,239 @@ func (op *AutopilotSetConfigRequest) RequestDatacenter() string {
 	return op.Datacenter
 }
 
+// CatalogOrphan identifies a service or check registered against a node (or
+// service) that doesn't exist.
+type CatalogOrphan struct {
+	// Node is the name of the node the entry is registered against.
+	Node string
+
+	// ID is the service or check ID.
+	ID string
+}
+
+// CatalogConsistencyReport has the findings of the last catalog consistency
+// check run by the leader.
+type CatalogConsistencyReport struct {
+	// LastRun is when the check last completed. This is zero if the check
+	// hasn't run since the current leader was elected.
+	LastRun time.Time
+
+	// UnregisteredMembers are the alive Serf members that have no node in
+	// the catalog.
+	UnregisteredMembers []string
+
+	// StaleNodes are the catalog nodes with a Serf health check that are no
+	// longer Serf members.
+	StaleNodes []string
+
+	// OrphanedServices are the services registered against nodes that don't
+	// exist.
+	OrphanedServices []CatalogOrphan
+
+	// OrphanedChecks are the checks registered against nodes or services
+	// that don't exist.
+	OrphanedChecks []CatalogOrphan
+
+	// Repaired is the number of orphaned services and checks that were
+	// removed from the catalog.
+	Repaired int
+}
+
+// CatalogExportVersion is the version of the catalog export schema. It's
+// bumped whenever the schema changes in a way older importers can't handle.
+const CatalogExportVersion = 1
+
+// CatalogExport is a point-in-time export of the catalog and KV store of a
+// datacenter, in a documented JSON schema that doesn't depend on the Raft
+// snapshot format, so it can be kept as a human-readable backup and imported
+// into another cluster.
+type CatalogExport struct {
+	// Version is the CatalogExportVersion of the export.
+	Version int
+
+	// Datacenter is the datacenter that was exported.
+	Datacenter string
+
+	// Index is the Raft index the export is consistent as of.
+	Index uint64
+
+	// Nodes are the nodes in the catalog, with their services and checks,
+	// sorted by name.
+	Nodes []*CatalogExportNode
+
+	// KV are the entries in the KV store, sorted by key. The sessions
+	// holding locks aren't exported.
+	KV []*CatalogExportKV
+}
+
+// CatalogExportNode is a node in a catalog export. Its services and checks
+// don't include Raft indexes.
+type CatalogExportNode struct {
+	ID              types.NodeID
+	Node            string
+	Address         string
+	TaggedAddresses map[string]string
+	Meta            map[string]string
+	Services        []*NodeService
+	Checks          HealthChecks
+}
+
+// CatalogExportKV is a KV entry in a catalog export.
+type CatalogExportKV struct {
+	Key   string
+	Flags uint64
+	Value []byte
+}
+
+// KVReplicationStatus is the status of KV replication from another
+// datacenter, as seen by the leader.
+type KVReplicationStatus struct {
+	// Enabled is true if KV replication is configured, and Running is true
+	// while this server is the leader and replicating.
+	Enabled bool
+	Running bool
+
+	// SourceDatacenter is the datacenter keys are replicated from, and
+	// Prefix is the replicated key prefix.
+	SourceDatacenter string
+	Prefix           string
+
+	// ReplicatedIndex is the index in the source datacenter that the local
+	// keys were last brought up to date with.
+	ReplicatedIndex uint64
+
+	// LastSuccess and LastError are when replication last succeeded and
+	// failed, and LastErrorMessage is the reason for the last failure.
+	LastSuccess      time.Time
+	LastError        time.Time
+	LastErrorMessage string
+
+	// Lag is how far behind the source datacenter the last replication
+	// round was: how stale the source server's data was, plus the time it
+	// took to apply the changes locally.
+	Lag time.Duration
+}
+
+// StateTableMemory is the estimated memory used by the entries of one state
+// store table.
+type StateTableMemory struct {
+	// Table is the name of the table, and Entries is how many entries it
+	// has.
+	Table   string
+	Entries int
+
+	// Bytes is the estimated size of the entries, based on their encoded
+	// size. It doesn't include the overhead of the table's indexes.
+	Bytes int64
+}
+
+// StateMemoryReport has the state store memory usage found by the last
+// accounting run on the leader.
+type StateMemoryReport struct {
+	// LastRun is when the accounting last completed. This is zero if it
+	// hasn't run since the current leader was elected.
+	LastRun time.Time
+
+	// Tables has the usage of each of the accounted tables, and TotalBytes
+	// is the sum of them.
+	Tables     []StateTableMemory
+	TotalBytes int64
+
+	// Budget is the configured memory budget in bytes, or zero if writes
+	// aren't limited.
+	Budget int64
+
+	// Rejected is the number of writes rejected because they would have
+	// taken the state store over the budget since the current leader was
+	// elected.
+	Rejected uint64
+}
+
+// ServerFeatureBlocker is a server that doesn't support a feature.
+type ServerFeatureBlocker struct {
+	Node    string
+	Address string
+	Version string
+}
+
+// ServerFeature reports whether every server in a datacenter supports one of
+// the optional protocol features.
+type ServerFeature struct {
+	// Name is the name the feature is advertised with.
+	Name string
+
+	// GatesRaftWrites is true if the feature is needed to apply some of the
+	// FSM message types, which the leader won't write until it's enabled.
+	GatesRaftWrites bool
+
+	// Enabled is true if every server supports the feature, and BlockedBy
+	// has the servers that don't otherwise.
+	Enabled   bool
+	BlockedBy []ServerFeatureBlocker
+}
+
+// ServerFeaturesResponse has the optional protocol features of a
+// datacenter's servers.
+type ServerFeaturesResponse struct {
+	Features []ServerFeature
+}
+
+// NodeClockSkew is how far a node's clock is from the leader's.
+type NodeClockSkew struct {
+	Node string
+
+	// Skew is how far the node's clock is ahead of the leader's, or behind
+	// it if it's negative. It's measured from the time the node sent its
+	// last coordinate update, so it's only accurate to within the time the
+	// update took to reach the leader.
+	Skew time.Duration
+
+	// Measured is when the skew was last measured, by the leader's clock.
+	Measured time.Time
+
+	// Exceeded is true if the skew is over the threshold.
+	Exceeded bool
+}
+
+// ClockSkewReport has the clock skew of the nodes that sent a coordinate
+// update since the current leader was elected.
+type ClockSkewReport struct {
+	// Leader is the name of the leader the skew is measured against.
+	Leader string
+
+	// Threshold is the skew over which the leader warns about a node, or
+	// zero if it doesn't.
+	Threshold time.Duration
+
+	// Nodes has the skew of each node, largest first.
+	Nodes []NodeClockSkew
+}
+
+// RuntimeSettings are the settings of an agent that can be changed through
+// the operator API while it's running. Changes aren't persisted, so they're
+// lost when the agent restarts, and a config reload puts the configured rate
+// limits back.
+type RuntimeSettings struct {
+	// LogLevel is the minimum level of the messages that are logged.
+	LogLevel string
+
+	// SlowQueryThreshold is how long a state store query can take before
+	// it's logged as slow. This only applies to servers.
+	SlowQueryThreshold time.Duration
+
+	// MaxQueryTime is the longest a blocking query can wait for a change.
+	// This only applies to servers.
+	MaxQueryTime time.Duration
+
+	// RPCServerReadRate, RPCServerWriteRate, RPCServerTokenRate and
+	// RPCServerMaxBurst are the limits on the rate of RPC requests. A rate
+	// of zero means no limit. These only apply to servers.
+	RPCServerReadRate  float64
+	RPCServerWriteRate float64
+	RPCServerTokenRate float64
+	RPCServerMaxBurst  int
+}
+
 // (Enterprise-only) NetworkSegment is the configuration for a network segment, which is an
 // isolated serf group on the LAN.
 type NetworkSegment struct {
@@ -2,6 +2,7 @@ package structs
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/consul/agent/cache"
 	"github.com/hashicorp/consul/types"
@@ -71,8 +72,18 @@ type ServiceQuery struct {
 	// to the _proxy_ and not the service being proxied. In practice, proxies
 	// should be directly next to their services so this isn't an issue.
 	Connect bool
+
+	// PinTTL, if set, makes the query return the same healthy subset of
+	// results to a source node for this long, so clients that re-resolve
+	// often don't keep switching instances. Pins are per server, and
+	// instances that become unhealthy are replaced. It can't be longer
+	// than MaxQueryPinTTL.
+	PinTTL string
 }
 
+// MaxQueryPinTTL is the longest results can be pinned to a source node for.
+const MaxQueryPinTTL = 24 * time.Hour
+
 const (
 	// QueryTemplateTypeNamePrefixMatch uses the Name field of the query as
 	// a prefix to select the template.
@@ -223,6 +234,10 @@ type PreparedQueryExecuteRequest struct {
 	// the execute request. Used to distance-sort relative to the local node.
 	Agent QuerySource
 
+	// PinTTL overrides the query's PinTTL for this request. Results are
+	// pinned to the Source node, or the Agent's if no source is given.
+	PinTTL time.Duration
+
 	// QueryOptions (unfortunately named here) controls the consistency
 	// settings for the query lookup itself, as well as the service lookups.
 	QueryOptions
@@ -255,6 +270,7 @@ func (q *PreparedQueryExecuteRequest) CacheInfo() cache.RequestInfo {
 		q.QueryIDOrName,
 		q.Limit,
 		q.Connect,
+		q.PinTTL,
 	}, nil)
 	if err == nil {
 		// If there is an error, we don't set the key. A blank key forces
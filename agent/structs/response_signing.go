@@ -0,0 +1,157 @@
+package structs
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// ResponseSigningAlgorithm is the signature algorithm of response signing
+// keys.
+const ResponseSigningAlgorithm = "ed25519"
+
+// ResponseSigningKey is the key the servers sign catalog and health replies
+// with when asked to, so caches and proxies between Consul and its consumers
+// can't change the data without it being noticed. There's one key per
+// datacenter, created by the leader when response signing is enabled.
+type ResponseSigningKey struct {
+	// ID identifies the key, so consumers know which public key to verify
+	// a signature with.
+	ID string
+
+	// PublicKey is the key signatures are verified with.
+	PublicKey []byte
+
+	// PrivateKey is the key replies are signed with. It never leaves the
+	// servers.
+	PrivateKey []byte
+
+	RaftIndex
+}
+
+// ResponseSigningKeyRequest is used to set the response signing key.
+type ResponseSigningKeyRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Key is the key to set.
+	Key *ResponseSigningKey
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ResponseSigningKeyRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedResponseSigningKey is the response for a response signing key
+// query. It only has the public part of the key.
+type IndexedResponseSigningKey struct {
+	ID        string
+	Algorithm string
+	PublicKey []byte
+	QueryMeta
+}
+
+// ResponseSignature is a signature over the canonical payload of a reply.
+// The payload is the JSON encoding of the results that the agent returns
+// as-is, so the bytes a consumer receives are the bytes that were signed.
+type ResponseSignature struct {
+	// KeyID is the ID of the key the payload was signed with.
+	KeyID string
+
+	// Payload is the JSON encoding of the results.
+	Payload []byte
+
+	// Signature is the signature over the message returned by
+	// ResponseSigningMessage for the request, the payload and the reply's
+	// index.
+	Signature []byte
+}
+
+// responseSigningPrefix starts every signed message, so a signature over
+// something else can never be passed off as a reply signature, and the
+// format can change later.
+const responseSigningPrefix = "consul-signed-response-v1"
+
+// ResponseSigningRequest is what's signed about the request a reply answers,
+// so a reply can't be passed off as the answer to a different query, like
+// another service, tag or datacenter. Consumers rebuild it from the request
+// they made to verify the signature. Its JSON encoding is the canonical form,
+// with the tags sorted and no duplicates.
+type ResponseSigningRequest struct {
+	// Datacenter is the datacenter of the servers that signed the reply.
+	Datacenter string
+
+	// Endpoint is the RPC endpoint the reply came from, which is either
+	// "Catalog.ServiceNodes" or "Health.ServiceNodes".
+	Endpoint string
+
+	// KeyID is the ID of the key the reply was signed with.
+	KeyID string
+
+	ServiceName     string
+	ServiceTags     []string
+	Connect         bool
+	OnlyPassing     bool
+	SummarizeChecks bool
+	NodeMetaFilters map[string]string
+}
+
+// NewResponseSigningRequest returns what's signed about the given service
+// query.
+func NewResponseSigningRequest(dc, endpoint, keyID string, args *ServiceSpecificRequest) *ResponseSigningRequest {
+	seen := make(map[string]struct{})
+	var tags []string
+	for _, tag := range append([]string{args.ServiceTag}, args.ServiceTags...) {
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	filters := args.NodeMetaFilters
+	if len(filters) == 0 {
+		filters = nil
+	}
+
+	return &ResponseSigningRequest{
+		Datacenter:      dc,
+		Endpoint:        endpoint,
+		KeyID:           keyID,
+		ServiceName:     args.ServiceName,
+		ServiceTags:     tags,
+		Connect:         args.Connect,
+		OnlyPassing:     args.OnlyPassing,
+		SummarizeChecks: args.SummarizeChecks,
+		NodeMetaFilters: filters,
+	}
+}
+
+// ResponseSigningMessage returns the message that's signed for a reply to
+// the given request with the given index and payload. It's made of these
+// lines, separated by newlines:
+//
+//	consul-signed-response-v1
+//	the JSON encoding of the request, which has sorted map keys
+//	the index in decimal
+//	the payload
+//
+// Including the index stops an old reply from being replayed as a newer
+// one, and including the request stops it being replayed for another query.
+func ResponseSigningMessage(req *ResponseSigningRequest, index uint64, payload []byte) ([]byte, error) {
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	msg := append([]byte(responseSigningPrefix), '\n')
+	msg = append(msg, encoded...)
+	msg = append(msg, '\n')
+	msg = strconv.AppendUint(msg, index, 10)
+	msg = append(msg, '\n')
+	return append(msg, payload...), nil
+}
@@ -0,0 +1,83 @@
+package structs
+
+import (
+	"fmt"
+)
+
+// ServiceFailover is a failover policy stored for a service. When the local
+// datacenter has no healthy instances of the service, health queries for it
+// are answered from the first of the policy's datacenters that has some, so
+// clients don't each need their own failover logic.
+type ServiceFailover struct {
+	// Service is the name of the service the policy applies to.
+	Service string
+
+	// Failover sets the datacenters to try, in the same way as the
+	// failover options of a prepared query: the nearest N datacenters by
+	// network coordinates, followed by the listed datacenters in order.
+	Failover QueryDatacenterOptions
+
+	RaftIndex
+}
+
+// Validate returns an error if the policy is invalid for inserting or
+// updating.
+func (f *ServiceFailover) Validate() error {
+	if f.Service == "" {
+		return fmt.Errorf("Must provide a service name")
+	}
+	if f.Failover.NearestN < 0 {
+		return fmt.Errorf("Bad NearestN '%d', must be >= 0", f.Failover.NearestN)
+	}
+	if f.Failover.NearestN == 0 && len(f.Failover.Datacenters) == 0 {
+		return fmt.Errorf("Must provide NearestN or Datacenters to fail over to")
+	}
+	for _, dc := range f.Failover.Datacenters {
+		if dc == "" {
+			return fmt.Errorf("Datacenters can't contain an empty name")
+		}
+	}
+	return nil
+}
+
+// ServiceFailovers is a list of service failover policies.
+type ServiceFailovers []*ServiceFailover
+
+// ServiceFailoverOp is the operation for a request related to service
+// failover policies.
+type ServiceFailoverOp string
+
+const (
+	ServiceFailoverOpUpsert ServiceFailoverOp = "upsert"
+	ServiceFailoverOpDelete ServiceFailoverOp = "delete"
+)
+
+// ServiceFailoverRequest is used to create, update, and delete service
+// failover policies.
+type ServiceFailoverRequest struct {
+	// Datacenter is the target for this request.
+	Datacenter string
+
+	// Op is the type of operation being requested.
+	Op ServiceFailoverOp
+
+	// Failover is the policy to operate on. Only the service name is
+	// needed for deletes.
+	Failover *ServiceFailover
+
+	// WriteRequest is a common struct containing ACL tokens and other
+	// write-related common elements for requests.
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given request.
+func (r *ServiceFailoverRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedServiceFailovers is the response for service failover policy
+// queries.
+type IndexedServiceFailovers struct {
+	Failovers ServiceFailovers
+	QueryMeta
+}
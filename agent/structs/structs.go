@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math/rand"
+	"net"
 	"reflect"
 	"regexp"
 	"sort"
@@ -32,28 +33,38 @@ type RaftIndex struct {
 // These are serialized between Consul servers and stored in Consul snapshots,
 // so entries must only ever be added.
 const (
-	RegisterRequestType        MessageType = 0
-	DeregisterRequestType                  = 1
-	KVSRequestType                         = 2
-	SessionRequestType                     = 3
-	ACLRequestType                         = 4 // DEPRECATED (ACL-Legacy-Compat)
-	TombstoneRequestType                   = 5
-	CoordinateBatchUpdateType              = 6
-	PreparedQueryRequestType               = 7
-	TxnRequestType                         = 8
-	AutopilotRequestType                   = 9
-	AreaRequestType                        = 10
-	ACLBootstrapRequestType                = 11
-	IntentionRequestType                   = 12
-	ConnectCARequestType                   = 13
-	ConnectCAProviderStateType             = 14
-	ConnectCAConfigType                    = 15 // FSM snapshots only.
-	IndexRequestType                       = 16 // FSM snapshots only.
-	ACLTokenSetRequestType                 = 17
-	ACLTokenDeleteRequestType              = 18
-	ACLPolicySetRequestType                = 19
-	ACLPolicyDeleteRequestType             = 20
-	ConnectCALeafRequestType               = 21
+	RegisterRequestType         MessageType = 0
+	DeregisterRequestType                   = 1
+	KVSRequestType                          = 2
+	SessionRequestType                      = 3
+	ACLRequestType                          = 4 // DEPRECATED (ACL-Legacy-Compat)
+	TombstoneRequestType                    = 5
+	CoordinateBatchUpdateType               = 6
+	PreparedQueryRequestType                = 7
+	TxnRequestType                          = 8
+	AutopilotRequestType                    = 9
+	AreaRequestType                         = 10
+	ACLBootstrapRequestType                 = 11
+	IntentionRequestType                    = 12
+	ConnectCARequestType                    = 13
+	ConnectCAProviderStateType              = 14
+	ConnectCAConfigType                     = 15 // FSM snapshots only.
+	IndexRequestType                        = 16 // FSM snapshots only.
+	ACLTokenSetRequestType                  = 17
+	ACLTokenDeleteRequestType               = 18
+	ACLPolicySetRequestType                 = 19
+	ACLPolicyDeleteRequestType              = 20
+	ConnectCALeafRequestType                = 21
+	NamespaceRequestType                    = 22
+	ServiceFailoverRequestType              = 23
+	ConfigEntryRequestType                  = 24
+	SnapshotChecksumType                    = 25 // FSM snapshots only.
+	ChangeFeedEntryType                     = 26 // FSM snapshots only.
+	ResponseSigningKeyType                  = 27
+	LeaderTransitionType                    = 28
+	ACLTokenUsageRequestType                = 29
+	ChangeFeedConfigRequestType             = 30
+	BootstrapDataRequestType                = 31
 )
 
 const (
@@ -67,6 +78,11 @@ const (
 	// NodeMaint is the special key set by a node in maintenance mode.
 	NodeMaint = "_node_maintenance"
 
+	// NodeDrain is the special key set by a node in drain mode. Unlike
+	// maintenance mode the check is passing, so the node's services stay
+	// discoverable, but they're ranked last and get a DNS weight of zero.
+	NodeDrain = "_node_drain"
+
 	// ServiceMaintPrefix is the prefix for a service in maintenance mode.
 	ServiceMaintPrefix = "_service_maintenance:"
 
@@ -103,6 +119,10 @@ type RPCInfo interface {
 	IsRead() bool
 	AllowStaleRead() bool
 	TokenSecret() string
+	RequestTraceID() string
+	StartDeadline()
+	Deadline() time.Time
+	SetRequestTimeout(time.Duration)
 }
 
 // QueryOptions is used to specify various flags for read queries
@@ -162,6 +182,29 @@ type QueryOptions struct {
 	// ignored if the endpoint supports background refresh caching. See
 	// https://www.consul.io/api/index.html#agent-caching for more details.
 	StaleIfError time.Duration
+
+	// Timeout bounds how long the request may take end to end, including
+	// forwarding it, waiting for a leader and blocking. If it runs out
+	// while blocking the current results are returned, otherwise the
+	// request fails with ErrRequestTimeout. Zero leaves the request to the
+	// server's usual limits.
+	Timeout time.Duration
+
+	// MaxResults limits how many results catalog and health queries return.
+	// Results are dropped after they have been sorted, and ResultsTruncated
+	// is set in the reply if any were. Servers may enforce a lower limit.
+	// Zero means no limit.
+	MaxResults int
+
+	// TraceID identifies the HTTP request this query was made for. It's
+	// passed along when the query is forwarded, and included in the logs of
+	// the agents that handle it.
+	TraceID string
+
+	// deadline is when the server handling the request gives up on it. It
+	// is set from Timeout by StartDeadline, and isn't sent along with the
+	// request.
+	deadline time.Time
 }
 
 // IsRead is always true for QueryOption.
@@ -188,10 +231,75 @@ func (q QueryOptions) TokenSecret() string {
 	return q.Token
 }
 
+func (q QueryOptions) RequestTraceID() string {
+	return q.TraceID
+}
+
+// RequestTimeout returns how long the request may take end to end, or zero
+// if it isn't bounded.
+func (q QueryOptions) RequestTimeout() time.Duration {
+	return q.Timeout
+}
+
+// StartDeadline sets the deadline for the request from its timeout. Later
+// calls keep the first deadline, so it isn't pushed back as the request
+// makes its way through the server.
+func (q *QueryOptions) StartDeadline() {
+	if q.Timeout > 0 && q.deadline.IsZero() {
+		q.deadline = time.Now().Add(q.Timeout)
+	}
+}
+
+// Deadline returns when the request should be given up on, or zero if it
+// has no timeout.
+func (q *QueryOptions) Deadline() time.Time {
+	return q.deadline
+}
+
+// SetRequestTimeout sets the timeout sent along with the request, which is
+// used to pass on the time left when forwarding it.
+func (q *QueryOptions) SetRequestTimeout(timeout time.Duration) {
+	q.Timeout = timeout
+}
+
+// BlockingTimeout returns the longest a server may hold the query while
+// waiting for changes, given the server's limits, or zero if the query
+// doesn't block. This includes the up to 1/16th jitter the server adds.
+func (q QueryOptions) BlockingTimeout(maxQueryTime, defaultQueryTime time.Duration) time.Duration {
+	if q.MinQueryIndex == 0 {
+		return 0
+	}
+
+	queryTime := q.MaxQueryTime
+	if queryTime > maxQueryTime {
+		queryTime = maxQueryTime
+	} else if queryTime <= 0 {
+		queryTime = defaultQueryTime
+	}
+	return queryTime + queryTime/16
+}
+
 type WriteRequest struct {
 	// Token is the ACL token ID. If not provided, the 'anonymous'
 	// token is assumed for backwards compatibility.
 	Token string
+
+	// Timeout bounds how long the request may take end to end, including
+	// forwarding it, waiting for a leader and waiting for the change to be
+	// applied. The request fails with ErrRequestTimeout when it runs out,
+	// although a change that was already submitted may still be applied.
+	// Zero leaves the request to the server's usual limits.
+	Timeout time.Duration
+
+	// TraceID identifies the HTTP request this write was made for. It's
+	// passed along when the write is forwarded, and included in the logs of
+	// the agents that handle it.
+	TraceID string
+
+	// deadline is when the server handling the request gives up on it. It
+	// is set from Timeout by StartDeadline, and isn't sent along with the
+	// request.
+	deadline time.Time
 }
 
 // WriteRequest only applies to writes, always false
@@ -207,6 +315,41 @@ func (w WriteRequest) TokenSecret() string {
 	return w.Token
 }
 
+func (w WriteRequest) RequestTraceID() string {
+	return w.TraceID
+}
+
+// SetTraceID is used to leave the trace ID out of the Raft log, where it
+// would be kept long after the request is done.
+func (w *WriteRequest) SetTraceID(traceID string) {
+	w.TraceID = traceID
+}
+
+// RequestTimeout returns how long the request may take end to end, or zero
+// if it isn't bounded.
+func (w WriteRequest) RequestTimeout() time.Duration {
+	return w.Timeout
+}
+
+// StartDeadline sets the deadline for the request from its timeout. Later
+// calls keep the first deadline.
+func (w *WriteRequest) StartDeadline() {
+	if w.Timeout > 0 && w.deadline.IsZero() {
+		w.deadline = time.Now().Add(w.Timeout)
+	}
+}
+
+// Deadline returns when the request should be given up on, or zero if it
+// has no timeout.
+func (w *WriteRequest) Deadline() time.Time {
+	return w.deadline
+}
+
+// SetRequestTimeout sets the timeout sent along with the request.
+func (w *WriteRequest) SetRequestTimeout(timeout time.Duration) {
+	w.Timeout = timeout
+}
+
 // QueryMeta allows a query response to include potentially
 // useful metadata about a query
 type QueryMeta struct {
@@ -225,6 +368,21 @@ type QueryMeta struct {
 	// Having `discovery_max_stale` on the agent can affect whether
 	// the request was served by a leader.
 	ConsistencyLevel string
+
+	// ResultsTruncated is true if some results were left out of the reply
+	// because of the request's MaxResults or the servers' limit.
+	ResultsTruncated bool
+
+	// ServedBy is the name of the server that answered the query.
+	ServedBy string
+
+	// Stale is true if the query was answered by a follower, so the results
+	// may be behind the leader's. LastContact and IndexGap show how far.
+	Stale bool
+
+	// IndexGap is how many Raft log entries the server that answered had
+	// stored but not yet applied to its state when it answered.
+	IndexGap uint64
 }
 
 // RegisterRequest is used for the Catalog.Register endpoint
@@ -248,6 +406,15 @@ type RegisterRequest struct {
 	// node portion of this update will not apply.
 	SkipNodeUpdate bool
 
+	// CAS turns the registration into a check-and-set operation, like the
+	// KV "cas" operation. The registration is only written if ModifyIndex
+	// matches the highest ModifyIndex in the catalog of the objects it
+	// writes: the service, or the node if there's no service, and any
+	// checks. A ModifyIndex of 0 means it is only written if none of them
+	// exist yet. Servers refuse it until they all support it.
+	CAS         bool
+	ModifyIndex uint64
+
 	WriteRequest
 }
 
@@ -291,6 +458,14 @@ type DeregisterRequest struct {
 	Node       string
 	ServiceID  string
 	CheckID    types.CheckID
+
+	// CAS turns the deregistration into a check-and-set operation. The
+	// service, check or node being removed is only deleted if its
+	// ModifyIndex in the catalog matches ModifyIndex. Servers refuse it
+	// until they all support it.
+	CAS         bool
+	ModifyIndex uint64
+
 	WriteRequest
 }
 
@@ -298,6 +473,23 @@ func (r *DeregisterRequest) RequestDatacenter() string {
 	return r.Datacenter
 }
 
+// CheckUpdateRequest is used to update the status of a health check that's
+// already in the catalog, without re-registering it. This lets systems that
+// don't run an agent report health for the nodes they register.
+type CheckUpdateRequest struct {
+	Datacenter string
+	Node       string
+	CheckID    types.CheckID
+	Status     string
+	Output     string
+
+	WriteRequest
+}
+
+func (r *CheckUpdateRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
 // QuerySource is used to pass along information about the source node
 // in queries so that we can adjust the response based on its network
 // coordinates.
@@ -313,6 +505,11 @@ type DCSpecificRequest struct {
 	Datacenter      string
 	NodeMetaFilters map[string]string
 	Source          QuerySource
+
+	// SessionMetaFilters restricts the sessions listed to the ones with the
+	// given metadata.
+	SessionMetaFilters map[string]string
+
 	QueryOptions
 }
 
@@ -364,6 +561,29 @@ type ServiceSpecificRequest struct {
 	// Connect if true will only search for Connect-compatible services.
 	Connect bool
 
+	// SkipFailover is set when querying another datacenter because of the
+	// service's failover policy, so the query doesn't fail over again.
+	SkipFailover bool
+
+	// MultiDC is the list of datacenters to query for the service, in
+	// which case the nodes of all of them are returned, tagged with their
+	// datacenter and sorted by the estimated RTT to it.
+	MultiDC []string
+
+	// OnlyPassing filters the nodes to those with all checks passing
+	// before they are sorted and limited, so a query with Source and
+	// MaxResults returns the nearest healthy instances.
+	OnlyPassing bool
+
+	// SummarizeChecks returns the aggregated status of each node's checks
+	// instead of the checks themselves, which makes the reply much smaller
+	// for services with many checks.
+	SummarizeChecks bool
+
+	// Sign asks the servers to sign the reply with the cluster's response
+	// signing key, so consumers can verify where the data came from.
+	Sign bool
+
 	QueryOptions
 }
 
@@ -404,6 +624,11 @@ func (r *ServiceSpecificRequest) CacheInfo() cache.RequestInfo {
 		r.ServiceAddress,
 		r.TagFilter,
 		r.Connect,
+		r.MultiDC,
+		r.MaxResults,
+		r.OnlyPassing,
+		r.SummarizeChecks,
+		r.Sign,
 	}, nil)
 	if err == nil {
 		// If there is an error, we don't set the key. A blank key forces
@@ -452,10 +677,36 @@ type Node struct {
 	TaggedAddresses map[string]string
 	Meta            map[string]string
 
+	// AddressFamily is the family of Address. It's only filled in by the
+	// HTTP API.
+	AddressFamily string `json:",omitempty"`
+
 	RaftIndex
 }
 type Nodes []*Node
 
+const (
+	// AddressFamilyIPv4 and AddressFamilyIPv6 are the address families
+	// reported for IP addresses. Other addresses, like hostnames, don't
+	// have one.
+	AddressFamilyIPv4 = "ipv4"
+	AddressFamilyIPv6 = "ipv6"
+)
+
+// AddressFamily returns the family of the given address, or an empty string
+// if it isn't an IP address.
+func AddressFamily(addr string) string {
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return AddressFamilyIPv4
+	default:
+		return AddressFamilyIPv6
+	}
+}
+
 // IsSame return whether nodes are similar without taking into account
 // RaftIndex fields.
 func (n *Node) IsSame(other *Node) bool {
@@ -559,6 +810,11 @@ type ServiceNode struct {
 	ServiceProxy            ConnectProxyConfig
 	ServiceConnect          ServiceConnect
 
+	// AddressFamily and ServiceAddressFamily are the families of Address
+	// and ServiceAddress. They're only filled in by the HTTP API.
+	AddressFamily        string `json:",omitempty"`
+	ServiceAddressFamily string `json:",omitempty"`
+
 	RaftIndex
 }
 
@@ -707,6 +963,10 @@ type NodeService struct {
 	// somewhere this is used in API output.
 	LocallyRegisteredAsSidecar bool `json:"-"`
 
+	// AddressFamily is the family of Address. It's only filled in by the
+	// HTTP API, and ignored when a service is registered.
+	AddressFamily string `json:",omitempty"`
+
 	RaftIndex
 }
 
@@ -946,15 +1206,90 @@ func (c *HealthCheck) Clone() *HealthCheck {
 // HealthChecks is a collection of HealthCheck structs.
 type HealthChecks []*HealthCheck
 
+// AggregatedStatus returns the status that best represents the checks, using
+// the same rules as the API client: maintenance > critical > warning > passing.
+// It returns an empty string if any of the checks has an unknown status.
+func (c HealthChecks) AggregatedStatus() string {
+	var warning, critical, maintenance bool
+	for _, check := range c {
+		id := string(check.CheckID)
+		if id == NodeMaint || strings.HasPrefix(id, ServiceMaintPrefix) {
+			maintenance = true
+			continue
+		}
+
+		switch check.Status {
+		case api.HealthPassing:
+		case api.HealthWarning:
+			warning = true
+		case api.HealthCritical:
+			critical = true
+		default:
+			return ""
+		}
+	}
+
+	switch {
+	case maintenance:
+		return api.HealthMaint
+	case critical:
+		return api.HealthCritical
+	case warning:
+		return api.HealthWarning
+	default:
+		return api.HealthPassing
+	}
+}
+
 // CheckServiceNode is used to provide the node, its service
 // definition, as well as a HealthCheck that is associated.
 type CheckServiceNode struct {
 	Node    *Node
 	Service *NodeService
 	Checks  HealthChecks
+
+	// Locality is how close the node is to the source node when results
+	// are sorted by distance, as one of the Locality* constants. It's empty
+	// when the distance isn't known.
+	Locality string `json:",omitempty"`
+
+	// Status is the aggregated status of the checks, set instead of Checks
+	// when the query asked for a summary.
+	Status string `json:",omitempty"`
 }
 type CheckServiceNodes []CheckServiceNode
 
+// SummarizeChecks replaces the checks of each node with their aggregated
+// status. Nodes that already have a status are left alone.
+func (nodes CheckServiceNodes) SummarizeChecks() {
+	for i := range nodes {
+		if nodes[i].Status == "" {
+			nodes[i].Status = nodes[i].Checks.AggregatedStatus()
+		}
+		nodes[i].Checks = nil
+	}
+}
+
+const (
+	// LocalityHost, LocalityZone and LocalityRegion label nodes whose
+	// estimated round trip time from the source node is within the host,
+	// zone or region threshold. LocalityRemote labels the ones further away.
+	LocalityHost   = "host"
+	LocalityZone   = "zone"
+	LocalityRegion = "region"
+	LocalityRemote = "remote"
+)
+
+// Drained returns true if the node is in drain mode.
+func (csn *CheckServiceNode) Drained() bool {
+	for _, check := range csn.Checks {
+		if check.CheckID == NodeDrain {
+			return true
+		}
+	}
+	return false
+}
+
 // Shuffle does an in-place random shuffle using the Fisher-Yates algorithm.
 func (nodes CheckServiceNodes) Shuffle() {
 	for i := len(nodes) - 1; i > 0; i-- {
@@ -963,6 +1298,14 @@ func (nodes CheckServiceNodes) Shuffle() {
 	}
 }
 
+// SortDrainedLast does an in-place stable sort that moves the nodes in drain
+// mode to the end, keeping the order of the rest.
+func (nodes CheckServiceNodes) SortDrainedLast() {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return !nodes[i].Drained() && nodes[j].Drained()
+	})
+}
+
 // Filter removes nodes that are failing health checks (and any non-passing
 // check if that option is selected). Note that this returns the filtered
 // results AND modifies the receiver for performance.
@@ -1031,6 +1374,11 @@ type IndexedServices struct {
 
 type IndexedServiceNodes struct {
 	ServiceNodes ServiceNodes
+
+	// Signature is the servers' signature over the nodes, if the request
+	// asked for one.
+	Signature *ResponseSignature `json:",omitempty"`
+
 	QueryMeta
 }
 
@@ -1048,6 +1396,15 @@ type IndexedHealthChecks struct {
 
 type IndexedCheckServiceNodes struct {
 	Nodes CheckServiceNodes
+
+	// FailoverDatacenter is set to the datacenter the nodes came from when
+	// the service's failover policy was used.
+	FailoverDatacenter string `json:",omitempty"`
+
+	// Signature is the servers' signature over the nodes, if the request
+	// asked for one.
+	Signature *ResponseSignature `json:",omitempty"`
+
 	QueryMeta
 }
 
@@ -1090,6 +1447,11 @@ type KVSRequest struct {
 	Datacenter string
 	Op         api.KVOp // Which operation are we performing
 	DirEnt     DirEntry // Which directory entry
+
+	// Queue makes a lock request wait in line for the key behind the
+	// sessions that tried to acquire it before, instead of racing them.
+	Queue bool
+
 	WriteRequest
 }
 
@@ -1101,6 +1463,11 @@ func (r *KVSRequest) RequestDatacenter() string {
 type KeyRequest struct {
 	Datacenter string
 	Key        string
+
+	// Diff makes KVS.List return only the entries that changed since
+	// MinQueryIndex instead of the full list.
+	Diff bool
+
 	QueryOptions
 }
 
@@ -1122,6 +1489,18 @@ func (r *KeyListRequest) RequestDatacenter() string {
 
 type IndexedDirEntries struct {
 	Entries DirEntries
+
+	// Removed and Resync are only set for diff requests. Removed holds the
+	// keys deleted since the requested index. If Resync is true, the changes
+	// couldn't be computed and Entries holds the full list instead.
+	Removed []string
+	Resync  bool
+
+	// LockDelay is only set for single key requests. It's how much longer
+	// the key can't be acquired for because of the lock-delay of a session
+	// that was invalidated while holding it, as seen by the server.
+	LockDelay time.Duration
+
 	QueryMeta
 }
 
@@ -1130,6 +1509,13 @@ type IndexedKeyList struct {
 	QueryMeta
 }
 
+// KVSLockQueue is the list of sessions waiting in line to acquire a key, in
+// the order they'll get it.
+type KVSLockQueue struct {
+	Sessions []string
+	QueryMeta
+}
+
 type SessionBehavior string
 
 const (
@@ -1153,6 +1539,10 @@ type Session struct {
 	Behavior  SessionBehavior // What to do when session is invalidated
 	TTL       string
 
+	// Meta is arbitrary metadata about the session, such as its owner or
+	// purpose, which sessions can be listed by.
+	Meta map[string]string
+
 	RaftIndex
 }
 type Sessions []*Session
@@ -1231,6 +1621,12 @@ type CoordinateUpdateRequest struct {
 	Node       string
 	Segment    string
 	Coord      *coordinate.Coordinate
+
+	// SentAt is when the node sent the update, by its own clock, which the
+	// leader uses to measure the node's clock skew. It's zero from older
+	// agents.
+	SentAt time.Time
+
 	WriteRequest
 }
 
@@ -1239,6 +1635,19 @@ func (c *CoordinateUpdateRequest) RequestDatacenter() string {
 	return c.Datacenter
 }
 
+// CoordinateBatchUpdateRequest is used to update the LAN coordinates of
+// several nodes at once, on behalf of nodes that don't run Serf.
+type CoordinateBatchUpdateRequest struct {
+	Datacenter  string
+	Coordinates Coordinates
+	WriteRequest
+}
+
+// RequestDatacenter returns the datacenter for a given batch update request.
+func (c *CoordinateBatchUpdateRequest) RequestDatacenter() string {
+	return c.Datacenter
+}
+
 // EventFireRequest is used to ask a server to fire
 // a Serf event. It is a bit odd, since it doesn't depend on
 // the catalog or leader. Any node can respond, so it's not quite
@@ -1296,6 +1705,22 @@ func Encode(t MessageType, msg interface{}) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
+// EncodedSize returns how many bytes the MsgPack encoding of an object takes,
+// without holding on to the encoded bytes.
+func EncodedSize(msg interface{}) (int, error) {
+	var w countingWriter
+	err := codec.NewEncoder(&w, msgpackHandle).Encode(msg)
+	return int(w), err
+}
+
+// countingWriter is an io.Writer that only counts the bytes written to it.
+type countingWriter int
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
 // CompoundResponse is an interface for gathering multiple responses. It is
 // used in cross-datacenter RPC calls where more than 1 datacenter is
 // expected to reply.
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/agent/cache"
 	"github.com/hashicorp/consul/api"
@@ -166,6 +167,23 @@ func testServiceNode(t *testing.T) *ServiceNode {
 	}
 }
 
+func TestStructs_AddressFamily(t *testing.T) {
+	cases := map[string]string{
+		"127.0.0.1":        AddressFamilyIPv4,
+		"::ffff:127.0.0.1": AddressFamilyIPv4,
+		"::1":              AddressFamilyIPv6,
+		"2001:db8::1":      AddressFamilyIPv6,
+		"":                 "",
+		"example.com":      "",
+		"[::1]":            "",
+	}
+	for addr, want := range cases {
+		if got := AddressFamily(addr); got != want {
+			t.Fatalf("%q: got %q, want %q", addr, got, want)
+		}
+	}
+}
+
 func TestNode_IsSame(t *testing.T) {
 	id := types.NodeID("e62f3b31-9284-4e26-ab14-2a59dea85b55")
 	node := "mynode1"
@@ -695,6 +713,55 @@ func TestStructs_CheckServiceNodes_Shuffle(t *testing.T) {
 	}
 }
 
+func TestStructs_CheckServiceNodes_SortDrainedLast(t *testing.T) {
+	drain := &HealthCheck{CheckID: NodeDrain, Status: api.HealthPassing}
+	nodes := CheckServiceNodes{
+		{Node: &Node{Node: "node1"}, Checks: HealthChecks{drain}},
+		{Node: &Node{Node: "node2"}},
+		{Node: &Node{Node: "node3"}, Checks: HealthChecks{drain}},
+		{Node: &Node{Node: "node4"}},
+	}
+	nodes.SortDrainedLast()
+
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Node.Node)
+	}
+	if got, want := strings.Join(names, "|"), "node2|node4|node1|node3"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestStructs_CheckServiceNodes_SummarizeChecks(t *testing.T) {
+	passing := &HealthCheck{CheckID: "a", Status: api.HealthPassing}
+	warning := &HealthCheck{CheckID: "b", Status: api.HealthWarning}
+	critical := &HealthCheck{CheckID: "c", Status: api.HealthCritical}
+	maint := &HealthCheck{CheckID: NodeMaint, Status: api.HealthCritical}
+	nodes := CheckServiceNodes{
+		{Checks: HealthChecks{passing}},
+		{Checks: HealthChecks{passing, warning}},
+		{Checks: HealthChecks{warning, critical}},
+		{Checks: HealthChecks{critical, maint}},
+		{},
+		{Status: api.HealthWarning},
+	}
+	nodes.SummarizeChecks()
+
+	var statuses []string
+	for _, node := range nodes {
+		require.Nil(t, node.Checks)
+		statuses = append(statuses, node.Status)
+	}
+	require.Equal(t, []string{
+		api.HealthPassing,
+		api.HealthWarning,
+		api.HealthCritical,
+		api.HealthMaint,
+		api.HealthPassing,
+		api.HealthWarning,
+	}, statuses)
+}
+
 func TestStructs_CheckServiceNodes_Filter(t *testing.T) {
 	nodes := CheckServiceNodes{
 		CheckServiceNode{
@@ -1036,3 +1103,23 @@ func TestSpecificServiceRequest_CacheInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryOptions_BlockingTimeout(t *testing.T) {
+	maxQueryTime, defaultQueryTime := 10*time.Minute, 5*time.Minute
+
+	cases := []struct {
+		name string
+		opts QueryOptions
+		want time.Duration
+	}{
+		{"not blocking", QueryOptions{MaxQueryTime: time.Minute}, 0},
+		{"default", QueryOptions{MinQueryIndex: 1}, 5*time.Minute + 5*time.Minute/16},
+		{"given", QueryOptions{MinQueryIndex: 1, MaxQueryTime: time.Minute}, time.Minute + time.Minute/16},
+		{"capped", QueryOptions{MinQueryIndex: 1, MaxQueryTime: time.Hour}, 10*time.Minute + 10*time.Minute/16},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.opts.BlockingTimeout(maxQueryTime, defaultQueryTime))
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package agent
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/lib/file"
+	"github.com/hashicorp/consul/watch"
+)
+
+// templateRetryWait is how long to wait before trying again after a template
+// fails to render or one of its queries fails.
+const templateRetryWait = 5 * time.Second
+
+// templateFileMode is the mode given to a template's destination when it
+// doesn't exist yet. An existing destination keeps its mode.
+const templateFileMode os.FileMode = 0644
+
+// templateService is a healthy service instance as seen by templates.
+type templateService struct {
+	Node    string
+	Address string
+	ID      string
+	Name    string
+	Tags    []string
+	Meta    map[string]string
+	Port    int
+}
+
+// templateKeyPair is a KV entry as seen by templates. Key is relative to the
+// prefix given to ls.
+type templateKeyPair struct {
+	Key   string
+	Value string
+}
+
+// templateFetchFunc runs the query for a dependency, blocking until its index
+// is past minIndex.
+type templateFetchFunc func(minIndex uint64) (uint64, interface{}, error)
+
+// templateFetchResult is the result of a call to a templateFetchFunc.
+type templateFetchResult struct {
+	index uint64
+	value interface{}
+	err   error
+}
+
+// templateRunner renders a template file and keeps the destination up to date
+// as the healthy instances of the services and the KV data it uses change.
+// Results come from the agent's RPC interface rather than the HTTP API, so the
+// HTTP endpoint doesn't need to be enabled.
+type templateRunner struct {
+	tmpl       config.RuntimeTemplate
+	datacenter string
+	token      func() string
+	rpc        func(method string, args interface{}, reply interface{}) error
+	handler    watch.HandlerFunc
+	logger     *log.Logger
+
+	// changeCh is notified when the result of any dependency changes.
+	changeCh chan struct{}
+	stopCh   chan struct{}
+
+	// wg tracks the render loop and the goroutines watching each
+	// dependency, so Stop can wait for them to exit.
+	wg sync.WaitGroup
+
+	// deps holds the latest result of each query the template uses, which
+	// is kept up to date by a blocking query in the background.
+	lock    sync.Mutex
+	deps    map[string]interface{}
+	renders uint64
+}
+
+// newTemplateRunner returns a runner for the template which uses the agent to
+// make queries. Start must be called to start rendering.
+func newTemplateRunner(a *Agent, tmpl config.RuntimeTemplate) *templateRunner {
+	r := &templateRunner{
+		tmpl:       tmpl,
+		datacenter: a.config.Datacenter,
+		token:      a.tokens.UserToken,
+		rpc:        a.RPC,
+		logger:     a.logger,
+		changeCh:   make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+		deps:       make(map[string]interface{}),
+	}
+	if len(tmpl.Args) > 0 {
+		r.handler = makeWatchHandler(a.LogOutput, tmpl.Args)
+	}
+	return r
+}
+
+// Start starts rendering the template in the background.
+func (r *templateRunner) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run()
+	}()
+}
+
+// run renders the template each time one of its dependencies changes until
+// Stop is called.
+func (r *templateRunner) run() {
+	for {
+		if err := r.render(); err != nil {
+			r.logger.Printf("[ERR] agent: Failed to render template %q: %v", r.tmpl.Destination, err)
+			select {
+			case <-time.After(templateRetryWait):
+				continue
+			case <-r.stopCh:
+				return
+			}
+		}
+
+		select {
+		case <-r.changeCh:
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops rendering the template and watching its dependencies, and waits
+// for the background goroutines to exit. Blocking queries which are still in
+// flight finish on their own, but their results are dropped.
+func (r *templateRunner) Stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+// render executes the template and writes the result to the destination if it
+// changed, then runs the command if there is one. The command is passed the
+// template's configuration as JSON on stdin, the same way watch handlers are.
+func (r *templateRunner) render() error {
+	src, err := ioutil.ReadFile(r.tmpl.Source)
+	if err != nil {
+		return err
+	}
+	t, err := template.New(filepath.Base(r.tmpl.Source)).Funcs(r.funcs()).Parse(string(src))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return err
+	}
+
+	if old, err := ioutil.ReadFile(r.tmpl.Destination); err == nil && bytes.Equal(old, buf.Bytes()) {
+		return nil
+	}
+	mode := templateFileMode
+	if fi, err := os.Stat(r.tmpl.Destination); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := file.WriteAtomicWithPerms(r.tmpl.Destination, buf.Bytes(), mode); err != nil {
+		return err
+	}
+	r.logger.Printf("[INFO] agent: Rendered template %q", r.tmpl.Destination)
+
+	if r.handler != nil {
+		r.renders++
+		r.handler(r.renders, r.tmpl)
+	}
+	return nil
+}
+
+// funcs returns the functions templates can use to look up data.
+func (r *templateRunner) funcs() template.FuncMap {
+	return template.FuncMap{
+		"service":      r.service,
+		"key":          r.key,
+		"keyOrDefault": r.keyOrDefault,
+		"ls":           r.ls,
+	}
+}
+
+// service returns the healthy instances of a service, given as "name",
+// "tag.name" and optionally followed by "@dc".
+func (r *templateRunner) service(name string) ([]templateService, error) {
+	name, dc := r.splitDatacenter(name)
+	var tag string
+	if i := strings.Index(name, "."); i != -1 {
+		tag, name = name[:i], name[i+1:]
+	}
+
+	v, err := r.get("service:"+name+"."+tag+"@"+dc, func(minIndex uint64) (uint64, interface{}, error) {
+		args := structs.ServiceSpecificRequest{
+			Datacenter:  dc,
+			ServiceName: name,
+			QueryOptions: structs.QueryOptions{
+				Token:         r.token(),
+				AllowStale:    true,
+				MinQueryIndex: minIndex,
+			},
+		}
+		if tag != "" {
+			args.ServiceTags = []string{tag}
+			args.TagFilter = true
+		}
+		var out structs.IndexedCheckServiceNodes
+		if err := r.rpc("Health.ServiceNodes", &args, &out); err != nil {
+			return 0, nil, err
+		}
+
+		var services []templateService
+		for _, node := range out.Nodes.Filter(true) {
+			addr := node.Service.Address
+			if addr == "" {
+				addr = node.Node.Address
+			}
+			services = append(services, templateService{
+				Node:    node.Node.Node,
+				Address: addr,
+				ID:      node.Service.ID,
+				Name:    node.Service.Service,
+				Tags:    node.Service.Tags,
+				Meta:    node.Service.Meta,
+				Port:    node.Service.Port,
+			})
+		}
+		return out.Index, services, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]templateService), nil
+}
+
+// key returns the value of a KV entry, or an empty string if it doesn't
+// exist.
+func (r *templateRunner) key(key string) (string, error) {
+	return r.keyOrDefault(key, "")
+}
+
+// keyOrDefault returns the value of a KV entry, or the given default if it
+// doesn't exist.
+func (r *templateRunner) keyOrDefault(key, def string) (string, error) {
+	key, dc := r.splitDatacenter(key)
+	v, err := r.get("key:"+key+"@"+dc, func(minIndex uint64) (uint64, interface{}, error) {
+		out, err := r.kvs("KVS.Get", dc, key, minIndex)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(out.Entries) == 0 {
+			return out.Index, (*string)(nil), nil
+		}
+		value := string(out.Entries[0].Value)
+		return out.Index, &value, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if value := v.(*string); value != nil {
+		return *value, nil
+	}
+	return def, nil
+}
+
+// ls returns the KV entries under a prefix, not including any folders.
+func (r *templateRunner) ls(prefix string) ([]templateKeyPair, error) {
+	prefix, dc := r.splitDatacenter(prefix)
+	v, err := r.get("ls:"+prefix+"@"+dc, func(minIndex uint64) (uint64, interface{}, error) {
+		out, err := r.kvs("KVS.List", dc, prefix, minIndex)
+		if err != nil {
+			return 0, nil, err
+		}
+		var pairs []templateKeyPair
+		for _, entry := range out.Entries {
+			key := strings.TrimPrefix(strings.TrimPrefix(entry.Key, prefix), "/")
+			if key == "" || strings.HasSuffix(key, "/") {
+				continue
+			}
+			pairs = append(pairs, templateKeyPair{Key: key, Value: string(entry.Value)})
+		}
+		return out.Index, pairs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]templateKeyPair), nil
+}
+
+// kvs makes a blocking KV query.
+func (r *templateRunner) kvs(method, dc, key string, minIndex uint64) (*structs.IndexedDirEntries, error) {
+	args := structs.KeyRequest{
+		Datacenter: dc,
+		Key:        key,
+		QueryOptions: structs.QueryOptions{
+			Token:         r.token(),
+			AllowStale:    true,
+			MinQueryIndex: minIndex,
+		},
+	}
+	var out structs.IndexedDirEntries
+	if err := r.rpc(method, &args, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// splitDatacenter splits an optional "@dc" suffix off a template argument,
+// defaulting to the agent's datacenter.
+func (r *templateRunner) splitDatacenter(s string) (string, string) {
+	if i := strings.LastIndex(s, "@"); i != -1 {
+		return s[:i], s[i+1:]
+	}
+	return s, r.datacenter
+}
+
+// get returns the latest result of a dependency. The first time a dependency
+// is used it is fetched straight away, and then kept up to date by a blocking
+// query in the background.
+func (r *templateRunner) get(id string, fetch templateFetchFunc) (interface{}, error) {
+	r.lock.Lock()
+	value, ok := r.deps[id]
+	r.lock.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	index, value, err := fetch(0)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	r.deps[id] = value
+	r.lock.Unlock()
+
+	// This is only called while rendering, so the render loop holds the
+	// wait group open and it's safe to add to it here.
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.watch(id, fetch, index)
+	}()
+	return value, nil
+}
+
+// watch keeps a dependency up to date until the runner is stopped, notifying
+// the runner each time its index changes.
+func (r *templateRunner) watch(id string, fetch templateFetchFunc, index uint64) {
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		default:
+		}
+
+		// Run the query in the background so a stop doesn't have to wait
+		// for a blocking query to return.
+		resultCh := make(chan templateFetchResult, 1)
+		go func(index uint64) {
+			newIndex, value, err := fetch(index)
+			resultCh <- templateFetchResult{newIndex, value, err}
+		}(index)
+
+		var res templateFetchResult
+		select {
+		case res = <-resultCh:
+		case <-r.stopCh:
+			return
+		}
+		newIndex, value, err := res.index, res.value, res.err
+		if err != nil {
+			r.logger.Printf("[ERR] agent: Failed to query %q for template %q: %v", id, r.tmpl.Destination, err)
+			select {
+			case <-time.After(templateRetryWait):
+				continue
+			case <-r.stopCh:
+				return
+			}
+		}
+		if newIndex == index {
+			continue
+		}
+		index = newIndex
+
+		r.lock.Lock()
+		r.deps[id] = value
+		r.lock.Unlock()
+
+		select {
+		case r.changeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reloadTemplates stops rendering any existing templates and starts rendering
+// the given set of templates.
+func (a *Agent) reloadTemplates(cfg *config.RuntimeConfig) {
+	a.templatesLock.Lock()
+	defer a.templatesLock.Unlock()
+
+	for _, r := range a.templates {
+		r.Stop()
+	}
+	a.templates = nil
+
+	for _, tmpl := range cfg.Templates {
+		r := newTemplateRunner(a, tmpl)
+		a.templates = append(a.templates, r)
+		r.Start()
+	}
+}
+
+// stopTemplates stops rendering all templates.
+func (a *Agent) stopTemplates() {
+	a.reloadTemplates(&config.RuntimeConfig{})
+}
@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/agent/config"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/testutil/retry"
+)
+
+func TestAgent_Templates(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "templates")
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "haproxy.ctmpl")
+	dest := filepath.Join(dir, "haproxy.cfg")
+	marker := filepath.Join(dir, "reloaded")
+	tmpl := `timeout {{ keyOrDefault "lb/timeout" "5s" }}
+{{ range service "web" }}server {{ .ID }} {{ .Address }}:{{ .Port }}
+{{ end }}{{ range ls "lb/extra" }}{{ .Key }}={{ .Value }}
+{{ end }}`
+	if err := ioutil.WriteFile(source, []byte(tmpl), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	a := NewTestAgent(t.Name(), fmt.Sprintf(`
+		templates = [
+			{
+				source = %q
+				destination = %q
+				args = ["/bin/sh", "-c", "cat > %s"]
+			}
+		]
+	`, source, dest, marker))
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// waitFor waits for the destination to have the given contents, and
+	// for the command to have been run afterwards.
+	waitFor := func(expected string) {
+		t.Helper()
+		retry.Run(t, func(r *retry.R) {
+			out, err := ioutil.ReadFile(dest)
+			if err != nil {
+				r.Fatalf("err: %v", err)
+			}
+			if string(out) != expected {
+				r.Fatalf("bad: %q", out)
+			}
+			if _, err := os.Stat(marker); err != nil {
+				r.Fatalf("err: %v", err)
+			}
+		})
+		if err := os.Remove(marker); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	waitFor("timeout 5s\n")
+
+	srv := &structs.NodeService{
+		ID:      "web1",
+		Service: "web",
+		Address: "127.0.0.2",
+		Port:    8000,
+	}
+	if err := a.AddService(srv, nil, false, "", ConfigSourceLocal); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	waitFor("timeout 5s\nserver web1 127.0.0.2:8000\n")
+
+	setKV(t, a.Agent, "lb/timeout", []byte("30s"), "")
+	setKV(t, a.Agent, "lb/extra/maxconn", []byte("100"), "")
+	waitFor("timeout 30s\nserver web1 127.0.0.2:8000\nmaxconn=100\n")
+
+	// Writes which don't change the output don't run the command.
+	setKV(t, a.Agent, "lb/unused", []byte("1"), "")
+	setKV(t, a.Agent, "lb/timeout", []byte("30s"), "")
+	time.Sleep(500 * time.Millisecond)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("command should not have run: %v", err)
+	}
+
+	// Once stopped the template isn't rendered any more.
+	a.stopTemplates()
+	setKV(t, a.Agent, "lb/timeout", []byte("60s"), "")
+	time.Sleep(500 * time.Millisecond)
+	out, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(out) != "timeout 30s\nserver web1 127.0.0.2:8000\nmaxconn=100\n" {
+		t.Fatalf("bad: %q", out)
+	}
+}
+
+// newTestTemplateRunner returns a runner for the template whose KV queries
+// return the given value, blocking on the given channel once the first query
+// has been answered.
+func newTestTemplateRunner(tmpl config.RuntimeTemplate, value string, blockCh chan struct{}) *templateRunner {
+	return &templateRunner{
+		tmpl:       tmpl,
+		datacenter: "dc1",
+		token:      func() string { return "" },
+		rpc: func(method string, args interface{}, reply interface{}) error {
+			req := args.(*structs.KeyRequest)
+			if req.MinQueryIndex > 0 {
+				<-blockCh
+			}
+			out := reply.(*structs.IndexedDirEntries)
+			out.Index = 1
+			out.Entries = structs.DirEntries{&structs.DirEntry{Key: req.Key, Value: []byte(value)}}
+			return nil
+		},
+		logger:   log.New(os.Stderr, "", log.LstdFlags),
+		changeCh: make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		deps:     make(map[string]interface{}),
+	}
+}
+
+func TestTemplateRunner_FileMode(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "templates")
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "app.ctmpl")
+	if err := ioutil.WriteFile(source, []byte(`{{ key "app/value" }}`), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+
+	// A new destination gets the default mode.
+	created := filepath.Join(dir, "created")
+	r := newTestTemplateRunner(config.RuntimeTemplate{Source: source, Destination: created}, "hello", blockCh)
+	if err := r.render(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r.Stop()
+	fi, err := os.Stat(created)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got := fi.Mode().Perm(); got != templateFileMode {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// An existing destination keeps its mode.
+	existing := filepath.Join(dir, "existing")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := os.Chmod(existing, 0640); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r = newTestTemplateRunner(config.RuntimeTemplate{Source: source, Destination: existing}, "hello", blockCh)
+	if err := r.render(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r.Stop()
+	out, err := ioutil.ReadFile(existing)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(out) != "hello" {
+		t.Fatalf("bad: %q", out)
+	}
+	fi, err = os.Stat(existing)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got := fi.Mode().Perm(); got != 0640 {
+		t.Fatalf("bad: %v", got)
+	}
+}
+
+func TestTemplateRunner_Stop(t *testing.T) {
+	t.Parallel()
+	dir := testutil.TempDir(t, "templates")
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "app.ctmpl")
+	dest := filepath.Join(dir, "app.cfg")
+	if err := ioutil.WriteFile(source, []byte(`{{ key "a" }} {{ key "b" }}`), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The watchers' blocking queries never return while the runner is
+	// running, so Stop must not wait for them.
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+	r := newTestTemplateRunner(config.RuntimeTemplate{Source: source, Destination: dest}, "x", blockCh)
+	r.Start()
+	retry.Run(t, func(r *retry.R) {
+		out, err := ioutil.ReadFile(dest)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if string(out) != "x x" {
+			r.Fatalf("bad: %q", out)
+		}
+	})
+
+	doneCh := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the runner to stop")
+	}
+}
@@ -2,6 +2,7 @@ package token
 
 import (
 	"sync"
+	"time"
 )
 
 // Store is used to hold the special ACL tokens used by Consul agents. It is
@@ -34,11 +35,36 @@ type Store struct {
 	// connectReplicationToken is a special token that's used by servers to
 	// replicate intentions from the primary datacenter.
 	connectReplicationToken string
+
+	// rotationGrace is how long the previous user and agent tokens are
+	// offered as a fallback after they're replaced, for when the servers
+	// don't know the new token yet.
+	rotationGrace time.Duration
+
+	// previousUserToken and previousAgentToken are the tokens that were
+	// replaced at userTokenRotated and agentTokenRotated.
+	previousUserToken  string
+	userTokenRotated   time.Time
+	previousAgentToken string
+	agentTokenRotated  time.Time
+}
+
+// SetRotationGrace sets how long the previous user and agent tokens are
+// returned by PreviousToken after they're replaced. Tokens replaced before
+// it's set, such as while loading the initial tokens, aren't tracked.
+func (t *Store) SetRotationGrace(grace time.Duration) {
+	t.l.Lock()
+	t.rotationGrace = grace
+	t.l.Unlock()
 }
 
 // UpdateUserToken replaces the current user token in the store.
 func (t *Store) UpdateUserToken(token string) {
 	t.l.Lock()
+	if t.rotationGrace > 0 && token != t.userToken {
+		t.previousUserToken = t.userToken
+		t.userTokenRotated = time.Now()
+	}
 	t.userToken = token
 	t.l.Unlock()
 }
@@ -46,6 +72,10 @@ func (t *Store) UpdateUserToken(token string) {
 // UpdateAgentToken replaces the current agent token in the store.
 func (t *Store) UpdateAgentToken(token string) {
 	t.l.Lock()
+	if t.rotationGrace > 0 && token != t.agentToken {
+		t.previousAgentToken = t.agentTokenLocked()
+		t.agentTokenRotated = time.Now()
+	}
 	t.agentToken = token
 	t.l.Unlock()
 }
@@ -84,12 +114,40 @@ func (t *Store) AgentToken() string {
 	t.l.RLock()
 	defer t.l.RUnlock()
 
+	return t.agentTokenLocked()
+}
+
+// agentTokenLocked returns the agent token, falling back to the user token.
+// The lock must already be held.
+func (t *Store) agentTokenLocked() string {
 	if t.agentToken != "" {
 		return t.agentToken
 	}
 	return t.userToken
 }
 
+// PreviousToken returns the token that the given user or agent token replaced,
+// if that happened within the rotation grace period. Requests made with the
+// new token that fail because the servers don't know it yet can be retried
+// with the previous one, so rotating tokens doesn't interrupt the agent.
+func (t *Store) PreviousToken(token string) (string, bool) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	if token == "" {
+		return "", false
+	}
+
+	now := time.Now()
+	switch {
+	case token == t.agentToken && t.previousAgentToken != "" && now.Sub(t.agentTokenRotated) < t.rotationGrace:
+		return t.previousAgentToken, true
+	case token == t.userToken && t.previousUserToken != "" && now.Sub(t.userTokenRotated) < t.rotationGrace:
+		return t.previousUserToken, true
+	}
+	return "", false
+}
+
 // ACLReplicationToken returns the ACL replication token.
 func (t *Store) ACLReplicationToken() string {
 	t.l.RLock()
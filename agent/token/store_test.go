@@ -2,6 +2,7 @@ package token
 
 import (
 	"testing"
+	"time"
 )
 
 func TestStore_RegularTokens(t *testing.T) {
@@ -85,3 +86,41 @@ func TestStore_AgentMasterToken(t *testing.T) {
 	s.UpdateAgentMasterToken("")
 	verify(false, "", "nope", "master", "another")
 }
+
+func TestStore_PreviousToken(t *testing.T) {
+	t.Parallel()
+	s := new(Store)
+	s.SetRotationGrace(time.Hour)
+
+	verify := func(token, want string) {
+		t.Helper()
+		got, ok := s.PreviousToken(token)
+		if got != want || ok != (want != "") {
+			t.Fatalf("token %q got %q (%v) want %q", token, got, ok, want)
+		}
+	}
+
+	// Setting the first tokens doesn't replace anything.
+	s.UpdateUserToken("U1")
+	verify("U1", "")
+
+	// The agent token was the user token before it was set.
+	s.UpdateAgentToken("A1")
+	verify("A1", "U1")
+
+	s.UpdateUserToken("U2")
+	s.UpdateAgentToken("A2")
+	verify("U2", "U1")
+	verify("A2", "A1")
+	verify("U1", "")
+	verify("nope", "")
+
+	// Setting the same token again keeps the previous one.
+	s.UpdateAgentToken("A2")
+	verify("A2", "A1")
+
+	// There's no fallback once the grace period is over.
+	s.SetRotationGrace(0)
+	verify("U2", "")
+	verify("A2", "")
+}
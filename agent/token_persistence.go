@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/consul/lib/file"
+)
+
+const (
+	// tokensPath is the name of the file in the data dir that holds the
+	// tokens set through the /v1/agent/token endpoints, when
+	// acl.enable_token_persistence is set.
+	tokensPath = "acl-tokens.json"
+)
+
+// tokenAliases maps the names of the config options under acl.tokens to the
+// names of the tokens in the /v1/agent/token endpoints.
+var tokenAliases = map[string]string{
+	"default":      "acl_token",
+	"agent":        "acl_agent_token",
+	"agent_master": "acl_agent_master_token",
+	"replication":  "acl_replication_token",
+}
+
+// setToken updates one of the agent's tokens, named as in the
+// /v1/agent/token endpoints. It returns false if the name is unknown.
+func (a *Agent) setToken(target, token string) bool {
+	switch target {
+	case "acl_token":
+		a.tokens.UpdateUserToken(token)
+
+	case "acl_agent_token":
+		a.tokens.UpdateAgentToken(token)
+
+	case "acl_agent_master_token":
+		a.tokens.UpdateAgentMasterToken(token)
+
+	case "acl_replication_token":
+		a.tokens.UpdateACLReplicationToken(token)
+
+	case "connect_replication_token":
+		a.tokens.UpdateConnectReplicationToken(token)
+
+	default:
+		return false
+	}
+	return true
+}
+
+// loadPersistedTokens returns the tokens saved to the data dir, keyed by the
+// name they were set with.
+func (a *Agent) loadPersistedTokens() (map[string]string, error) {
+	if !a.config.ACLTokenPersistence {
+		return nil, nil
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(a.config.DataDir, tokensPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted ACL tokens: %v", err)
+	}
+
+	var tokens map[string]string
+	if err := json.Unmarshal(buf, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode persisted ACL tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// persistToken saves a token set through the /v1/agent/token endpoints to the
+// data dir, so it's used again after a restart.
+func (a *Agent) persistToken(target, token string) error {
+	a.persistedTokensLock.Lock()
+	defer a.persistedTokensLock.Unlock()
+
+	tokens, err := a.loadPersistedTokens()
+	if err != nil {
+		return err
+	}
+	if tokens == nil {
+		tokens = make(map[string]string)
+	}
+	tokens[target] = token
+
+	buf, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(a.config.DataDir, 0700); err != nil {
+		return err
+	}
+	return file.WriteAtomic(filepath.Join(a.config.DataDir, tokensPath), buf)
+}
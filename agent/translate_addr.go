@@ -60,3 +60,58 @@ func (a *Agent) TranslateAddresses(dc string, subj interface{}) {
 		panic(fmt.Errorf("Unhandled type passed to address translator: %#v", subj))
 	}
 }
+
+// setAddressFamilies fills in the family of the node and service addresses in
+// the given structure. Like in TranslateAddresses, the nodes and services may
+// point into the state store, so they're copied rather than changed in place.
+// This runs after the addresses are translated, since a node's WAN address
+// may be of a different family than its LAN one.
+func setAddressFamilies(subj interface{}) {
+	switch v := subj.(type) {
+	case structs.CheckServiceNodes:
+		for i := range v {
+			v[i].Node = nodeWithAddressFamily(v[i].Node)
+			v[i].Service = serviceWithAddressFamily(v[i].Service)
+		}
+	case *structs.NodeServices:
+		v.Node = nodeWithAddressFamily(v.Node)
+		for id, service := range v.Services {
+			v.Services[id] = serviceWithAddressFamily(service)
+		}
+	case structs.Nodes:
+		for i, node := range v {
+			v[i] = nodeWithAddressFamily(node)
+		}
+	case structs.ServiceNodes:
+		for i, entry := range v {
+			clone := *entry
+			clone.AddressFamily = structs.AddressFamily(entry.Address)
+			clone.ServiceAddressFamily = structs.AddressFamily(entry.ServiceAddress)
+			v[i] = &clone
+		}
+	default:
+		panic(fmt.Errorf("Unhandled type passed to address family setter: %#v", subj))
+	}
+}
+
+// nodeWithAddressFamily returns a copy of the node with its address family
+// filled in.
+func nodeWithAddressFamily(node *structs.Node) *structs.Node {
+	if node == nil {
+		return nil
+	}
+	clone := *node
+	clone.AddressFamily = structs.AddressFamily(node.Address)
+	return &clone
+}
+
+// serviceWithAddressFamily returns a copy of the service with its address
+// family filled in.
+func serviceWithAddressFamily(service *structs.NodeService) *structs.NodeService {
+	if service == nil {
+		return nil
+	}
+	clone := *service
+	clone.AddressFamily = structs.AddressFamily(service.Address)
+	return &clone
+}
@@ -202,7 +202,9 @@ func (s *HTTPServer) Txn(resp http.ResponseWriter, req *http.Request) (interface
 	} else {
 		args := structs.TxnRequest{Ops: ops}
 		s.parseDC(req, &args.Datacenter)
-		s.parseToken(req, &args.Token)
+		if s.parseWriteRequest(resp, req, &args.WriteRequest) {
+			return nil, nil
+		}
 
 		var reply structs.TxnResponse
 		if err := s.agent.RPC("Txn.Apply", &args, &reply); err != nil {
@@ -286,6 +286,7 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
 				},
 				QueryMeta: structs.QueryMeta{
 					KnownLeader: true,
+					ServedBy:    a.Config.NodeName,
 				},
 			}
 			if !reflect.DeepEqual(txnResp, expected) {
@@ -32,6 +32,11 @@ type ACLToken struct {
 	CreateTime  time.Time `json:",omitempty"`
 	Hash        []byte    `json:",omitempty"`
 
+	// ExpirationTime and AuthMethod are only set for tokens created by
+	// logging in to an auth method.
+	ExpirationTime *time.Time `json:",omitempty"`
+	AuthMethod     string     `json:",omitempty"`
+
 	// DEPRECATED (ACL-Legacy-Compat)
 	// Rules will only be present for legacy tokens returned via the new APIs
 	Rules string `json:",omitempty"`
@@ -47,6 +52,14 @@ type ACLTokenListEntry struct {
 	CreateTime  time.Time
 	Hash        []byte
 	Legacy      bool
+
+	ExpirationTime *time.Time `json:",omitempty"`
+	AuthMethod     string     `json:",omitempty"`
+
+	// LastUsed is when the token was last used in the datacenter, to within
+	// an hour. It's nil if the token hasn't been used since the servers
+	// started tracking it.
+	LastUsed *time.Time `json:",omitempty"`
 }
 
 // ACLEntry is used to represent a legacy ACL token
@@ -539,6 +552,48 @@ func (a *ACL) PolicyList(q *QueryOptions) ([]*ACLPolicyListEntry, *QueryMeta, er
 	return entries, qm, nil
 }
 
+// ACLLoginParams are the details used to log in to an auth method.
+type ACLLoginParams struct {
+	AuthMethod  string
+	BearerToken string
+	Meta        map[string]string `json:",omitempty"`
+}
+
+// Login exchanges a credential issued by a third party for a local ACL token,
+// using the auth method named in the params.
+func (a *ACL) Login(auth *ACLLoginParams, q *WriteOptions) (*ACLToken, *WriteMeta, error) {
+	r := a.c.newRequest("POST", "/v1/acl/login")
+	r.setWriteOptions(q)
+	r.obj = auth
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{RequestTime: rtt}
+	var out ACLToken
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return &out, wm, nil
+}
+
+// Logout deletes the token in the write options, which must have been
+// created by logging in.
+func (a *ACL) Logout(q *WriteOptions) (*WriteMeta, error) {
+	r := a.c.newRequest("POST", "/v1/acl/logout")
+	r.setWriteOptions(q)
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	wm := &WriteMeta{RequestTime: rtt}
+	return wm, nil
+}
+
 // RulesTranslate translates the legacy rule syntax into the current syntax.
 //
 // Deprecated: Support for the legacy syntax translation will be removed
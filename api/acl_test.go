@@ -1,8 +1,17 @@
 package api
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/testutil/retry"
 
@@ -724,3 +733,61 @@ query_prefix "" {
 	require.NoError(t, err)
 	require.Equal(t, expected, rules)
 }
+
+func TestAPI_ACLLoginLogout(t *testing.T) {
+	t.Parallel()
+	c, s := makeACLClient(t)
+	defer s.Stop()
+
+	acl := c.ACL()
+
+	_, _, err := acl.PolicyCreate(&ACLPolicy{
+		Name:  "service-web",
+		Rules: `service "web" { policy = "write" }`,
+	}, nil)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	_, err = c.ConfigEntries().Set(&ConfigEntry{
+		Kind: ACLAuthMethod,
+		Name: "workloads",
+		Config: map[string]interface{}{
+			"JWTValidationPubKeys": []string{string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+			"ClaimMappings":        map[string]string{"svc": "service"},
+			"BindingRules": []map[string]interface{}{
+				{"Policies": []string{"service-${service}"}},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	// Sign a JWT for the web service with ES256.
+	header, _ := json.Marshal(map[string]string{"alg": "ES256"})
+	claims, _ := json.Marshal(map[string]interface{}{"svc": "web", "exp": time.Now().Add(time.Minute).Unix()})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, ss, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	sig := make([]byte, 64)
+	copy(sig[32-len(r.Bytes()):32], r.Bytes())
+	copy(sig[64-len(ss.Bytes()):], ss.Bytes())
+
+	token, _, err := acl.Login(&ACLLoginParams{
+		AuthMethod:  "workloads",
+		BearerToken: signed + "." + base64.RawURLEncoding.EncodeToString(sig),
+	}, &WriteOptions{Token: "anonymous"})
+	require.NoError(t, err)
+	require.Equal(t, "workloads", token.AuthMethod)
+	require.NotNil(t, token.ExpirationTime)
+	require.True(t, token.Local)
+
+	_, err = acl.Logout(&WriteOptions{Token: token.SecretID})
+	require.NoError(t, err)
+
+	_, _, err = acl.TokenRead(token.AccessorID, nil)
+	require.Error(t, err)
+}
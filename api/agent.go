@@ -2,9 +2,13 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 // ServiceKind is the kind of service being registered.
@@ -89,6 +93,10 @@ type AgentService struct {
 	ProxyDestination string                          `json:",omitempty"`
 	Proxy            *AgentServiceConnectProxyConfig `json:",omitempty"`
 	Connect          *AgentServiceConnect            `json:",omitempty"`
+
+	// AddressFamily is left out of the agent's content hash, so it doesn't
+	// change the hashes blocking clients already hold.
+	AddressFamily string `json:",omitempty" hash:"ignore"`
 }
 
 // AgentServiceChecksInfo returns information about a Service and its checks
@@ -341,6 +349,25 @@ func (a *Agent) Host() (map[string]interface{}, error) {
 	return out, nil
 }
 
+// DNSForwarding returns configuration for the given resolver, one of "bind",
+// "dnsmasq" or "systemd-resolved", that forwards queries for the Consul
+// domain to the agent's DNS server.
+func (a *Agent) DNSForwarding(format string) (string, error) {
+	r := a.c.newRequest("GET", "/v1/agent/dns-forwarding")
+	r.params.Set("format", format)
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
 // Metrics is used to query the agent we are speaking to for
 // its current internal metric data
 func (a *Agent) Metrics() (*MetricsInfo, error) {
@@ -369,6 +396,146 @@ func (a *Agent) Reload() error {
 	return nil
 }
 
+// AgentStateDump is the result of asking an agent to dump its in-memory state.
+type AgentStateDump struct {
+	// Path is where the dump was written, in the agent's data dir.
+	Path string
+
+	// Dump is the contents of the dump.
+	Dump map[string]interface{}
+}
+
+// StateDump makes the agent we are connected to write its in-memory view of
+// the cluster and its local state to its data dir, for debugging the agent
+// disagreeing with the servers.
+func (a *Agent) StateDump() (*AgentStateDump, error) {
+	r := a.c.newRequest("PUT", "/v1/agent/state-dump")
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out AgentStateDump
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AgentFlushedCaches is the result of asking an agent to flush its caches.
+type AgentFlushedCaches struct {
+	// ConnPoolConns is how many connections to servers were closed.
+	ConnPoolConns int
+
+	// CoordinateUpdates is how many pending coordinate updates the leader
+	// applied.
+	CoordinateUpdates int
+}
+
+// FlushCaches makes the agent we are connected to drop its resolved ACLs and
+// its connections to servers, and the leader apply pending coordinate
+// updates, so emergency fixes take effect straight away.
+func (a *Agent) FlushCaches() (*AgentFlushedCaches, error) {
+	r := a.c.newRequest("PUT", "/v1/agent/flush-caches")
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out AgentFlushedCaches
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AgentBenchOptions configures a benchmark the agent runs against the
+// cluster. Zero values are replaced by the agent's defaults.
+type AgentBenchOptions struct {
+	// Workloads are the workloads to run at once: "kv", "register" and
+	// "blocking".
+	Workloads []string
+
+	// Duration is how long to generate load for.
+	Duration time.Duration
+
+	// Rate limits the requests per second of each workload. Zero means
+	// no limit.
+	Rate float64
+
+	// Concurrency is the number of workers of each workload.
+	Concurrency int
+
+	// Keys is the number of distinct keys and nodes written.
+	Keys int
+
+	// ValueSize is the size in bytes of the values written.
+	ValueSize int
+
+	// Prefix is the prefix of the keys and node names written.
+	Prefix string
+
+	// SkipCleanup leaves the keys and nodes written in place once the
+	// run ends.
+	SkipCleanup bool
+}
+
+// AgentBenchReport is what a benchmark measured.
+type AgentBenchReport struct {
+	// Elapsed is how long the load was generated for, which is shorter
+	// than the requested duration if the run was stopped.
+	Elapsed time.Duration
+
+	Workloads []AgentBenchWorkload
+}
+
+// AgentBenchWorkload is what a benchmark measured for one workload. Failed
+// requests are left out of the rate and latencies.
+type AgentBenchWorkload struct {
+	Name       string
+	Requests   int
+	Errors     int
+	Rate       float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	FirstError string
+}
+
+// Bench makes the agent we are connected to generate load against the
+// cluster, and returns what it measured once the run ends. It blocks for
+// the duration of the run.
+func (a *Agent) Bench(opts *AgentBenchOptions) (*AgentBenchReport, error) {
+	r := a.c.newRequest("PUT", "/v1/agent/bench")
+	r.obj = opts
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out AgentBenchReport
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BenchStop ends the benchmark the agent we are connected to is running
+// early. The pending Bench call returns what was measured until then.
+func (a *Agent) BenchStop() error {
+	r := a.c.newRequest("PUT", "/v1/agent/bench/stop")
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
 // NodeName is used to get the node name of the agent
 func (a *Agent) NodeName() (string, error) {
 	if a.nodeName != "" {
@@ -878,6 +1045,33 @@ func (a *Agent) DisableNodeMaintenance() error {
 	return nil
 }
 
+// EnableNodeDrain toggles node drain mode on for the agent we are
+// connected to.
+func (a *Agent) EnableNodeDrain(reason string) error {
+	r := a.c.newRequest("PUT", "/v1/agent/drain")
+	r.params.Set("enable", "true")
+	r.params.Set("reason", reason)
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DisableNodeDrain toggles node drain mode off for the agent we are
+// connected to.
+func (a *Agent) DisableNodeDrain() error {
+	r := a.c.newRequest("PUT", "/v1/agent/drain")
+	r.params.Set("enable", "false")
+	_, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
 // Monitor returns a channel which will receive streaming logs from the agent
 // Providing a non-nil stopCh can be used to close the connection and stop the
 // log stream. An empty string will be sent down the given channel when there's
@@ -949,8 +1143,9 @@ func (a *Agent) UpdateACLReplicationToken(token string, q *WriteOptions) (*Write
 }
 
 // updateToken can be used to update an agent's ACL token after the agent has
-// started. The tokens are not persisted, so will need to be updated again if
-// the agent is restarted.
+// started. The tokens are only persisted if the agent has
+// acl.enable_token_persistence set, otherwise they will need to be updated
+// again if the agent is restarted.
 func (a *Agent) updateToken(target, token string, q *WriteOptions) (*WriteMeta, error) {
 	r := a.c.newRequest("PUT", fmt.Sprintf("/v1/agent/token/%s", target))
 	r.setWriteOptions(q)
@@ -964,3 +1159,146 @@ func (a *Agent) updateToken(target, token string, q *WriteOptions) (*WriteMeta,
 	wm := &WriteMeta{RequestTime: rtt}
 	return wm, nil
 }
+
+// LogEntry is a single parsed line from the agent's log stream.
+type LogEntry struct {
+	// Time is when the line was logged, with the one second resolution of
+	// the agent's log format. It is zero if the line couldn't be parsed.
+	Time time.Time
+
+	// Level is the log level, such as "INFO", without brackets.
+	Level string
+
+	// Message is the remainder of the line after the level.
+	Message string
+
+	// Raw is the line exactly as it was received.
+	Raw string
+}
+
+// logTimeFormat is the timestamp format the agent's logger prefixes lines with.
+const logTimeFormat = "2006/01/02 15:04:05"
+
+// parseLogEntry splits a log line into its parts. Lines that don't follow
+// the agent's format are returned with only Message and Raw set.
+func parseLogEntry(line string) *LogEntry {
+	entry := &LogEntry{Message: line, Raw: line}
+	if len(line) < len(logTimeFormat)+2 {
+		return entry
+	}
+	t, err := time.ParseInLocation(logTimeFormat, line[:len(logTimeFormat)], time.Local)
+	if err != nil {
+		return entry
+	}
+	rest := line[len(logTimeFormat)+1:]
+	if !strings.HasPrefix(rest, "[") {
+		return entry
+	}
+	end := strings.Index(rest, "] ")
+	if end == -1 {
+		return entry
+	}
+	entry.Time = t
+	entry.Level = rest[1:end]
+	entry.Message = rest[end+2:]
+	return entry
+}
+
+// MonitorStream is like Monitor but delivers parsed log entries and keeps
+// the stream going across interruptions. If the connection to the agent is
+// lost, it reconnects after DefaultMonitorRetryTime. The agent replays its
+// recent log buffer to new monitors, so lines that were already delivered
+// before the reconnect are skipped. Closing stopCh ends the stream and
+// closes the returned channel.
+func (a *Agent) MonitorStream(loglevel string, stopCh <-chan struct{}, q *QueryOptions) (<-chan *LogEntry, error) {
+	ctx, cancel := context.WithCancel(q.Context())
+	opts := q.WithContext(ctx)
+
+	resp, err := a.monitorRequest(loglevel, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-stopCh:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	entryCh := make(chan *LogEntry, 64)
+	go func() {
+		defer cancel()
+		defer close(entryCh)
+
+		var resume monitorResume
+		for {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				entry := parseLogEntry(scanner.Text())
+				if !resume.add(entry) {
+					continue
+				}
+				select {
+				case entryCh <- entry:
+				case <-ctx.Done():
+					resp.Body.Close()
+					return
+				}
+			}
+			resp.Body.Close()
+
+			for {
+				select {
+				case <-time.After(DefaultMonitorRetryTime):
+				case <-ctx.Done():
+					return
+				}
+				if resp, err = a.monitorRequest(loglevel, opts); err == nil {
+					break
+				}
+			}
+		}
+	}()
+	return entryCh, nil
+}
+
+// monitorRequest opens a log stream from the agent.
+func (a *Agent) monitorRequest(loglevel string, q *QueryOptions) (*http.Response, error) {
+	r := a.c.newRequest("GET", "/v1/agent/monitor")
+	r.setQueryOptions(q)
+	if loglevel != "" {
+		r.params.Add("loglevel", loglevel)
+	}
+	_, resp, err := requireOK(a.c.doRequest(r))
+	return resp, err
+}
+
+// monitorResume tracks the newest log lines delivered by MonitorStream so
+// that lines replayed by the agent after a reconnect can be skipped.
+type monitorResume struct {
+	last time.Time
+	seen map[string]struct{}
+}
+
+// add records the entry and returns false if it was already delivered.
+// Entries without a timestamp can't be placed and are always delivered.
+func (m *monitorResume) add(entry *LogEntry) bool {
+	if entry.Time.IsZero() {
+		return true
+	}
+	switch {
+	case entry.Time.Before(m.last):
+		return false
+	case entry.Time.After(m.last):
+		m.last = entry.Time
+		m.seen = make(map[string]struct{})
+	}
+	if _, ok := m.seen[entry.Raw]; ok {
+		return false
+	}
+	m.seen[entry.Raw] = struct{}{}
+	return true
+}
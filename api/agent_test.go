@@ -74,6 +74,73 @@ func TestAPI_AgentHost(t *testing.T) {
 	})
 }
 
+func TestAPI_AgentStateDump(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	agent := c.Agent()
+	reg := &AgentServiceRegistration{
+		Name: "redis",
+		Port: 8000,
+	}
+	if err := agent.ServiceRegister(reg); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := agent.StateDump()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := os.Stat(out.Path); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Dump["Node"] != s.Config.NodeName {
+		t.Fatalf("bad: %v", out.Dump)
+	}
+	services, ok := out.Dump["Services"].([]interface{})
+	if !ok || len(services) != 1 {
+		t.Fatalf("bad: %v", out.Dump["Services"])
+	}
+}
+
+func TestAPI_AgentFlushCaches(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	s.WaitForSerfCheck(t)
+	if _, err := c.Agent().FlushCaches(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAPI_AgentBench(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	s.WaitForSerfCheck(t)
+	agent := c.Agent()
+	report, err := agent.Bench(&AgentBenchOptions{
+		Workloads: []string{"register"},
+		Duration:  200 * time.Millisecond,
+		Keys:      3,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(report.Workloads) != 1 || report.Workloads[0].Name != "register" ||
+		report.Workloads[0].Requests == 0 {
+		t.Fatalf("bad: %#v", report)
+	}
+
+	// There's nothing left to stop.
+	if err := agent.BenchStop(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestAPI_AgentReload(t *testing.T) {
 	t.Parallel()
 
@@ -1136,6 +1203,85 @@ func TestAPI_AgentMonitor(t *testing.T) {
 	}
 }
 
+func TestAPI_AgentMonitorStream(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	agent := c.Agent()
+
+	stopCh := make(chan struct{})
+	entryCh, err := agent.MonitorStream("info", stopCh, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Wait for the first log entry and validate it
+	select {
+	case entry := <-entryCh:
+		if entry.Level != "INFO" || entry.Time.IsZero() {
+			t.Fatalf("bad: %#v", entry)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("failed to get a log entry")
+	}
+
+	// Stopping should close the channel.
+	close(stopCh)
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-entryCh:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("channel not closed")
+		}
+	}
+}
+
+func TestAPI_parseLogEntry(t *testing.T) {
+	t.Parallel()
+
+	entry := parseLogEntry("2018/12/06 15:04:05 [WARN] agent: something happened")
+	require.Equal(t, "WARN", entry.Level)
+	require.Equal(t, "agent: something happened", entry.Message)
+	require.Equal(t, time.Date(2018, 12, 6, 15, 4, 5, 0, time.Local), entry.Time)
+
+	for _, line := range []string{"", "    hello", "2018/12/06 15:04:05 no level"} {
+		entry := parseLogEntry(line)
+		require.True(t, entry.Time.IsZero(), line)
+		require.Equal(t, line, entry.Message)
+		require.Equal(t, line, entry.Raw)
+	}
+}
+
+func TestAPI_monitorResume(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		"2018/12/06 15:04:05 [INFO] one",
+		"2018/12/06 15:04:05 [INFO] two",
+		"2018/12/06 15:04:06 [INFO] three",
+	}
+	var resume monitorResume
+	for _, line := range lines {
+		require.True(t, resume.add(parseLogEntry(line)), line)
+	}
+
+	// A reconnect replays the buffer, which should all be skipped.
+	for _, line := range lines {
+		require.False(t, resume.add(parseLogEntry(line)), line)
+	}
+
+	// New lines, including ones in the same second, get through.
+	require.True(t, resume.add(parseLogEntry("2018/12/06 15:04:06 [INFO] four")))
+	require.True(t, resume.add(parseLogEntry("2018/12/06 15:04:07 [INFO] five")))
+	require.True(t, resume.add(parseLogEntry("unparseable")))
+	require.True(t, resume.add(parseLogEntry("unparseable")))
+}
+
 func TestAPI_ServiceMaintenance(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -1238,6 +1384,46 @@ func TestAPI_NodeMaintenance(t *testing.T) {
 	}
 }
 
+func TestAPI_NodeDrain(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	agent := c.Agent()
+
+	// Enable drain mode
+	if err := agent.EnableNodeDrain("scaling down"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Check that a passing check was added
+	checks, err := agent.Checks()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	check, ok := checks[NodeDrain]
+	if !ok {
+		t.Fatalf("bad: %#v", checks)
+	}
+	if check.Status != HealthPassing || check.Notes != "scaling down" {
+		t.Fatalf("bad: %#v", check)
+	}
+
+	// Disable drain mode
+	if err := agent.DisableNodeDrain(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Ensure the check was removed
+	checks, err = agent.Checks()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := checks[NodeDrain]; ok {
+		t.Fatalf("should have removed health check")
+	}
+}
+
 func TestAPI_AgentUpdateToken(t *testing.T) {
 	t.Parallel()
 	c, s := makeACLClient(t)
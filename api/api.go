@@ -62,6 +62,11 @@ const (
 	// whether or not to disable certificate checking.
 	HTTPSSLVerifyEnvName = "CONSUL_HTTP_SSL_VERIFY"
 
+	// HTTPAddrsEnvName defines an environment variable name which sets a
+	// comma-separated list of fallback HTTP addresses that are tried in order
+	// if the primary address can't be reached.
+	HTTPAddrsEnvName = "CONSUL_HTTP_ADDRS"
+
 	// GRPCAddrEnvName defines an environment variable name which sets the gRPC
 	// address for consul connect envoy. Note this isn't actually used by the api
 	// client in this package but is defined here for consistency with all the
@@ -134,6 +139,26 @@ type QueryOptions struct {
 	// be provided for filtering.
 	NodeMeta map[string]string
 
+	// SessionMeta is used to filter the sessions listed to the ones with
+	// the given metadata key/value pairs.
+	SessionMeta map[string]string
+
+	// Timeout bounds how long the servers may take to answer the query,
+	// including any time spent blocking. A blocking query that runs out of
+	// time returns the current results, and other queries fail. It is
+	// ignored when UseCache is set.
+	Timeout time.Duration
+
+	// MultiDC is used in service queries to return the nodes of all of
+	// the given datacenters, tagged with their datacenter and sorted by
+	// the estimated round trip time to it.
+	MultiDC []string
+
+	// MaxResults limits how many results catalog and health queries return.
+	// Results are dropped after sorting by Near, and ResultsTruncated is set
+	// in the QueryMeta if any were. Servers may enforce a lower limit.
+	MaxResults int
+
 	// RelayFactor is used in keyring operations to cause responses to be
 	// relayed back to the sender through N other random nodes. Must be
 	// a value from 0 to 5 (inclusive).
@@ -230,6 +255,28 @@ type QueryMeta struct {
 	// CacheAge is set if request was ?cached and indicates how stale the cached
 	// response is.
 	CacheAge time.Duration
+
+	// ResultsTruncated is true if some results were left out because of
+	// MaxResults or the servers' limit.
+	ResultsTruncated bool
+
+	// LockDelay is set when reading a single key that can't be acquired
+	// yet because of the lock-delay of a session that was invalidated while
+	// holding it. It's how much longer the lock-delay lasts.
+	LockDelay time.Duration
+
+	// ServedBy is the name of the server that answered the request. It's
+	// empty for requests the agent answered itself.
+	ServedBy string
+
+	// Stale is true if the request was answered by a follower rather than
+	// the leader, so the results may be behind. LastContact and IndexGap
+	// show how far behind.
+	Stale bool
+
+	// IndexGap is how many Raft log entries the server that answered had
+	// stored but not yet applied to its state when it answered.
+	IndexGap uint64
 }
 
 // WriteMeta is used to return meta data about a write
@@ -276,9 +323,28 @@ type Config struct {
 	// which overrides the agent's default token.
 	Token string
 
+	// Addresses is an optional list of fallback agent addresses. If a
+	// connection can't be made to Address, each of these is tried in order
+	// using the same scheme. Unix sockets are not supported here.
+	Addresses []string
+
+	// MaxRetries is the number of times a GET request is retried after a
+	// retryable error (see IsRetryableError). Writes are only retried when
+	// they were rejected by rate limits with a 429, since otherwise they may
+	// have gone through. Defaults to 0, which disables retries.
+	MaxRetries int
+
+	// RetryWaitTime is the initial time to wait between retries of a read.
+	// The wait doubles after each attempt. Defaults to DefaultRetryWaitTime.
+	RetryWaitTime time.Duration
+
 	TLSConfig TLSConfig
 }
 
+// DefaultRetryWaitTime is the initial backoff between retried reads when
+// Config.MaxRetries is set and no RetryWaitTime is given.
+const DefaultRetryWaitTime = 250 * time.Millisecond
+
 // TLSConfig is used to generate a TLSClientConfig that's useful for talking to
 // Consul using TLS.
 type TLSConfig struct {
@@ -339,6 +405,14 @@ func defaultConfig(transportFn func() *http.Transport) *Config {
 		config.Address = addr
 	}
 
+	if addrs := os.Getenv(HTTPAddrsEnvName); addrs != "" {
+		for _, addr := range strings.Split(addrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				config.Addresses = append(config.Addresses, addr)
+			}
+		}
+	}
+
 	if token := os.Getenv(HTTPTokenEnvName); token != "" {
 		config.Token = token
 	}
@@ -537,6 +611,29 @@ func NewClient(config *Config) (*Client, error) {
 		config.Address = parts[1]
 	}
 
+	if len(config.Addresses) == 0 {
+		config.Addresses = defConfig.Addresses
+	}
+	addrs := make([]string, 0, len(config.Addresses))
+	for _, addr := range config.Addresses {
+		parts := strings.SplitN(addr, "://", 2)
+		if len(parts) == 2 {
+			if parts[0] != config.Scheme {
+				return nil, fmt.Errorf("Scheme for fallback address %q does not match %q", addr, config.Scheme)
+			}
+			addr = parts[1]
+		}
+		addrs = append(addrs, addr)
+	}
+	config.Addresses = addrs
+
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("MaxRetries must not be negative")
+	}
+	if config.RetryWaitTime == 0 {
+		config.RetryWaitTime = DefaultRetryWaitTime
+	}
+
 	if config.Token == "" {
 		config.Token = defConfig.Token
 	}
@@ -605,6 +702,9 @@ func (r *request) setQueryOptions(q *QueryOptions) {
 	if q.WaitTime != 0 {
 		r.params.Set("wait", durToMsec(q.WaitTime))
 	}
+	if q.Timeout != 0 {
+		r.params.Set("timeout", durToMsec(q.Timeout))
+	}
 	if q.WaitHash != "" {
 		r.params.Set("hash", q.WaitHash)
 	}
@@ -619,6 +719,17 @@ func (r *request) setQueryOptions(q *QueryOptions) {
 			r.params.Add("node-meta", key+":"+value)
 		}
 	}
+	if len(q.SessionMeta) > 0 {
+		for key, value := range q.SessionMeta {
+			r.params.Add("session-meta", key+":"+value)
+		}
+	}
+	if len(q.MultiDC) > 0 {
+		r.params.Set("multi-dc", strings.Join(q.MultiDC, ","))
+	}
+	if q.MaxResults > 0 {
+		r.params.Set("max_results", strconv.Itoa(q.MaxResults))
+	}
 	if q.RelayFactor != 0 {
 		r.params.Set("relay-factor", strconv.Itoa(int(q.RelayFactor)))
 	}
@@ -755,16 +866,106 @@ func (c *Client) newRequest(method, path string) *request {
 	return r
 }
 
-// doRequest runs a request with our client
+// doRequest runs a request with our client. The configured fallback addresses
+// are tried in order when a connection can't be made, and GET requests and
+// throttled writes are retried with backoff if MaxRetries is set.
 func (c *Client) doRequest(r *request) (time.Duration, *http.Response, error) {
-	req, err := r.toHTTP()
-	if err != nil {
-		return 0, nil, err
+	// Buffer the body so it can be replayed against another address. A
+	// streaming body can only be sent once, so only one attempt is made.
+	replayable := true
+	if r.body == nil && r.obj != nil {
+		b, err := encodeBody(r.obj)
+		if err != nil {
+			return 0, nil, err
+		}
+		r.body = b
 	}
+	var body []byte
+	switch b := r.body.(type) {
+	case nil:
+	case *bytes.Buffer:
+		body = b.Bytes()
+	case *bytes.Reader:
+		buf, err := ioutil.ReadAll(b)
+		if err != nil {
+			return 0, nil, err
+		}
+		body = buf
+	default:
+		replayable = false
+	}
+
+	hosts := []string{r.url.Host}
+	retries := 0
+	if replayable {
+		hosts = append(hosts, c.config.Addresses...)
+		retries = c.config.MaxRetries
+	}
+
 	start := time.Now()
-	resp, err := c.config.HttpClient.Do(req)
-	diff := time.Since(start)
-	return diff, resp, err
+	wait := c.config.RetryWaitTime
+	for attempt := 0; ; attempt++ {
+		var resp *http.Response
+		var err error
+		for _, host := range hosts {
+			r.url.Host = host
+			if replayable && body != nil {
+				r.body = bytes.NewReader(body)
+			}
+			var req *http.Request
+			req, err = r.toHTTP()
+			if err != nil {
+				return 0, nil, err
+			}
+			resp, err = c.config.HttpClient.Do(req)
+			if err == nil || !isDialError(err) {
+				break
+			}
+		}
+
+		// Requests rejected by rate limits were never handled, so they're
+		// safe to retry even if they're writes.
+		retryable := err == nil && resp.StatusCode == http.StatusTooManyRequests
+		if r.method == "GET" {
+			retryable = retryable || IsRetryableError(err) || (err == nil && resp.StatusCode == 500)
+		}
+		if attempt >= retries || !retryable || r.context().Err() != nil {
+			return time.Since(start), resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-r.context().Done():
+			return time.Since(start), nil, r.context().Err()
+		}
+		wait *= 2
+	}
+}
+
+// context returns the request's context, or a background context if none
+// was given.
+func (r *request) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// isDialError returns true if the error shows that a connection to the agent
+// couldn't be established, which means the request was never sent and it's
+// safe to try another address.
+func isDialError(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if oerr, ok := err.(*net.OpError); ok {
+		return oerr.Op == "dial"
+	}
+	return false
 }
 
 // Query is used to do a GET request against an endpoint
@@ -850,6 +1051,29 @@ func parseQueryMeta(resp *http.Response, q *QueryMeta) error {
 		q.AddressTranslationEnabled = false
 	}
 
+	// Parse X-Consul-Results-Truncated
+	q.ResultsTruncated = header.Get("X-Consul-Results-Truncated") == "true"
+
+	// Parse X-Consul-Served-By, X-Consul-Stale and X-Consul-Index-Gap
+	q.ServedBy = header.Get("X-Consul-Served-By")
+	q.Stale = header.Get("X-Consul-Stale") == "true"
+	if gapStr := header.Get("X-Consul-Index-Gap"); gapStr != "" {
+		gap, err := strconv.ParseUint(gapStr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Failed to parse X-Consul-Index-Gap: %v", err)
+		}
+		q.IndexGap = gap
+	}
+
+	// Parse X-Consul-LockDelay
+	if delayStr := header.Get("X-Consul-LockDelay"); delayStr != "" {
+		delay, err := strconv.ParseUint(delayStr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Failed to parse X-Consul-LockDelay: %v", err)
+		}
+		q.LockDelay = time.Duration(delay) * time.Millisecond
+	}
+
 	// Parse Cache info
 	if cacheStr := header.Get("X-Cache"); cacheStr != "" {
 		q.CacheHit = strings.EqualFold(cacheStr, "HIT")
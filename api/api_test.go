@@ -1,16 +1,20 @@
 package api
 
 import (
+	"context"
 	crand "crypto/rand"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -394,6 +398,8 @@ func TestAPI_SetQueryOptions(t *testing.T) {
 		WaitTime:          100 * time.Second,
 		Token:             "12345",
 		Near:              "nodex",
+		MultiDC:           []string{"dc1", "dc2"},
+		Timeout:           5 * time.Second,
 	}
 	r.setQueryOptions(q)
 
@@ -418,6 +424,12 @@ func TestAPI_SetQueryOptions(t *testing.T) {
 	if r.params.Get("near") != "nodex" {
 		t.Fatalf("bad: %v", r.params)
 	}
+	if r.params.Get("multi-dc") != "dc1,dc2" {
+		t.Fatalf("bad: %v", r.params)
+	}
+	if r.params.Get("timeout") != "5000ms" {
+		t.Fatalf("bad: %v", r.params)
+	}
 	assert.Equal("", r.header.Get("Cache-Control"))
 
 	r = c.newRequest("GET", "/v1/kv/foo")
@@ -485,6 +497,9 @@ func TestAPI_ParseQueryMeta(t *testing.T) {
 	resp.Header.Set("X-Consul-LastContact", "80")
 	resp.Header.Set("X-Consul-KnownLeader", "true")
 	resp.Header.Set("X-Consul-Translate-Addresses", "true")
+	resp.Header.Set("X-Consul-Served-By", "server1")
+	resp.Header.Set("X-Consul-Stale", "true")
+	resp.Header.Set("X-Consul-Index-Gap", "3")
 
 	qm := &QueryMeta{}
 	if err := parseQueryMeta(resp, qm); err != nil {
@@ -503,6 +518,9 @@ func TestAPI_ParseQueryMeta(t *testing.T) {
 	if !qm.AddressTranslationEnabled {
 		t.Fatalf("Bad: %v", qm)
 	}
+	if qm.ServedBy != "server1" || !qm.Stale || qm.IndexGap != 3 {
+		t.Fatalf("Bad: %v", qm)
+	}
 }
 
 func TestAPI_UnixSocket(t *testing.T) {
@@ -535,6 +553,132 @@ func TestAPI_UnixSocket(t *testing.T) {
 	}
 }
 
+func TestAPI_FallbackAddresses(t *testing.T) {
+	t.Parallel()
+
+	// Grab a port that nothing is listening on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := ln.Addr().String()
+	ln.Close()
+
+	var writes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			atomic.AddInt32(&writes, 1)
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+			return
+		}
+		w.Write([]byte(`{"Config":{"NodeName":"foo"}}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&Config{
+		Address:   deadAddr,
+		Addresses: []string{"http://" + srv.Listener.Addr().String()},
+	})
+	require.NoError(t, err)
+
+	info, err := c.Agent().Self()
+	require.NoError(t, err)
+	require.Equal(t, "foo", info["Config"]["NodeName"])
+
+	// Writes are failed over too since the request never left the client.
+	_, err = c.KV().Put(&KVPair{Key: "foo", Value: []byte("bar")}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&writes))
+
+	// A mismatched scheme is rejected.
+	_, err = NewClient(&Config{
+		Address:   deadAddr,
+		Addresses: []string{"https://" + srv.Listener.Addr().String()},
+	})
+	require.Error(t, err)
+}
+
+func TestAPI_ReadRetries(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(500)
+			return
+		}
+		w.Write([]byte(`{"Config":{"NodeName":"foo"}}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&Config{
+		Address:       srv.Listener.Addr().String(),
+		MaxRetries:    2,
+		RetryWaitTime: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// Reads get retried until they succeed.
+	info, err := c.Agent().Self()
+	require.NoError(t, err)
+	require.Equal(t, "foo", info["Config"]["NodeName"])
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Writes never get retried.
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.KV().Put(&KVPair{Key: "foo"}, nil)
+	require.Error(t, err)
+	require.True(t, IsRetryableError(err))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestAPI_ThrottledWriteRetries(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`true`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&Config{
+		Address:       srv.Listener.Addr().String(),
+		MaxRetries:    2,
+		RetryWaitTime: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// Writes rejected by rate limits are safe to retry.
+	_, err = c.KV().Put(&KVPair{Key: "foo"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestAPI_ReadRetries_Context(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&Config{
+		Address:       srv.Listener.Addr().String(),
+		MaxRetries:    10,
+		RetryWaitTime: time.Hour,
+	})
+	require.NoError(t, err)
+
+	// Cancelling the context should abort the backoff.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err = c.KV().Get("foo", (&QueryOptions{}).WithContext(ctx))
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
 func TestAPI_durToMsec(t *testing.T) {
 	t.Parallel()
 	if ms := durToMsec(0); ms != "0ms" {
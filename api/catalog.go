@@ -1,5 +1,15 @@
 package api
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"golang.org/x/crypto/ed25519"
+)
+
 type Weights struct {
 	Passing int
 	Warning int
@@ -12,6 +22,7 @@ type Node struct {
 	Datacenter      string
 	TaggedAddresses map[string]string
 	Meta            map[string]string
+	AddressFamily   string `json:",omitempty"`
 	CreateIndex     uint64
 	ModifyIndex     uint64
 }
@@ -33,10 +44,12 @@ type CatalogService struct {
 	ServiceEnableTagOverride bool
 	// DEPRECATED (ProxyDestination) - remove the next comment!
 	// We forgot to ever add ServiceProxyDestination here so no need to deprecate!
-	ServiceProxy *AgentServiceConnectProxyConfig
-	CreateIndex  uint64
-	Checks       HealthChecks
-	ModifyIndex  uint64
+	ServiceProxy         *AgentServiceConnectProxyConfig
+	AddressFamily        string `json:",omitempty"`
+	ServiceAddressFamily string `json:",omitempty"`
+	CreateIndex          uint64
+	Checks               HealthChecks
+	ModifyIndex          uint64
 }
 
 type CatalogNode struct {
@@ -65,6 +78,16 @@ type CatalogDeregistration struct {
 	CheckID    string
 }
 
+// CatalogCheckUpdate sets the status and output of a health check that's
+// already registered in the catalog.
+type CatalogCheckUpdate struct {
+	Node       string
+	Datacenter string
+	CheckID    string
+	Status     string
+	Output     string
+}
+
 // Catalog can be used to query the Catalog endpoints
 type Catalog struct {
 	c *Client
@@ -107,6 +130,64 @@ func (c *Catalog) Deregister(dereg *CatalogDeregistration, q *WriteOptions) (*Wr
 	return wm, nil
 }
 
+// UpdateCheck is used to update the status of a health check registered
+// through the catalog, for nodes that don't run an agent to do it.
+func (c *Catalog) UpdateCheck(update *CatalogCheckUpdate, q *WriteOptions) (*WriteMeta, error) {
+	r := c.c.newRequest("PUT", "/v1/catalog/check/update")
+	r.setWriteOptions(q)
+	r.obj = update
+	rtt, resp, err := requireOK(c.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+
+	return wm, nil
+}
+
+// RegisterCAS is used to do a check-and-set registration. The service being
+// registered, or the node if there's no service, is only written if its
+// ModifyIndex matches the given index. An index of 0 only writes it if it
+// doesn't exist yet. Returns true on success or false on failures.
+func (c *Catalog) RegisterCAS(reg *CatalogRegistration, index uint64, q *WriteOptions) (bool, *WriteMeta, error) {
+	r := c.c.newRequest("PUT", "/v1/catalog/register")
+	r.setWriteOptions(q)
+	r.params.Set("cas", strconv.FormatUint(index, 10))
+	r.obj = reg
+	return c.casRequest(r)
+}
+
+// DeregisterCAS is used to do a check-and-set deregistration. The service,
+// check or node is only removed if its ModifyIndex matches the given index.
+// Returns true on success or false on failures.
+func (c *Catalog) DeregisterCAS(dereg *CatalogDeregistration, index uint64, q *WriteOptions) (bool, *WriteMeta, error) {
+	r := c.c.newRequest("PUT", "/v1/catalog/deregister")
+	r.setWriteOptions(q)
+	r.params.Set("cas", strconv.FormatUint(index, 10))
+	r.obj = dereg
+	return c.casRequest(r)
+}
+
+func (c *Catalog) casRequest(r *request) (bool, *WriteMeta, error) {
+	rtt, resp, err := requireOK(c.c.doRequest(r))
+	if err != nil {
+		return false, nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+
+	var out bool
+	if err := decodeBody(resp, &out); err != nil {
+		return false, nil, err
+	}
+	return out, wm, nil
+}
+
 // Datacenters is used to query for all the known datacenters
 func (c *Catalog) Datacenters() ([]string, error) {
 	r := c.c.newRequest("GET", "/v1/catalog/datacenters")
@@ -144,6 +225,126 @@ func (c *Catalog) Nodes(q *QueryOptions) ([]*Node, *QueryMeta, error) {
 	return out, qm, nil
 }
 
+// NodesWithoutService is used to query for the nodes that don't have a
+// passing instance of the given service
+func (c *Catalog) NodesWithoutService(service string, q *QueryOptions) ([]*Node, *QueryMeta, error) {
+	r := c.c.newRequest("GET", "/v1/catalog/nodes-without-service/"+service)
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(c.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out []*Node
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return out, qm, nil
+}
+
+// SigningKey is the public key that signed catalog and health responses are
+// verified with.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	PublicKey []byte
+}
+
+// SigningKey is used to get the public key that signed catalog and health
+// responses are verified with
+func (c *Catalog) SigningKey(q *QueryOptions) (*SigningKey, *QueryMeta, error) {
+	r := c.c.newRequest("GET", "/v1/catalog/signing-key")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(c.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out SigningKey
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return &out, qm, nil
+}
+
+// SignedRequest describes the request a signed response answers, which the
+// signature covers so a response can't be replayed for a different query.
+// The fields are in the order they're encoded in.
+type SignedRequest struct {
+	// Datacenter is the datacenter the request was made to.
+	Datacenter string
+
+	// Endpoint is "Catalog.ServiceNodes" for the catalog service endpoints
+	// and "Health.ServiceNodes" for the health service endpoints.
+	Endpoint string
+
+	// KeyID is the X-Consul-Signature-Key-ID header of the response.
+	KeyID string
+
+	ServiceName     string
+	ServiceTags     []string
+	Connect         bool
+	OnlyPassing     bool
+	SummarizeChecks bool
+	NodeMetaFilters map[string]string
+}
+
+// VerifyResponseSignature checks the signature of a response that was
+// requested with ?signed. The request is the query that was made, the index
+// is the X-Consul-Index header, the body is the raw response body, and the
+// signature is the X-Consul-Signature header.
+func VerifyResponseSignature(key *SigningKey, req *SignedRequest, index uint64, body []byte, signature string) error {
+	if key.Algorithm != "ed25519" || len(key.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("unsupported signing key algorithm %q", key.Algorithm)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	// The tags are signed sorted and without duplicates, and empty lists
+	// and maps as null.
+	canonical := *req
+	seen := make(map[string]struct{})
+	canonical.ServiceTags = nil
+	for _, tag := range req.ServiceTags {
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		canonical.ServiceTags = append(canonical.ServiceTags, tag)
+	}
+	sort.Strings(canonical.ServiceTags)
+	if len(canonical.NodeMetaFilters) == 0 {
+		canonical.NodeMetaFilters = nil
+	}
+	encoded, err := json.Marshal(&canonical)
+	if err != nil {
+		return err
+	}
+
+	msg := []byte("consul-signed-response-v1\n")
+	msg = append(msg, encoded...)
+	msg = append(msg, '\n')
+	msg = strconv.AppendUint(msg, index, 10)
+	msg = append(msg, '\n')
+	msg = append(msg, body...)
+	if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), msg, sig) {
+		return fmt.Errorf("signature doesn't match the response")
+	}
+	return nil
+}
+
 // Services is used to query for all known services
 func (c *Catalog) Services(q *QueryOptions) (map[string][]string, *QueryMeta, error) {
 	r := c.c.newRequest("GET", "/v1/catalog/services")
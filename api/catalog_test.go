@@ -58,6 +58,7 @@ func TestAPI_CatalogNodes(t *testing.T) {
 				Meta: map[string]string{
 					"consul-network-segment": "",
 				},
+				AddressFamily: "ipv4",
 				// CreateIndex will never always be meta.LastIndex - 1
 				// The purpose of this test is not to test CreateIndex value of an agent
 				// rather to check if the client agent can get the correct number
@@ -73,6 +74,37 @@ func TestAPI_CatalogNodes(t *testing.T) {
 	})
 }
 
+func TestAPI_CatalogNodes_MaxResults(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	catalog := c.Catalog()
+	for _, node := range []string{"foo1", "foo2"} {
+		_, err := catalog.Register(&CatalogRegistration{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+		}, nil)
+		require.NoError(t, err)
+	}
+
+	retry.Run(t, func(r *retry.R) {
+		nodes, meta, err := catalog.Nodes(&QueryOptions{MaxResults: 2})
+		if err != nil {
+			r.Fatal(err)
+		}
+		if len(nodes) != 2 || !meta.ResultsTruncated {
+			r.Fatalf("bad: %v %v", nodes, meta)
+		}
+	})
+
+	nodes, meta, err := catalog.Nodes(&QueryOptions{MaxResults: 3})
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	require.False(t, meta.ResultsTruncated)
+}
+
 func TestAPI_CatalogNodes_MetaFilter(t *testing.T) {
 	t.Parallel()
 	meta := map[string]string{"somekey": "somevalue"}
@@ -127,6 +159,35 @@ func TestAPI_CatalogNodes_MetaFilter(t *testing.T) {
 	})
 }
 
+func TestAPI_CatalogNodesWithoutService(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	catalog := c.Catalog()
+	retry.Run(t, func(r *retry.R) {
+		// The consul service is on the only node.
+		nodes, _, err := catalog.NodesWithoutService("consul", nil)
+		if err != nil {
+			r.Fatal(err)
+		}
+		if len(nodes) != 0 {
+			r.Fatalf("bad: %v", nodes)
+		}
+
+		nodes, meta, err := catalog.NodesWithoutService("web", nil)
+		if err != nil {
+			r.Fatal(err)
+		}
+		if meta.LastIndex == 0 {
+			r.Fatalf("Bad: %v", meta)
+		}
+		if len(nodes) != 1 || nodes[0].Node != s.Config.NodeName {
+			r.Fatalf("bad: %v", nodes)
+		}
+	})
+}
+
 func TestAPI_CatalogServices(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -844,6 +905,118 @@ func TestAPI_CatalogRegistration(t *testing.T) {
 	})
 }
 
+func TestAPI_CatalogUpdateCheck(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	catalog := c.Catalog()
+	health := c.Health()
+
+	reg := &CatalogRegistration{
+		Datacenter: "dc1",
+		Node:       "external",
+		Address:    "192.168.10.10",
+		Checks: HealthChecks{
+			&HealthCheck{
+				CheckID: "ping",
+				Name:    "ping",
+			},
+		},
+	}
+	retry.Run(t, func(r *retry.R) {
+		if _, err := catalog.Register(reg, nil); err != nil {
+			r.Fatal(err)
+		}
+	})
+
+	update := &CatalogCheckUpdate{
+		Node:    "external",
+		CheckID: "ping",
+		Status:  HealthPassing,
+		Output:  "pong",
+	}
+	if _, err := catalog.UpdateCheck(update, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	checks, _, err := health.Node("external", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Status != HealthPassing || checks[0].Output != "pong" {
+		t.Fatalf("bad: %v", checks)
+	}
+
+	update.CheckID = "nope"
+	if _, err := catalog.UpdateCheck(update, nil); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func TestAPI_CatalogRegistrationCAS(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	catalog := c.Catalog()
+	s.WaitForSerfCheck(t)
+
+	reg := &CatalogRegistration{
+		Datacenter: "dc1",
+		Node:       "foobar",
+		Address:    "192.168.10.10",
+		Service: &AgentService{
+			ID:      "redis1",
+			Service: "redis",
+			Port:    8000,
+		},
+	}
+
+	// An index of 0 only registers the service if it's not there yet.
+	ok, _, err := catalog.RegisterCAS(reg, 0, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, _, err = catalog.RegisterCAS(reg, 0, nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	services, _, err := catalog.Service("redis", "", nil)
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	index := services[0].ModifyIndex
+
+	reg.Service.Port = 9000
+	ok, _, err = catalog.RegisterCAS(reg, index-1, nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, _, err = catalog.RegisterCAS(reg, index, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	services, _, err = catalog.Service("redis", "", nil)
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	require.Equal(t, 9000, services[0].ServicePort)
+	index = services[0].ModifyIndex
+
+	dereg := &CatalogDeregistration{
+		Datacenter: "dc1",
+		Node:       "foobar",
+		ServiceID:  "redis1",
+	}
+	ok, _, err = catalog.DeregisterCAS(dereg, index-1, nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, _, err = catalog.DeregisterCAS(dereg, index, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	services, _, err = catalog.Service("redis", "", nil)
+	require.NoError(t, err)
+	require.Len(t, services, 0)
+}
+
 func TestAPI_CatalogEnableTagOverride(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
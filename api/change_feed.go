@@ -0,0 +1,113 @@
+package api
+
+import (
+	"strconv"
+)
+
+// ChangeType is the kind of data a change feed entry is about.
+type ChangeType string
+
+const (
+	ChangeKV      ChangeType = "kv"
+	ChangeNode    ChangeType = "node"
+	ChangeService ChangeType = "service"
+	ChangeCheck   ChangeType = "check"
+)
+
+// ChangeOp is the kind of mutation recorded in the change feed.
+type ChangeOp string
+
+const (
+	ChangeUpsert     ChangeOp = "upsert"
+	ChangeDelete     ChangeOp = "delete"
+	ChangeDeleteTree ChangeOp = "delete-tree"
+)
+
+// Change is a single catalog or KV mutation recorded in the change feed.
+type Change struct {
+	// Type is the kind of data that changed.
+	Type ChangeType
+
+	// Op is the mutation that was made.
+	Op ChangeOp
+
+	// Key is the KV key that changed, or the prefix for tree deletes.
+	Key string
+
+	// Node is the node a catalog change is for.
+	Node string
+
+	// ServiceID and CheckID identify the service or check that changed.
+	ServiceID string
+	CheckID   string
+
+	// KV, NodeEntry, Service and Check hold the written values for
+	// upserts.
+	KV        *KVPair
+	NodeEntry *Node
+	Service   *AgentService
+	Check     *HealthCheck
+}
+
+// ChangeFeedEntry has the changes made by a single write.
+type ChangeFeedEntry struct {
+	// Index is the Raft index of the write.
+	Index uint64
+
+	// Seq numbers the entries in the order they were recorded.
+	Seq uint64
+
+	// Changes are the mutations made by the write, in order.
+	Changes []*Change
+}
+
+// ChangeFeedPage is a batch of change feed entries.
+type ChangeFeedPage struct {
+	// Entries are the entries after the requested index, oldest first.
+	Entries []*ChangeFeedEntry
+
+	// NextIndex is the WaitIndex to use to read the next batch.
+	NextIndex uint64
+
+	// More is true if the entries were cut short by the limit.
+	More bool
+}
+
+// ChangeFeed can be used to read the log of catalog and KV writes kept by the
+// servers when change_feed_max_entries is set.
+type ChangeFeed struct {
+	c *Client
+}
+
+// ChangeFeed returns a handle to the change feed endpoint.
+func (c *Client) ChangeFeed() *ChangeFeed {
+	return &ChangeFeed{c}
+}
+
+// List returns up to limit entries after q.WaitIndex, or all of them if limit
+// is 0, blocking until there are some. Consumers should keep the NextIndex of
+// each page and use it as the WaitIndex of the next request. If entries after
+// the WaitIndex were already dropped from the feed, the request fails with a
+// 410 status and the consumer has to resynchronize from the current state.
+func (f *ChangeFeed) List(limit int, q *QueryOptions) (*ChangeFeedPage, *QueryMeta, error) {
+	r := f.c.newRequest("GET", "/v1/changes")
+	r.setQueryOptions(q)
+	if limit > 0 {
+		r.params.Set("limit", strconv.Itoa(limit))
+	}
+	rtt, resp, err := requireOK(f.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out ChangeFeedPage
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return &out, qm, nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_ChangeFeed(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	c, s := makeClientWithConfig(t, nil, func(conf *testutil.TestServerConfig) {
+		conf.ChangeFeedSize = 100
+	})
+	defer s.Stop()
+
+	s.WaitForSerfCheck(t)
+
+	feed := c.ChangeFeed()
+	page, meta, err := feed.List(0, nil)
+	require.NoError(err)
+	require.NotZero(meta.LastIndex)
+	start := page.NextIndex
+
+	_, err = c.KV().Put(&KVPair{Key: "foo", Value: []byte("bar")}, nil)
+	require.NoError(err)
+
+	// The agent may sync to the catalog as well, so look for the KV write
+	// among the entries.
+	page, _, err = feed.List(0, &QueryOptions{WaitIndex: start})
+	require.NoError(err)
+	require.NotEmpty(page.Entries)
+	require.False(page.More)
+	require.Equal(page.Entries[len(page.Entries)-1].Index, page.NextIndex)
+
+	var change *Change
+	for _, entry := range page.Entries {
+		for _, c := range entry.Changes {
+			if c.Type == ChangeKV {
+				change = c
+			}
+		}
+	}
+	require.NotNil(change)
+	require.Equal(ChangeUpsert, change.Op)
+	require.Equal("foo", change.KV.Key)
+	require.Equal([]byte("bar"), change.KV.Value)
+}
+
+func TestAPI_ChangeFeed_Disabled(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	_, _, err := c.ChangeFeed().List(0, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "404")
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// ServiceDefaults is the kind of config entry that holds the defaults
+	// for a single service. Entries of this kind are named after their
+	// service.
+	ServiceDefaults string = "service-defaults"
+
+	// ACLAuthMethod is the kind of config entry that lets workloads log in
+	// for an ACL token with ACL.Login.
+	ACLAuthMethod string = "acl-auth-method"
+)
+
+// ConfigEntry is a generic piece of configuration stored by the servers,
+// identified by its kind and name. The format of the body depends on the
+// kind.
+type ConfigEntry struct {
+	Kind   string
+	Name   string
+	Config map[string]interface{}
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// ConfigEntries can be used to manage config entries.
+type ConfigEntries struct {
+	c *Client
+}
+
+// ConfigEntries returns a handle to the config entry endpoints.
+func (c *Client) ConfigEntries() *ConfigEntries {
+	return &ConfigEntries{c}
+}
+
+// List returns the config entries of the given kind, or of all kinds if the
+// kind is empty.
+func (e *ConfigEntries) List(kind string, q *QueryOptions) ([]*ConfigEntry, *QueryMeta, error) {
+	path := "/v1/config"
+	if kind != "" {
+		path += "/" + kind
+	}
+	r := e.c.newRequest("GET", path)
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(e.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out []*ConfigEntry
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return out, qm, nil
+}
+
+// Get returns the config entry with the given kind and name, or nil if there
+// isn't one.
+func (e *ConfigEntries) Get(kind, name string, q *QueryOptions) (*ConfigEntry, *QueryMeta, error) {
+	r := e.c.newRequest("GET", "/v1/config/"+kind+"/"+name)
+	r.setQueryOptions(q)
+	rtt, resp, err := e.c.doRequest(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	if resp.StatusCode == 404 {
+		return nil, qm, nil
+	} else if resp.StatusCode != 200 {
+		var buf bytes.Buffer
+		io.Copy(&buf, resp.Body)
+		return nil, nil, fmt.Errorf(
+			"Unexpected response %d: %s", resp.StatusCode, buf.String())
+	}
+
+	var out ConfigEntry
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return &out, qm, nil
+}
+
+// Set creates or updates a config entry.
+func (e *ConfigEntries) Set(entry *ConfigEntry, q *WriteOptions) (*WriteMeta, error) {
+	r := e.c.newRequest("PUT", "/v1/config/"+entry.Kind+"/"+entry.Name)
+	r.setWriteOptions(q)
+	r.obj = entry
+	rtt, resp, err := requireOK(e.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+	return wm, nil
+}
+
+// Delete deletes the config entry with the given kind and name.
+func (e *ConfigEntries) Delete(kind, name string, q *WriteOptions) (*WriteMeta, error) {
+	r := e.c.newRequest("DELETE", "/v1/config/"+kind+"/"+name)
+	r.setWriteOptions(q)
+	rtt, resp, err := requireOK(e.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+	return wm, nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_ConfigEntries(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	entries := c.ConfigEntries()
+
+	list, _, err := entries.List("", nil)
+	require.NoError(err)
+	require.Len(list, 0)
+
+	// Create a couple of entries and read one back.
+	_, err = entries.Set(&ConfigEntry{
+		Kind: ServiceDefaults,
+		Name: "web",
+		Config: map[string]interface{}{
+			"protocol": "http",
+			"upstreams": []interface{}{
+				map[string]interface{}{"name": "db", "port": float64(5432)},
+			},
+		},
+	}, nil)
+	require.NoError(err)
+	_, err = entries.Set(&ConfigEntry{Kind: "router", Name: "web"}, nil)
+	require.NoError(err)
+
+	entry, _, err := entries.Get(ServiceDefaults, "web", nil)
+	require.NoError(err)
+	require.NotNil(entry)
+	require.Equal(map[string]interface{}{
+		"protocol": "http",
+		"upstreams": []interface{}{
+			map[string]interface{}{"name": "db", "port": float64(5432)},
+		},
+	}, entry.Config)
+	require.NotZero(entry.CreateIndex)
+
+	list, _, err = entries.List("", nil)
+	require.NoError(err)
+	require.Len(list, 2)
+	list, _, err = entries.List("router", nil)
+	require.NoError(err)
+	require.Len(list, 1)
+
+	// Kinds are validated.
+	_, err = entries.Set(&ConfigEntry{Kind: "Bad_Kind", Name: "web"}, nil)
+	require.Error(err)
+
+	// Delete one.
+	_, err = entries.Delete(ServiceDefaults, "web", nil)
+	require.NoError(err)
+	entry, _, err = entries.Get(ServiceDefaults, "web", nil)
+	require.NoError(err)
+	require.Nil(entry)
+}
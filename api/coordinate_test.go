@@ -104,3 +104,46 @@ func TestAPI_CoordinateUpdate(t *testing.T) {
 		verify.Values(r, "", coords[0], entry)
 	})
 }
+
+func TestAPI_CoordinateUpdateBatch(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	var entries []*CoordinateEntry
+	for _, node := range []string{"foo", "bar"} {
+		_, err := c.Catalog().Register(&CatalogRegistration{
+			Node:    node,
+			Address: "1.1.1.1",
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		newCoord := coordinate.NewCoordinate(coordinate.DefaultConfig())
+		newCoord.Height = 0.5
+		entries = append(entries, &CoordinateEntry{
+			Node:  node,
+			Coord: newCoord,
+		})
+	}
+
+	coord := c.Coordinate()
+	if _, err := coord.UpdateBatch(entries, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	retryer := &retry.Timer{Timeout: 5 * time.Second, Wait: 1 * time.Second}
+	retry.RunWith(retryer, t, func(r *retry.R) {
+		for _, entry := range entries {
+			coords, _, err := coord.Node(entry.Node, nil)
+			if err != nil {
+				r.Fatal(err)
+			}
+			if len(coords) != 1 {
+				r.Fatalf("bad: %v", coords)
+			}
+			verify.Values(r, "", coords[0], entry)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+)
+
+// ElectionCandidate is a session taking part in an election.
+type ElectionCandidate struct {
+	// Session is the session of the candidate. The candidate drops out of
+	// the election when the session is invalidated.
+	Session string
+
+	// Value is the data the candidate supplied when it registered.
+	Value []byte
+
+	// CreateIndex is when the candidate registered. Candidates are elected
+	// in the order they registered.
+	CreateIndex uint64
+}
+
+// Election is the state of a named election.
+type Election struct {
+	// Name is the name of the election.
+	Name string
+
+	// Leader is the current leader, or nil if there isn't one.
+	Leader *ElectionCandidate
+
+	// Term is increased each time a leader is elected.
+	Term uint64
+
+	// Candidates are the registered candidates, including the leader, in
+	// the order they will be elected.
+	Candidates []*ElectionCandidate
+}
+
+// Elections can be used to take part in leader elections run by the servers.
+// Unlike Lock, candidates don't need to watch the leader and try again when
+// it goes away, since the servers hand leadership to the next candidate.
+type Elections struct {
+	c *Client
+}
+
+// Elections returns a handle to the election endpoints.
+func (c *Client) Elections() *Elections {
+	return &Elections{c}
+}
+
+// Campaign registers the session as a candidate in the named election, with
+// the given value published while it's the leader. It returns true if the
+// session is the leader. Campaigning again updates the candidate's value.
+func (e *Elections) Campaign(name, session string, value []byte, q *WriteOptions) (bool, *WriteMeta, error) {
+	r := e.c.newRequest("PUT", "/v1/election/"+name)
+	r.setWriteOptions(q)
+	r.params.Set("session", session)
+	r.body = bytes.NewReader(value)
+	rtt, resp, err := requireOK(e.c.doRequest(r))
+	if err != nil {
+		return false, nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+
+	var leader bool
+	if err := decodeBody(resp, &leader); err != nil {
+		return false, nil, err
+	}
+	return leader, wm, nil
+}
+
+// Resign removes the session from the named election, stepping down if it's
+// the leader.
+func (e *Elections) Resign(name, session string, q *WriteOptions) (*WriteMeta, error) {
+	r := e.c.newRequest("DELETE", "/v1/election/"+name)
+	r.setWriteOptions(q)
+	r.params.Set("session", session)
+	rtt, resp, err := requireOK(e.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+	return wm, nil
+}
+
+// Get returns the leader and candidates of the named election. This supports
+// blocking queries, so it can be used to watch for leadership changes.
+func (e *Elections) Get(name string, q *QueryOptions) (*Election, *QueryMeta, error) {
+	r := e.c.newRequest("GET", "/v1/election/"+name)
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(e.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out Election
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return &out, qm, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_Elections(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	elections := c.Elections()
+	session := c.Session()
+
+	election, meta, err := elections.Get("db", nil)
+	require.NoError(err)
+	require.Nil(election.Leader)
+	require.Empty(election.Candidates)
+	require.NotZero(meta.LastIndex)
+
+	id1, _, err := session.CreateNoChecks(nil, nil)
+	require.NoError(err)
+	id2, _, err := session.CreateNoChecks(nil, nil)
+	require.NoError(err)
+
+	// The first candidate becomes the leader.
+	leader, _, err := elections.Campaign("db", id1, []byte("one"), nil)
+	require.NoError(err)
+	require.True(leader)
+	leader, _, err = elections.Campaign("db", id2, []byte("two"), nil)
+	require.NoError(err)
+	require.False(leader)
+
+	election, _, err = elections.Get("db", nil)
+	require.NoError(err)
+	require.NotNil(election.Leader)
+	require.Equal(id1, election.Leader.Session)
+	require.Equal([]byte("one"), election.Leader.Value)
+	require.Len(election.Candidates, 2)
+
+	// Resigning hands over to the next candidate.
+	_, err = elections.Resign("db", id1, nil)
+	require.NoError(err)
+	election, _, err = elections.Get("db", nil)
+	require.NoError(err)
+	require.NotNil(election.Leader)
+	require.Equal(id2, election.Leader.Session)
+	require.Equal(uint64(2), election.Term)
+
+	// Campaigns need a session.
+	_, _, err = elections.Campaign("db", "", nil, nil)
+	require.Error(err)
+	require.Contains(err.Error(), "Must provide a session")
+}
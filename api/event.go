@@ -2,9 +2,15 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"strconv"
+	"time"
 )
 
+// DefaultEventWatchRetryTime is how long Watch waits before retrying the
+// event list after a failed request.
+const DefaultEventWatchRetryTime = 2 * time.Second
+
 // Event can be used to query the Event endpoints
 type Event struct {
 	c *Client
@@ -102,3 +108,74 @@ func (e *Event) IDToIndex(uuid string) uint64 {
 	}
 	return lowVal ^ highVal
 }
+
+// Watch returns a channel which receives user events as the agent sees them,
+// optionally filtered by name. It uses blocking queries against the event
+// list and re-issues them after failures, so the stream survives brief agent
+// unavailability. If q.WaitIndex is set, events after the one with that index
+// are delivered first, which allows a watcher to resume where it left off by
+// passing IDToIndex of the last event it saw. Otherwise only events fired
+// after Watch is called are delivered. Events can be missed if more arrive
+// than the agent buffers between two queries. Closing stopCh stops the watch
+// and closes the returned channel.
+func (e *Event) Watch(name string, stopCh <-chan struct{}, q *QueryOptions) (<-chan *UserEvent, error) {
+	ctx, cancel := context.WithCancel(q.Context())
+	opts := q.WithContext(ctx)
+
+	// Seed the index from the current events unless we are resuming.
+	if opts.WaitIndex == 0 {
+		_, qm, err := e.List(name, opts)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		opts.WaitIndex = qm.LastIndex
+	}
+
+	go func() {
+		select {
+		case <-stopCh:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	eventCh := make(chan *UserEvent, 64)
+	go func() {
+		defer cancel()
+		defer close(eventCh)
+		for {
+			events, qm, err := e.List(name, opts)
+			if err != nil {
+				select {
+				case <-time.After(DefaultEventWatchRetryTime):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			for _, event := range e.eventsAfter(events, opts.WaitIndex) {
+				select {
+				case eventCh <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			opts.WaitIndex = qm.LastIndex
+		}
+	}()
+	return eventCh, nil
+}
+
+// eventsAfter returns the events that follow the one with the given index.
+// If no event has that index then it has aged out of the agent's buffer (or
+// there were no events), so all of them are considered new.
+func (e *Event) eventsAfter(events []*UserEvent, index uint64) []*UserEvent {
+	for i := len(events) - 1; i >= 0; i-- {
+		if e.IDToIndex(events[i].ID) == index {
+			return events[i+1:]
+		}
+	}
+	return events
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAPI_EventFireList(t *testing.T) {
@@ -48,3 +50,57 @@ func TestAPI_EventFireList(t *testing.T) {
 		t.Fatalf("Bad: %#v", qm)
 	}
 }
+
+func TestAPI_EventWatch(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	event := c.Event()
+
+	// Fire an event before watching which should not be delivered.
+	if _, _, err := event.Fire(&UserEvent{Name: "foo"}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var before string
+	retry.Run(t, func(r *retry.R) {
+		events, _, err := event.List("foo", nil)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if len(events) != 1 {
+			r.Fatalf("bad: %#v", events)
+		}
+		before = events[0].ID
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	eventCh, err := event.Watch("foo", stopCh, nil)
+	require.NoError(t, err)
+
+	// Events with other names are filtered out.
+	if _, _, err := event.Fire(&UserEvent{Name: "bar"}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	id, _, err := event.Fire(&UserEvent{Name: "foo", Payload: []byte("hello")}, nil)
+	require.NoError(t, err)
+
+	select {
+	case e := <-eventCh:
+		require.Equal(t, id, e.ID)
+		require.Equal(t, []byte("hello"), e.Payload)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("failed to get an event")
+	}
+
+	// Resuming from the first event delivers everything after it.
+	resumeCh, err := event.Watch("foo", stopCh, &QueryOptions{WaitIndex: event.IDToIndex(before)})
+	require.NoError(t, err)
+	select {
+	case e := <-resumeCh:
+		require.Equal(t, id, e.ID)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("failed to get an event")
+	}
+}
@@ -19,6 +19,9 @@ const (
 	// NodeMaint is the special key set by a node in maintenance mode.
 	NodeMaint = "_node_maintenance"
 
+	// NodeDrain is the special key set by a node in drain mode.
+	NodeDrain = "_node_drain"
+
 	// ServiceMaintPrefix is the prefix for a service in maintenance mode.
 	ServiceMaintPrefix = "_service_maintenance:"
 )
@@ -101,6 +104,15 @@ type ServiceEntry struct {
 	Node    *Node
 	Service *AgentService
 	Checks  HealthChecks
+
+	// Locality is how close the node is to the node results were sorted
+	// near: "host", "zone", "region" or "remote". It's empty if the results
+	// weren't sorted, or the distance isn't known.
+	Locality string `json:",omitempty"`
+
+	// Status is the aggregated status of the checks, which is only set
+	// instead of Checks by ServiceSummary.
+	Status string `json:",omitempty"`
 }
 
 // Health can be used to query the Health endpoints
@@ -163,11 +175,37 @@ func (h *Health) Service(service, tag string, passingOnly bool, q *QueryOptions)
 	if tag != "" {
 		tags = []string{tag}
 	}
-	return h.service(service, tags, passingOnly, q, false)
+	return h.service(service, tags, passingOnly, false, q, false)
 }
 
 func (h *Health) ServiceMultipleTags(service string, tags []string, passingOnly bool, q *QueryOptions) ([]*ServiceEntry, *QueryMeta, error) {
-	return h.service(service, tags, passingOnly, q, false)
+	return h.service(service, tags, passingOnly, false, q, false)
+}
+
+// Nearest returns up to n passing instances of a service, nearest to the agent
+// first, unless q.Near asks for another node. The health filtering, sorting and
+// limiting are all done by the servers.
+func (h *Health) Nearest(service string, n int, q *QueryOptions) ([]*ServiceEntry, *QueryMeta, error) {
+	opts := &QueryOptions{}
+	if q != nil {
+		*opts = *q
+	}
+	if opts.Near == "" {
+		opts.Near = "_agent"
+	}
+	opts.MaxResults = n
+	return h.service(service, nil, true, false, opts, false)
+}
+
+// ServiceSummary is equivalent to Service except that the checks of each
+// instance are left out and replaced with their aggregated status, which makes
+// the response much smaller for services with many checks.
+func (h *Health) ServiceSummary(service, tag string, passingOnly bool, q *QueryOptions) ([]*ServiceEntry, *QueryMeta, error) {
+	var tags []string
+	if tag != "" {
+		tags = []string{tag}
+	}
+	return h.service(service, tags, passingOnly, true, q, false)
 }
 
 // Connect is equivalent to Service except that it will only return services
@@ -180,14 +218,14 @@ func (h *Health) Connect(service, tag string, passingOnly bool, q *QueryOptions)
 	if tag != "" {
 		tags = []string{tag}
 	}
-	return h.service(service, tags, passingOnly, q, true)
+	return h.service(service, tags, passingOnly, false, q, true)
 }
 
 func (h *Health) ConnectMultipleTags(service string, tags []string, passingOnly bool, q *QueryOptions) ([]*ServiceEntry, *QueryMeta, error) {
-	return h.service(service, tags, passingOnly, q, true)
+	return h.service(service, tags, passingOnly, false, q, true)
 }
 
-func (h *Health) service(service string, tags []string, passingOnly bool, q *QueryOptions, connect bool) ([]*ServiceEntry, *QueryMeta, error) {
+func (h *Health) service(service string, tags []string, passingOnly, summary bool, q *QueryOptions, connect bool) ([]*ServiceEntry, *QueryMeta, error) {
 	path := "/v1/health/service/" + service
 	if connect {
 		path = "/v1/health/connect/" + service
@@ -202,6 +240,9 @@ func (h *Health) service(service string, tags []string, passingOnly bool, q *Que
 	if passingOnly {
 		r.params.Set(HealthPassing, "1")
 	}
+	if summary {
+		r.params.Set("summary", "1")
+	}
 	rtt, resp, err := requireOK(h.c.doRequest(r))
 	if err != nil {
 		return nil, nil, err
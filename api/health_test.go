@@ -283,6 +283,60 @@ func TestAPI_HealthService(t *testing.T) {
 	})
 }
 
+func TestAPI_HealthNearest(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	health := c.Health()
+	retry.Run(t, func(r *retry.R) {
+		services, meta, err := health.Nearest("consul", 1, nil)
+		if err != nil {
+			r.Fatal(err)
+		}
+		if meta.LastIndex == 0 {
+			r.Fatalf("bad: %v", meta)
+		}
+		if len(services) != 1 {
+			r.Fatalf("Bad: %v", services)
+		}
+		if meta.ResultsTruncated {
+			r.Fatalf("bad: %v", meta)
+		}
+	})
+
+	services, meta, err := health.Nearest("consul", 1, &QueryOptions{Near: "nope"})
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	require.NotZero(t, meta.LastIndex)
+}
+
+func TestAPI_HealthServiceSummary(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	health := c.Health()
+	retry.Run(t, func(r *retry.R) {
+		services, meta, err := health.ServiceSummary("consul", "", true, nil)
+		if err != nil {
+			r.Fatal(err)
+		}
+		if meta.LastIndex == 0 {
+			r.Fatalf("bad: %v", meta)
+		}
+		if len(services) == 0 {
+			r.Fatalf("Bad: %v", services)
+		}
+		if len(services[0].Checks) != 0 {
+			r.Fatalf("Bad: %v", services[0].Checks)
+		}
+		if services[0].Status != HealthPassing {
+			r.Fatalf("Bad: %v", services[0].Status)
+		}
+	})
+}
+
 func TestAPI_HealthService_SingleTag(t *testing.T) {
 	t.Parallel()
 	c, s := makeClientWithConfig(t, nil, func(conf *testutil.TestServerConfig) {
@@ -45,6 +45,23 @@ type KVPair struct {
 // KVPairs is a list of KVPair objects
 type KVPairs []*KVPair
 
+// KVDiff is the set of changes to the K/V entries under a prefix since a
+// given index, as returned by ListDiff.
+type KVDiff struct {
+	// Entries holds the entries that were created or modified. If Resync is
+	// set, it holds every entry under the prefix instead.
+	Entries KVPairs
+
+	// Removed holds the keys that were deleted.
+	Removed []string
+
+	// Resync is set when the changes couldn't be computed, either because
+	// no index was given or because the server no longer tracks deletions
+	// that old. The caller should replace its copy of the entries with
+	// Entries.
+	Resync bool
+}
+
 // KVOp constants give possible operations available in a KVTxn.
 type KVOp string
 
@@ -133,6 +150,26 @@ func (k *KV) List(prefix string, q *QueryOptions) (KVPairs, *QueryMeta, error) {
 	return entries, qm, nil
 }
 
+// ListDiff is used to get the changes to the entries under a prefix since
+// q.WaitIndex, rather than the full list. This is useful for watching large
+// prefixes with blocking queries. A zero WaitIndex always results in a resync.
+func (k *KV) ListDiff(prefix string, q *QueryOptions) (*KVDiff, *QueryMeta, error) {
+	resp, qm, err := k.getInternal(prefix, map[string]string{"recurse": "", "diff": ""}, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return nil, qm, nil
+	}
+	defer resp.Body.Close()
+
+	var diff KVDiff
+	if err := decodeBody(resp, &diff); err != nil {
+		return nil, nil, err
+	}
+	return &diff, qm, nil
+}
+
 // Keys is used to list all the keys under a prefix. Optionally,
 // a separator can be used to limit the responses.
 func (k *KV) Keys(prefix, separator string, q *QueryOptions) ([]string, *QueryMeta, error) {
@@ -156,6 +193,25 @@ func (k *KV) Keys(prefix, separator string, q *QueryOptions) ([]string, *QueryMe
 	return entries, qm, nil
 }
 
+// LockQueue returns the sessions waiting in line to acquire the given key
+// with AcquireQueued, in the order they'll get it.
+func (k *KV) LockQueue(key string, q *QueryOptions) ([]string, *QueryMeta, error) {
+	resp, qm, err := k.getInternal(key, map[string]string{"lock-queue": ""}, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return nil, qm, nil
+	}
+	defer resp.Body.Close()
+
+	var sessions []string
+	if err := decodeBody(resp, &sessions); err != nil {
+		return nil, nil, err
+	}
+	return sessions, qm, nil
+}
+
 func (k *KV) getInternal(key string, params map[string]string, q *QueryOptions) (*http.Response, *QueryMeta, error) {
 	r := k.c.newRequest("GET", "/v1/kv/"+strings.TrimPrefix(key, "/"))
 	r.setQueryOptions(q)
@@ -216,6 +272,20 @@ func (k *KV) Acquire(p *KVPair, q *WriteOptions) (bool, *WriteMeta, error) {
 	return k.put(p.Key, params, p.Value, q)
 }
 
+// AcquireQueued is like Acquire, but the session waits in line for the key
+// behind the sessions that tried to acquire it with AcquireQueued before.
+// It returns false until it's the session's turn, so it should be retried
+// whenever the key changes.
+func (k *KV) AcquireQueued(p *KVPair, q *WriteOptions) (bool, *WriteMeta, error) {
+	params := make(map[string]string, 3)
+	if p.Flags != 0 {
+		params["flags"] = strconv.FormatUint(p.Flags, 10)
+	}
+	params["acquire"] = p.Session
+	params["queue"] = ""
+	return k.put(p.Key, params, p.Value, q)
+}
+
 // Release is used for a lock release operation. The Key,
 // Flags, Value and Session are respected. Returns true
 // on success or false on failures.
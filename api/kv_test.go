@@ -337,6 +337,75 @@ func TestAPI_ClientWatchList(t *testing.T) {
 	<-doneCh
 }
 
+func TestAPI_ClientListDiff(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	kv := c.KV()
+
+	prefix := testKey()
+	keys := []string{path.Join(prefix, "a"), path.Join(prefix, "b"), path.Join(prefix, "c")}
+	for _, key := range keys {
+		if _, err := kv.Put(&KVPair{Key: key, Value: []byte("test")}, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Without an index the full list comes back as a resync.
+	diff, meta, err := kv.ListDiff(prefix, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !diff.Resync || len(diff.Entries) != 3 || len(diff.Removed) != 0 {
+		t.Fatalf("bad: %#v", diff)
+	}
+
+	// Change one key and delete another while blocking.
+	doneCh := make(chan error, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if _, err := kv.Put(&KVPair{Key: keys[0], Value: []byte("new")}, nil); err != nil {
+			doneCh <- err
+			return
+		}
+		_, err := kv.Delete(keys[1], nil)
+		doneCh <- err
+	}()
+
+	var updated []string
+	removed := make(map[string]bool)
+	for len(updated) == 0 || len(removed) == 0 {
+		options := &QueryOptions{WaitIndex: meta.LastIndex}
+		diff, meta, err = kv.ListDiff(prefix, options)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if diff.Resync {
+			t.Fatalf("unexpected resync")
+		}
+		for _, pair := range diff.Entries {
+			if !bytes.Equal(pair.Value, []byte("new")) {
+				t.Fatalf("unexpected value: %#v", pair)
+			}
+			updated = append(updated, pair.Key)
+		}
+		for _, key := range diff.Removed {
+			removed[key] = true
+		}
+	}
+	if len(updated) != 1 || updated[0] != keys[0] {
+		t.Fatalf("bad: %v", updated)
+	}
+	if len(removed) != 1 || !removed[keys[1]] {
+		t.Fatalf("bad: %v", removed)
+	}
+
+	if err := <-doneCh; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestAPI_ClientKeys_DeleteRecurse(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -456,6 +525,66 @@ func TestAPI_ClientAcquireRelease(t *testing.T) {
 	}
 }
 
+func TestAPI_ClientAcquireQueued(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	session := c.Session()
+	kv := c.KV()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, _, err := session.CreateNoChecks(nil, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer session.Destroy(id, nil)
+		ids = append(ids, id)
+	}
+
+	// The first session takes the lock and the others get in line.
+	key := testKey()
+	for i, id := range ids {
+		work, _, err := kv.AcquireQueued(&KVPair{Key: key, Session: id}, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if work != (i == 0) {
+			t.Fatalf("bad: %d %v", i, work)
+		}
+	}
+	queue, _, err := kv.LockQueue(key, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(queue) != 2 || queue[0] != ids[1] || queue[1] != ids[2] {
+		t.Fatalf("bad: %v", queue)
+	}
+
+	// Once it's released only the next one in line gets it.
+	if _, _, err := kv.Release(&KVPair{Key: key, Session: ids[0]}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if work, _, err := kv.AcquireQueued(&KVPair{Key: key, Session: ids[2]}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	} else if work {
+		t.Fatalf("should not get the lock out of turn")
+	}
+	if work, _, err := kv.AcquireQueued(&KVPair{Key: key, Session: ids[1]}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !work {
+		t.Fatalf("Lock failure")
+	}
+	queue, _, err = kv.LockQueue(key, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(queue) != 1 || queue[0] != ids[2] {
+		t.Fatalf("bad: %v", queue)
+	}
+}
+
 func TestAPI_ClientTxn(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
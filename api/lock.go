@@ -77,8 +77,11 @@ type LockOptions struct {
 	SessionTTL       string        // Optional, defaults to DefaultLockSessionTTL (ignored if SessionOpts is given)
 	MonitorRetries   int           // Optional, defaults to 0 which means no retries
 	MonitorRetryTime time.Duration // Optional, defaults to DefaultMonitorRetryTime
+	LockDelay        time.Duration // Optional, lock-delay for a created session (ignored if SessionOpts is given)
 	LockWaitTime     time.Duration // Optional, defaults to DefaultLockWaitTime
+	LockRetryTime    time.Duration // Optional, defaults to DefaultLockRetryTime
 	LockTryOnce      bool          // Optional, defaults to false which means try forever
+	LockQueue        bool          // Optional, defaults to false which means contenders race for the lock
 }
 
 // LockKey returns a handle to a lock struct which can be used
@@ -114,6 +117,12 @@ func (c *Client) LockOpts(opts *LockOptions) (*Lock, error) {
 	if opts.LockWaitTime == 0 {
 		opts.LockWaitTime = DefaultLockWaitTime
 	}
+	if opts.LockRetryTime == 0 {
+		opts.LockRetryTime = DefaultLockRetryTime
+	}
+	if opts.LockDelay < 0 {
+		return nil, fmt.Errorf("invalid LockDelay: %v", opts.LockDelay)
+	}
 	l := &Lock{
 		c:    c,
 		opts: opts,
@@ -202,14 +211,20 @@ WAIT:
 	if pair != nil && pair.Session == l.lockSession {
 		goto HELD
 	}
-	if pair != nil && pair.Session != "" {
+	// Queued contenders try to acquire the lock right away, even if it's
+	// held, so they get in line.
+	if pair != nil && pair.Session != "" && !(l.opts.LockQueue && attempts == 1) {
 		qOpts.WaitIndex = meta.LastIndex
 		goto WAIT
 	}
 
 	// Try to acquire the lock
 	pair = l.lockEntry(l.lockSession)
-	locked, _, err = kv.Acquire(pair, nil)
+	if l.opts.LockQueue {
+		locked, _, err = kv.AcquireQueued(pair, nil)
+	} else {
+		locked, _, err = kv.Acquire(pair, nil)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to acquire lock: %v", err)
 	}
@@ -219,16 +234,25 @@ WAIT:
 		// Determine why the lock failed
 		qOpts.WaitIndex = 0
 		pair, meta, err = kv.Get(l.opts.Key, qOpts)
-		if pair != nil && pair.Session != "" {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock: %v", err)
+		}
+		if pair != nil && (pair.Session != "" || l.opts.LockQueue) {
 			//If the session is not null, this means that a wait can safely happen
-			//using a long poll
+			//using a long poll. Queued contenders also wait for the key to
+			//change while the sessions ahead of them take the lock.
 			qOpts.WaitIndex = meta.LastIndex
 			goto WAIT
 		} else {
 			// If the session is empty and the lock failed to acquire, then it means
-			// a lock-delay is in effect and a timed wait must be used
+			// a lock-delay is in effect and a timed wait must be used, for as
+			// long as the servers say it lasts if they do
+			wait := l.opts.LockRetryTime
+			if meta.LockDelay > 0 {
+				wait = meta.LockDelay
+			}
 			select {
-			case <-time.After(DefaultLockRetryTime):
+			case <-time.After(wait):
 				goto WAIT
 			case <-stopCh:
 				return nil, nil
@@ -335,8 +359,9 @@ func (l *Lock) createSession() (string, error) {
 	se := l.opts.SessionOpts
 	if se == nil {
 		se = &SessionEntry{
-			Name: l.opts.SessionName,
-			TTL:  l.opts.SessionTTL,
+			Name:      l.opts.SessionName,
+			TTL:       l.opts.SessionTTL,
+			LockDelay: l.opts.LockDelay,
 		}
 	}
 	id, _, err := session.Create(se, nil)
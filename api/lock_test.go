@@ -580,3 +580,81 @@ func TestAPI_LockOneShot(t *testing.T) {
 		t.Fatalf("should be leader")
 	}
 }
+
+func TestAPI_LockDelay(t *testing.T) {
+	t.Parallel()
+	c, s := makeClientWithoutConnect(t)
+	defer s.Stop()
+
+	s.WaitForSerfCheck(t)
+
+	opts := &LockOptions{
+		Key:       "test/lock",
+		LockDelay: time.Second,
+	}
+	lock, err := c.LockOpts(opts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Make sure the default got set.
+	if lock.opts.LockRetryTime != DefaultLockRetryTime {
+		t.Fatalf("bad: %d", lock.opts.LockRetryTime)
+	}
+
+	// Should get the lock.
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh == nil {
+		t.Fatalf("not leader")
+	}
+
+	// Invalidate the session out from under the lock, which puts the
+	// lock-delay into effect.
+	if _, err := c.Session().Destroy(lock.lockSession, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case <-leaderCh:
+	case <-time.After(time.Second):
+		t.Fatalf("should not be leader")
+	}
+
+	// A contender with a short retry time should get the lock once the
+	// lock-delay expires.
+	contender, err := c.LockOpts(&LockOptions{
+		Key:           "test/lock",
+		LockRetryTime: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	start := time.Now()
+	ch, err := contender.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ch == nil {
+		t.Fatalf("should be leader")
+	}
+	defer contender.Unlock()
+	if diff := time.Since(start); diff > DefaultLockRetryTime {
+		t.Fatalf("time out of bounds: %9.6f", diff.Seconds())
+	}
+}
+
+func TestAPI_LockBadLockDelay(t *testing.T) {
+	t.Parallel()
+	c, s := makeClientWithoutConnect(t)
+	defer s.Stop()
+
+	_, err := c.LockOpts(&LockOptions{
+		Key:       "test/lock",
+		LockDelay: -time.Second,
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid LockDelay") {
+		t.Fatalf("err: %v", err)
+	}
+}
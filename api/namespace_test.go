@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_Namespaces(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	namespaces := c.Namespaces()
+
+	// The default namespace always exists.
+	list, _, err := namespaces.List(nil)
+	require.NoError(err)
+	require.Len(list, 1)
+	require.Equal(NamespaceDefault, list[0].Name)
+
+	// Create a namespace and read it back.
+	_, err = namespaces.Write(&Namespace{Name: "eng", Description: "Engineering"}, nil)
+	require.NoError(err)
+
+	ns, _, err := namespaces.Read("eng", nil)
+	require.NoError(err)
+	require.NotNil(ns)
+	require.Equal("Engineering", ns.Description)
+	require.NotZero(ns.CreateIndex)
+
+	list, _, err = namespaces.List(nil)
+	require.NoError(err)
+	require.Len(list, 2)
+
+	// Invalid names are rejected.
+	_, err = namespaces.Write(&Namespace{Name: "-bad"}, nil)
+	require.Error(err)
+
+	// Delete it.
+	_, err = namespaces.Delete("eng", nil)
+	require.NoError(err)
+	ns, _, err = namespaces.Read("eng", nil)
+	require.NoError(err)
+	require.Nil(ns)
+
+	// The default namespace can't be deleted.
+	_, err = namespaces.Delete(NamespaceDefault, nil)
+	require.Error(err)
+}
@@ -105,6 +105,14 @@ type OperatorHealthReply struct {
 
 	// Servers holds the health of each server.
 	Servers []ServerHealth
+
+	// RecentLeaderElections is the number of leader elections the leader
+	// has seen within the leader flap detection window.
+	RecentLeaderElections int
+
+	// LeaderFlapping is true if RecentLeaderElections has reached the
+	// leader flap threshold.
+	LeaderFlapping bool
 }
 
 // ReadableDuration is a duration type that is serialized to JSON in human readable format.
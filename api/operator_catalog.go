@@ -0,0 +1,120 @@
+package api
+
+import (
+	"time"
+)
+
+// CatalogOrphan identifies a service or check registered against a node (or
+// service) that doesn't exist.
+type CatalogOrphan struct {
+	// Node is the name of the node the entry is registered against.
+	Node string
+
+	// ID is the service or check ID.
+	ID string
+}
+
+// CatalogConsistencyReport has the findings of the last catalog consistency
+// check run by the leader.
+type CatalogConsistencyReport struct {
+	// LastRun is when the check last completed. This is zero if the check
+	// hasn't run since the current leader was elected.
+	LastRun time.Time
+
+	// UnregisteredMembers are the alive Serf members that have no node in
+	// the catalog.
+	UnregisteredMembers []string
+
+	// StaleNodes are the catalog nodes with a Serf health check that are no
+	// longer Serf members.
+	StaleNodes []string
+
+	// OrphanedServices are the services registered against nodes that don't
+	// exist.
+	OrphanedServices []CatalogOrphan
+
+	// OrphanedChecks are the checks registered against nodes or services
+	// that don't exist.
+	OrphanedChecks []CatalogOrphan
+
+	// Repaired is the number of orphaned services and checks that were
+	// removed from the catalog.
+	Repaired int
+}
+
+// CatalogConsistency is used to query the findings of the last catalog
+// consistency check.
+func (op *Operator) CatalogConsistency(q *QueryOptions) (*CatalogConsistencyReport, error) {
+	r := op.c.newRequest("GET", "/v1/operator/catalog/consistency")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out CatalogConsistencyReport
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CatalogExportVersion is the version of the catalog export schema this
+// client understands.
+const CatalogExportVersion = 1
+
+// CatalogExport is a point-in-time export of the catalog and KV store of a
+// datacenter.
+type CatalogExport struct {
+	// Version is the version of the export schema.
+	Version int
+
+	// Datacenter is the datacenter that was exported.
+	Datacenter string
+
+	// Index is the Raft index the export is consistent as of.
+	Index uint64
+
+	// Nodes are the nodes in the catalog, with their services and checks.
+	Nodes []*CatalogExportNode
+
+	// KV are the entries in the KV store.
+	KV []*CatalogExportKV
+}
+
+// CatalogExportNode is a node in a catalog export.
+type CatalogExportNode struct {
+	ID              string
+	Node            string
+	Address         string
+	TaggedAddresses map[string]string
+	Meta            map[string]string
+	Services        []*AgentService
+	Checks          HealthChecks
+}
+
+// CatalogExportKV is a KV entry in a catalog export.
+type CatalogExportKV struct {
+	Key   string
+	Flags uint64
+	Value []byte
+}
+
+// CatalogExport is used to export the catalog and KV store. This requires
+// the same ACL permissions as taking a snapshot.
+func (op *Operator) CatalogExport(q *QueryOptions) (*CatalogExport, error) {
+	r := op.c.newRequest("GET", "/v1/operator/catalog/export")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out CatalogExport
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestAPI_OperatorCatalogConsistency(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	// The check runs every few minutes by default, so it won't have run
+	// yet but the report should still be returned.
+	operator := c.Operator()
+	out, err := operator.CatalogConsistency(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !out.LastRun.IsZero() || out.Repaired != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
+func TestAPI_OperatorCatalogExport(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	s.WaitForSerfCheck(t)
+	if _, err := c.KV().Put(&KVPair{Key: "foo", Value: []byte("bar"), Flags: 42}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := c.Operator().CatalogExport(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Version != CatalogExportVersion || out.Datacenter != "dc1" || out.Index == 0 {
+		t.Fatalf("bad: %v", out)
+	}
+	if len(out.Nodes) != 1 || out.Nodes[0].Node != s.Config.NodeName || len(out.Nodes[0].Checks) == 0 {
+		t.Fatalf("bad: %v", out.Nodes)
+	}
+	if len(out.KV) != 1 || out.KV[0].Key != "foo" || string(out.KV[0].Value) != "bar" || out.KV[0].Flags != 42 {
+		t.Fatalf("bad: %v", out.KV)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"time"
+)
+
+// NodeClockSkew is how far a node's clock is from the leader's.
+type NodeClockSkew struct {
+	Node string
+
+	// Skew is how far the node's clock is ahead of the leader's, or behind
+	// it if it's negative.
+	Skew time.Duration
+
+	// Measured is when the skew was last measured, by the leader's clock.
+	Measured time.Time
+
+	// Exceeded is true if the skew is over the threshold.
+	Exceeded bool
+}
+
+// ClockSkewReport has the clock skew of the nodes that sent a coordinate
+// update since the current leader was elected.
+type ClockSkewReport struct {
+	// Leader is the name of the leader the skew is measured against.
+	Leader string
+
+	// Threshold is the skew over which the leader warns about a node, or
+	// zero if it doesn't.
+	Threshold time.Duration
+
+	// Nodes has the skew of each node, largest first.
+	Nodes []NodeClockSkew
+}
+
+// ClockSkew is used to query how far the clocks of the nodes are from the
+// leader's.
+func (op *Operator) ClockSkew(q *QueryOptions) (*ClockSkewReport, error) {
+	r := op.c.newRequest("GET", "/v1/operator/clock-skew")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out ClockSkewReport
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
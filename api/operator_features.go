@@ -0,0 +1,48 @@
+package api
+
+// ServerFeatureBlocker is a server that doesn't support a feature.
+type ServerFeatureBlocker struct {
+	Node    string
+	Address string
+	Version string
+}
+
+// ServerFeature reports whether every server in a datacenter supports one of
+// the optional protocol features.
+type ServerFeature struct {
+	// Name is the name the feature is advertised with.
+	Name string
+
+	// GatesRaftWrites is true if the feature is needed to apply some of the
+	// FSM message types, which the leader won't write until it's enabled.
+	GatesRaftWrites bool
+
+	// Enabled is true if every server supports the feature, and BlockedBy
+	// has the servers that don't otherwise.
+	Enabled   bool
+	BlockedBy []ServerFeatureBlocker
+}
+
+// ServerFeaturesResponse has the optional protocol features of a
+// datacenter's servers.
+type ServerFeaturesResponse struct {
+	Features []ServerFeature
+}
+
+// ServerFeatures is used to query which of the optional protocol features
+// are enabled, and which servers are holding back the others.
+func (op *Operator) ServerFeatures(q *QueryOptions) (*ServerFeaturesResponse, error) {
+	r := op.c.newRequest("GET", "/v1/operator/features")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out ServerFeaturesResponse
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestAPI_OperatorServerFeatures(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	out, err := c.Operator().ServerFeatures(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Features) == 0 {
+		t.Fatalf("bad: %v", out)
+	}
+	for _, feature := range out.Features {
+		if !feature.Enabled || len(feature.BlockedBy) != 0 {
+			t.Fatalf("bad: %v", feature)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"time"
+)
+
+// KVReplicationStatus is the status of KV replication from another
+// datacenter, as seen by the leader.
+type KVReplicationStatus struct {
+	// Enabled is true if KV replication is configured, and Running is true
+	// while the leader is replicating.
+	Enabled bool
+	Running bool
+
+	// SourceDatacenter is the datacenter keys are replicated from, and
+	// Prefix is the replicated key prefix.
+	SourceDatacenter string
+	Prefix           string
+
+	// ReplicatedIndex is the index in the source datacenter that the local
+	// keys were last brought up to date with.
+	ReplicatedIndex uint64
+
+	// LastSuccess and LastError are when replication last succeeded and
+	// failed, and LastErrorMessage is the reason for the last failure.
+	LastSuccess      time.Time
+	LastError        time.Time
+	LastErrorMessage string
+
+	// Lag is how far behind the source datacenter the last replication
+	// round was.
+	Lag time.Duration
+}
+
+// KVReplicationStatus is used to query the status of KV replication from
+// another datacenter.
+func (op *Operator) KVReplicationStatus(q *QueryOptions) (*KVReplicationStatus, error) {
+	r := op.c.newRequest("GET", "/v1/operator/kv/replication")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out KVReplicationStatus
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
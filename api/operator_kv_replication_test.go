@@ -0,0 +1,22 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestAPI_OperatorKVReplicationStatus(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	// Replication isn't configured, but the status should still be
+	// returned.
+	operator := c.Operator()
+	out, err := operator.KVReplicationStatus(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Enabled || out.Running || out.ReplicatedIndex != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // RaftServer has information about a server in the Raft configuration.
 type RaftServer struct {
 	// ID is the unique ID for the server. These are currently the same
@@ -87,3 +91,83 @@ func (op *Operator) RaftRemovePeerByID(id string, q *WriteOptions) error {
 	resp.Body.Close()
 	return nil
 }
+
+// RaftVerification is returned when verifying a server's Raft log and latest
+// snapshot.
+type RaftVerification struct {
+	// Node is the name of the server that did the verification.
+	Node string
+
+	// FirstIndex and LastIndex are the range of the Raft log that was
+	// verified. These are zero if the log is empty.
+	FirstIndex uint64
+	LastIndex  uint64
+
+	// SnapshotID and SnapshotIndex identify the snapshot that was verified.
+	// These are empty if the server has no snapshot yet.
+	SnapshotID    string
+	SnapshotIndex uint64
+}
+
+// RaftVerify is used to check the Raft log and latest snapshot on disk for
+// corruption. This checks the leader, or the server the agent talks to if
+// the query allows stale results. An error is returned if verification fails.
+func (op *Operator) RaftVerify(q *QueryOptions) (*RaftVerification, error) {
+	r := op.c.newRequest("GET", "/v1/operator/raft/verify")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out RaftVerification
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LeaderTransition is a change of leader, as recorded by the new leader.
+type LeaderTransition struct {
+	// Time is when the new leader established its leadership, by its own
+	// clock.
+	Time time.Time
+
+	// Term is the Raft term the new leader was elected in.
+	Term uint64
+
+	// Leader is the name of the new leader, and PreviousLeader is the name
+	// of the leader before it, if it's known.
+	Leader         string
+	PreviousLeader string
+
+	// Reason is what the new leader found out about the previous one, if
+	// anything.
+	Reason string
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// RaftLeaderHistory is used to query the recorded leader transitions, newest
+// first.
+func (op *Operator) RaftLeaderHistory(q *QueryOptions) ([]*LeaderTransition, *QueryMeta, error) {
+	r := op.c.newRequest("GET", "/v1/operator/raft/leader-history")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out []*LeaderTransition
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return out, qm, nil
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"strings"
 	"testing"
+
+	"github.com/hashicorp/consul/testutil/retry"
 )
 
 func TestAPI_OperatorRaftGetConfiguration(t *testing.T) {
@@ -36,3 +38,39 @@ func TestAPI_OperatorRaftRemovePeerByAddress(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestAPI_OperatorRaftVerify(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	operator := c.Operator()
+	out, err := operator.RaftVerify(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Node == "" || out.LastIndex == 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
+func TestAPI_OperatorRaftLeaderHistory(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	// The leader records its election once it's established leadership.
+	operator := c.Operator()
+	retry.Run(t, func(r *retry.R) {
+		out, qm, err := operator.RaftLeaderHistory(nil)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if len(out) != 1 || out[0].Leader != s.Config.NodeName || out[0].Term == 0 {
+			r.Fatalf("bad: %v", out)
+		}
+		if qm.LastIndex == 0 {
+			r.Fatalf("bad: %v", qm)
+		}
+	})
+}
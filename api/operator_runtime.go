@@ -0,0 +1,64 @@
+package api
+
+// RuntimeSettings are the settings of an agent that can be changed while
+// it's running. When setting them, only the fields that are set are changed.
+// The server settings are left out for clients.
+type RuntimeSettings struct {
+	// LogLevel is the minimum level of the messages that are logged.
+	LogLevel string `json:",omitempty"`
+
+	// SlowQueryThreshold is how long a state store query can take before
+	// it's logged as slow.
+	SlowQueryThreshold *ReadableDuration `json:",omitempty"`
+
+	// MaxQueryTime is the longest a blocking query can wait for a change.
+	MaxQueryTime *ReadableDuration `json:",omitempty"`
+
+	// RPCServerReadRate, RPCServerWriteRate and RPCServerTokenRate are the
+	// limits on the rate of RPC requests, in requests per second. Zero
+	// means no limit.
+	RPCServerReadRate  *float64 `json:",omitempty"`
+	RPCServerWriteRate *float64 `json:",omitempty"`
+	RPCServerTokenRate *float64 `json:",omitempty"`
+
+	// RPCServerMaxBurst is the burst size of the RPC rate limits.
+	RPCServerMaxBurst *int `json:",omitempty"`
+}
+
+// RuntimeSettings returns the runtime settings of the agent the request is
+// made to.
+func (op *Operator) RuntimeSettings(q *QueryOptions) (*RuntimeSettings, error) {
+	r := op.c.newRequest("GET", "/v1/operator/runtime")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out RuntimeSettings
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetRuntimeSettings changes the runtime settings of the agent the request
+// is made to, and returns all of them after the change. The changes are lost
+// when the agent restarts.
+func (op *Operator) SetRuntimeSettings(settings *RuntimeSettings, q *WriteOptions) (*RuntimeSettings, error) {
+	r := op.c.newRequest("PUT", "/v1/operator/runtime")
+	r.setWriteOptions(q)
+	r.obj = settings
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out RuntimeSettings
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
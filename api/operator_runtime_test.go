@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_OperatorRuntimeSettings(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	operator := c.Operator()
+	settings, err := operator.RuntimeSettings(nil)
+	require.NoError(t, err)
+	require.NotNil(t, settings.MaxQueryTime)
+	require.NotNil(t, settings.RPCServerMaxBurst)
+
+	burst := 50
+	out, err := operator.SetRuntimeSettings(&RuntimeSettings{
+		SlowQueryThreshold: NewReadableDuration(250 * time.Millisecond),
+		RPCServerMaxBurst:  &burst,
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 250*time.Millisecond, out.SlowQueryThreshold.Duration())
+	require.Equal(t, 50, *out.RPCServerMaxBurst)
+	require.Equal(t, settings.LogLevel, out.LogLevel)
+	require.Equal(t, settings.MaxQueryTime.Duration(), out.MaxQueryTime.Duration())
+
+	settings, err = operator.RuntimeSettings(nil)
+	require.NoError(t, err)
+	require.Equal(t, out, settings)
+}
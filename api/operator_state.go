@@ -0,0 +1,58 @@
+package api
+
+import (
+	"time"
+)
+
+// StateTableMemory is the estimated memory used by the entries of one state
+// store table.
+type StateTableMemory struct {
+	// Table is the name of the table, and Entries is how many entries it
+	// has.
+	Table   string
+	Entries int
+
+	// Bytes is the estimated size of the entries, based on their encoded
+	// size. It doesn't include the overhead of the table's indexes.
+	Bytes int64
+}
+
+// StateMemoryReport has the state store memory usage found by the last
+// accounting run on the leader.
+type StateMemoryReport struct {
+	// LastRun is when the accounting last completed. This is zero if it
+	// hasn't run since the current leader was elected.
+	LastRun time.Time
+
+	// Tables has the usage of each of the accounted tables, and TotalBytes
+	// is the sum of them.
+	Tables     []StateTableMemory
+	TotalBytes int64
+
+	// Budget is the configured memory budget in bytes, or zero if writes
+	// aren't limited.
+	Budget int64
+
+	// Rejected is the number of writes rejected because they would have
+	// taken the state store over the budget since the current leader was
+	// elected.
+	Rejected uint64
+}
+
+// StateMemory is used to query the state store memory usage found by the
+// last accounting run.
+func (op *Operator) StateMemory(q *QueryOptions) (*StateMemoryReport, error) {
+	r := op.c.newRequest("GET", "/v1/operator/state/memory")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out StateMemoryReport
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/testutil/retry"
+)
+
+func TestAPI_OperatorStateMemory(t *testing.T) {
+	t.Parallel()
+	c, s := makeClientWithConfig(t, nil, func(conf *testutil.TestServerConfig) {
+		conf.Limits = &testutil.TestLimitsConfig{StateMemoryBudgetMB: 64}
+	})
+	defer s.Stop()
+
+	// The leader measures the state store as soon as it's elected.
+	operator := c.Operator()
+	retry.Run(t, func(r *retry.R) {
+		out, err := operator.StateMemory(nil)
+		if err != nil {
+			r.Fatalf("err: %v", err)
+		}
+		if out.LastRun.IsZero() || len(out.Tables) != 4 {
+			r.Fatalf("bad: %v", out)
+		}
+		if out.Budget != 64*1024*1024 || out.Rejected != 0 {
+			r.Fatalf("bad: %v", out)
+		}
+	})
+}
+
+func TestAPI_OperatorStateMemory_NoBudget(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	// There's nothing to report without a budget.
+	_, err := c.Operator().StateMemory(nil)
+	if err == nil || !strings.Contains(err.Error(), "accounting is disabled") {
+		t.Fatalf("err: %v", err)
+	}
+}
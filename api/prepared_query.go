@@ -62,6 +62,10 @@ type ServiceQuery struct {
 	// to the _proxy_ and not the service being proxied. In practice, proxies
 	// should be directly next to their services so this isn't an issue.
 	Connect bool
+
+	// PinTTL, if set, makes the query return the same healthy subset of
+	// results to a source node for this long.
+	PinTTL string
 }
 
 // QueryTemplate carries the arguments for creating a templated query.
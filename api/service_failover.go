@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ServiceFailover is the failover policy of a service. When the local
+// datacenter has no healthy instances of the service, health queries for it
+// are answered from the first of the policy's datacenters that has some.
+type ServiceFailover struct {
+	// Service is the name of the service the policy applies to.
+	Service string
+
+	// Failover sets the datacenters to try, in the same way as the failover
+	// options of a prepared query.
+	Failover QueryDatacenterOptions
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// ServiceFailovers can be used to manage the failover policies of services.
+type ServiceFailovers struct {
+	c *Client
+}
+
+// ServiceFailovers returns a handle to the service failover endpoints.
+func (c *Client) ServiceFailovers() *ServiceFailovers {
+	return &ServiceFailovers{c}
+}
+
+// List returns the failover policies of all the services.
+func (f *ServiceFailovers) List(q *QueryOptions) ([]*ServiceFailover, *QueryMeta, error) {
+	r := f.c.newRequest("GET", "/v1/service-failovers")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(f.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out []*ServiceFailover
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return out, qm, nil
+}
+
+// Read returns the failover policy of the given service, or nil if it doesn't
+// have one.
+func (f *ServiceFailovers) Read(service string, q *QueryOptions) (*ServiceFailover, *QueryMeta, error) {
+	r := f.c.newRequest("GET", "/v1/service-failover/"+service)
+	r.setQueryOptions(q)
+	rtt, resp, err := f.c.doRequest(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	if resp.StatusCode == 404 {
+		return nil, qm, nil
+	} else if resp.StatusCode != 200 {
+		var buf bytes.Buffer
+		io.Copy(&buf, resp.Body)
+		return nil, nil, fmt.Errorf(
+			"Unexpected response %d: %s", resp.StatusCode, buf.String())
+	}
+
+	var out ServiceFailover
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return &out, qm, nil
+}
+
+// Write creates or updates the failover policy of a service.
+func (f *ServiceFailovers) Write(failover *ServiceFailover, q *WriteOptions) (*WriteMeta, error) {
+	r := f.c.newRequest("PUT", "/v1/service-failover/"+failover.Service)
+	r.setWriteOptions(q)
+	r.obj = failover
+	rtt, resp, err := requireOK(f.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+	return wm, nil
+}
+
+// Delete deletes the failover policy of a service.
+func (f *ServiceFailovers) Delete(service string, q *WriteOptions) (*WriteMeta, error) {
+	r := f.c.newRequest("DELETE", "/v1/service-failover/"+service)
+	r.setWriteOptions(q)
+	rtt, resp, err := requireOK(f.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{}
+	wm.RequestTime = rtt
+	return wm, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_ServiceFailovers(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	failovers := c.ServiceFailovers()
+
+	list, _, err := failovers.List(nil)
+	require.NoError(err)
+	require.Len(list, 0)
+
+	// Create a policy and read it back.
+	_, err = failovers.Write(&ServiceFailover{
+		Service:  "web",
+		Failover: QueryDatacenterOptions{NearestN: 2, Datacenters: []string{"dc2"}},
+	}, nil)
+	require.NoError(err)
+
+	failover, _, err := failovers.Read("web", nil)
+	require.NoError(err)
+	require.NotNil(failover)
+	require.Equal(2, failover.Failover.NearestN)
+	require.Equal([]string{"dc2"}, failover.Failover.Datacenters)
+	require.NotZero(failover.CreateIndex)
+
+	list, _, err = failovers.List(nil)
+	require.NoError(err)
+	require.Len(list, 1)
+
+	// Policies need somewhere to fail over to.
+	_, err = failovers.Write(&ServiceFailover{Service: "web"}, nil)
+	require.Error(err)
+
+	// Delete it.
+	_, err = failovers.Delete("web", nil)
+	require.NoError(err)
+	failover, _, err = failovers.Read("web", nil)
+	require.NoError(err)
+	require.Nil(failover)
+}
@@ -29,6 +29,7 @@ type SessionEntry struct {
 	LockDelay   time.Duration
 	Behavior    string
 	TTL         string
+	Meta        map[string]string
 }
 
 // Session can be used to query the Session endpoints
@@ -92,6 +93,9 @@ func (s *Session) Create(se *SessionEntry, q *WriteOptions) (string, *WriteMeta,
 		if se.TTL != "" {
 			body["TTL"] = se.TTL
 		}
+		if len(se.Meta) > 0 {
+			body["Meta"] = se.Meta
+		}
 	}
 	return s.create(obj, q)
 }
@@ -433,3 +433,41 @@ func TestAPI_SessionList(t *testing.T) {
 		t.Fatalf("bad: %v", qm)
 	}
 }
+
+func TestAPI_SessionList_Meta(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	s.WaitForSerfCheck(t)
+
+	session := c.Session()
+
+	id, _, err := session.Create(&SessionEntry{Meta: map[string]string{"owner": "ops"}}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer session.Destroy(id, nil)
+
+	other, _, err := session.Create(nil, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer session.Destroy(other, nil)
+
+	info, _, err := session.Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Meta["owner"] != "ops" {
+		t.Fatalf("bad: %v", info)
+	}
+
+	sessions, _, err := session.List(&QueryOptions{SessionMeta: map[string]string{"owner": "ops"}})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != id {
+		t.Fatalf("bad: %v", sessions)
+	}
+}
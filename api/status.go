@@ -41,3 +41,59 @@ func (s *Status) Peers() ([]string, error) {
 	}
 	return peers, nil
 }
+
+// RaftStats are the Raft indexes of a server, which can be compared across the
+// servers to work out how far each is behind the leader.
+type RaftStats struct {
+	ID      string
+	Name    string
+	Address string
+
+	// State is the server's Raft state, like "Leader" or "Follower", and
+	// Term its current term.
+	State string
+	Term  uint64
+
+	// LastContact is the time since the server's last contact with the
+	// leader.
+	LastContact string
+
+	// LastIndex and LastTerm are those of the last entry in the server's
+	// Raft log.
+	LastIndex uint64
+	LastTerm  uint64
+
+	// CommitIndex is the latest index the server knows to be committed,
+	// and AppliedIndex the latest index it has applied.
+	CommitIndex  uint64
+	AppliedIndex uint64
+
+	// LastSnapshotIndex and LastSnapshotTerm are those of the server's
+	// latest snapshot.
+	LastSnapshotIndex uint64
+	LastSnapshotTerm  uint64
+
+	// FSMPending is the number of committed batches waiting to be applied.
+	FSMPending uint64
+
+	// Error is set if the server's stats couldn't be fetched.
+	Error string
+}
+
+// RaftStats is used to query the Raft stats of all the servers in the
+// datacenter. This requires operator:read.
+func (s *Status) RaftStats(q *QueryOptions) ([]*RaftStats, error) {
+	r := s.c.newRequest("GET", "/v1/status/raft-stats")
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(s.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out []*RaftStats
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -37,3 +37,20 @@ func TestAPI_StatusPeers(t *testing.T) {
 		t.Fatalf("Expected peers ")
 	}
 }
+
+func TestAPI_StatusRaftStats(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+	s.WaitForSerfCheck(t)
+
+	status := c.Status()
+
+	stats, err := status.RaftStats(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Error != "" || stats[0].AppliedIndex == 0 {
+		t.Fatalf("bad: %v", stats)
+	}
+}
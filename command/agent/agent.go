@@ -221,6 +221,7 @@ func (c *cmd) run(args []string) int {
 	}
 	agent.LogOutput = logOutput
 	agent.LogWriter = logWriter
+	agent.LogFilter = c.logFilter
 	agent.MemSink = memSink
 
 	if err := agent.Start(); err != nil {
@@ -276,12 +277,23 @@ func (c *cmd) run(args []string) int {
 	signalCh := make(chan os.Signal, 10)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGPIPE)
 
+	// SIGUSR1 also dumps metrics to stderr, which is set up separately.
+	dumpCh := make(chan os.Signal, 1)
+	if len(dumpSignals) > 0 {
+		signal.Notify(dumpCh, dumpSignals...)
+	}
+
 	for {
 		var sig os.Signal
 		var reloadErrCh chan error
 		select {
 		case s := <-signalCh:
 			sig = s
+		case <-dumpCh:
+			if _, _, err := agent.WriteStateDump(); err != nil {
+				c.logger.Println("[ERR] agent: Failed to write state dump: ", err)
+			}
+			continue
 		case ch := <-agent.ReloadCh():
 			sig = syscall.SIGHUP
 			reloadErrCh = ch
@@ -335,7 +347,7 @@ func (c *cmd) run(args []string) int {
 				close(gracefulCh)
 			}()
 
-			gracefulTimeout := 15 * time.Second
+			gracefulTimeout := 15*time.Second + config.LeaveMaintenanceTime
 			select {
 			case <-signalCh:
 				c.logger.Printf("[INFO] agent: Caught second signal %v. Exiting\n", sig)
@@ -0,0 +1,11 @@
+// +build !windows
+
+package agent
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals are the signals which make the agent write a state dump.
+var dumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,11 @@
+// +build windows
+
+package agent
+
+import (
+	"os"
+)
+
+// dumpSignals are the signals which make the agent write a state dump, of
+// which there are none on Windows.
+var dumpSignals = []os.Signal{}
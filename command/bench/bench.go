@@ -0,0 +1,247 @@
+package bench
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/command/flags"
+	"github.com/mitchellh/cli"
+	"github.com/ryanuber/columnize"
+)
+
+const (
+	// workloadKV writes keys, workloadRegister registers nodes with a
+	// service, and workloadBlocking measures how long a blocking query
+	// takes to return after the key it watches is written.
+	workloadKV       = "kv"
+	workloadRegister = "register"
+	workloadBlocking = "blocking"
+
+	// benchDuration is how long the load is generated for by default.
+	benchDuration = 30 * time.Second
+)
+
+// benchWorkloads are the workloads that can be run, in the order they're
+// reported.
+var benchWorkloads = []string{workloadKV, workloadRegister, workloadBlocking}
+
+func New(ui cli.Ui, shutdownCh <-chan struct{}) *cmd {
+	c := &cmd{UI: ui, shutdownCh: shutdownCh}
+	c.init()
+	return c
+}
+
+type cmd struct {
+	UI    cli.Ui
+	flags *flag.FlagSet
+	http  *flags.HTTPFlags
+	help  string
+
+	shutdownCh <-chan struct{}
+
+	// flags
+	workloads   []string
+	duration    time.Duration
+	rate        float64
+	concurrency int
+	keys        int
+	valueSize   int
+	prefix      string
+	cleanup     bool
+}
+
+func (c *cmd) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.Var((*flags.AppendSliceValue)(&c.workloads), "workload",
+		fmt.Sprintf("A workload to run. Possible workloads: %s. This can be "+
+			"repeated to run several workloads at once, and defaults to %q.",
+			strings.Join(benchWorkloads, ", "), workloadKV))
+	c.flags.DurationVar(&c.duration, "duration", benchDuration,
+		fmt.Sprintf("How long to generate load for, at most 10 minutes. Defaults "+
+			"to %s.", benchDuration))
+	c.flags.Float64Var(&c.rate, "rate", 0,
+		"The number of requests per second each workload is limited to. "+
+			"Defaults to 0, which sends requests as fast as the workers can.")
+	c.flags.IntVar(&c.concurrency, "concurrency", 8,
+		"The number of workers sending requests for each workload. Each worker "+
+			"waits for a response before sending its next request. At most 64, "+
+			"defaults to 8.")
+	c.flags.IntVar(&c.keys, "keys", 100,
+		"The number of distinct keys the kv workload writes, and of nodes the "+
+			"register workload registers. At most 10000, defaults to 100.")
+	c.flags.IntVar(&c.valueSize, "value-size", 128,
+		"The size in bytes of the values written to keys. Defaults to 128.")
+	c.flags.StringVar(&c.prefix, "prefix", "consul-bench",
+		"The prefix of the keys written, and of the names of the nodes "+
+			"registered. Defaults to \"consul-bench\".")
+	c.flags.BoolVar(&c.cleanup, "cleanup", true,
+		"Delete the keys and deregister the nodes written by the benchmark "+
+			"once it's done. Defaults to true.")
+
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flags, c.http.ClientFlags())
+	flags.Merge(c.flags, c.http.ServerFlags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *cmd) Run(args []string) int {
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(c.flags.Args()) > 0 {
+		c.UI.Error("Too many arguments (expected 0)")
+		return 1
+	}
+	if len(c.workloads) == 0 {
+		c.workloads = []string{workloadKV}
+	}
+	if err := c.validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("Error: %s", err))
+		return 1
+	}
+
+	client, err := c.http.APIClient()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	// Stop the run early if interrupted, and still report what was
+	// measured. The agent may have finished the run in the meantime, so
+	// a failure to stop it is ignored.
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	go func() {
+		select {
+		case <-c.shutdownCh:
+			client.Agent().BenchStop()
+		case <-doneCh:
+		}
+	}()
+
+	c.UI.Output(fmt.Sprintf("Running %s for %s with %d workers each",
+		strings.Join(c.workloads, ", "), c.duration, c.concurrency))
+	report, err := client.Agent().Bench(&api.AgentBenchOptions{
+		Workloads:   c.workloads,
+		Duration:    c.duration,
+		Rate:        c.rate,
+		Concurrency: c.concurrency,
+		Keys:        c.keys,
+		ValueSize:   c.valueSize,
+		Prefix:      c.prefix,
+		SkipCleanup: !c.cleanup,
+	})
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error running benchmark: %s", err))
+		return 1
+	}
+
+	c.UI.Output(formatReport(report))
+	for _, w := range report.Workloads {
+		if w.FirstError != "" {
+			c.UI.Warn(fmt.Sprintf("First %s error: %s", w.Name, w.FirstError))
+		}
+	}
+	return 0
+}
+
+// validate checks the flags. The agent checks them too, this only saves a
+// round trip for obvious mistakes.
+func (c *cmd) validate() error {
+	for _, w := range c.workloads {
+		valid := false
+		for _, known := range benchWorkloads {
+			if w == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown workload %q, must be one of: %s", w, strings.Join(benchWorkloads, ", "))
+		}
+	}
+	if c.duration <= 0 {
+		return fmt.Errorf("duration must be greater than zero")
+	}
+	if c.rate < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	if c.concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	if c.keys < 1 {
+		return fmt.Errorf("keys must be at least 1")
+	}
+	if c.valueSize < 1 {
+		return fmt.Errorf("value-size must be at least 1")
+	}
+	if c.prefix == "" {
+		return fmt.Errorf("prefix must not be empty")
+	}
+	return nil
+}
+
+// formatReport formats the throughput and latency percentiles of each
+// workload.
+func formatReport(report *api.AgentBenchReport) string {
+	lines := []string{"Workload|Requests|Errors|Rate|p50|p90|p99|Max"}
+	for _, w := range report.Workloads {
+		lines = append(lines, fmt.Sprintf("%s|%d|%d|%.1f/s|%s|%s|%s|%s",
+			w.Name, w.Requests, w.Errors, w.Rate,
+			formatLatency(w, w.P50), formatLatency(w, w.P90),
+			formatLatency(w, w.P99), formatLatency(w, w.Max)))
+	}
+	return columnize.SimpleFormat(lines)
+}
+
+// formatLatency formats a latency of the workload, which are all zero if no
+// request succeeded.
+func formatLatency(w api.AgentBenchWorkload, latency time.Duration) string {
+	if w.Requests == 0 {
+		return "-"
+	}
+	return latency.Round(time.Microsecond).String()
+}
+
+func (c *cmd) Synopsis() string {
+	return synopsis
+}
+
+func (c *cmd) Help() string {
+	return c.help
+}
+
+const synopsis = "Generates load against a cluster and reports latencies"
+const help = `
+Usage: consul bench [options]
+
+  Makes the agent generate registration, KV write and blocking query load
+  against the cluster, and reports the throughput and latency percentiles
+  of each kind of request. This helps size servers before taking them into
+  production.
+
+  The load is real: keys are written and nodes are registered in the
+  datacenter's catalog, and they're removed when the run ends unless
+  -cleanup=false is given. The run is refused if any of them already
+  exist. Don't run it against a cluster that's serving production traffic.
+
+  Write 128 byte values to 100 keys with 8 workers for 30 seconds:
+
+      $ consul bench
+
+  Run all the workloads at once at 500 requests per second each:
+
+      $ consul bench -workload=kv -workload=register -workload=blocking \
+          -rate=500 -duration=2m
+
+  The blocking workload measures how long it takes from the start of a
+  write until a blocking query watching the written key returns, which
+  covers the Raft commit and the notification of the watchers.
+
+  If ACLs are enabled, the token must have agent:write on the agent, and
+  be able to write the keys under the prefix, and to register the nodes
+  and the service named after it.
+`
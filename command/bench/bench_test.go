@@ -0,0 +1,99 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/agent"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testrpc"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchCommand_noTabs(t *testing.T) {
+	t.Parallel()
+	if strings.ContainsRune(New(cli.NewMockUi(), nil).Help(), '\t') {
+		t.Fatal("help has tabs")
+	}
+}
+
+func TestBenchCommand_Validation(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"unknown workload": {
+			[]string{"-workload=nope"},
+			`unknown workload "nope"`,
+		},
+		"no workers": {
+			[]string{"-concurrency=0"},
+			"concurrency must be at least 1",
+		},
+		"negative rate": {
+			[]string{"-rate=-1"},
+			"rate must not be negative",
+		},
+		"too many args": {
+			[]string{"foo"},
+			"Too many arguments",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			c := New(ui, nil)
+			require.Equal(t, 1, c.Run(tc.args))
+			require.Contains(t, ui.ErrorWriter.String(), tc.output)
+		})
+	}
+}
+
+func TestBenchCommand(t *testing.T) {
+	t.Parallel()
+	a := agent.NewTestAgent(t.Name(), ``)
+	defer a.Shutdown()
+	testrpc.WaitForTestAgent(t, a.RPC, "dc1")
+
+	// Other keys under the prefix are left alone.
+	client := a.Client()
+	_, err := client.KV().Put(&api.KVPair{Key: "consul-bench/other"}, nil)
+	require.NoError(t, err)
+
+	ui := cli.NewMockUi()
+	c := New(ui, nil)
+	args := []string{
+		"-http-addr=" + a.HTTPAddr(),
+		"-workload=kv",
+		"-workload=register",
+		"-workload=blocking",
+		"-duration=500ms",
+		"-concurrency=2",
+		"-keys=5",
+	}
+	require.Equal(t, 0, c.Run(args), ui.ErrorWriter.String())
+
+	output := ui.OutputWriter.String()
+	for _, w := range []string{"kv", "register", "blocking"} {
+		require.Contains(t, output, "\n"+w+" ")
+	}
+
+	// The keys and nodes written are cleaned up.
+	keys, _, err := client.KV().Keys("consul-bench/", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"consul-bench/other"}, keys)
+	nodes, _, err := client.Catalog().Service("consul-bench", "", nil)
+	require.NoError(t, err)
+	require.Empty(t, nodes)
+
+	// The run is refused if it would overwrite an existing key.
+	_, err = client.KV().Put(&api.KVPair{Key: "consul-bench/kv/3"}, nil)
+	require.NoError(t, err)
+	ui = cli.NewMockUi()
+	c = New(ui, nil)
+	require.Equal(t, 1, c.Run(args))
+	require.Contains(t, ui.ErrorWriter.String(), `key "consul-bench/kv/3" already exists`)
+}
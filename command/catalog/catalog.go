@@ -46,5 +46,9 @@ Usage: consul catalog <subcommand> [options] [args]
 
       $ consul catalog services
 
+  Export the catalog and KV store to a file:
+
+      $ consul catalog export catalog.json
+
   For more examples, ask for subcommand help or view the documentation.
 `
@@ -0,0 +1,139 @@
+package exp
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/command/flags"
+	"github.com/mitchellh/cli"
+)
+
+func New(ui cli.Ui) *cmd {
+	c := &cmd{UI: ui}
+	c.init()
+	return c
+}
+
+type cmd struct {
+	UI    cli.Ui
+	flags *flag.FlagSet
+	http  *flags.HTTPFlags
+	help  string
+}
+
+func (c *cmd) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flags, c.http.ClientFlags())
+	flags.Merge(c.flags, c.http.ServerFlags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *cmd) Run(args []string) int {
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+
+	var file string
+
+	args = c.flags.Args()
+	switch len(args) {
+	case 0:
+		c.UI.Error("Missing FILE argument")
+		return 1
+	case 1:
+		file = args[0]
+	default:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	client, err := c.http.APIClient()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	export, err := client.Operator().CatalogExport(&api.QueryOptions{
+		AllowStale: c.http.Stale(),
+	})
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error exporting catalog: %s", err))
+		return 1
+	}
+	buf, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error encoding catalog export: %s", err))
+		return 1
+	}
+
+	// Write to a temporary file and rename it into place, so a scheduled
+	// export never leaves a partial file behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error creating export file: %s", err))
+		return 1
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		c.UI.Error(fmt.Sprintf("Error writing export file: %s", err))
+		return 1
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		c.UI.Error(fmt.Sprintf("Error closing export file after writing: %s", err))
+		return 1
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		c.UI.Error(fmt.Sprintf("Error renaming export file: %s", err))
+		return 1
+	}
+
+	c.UI.Info(fmt.Sprintf("Exported %d nodes and %d keys to %s at index %d",
+		len(export.Nodes), len(export.KV), file, export.Index))
+	return 0
+}
+
+func (c *cmd) Synopsis() string {
+	return synopsis
+}
+
+func (c *cmd) Help() string {
+	return c.help
+}
+
+const synopsis = "Exports the catalog and KV store to a JSON file"
+const help = `
+Usage: consul catalog export [options] FILE
+
+  Exports the nodes, services, checks and KV entries of a datacenter to a
+  human-readable JSON file, consistent as of a single Raft index. Unlike a
+  snapshot, the file doesn't depend on the servers' internal format, so it
+  can be audited, kept as a backup, and imported into another cluster with
+  "consul catalog import". Sessions, ACLs and other server state aren't
+  exported.
+
+  If ACLs are enabled, a management token must be supplied in order to
+  perform the export.
+
+  To export to the file "catalog.json":
+
+      $ consul catalog export catalog.json
+
+  To make the export from any server rather than just the leader:
+
+      $ consul catalog export -stale catalog.json
+
+  This is useful for scheduled exports from cron jobs and the like, since
+  the file is only replaced once the new export is complete.
+
+  For a full list of options and examples, please see the Consul documentation.
+`
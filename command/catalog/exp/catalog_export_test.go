@@ -0,0 +1,101 @@
+package exp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/agent"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/mitchellh/cli"
+)
+
+func TestCatalogExportCommand_noTabs(t *testing.T) {
+	t.Parallel()
+	if strings.ContainsRune(New(cli.NewMockUi()).Help(), '\t') {
+		t.Fatal("help has tabs")
+	}
+}
+
+func TestCatalogExportCommand_Validation(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no file": {
+			[]string{},
+			"Missing FILE argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar", "baz"},
+			"Too many arguments",
+		},
+	}
+
+	for name, tc := range cases {
+		ui := cli.NewMockUi()
+		c := New(ui)
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestCatalogExportCommand(t *testing.T) {
+	t.Parallel()
+	a := agent.NewTestAgent(t.Name(), ``)
+	defer a.Shutdown()
+	client := a.Client()
+
+	if _, err := client.KV().Put(&api.KVPair{Key: "foo", Value: []byte("bar")}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ui := cli.NewMockUi()
+	c := New(ui)
+
+	dir := testutil.TempDir(t, "catalog")
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "catalog.json")
+	args := []string{
+		"-http-addr=" + a.HTTPAddr(),
+		file,
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var export api.CatalogExport
+	if err := json.Unmarshal(buf, &export); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if export.Version != api.CatalogExportVersion || export.Datacenter != "dc1" {
+		t.Fatalf("bad: %#v", export)
+	}
+	if len(export.Nodes) != 1 || export.Nodes[0].Node != a.Config.NodeName {
+		t.Fatalf("bad: %#v", export.Nodes)
+	}
+	if len(export.KV) != 1 || export.KV[0].Key != "foo" || string(export.KV[0].Value) != "bar" {
+		t.Fatalf("bad: %#v", export.KV)
+	}
+}
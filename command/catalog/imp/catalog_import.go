@@ -0,0 +1,183 @@
+package imp
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/command/flags"
+	"github.com/mitchellh/cli"
+)
+
+func New(ui cli.Ui) *cmd {
+	c := &cmd{UI: ui}
+	c.init()
+	return c
+}
+
+type cmd struct {
+	UI    cli.Ui
+	flags *flag.FlagSet
+	http  *flags.HTTPFlags
+	help  string
+
+	// flags
+	catalog bool
+	kv      bool
+}
+
+func (c *cmd) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.BoolVar(&c.catalog, "catalog", true, "Import the nodes, services "+
+		"and checks of the export.")
+	c.flags.BoolVar(&c.kv, "kv", true, "Import the KV entries of the export.")
+
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flags, c.http.ClientFlags())
+	flags.Merge(c.flags, c.http.ServerFlags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *cmd) Run(args []string) int {
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+
+	var file string
+
+	args = c.flags.Args()
+	switch len(args) {
+	case 0:
+		c.UI.Error("Missing FILE argument")
+		return 1
+	case 1:
+		file = args[0]
+	default:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error opening export file: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	var export api.CatalogExport
+	if err := json.NewDecoder(f).Decode(&export); err != nil {
+		c.UI.Error(fmt.Sprintf("Error decoding export file: %s", err))
+		return 1
+	}
+	if export.Version < 1 || export.Version > api.CatalogExportVersion {
+		c.UI.Error(fmt.Sprintf("Unsupported export version %d (expected at most %d)",
+			export.Version, api.CatalogExportVersion))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	client, err := c.http.APIClient()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	var nodes, keys int
+	if c.catalog {
+		catalog := client.Catalog()
+		for _, node := range export.Nodes {
+			reg := &api.CatalogRegistration{
+				ID:              node.ID,
+				Node:            node.Node,
+				Address:         node.Address,
+				TaggedAddresses: node.TaggedAddresses,
+				NodeMeta:        node.Meta,
+			}
+			if _, err := catalog.Register(reg, nil); err != nil {
+				c.UI.Error(fmt.Sprintf("Error registering node %q: %s", node.Node, err))
+				return 1
+			}
+
+			for _, service := range node.Services {
+				reg.Service = service
+				reg.SkipNodeUpdate = true
+				if _, err := catalog.Register(reg, nil); err != nil {
+					c.UI.Error(fmt.Sprintf("Error registering service %q on node %q: %s",
+						service.ID, node.Node, err))
+					return 1
+				}
+			}
+			reg.Service = nil
+
+			// The serfHealth check is managed by the servers of the
+			// datacenter the node is a member of, so it isn't imported.
+			var checks api.HealthChecks
+			for _, check := range node.Checks {
+				if check.CheckID == "serfHealth" {
+					continue
+				}
+				checks = append(checks, check)
+			}
+			if len(checks) > 0 {
+				reg.Checks = checks
+				if _, err := catalog.Register(reg, nil); err != nil {
+					c.UI.Error(fmt.Sprintf("Error registering checks on node %q: %s", node.Node, err))
+					return 1
+				}
+			}
+			nodes++
+		}
+	}
+
+	if c.kv {
+		kv := client.KV()
+		for _, entry := range export.KV {
+			pair := &api.KVPair{
+				Key:   entry.Key,
+				Flags: entry.Flags,
+				Value: entry.Value,
+			}
+			if _, err := kv.Put(pair, nil); err != nil {
+				c.UI.Error(fmt.Sprintf("Error writing key %q: %s", entry.Key, err))
+				return 1
+			}
+			keys++
+		}
+	}
+
+	c.UI.Info(fmt.Sprintf("Imported %d nodes and %d keys from %s", nodes, keys, file))
+	return 0
+}
+
+func (c *cmd) Synopsis() string {
+	return synopsis
+}
+
+func (c *cmd) Help() string {
+	return c.help
+}
+
+const synopsis = "Imports a catalog export into the catalog and KV store"
+const help = `
+Usage: consul catalog import [options] FILE
+
+  Imports the nodes, services, checks and KV entries of a file written by
+  "consul catalog export". Existing entries with the same names are
+  overwritten, and entries that aren't in the file are left alone.
+
+  Nodes that are members of the datacenter will have their registrations
+  kept in sync by their agents as usual, and their serfHealth checks are
+  never imported.
+
+  To import the file "catalog.json":
+
+      $ consul catalog import catalog.json
+
+  To import only the KV entries:
+
+      $ consul catalog import -catalog=false catalog.json
+
+  For a full list of options and examples, please see the Consul documentation.
+`
@@ -0,0 +1,151 @@
+package imp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/agent"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/mitchellh/cli"
+)
+
+func TestCatalogImportCommand_noTabs(t *testing.T) {
+	t.Parallel()
+	if strings.ContainsRune(New(cli.NewMockUi()).Help(), '\t') {
+		t.Fatal("help has tabs")
+	}
+}
+
+func TestCatalogImportCommand_Validation(t *testing.T) {
+	t.Parallel()
+
+	dir := testutil.TempDir(t, "catalog")
+	defer os.RemoveAll(dir)
+
+	future := path.Join(dir, "future.json")
+	if err := ioutil.WriteFile(future, []byte(`{"Version": 99}`), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no file": {
+			[]string{},
+			"Missing FILE argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar", "baz"},
+			"Too many arguments",
+		},
+		"missing file": {
+			[]string{path.Join(dir, "nope.json")},
+			"Error opening export file",
+		},
+		"future version": {
+			[]string{future},
+			"Unsupported export version 99",
+		},
+	}
+
+	for name, tc := range cases {
+		ui := cli.NewMockUi()
+		c := New(ui)
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestCatalogImportCommand(t *testing.T) {
+	t.Parallel()
+	a := agent.NewTestAgent(t.Name(), ``)
+	defer a.Shutdown()
+	client := a.Client()
+
+	export := &api.CatalogExport{
+		Version:    api.CatalogExportVersion,
+		Datacenter: "dc2",
+		Nodes: []*api.CatalogExportNode{
+			{
+				ID:      "3ec2ec2a-c3f0-4d33-b2c4-7b4d7d4c5a2e",
+				Node:    "web1",
+				Address: "10.1.2.3",
+				Meta:    map[string]string{"rack": "a"},
+				Services: []*api.AgentService{
+					{ID: "web", Service: "web", Port: 80},
+				},
+				Checks: api.HealthChecks{
+					{Node: "web1", CheckID: "serfHealth", Name: "Serf Health Status", Status: api.HealthPassing},
+					{Node: "web1", CheckID: "web", Name: "web", Status: api.HealthPassing, ServiceID: "web"},
+				},
+			},
+		},
+		KV: []*api.CatalogExportKV{
+			{Key: "foo", Flags: 42, Value: []byte("bar")},
+		},
+	}
+	buf, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	dir := testutil.TempDir(t, "catalog")
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "catalog.json")
+	if err := ioutil.WriteFile(file, buf, 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ui := cli.NewMockUi()
+	c := New(ui)
+	args := []string{
+		"-http-addr=" + a.HTTPAddr(),
+		file,
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	node, _, err := client.Catalog().Node("web1", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if node == nil || node.Node.Address != "10.1.2.3" || node.Node.Meta["rack"] != "a" {
+		t.Fatalf("bad: %#v", node)
+	}
+	if _, ok := node.Services["web"]; !ok {
+		t.Fatalf("bad: %#v", node.Services)
+	}
+
+	checks, _, err := client.Health().Node("web1", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(checks) != 1 || checks[0].CheckID != "web" {
+		t.Fatalf("bad: %#v", checks)
+	}
+
+	pair, _, err := client.KV().Get("foo", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pair == nil || pair.Flags != 42 || string(pair.Value) != "bar" {
+		t.Fatalf("bad: %#v", pair)
+	}
+}
@@ -19,7 +19,10 @@ import (
 	acltread "github.com/hashicorp/consul/command/acl/token/read"
 	acltupdate "github.com/hashicorp/consul/command/acl/token/update"
 	"github.com/hashicorp/consul/command/agent"
+	"github.com/hashicorp/consul/command/bench"
 	"github.com/hashicorp/consul/command/catalog"
+	catexp "github.com/hashicorp/consul/command/catalog/exp"
+	catimp "github.com/hashicorp/consul/command/catalog/imp"
 	catlistdc "github.com/hashicorp/consul/command/catalog/list/dc"
 	catlistnodes "github.com/hashicorp/consul/command/catalog/list/nodes"
 	catlistsvc "github.com/hashicorp/consul/command/catalog/list/services"
@@ -30,6 +33,7 @@ import (
 	"github.com/hashicorp/consul/command/connect/envoy"
 	"github.com/hashicorp/consul/command/connect/proxy"
 	"github.com/hashicorp/consul/command/debug"
+	"github.com/hashicorp/consul/command/dnsforwarding"
 	"github.com/hashicorp/consul/command/event"
 	"github.com/hashicorp/consul/command/exec"
 	"github.com/hashicorp/consul/command/forceleave"
@@ -109,8 +113,11 @@ func init() {
 	Register("agent", func(ui cli.Ui) (cli.Command, error) {
 		return agent.New(ui, rev, ver, verPre, verHuman, make(chan struct{})), nil
 	})
+	Register("bench", func(ui cli.Ui) (cli.Command, error) { return bench.New(ui, MakeShutdownCh()), nil })
 	Register("catalog", func(cli.Ui) (cli.Command, error) { return catalog.New(), nil })
 	Register("catalog datacenters", func(ui cli.Ui) (cli.Command, error) { return catlistdc.New(ui), nil })
+	Register("catalog export", func(ui cli.Ui) (cli.Command, error) { return catexp.New(ui), nil })
+	Register("catalog import", func(ui cli.Ui) (cli.Command, error) { return catimp.New(ui), nil })
 	Register("catalog nodes", func(ui cli.Ui) (cli.Command, error) { return catlistnodes.New(ui), nil })
 	Register("catalog services", func(ui cli.Ui) (cli.Command, error) { return catlistsvc.New(ui), nil })
 	Register("connect", func(ui cli.Ui) (cli.Command, error) { return connect.New(), nil })
@@ -120,6 +127,7 @@ func init() {
 	Register("connect proxy", func(ui cli.Ui) (cli.Command, error) { return proxy.New(ui, MakeShutdownCh()), nil })
 	Register("connect envoy", func(ui cli.Ui) (cli.Command, error) { return envoy.New(ui), nil })
 	Register("debug", func(ui cli.Ui) (cli.Command, error) { return debug.New(ui, MakeShutdownCh()), nil })
+	Register("dns-forwarding", func(ui cli.Ui) (cli.Command, error) { return dnsforwarding.New(ui), nil })
 	Register("event", func(ui cli.Ui) (cli.Command, error) { return event.New(ui), nil })
 	Register("exec", func(ui cli.Ui) (cli.Command, error) { return exec.New(ui, MakeShutdownCh()), nil })
 	Register("force-leave", func(ui cli.Ui) (cli.Command, error) { return forceleave.New(ui), nil })
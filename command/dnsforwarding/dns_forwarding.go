@@ -0,0 +1,83 @@
+package dnsforwarding
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/command/flags"
+	"github.com/mitchellh/cli"
+)
+
+func New(ui cli.Ui) *cmd {
+	c := &cmd{UI: ui}
+	c.init()
+	return c
+}
+
+type cmd struct {
+	UI    cli.Ui
+	flags *flag.FlagSet
+	http  *flags.HTTPFlags
+	help  string
+
+	format string
+}
+
+func (c *cmd) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.StringVar(&c.format, "format", "",
+		"The resolver to write configuration for. One of \"bind\", \"dnsmasq\" "+
+			"or \"systemd-resolved\". Required.")
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flags, c.http.ClientFlags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *cmd) Run(args []string) int {
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if c.format == "" {
+		c.UI.Error("Missing -format")
+		return 1
+	}
+
+	client, err := c.http.APIClient()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	out, err := client.Agent().DNSForwarding(c.format)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error generating DNS forwarding configuration: %s", err))
+		return 1
+	}
+
+	c.UI.Output(strings.TrimSuffix(out, "\n"))
+	return 0
+}
+
+func (c *cmd) Synopsis() string {
+	return synopsis
+}
+
+func (c *cmd) Help() string {
+	return c.help
+}
+
+const synopsis = "Prints resolver configuration that forwards queries to Consul DNS"
+const help = `
+Usage: consul dns-forwarding -format=<bind|dnsmasq|systemd-resolved> [options]
+
+  Prints a snippet of configuration for a DNS resolver that forwards the
+  queries for the Consul domain to the agent's DNS interface. It's built
+  from the agent's own domain and DNS addresses, with wildcard addresses
+  replaced by the loopback address, so it's meant for a resolver on the
+  same host as the agent.
+
+  For example, to set up dnsmasq:
+
+      $ consul dns-forwarding -format=dnsmasq > /etc/dnsmasq.d/10-consul
+`
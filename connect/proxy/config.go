@@ -3,6 +3,8 @@ package proxy
 import (
 	"fmt"
 	"log"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -242,8 +244,8 @@ func (w *AgentConfigWatcher) handler(blockVal watch.BlockingParamVal,
 	}
 	cfg.PublicListener.BindAddress = resp.Address
 	cfg.PublicListener.BindPort = resp.Port
-	cfg.PublicListener.LocalServiceAddress = fmt.Sprintf("%s:%d",
-		resp.Proxy.LocalServiceAddress, resp.Proxy.LocalServicePort)
+	cfg.PublicListener.LocalServiceAddress = net.JoinHostPort(
+		resp.Proxy.LocalServiceAddress, strconv.Itoa(resp.Proxy.LocalServicePort))
 
 	cfg.PublicListener.applyDefaults()
 
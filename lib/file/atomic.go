@@ -11,6 +11,12 @@ import (
 // WriteAtomic writes the given contents to a temporary file in the same
 // directory, does an fsync and then renames the file to its real path
 func WriteAtomic(path string, contents []byte) error {
+	return WriteAtomicWithPerms(path, contents, 0600)
+}
+
+// WriteAtomicWithPerms is the same as WriteAtomic but the file is given the
+// specified permissions rather than 0600.
+func WriteAtomicWithPerms(path string, contents []byte, perms os.FileMode) error {
 	uuid, err := uuid.GenerateUUID()
 	if err != nil {
 		return err
@@ -24,6 +30,12 @@ func WriteAtomic(path string, contents []byte) error {
 	if err != nil {
 		return err
 	}
+	// Set the mode explicitly so it isn't affected by the umask.
+	if err := fh.Chmod(perms); err != nil {
+		fh.Close()
+		os.Remove(tempPath)
+		return err
+	}
 	if _, err := fh.Write(contents); err != nil {
 		fh.Close()
 		os.Remove(tempPath)
@@ -0,0 +1,284 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/testutil/retry"
+	"github.com/pkg/errors"
+)
+
+// TestDatacenterConfig describes one datacenter of a test cluster.
+type TestDatacenterConfig struct {
+	// Name is the name of the datacenter.
+	Name string
+
+	// Servers is how many servers to start. At least one is started.
+	Servers int
+
+	// Clients is how many client agents to start.
+	Clients int
+}
+
+// TestClusterConfig describes the topology of a test cluster.
+type TestClusterConfig struct {
+	// Datacenters are the datacenters to start. The first one is the
+	// primary datacenter, and the servers of all of them are joined over
+	// the WAN.
+	Datacenters []TestDatacenterConfig
+
+	// ConfigCallback is called with the config of every agent before it's
+	// started, after the cluster has set the datacenter, role and ports.
+	ConfigCallback ServerConfigCallback
+}
+
+// TestDatacenter is a datacenter of a running test cluster.
+type TestDatacenter struct {
+	Name    string
+	Servers []*TestServer
+	Clients []*TestServer
+}
+
+// Agents returns the servers and then the clients of the datacenter.
+func (d *TestDatacenter) Agents() []*TestServer {
+	agents := make([]*TestServer, 0, len(d.Servers)+len(d.Clients))
+	agents = append(agents, d.Servers...)
+	return append(agents, d.Clients...)
+}
+
+// TestCluster is a set of Consul agents started by the test harness, which
+// form one or more datacenters. Like TestServer, it runs each agent as a
+// consul subprocess, so the consul binary must be on the $PATH.
+type TestCluster struct {
+	Datacenters []*TestDatacenter
+}
+
+// NewTestCluster starts the agents of the given topology, joins them over the
+// LAN and WAN, and waits for every datacenter to elect a leader and for every
+// agent to see it. If there is an error starting the cluster, the agents
+// already started are stopped before it's returned.
+func NewTestCluster(t *testing.T, cfg TestClusterConfig) (*TestCluster, error) {
+	if len(cfg.Datacenters) == 0 {
+		return nil, errors.New("cluster must have at least one datacenter")
+	}
+	primary := cfg.Datacenters[0].Name
+
+	c := &TestCluster{}
+	for _, dcCfg := range cfg.Datacenters {
+		if dcCfg.Name == "" {
+			c.Stop()
+			return nil, errors.New("datacenter must have a name")
+		}
+		dc, err := c.startDatacenter(t, dcCfg, primary, cfg.ConfigCallback)
+		c.Datacenters = append(c.Datacenters, dc)
+		if err != nil {
+			c.Stop()
+			return nil, errors.Wrapf(err, "failed starting datacenter %q", dcCfg.Name)
+		}
+	}
+
+	// Join the servers of every datacenter to the first server of the
+	// primary, and wait for it to see all of them.
+	first := c.Datacenters[0].Servers[0]
+	for _, dc := range c.Datacenters[1:] {
+		for _, srv := range dc.Servers {
+			if err := srv.join(first.WANAddr, true); err != nil {
+				c.Stop()
+				return nil, err
+			}
+		}
+	}
+	if len(c.Datacenters) > 1 {
+		if err := first.waitForDatacenters(len(c.Datacenters)); err != nil {
+			c.Stop()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startDatacenter starts the servers of a datacenter, then its clients. The
+// first server bootstraps the datacenter and the others join it.
+func (c *TestCluster) startDatacenter(t *testing.T, cfg TestDatacenterConfig,
+	primary string, cb ServerConfigCallback) (*TestDatacenter, error) {
+	dc := &TestDatacenter{Name: cfg.Name}
+	servers := cfg.Servers
+	if servers < 1 {
+		servers = 1
+	}
+
+	start := func(server, bootstrap bool) (*TestServer, error) {
+		return NewTestServerConfigT(t, func(c *TestServerConfig) {
+			c.Datacenter = cfg.Name
+			c.PrimaryDatacenter = primary
+			c.Server = server
+			c.Bootstrap = bootstrap
+			if cb != nil {
+				cb(c)
+			}
+		})
+	}
+
+	for i := 0; i < servers; i++ {
+		srv, err := start(true, i == 0)
+		if err != nil {
+			return dc, err
+		}
+		dc.Servers = append(dc.Servers, srv)
+		if i > 0 {
+			if err := srv.join(dc.Servers[0].LANAddr, false); err != nil {
+				return dc, err
+			}
+		}
+	}
+	if err := dc.Servers[0].waitForPeers(servers); err != nil {
+		return dc, err
+	}
+
+	for i := 0; i < cfg.Clients; i++ {
+		client, err := start(false, false)
+		if err != nil {
+			return dc, err
+		}
+		dc.Clients = append(dc.Clients, client)
+		if err := client.join(dc.Servers[0].LANAddr, false); err != nil {
+			return dc, err
+		}
+	}
+
+	for _, agent := range dc.Agents() {
+		if err := agent.waitForKnownLeader(); err != nil {
+			return dc, err
+		}
+	}
+	return dc, nil
+}
+
+// Datacenter returns the datacenter with the given name, or nil if there
+// isn't one.
+func (c *TestCluster) Datacenter(name string) *TestDatacenter {
+	for _, dc := range c.Datacenters {
+		if dc.Name == name {
+			return dc
+		}
+	}
+	return nil
+}
+
+// Stop stops all the agents of the cluster, clients first, and returns the
+// first error. Agents exiting with a non-zero status aren't an error, since
+// servers skip leaving on interrupt and always exit that way.
+func (c *TestCluster) Stop() error {
+	var first error
+	for i := len(c.Datacenters) - 1; i >= 0; i-- {
+		agents := c.Datacenters[i].Agents()
+		for j := len(agents) - 1; j >= 0; j-- {
+			err := agents[j].Stop()
+			if _, ok := err.(*exec.ExitError); ok {
+				continue
+			}
+			if err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// join joins the agent to the one at the given Serf address.
+func (s *TestServer) join(addr string, wan bool) error {
+	path := "/v1/agent/join/" + addr
+	if wan {
+		path += "?wan=1"
+	}
+	req, err := http.NewRequest("PUT", s.url(path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed joining %s", addr)
+	}
+	defer resp.Body.Close()
+	if err := s.requireOK(resp); err != nil {
+		return errors.Wrapf(err, "failed joining %s", addr)
+	}
+	return nil
+}
+
+// waitForList waits for the given endpoint to return a list with at least n
+// entries.
+func (s *TestServer) waitForList(path string, n int) error {
+	f := &failer{}
+	timer := &retry.Timer{
+		Timeout: s.Config.ReadyTimeout,
+		Wait:    250 * time.Millisecond,
+	}
+	retry.RunWith(timer, f, func(r *retry.R) {
+		resp, err := s.HTTPClient.Get(s.url(path))
+		if err != nil {
+			r.Fatal("failed http get", err)
+		}
+		defer resp.Body.Close()
+		if err := s.requireOK(resp); err != nil {
+			r.Fatal("failed OK response", err)
+		}
+		var list []interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			r.Fatal(err)
+		}
+		if len(list) < n {
+			r.Fatalf("got %d entries from %s, want %d", len(list), path, n)
+		}
+	})
+	if f.failed {
+		return fmt.Errorf("failed waiting for %d entries from %s", n, path)
+	}
+	return nil
+}
+
+// waitForPeers waits for the agent to see the given number of Raft peers.
+func (s *TestServer) waitForPeers(n int) error {
+	return s.waitForList("/v1/status/peers", n)
+}
+
+// waitForDatacenters waits for the agent to see the given number of
+// datacenters.
+func (s *TestServer) waitForDatacenters(n int) error {
+	return s.waitForList("/v1/catalog/datacenters", n)
+}
+
+// waitForKnownLeader waits for the agent to know the leader of its
+// datacenter. Unlike waitForLeader, this works for clients too.
+func (s *TestServer) waitForKnownLeader() error {
+	f := &failer{}
+	timer := &retry.Timer{
+		Timeout: s.Config.ReadyTimeout,
+		Wait:    250 * time.Millisecond,
+	}
+	retry.RunWith(timer, f, func(r *retry.R) {
+		resp, err := s.HTTPClient.Get(s.url("/v1/status/leader"))
+		if err != nil {
+			r.Fatal("failed http get", err)
+		}
+		defer resp.Body.Close()
+		if err := s.requireOK(resp); err != nil {
+			r.Fatal("failed OK response", err)
+		}
+		var leader string
+		if err := json.NewDecoder(resp.Body).Decode(&leader); err != nil {
+			r.Fatal(err)
+		}
+		if leader == "" {
+			r.Fatal("no known leader")
+		}
+	})
+	if f.failed {
+		return errors.New("failed waiting for known leader")
+	}
+	return nil
+}
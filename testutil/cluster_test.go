@@ -0,0 +1,102 @@
+package testutil
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestNewTestCluster_Invalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewTestCluster(t, TestClusterConfig{}); err == nil {
+		t.Fatalf("should fail without datacenters")
+	}
+
+	cfg := TestClusterConfig{
+		Datacenters: []TestDatacenterConfig{{Servers: 1}},
+	}
+	if _, err := NewTestCluster(t, cfg); err == nil {
+		t.Fatalf("should fail without a datacenter name")
+	}
+}
+
+func TestTestCluster_Datacenter(t *testing.T) {
+	t.Parallel()
+
+	s1, s2, c1 := &TestServer{}, &TestServer{}, &TestServer{}
+	c := &TestCluster{
+		Datacenters: []*TestDatacenter{
+			{Name: "dc1", Servers: []*TestServer{s1, s2}, Clients: []*TestServer{c1}},
+			{Name: "dc2"},
+		},
+	}
+
+	dc := c.Datacenter("dc1")
+	if dc == nil || dc.Name != "dc1" {
+		t.Fatalf("bad: %#v", dc)
+	}
+	agents := dc.Agents()
+	if len(agents) != 3 || agents[0] != s1 || agents[1] != s2 || agents[2] != c1 {
+		t.Fatalf("bad: %#v", agents)
+	}
+	if dc := c.Datacenter("dc2"); dc == nil || len(dc.Agents()) != 0 {
+		t.Fatalf("bad: %#v", dc)
+	}
+	if dc := c.Datacenter("dc3"); dc != nil {
+		t.Fatalf("bad: %#v", dc)
+	}
+}
+
+func TestNewTestCluster(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("consul"); err != nil {
+		t.Skip("consul not found on $PATH")
+	}
+
+	var configured int
+	c, err := NewTestCluster(t, TestClusterConfig{
+		Datacenters: []TestDatacenterConfig{
+			{Name: "dc1", Servers: 2, Clients: 1},
+			{Name: "dc2"},
+		},
+		ConfigCallback: func(c *TestServerConfig) {
+			configured++
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer c.Stop()
+
+	// Every agent was configured, and a datacenter without a server count
+	// still gets one.
+	if configured != 4 {
+		t.Fatalf("bad: %d", configured)
+	}
+	dc1, dc2 := c.Datacenter("dc1"), c.Datacenter("dc2")
+	if len(dc1.Servers) != 2 || len(dc1.Clients) != 1 || len(dc2.Servers) != 1 || len(dc2.Clients) != 0 {
+		t.Fatalf("bad: %#v %#v", dc1, dc2)
+	}
+	for _, dc := range c.Datacenters {
+		for _, agent := range dc.Agents() {
+			if agent.Config.Datacenter != dc.Name || agent.Config.PrimaryDatacenter != "dc1" {
+				t.Fatalf("bad: %#v", agent.Config)
+			}
+		}
+	}
+
+	// The agents of a datacenter share its state.
+	dc1.Servers[1].SetKVString(t, "foo", "bar")
+	if v := dc1.Clients[0].GetKVString(t, "foo"); v != "bar" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	// The servers of both datacenters are joined over the WAN.
+	if err := dc2.Servers[0].waitForDatacenters(2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -40,6 +40,11 @@ type TestPerformanceConfig struct {
 	RaftMultiplier uint `json:"raft_multiplier,omitempty"`
 }
 
+// TestLimitsConfig configures the limits.
+type TestLimitsConfig struct {
+	StateMemoryBudgetMB int `json:"state_memory_budget_mb,omitempty"`
+}
+
 // TestPortConfig configures the various ports used for services
 // provided by the Consul server.
 type TestPortConfig struct {
@@ -101,6 +106,8 @@ type TestServerConfig struct {
 	EnableScriptChecks  bool                   `json:"enable_script_checks,omitempty"`
 	Connect             map[string]interface{} `json:"connect,omitempty"`
 	EnableDebug         bool                   `json:"enable_debug,omitempty"`
+	ChangeFeedSize      int                    `json:"change_feed_max_entries,omitempty"`
+	Limits              *TestLimitsConfig      `json:"limits,omitempty"`
 	ReadyTimeout        time.Duration          `json:"-"`
 	Stdout, Stderr      io.Writer              `json:"-"`
 	Args                []string               `json:"-"`
@@ -190,6 +197,23 @@ type TestCheck struct {
 	TTL       string `json:",omitempty"`
 }
 
+// TestCatalogRegistration is used to serialize a catalog registration of a
+// node, and optionally a service on it, that has no agent.
+type TestCatalogRegistration struct {
+	Node    string       `json:",omitempty"`
+	Address string       `json:",omitempty"`
+	Service *TestService `json:",omitempty"`
+}
+
+// TestCoordinate is used to serialize a network coordinate. It has the same
+// fields as the coordinates in Serf.
+type TestCoordinate struct {
+	Vec        []float64
+	Error      float64
+	Adjustment float64
+	Height     float64
+}
+
 // TestKVResponse is what we use to decode KV data.
 type TestKVResponse struct {
 	Value string
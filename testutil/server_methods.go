@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/hashicorp/consul/testutil/retry"
 	"github.com/pkg/errors"
 )
 
@@ -185,6 +186,49 @@ func (s *TestServer) AddCheck(t *testing.T, name, serviceID, status string) {
 	}
 }
 
+// RegisterCatalogNode registers a node that has no agent directly in the
+// catalog, along with a service on it if one is given. Such nodes have no
+// health checks, so their services are always passing.
+func (s *TestServer) RegisterCatalogNode(t *testing.T, node, address string, svc *TestService) {
+	reg := &TestCatalogRegistration{
+		Node:    node,
+		Address: address,
+		Service: svc,
+	}
+	payload, err := s.encodePayload(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := s.put(t, "/v1/catalog/register", payload)
+	resp.Body.Close()
+}
+
+// SetCoordinate sets the network coordinate of a node, and waits for it to
+// be visible, since the servers apply coordinate updates in batches.
+func (s *TestServer) SetCoordinate(t *testing.T, node string, coord *TestCoordinate) {
+	update := map[string]interface{}{
+		"Node":  node,
+		"Coord": coord,
+	}
+	payload, err := s.encodePayload(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := s.put(t, "/v1/coordinate/update", payload)
+	resp.Body.Close()
+
+	retry.Run(t, func(r *retry.R) {
+		resp, err := s.HTTPClient.Get(s.url("/v1/coordinate/node/" + node))
+		if err != nil {
+			r.Fatal("failed http get", err)
+		}
+		defer resp.Body.Close()
+		if err := s.requireOK(resp); err != nil {
+			r.Fatal("failed OK response", err)
+		}
+	})
+}
+
 // put performs a new HTTP PUT request.
 func (s *TestServer) put(t *testing.T, path string, body io.Reader) *http.Response {
 	req, err := http.NewRequest("PUT", s.url(path), body)
@@ -63,3 +63,11 @@ func (w *WrappedServer) AddAddressableService(name, status, address string, port
 func (w *WrappedServer) AddCheck(name, serviceID, status string) {
 	w.s.AddCheck(w.t, name, serviceID, status)
 }
+
+func (w *WrappedServer) RegisterCatalogNode(node, address string, svc *TestService) {
+	w.s.RegisterCatalogNode(w.t, node, address, svc)
+}
+
+func (w *WrappedServer) SetCoordinate(node string, coord *TestCoordinate) {
+	w.s.SetCoordinate(w.t, node, coord)
+}
@@ -21,6 +21,13 @@ type DCWrapper func(dc string, conn net.Conn) (net.Conn, error)
 // a constant value. This is usually done by currying DCWrapper.
 type Wrapper func(conn net.Conn) (net.Conn, error)
 
+// GatewayWrapper is a function that is used to wrap a connection to a WAN
+// federation gateway in a TLS connection to a server behind it. It takes the
+// datacenter and node name of the server, which are sent as the TLS server
+// name so the gateway can route the connection. An empty node name lets the
+// gateway pick any server in the datacenter.
+type GatewayWrapper func(dc, node string, conn net.Conn) (net.Conn, error)
+
 // TLSLookup maps the tls_min_version configuration to the internal value
 var TLSLookup = map[string]uint16{
 	"tls10": tls.VersionTLS10,
@@ -208,6 +215,57 @@ func (c *Config) OutgoingTLSWrapper() (DCWrapper, error) {
 	return wrapper, nil
 }
 
+// GatewayServerName returns the TLS server name used to reach a server
+// through a WAN federation gateway, which is <node>.server.<dc>.<domain>, or
+// server.<dc>.<domain> for any server in the datacenter.
+func GatewayServerName(dc, node, domain string) string {
+	name := "server." + dc + "." + strings.TrimSuffix(domain, ".")
+	if node != "" {
+		name = node + "." + name
+	}
+	return name
+}
+
+// OutgoingGatewayWrapper returns a GatewayWrapper based on the outgoing TLS
+// configuration. TLS is always used through gateways, since they route
+// connections by the TLS server name. The server name includes the node, so
+// hostname verification is done manually against server.<dc>.<domain>.
+func (c *Config) OutgoingGatewayWrapper() (GatewayWrapper, error) {
+	conf := *c
+	conf.UseTLS = true
+	tlsConfig, err := conf.OutgoingTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := func(dc, node string, conn net.Conn) (net.Conn, error) {
+		config := tlsConfig.Clone()
+		config.ServerName = GatewayServerName(dc, node, c.Domain)
+		config.InsecureSkipVerify = true
+
+		tlsConn := tls.Client(conn, config)
+		if !c.VerifyOutgoing {
+			return tlsConn, nil
+		}
+
+		if err := tlsConn.Handshake(); err != nil {
+			tlsConn.Close()
+			return nil, err
+		}
+		var dnsName string
+		if c.VerifyServerHostname {
+			dnsName = GatewayServerName(dc, "", c.Domain)
+		}
+		if err := verifyPeer(tlsConn, config.RootCAs, dnsName); err != nil {
+			tlsConn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+
+	return wrapper, nil
+}
+
 // SpecificDC is used to invoke a static datacenter
 // and turns a DCWrapper into a Wrapper type.
 func SpecificDC(dc string, tlsWrap DCWrapper) Wrapper {
@@ -251,16 +309,31 @@ func (c *Config) wrapTLSClient(conn net.Conn, tlsConfig *tls.Config) (net.Conn,
 		return nil, err
 	}
 
+	if err = verifyPeer(tlsConn, tlsConfig.RootCAs, ""); err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+
+	return tlsConn, err
+}
+
+// verifyPeer checks the certificate presented by the other end of a TLS
+// connection that has completed its handshake was signed by one of the
+// given roots. It also checks it's valid for dnsName, if that isn't empty.
+func verifyPeer(tlsConn *tls.Conn, roots *x509.CertPool, dnsName string) error {
 	// The following is lightly-modified from the doFullHandshake
 	// method in crypto/tls's handshake_client.go.
 	opts := x509.VerifyOptions{
-		Roots:         tlsConfig.RootCAs,
+		Roots:         roots,
 		CurrentTime:   time.Now(),
-		DNSName:       "",
+		DNSName:       dnsName,
 		Intermediates: x509.NewCertPool(),
 	}
 
 	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no peer certificates presented")
+	}
 	for i, cert := range certs {
 		if i == 0 {
 			continue
@@ -268,13 +341,8 @@ func (c *Config) wrapTLSClient(conn net.Conn, tlsConfig *tls.Config) (net.Conn,
 		opts.Intermediates.AddCert(cert)
 	}
 
-	_, err = certs[0].Verify(opts)
-	if err != nil {
-		tlsConn.Close()
-		return nil, err
-	}
-
-	return tlsConn, err
+	_, err := certs[0].Verify(opts)
+	return err
 }
 
 // IncomingTLSConfig generates a TLS configuration for incoming requests
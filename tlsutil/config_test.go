@@ -439,6 +439,15 @@ func TestConfig_wrapTLS_BadCert(t *testing.T) {
 	}
 }
 
+func TestGatewayServerName(t *testing.T) {
+	if got, want := GatewayServerName("dc1", "node1", "consul."), "node1.server.dc1.consul"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got, want := GatewayServerName("dc1", "", "consul"), "server.dc1.consul"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
 func TestConfig_IncomingTLS(t *testing.T) {
 	conf := &Config{
 		VerifyIncoming: true,
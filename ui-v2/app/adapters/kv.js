@@ -9,6 +9,7 @@ import injectableRequestToJQueryAjaxHash from 'consul-ui/utils/injectableRequest
 import { typeOf } from '@ember/utils';
 import { get } from '@ember/object';
 import { inject as service } from '@ember/service';
+import EmberError from '@ember/error';
 
 import keyToArray from 'consul-ui/utils/keyToArray';
 import removeNull from 'consul-ui/utils/remove-null';
@@ -16,9 +17,10 @@ import removeNull from 'consul-ui/utils/remove-null';
 import { PRIMARY_KEY, SLUG_KEY } from 'consul-ui/models/kv';
 import { FOREIGN_KEY as DATACENTER_KEY } from 'consul-ui/models/dc';
 import { PUT as HTTP_PUT, DELETE as HTTP_DELETE } from 'consul-ui/utils/http/method';
-import { OK as HTTP_OK } from 'consul-ui/utils/http/status';
+import { OK as HTTP_OK, CONFLICT as HTTP_CONFLICT } from 'consul-ui/utils/http/status';
 
 const API_KEYS_KEY = 'keys';
+const API_CAS_KEY = 'cas';
 const stringify = function(obj) {
   if (typeOf(obj) === 'string') {
     return obj;
@@ -62,14 +64,20 @@ export default Adapter.extend({
     }
     return this.appendURL('kv', keyToArray(query.id), this.cleanQuery(query));
   },
+  // Both creates and updates use check-and-set so we never clobber a change
+  // someone else made whilst the form was open. A `cas` of 0 only creates the
+  // key if it doesn't already exist, otherwise the key must not have been
+  // modified since we read it.
   urlForCreateRecord: function(modelName, snapshot) {
     return this.appendURL('kv', keyToArray(snapshot.attr(SLUG_KEY)), {
       [API_DATACENTER_KEY]: snapshot.attr(DATACENTER_KEY),
+      [API_CAS_KEY]: 0,
     });
   },
   urlForUpdateRecord: function(id, modelName, snapshot) {
     return this.appendURL('kv', keyToArray(snapshot.attr(SLUG_KEY)), {
       [API_DATACENTER_KEY]: snapshot.attr(DATACENTER_KEY),
+      [API_CAS_KEY]: snapshot.attr('ModifyIndex') || 0,
     });
   },
   urlForDeleteRecord: function(id, modelName, snapshot) {
@@ -115,6 +123,16 @@ export default Adapter.extend({
         case response === true:
           response = this.handleBooleanResponse(url, response, PRIMARY_KEY, SLUG_KEY);
           break;
+        case response === false:
+          // a `false` response means the check-and-set failed, so the key
+          // was created or modified by someone else
+          if (url.searchParams.has(API_CAS_KEY)) {
+            const e = new EmberError();
+            e.code = HTTP_CONFLICT;
+            e.message = 'The key was modified since it was read';
+            throw e;
+          }
+          break;
         case this.isQueryRecord(url, method):
           response = this.handleSingleResponse(url, removeNull(response[0]), PRIMARY_KEY, SLUG_KEY);
           break;
@@ -0,0 +1,15 @@
+import Component from '@ember/component';
+import { computed, get } from '@ember/object';
+
+import projectionFactory from 'consul-ui/utils/projection';
+
+const size = 336;
+const projection = projectionFactory(size, 16);
+export default Component.extend({
+  size: size,
+  selected: '',
+  items: null,
+  points: computed('items', function() {
+    return projection(get(this, 'items') || []);
+  }),
+});
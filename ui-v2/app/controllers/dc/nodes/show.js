@@ -1,9 +1,10 @@
 import Controller from '@ember/controller';
-import { get, set } from '@ember/object';
+import { get, set, computed } from '@ember/object';
 import { getOwner } from '@ember/application';
 import WithFiltering from 'consul-ui/mixins/with-filtering';
 import qsaFactory from 'consul-ui/utils/dom/qsa-factory';
 import getComponentFactory from 'consul-ui/utils/get-component-factory';
+import ucfirst from 'consul-ui/utils/ucfirst';
 
 const $$ = qsaFactory();
 export default Controller.extend(WithFiltering, {
@@ -13,6 +14,32 @@ export default Controller.extend(WithFiltering, {
       replace: true,
     },
   },
+  checkStatus: '',
+  checks: computed('item.Checks.[]', 'checkStatus', function() {
+    const checks = get(this, 'item.Checks') || [];
+    const status = get(this, 'checkStatus');
+    if (status === '') {
+      return checks;
+    }
+    return checks.filter(function(item) {
+      return get(item, 'Status') === status;
+    });
+  }),
+  checkFilters: computed('item.Checks.[]', function() {
+    const checks = get(this, 'item.Checks') || [];
+    return ['', 'passing', 'warning', 'critical'].map(function(item) {
+      const count =
+        item === ''
+          ? checks.length
+          : checks.filter(function(check) {
+              return get(check, 'Status') === item;
+            }).length;
+      return {
+        label: `${item === '' ? 'All' : ucfirst(item)} (${count.toLocaleString()})`,
+        value: item,
+      };
+    });
+  }),
   setProperties: function() {
     this._super(...arguments);
     // the default selected tab depends on whether you have any healthchecks or not
@@ -41,6 +68,9 @@ export default Controller.extend(WithFiltering, {
     );
   },
   actions: {
+    filterChecks: function(e) {
+      set(this, 'checkStatus', e.target.value);
+    },
     change: function(e) {
       set(this, 'selectedTab', e.target.value);
       const getComponent = getComponentFactory(getOwner(this));
@@ -0,0 +1,32 @@
+import Controller from '@ember/controller';
+import { computed, get } from '@ember/object';
+
+import distance from 'consul-ui/utils/distance';
+import tomographyFactory from 'consul-ui/utils/tomography';
+
+const tomography = tomographyFactory(distance);
+export default Controller.extend({
+  queryParams: {
+    node: {
+      replace: true,
+    },
+  },
+  node: '',
+  coordinates: computed('items', function() {
+    return (get(this, 'items') || []).map(item => get(item, 'data'));
+  }),
+  // the selected node falls back to the first node if none was picked or
+  // the one that was picked no longer has a coordinate
+  selected: computed('coordinates', 'node', function() {
+    const coordinates = get(this, 'coordinates');
+    const node = get(this, 'node');
+    if (coordinates.some(item => item.Node === node)) {
+      return node;
+    }
+    return coordinates.length > 0 ? coordinates[0].Node : '';
+  }),
+  tomography: computed('coordinates', 'selected', function() {
+    const coordinates = get(this, 'coordinates');
+    return coordinates.length > 1 ? tomography(get(this, 'selected'), coordinates) : null;
+  }),
+});
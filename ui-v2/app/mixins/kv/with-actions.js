@@ -2,6 +2,8 @@ import Mixin from '@ember/object/mixin';
 import { get, set } from '@ember/object';
 import WithBlockingActions from 'consul-ui/mixins/with-blocking-actions';
 
+import { CONFLICT as HTTP_CONFLICT } from 'consul-ui/utils/http/status';
+
 export default Mixin.create(WithBlockingActions, {
   // afterCreate just calls afterUpdate
   afterUpdate: function(item, parent) {
@@ -12,6 +14,18 @@ export default Mixin.create(WithBlockingActions, {
       return this.transitionTo('dc.kv.folder', key);
     }
   },
+  errorCreate: function(type, e) {
+    if (e && e.code === HTTP_CONFLICT) {
+      return 'exists';
+    }
+    return type;
+  },
+  errorUpdate: function(type, e) {
+    if (e && e.code === HTTP_CONFLICT) {
+      return 'conflict';
+    }
+    return type;
+  },
   afterDelete: function(item, parent) {
     if (this.routeName === 'dc.kv.folder') {
       return this.refresh();
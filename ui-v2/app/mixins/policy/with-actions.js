@@ -1,4 +1,35 @@
 import Mixin from '@ember/object/mixin';
+import { get } from '@ember/object';
 import WithBlockingActions from 'consul-ui/mixins/with-blocking-actions';
 
-export default Mixin.create(WithBlockingActions, {});
+import { INTERNAL_SERVER_ERROR as HTTP_INTERNAL_SERVER_ERROR } from 'consul-ui/utils/http/status';
+
+const RULES_ERROR = 'Failed to parse';
+export default Mixin.create(WithBlockingActions, {
+  errorCreate: function(type, e) {
+    return this.errorRules(type, e);
+  },
+  errorUpdate: function(type, e) {
+    return this.errorRules(type, e);
+  },
+  // Rules are only parsed by the server when the policy is saved, so if the
+  // parser rejects them show its message against the Rules field
+  errorRules: function(type, e) {
+    if (e && e.errors && e.errors[0]) {
+      const error = e.errors[0];
+      const detail = error.detail || '';
+      const pos = detail.indexOf(RULES_ERROR);
+      if (parseInt(error.status) === HTTP_INTERNAL_SERVER_ERROR && pos !== -1) {
+        const item = get(this, 'controller.item');
+        if (item && typeof item.addError === 'function') {
+          item.addError('Rules', {
+            value: get(item, 'Rules'),
+            validation: detail.substr(pos),
+          });
+        }
+        return 'invalid';
+      }
+    }
+    return type;
+  },
+});
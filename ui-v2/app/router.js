@@ -19,6 +19,8 @@ Router.map(function() {
       // Show an individual node
       this.route('show', { path: '/:name' });
     });
+    // Topology shows the nodes positioned by their network coordinates
+    this.route('topology', { path: '/topology' });
     // Intentions represent a consul intention
     this.route('intentions', { path: '/intentions' }, function() {
       this.route('edit', { path: '/:id' });
@@ -0,0 +1,17 @@
+import Route from '@ember/routing/route';
+import { inject as service } from '@ember/service';
+import { hash } from 'rsvp';
+import { get } from '@ember/object';
+
+export default Route.extend({
+  repo: service('repository/coordinate'),
+  model: function(params) {
+    return hash({
+      items: get(this, 'repo').findAllByDatacenter(this.modelFor('dc').dc.Name),
+    });
+  },
+  setupController: function(controller, model) {
+    this._super(...arguments);
+    controller.setProperties(model);
+  },
+});
@@ -1,4 +1,5 @@
 export const OK = 200;
 export const UNAUTHORIZED = 401;
 export const FORBIDDEN = 403;
+export const CONFLICT = 409;
 export const INTERNAL_SERVER_ERROR = 500;
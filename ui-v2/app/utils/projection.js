@@ -0,0 +1,34 @@
+// Projects network coordinates onto a square of `size` so nodes can be drawn
+// relative to each other. Only the first two dimensions of each coordinate
+// are used, and both axes share the same scale so that nodes which are
+// further apart on screen are also further apart in network terms.
+export default function(size, padding = 0) {
+  const inner = size - padding * 2;
+  return function(coordinates) {
+    let minX = Infinity;
+    let minY = Infinity;
+    let maxX = -Infinity;
+    let maxY = -Infinity;
+    coordinates.forEach(function(item) {
+      const vec = item.Coord.Vec;
+      minX = Math.min(minX, vec[0]);
+      maxX = Math.max(maxX, vec[0]);
+      minY = Math.min(minY, vec[1]);
+      maxY = Math.max(maxY, vec[1]);
+    });
+    const range = Math.max(maxX - minX, maxY - minY);
+    const scale = range > 0 ? inner / range : 0;
+    // centre the points on whichever axis has the smaller range
+    const offsetX = padding + (inner - (maxX - minX) * scale) / 2;
+    const offsetY = padding + (inner - (maxY - minY) * scale) / 2;
+    return coordinates.map(function(item) {
+      const vec = item.Coord.Vec;
+      return {
+        node: item.Node,
+        segment: item.Segment,
+        x: offsetX + (vec[0] - minX) * scale,
+        y: offsetY + (vec[1] - minY) * scale,
+      };
+    });
+  };
+}
@@ -44,3 +44,14 @@ Feature: dc / acls / policies / update: ACL Policy Update
     Then the url should be /datacenter/acls/policies/policy-id
     Then "[data-notification]" has the "notification-update" class
     And "[data-notification]" has the "error" class
+  Scenario: The rules are rejected by the policy parser
+    Given the url "/v1/acl/policy/policy-id" responds with from yaml
+    ---
+    status: 500
+    body: "Failed to parse ACL rules: At 1:5: object expected"
+    ---
+    And I submit
+    Then the url should be /datacenter/acls/policies/policy-id
+    Then "[data-notification]" has the "notification-update" class
+    And "[data-notification]" has the "error" class
+    And I see the text "Failed to parse ACL rules: At 1:5: object expected" in ".type-text.has-error strong"
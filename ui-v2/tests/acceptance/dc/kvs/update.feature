@@ -6,6 +6,7 @@ Feature: dc / kvs / update: KV Update
     And 1 kv model from yaml
     ---
       Key: [Name]
+      ModifyIndex: 10
     ---
     When I visit the kv page for yaml
     ---
@@ -20,7 +21,7 @@ Feature: dc / kvs / update: KV Update
       value: [Value]
     ---
     And I submit
-    Then a PUT request is made to "/v1/kv/[Name]?dc=datacenter" with the body "[Value]"
+    Then a PUT request is made to "/v1/kv/[Name]?dc=datacenter&cas=10" with the body "[Value]"
     And "[data-notification]" has the "notification-update" class
     And "[data-notification]" has the "success" class
   Where:
@@ -34,6 +35,7 @@ Feature: dc / kvs / update: KV Update
     And 1 kv model from yaml
     ---
       Key: key
+      ModifyIndex: 10
     ---
     When I visit the kv page for yaml
     ---
@@ -48,7 +50,7 @@ Feature: dc / kvs / update: KV Update
       value: '   '
     ---
     And I submit
-    Then a PUT request is made to "/v1/kv/key?dc=datacenter" with the body "   "
+    Then a PUT request is made to "/v1/kv/key?dc=datacenter&cas=10" with the body "   "
     Then the url should be /datacenter/kv
     And "[data-notification]" has the "notification-update" class
     And "[data-notification]" has the "success" class
@@ -56,6 +58,7 @@ Feature: dc / kvs / update: KV Update
     And 1 kv model from yaml
     ---
       Key: key
+      ModifyIndex: 10
     ---
     When I visit the kv page for yaml
     ---
@@ -70,7 +73,7 @@ Feature: dc / kvs / update: KV Update
       value: ''
     ---
     And I submit
-    Then a PUT request is made to "/v1/kv/key?dc=datacenter" with no body
+    Then a PUT request is made to "/v1/kv/key?dc=datacenter&cas=10" with no body
     Then the url should be /datacenter/kv
     And "[data-notification]" has the "notification-update" class
     And "[data-notification]" has the "success" class
@@ -79,6 +82,7 @@ Feature: dc / kvs / update: KV Update
     ---
     Key: key
     Value: ~
+    ModifyIndex: 10
     ---
     When I visit the kv page for yaml
     ---
@@ -87,7 +91,7 @@ Feature: dc / kvs / update: KV Update
     ---
     Then the url should be /datacenter/kv/key/edit
     And I submit
-    Then a PUT request is made to "/v1/kv/key?dc=datacenter" with no body
+    Then a PUT request is made to "/v1/kv/key?dc=datacenter&cas=10" with no body
     Then the url should be /datacenter/kv
     And "[data-notification]" has the "notification-update" class
     And "[data-notification]" has the "success" class
@@ -104,6 +108,28 @@ Feature: dc / kvs / update: KV Update
     Then the url should be /datacenter/kv/key/edit
     Then "[data-notification]" has the "notification-update" class
     And "[data-notification]" has the "error" class
+  Scenario: The key was modified since it was read
+    And 1 kv model from yaml
+    ---
+      Key: key
+      ModifyIndex: 10
+    ---
+    When I visit the kv page for yaml
+    ---
+      dc: datacenter
+      kv: key
+    ---
+    Then the url should be /datacenter/kv/key/edit
+
+    Given the url "/v1/kv/key" responds with from yaml
+    ---
+    status: 200
+    body: "false"
+    ---
+    And I submit
+    Then the url should be /datacenter/kv/key/edit
+    Then "[data-notification]" has the "notification-update" class
+    And "[data-notification]" has the "error" class
 @ignore
   Scenario: KV's with spaces are saved correctly
     Then ok
@@ -0,0 +1,16 @@
+import { moduleForComponent, test } from 'ember-qunit';
+import hbs from 'htmlbars-inline-precompile';
+
+moduleForComponent('topology-graph', 'Integration | Component | topology graph', {
+  integration: true,
+});
+
+test('it renders', function(assert) {
+  // Set any properties with this.set('myProperty', 'value');
+  // Handle any actions with this.on('myAction', function(val) { ... });
+
+  this.render(hbs`{{topology-graph}}`);
+
+  assert.equal(this.$('svg').length, 1);
+  assert.equal(this.$('circle').length, 0);
+});
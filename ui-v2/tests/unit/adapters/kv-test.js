@@ -6,7 +6,23 @@ import stubSuper from 'consul-ui/tests/helpers/stub-super';
 module('Unit | Adapter | kv', function(hooks) {
   setupTest(hooks);
 
-  skip('what should handleResponse return when createRecord is called with a `false` payload');
+  test('handleResponse throws a conflict when a check-and-set fails', function(assert) {
+    const adapter = this.owner.lookup('adapter:kv');
+    const url = '/v1/kv/key/name?dc=dc1&cas=10';
+    const it = stubSuper(adapter, function(status, headers, response, requestData) {
+      return response;
+    });
+    it('throws an error with a 409 code when called with a `false` payload', function() {
+      assert.throws(
+        function() {
+          adapter.handleResponse(200, {}, false, { url: url });
+        },
+        function(e) {
+          return e.code === 409;
+        }
+      );
+    });
+  });
 
   // Replace this with your real tests.
   test('it exists', function(assert) {
@@ -52,6 +68,23 @@ module('Unit | Adapter | kv', function(hooks) {
     });
   });
   skip('handleRequest for multiple type requests');
+  test('urlForCreateRecord and urlForUpdateRecord use check-and-set', function(assert) {
+    const adapter = this.owner.lookup('adapter:kv');
+    const snapshot = {
+      attr: function(prop) {
+        return {
+          Key: 'key/name',
+          Datacenter: 'dc1',
+          ModifyIndex: 10,
+        }[prop];
+      },
+    };
+    assert.equal(adapter.urlForCreateRecord('kv', snapshot), '/v1/kv/key/name?dc=dc1&cas=0');
+    assert.equal(
+      adapter.urlForUpdateRecord('uid', 'kv', snapshot),
+      '/v1/kv/key/name?dc=dc1&cas=10'
+    );
+  });
   test('dataForRequest returns', function(assert) {
     const adapter = this.owner.lookup('adapter:kv');
     // dataForRequest goes through window.atob
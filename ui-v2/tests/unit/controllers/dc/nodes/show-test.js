@@ -10,3 +10,23 @@ test('it exists', function(assert) {
   let controller = this.subject();
   assert.ok(controller);
 });
+test('checks only contains checks with the selected status', function(assert) {
+  const controller = this.subject();
+  const checks = [
+    { Name: 'passing', Status: 'passing' },
+    { Name: 'critical', Status: 'critical' },
+    { Name: 'warning', Status: 'warning' },
+  ];
+  controller.set('item', { Checks: checks });
+  assert.deepEqual(controller.get('checks'), checks);
+  const labels = controller.get('checkFilters').map(function(item) {
+    return item.label;
+  });
+  assert.deepEqual(labels, ['All (3)', 'Passing (1)', 'Warning (1)', 'Critical (1)']);
+  ['passing', 'critical', 'warning'].forEach(function(status, i) {
+    controller.send('filterChecks', { target: { value: status } });
+    assert.deepEqual(controller.get('checks'), [checks[i]]);
+  });
+  controller.send('filterChecks', { target: { value: '' } });
+  assert.deepEqual(controller.get('checks'), checks);
+});
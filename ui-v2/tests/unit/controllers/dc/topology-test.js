@@ -0,0 +1,49 @@
+import { moduleFor, test } from 'ember-qunit';
+
+moduleFor('controller:dc/topology', 'Unit | Controller | dc/topology', {
+  // Specify the other units that are required for this test.
+  // needs: ['controller:foo']
+});
+
+const coordinate = function(node, x, y) {
+  return {
+    data: {
+      Node: node,
+      Segment: '',
+      Coord: {
+        Vec: [x, y, 0, 0, 0, 0, 0, 0],
+        Height: 0,
+        Adjustment: 0,
+      },
+    },
+  };
+};
+test('it exists', function(assert) {
+  let controller = this.subject();
+  assert.ok(controller);
+});
+test('selected falls back to the first node', function(assert) {
+  const controller = this.subject();
+  controller.setProperties({
+    items: [coordinate('node-0', 0, 0), coordinate('node-1', 0.01, 0)],
+  });
+  assert.equal(controller.get('selected'), 'node-0');
+  controller.set('node', 'node-1');
+  assert.equal(controller.get('selected'), 'node-1');
+  controller.set('node', 'missing');
+  assert.equal(controller.get('selected'), 'node-0');
+});
+test('tomography is only calculated with more than one node', function(assert) {
+  const controller = this.subject();
+  controller.setProperties({
+    items: [coordinate('node-0', 0, 0)],
+  });
+  assert.equal(controller.get('tomography'), null);
+  controller.setProperties({
+    items: [coordinate('node-0', 0, 0), coordinate('node-1', 0.01, 0)],
+  });
+  const actual = controller.get('tomography');
+  assert.equal(actual.n, 1);
+  assert.equal(actual.distances[0].node, 'node-1');
+  assert.equal(actual.distances[0].distance, 10);
+});
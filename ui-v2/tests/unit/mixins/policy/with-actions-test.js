@@ -27,3 +27,38 @@ test('it works', function(assert) {
   const subject = this.subject();
   assert.ok(subject);
 });
+test('errorCreate and errorUpdate return a different status code if the rules are invalid', function(assert) {
+  const subject = this.subject();
+  const addError = this.stub();
+  subject.controller = {
+    item: {
+      Rules: 'key {',
+      addError: addError,
+    },
+  };
+  const e = {
+    errors: [
+      {
+        status: '500',
+        detail: 'rpc error making call: Failed to parse ACL rules: At 1:5: object expected',
+      },
+    ],
+  };
+  assert.equal(subject.errorCreate('error', e), 'invalid');
+  assert.equal(subject.errorUpdate('error', e), 'invalid');
+  assert.ok(
+    addError.calledWith('Rules', {
+      value: 'key {',
+      validation: 'Failed to parse ACL rules: At 1:5: object expected',
+    })
+  );
+});
+test('errorCreate and errorUpdate return the same code if there is no rules error', function(assert) {
+  const subject = this.subject();
+  const expected = 'error';
+  assert.equal(subject.errorCreate(expected, {}), expected);
+  assert.equal(
+    subject.errorUpdate(expected, { errors: [{ status: '500', detail: 'Permission denied' }] }),
+    expected
+  );
+});
@@ -0,0 +1,11 @@
+import { moduleFor, test } from 'ember-qunit';
+
+moduleFor('route:dc/topology', 'Unit | Route | dc/topology', {
+  // Specify the other units that are required for this test.
+  needs: ['service:repository/coordinate'],
+});
+
+test('it exists', function(assert) {
+  let route = this.subject();
+  assert.ok(route);
+});
@@ -0,0 +1,34 @@
+import { module } from 'ember-qunit';
+import test from 'ember-sinon-qunit/test-support/test';
+import projectionFactory from 'consul-ui/utils/projection';
+module('Unit | Utils | projection', {});
+
+const coordinate = function(node, x, y) {
+  return {
+    Node: node,
+    Segment: '',
+    Coord: {
+      Vec: [x, y, 0, 0, 0, 0, 0, 0],
+    },
+  };
+};
+test('it scales both axes by the same amount', function(assert) {
+  const projection = projectionFactory(100, 10);
+  const actual = projection([coordinate('a', 0, 0), coordinate('b', 2, 1)]);
+  assert.deepEqual(actual, [
+    { node: 'a', segment: '', x: 10, y: 30 },
+    { node: 'b', segment: '', x: 90, y: 70 },
+  ]);
+});
+test('it centres nodes that share a position', function(assert) {
+  const projection = projectionFactory(100);
+  const actual = projection([coordinate('a', 1, 1), coordinate('b', 1, 1)]);
+  assert.deepEqual(actual, [
+    { node: 'a', segment: '', x: 50, y: 50 },
+    { node: 'b', segment: '', x: 50, y: 50 },
+  ]);
+});
+test('it returns nothing for no coordinates', function(assert) {
+  const projection = projectionFactory(100);
+  assert.deepEqual(projection([]), []);
+});